@@ -7,8 +7,11 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"database/sql"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -18,42 +21,60 @@ import (
 	"time"
 
 	"github.com/moov-io/base/admin"
-	moovhttp "github.com/moov-io/base/http"
 	"github.com/moov-io/base/http/bind"
 
-	"github.com/moov-io/base/database"
 	mainPkg "github.com/moov-io/customers"
 
 	"github.com/moov-io/base/log"
 
 	"github.com/moov-io/customers/internal"
+	"github.com/moov-io/customers/internal/background"
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/internal/ids"
+	"github.com/moov-io/customers/internal/logging"
 	"github.com/moov-io/customers/internal/util"
 	"github.com/moov-io/customers/pkg/accounts"
+	"github.com/moov-io/customers/pkg/audit"
+	"github.com/moov-io/customers/pkg/auth"
 	"github.com/moov-io/customers/pkg/config"
 	"github.com/moov-io/customers/pkg/configuration"
 	"github.com/moov-io/customers/pkg/customers"
 	"github.com/moov-io/customers/pkg/documents"
 	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/email"
+	"github.com/moov-io/customers/pkg/export"
 	"github.com/moov-io/customers/pkg/fed"
+	"github.com/moov-io/customers/pkg/health"
+	"github.com/moov-io/customers/pkg/merge"
+	"github.com/moov-io/customers/pkg/notes"
 	"github.com/moov-io/customers/pkg/paygate"
+	"github.com/moov-io/customers/pkg/purge"
 	"github.com/moov-io/customers/pkg/reports"
+	"github.com/moov-io/customers/pkg/route"
 	"github.com/moov-io/customers/pkg/secrets"
+	"github.com/moov-io/customers/pkg/sms"
+	"github.com/moov-io/customers/pkg/timeline"
+	"github.com/moov-io/customers/pkg/tracing"
 	"github.com/moov-io/customers/pkg/validator"
 	"github.com/moov-io/customers/pkg/validator/microdeposits"
 	"github.com/moov-io/customers/pkg/validator/mx"
 	"github.com/moov-io/customers/pkg/validator/plaid"
 	"github.com/moov-io/customers/pkg/watchman"
+	"github.com/moov-io/customers/pkg/webhooks"
 
 	"github.com/gorilla/mux"
 	"github.com/mattn/go-sqlite3"
 	"gocloud.dev/blob/fileblob"
+	"google.golang.org/grpc"
 )
 
 var (
 	httpAddr  = flag.String("http.addr", bind.HTTP("customers"), "HTTP listen address")
 	adminAddr = flag.String("admin.addr", bind.Admin("customers"), "Admin HTTP listen address")
 
-	flagLogFormat = flag.String("log.format", "", "Format for log lines (Options: json, plain")
+	flagLogFormat = flag.String("log.format", "", "Format for log lines, overrides LOG_FORMAT (Options: json, plain)")
+
+	flagMigrateDryRun = flag.Bool("migrate.dry-run", false, "List pending database migrations and exit without applying them")
 
 	preventInsecureStartup = func() bool {
 		prevent, err := strconv.ParseBool(os.Getenv("PREVENT_INSECURE_STARTUP"))
@@ -79,11 +100,10 @@ type securityConfiguration struct {
 func main() {
 	flag.Parse()
 
-	var logger log.Logger
-	if strings.ToLower(*flagLogFormat) == "json" {
-		logger = log.NewJSONLogger()
-	} else {
-		logger = log.NewDefaultLogger()
+	logger, err := logging.NewLogger(os.Stderr, util.Or(*flagLogFormat, os.Getenv("LOG_FORMAT")), os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to setup logging: %v\n", err)
+		os.Exit(1)
 	}
 
 	logger = logger.Set("app", log.String("customers"))
@@ -98,6 +118,18 @@ func main() {
 		errs <- fmt.Errorf("%s", <-c)
 	}()
 
+	// Setup tracing
+	shutdownTracing, err := tracing.Setup(logger, tracing.Config{
+		Endpoint:    os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		Insecure:    util.Yes(os.Getenv("OTEL_EXPORTER_OTLP_INSECURE")),
+		ServiceName: util.Or(os.Getenv("OTEL_SERVICE_NAME"), "customers"),
+	})
+	if err != nil {
+		logger.LogErrorf("failed to setup tracing: %v", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing()
+
 	// Setup SQLite database
 	if sqliteVersion, _, _ := sqlite3.Version(); sqliteVersion != "" {
 		logger.Logf("sqlite version %s", sqliteVersion)
@@ -109,20 +141,71 @@ func main() {
 		os.Exit(1)
 	}
 
-	ctx := context.TODO()
-	db, err := database.NewAndMigrate(ctx, logger, *dbConf.Database)
+	if *flagMigrateDryRun || util.Yes(os.Getenv("DATABASE_MIGRATE_DRY_RUN")) {
+		pending, err := customersdb.PendingMigrations(logger, *dbConf.Database)
+		if err != nil {
+			logger.LogErrorf("failed to list pending migrations: %v", err)
+			os.Exit(1)
+		}
+		for i := range pending {
+			logger.Info().Logf("pending migration %s", pending[i])
+		}
+		logger.Info().Logf("dry run found %d pending migrations", len(pending))
+		os.Exit(0)
+	}
+	if util.Yes(os.Getenv("DISABLE_AUTO_MIGRATE")) {
+		logger.Info().Log("automatic migrations are disabled, verifying database schema")
+		if err := customersdb.VerifySchema(logger, *dbConf.Database); err != nil {
+			logger.LogErrorf("database schema isn't up to date: %v", err)
+			os.Exit(1)
+		}
+	} else if err := customersdb.Migrate(logger, *dbConf.Database); err != nil {
+		logger.LogErrorf("failed to migrate database: %v", err)
+		os.Exit(1)
+	}
+
+	// Background workers, and the database's connection metrics, run until shutdown
+	workers := background.NewGroup(context.Background())
+	shutdownTimeout, err := time.ParseDuration(util.Or(os.Getenv("SHUTDOWN_TIMEOUT"), "30s"))
+	if err != nil {
+		panic(fmt.Sprintf("invalid SHUTDOWN_TIMEOUT: %v", err))
+	}
+
+	queryTimeout, err := time.ParseDuration(util.Or(os.Getenv("DATABASE_QUERY_TIMEOUT"), "10s"))
+	if err != nil {
+		panic(fmt.Sprintf("invalid DATABASE_QUERY_TIMEOUT: %v", err))
+	}
+	if err := customersdb.SetQueryTimeout(queryTimeout); err != nil {
+		panic(fmt.Sprintf("invalid DATABASE_QUERY_TIMEOUT: %v", err))
+	}
+
+	idFormat, err := ids.ParseFormat(os.Getenv("CUSTOMER_ID_FORMAT"))
+	if err != nil {
+		panic(fmt.Sprintf("invalid CUSTOMER_ID_FORMAT: %v", err))
+	}
+	ids.SetFormat(idFormat)
+
+	db, err := customersdb.Connect(workers.Context(), logger, dbConf)
 	if err != nil {
 		logger.LogErrorf("failed to connect to database: %v", err)
 		os.Exit(1)
 	}
 	defer db.Close()
 
+	fieldEncryptor, err := setupFieldEncryptor()
+	if err != nil {
+		panic(err)
+	}
+
 	accountsRepo := accounts.NewRepo(logger, db)
-	customerRepo := customers.NewCustomerRepo(logger, db)
+	customerRepo := customers.NewCustomerRepoWithEncryption(logger, db, fieldEncryptor)
+	customerEmailRepo := customers.NewCustomerEmailRepository(logger, db, fieldEncryptor)
 	customerSSNRepo := customers.NewCustomerSSNRepository(logger, db)
+	contactPreferencesRepo := customers.NewContactPreferencesRepository(logger, db)
 	disclaimerRepo := documents.NewDisclaimerRepo(logger, db)
 	documentRepo := documents.NewDocumentRepo(logger, db)
 	validationsRepo := validator.NewRepo(db)
+	auditRepo := audit.NewRepository(logger, db)
 
 	// Start Admin server (with Prometheus metrics)
 	adminServer := admin.NewServer(*adminAddr)
@@ -135,21 +218,33 @@ func main() {
 		}
 	}()
 	defer adminServer.Shutdown()
+	adminServer.AddReadinessCheck("database", db.Ping)
 
 	// Create our Watchman client
-	debugWatchmanCalls := util.Or(os.Getenv("WATCHMAN_DEBUG_CALLS"), "false")
-	watchmanEndpoint := util.Or(os.Getenv("WATCHMAN_ENDPOINT"), os.Getenv("OFAC_ENDPOINT"))
-	watchmanClient := watchman.NewClient(logger, watchmanEndpoint, util.Yes(debugWatchmanCalls))
+	watchmanTimeout, err := time.ParseDuration(util.Or(os.Getenv("WATCHMAN_TIMEOUT"), "10s"))
+	if err != nil {
+		panic(fmt.Sprintf("invalid WATCHMAN_TIMEOUT: %v", err))
+	}
+	watchmanAuthToken, err := config.Secret("WATCHMAN_AUTH_TOKEN")
+	if err != nil {
+		panic(err)
+	}
+	watchmanClient := watchman.NewClient(logger, watchman.Config{
+		Endpoint:  util.Or(os.Getenv("WATCHMAN_ENDPOINT"), os.Getenv("OFAC_ENDPOINT")),
+		Timeout:   watchmanTimeout,
+		AuthToken: watchmanAuthToken,
+		Debug:     util.Yes(util.Or(os.Getenv("WATCHMAN_DEBUG_CALLS"), "false")),
+	})
 	if watchmanClient == nil {
 		panic("No Watchman client created, see WATCHMAN_ENDPOINT")
 	}
 	adminServer.AddLivenessCheck("watchman", watchmanClient.Ping)
 	ofac := customers.NewOFACSearcher(customerRepo, watchmanClient)
 
-	// Register our admin routes
-	documents.AddDisclaimerAdminRoutes(logger, adminServer, disclaimerRepo, documentRepo)
-
-	securityCfg := loadSecurityConfig()
+	securityCfg, err := loadSecurityConfig()
+	if err != nil {
+		panic(err)
+	}
 	missingOpts := checkMissingSecurityOptions(securityCfg)
 	if len(missingOpts) > 0 {
 		if preventInsecureStartup {
@@ -159,6 +254,79 @@ func main() {
 		logger.Warn().Log(fmt.Sprintf("running with insecure configuration - missing: %s", strings.Join(missingOpts, ", ")))
 	}
 
+	// Setup webhook deliveries
+	webhookRepo := webhooks.NewRepository(logger, db)
+	notifier, err := setupWebhookNotifier(logger, webhookRepo, workers)
+	if err != nil {
+		panic(err)
+	}
+
+	// Setup outbound emails and activation codes
+	emailRepo := email.NewRepository(logger, db)
+	emailSender, err := setupEmailSender()
+	if err != nil {
+		panic(err)
+	}
+	var activator *email.Activator
+	if emailSender != nil {
+		ttl, err := time.ParseDuration(util.Or(os.Getenv("EMAIL_ACTIVATION_CODE_TTL"), "24h"))
+		if err != nil {
+			panic(fmt.Sprintf("invalid EMAIL_ACTIVATION_CODE_TTL: %v", err))
+		}
+		maxResends, _ := strconv.Atoi(util.Or(os.Getenv("EMAIL_ACTIVATION_MAX_RESENDS"), "3"))
+		templates, err := email.LoadTemplates(os.Getenv("EMAIL_TEMPLATES_DIR"), os.Getenv)
+		if err != nil {
+			panic(err)
+		}
+		secret, err := config.Secret("EMAIL_ACTIVATION_SECRET")
+		if err != nil {
+			panic(err)
+		}
+		if secret = util.Or(secret, securityCfg.appSalt); secret == "" {
+			panic("EMAIL_ACTIVATION_SECRET or APP_SALT is required to send activation codes")
+		}
+		activator = email.NewActivator(logger, emailRepo, customerRepo, customerEmailRepo, email.ActivatorConfig{
+			TTL:        ttl,
+			MaxResends: maxResends,
+			Templates:  templates,
+			LinkURL:    os.Getenv("EMAIL_ACTIVATION_LINK_URL"),
+			Secret:     secret,
+		})
+		notifier = webhooks.MultiNotifier(notifier, activator)
+
+		worker, err := setupEmailWorker(logger, emailRepo, contactPreferencesRepo, emailSender)
+		if err != nil {
+			panic(err)
+		}
+		workers.Go(worker.Start)
+	}
+
+	// Setup periodic OFAC rescreens of every customer
+	rescreener, err := setupOFACRescreener(logger, db, customerRepo, ofac, notifier)
+	if err != nil {
+		panic(err)
+	}
+	workers.Go(rescreener.Start)
+
+	// Delete OFAC searches past their retention period
+	ofacPurger, err := setupOFACSearchRetention(logger, db)
+	if err != nil {
+		panic(err)
+	}
+	workers.Go(ofacPurger.Start)
+
+	// Register our admin routes
+	customers.AddCustomerAdminRoutes(logger, adminServer, customerRepo)
+	documents.AddDisclaimerAdminRoutes(logger, adminServer, disclaimerRepo, documentRepo)
+	documents.AddDocumentAdminRoutes(logger, adminServer, documentRepo)
+	webhooks.AddAdminRoutes(logger, adminServer, webhookRepo)
+	audit.AddAdminRoutes(logger, adminServer, auditRepo)
+	notes.AddAdminRoutes(logger, adminServer, notes.NewRepository(logger, db))
+	customersdb.AddMigrationAdminRoutes(logger, adminServer, db)
+	email.AddAdminRoutes(logger, adminServer, emailRepo)
+	customers.AddOFACRescreenAdminRoutes(logger, adminServer, rescreener)
+	customers.AddFieldReencryptionAdminRoutes(logger, adminServer, customers.NewFieldReencryptor(logger, customerRepo, 100))
+
 	// Setup Customer SSN storage wrapper
 	keeper, err := secrets.OpenSecretKeeper(context.Background(), "customer-ssn", securityCfg.ssnSecretsProvider, securityCfg.ssnLocalKey)
 	if err != nil {
@@ -166,7 +334,24 @@ func main() {
 	}
 	stringKeeper := secrets.NewStringKeeper(keeper, 10*time.Second)
 
-	customerSSNStorage := customers.NewSSNStorage(stringKeeper, customerSSNRepo)
+	if err := customers.SetRequiredTaxForms(os.Getenv("REQUIRED_TAX_FORMS")); err != nil {
+		panic(err)
+	}
+
+	customerSSNStorage := customers.NewSSNStorage(stringKeeper, customerSSNRepo, securityCfg.appSalt)
+	if path := os.Getenv("SSN_DENYLIST_PATH"); path != "" {
+		salt, err := config.Secret("SSN_DENYLIST_SALT")
+		if err != nil {
+			panic(err)
+		}
+		denylist, err := customers.NewSSNDenylist(path, salt)
+		if err != nil {
+			panic(err)
+		}
+		customerSSNStorage.UseDenylist(denylist)
+		customers.AddSSNDenylistAdminRoutes(logger, adminServer, denylist)
+	}
+	customers.AddSSNRemaskAdminRoutes(logger, adminServer, customers.NewSSNRemasker(logger, db, stringKeeper))
 
 	// read transit keeper
 	transitKeeper, err := secrets.OpenLocal(securityCfg.transitLocalKey)
@@ -197,14 +382,58 @@ func main() {
 	}
 
 	// Setup business HTTP routes
+	authenticator, err := setupAuthenticator()
+	if err != nil {
+		panic(err)
+	}
+	cors, err := setupCORS()
+	if err != nil {
+		panic(err)
+	}
 	router := mux.NewRouter()
-	moovhttp.AddCORSHandler(router)
+	router.Use(route.RequestIDMiddleware)
+	router.Use(route.MetricsMiddleware)
+	router.Use(cors.Middleware)
+	router.Use(tracing.Middleware)
+	router.Use(auth.Middleware(logger, authenticator, "/ping", "/live", "/ready", "/files"))
+	router.Use(audit.Middleware(logger, auditRepo, customerRepo))
+	router.Use(documents.RequireDisclaimers(logger, disclaimerRepo, documents.ReadRequiredDisclaimers(os.Getenv("REQUIRED_DISCLAIMERS")), "/customers/{customerID}/accounts"))
+	cors.AddPreflightRoute(router)
 	addPingRoute(router)
+	health.AddRoutes(logger, router, 5*time.Second,
+		health.Check{Name: "database", Check: db.Ping},
+		health.Check{Name: "watchman", Check: watchmanClient.Ping},
+	)
 	accounts.RegisterRoutes(logger, router, accountsRepo, validationsRepo, fedClient, stringKeeper, transitStringKeeper, validationStrategies, &accountOfacSeacher, securityCfg.appSalt)
-	customers.AddCustomerRoutes(logger, router, customerRepo, customerSSNStorage, ofac)
-	customers.AddCustomerAddressRoutes(logger, router, customerRepo)
-	customers.AddRepresentativeRoutes(logger, router, customerRepo, customerSSNStorage)
+	customers.AddReviewRoutes(logger, router, customerRepo, customers.NewReviewRepository(logger, db))
+	addressVerifier, err := customers.NewAddressVerifier(logger)
+	if err != nil {
+		panic(err)
+	}
+	customers.AddCustomerRoutes(logger, router, customerRepo, customerSSNStorage, ofac, notifier, addressVerifier)
+	customers.AddCustomerAddressRoutes(logger, router, customerRepo, addressVerifier)
+	customers.AddContactPreferenceRoutes(logger, router, customerRepo, contactPreferencesRepo)
+	customers.AddCustomerEmailRoutes(logger, router, customerRepo, customerEmailRepo)
+	customers.AddTagRoutes(logger, router, customerRepo, customers.NewTagRepository(logger, db))
+	customers.AddRelationshipRoutes(logger, router, customerRepo, customers.NewRelationshipRepository(logger, db))
+	customers.AddRepresentativeRoutes(logger, router, customerRepo, customerSSNStorage, ofac, notifier)
 	documents.AddDisclaimerRoutes(logger, router, disclaimerRepo)
+	if activator != nil {
+		email.AddRoutes(logger, router, activator)
+	}
+	verifier, err := setupPhoneVerifier(logger, db, customerRepo, fieldEncryptor, securityCfg.appSalt)
+	if err != nil {
+		panic(err)
+	}
+	if verifier != nil {
+		sms.AddRoutes(logger, router, verifier)
+	}
+
+	exportService := export.NewService(customers.NewExporter(customerRepo, customerEmailRepo, customerSSNStorage), documents.NewExporter(documentRepo, disclaimerRepo))
+	export.AddRoutes(logger, router, exportService)
+	timeline.AddRoutes(logger, router, timeline.NewRepository(db))
+	export.AddAdminRoutes(logger, adminServer, exportService)
+	customers.AddCustomerStreamAdminRoutes(logger, adminServer, customers.NewCustomerStreamer(customerRepo, customerSSNStorage))
 
 	signer := setupSigner(logger, securityCfg.docStorageProvider, securityCfg.fileblobURLSecret)
 	bucket := storage.GetBucket(logger, securityCfg.docBucketName, securityCfg.docStorageProvider, signer)
@@ -214,7 +443,43 @@ func main() {
 	}
 	defer docsKeeper.Close()
 
-	documents.AddDocumentRoutes(logger, router, documentRepo, docsKeeper, bucket)
+	// Scan uploaded documents for viruses before they're stored
+	scanRepo := documents.NewScanRepo(logger, db)
+	scanner, err := setupDocumentScanner(logger, scanRepo)
+	if err != nil {
+		panic(err)
+	}
+	documents.AddScanAdminRoutes(logger, adminServer, scanRepo)
+
+	uploadRepo := documents.NewUploadRepo(logger, db)
+	documents.AddUploadRoutes(logger, router, documentRepo, uploadRepo, docsKeeper, bucket, scanner, notifier)
+	documents.AddDocumentRoutes(logger, router, documentRepo, docsKeeper, bucket, scanner, notifier)
+	documents.AddAvatarRoutes(logger, router, documents.NewAvatarRepo(logger, db), docsKeeper, bucket)
+	purge.AddAdminRoutes(logger, adminServer, purge.NewPurger(db, bucket))
+
+	// Purge the blobs of deleted documents after their retention period
+	sweeper, err := setupDocumentRetention(logger, documentRepo, bucket)
+	if err != nil {
+		panic(err)
+	}
+	workers.Go(sweeper.Start)
+
+	// Remove partial uploads which stopped receiving chunks
+	uploadSweeper, err := setupDocumentUploads(logger, uploadRepo, bucket)
+	if err != nil {
+		panic(err)
+	}
+	workers.Go(uploadSweeper.Start)
+
+	// Alert on documents which are about to expire
+	expiryAlerter, err := setupDocumentExpiryAlerts(logger, documentRepo, notifier)
+	if err != nil {
+		panic(err)
+	}
+	workers.Go(expiryAlerter.Start)
+
+	merge.AddAdminRoutes(logger, adminServer, merge.NewMerger(logger, db, bucket, fieldEncryptor), notifier)
+	documents.AddReassignAdminRoutes(logger, adminServer, documents.NewReassigner(logger, db, bucket))
 
 	// Optionally serve /files/ as our fileblob routes
 	// Note: FILEBLOB_BASE_URL needs to match something that's routed to /files/...
@@ -222,13 +487,16 @@ func main() {
 		storage.AddFileblobRoutes(logger, router, signer, bucket)
 	}
 
-	customers.AddOFACRoutes(logger, router, customerRepo, ofac)
+	customers.AddOFACRoutes(logger, router, customerRepo, ofac, notifier)
 	reports.AddRoutes(logger, router, customerRepo, accountsRepo)
 
 	// Add Configuration routes
 	configRepo := configuration.NewRepository(db)
 	configuration.RegisterRoutes(logger, router, configRepo, bucket)
 
+	// Optionally serve the gRPC API on its own port
+	shutdownGRPC := setupGRPCServer(logger, customers.NewGRPCServer(logger, authenticator, customerRepo, customerSSNStorage, ofac, notifier))
+
 	// Start business HTTP server
 	readTimeout, _ := time.ParseDuration("30s")
 	writTimeout, _ := time.ParseDuration("30s")
@@ -247,7 +515,9 @@ func main() {
 		IdleTimeout:  idleTimeout,
 	}
 	shutdownServer := func() {
-		if err := serve.Shutdown(context.TODO()); err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := serve.Shutdown(ctx); err != nil {
 			logger.Set("phase", log.String("shutdown")).LogErrorf("failed to shutdown server: %v", err)
 		}
 	}
@@ -256,12 +526,12 @@ func main() {
 	go func() {
 		if certFile, keyFile := os.Getenv("HTTPS_CERT_FILE"), os.Getenv("HTTPS_KEY_FILE"); certFile != "" && keyFile != "" {
 			logger.Set("phase", log.String("startup")).Logf("binding to %s for secure HTTP server", *httpAddr)
-			if err := serve.ListenAndServeTLS(certFile, keyFile); err != nil {
+			if err := serve.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
 				logger.LogErrorf("failed to start TLS server: %v", err)
 			}
 		} else {
 			logger.Set("phase", log.String("startup")).Logf("binding to %s for HTTP server", *httpAddr)
-			if err := serve.ListenAndServe(); err != nil {
+			if err := serve.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 				logger.LogErrorf("failed to start server: %v", err)
 			}
 		}
@@ -269,34 +539,53 @@ func main() {
 
 	// Block/Wait for an error
 	if err := <-errs; err != nil {
-		shutdownServer()
 		logger.LogErrorf("service error: %v", err)
 	}
+
+	// Stop accepting requests and let in-flight ones finish, then stop the background workers
+	// before the database is closed
+	shutdownGRPC()
+	shutdownServer()
+	if !workers.Stop(shutdownTimeout) {
+		logger.Set("phase", log.String("shutdown")).LogErrorf("background workers still running after %v", shutdownTimeout)
+	}
 }
 
 func addPingRoute(r *mux.Router) {
 	r.Methods("GET").Path("/ping").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		moovhttp.SetAccessControlAllowHeaders(w, r.Header.Get("Origin"))
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("PONG"))
 	})
 }
 
-func loadSecurityConfig() *securityConfiguration {
+func loadSecurityConfig() (*securityConfiguration, error) {
 	cfg := &securityConfiguration{
-		appSalt:            os.Getenv("APP_SALT"),
 		ssnSecretsProvider: util.Or(os.Getenv("SSN_SECRET_PROVIDER"), "local"),
-		ssnLocalKey:        os.Getenv("SSN_SECRET_KEY"),
 		docSecretsProvider: util.Or(os.Getenv("DOCUMENTS_SECRET_PROVIDER"), "local"),
-		docLocalKey:        os.Getenv("DOCUMENTS_SECRET_KEY"),
 		docStorageProvider: util.Or(os.Getenv("DOCUMENTS_STORAGE_PROVIDER"), "file"),
 		docBucketName:      util.Or(os.Getenv("DOCUMENTS_BUCKET_NAME"), "./storage"),
-		fileblobURLSecret:  os.Getenv("FILEBLOB_HMAC_SECRET"),
-		transitLocalKey:    os.Getenv("TRANSIT_LOCAL_BASE64_KEY"),
 	}
 
-	return cfg
+	// each secret can also be read from a file named by its _FILE variable
+	secretOpts := []struct {
+		key   string
+		value *string
+	}{
+		{"APP_SALT", &cfg.appSalt},
+		{"SSN_SECRET_KEY", &cfg.ssnLocalKey},
+		{"DOCUMENTS_SECRET_KEY", &cfg.docLocalKey},
+		{"FILEBLOB_HMAC_SECRET", &cfg.fileblobURLSecret},
+		{"TRANSIT_LOCAL_BASE64_KEY", &cfg.transitLocalKey},
+	}
+	for _, opt := range secretOpts {
+		v, err := config.Secret(opt.key)
+		if err != nil {
+			return nil, err
+		}
+		*opt.value = v
+	}
+	return cfg, nil
 }
 
 func checkMissingSecurityOptions(cfg *securityConfiguration) []string {
@@ -338,6 +627,323 @@ func setupSigner(logger log.Logger, cloudProvider, secret string) *fileblob.URLS
 	return nil
 }
 
+func setupWebhookNotifier(logger log.Logger, repo webhooks.Repository, workers *background.Group) (webhooks.Notifier, error) {
+	events, err := webhooks.ReadEventTypes(os.Getenv("WEBHOOK_EVENTS"))
+	if err != nil {
+		return nil, err
+	}
+	maxAttempts, _ := strconv.Atoi(util.Or(os.Getenv("WEBHOOK_MAX_ATTEMPTS"), "5"))
+	backoff, err := time.ParseDuration(util.Or(os.Getenv("WEBHOOK_BACKOFF"), "1s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_BACKOFF: %v", err)
+	}
+	secret, err := config.Secret("WEBHOOK_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	return webhooks.NewNotifier(logger, repo, webhooks.Config{
+		Endpoint:    os.Getenv("WEBHOOK_ENDPOINT"),
+		Secret:      secret,
+		Events:      events,
+		MaxAttempts: maxAttempts,
+		Backoff:     backoff,
+		Workers:     workers,
+	})
+}
+
+func setupEmailSender() (email.EmailSender, error) {
+	password, err := config.Secret("SMTP_PASSWORD")
+	if err != nil {
+		return nil, err
+	}
+	return email.NewSender(email.SenderConfig{
+		Provider: os.Getenv("EMAIL_SENDER"),
+		From:     os.Getenv("EMAIL_FROM"),
+		SMTP: email.SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: password,
+		},
+		SES: email.SESConfig{
+			Region: util.Or(os.Getenv("SES_REGION"), os.Getenv("AWS_REGION")),
+		},
+	})
+}
+
+func setupEmailWorker(logger log.Logger, repo email.Repository, prefs customers.ContactPreferencesRepository, sender email.EmailSender) (*email.Worker, error) {
+	interval, err := time.ParseDuration(util.Or(os.Getenv("EMAIL_SEND_INTERVAL"), "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_SEND_INTERVAL: %v", err)
+	}
+	backoff, err := time.ParseDuration(util.Or(os.Getenv("EMAIL_BACKOFF"), "1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_BACKOFF: %v", err)
+	}
+	maxAttempts, _ := strconv.Atoi(util.Or(os.Getenv("EMAIL_MAX_ATTEMPTS"), "5"))
+	return email.NewWorker(logger, repo, prefs, sender, email.WorkerConfig{
+		Interval:    interval,
+		MaxAttempts: maxAttempts,
+		Backoff:     backoff,
+	}), nil
+}
+
+// setupPhoneVerifier returns nil when no SMS provider is configured
+func setupPhoneVerifier(logger log.Logger, db *sql.DB, customerRepo customers.CustomerRepository, fields *secrets.FieldEncryptor, appSalt string) (*sms.Verifier, error) {
+	authToken, err := config.Secret("TWILIO_AUTH_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	sender, err := sms.NewSender(sms.SenderConfig{
+		Provider: os.Getenv("SMS_PROVIDER"),
+		From:     os.Getenv("SMS_FROM"),
+		Twilio: sms.TwilioConfig{
+			AccountSID: os.Getenv("TWILIO_ACCOUNT_SID"),
+			AuthToken:  authToken,
+		},
+	})
+	if err != nil || sender == nil {
+		return nil, err
+	}
+	ttl, err := time.ParseDuration(util.Or(os.Getenv("PHONE_VERIFICATION_CODE_TTL"), "10m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PHONE_VERIFICATION_CODE_TTL: %v", err)
+	}
+	maxAttempts, _ := strconv.Atoi(util.Or(os.Getenv("PHONE_VERIFICATION_MAX_ATTEMPTS"), "5"))
+	secret, err := config.Secret("PHONE_VERIFICATION_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	return sms.NewVerifier(logger, sms.NewRepository(logger, db, fields), customerRepo, sender, sms.VerifierConfig{
+		TTL:         ttl,
+		MaxAttempts: maxAttempts,
+		Secret:      util.Or(secret, appSalt),
+	}), nil
+}
+
+// setupFieldEncryptor reads the keys customer emails and phone numbers are encrypted with. They're
+// left in plaintext when FIELD_ENCRYPTION_KEYS is empty.
+func setupFieldEncryptor() (*secrets.FieldEncryptor, error) {
+	v, err := config.Secret("FIELD_ENCRYPTION_KEYS")
+	if err != nil {
+		return nil, err
+	}
+	keys, err := secrets.ParseFieldKeys(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FIELD_ENCRYPTION_KEYS: %v", err)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return secrets.NewFieldEncryptor(keys...)
+}
+
+// setupCORS reads which browser origins can call the API. No origins are allowed unless
+// CORS_ALLOWED_ORIGINS is set.
+func setupCORS() (*route.CORS, error) {
+	maxAge, err := time.ParseDuration(util.Or(os.Getenv("CORS_MAX_AGE"), "10m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CORS_MAX_AGE: %v", err)
+	}
+	cfg := route.CORSConfig{
+		AllowedOrigins:   splitList(os.Getenv("CORS_ALLOWED_ORIGINS")),
+		AllowedMethods:   splitList(os.Getenv("CORS_ALLOWED_METHODS")),
+		AllowedHeaders:   splitList(os.Getenv("CORS_ALLOWED_HEADERS")),
+		ExposedHeaders:   splitList(os.Getenv("CORS_EXPOSED_HEADERS")),
+		AllowCredentials: util.Yes(os.Getenv("CORS_ALLOW_CREDENTIALS")),
+		MaxAge:           maxAge,
+	}
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = route.DefaultCORSMethods
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = route.DefaultCORSHeaders
+	}
+	if len(cfg.ExposedHeaders) == 0 {
+		cfg.ExposedHeaders = route.DefaultCORSExposed
+	}
+	return route.NewCORS(cfg)
+}
+
+// splitList returns the non-empty values of a comma separated list
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// setupAuthenticator returns nil, leaving requests unauthenticated, unless AUTH_PROVIDER is set
+func setupAuthenticator() (auth.Authenticator, error) {
+	jwtSecret, err := config.Secret("AUTH_JWT_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	clientSecret, err := config.Secret("AUTH_INTROSPECTION_CLIENT_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	cfg := auth.Config{
+		Provider: os.Getenv("AUTH_PROVIDER"),
+		JWT: auth.JWTConfig{
+			Secret:            jwtSecret,
+			Issuer:            os.Getenv("AUTH_JWT_ISSUER"),
+			Audience:          os.Getenv("AUTH_JWT_AUDIENCE"),
+			OrganizationClaim: os.Getenv("AUTH_ORGANIZATION_CLAIM"),
+		},
+		Introspection: auth.IntrospectionConfig{
+			Endpoint:          os.Getenv("AUTH_INTROSPECTION_ENDPOINT"),
+			ClientID:          os.Getenv("AUTH_INTROSPECTION_CLIENT_ID"),
+			ClientSecret:      clientSecret,
+			OrganizationClaim: os.Getenv("AUTH_ORGANIZATION_CLAIM"),
+		},
+	}
+	if path := os.Getenv("AUTH_JWT_PUBLIC_KEY_FILE"); path != "" {
+		key, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading AUTH_JWT_PUBLIC_KEY_FILE: %v", err)
+		}
+		cfg.JWT.PublicKey = key
+	}
+	ttl, err := time.ParseDuration(util.Or(os.Getenv("AUTH_INTROSPECTION_CACHE_TTL"), "0s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_INTROSPECTION_CACHE_TTL: %v", err)
+	}
+	cfg.Introspection.CacheTTL = ttl
+	return auth.NewAuthenticator(cfg)
+}
+
+// setupDocumentRetention returns a sweeper which keeps deleted documents forever unless
+// DOCUMENTS_RETENTION_PERIOD is set
+func setupDocumentRetention(logger log.Logger, repo documents.DocumentRepository, bucket storage.BucketFunc) (*documents.RetentionSweeper, error) {
+	period, err := time.ParseDuration(util.Or(os.Getenv("DOCUMENTS_RETENTION_PERIOD"), "0s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOCUMENTS_RETENTION_PERIOD: %v", err)
+	}
+	interval, err := time.ParseDuration(util.Or(os.Getenv("DOCUMENTS_RETENTION_SWEEP_INTERVAL"), "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOCUMENTS_RETENTION_SWEEP_INTERVAL: %v", err)
+	}
+	return documents.NewRetentionSweeper(logger, repo, bucket, documents.RetentionConfig{
+		Period:   period,
+		Interval: interval,
+	}), nil
+}
+
+// setupDocumentScanner returns a Scanner for DOCUMENTS_SCANNER or nil when uploaded documents aren't scanned
+func setupDocumentScanner(logger log.Logger, repo documents.ScanRepository) (*documents.Scanner, error) {
+	var scanner documents.DocumentScanner
+	switch name := strings.ToLower(os.Getenv("DOCUMENTS_SCANNER")); name {
+	case "":
+		return nil, nil
+	case "clamd":
+		timeout, err := time.ParseDuration(util.Or(os.Getenv("DOCUMENTS_SCANNER_TIMEOUT"), "30s"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid DOCUMENTS_SCANNER_TIMEOUT: %v", err)
+		}
+		scanner, err = documents.NewClamdScanner(util.Or(os.Getenv("DOCUMENTS_SCANNER_CLAMD_ADDRESS"), "tcp://localhost:3310"), timeout)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown DOCUMENTS_SCANNER: %s", name)
+	}
+	failOpen := util.Yes(os.Getenv("DOCUMENTS_SCANNER_FAIL_OPEN"))
+	logger.Logf("scanning uploaded documents with %s (fail open: %v)", os.Getenv("DOCUMENTS_SCANNER"), failOpen)
+	return documents.NewScanner(logger, scanner, repo, documents.ScanConfig{
+		FailOpen: failOpen,
+	}), nil
+}
+
+// setupDocumentUploads returns a sweeper which removes partial uploads after DOCUMENTS_UPLOAD_EXPIRATION
+// without a new chunk
+func setupDocumentUploads(logger log.Logger, repo documents.UploadRepository, bucket storage.BucketFunc) (*documents.UploadSweeper, error) {
+	expiration, err := time.ParseDuration(util.Or(os.Getenv("DOCUMENTS_UPLOAD_EXPIRATION"), "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOCUMENTS_UPLOAD_EXPIRATION: %v", err)
+	}
+	interval, err := time.ParseDuration(util.Or(os.Getenv("DOCUMENTS_UPLOAD_SWEEP_INTERVAL"), "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOCUMENTS_UPLOAD_SWEEP_INTERVAL: %v", err)
+	}
+	return documents.NewUploadSweeper(logger, repo, bucket, documents.UploadConfig{
+		Expiration: expiration,
+		Interval:   interval,
+	}), nil
+}
+
+// setupDocumentExpiryAlerts returns an alerter which only sends document.expiring webhooks when
+// DOCUMENTS_EXPIRY_ALERT_WINDOW is set
+func setupDocumentExpiryAlerts(logger log.Logger, repo documents.DocumentRepository, notifier webhooks.Notifier) (*documents.ExpiryAlerter, error) {
+	window, err := time.ParseDuration(util.Or(os.Getenv("DOCUMENTS_EXPIRY_ALERT_WINDOW"), "0s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOCUMENTS_EXPIRY_ALERT_WINDOW: %v", err)
+	}
+	interval, err := time.ParseDuration(util.Or(os.Getenv("DOCUMENTS_EXPIRY_ALERT_INTERVAL"), "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOCUMENTS_EXPIRY_ALERT_INTERVAL: %v", err)
+	}
+	return documents.NewExpiryAlerter(logger, repo, notifier, documents.ExpiryConfig{
+		Window:   window,
+		Interval: interval,
+	}), nil
+}
+
+func setupOFACRescreener(logger log.Logger, db *sql.DB, repo customers.CustomerRepository, ofac *customers.OFACSearcher, notifier webhooks.Notifier) (*customers.OFACRescreener, error) {
+	interval, err := time.ParseDuration(util.Or(os.Getenv("OFAC_RESCREEN_INTERVAL"), "0s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OFAC_RESCREEN_INTERVAL: %v", err)
+	}
+	delay, err := time.ParseDuration(util.Or(os.Getenv("OFAC_RESCREEN_DELAY"), "100ms"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OFAC_RESCREEN_DELAY: %v", err)
+	}
+	batchSize, _ := strconv.Atoi(util.Or(os.Getenv("OFAC_RESCREEN_BATCH_SIZE"), "100"))
+	return customers.NewOFACRescreener(logger, db, repo, ofac, notifier, customers.RescreenConfig{
+		Interval:  interval,
+		Delay:     delay,
+		BatchSize: batchSize,
+	}), nil
+}
+
+// setupOFACSearchRetention returns a purger which keeps every OFAC search unless OFAC_SEARCH_RETENTION_PERIOD is set
+func setupOFACSearchRetention(logger log.Logger, db *sql.DB) (*customers.OFACSearchPurger, error) {
+	period, err := time.ParseDuration(util.Or(os.Getenv("OFAC_SEARCH_RETENTION_PERIOD"), "0s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OFAC_SEARCH_RETENTION_PERIOD: %v", err)
+	}
+	interval, err := time.ParseDuration(util.Or(os.Getenv("OFAC_SEARCH_RETENTION_INTERVAL"), "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OFAC_SEARCH_RETENTION_INTERVAL: %v", err)
+	}
+	return customers.NewOFACSearchPurger(logger, db, customers.OFACRetentionConfig{
+		Period:   period,
+		Interval: interval,
+	}), nil
+}
+
+// setupGRPCServer starts srv when GRPC_ENABLED is set and returns a func to stop it
+func setupGRPCServer(logger log.Logger, srv *grpc.Server) func() {
+	if !util.Yes(os.Getenv("GRPC_ENABLED")) {
+		return func() {}
+	}
+	addr := util.Or(os.Getenv("GRPC_BIND_ADDRESS"), ":9087")
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		panic(logger.LogErrorf("failed to bind gRPC server: %v", err))
+	}
+	go func() {
+		logger.Set("phase", log.String("startup")).Logf("binding to %s for gRPC server", addr)
+		if err := srv.Serve(listener); err != nil {
+			logger.LogErrorf("failed to start gRPC server: %v", err)
+		}
+	}()
+	return srv.GracefulStop
+}
+
 func setupValidationStrategies(logger log.Logger, adminServer *admin.Server) (map[validator.StrategyKey]validator.Strategy, error) {
 	strategies := map[validator.StrategyKey]validator.Strategy{}
 
@@ -350,9 +956,13 @@ func setupValidationStrategies(logger log.Logger, adminServer *admin.Server) (ma
 
 	// setup Plaid instant account verification
 	if os.Getenv("PLAID_CLIENT_ID") != "" {
+		secret, err := config.Secret("PLAID_SECRET")
+		if err != nil {
+			return nil, err
+		}
 		options := plaid.StrategyOptions{
 			ClientID:    os.Getenv("PLAID_CLIENT_ID"),
-			Secret:      os.Getenv("PLAID_SECRET"),
+			Secret:      secret,
 			Environment: util.Or(os.Getenv("PLAID_ENVIRONMENT"), "sandbox"),
 			ClientName:  os.Getenv("PLAID_CLIENT_NAME"),
 		}
@@ -366,9 +976,13 @@ func setupValidationStrategies(logger log.Logger, adminServer *admin.Server) (ma
 	}
 
 	if os.Getenv("ATRIUM_CLIENT_ID") != "" {
+		apiKey, err := config.Secret("ATRIUM_API_KEY")
+		if err != nil {
+			return nil, err
+		}
 		options := mx.StrategyOptions{
 			ClientID: os.Getenv("ATRIUM_CLIENT_ID"),
-			APIKey:   os.Getenv("ATRIUM_API_KEY"),
+			APIKey:   apiKey,
 		}
 		strategy := mx.NewStrategy(options)
 		strategies[validator.StrategyKey{Strategy: "instant", Vendor: "mx"}] = strategy
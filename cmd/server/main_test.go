@@ -5,7 +5,9 @@
 package main
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/moov-io/base/admin"
@@ -57,7 +59,8 @@ func TestSecurityConfiguration_emptyConfig(t *testing.T) {
 }
 
 func TestSecurityConfiguration_defaultConfig(t *testing.T) {
-	cfg := loadSecurityConfig()
+	cfg, err := loadSecurityConfig()
+	require.NoError(t, err)
 
 	missing := checkMissingSecurityOptions(cfg)
 
@@ -65,7 +68,8 @@ func TestSecurityConfiguration_defaultConfig(t *testing.T) {
 }
 
 func TestSecurityConfiguration_completeConfig(t *testing.T) {
-	cfg := loadSecurityConfig()
+	cfg, err := loadSecurityConfig()
+	require.NoError(t, err)
 	cfg.appSalt = "appSalt"
 	cfg.docLocalKey = "docKey"
 	cfg.ssnLocalKey = "ssnKey"
@@ -76,3 +80,22 @@ func TestSecurityConfiguration_completeConfig(t *testing.T) {
 
 	require.Empty(t, missing)
 }
+
+func TestSecurityConfiguration_secretFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "customers-secrets")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "app-salt")
+	require.NoError(t, ioutil.WriteFile(path, []byte("salty\n"), 0600))
+	os.Setenv("APP_SALT_FILE", path)
+	t.Cleanup(func() { os.Unsetenv("APP_SALT_FILE") })
+
+	cfg, err := loadSecurityConfig()
+	require.NoError(t, err)
+	require.Equal(t, "salty", cfg.appSalt)
+
+	os.Setenv("APP_SALT_FILE", filepath.Join(dir, "missing"))
+	_, err = loadSecurityConfig()
+	require.Error(t, err)
+}
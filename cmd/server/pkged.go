@@ -9,4 +9,4 @@ import (
 	"github.com/markbates/pkger/pkging/mem"
 )
 
-var _ = pkger.Apply(mem.UnmarshalEmbed([]byte(`1f8b08000000000000ffec9d5d73a2c8f7c7dfca16d74ed20f34d0a9fa5d442712938d3b310ac8d6568aa72011c4bf6812dd9af7fe2f400163d4c6d1752ea8adad8972bae1007ebedda7fb74ffcb79a39730e2aefee55c6f3a98991756185c0661f8f6cd0b2fad59340d0367921cffee4db82beeb2173993e8d29c18233b1cbd868351148e2e7f4cc257c79a46970f61f8562856e35ac1389c4c7f18d30177b5fb1435ae6d040e77c515bffa1e5adc15c7d5b8ae31719de9d60b70c34b7801c905b81c0fdd4bdb98bc7ba36723b005feb270d2f4fb457ed20b83ab719d3064a898ab710fc6d41a70577f7317dc3f35ee696af80e77359dcc9ce5878e6344e188bbe2cc99e7db7fb4beff1178519014aa7172d8f47c278a8bdba175e1865c8d8ba6c67416a57fbf3993c80b47f1877f56b72de2ae4633dfaf71df9d71f677d789a65965f9579f4a3c84f62cbebc7f39b67bff6078a39533873d69397c08edb2e52eddf02208eda4b892de01ee8a831790e77efefc59e35e523777bf9d579781e74e8ca9178e12dbf8958effb59da9e1f9c957a3f4d52ad8d5b8c85b38dc158404d5b820b41dee0a415ee4251ef230f9e679ea25a51040e01b84df00e9427205842b482e7848450902fe1b10af40fc7278d1b31ddfb7f41646f3f437e3bc71575010451161903c94f88c82248a35aeed7ba3217785851ad71a85dc15219010213e75cfb3b92b02608d93e3bf10a8711d3bae09d4b81f86fd6cb9e133e0aefe06b5e4bf7f6adc757ca5d1d8b1e2933ec5ff4001f08284a0006a5c3b8abf117804289230fa59e31ebeb687988a6465bff210fcac718d92f6756f321d7c5506f3a280f332a2c8f388108c7fd6b8a7c2e3a8fba1358c1287ebfe307d503ca0428d6bfa869b1e909d51f2ef9fd1d89838c99f8fcb3fe33bf34ff2fe0c9c49c5ae8a5dff3dbb6adc38b9f67fb91f4397f9f52982ec678db38da9b1ba29f19b3d9ae615e68592b3b1f2f11200f86c4d1c63ea3ce7afd26c7c11fd9fbf9b9dbb0aae60ca43b262291f636b03a2e01bc25d88961015459e00207e03c23a435f0c3fda03518ca124651085eb0c45a762a848a824520ae036866ef22d76106c65e80efb320c15b00441c6d0e429ac102a9d0fa1976b72bb46d3fced5db3d94466ceb81c62e90f62c9b0e5bbb20eb122b352ebdf164a39fda389b513f87b39c55ed569d1b5933119d6b83ebef3fb5ac76fdd7606fde0c36fc96d68e1ce9ba92a736b7e1d36bc6bb78f95852dd3a9aeddbd18eaa36b07cd791f0d0696e78287c6306a5d876e4bd6c7d6a80d344406a6dadb6203c7badc897485cefa2af45bb7fac00ada615f6b85edefd7e33f1bd7f7ad463dea6bfbea21e33e9abe984173aa3fd5515fbb7b35e4e6fcfefbe3fbfdd3bb1b5fb38595400f7cbe788e87f9aafcddd81a75420d7506b6dc7375b90974ad3336d55e7afcb60dfa5a075af362ddadac6e5d8503437d2f5edbc7c36b76fdc0d1ea6bbe3dbcf6c67f26e7a5731d356786361ed8b2ff667a9fafbd3e33f1a36b8e94c86cbcc775bd5a8132b06565a8a12668c9f1f52ac050a1bf7eafe09b2efb81a12ac32f6c86bafae1efa8e3dd0afc695fbb232d79ea3b4fd7e1a7e73d6e7843b1e1feef7fdc3105106dbc9ccfe34138725875706ff9ac6f21a1dd7208bf01be0bf11516ae787a4179cc0b980874a35371b0200a22043c42e49482482924786ba7822084005dd9672e6eed546cb7df2a889b65a080041ee59d0a0955825809e23905712f33187511d27743a6335d7b70ef13aee7c734e40f5b4dbdde1bb65b8fca9aaecd6c157abad6729561f3e9110cea3dcf9d679a76ab0f4cd91fb66eee7e74c147f3b1c72fbfef104bee6d94497419d199853bf3beeacfec46fdd5d6dac044d0b77c9a9dcb56c9d8d214bfd518148f8ff5c67bac33d37ea0ccef1fc3f15fefe171f98e37efb561db13278a9811cf52c58af258c425292f490050e9e894174e48790a25b2bddbb349e0d44576ca67f625284f04200a7c46f9e4395494af287f3ecab3608319f4035deecc75adbdd0b587b433a4768656a02c2c184374a301ffa9319d7618983a504bd0178ee5a05f9d7371b3d9e94804a839d46fef7c0b3fccd73a1eddbcd3d2473e70d63a4bbd426729ed0815cf9d1d93e9c2969b9186da6ffaba0dc9eba6d01c75e6ebf53fac840ff5d58f71d2c9521fddc721fdd1bd51ea5d6fd999929548d73abedea403bb511fc6cfc296fda9fe9476804c4416f6edddc050095817dacce79d2257b877a7e9c8f09f5fb7e7c0991af1dbc52873fb2bc8bb32b064644fc4983f416cef945d198a054045f6d85eea227b6c2fb32f11db1301148462570656225789dc39456e3f34d8244e43d0b7e5668cddc1977d99af6374535deb0c34144bdcd7f1375355405fa131fae17a8caef7f1e0ade270ed3773d40656d01c9ba3c735995c969fe89aff6207cda875abcc0cad09f5a7eb303f368c5a7272fd898dadf64e8378b2bad9e978dbf36c6c1b53e66ecc9ed219dcf93d71aa0db84b8002281d1dee27edc11028c1ad83df9be04d5d64877b665f06ee22468294c39dafe25415dccf0af73dc460243b5e8e62400aad34eabf2841f8c08ecb06ca4bd239b855162dd99fd9b232d2b5564e6f15fa31b90b9d02f8d06dadea88893fd3d1e6c8c349282d6c4862f86258cf91634cac0133ad196b59513b09f894a3b60430148e4e6df194d41624918212d44e5c2c41ed957d096a4bbc2424b1b0a7fc3954d4aea87d3e6a339283b55d4e83beda7eb19092d0390b3f318dc52b335bf681a37c15a25a86755067b6738cfdb63d347d9a8e657f26ff6dddb782b66f8e3a031d292fa6da047de4baba4c61e2c76d7daeabedb185fc37d3bb0edb4fef6edee6bf8b4cd49ee8eaa3db0fe89b292b03d3db3dd67d12b510379e56148d18356267d9ac3d8fcb066be2c6318f8eae0cd22995419248426e4665485d645786ccbe8432504879982b43f21c2a65a894e17ccab09317ccadf985e9b90927d743dc6c4310166ecf5a37770f5db062787b61fa74dad752167f19d65f5ecf4668fe14e3c5d2ea1ed9a1350b9cd194b5c1bebd6046625a32b24200c218d0a393989e8cc4020040a460eb0ca00d4a2e5d6426716e5f86c414f0a81059a15564a522f15949bc1d16bb30dc79eb6365aaab0458f304c14313b5a1a92a33bb79f251e0e2d4d2571311105f878637ec0a7528efa64c07fa57533e932678c7376505e86ae7a5af3d16a7bfdedf77a3fba3629d6637dc8b2cdff04a643aec2a9aa19dd0b268a71463786cb413704ab4238278f646f6d2c512685fd9b3a39d000151be9031163f870aed15dacf87f65db8d809f7b185da515ff563302f23e46bdf7d056bd7babd1b9b6a73ee3cd5d371c814debe73fbe8dab2c2db8dd5f416fa6a2751f2ce8e784d7baeabcdaf80bc3cfedf011b82cddbf86c5896339e1a238b358ace5a4b1e45174b621c8a18e1a347d1c9c9127f638cf38012f6a4b5a58bec18cfec4b601cf21011a11045172b8c57183f23c659c9b18be87ed092c99bdda8fb8eec2fecdb075797fd451f7d0ce226b0e5d3411fb57debb6333083b6bf6ad21b5afbd5949be33de3a27ba22fcba6beda7ed5b5fa0ea2afcf7cd9757d1a5ece7c51283021cdcfefd5a119f81fb6da73ef37152bfa3cce7b8a59fe304b31342c2b9c31076db6975b2902c12593980922981ebf5d7fba2c66010022f112fb4cc7d443f62ce6dcbe8420204810cdb39893c75009422508e71384ada8d82501cdd7e59cf465833efbccde98679b26032db4f3b86f06edb9b39202b5fd6ae2246a94276815233d79b9b0afb5438632a89d0fd886fd6e0bb653f978b355023444a0a9dec5525190a93ec827693617463a3d27bb3faba4b0f53c82deeabae6265680a192d1d775df641268c834d2e5f8de6c0c32a3f89eebb212f43525b21bd7a3bb793200bcb0e526b0b587f57be2ed888c79bfdc81fa2a912ebb7f16a44b89555e6c99bee411bcdd8975161a0c1e5e7be8abfbfae797f7709874e48e3dc80db384c737c3f7ecf46b4685de5534ebb6955d6984604421c64757697c4a95167931498a6354e9d4457695ceeccba834c594cf075650b5d648a5d2e755e95db8d821d4cbace77e0c634452b15effae8460ef1479a6953d1211d03abe8e871b8978cb2c6ddf0c3a6feba25748b2db3662be3c6e6be977ffc5883ac4cfd66064b86b8fe4d9b0ed676f643b1f8c32c056c94a1068593d203c40d2d1f3d3087f423d8000c03203eda98bec7a90d997d0032c0254d0035ac9412507679503366c7c210cb23fd365856fc9fed069d22c73d850e9cc82eb9f8bad6b43ed2c5a329d7d16945663f0a98c3ffcb3d0c25f06c68e0b5efe538ff5903c04c64ab2963829998640048c787274f09e2e315800100a122c317e927858225c96d997002f910810c5bc214eaa2c848abc67252f1b379862672f26d2077d4817ba9a3485574325057aae27297c15076bddd6e7860a07d668e81a4821cb38d21a81b7c6ce469db17debef6ace0f4d7fe762820b5d262ff6adffae3fd5c7e6a8e3eb4899af16bed3b5bb575d25a0afdabe86e0c096db614beebcd9ea5da427b3b89457436b8f4dc4bbf7df7b51eb7b9edaf65f262bc02c21309cb8c6c85b24079ead70f4e2b9b349a9b84e99aab2595662c95406220241e48fae2ea7cb4c1600c48848845d5d120f4ba84b665f425de212b430c94aac32192a7539abba94a1c72e8df9b410aa4f5f4d440243b5a115a42df57bb605533fcdb45d5b40351de39095515ffd7889396f681db2a14fe9548199ad7e449f1744cd7b189b212833a0a0251368caefc79f8425247d2573167923278a9e63783f4fc3b26b853357b3e2bcc8ef5d436f6df7051e4002f9a3ae948a79ccc353e649408210d81acedfd84e61e922f3f60bb9fdf635f43e97412215002619e7e3c75061bec2fcf930cf0a8e1cf18fbd8f5e4769b9ca4db3d1bde915931d16ad9be64da751ffde051f4ab7c7bbfd91b23054e25bb8fdc51ade2dd87e2a0e0fa7683e7a745c4c5cb4436fe4e68e1ad121982d5355865aa9246a7901507a74d4a253e62d4011f08867476dea223b6a337b76d4f210008cf348b92855a8ad507b4ed49681c761b8d5653a3603fba588ddfefa2c9b79bbdb1bb7e48eaf074d68de2e5bd0df8fdcaa95d655653a1f1fd4aa65ad2643edfe95a1d7512b0a543c3a684f99590029a4a4449b367670fb76383bec4b8096205c5c17ba5a16ba02ed7941cb8a8d5f6fd32ec3cd9f965aae0f75551fd8eac72a6c70fc30314d5c74bcd12158dd5d780553a1244c092022908ebacc7e82d353cecb47402468fb32fb9f51b7749119a7b97d099c4a1892422858a8705ae1f4ac38dd8d8b035baaaa3fdb8ccf9eb6558a40da009f8d0ee9fbef2b9d61b36464951088e8f123abe89413a51142d2f685db373198bac88ecdcc9e1d9b044228a23cb22a5491d50a9be7c4e63e5e1cc8cd5b65b63ea5f9f13f69702298781379d6b315da8774e6596ac818ba678d800d864a0460e1f84dcf534e2e469852b27516c226df5217d9199ad997602811082fe46b0408d512011543cfca5006661cc6510bf9af5fec7304ff1396a2c4ab91e159d1c13465aa23e3e99ea5b33ef35400922890a3e3f49453861121046f5d396b0375a987ec81d1dcbe044e2940122d3449ab85b32a9c9e15a74cd0380ca866d01cf771fba58fe8f05344f4f41d7b9cf8f5ee9891373d88a6fb2bc8505a72345fc03c05127f74969e72822c12b1286dddba67938da98bec2ccdecd9592a402c219aa75f08d5687ec5d2b3b2743f320e03a98d14cf423e38c75429c4a7310b63eae4c3674e34354cdf8b068e7d085a0fa972055b694f92f1066c05442578fc38c0e976dc1100a2305d6d9b11b6a98becb0cdec4bc0968708e17c6928a94a32ae607b56d81e029143a7503581ae50cfd6da6333ddfcd9b77092cc30b6d0c0ff6209f0d3070cb25c8c89339e3891339a1a53ef8d35c1786ff16c677d50b2712b022862091d9db7a7dbc746001888e91e906cbc5dbac8ccdbdcbe046f05896094c75d93e75001b702eef980bb0f1905b8c2bbe6a3d269b69a9dfae3f0a3f9d5a2ac56a0bc5b81b2483270b5ced80e9445abe1deb71ad76eebf6ceb7e2ff9132b3e5263034dd67ca2ebe0eddb8fcbebd161adeb5db6ad40343bb5bd8cd2d19c4cbba4cb9b9d7c608a8a7e1ced8963fd66dbab94d3ff0e7b63c7849c4e4e9f34ef7d73b57b15b5e6fd4d7da4043f04d97fdc05095e1c6de0e8d1d3bea9f609121243c1bfed499aced6c947ff012110edf47c9df8cc274489587ceb31009c0c9761047d6aa5366b061240894bd6fb074915dab327b76ad12814801cf57f32c2aa9fa4da4ea108830f50d5e929dd49a77cdeeb0d9ee3c1566007f921ce546724d6ccf969f8fdffe4f334752273ecf6666dfcc92b99a2cfc52b23b20017c82996cf894896b7167036f5de26d837ea987ecc386b97d09c2124281904f0096aace4045d8b31296951b25a8fa79f47049cfcd7c8a16bc7faaffd5858f6ed7571eba8d42b4a561177648b08e4f5d69292c1327c98a5ed397695882bbec15adc8cb8392e44514007cf4400c3e652a1b16102d917bb174911dbd993d3b7a25082401a20cbdf163a8d05ba1f77ce86547c7414dda5e775e5f58883f3e3ce9a75679b226e8c4892227fac5a0c12fd49ce509ef59e46c03af041109e3a3e3f594a96d58a400b3c70e962eb2e335b32f81574c401acf78ca1e4385d70aafe7c3eb2fb0846d785143cda17e7be75bb8ed5bf3fa53a747eadd5ecf7d04f441e9c1bf36f69e69767eb4648acd20fd7cec282e063bdaf205efd9585cb636d6c5e337f82b4a92408f3e890e9f32470e5382c412fc4d5d64e76f665f82bf4402b8d0bcad168faff87b56fe96e5c741ccad776e1e0bbc5db2757357f579fb69e87687b4d7ba214af7e67d6ddfaac27e50a3a333197eddc82f8ad1342ccde6036bcdb7652cc7680a888485a38fafe153e6e0f11089987da2f3d2456646e7f625182df1222f16b765ac185d31fa9c8c3e9023bfd63e566e24b7af768656a02c2ca42c8ece5cb4d1f21f0fc2d1af06270eaef6d0f412ca631e48475fc30c9f32558fc708248463a46eea223b75337b76ea5288084f48955e5251f737a1eea12061c42eae872622bf474802efec04a46e33e2b74c555930029544ae08241e1f3dc9049f32a38f278096589f77e9223b7233fb12c8254024200f06c78fa1426e85dcf321b70c3c4a63f6f78f42f0dbdaf94be19986e5585cbabe6cf243b9d57f10003c22f0f8d1e15366fdf1029530731b78e5222b900bf625802c8a7c71b90abe5afda702f259815c9a2087377e951bc9b5e4c19b19b0c51ad257da39f09dfefc22b3dd9ce2579beff8bea7391ebacb47f86c04b6c0ef7cb41746fe93d95371fe8bfa9bbbe0fec97e52e94bbbfe8b32679e6fffd1fafe47e0454152a8f013fb9bb343ebc20d631d981ad35994fefd96be59f1877f7ee35fe0f627bdfbe7b68d7abb7e5b3fff1f0000ffff010000ffff78bd928247ce0000`)))
+var _ = pkger.Apply(mem.UnmarshalEmbed([]byte(`1f8b08000000000000ffec9d5b73aa48bff0bf8bd7994c7773b2adda17d115d164c59998c869d753162795c8690bc6e8d47cf7b71a015151c185f34ceae5626a56a469bad1ffafffc7eebf1a963bf18246ebafc6d40a674bed5ef79cdf1dcffbfccdf27ed79741e839e622bafec35a345a8ddf179e17feee78c6d2361b778dbee37b8bf04f359c355ae77bb86b0c54c76cb41ad98f7e787aa3d568dc35ded5c5d40cb7ff1e7a5e78fca41735d4678dd6ff36ee1bffb96bbc85aa6d365a13d50eccf8afa1a9069ebbed82f7ba966d06a4b9e1e9f753af71d70842355c06db7f7f9a8bc0f25cf2c77f9249048d96bbb4edbbc60fd34ffffd6e0661dad9eea3833b5eb6afa3f557a3d89b78512db7d10a174bf32effb5f2de8b671c7cfcfbd4bb773c23ba2a6cc7df6835e03da41b7ffffdf75d63b29df1f92fb2f5bb634d176a68796ef4a5926f9ffcdf3043d5b2a38fdcedd7946977d708ac8dd968d100b3770dc733cc460b419aa39b3464b8e893716845772180d8df20f80dd2ef10b420dda2997bdce4d8264014541a770d2b181b64c6dbc907ebe8913fcccf468b6500a2ef1a7dd76bb49a10230cef1a03db72e78d16ba6bbc444f856c1353778d9165345ae0aec1c7ff97c6635f3540f4efa1413a03778db7cc98dbf63c3b85b6ede9f3a0d16ade351e42cb21437833f5460b7218620e359bec5d6310904f18066ec7fef75de325b72993344da7f9f75da353bca9341e2fdd65601a8dd6ff823b7007fe137d9b3373510bddbf5ce8ee1a7ef4e4bf1a7fcea785bf8aac04fe7dd730d4504da6e4ab0bd30d771dee6e8a9e5654b07f07008ef585a986e6386d70bff4ef83ffb3cf0bfdb91b530a402681004db187d20f7f03d46f00bd03aa05d81683b2321fff70ce0a3d4a851e26424f5108d0e5841e32e5649e4110a5d2d93c29f32ca45986a6214a641ee4cafa5e6f888614861cc657c8faefaa6f1dcafbee37b1bd784e9a7712bcfd7d1515e06debffcf253492d0b3a2944a6f43a69e6c591adafdde70263b5f769f1f409d1a7e6aa2b0d6d70f5ec77a98ca94b031781c2ad2d344155fa786d35dcb6836d3ad2978e9cc83fe8337edf38aafbb03202166a689a3136da0aff0c34011f05216a1ddef2933dd1978b2d4f7063f1efc9f9d87e77ea71dc8d2a57e185f46e14473baa1f2d646b2f4f4a1f2ddf5f38fd7d5f3db6a4ac6ac5382a338369d7dc6cb3ab9ffc9d7dda127a1e1cce0475385ef02451afa9a38da5eef0d802c0da1becef6dd4ffb56443853c555766c5f2f1fe9f88129b5f7e6f6f231f27f927e79bc565077a94afecce0ed4fcd3a1c7b7ba951af53cd1502adb322efe243778499c10b730975419f27e315802a427bff5dc14f85b71d5514e6396de68af8659fe963a53b76284b4f4c9f0f6df3edc13bf8befd8e35e73ad3fff99f46959c47473fceb13ff35cb328ee2fde9f501f36d10da94f5541fd688835f56bea5741fd8b825110fe10af541e2f15e965fa1cc16b774d42f6bcdf55daa3f9a0ff2aecc17b6988d052a4fe549877df5ec1ac3db2a6eb14dc3d65a6f1f6bcfff8f4e73bf8eabe8ee8f8f321a3f3a3a37b08c86584973a355ccba2bd343aed0f431a000d415bb771fa2c43647c5d12ec7e6796bdee2b9d158169283bc2faf9d5f3ff5879d5428c3a7ed7aa612ccc2028ccb1225d2428a338ea8628a3ab405934c41a6535caaa405911d9284cb399c20fd78a34d828d2cb56ad158773dd11363ac4bed23952c50ed4a2d5b4b02a1cd32c736d47b3e4999bc7ecf5adfa4848c877e74aefc9d6a997f59e0af9be533f656403734fed1da5d7748aa877fbcf4eaff17863f0dd4042834f65bf0d93b49111869a3b5ceff7ff92d01dc9e2971fa9cbe2ebf4758eff7c7f14daef56ac16f342a048435be9e299d169cfc97761f076a8bc6d55590d311ba3f734534506ecaf26e99ccf923cf3ee6ea392d2873fb7b163862a71731464f9e50e764a29bc21c9992a481e0db126794df22a487e59328a715c42d036f82e61cb2c572bcd7729848a349c4928b4f7b9b6731768a200640113bec17d97c2e8ebc58ab9ce0f3e35770074a7eb6beeebde5a10dfbf50247b6238dda0df1396aad485cadb83b7bb360ffa7c34fea88d218e6ec3312679d95b1ff678e91b6a6806052176e1ee9460f42dcd6ab612b39aaecdeadaacaec8acbe201605f145c59e4588a1bef5d46d4a60cc31a421d41d6112a9793d61d3e7eda5c10bae22f5778812a14df09451efe0cb7b3fe983a88c4b051d7b036f822236796b4983b13751f57160aa0b7d561849057b49d084107b43347155a0291a628da61a4d55a0a9a07814d5b0b0238b83898e8448934aade522962f2f2c0dde06a6906751c756281a2ecf06777a83b966e36d10e5106fbdb6ad3b035b7387330509134dec02194da70a8f61348f5e7bad88035f4724b8f2e00dde56d39df6f6146868b050c4d7a9ece04f8d17669a753ec87213247247df5610b8054178f6de5433a36e695b362bd1cca8dab6ac6dcb8a6ccbb34251582fdb68d63482c1bedbe91062f96e419d1a2cfb8f4f2fef2001d560a3d93894a52d70725d6df1788edc65b708543493776478fad231dd3028489cd337a6b8c1b734047125b8c1b521581b82151982a725e21c6b869f3225848ac8007d1d7166aea101d4446169746f1e7ec866a77c688801641c1275d42ed387b0d2783c53f2b246c8757e686bbc0014713891a5d76c06cdf3f37bf05c29bb70fac2ad40b7552b9bc874815ee76e4df9c5e09bf18b02a0127e31b8e657cdaf6af8754e26ce12ccd7d12090459b9880b1d76aefb33c224df5de93af895d1250dc3ac0c97dbda16df65ea7062fd04627091ee20f23f25c0d4f938d1fac15b19b479da0ff0f530982e3d7385675ddf443d5d5cd82802ada4bc22a84b81bb20a56c1aa688835ab6a5655c0aaa2e2710e5bb6d3e7994fa3d3b64ddede18bd97a9c2db1b197dcd888747b7f14c46035bef0d679a33b013e54c95061f1adff52f38e42f188bb1d2260e3e14a97d065bfb71c573e393a838ae2860a041bc7bbed5869a637f19e268fabc8f6a82d360dfc367cf6f910d07d37c7355d7bda51b1685e0c9fb12ec31d4ed0a37285049e14634c41a7b35f6aac0de49813807baee479cbc15eb66e9dfc5f5b2625148a8a3b3d76dcd19accd0478e2e043a3222b7797aebb1bcbd7cbee3e4f96065e817bd020b552079efcde87832dc43f0d62d522066ae213016206c6324860ac89dd8dba8d7ea6ef274911cecee7e57d948c6bad51241cc0b8f97d3fa6a057791c28bcb0ce096fa097ce7caaf082234b4260741edca775147a200979c0905eb26d7709277996bcf5cbba705e5a75fafe7488e3854498183c9eec3c0ee7d3ac75349bbd7c8c50de7bfd99fb0ee7914e5e757805a6e9ef9faa6d19db8f0bae43e76e4d35f01bd61052a0926a1254d710d6358415d5109e15a733ab515ce82113e22066f39c29fe883f2bb12a9d5dc90a55ec45052424d642cdb3f7670b536ccd197eea56fefd276335f175436acf73afdf42cda6c6facc55a77b5f09c98b1f5bae617e15645db14e12eae15b42af92ba135c33af665e45cc2b261b39f4e3eda5c20b749fb7e76617a7c512aa88973adcff3bab27a9e270d3e7f1f280909b7e6776708f3dff99d1d56243be5abad007b6c735097b053b49752a86bda14e5549310462ea7cbd3a5faf9a7cbd82d251c8d69f684899c9106f1431d28a1207668611fbe97c79767bbfd75eab229ce9ee7caa228189edde4c1f676c7d77e81b3dfb9c6646d2f9ceedf7b051786662f4ec95f2d6f63577682b88d88c51ff2b457afa20d16a59346c09c199c10f3c124d37c4a74089a2e4c2872a0d7c0dd1d3e71fa3a0ff6397e9fc4fa6f5c1343fdc5b4c55d7da4417c6bae74eace9326e56909e65ba4a180ab9db25fd51a09a720cae4efaab93feaa49fa2b256ee7487ab0238b8d497e8ca38a06d49dada6f65c6ce796837c9dbd9d5c221b51e3055716bf268466aa34640e691887a99686f81524f44bfadc698b47949d6a0e067d9e811abfaa3ecacd467aafb60c2cd70c823141d438f4d24ccba2442bda4d42338ebe21cc2a29e0e0e89a6535cbaa615951e9d871ec75f4351a0afda9f0d8edbc3f8eb279819bfe63f771d869ff78075fc2fb889ecaaeb05145c6d6a941ce8e597d3878cb4626b6fca9dc67c54553343ccb9dee26aa06d7b0a44c57294f9a37e4492515115cb3e649cd936a78524642ae638ac2635f738c49962df27e14733d781ff97d7e682b4e176abd5817fa51b17ed2dc4767b8f6cd6b9852b49b9427b7db87890295943cd4db30d5db3055b40d5361e9f875fd24f60265f413b2b5517bae88cacc10bf123ba77aef0d8ea6685aee35f4387f73c20cf686cc80959419b035336a6654c48cf33271a5d621dacb63afc96d350c04a289184b37b8020d97ee4ed970437f07ac24ad9fadfd1db5bfa31a7fc725a1b8120e3d61b99ffef3fa8fa80e0846b3092c7dac7b86790d240af49082e286f53fb0924478b62effa9cb7faa29ff29225ad7c14247f647ce36a8f01f01068a66e5aa961e5c8d8c427da4d0b8618133ac246599adeb9bebfae66aea9b8b89c675d8d09cae2f5383898cf0fcc04d717b43848ae6b532b5c00aaf62c6e50e5260dc305c022b49f765eb70491d2ea9265c5240b0aea38581044b4736f86f045c111d4d8a6c51ba73dc9a41a86ab615cc4ce31a7e5cd3654294e60d0b08602519becd5f2b20606aa2d4444988728da45cc718524ea008d832a481af91732520b6c9e6c0b2f3e5eb68662b9dff824724cdcd5b98fec20c4c375443ebd32cca994bb7274ca1c02dd5944a525e29f06b7a4a4d959a2a29552ec9458620f0a9fb2a0cbbfdeeb0fd3affeae6ed82a23bc28a9ca642d25149c191e1089b7e87ec7ef230ed931368c87f88ece7db05aaa4d8850a0748aa6ce7f23675241db6df693baaf4b431ba278a03e2be34be7bb18dea604ba286bec17fedb779dfb5911d7b6df0b34944ccb7bd12ceed9ccf14d4c7e33d7fd862fc9c93a7e0dca01414b163d50ecd45ba9a8c83c0ddfd61452b8db772a37f17a4ef355d2644be69188bfb1784b16a1ed73c4e797c8da414d2f226d176c2dda7eefbbc3b18beedb4bd43ae0a8fcda94619cbf8efea35b96d26e1761287693fd95d962f30a5683709479ab754ec2a49d76d366b8ed41ca9862345a5a3043b0eacc48411c7e9757df8fcd6fee31dbe4edf6de1e5bd93b10e3b46667739bd7ab634637c2e4cc289bd199337509c2ec53b4af842831bf2a592f45d1ad47ca9f9520d5f8acbc755dac9e87ddddee888ae9e10381e78cee9af97f4ac0bc8f8859e1386dcb2de1a5592cecbc19a213543aa61c82f084c21a86c768700934d78db6fc311d37e1f8da6af00bf0823f8c7d1de94dde19f7d1e539ab3fdbb6ad70a05ce686599d917034ed9defe897db710fc17ecbb5543a6864c0299b242721558dac3c7d70c5462801c1f85b22651faf7391ef51f19e1fd719589d83fb8bbfefb6ee5e081f9ea5ae60510149505d095bd2620626e58bc842ada80bb06510da26a4074a5b0fc9aa6439cb9b2389c93a09c8e844de56041f1ac76d3f1679e7b5981bb40966bbb4dd0c2ded0d98baac94eae9dbdb5b3b71a67efd5d252902d54dbd310f3efb0a0a8b316d476da051953a6ab842b373c969242d5ec598c6aaed45ca9862b6524a4344bfefd46137d4a638be91a7ae58053babf843af40d0b34512589ce345753a7a64e35d4292d26d7ab31c43cd2f9d927c972ae1c1f4c444fc3b4cdd034c66a589a17973b4800c1ece246081c0282fd0d82df20fd0ee8160d5a347b0f00e62896a3e972a860516e141a369ba550c1948e20356936892041d484340b20388a201d358de7780218b90d6b5c7c435c5c9692d37c4864ffb802e244be6dc55be152db5d3a553df41659e56a1c846ab80cc64b9fd47b14e545b9ce1276b06c4176702d04ef390e618a01b0a49a8118a60a76b0650f4ca0100d930313388e6e028060339f1dfb4de359e6d3e354d39a1fdf901fe5a4a690ae3121d552464fd84894b08a6a03a497e9eb68f8d87f1cfcf9de1506ef567b2653874743bdaeaade6a9be292f28e95a9cd3c6f3e56c3d074fcb028522ede9f50243a12a51046708b06f7888aaba54baa2014aa0223d160cb7184c2a9c43310204073f4b1b912376d82a4693acd131c39d1b4e6c837e4c8455129574a450abd551e7faa10cf8cded0d6a436d0d70f5e5c36641b4e749669de01d171e991804819568e47255b2e7570e6e6a9bebac0e08550efbd4e5591018a68d8ba155f4b4ec983e49483ed7955062fb88ad44f9e61ebeed301ea46d199a3f1f5747e396552d1e903e9fb7ab4ff183e0a72bf67d8b233fbd4503891a5215044b8327a83ccb9a251e9c2f41dd027dfe341dbcacba8a866b4ae2457b71bb04787e99945e15ba08704bf8805c5f0cb5004bf2c4d332ccb524c49fcd27415f88d065b0ebf5bdb33622ac7d01c07213e6102522c4a999a4ef3047e4f34adf1fb0df15b405872009c002513c6d221fed41d63a639369b1c2bba572ffa88f7c25e04261af5e4ca22e39bf1f12e3fd3bacee8d066ff8f95ff633417dac2e368fa36621e87c274df37858e8e8cd9af632df6ccbd7b72c179699e8efda1c252cf5caae260b19b67c510c5c9a26a19a6e37ba1e9eaebf1dc5c1745e8c5fb538062ae084099ad8ffd9e65314218c3922e34ba59890b0de1b2eef6ac0f9de520800030381fa07b4d9369e603f454d31aa0df10a01745e5dc8957f69ce8601a3524e7f433120a6d537a897455558c74ae4f83179632654f48497fb69cfee563049ff74fb62267f41da0893e774255409e73a0cf15687feaf4e5a3b17c6808ae4a9c7bef2b4457e6315044e6c314f042916ce20a58aa52172a0206da117ae9ec39f839f7cf83e3d3c2e6959fcc456f9365f7b782d8c67f8399e517636ec14e12f072cd62dc45b005d03d462cc700c095545c69dcac82bba5cfd361109b02125334a010c7d1f9d8dd6b9acc321fbba79ad6d8fd7ed82d282d19f68a5f4091fa5383ef5a1a3fcadf7285efce954efb43435f5013d352dd8dcadb2b896adbba33b035773853d068aaf018460cefb5d78a38f075647f6a1f157305266bcbe13cf3cea8bd8097527da5ea1d4595c30cd7e4105336cac1b2b00acc20aaec99195773269e6611ceec9ad69cf9869c2925366754bddc5d9cf68f835662d52f074d27776e4a0e30cd3d16fad291cfbbebc094da472e489d17d6f276bcae22e05096861f6aa73dd728618bd0de932d237b432cda7e670a7f761ed65bd767dbd278fca12261dee79f3e35f465cb227d5e7dbcc18e4c344abebba4c1d85f2ea6858979e9f614920c5d1092b805e03d93a46d95842453892e8698b2bb2e31dc2e6acb70bb68cbcb85a699ecb44ef1a63524bf21242f49ca192e663c6512d586ba6324c7e65f08b1fcbae9abf784b582c891f44fe70f808e38f964eb9240f6f33ccd621e7f1822242ae24642437b6bfa1e867eda2b437a72734ce29cf13df99ad85d9b6f6d62ca4e9fb3ef0ad9f3e75b30934abe4a756958e1d8f6a6056979fac68493145d28d6cdb428d402e09e062c0531cd70253989d82a38190db61c27f12e2c42034c127ed0899c99fda6f1344f70f244d39a93df9093a765e41c21bb50e16d20a1af4f654bc699210efdfc20f6e1d1f71171f2736676d70e69f9f5f29143c003fa5c24e6e563fa0f09be5688a32f8aff9cd066f9a1af38f2d4e005dad8def3a13b02d9f7732ea12ec8ee019a1d4fc79a731d27de53f4aded6bced03677ef31d0907110041f4e0e345532fe4c7bfd88c63f0fc652b993914e7e3cde32d4bca56b8c4d8750b8209f2fdd9e50ba5934a243e11603ef597c95364be34a32929aa5233a6c262389c5f89c36bbdff4ac367baa694de96f48e94b92728ed5181afcd3a72132730909a12cda41accdda9ad8f5b5e2cc2e906094f6b9b5de2ff1786badaf5451581a7bfd6d4fc138d23e29c1521de1a3485bd9c173f3ad0d1469068e9e9b26410d37190f43b68f6c69da6acbf9af19d1b415e969ad51fdecda045fdefbf15ac0d8666fb84b64ba1c908a9cbd87eb44bcaed8b2389c108ddde085f5c980d529ef45f6590f442bf7e3b56044b4ffb9224da71a2500d9c15073861345843355fcda10a7b2e60c7dcdd1a7640dce6bd3efccd271ff8cf684ecce25f46593a42cdd21d64b17907c02f2ee2594be6bb26f36b10e9e8f7fa3dbdfa584ba1f066fa32487213a4729f64045ff16aafaadfebaa5b67d17ab9c23ed0f7f6be4183961a2f2dd8dba370e19e48cc3367b6d9f78dacee90ef16f38795739dffd2feb21891c47ba9811e7886c8fc423633bd0bb78bcbcf01d1e5b8a55eb22dbe2918c0f741cce166630f36ca3a83e52a48b4427612028a693d04c8b6ade23a60901006c59cb91a5aad049a2c196d3493826d5493006886e42c09ed04938aa99e824e9344fe824279ad63ac937d4498a48cbe96067d6b6d190329321de2862c45cb23dc54ce15fa732c28121c225396fc2706cdb8038b2c754e9899c5c636908078ad85d66cfd6539c6ea0a311d771ba015937776b4c963f47518e686b1dc26aad27849ad5de4616ba18a8518464f6a9f1af2703ac15cdedaa67e54566fe91f7592c7a54e97bfdc5b9167a66a5f6319ba8edbae786aa1e8efd85393117a6ab9b45d7a4225d246b5294c377794d625b806e51f89e6221a2a866b3a49d8c38ae8a35291a6ca935896b36d3350922ea9c9dcc35b934cb3c9d66fe9a74aa69bd267dc335a988b49cb395b36bc4e09324d6c8d470a2f79e6cc5213618f39144c4b38ccf89be1c464a3236c3d744a3dac49fb8cc44a24fd89e6d4716bf36cabe6dfda9f7221fa0afd9b97aff469306d73e23bd97d89baac8e4da9c2402a422624b30ae84f08af87d352bbb7eecec8b9cb524bf8fc847692f0f6d95c8d6e9c5b597e723545112e5f1ba9fe7ff385c23060b556aaf726cecca772da7b9fdea86cfedbe1b055783f33727eb008b8b2d0310b4007ddf44b08911d32c9921c5804a825aa58ff66e461bdac4dbcd200ad3189caa03df6f1acf327f1538d5b45e05bee12a705e4a0ad9244789978623ac237ddf6afb9a3bb41524ac4f71eea56adf463359d6226b6b6106fac234ddf162e90605c151a087841e1457508b44a04573f700b21862aaf4163474259e0d8a2bab45369b3497fa20204b710c854ee023d33299e4097ae4b7ace1f10de1514052ce6990b105ec081b724d119989ee0acb38e2b23644a688b698d56ab6dad2b66ab184573b7b5293eec679952402606b8e302f1ef550025934dcfd9ca113cff9f110c4b9a2ffa7880350e61ec5e9fa1a5f625c51a9fad3258d30eadb90daf37c2f79a9f2a0ea4b74f0fe32e5988ba9698c2d37f40a42fd720709d39942a5396c8bc22d80ef31e69a0072cd92a53988ae241d94295b9a833193e623218e82cd539e6a8ce9d4d44fe7984ff4534d6ba47f43a45f9693eb7442e227d8666b126a350fa95eb9edc880646d8a7645238aad35b1f4ed3c8b31a35017093568bad066846c8b615b14ba67014b5334553a8bbc5949a94d34d832dc6001c669510c0b21859bb0c9e49263bf6932cd5c729c6c5a93e3fb91a390b49cd1067bdb7d4a254ab175c7765471b0cd3b74b73e446253aaa2e2cb2889af173c437dcf4f9973cfafe73dae541e2f15012f0d115a110f0f34d6432d2bcecff06469e0ed8de7e3d5af26ff46a075de5e2bd2e0b2c617bfd75be4ccc4fb4c4e0ebf3b1de2ed3b7b6b93f79bbc3fa4484fbee2d81f713ec4a6df991d68f1abb44fcd1542d911d6556b9a4c5a31963418ab9f6aa82e8a2e1a17ef4f560c840ad51d712d005a147d8f10041cc5b225154d16d355289ad1604bad181051a99710511c0b41f3c4de71fb4d9369e6af18a79ad62bc6375c312e8a4ad1f05397a476cdf478a9b826dc24234cd0ba348aa663f20290453ddb379d877a03d973839f9e30ee2343da5344db557bafe7da409dfffa94c522182e5f50f4ffd8bbd6e6c4792cfd5ffaeb6e5196e48bc4b7403710d26126d0e1363545619b041263981842a06afffb966ccb57d9967adcb32f5b54cdd4dbdd1ccb922c3d3a3a97e794c2214cdf0712212c2b6fe1508ed043403825088fd2ed31b8244284bd4653414da83610347e27aedda8c7514324097b75a0c62e15840c15005c4054941665a32c00cb02d11b585e21584a6f1c0e78769de3bc3b56d3e0998d431abc98bebe38508258d532d9c7ff781e8d165467b1d61bc75e1c96a6b3f261c03b2cb77b4f1083449a60b00354b1b4478352a429a081312486a6eab2c003943a8027e8ad1cf4687a1429847159a45046341c6701f41488dea0e70aa14764bf145b05c31b5bce225878b3fce53d88b611fa7a0e26a499858e33af68275914f2dfe94f593bd27d4aa8abe684aa9534a2e747361ab43043e16fa7fddaa4d9249b96329bf4bdf92895d171984f876b7aab9f8d32913c61968e3921eff4763e9fa46eff46fb750f2cff3d89f9ef0d1c3b9b2df15da1d1c76bb33bbecce0b3af823ff406347f9e661fc57d711f8f9939de5a5b72489e30d6b985963483273906ffb9b44afff7516835983e1e439a27ea2b0c328bfc8c9b2f67869eb834513f590607b378b8fd4f73d43acfa7f4ff014514cdca984f291df5dab1b6dc68dacb4f9717d5ebbcdf7f57a2f9fab98d8b0286595f6ff6b47f0e29aaf7e6848eab73b0ee76fbfb9ebd9b4ffbce7d37ddbfe5f431b80a7cf71e987f8e59cbac73eb329f3e71d6dc1da111dab3c99763c181f3d0657c067e9b5ee61d8977e7d744f2fd6d378c2ef3d7d290ced785ce9305c74ab6df7f1fd13dd1f1e6d3c1db7c447dba773b0b8e3deaef4c7f732db3b6e767132a696f423c37a9e7a235e25b87e898930561b54f9bce91ffdbbb975997bcefcd5f9f9ce8edc43ae5b6c3592f6cdd26e72dbf76e9f8dc3e6debc5c78be940994dc0a9bd798fd662a68f7beb1c44d5fced14efd7143e057b3664e6059ff32eb5ae8edfb3fb365dfde594c1c3f8fd1c7c4bf46117afd1c4b79a42e0d8dd0efdf7f55f08430a7032bbae4213011a9fad807edec75d5aedc29cde3d3c8ce2f7a5d7b173094d014662df14bcb3741fa7f695e077a8d70ca0fa2ac0cab53ece7b5a70c84fa95d2c5d3bb4f6bbc7adb9fa10d4bfe51a8b3471510b00804da03720549182b12119aa69805a3c6c40de044050549b0b225da10e363ed97b5a940db3400f2f10bde9e157a887cbed1ba19a3df91a6013edcd729d30c63c8801bfefcc5bcfef83fba7f1fd6ef0ebc75980639d12229f5761710b96ff4b0b53a4dedd5ef3e4fcbce76c9f0b6b96a5faab0173d2a79af0e469dcff3efad1198539eff5c7178405d876d671bb720f5ec04d472bb1098260e5f311ee01413f11c04d15370c0c81010891c43dbd9e4c250064fd4410c128cfd5c0aa820d8c111ff7d2a2e130f9b857247ac3bd2bc4bdcaad526c7c4892ba652fea31815cee429d7365f3c9e1828b0ea54048bda7bd7e9f4fbe9c3f6602d517eeee63bb743697159d998f95e7d1aaf7bba37bf8380bc28f501b1104e98210844053450da08666444908aaa74c04d065210801108105d011c10a510a542f0414c454af68987c082a12bd41d0154290d076896128be0327ed11c11d6f060930dde17935ba23f76d7bfc7cb6e2bb7df45b7c97a32510ee7be313e5f37de80e283b9162b9ef7b1aff7ddf7e75fbe7d36b1f8c7fb1ff3e8dac7d852de0cd44e3a3ddeb6bd406d05706ad7e7c670656afe5589b7524f310f4f3fbd3b35a7bb179cd589c36f6ca4dcce9c7ea75b37383abe5ce3b2c9d85b5b35714d5b667ef5f8e08b6fd5ea30cec3090c33aac60a26259b212520bd661f09f82ba70942250178bdea0ee0aa1eef7764fb10a96c29f6e607f5c8d5ad4eeadcc47c9e8ca1f275aa23121733211b57f7ebdf81193695958bb5a85290a2d3ccf5dac97de5a508fe23fc4b08488ea4d6a53230d555330c106d665f5a65a7cc7445a6d4220daf56a1c5dc2831248a20c916890055052207a83922b8412fee628364c596870cc1a78e8bf4d69841d7c7d1dbf77464fcabaf5bc7985039a34f23e180d9f3bcfc351abffeb7dd899b45b170b6a2fc967a8d189fefdbebdf67f0bc8e5fe40c209c9dc52575ffbcdc7ca4b5c532ba0a4ba01062bc0102279359a2a6aaaa4415048692a872b06ac25edd8efac1cb0e0b8ee2951b1ae18a8a0ee695a940db300590a446fc87285c852bd578a1592329bd07cba3e518a2f0b54078108b4a34da1f669870e57793b533a8d8363674a5160d6ad10e951464f2420c5595dfd7c8467100a5eba34407d7b48c19a02540d4b021aaa07d0fcde4a219a0a8dc80d8700d4340c309f88252dcac6c947b422d11ba25d1fa2556f9604a0952443f8be37ca47db7bdc2579b48413212a9227b2090eccb79790f3b97dc324091aada1851c5c014f6e9b5f10dfee39a7f46df09e976bb7a7b9633187b1142718cb2fcbf037f2796992fc5bb5e4c805fdcf47e1240e847c9249dad19078afcfb0c0decb8beb8ed7c120531b213848927ed898ebeb6e9791f57db1cb8976994ffb0a7d0fcddd2e7a36b1d6bcfb7c5c78f8fd4f5c1ff3cf76cb4fbcf999f9c63f53dfed94fd16d948368f13c1e665e671df76399145617e6166fc1ee36afed96ef17cd5de7ddb3ed17930692df0b6e5ddf786673b5c17f3e97a6fa1e1251991f83862114be51cd0cc22cce674361928cbe99cd5497f33e1d0e147c09545c8b5d6e6f6a9aacd88c73a9caf200230de5fb9284b73d2511f721cdbef2cb2b2941f3b8eec2b9d8ff2e8ad7c74178ba64b444066d7093fd2ee61744a45a2e5a3ac4e459160fb80df3d76fcb5b765918cc178efbf9ffeebbe6db9be959f8f97be2720c291ef3cdebef2b594892466df23cf8a53b702097c2d3d0c53b10e9b4fffb4f30dfad4aae8addc83a02a29d112532ab150de066e2a90864de80428aaa12022a752a27a544a2c9bb5a1123db2e3630de848850abff4694a341a65814259207a5328af50a194d8322577e5d2a3224b0d1a06498bab57b55be1f4a89621b30d2cb6abc3d25e1e96824853dd00031828c61d8a9bb476a8de4010aa9aa20049ebbea1d693c02f4b1eaa6b00c6b74ba8e98a8a614160565a341c261f628a446f1073851053bd57ca2eadc3cf191a1f688a44323de0c157dc92bf8990b6f8cfbf50d21751599b921f4f9e931757bf38515e3ea538262f6145d483c990fcb2cbe01b2539b17bfd356507e0cc47389ef171490beb8dee7609eb5f783989e5fe4439511df1caf47b87e5e1e809c2a9400b119e62413c057a13690dac85e5df24f114d652ba0e62693c3588c6908f180acdb5550b6c8049d1689805785a207ac3d32bc45381cd52acaaf17214b36e097a53b57be34b1224b3d542936a5a5f096e94f39145f341237745c93b5fd83ba628cc790aab00f064391646112ba42f3377c78a3ded3b531a23321d02eb9cb51e463ca5fe21933a18681643757555faf7232f44b8bda9df81ac07e91df6c6b39ce52641c62d0ab595cf33a0050a14f21f635af2de8f4bc110420349c6b8195a3d9c067e6fa5a056d7124124ba4154a0ab050ee494281b271f6a8b446f507b85505bb9598a8176de752e33f8b5a64060b939f31a35575f28cd9d005ddf172779ff7d391dbc99ddce3e4c66954ddacf8174aabf0e89fa17126da7de27a46df7e68ee50efcb2d3259c554139d1ded059f59e2adc245c6d786fc181379b50d36cff252a29e00e5eec89b6a7c5fcd39a716a8c7be62af09fd9bcfbee92ac1ba3785eaada3bb112d69931bd3fc409eee9fefbdafaf71f1e9b8fdcad223095a7bffd859a7a43b9117f7df87499feb7d0a88de66d3ea57271e99dfc38b8079dd49a0d6f4529f74e76cdb0b29b33380edd3ce903346c837e4fc5a284e6ee53b6afeb191c38566fb836b703678ac26f3d268a09e26f25be0672ae9dbd7f886f9da305c7679a486fbac3cfc27597e98f75967e9f979d8f87a77de442e1b5f538aa6e83912594cd9d75f11ee6bdfea7d52e584390b5d939cee0f8528549256b8e12651c67d495d9cbcf6546b67e054a63e60826b0382c5f45b5a7f28799eaa46124a639a9b009700340801443d188ace654133b3c92549c0c14171d051061040ccde02b4e69d170987cc5a948f4a6385da1e254be4f125a53d6ded71baee7282473ee765c7102676942e610812ab498ee903a86cfcb69cb774827e51fdf9ec1c3a8a00c072b112261179c4172b4d0f03c9b38b478bb329bd89729cc8ccb2161bfe313cd447d7736d1f6abd069fe331c5b68ebe314074f045da078be2baed3b9fe167e9fdc1c52f3003927e51e7fdd9dfee0dc25031ac279cc8cf9dc02e6d6f9b227cf3c7242fa4c7cfabfe62330937345e7c102843d930d9c097e3fdfedd87cfdcc0594fc0133819e26820cacb18be3dea61c90690ac88a234fa2a5d8742078fed1404db5a12a8060c300d2e75f3d99c08af4f98771549c0a238c890150818dd6c04644ae1a0db3e0fc2b10bd9d7f5778fe496c1ace61c889d48bec97808439bbe334a85120ce32b1729ee1b1e5539037b736bb9ed60f4646ce0378dc3bbba5ed094250e5f30c78745511041e95a6bc18b4d4a5a1ab92ce764da9c539e477560a7868ce2c83084353554410e197da4b8bb261f281a748f4063c57083c955ba544f74efa96d1f86476c97a1e0688dbd39667c2cebbb83e9e81a64a1d515a7f1765e02f2a72e2eb8d1674f4f9a8b5594e6cdfba5460cd3ac9eaa38cdaa134d8fb2ea39f47b0cffb0e83974c9bb428cabb0907c09cf841a68cfd9f061233b88f9865781642ceb32f33b806e6f67079088b44fbefe60581e6bfc766351d3a94fc7beefad48dbc39ad9a73fff7d9f6eb73e6908ff9f43d7907f11306f2dff7b7ef08a5e34f8c23be27a4e6e3f43a731d653e269ff3ed9c52768477859a8f4e9cdbcf9eb574450fce8aa7d9b1a989e9eba4094013c20626e0b7622a702d51b09ab4be4e141c1510234a690e7a52341a26ffd82c12bd1d9b57786c566c14d14373f0369b682178fa7ffe63195559a34ff54198e8a7c881dc1b6a56f7b9b21f652571f20632ffe0be2ca77be7bedbdfcfa10fc4d9dfe961ed87ff26defdc57bb7051dd7dc76fcf80e79c31ccdce481ba57cd6caaae782ef7aac38701e3287eededc0e9d55fad0b9cc60e768815c0de2ecc11ec8e5b3aebc4c5fea0feccb95f90cc236d3f7d78a2348a80d761089c5f69126509b103530d030525543f61cd2eb6155920ded23d088aaea12044a8f21a847a429d1280b8ea102d1db317485c790d06611341825a2a52b0d4509599e81c82f3831f635f2da41c6505263f616bb8fd7a5bbb9f8731220cdca13c31aa9a618e4005da892376922d0d450032a9aa12202240b2c1aa41ee25c5db294b7a1201469a9860a740de9989f02662809a6a668985cd02914bd81cef5818ed4ae11c31e0b904f6b6bafcdada3b334d328a838833dd699ebc1f489dfece923b7646c4211e27948437916fb22f4bed43375a7b81a201b47b8b4acd5feb074add562f549b92dad9520c6c93415619c58d971d244f47f0d9ddd91e5300e2bb5e4a001d9b2e386621891015b870801400a7226d2a20953405b5cf4867157887132bb4630a63717eb978dc1754e53c8887d1f531769bfd4402e3dbf340e2e1b3f0c683ce214765c6ed242ddf815a5f0318185bb3bac3c41c8aa789aa1145205ad90aad65450c3d089ae13459374dee17a4ab7f89d95422990a0bf358c3807ebb142940d938f5245a23794ba4294aad8286556c858c109c8e4c68115925af5bae3bf9015b203ecee9a934995b4baf956c28d09875a18089f7b2f1b7fa97b2db026a68a2ce4b2b3f216bae4e5f768a2a193b3d075ca154c36ffdc488b54bfeb77171928b7823e564ef0eb7ab317056cc1561870ab0614036e4d692a4a031be4f7d4cb5adc477e67e5805b5522bb1dc644d50cd52802eea4a841cad4cb22d11b705f21700b6e184100477d8716d7a6806ac1f5de0a6330acedd80b6fd39765d73971dd38f5c4466780ab52fe3c9f7c858098044211375366ac1c3792684c315f4e3c3e20ca18e3007738f714b88b7e8bbf0bf25d677b8103269c37876638ed96d3d6857d7bbfbfe5847c2fd9f752cb46713fffc061136447ee8e077377741989d0c7ea5f47bfcabc2d78d808b6c20e1b5df092a0194da437082d9108154db2ce1906b59c35baf41d01eb114f0354559da886cecfac3100d6228a9c689405474d81e8eda8b9c2a34670bb141b314c3456665b02cc2de3a7fcca33406cc75f36ad3a9d0bfd9ad55ea8ccd078235a1fb6cec2dcd9674114116b84818826c2fa0794a6829aaade8040c73a8d8a9505915aeca19a24eb9f01614c9e05715c52ecb142948d920f2245a23710b9421011db2dff1e862c7bc383d921c97b7cfd2a483acfc25b384befb0d8eeeccdcb6665a7dde6150022d19254dd1e8a227a53c10da8e81810249b6c8081f67f51b7c780c800ac6e0fd18d52144131216834ca02142910bda1c815a288c496e1dc7c05fdc61cb63c7a838d6f37eee3eb43ee867be2b0ecfd811b90919a81c5c7ea73b33a2d969eb77975572b41e4116b84810e80a2ba0ba1b1daba0e74820196d55d503dac4c505a79d1f4285e4537ca82e4d2a26c9805b053207a839d2b841db1fd52acbc246c28bc8b0f5c4ec6680a3b9720bc789c0f69e654f9916c33e78ca83daa0ea727c9d9594b4714904a9f6538a489d8618042437555ad4188aa1b483790240cc15a604893b5c340127b5509d214808a2cfe10e3c862130db200840a446f2074852054ba477e0f7bcc2e79a3852852787379168ab80dd6cb8abf60b28b456cbcc97f2a5b4e9937854beb1fdf1adffe29beb6fef1cdde598dd7ddb7fffe163299fa7f0ee9f6e80ffffc7fb1f4fee77f010000ffff0300c34a16d746990100`)))
@@ -0,0 +1,64 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package background runs long lived goroutines, like workers and metrics tickers, so they can be
+// stopped together on shutdown.
+package background
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Group runs functions until the Group is stopped. Each function is given a context which is
+// cancelled by Stop and should return soon after, once any in-flight work is finished.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewGroup returns a Group whose context is derived from parent
+func NewGroup(parent context.Context) *Group {
+	ctx, cancel := context.WithCancel(parent)
+	return &Group{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Context is cancelled when the Group is stopped. Pass it to anything which starts its own
+// goroutines so they stop along with the Group.
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Go runs fn in a goroutine which Stop waits for
+func (g *Group) Go(fn func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn(g.ctx)
+	}()
+}
+
+// Stop cancels the Group's context and waits up to timeout for every function to return.
+// It returns false if some are still running after timeout.
+func (g *Group) Stop(timeout time.Duration) bool {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package background
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	g := NewGroup(context.Background())
+
+	var finished int32
+	for i := 0; i < 3; i++ {
+		g.Go(func(ctx context.Context) {
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond) // in-flight work
+			atomic.AddInt32(&finished, 1)
+		})
+	}
+
+	require.True(t, g.Stop(time.Second))
+	require.Equal(t, int32(3), atomic.LoadInt32(&finished))
+	require.Error(t, g.Context().Err())
+}
+
+func TestGroup__timeout(t *testing.T) {
+	g := NewGroup(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+	g.Go(func(ctx context.Context) {
+		<-release
+	})
+
+	require.False(t, g.Stop(10*time.Millisecond))
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package countries
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"US":   "US",
+		"usa":  "US",
+		" ca ": "CA",
+		"GBR":  "GB",
+		"de":   "DE",
+	}
+	for input, expected := range cases {
+		if code, ok := Normalize(input); !ok || code != expected {
+			t.Errorf("Normalize(%q) = %q, %v", input, code, ok)
+		}
+	}
+
+	for _, input := range []string{"", "XX", "U.S.A.", "United States"} {
+		if code, ok := Normalize(input); ok {
+			t.Errorf("expected %q to be invalid, got %q", input, code)
+		}
+	}
+
+	if !Valid("mx") || Valid("MEX") {
+		t.Error("Valid only accepts alpha-2 codes")
+	}
+}
+
+func TestValidPostalCode(t *testing.T) {
+	valid := [][2]string{
+		{"US", "80202"},
+		{"US", "80202-1234"},
+		{"CA", "K1A 0B1"},
+		{"CA", "k1a0b1"},
+		{"GB", "SW1A 1AA"},
+		{"GB", "M1 1AE"},
+		{"NL", "1012 AB"},
+		{"JP", "100-0001"},
+		{"HK", ""},
+		{"AE", "12345"},
+	}
+	for _, c := range valid {
+		if !ValidPostalCode(c[0], c[1]) {
+			t.Errorf("expected %q to be a valid postal code in %s", c[1], c[0])
+		}
+	}
+
+	invalid := [][2]string{
+		{"US", ""},
+		{"US", "8020"},
+		{"US", "802021234"},
+		{"CA", "90210"},
+		{"DE", "1234"},
+		{"AE", "12345678901234567"},
+	}
+	for _, c := range invalid {
+		if ValidPostalCode(c[0], c[1]) {
+			t.Errorf("expected %q to be an invalid postal code in %s", c[1], c[0])
+		}
+	}
+}
+
+func TestValidRegion(t *testing.T) {
+	if !ValidRegion("US", "co") || ValidRegion("US", "ON") || ValidRegion("US", "") {
+		t.Error("unexpected US state validation")
+	}
+	if !ValidRegion("CA", "ON") || ValidRegion("CA", "CO") {
+		t.Error("unexpected Canadian province validation")
+	}
+	if !ValidRegion("GB", "Greater London") || !ValidRegion("DE", "") {
+		t.Error("expected free-form regions outside of the US and Canada")
+	}
+}
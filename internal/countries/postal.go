@@ -0,0 +1,94 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package countries
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/moov-io/customers/internal/usstates"
+)
+
+const (
+	// MaxRegionLength and MaxPostalCodeLength limit regions and postal codes in countries
+	// without a known format.
+	MaxRegionLength     = 100
+	MaxPostalCodeLength = 16
+)
+
+var (
+	// postalCodes are the formats of postal codes in countries we commonly see. Postal codes
+	// are uppercased before they're matched.
+	//
+	// https://en.wikipedia.org/wiki/List_of_postal_codes
+	postalCodes = map[string]*regexp.Regexp{
+		"AT": regexp.MustCompile(`^\d{4}$`),
+		"AU": regexp.MustCompile(`^\d{4}$`),
+		"BE": regexp.MustCompile(`^\d{4}$`),
+		"BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+		"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+		"CH": regexp.MustCompile(`^\d{4}$`),
+		"CN": regexp.MustCompile(`^\d{6}$`),
+		"DE": regexp.MustCompile(`^\d{5}$`),
+		"DK": regexp.MustCompile(`^\d{4}$`),
+		"ES": regexp.MustCompile(`^\d{5}$`),
+		"FI": regexp.MustCompile(`^\d{5}$`),
+		"FR": regexp.MustCompile(`^\d{5}$`),
+		"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+		"IE": regexp.MustCompile(`^[A-Z]\d[\dW] ?[A-Z\d]{4}$`),
+		"IN": regexp.MustCompile(`^\d{6}$`),
+		"IT": regexp.MustCompile(`^\d{5}$`),
+		"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+		"MX": regexp.MustCompile(`^\d{5}$`),
+		"NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+		"NO": regexp.MustCompile(`^\d{4}$`),
+		"NZ": regexp.MustCompile(`^\d{4}$`),
+		"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+		"PT": regexp.MustCompile(`^\d{4}-\d{3}$`),
+		"SE": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+		"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	}
+
+	// provinces are the abbreviations of Canada's provinces and territories.
+	//
+	// https://en.wikipedia.org/wiki/Provinces_and_territories_of_Canada
+	provinces = map[string]bool{
+		"AB": true, // Alberta
+		"BC": true, // British Columbia
+		"MB": true, // Manitoba
+		"NB": true, // New Brunswick
+		"NL": true, // Newfoundland and Labrador
+		"NS": true, // Nova Scotia
+		"NT": true, // Northwest Territories
+		"NU": true, // Nunavut
+		"ON": true, // Ontario
+		"PE": true, // Prince Edward Island
+		"QC": true, // Quebec
+		"SK": true, // Saskatchewan
+		"YT": true, // Yukon
+	}
+)
+
+// ValidPostalCode returns true if the postal code matches the format used in country.
+// Any postal code up to MaxPostalCodeLength is accepted for countries we don't have a format for.
+func ValidPostalCode(country, code string) bool {
+	if format, exists := postalCodes[strings.ToUpper(country)]; exists {
+		return format.MatchString(strings.ToUpper(code))
+	}
+	return len(code) <= MaxPostalCodeLength
+}
+
+// ValidRegion returns true if region is a state, province or region of country. US states and
+// Canadian provinces must be their two letter abbreviation. Other countries accept any region up
+// to MaxRegionLength.
+func ValidRegion(country, region string) bool {
+	switch strings.ToUpper(country) {
+	case "US":
+		return usstates.Valid(region)
+	case "CA":
+		return provinces[strings.ToUpper(region)]
+	}
+	return len(region) <= MaxRegionLength
+}
@@ -0,0 +1,161 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// MigrationChecksum is the SHA-256 checksum of a migration's SQL recorded when it was applied
+type MigrationChecksum struct {
+	Version   uint      `json:"version"`
+	Name      string    `json:"name"`
+	Checksum  string    `json:"checksum"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// createChecksumsTable is run before migrations rather than being one so checksums are recorded from the first
+const createChecksumsTable = `create table if not exists migration_checksums(
+  version integer primary key,
+  name varchar(255) not null,
+  checksum varchar(64) not null,
+  applied_at datetime not null
+);`
+
+// migrationChecksum returns the hex encoded SHA-256 hash of a migration's up SQL. Line endings are
+// normalized so checkouts with CRLF endings match.
+func migrationChecksum(src source.Driver, version uint) (string, string, error) {
+	r, name, err := src.ReadUp(version)
+	if err != nil {
+		return "", "", fmt.Errorf("reading migration %d: %v", version, err)
+	}
+	defer r.Close()
+
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", "", fmt.Errorf("reading migration %d: %v", version, err)
+	}
+	sum := sha256.Sum256(bytes.ReplaceAll(bs, []byte("\r\n"), []byte("\n")))
+	return hex.EncodeToString(sum[:]), name, nil
+}
+
+func readChecksums(db *sql.DB) ([]MigrationChecksum, error) {
+	rows, err := db.Query(`select version, name, checksum, applied_at from migration_checksums order by version asc;`)
+	if err != nil {
+		return nil, fmt.Errorf("reading migration checksums: %v", err)
+	}
+	defer rows.Close()
+
+	out := make([]MigrationChecksum, 0)
+	for rows.Next() {
+		var c MigrationChecksum
+		if err := rows.Scan(&c.Version, &c.Name, &c.Checksum, &c.AppliedAt); err != nil {
+			return nil, fmt.Errorf("reading migration checksums: %v", err)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func recordChecksum(db *sql.DB, src source.Driver, version uint) error {
+	checksum, name, err := migrationChecksum(src, version)
+	if err != nil {
+		return err
+	}
+	query := `insert into migration_checksums (version, name, checksum, applied_at) values (?, ?, ?, ?);`
+	if _, err := db.Exec(query, version, name, checksum, time.Now()); err != nil {
+		return fmt.Errorf("recording checksum of migration %d: %v", version, err)
+	}
+	return nil
+}
+
+// verifyChecksums compares the recorded checksum of each applied migration with its SQL. Applied migrations
+// without a checksum, like those applied before checksums were recorded, have theirs recorded.
+func verifyChecksums(db *sql.DB, src source.Driver, current int) error {
+	if _, err := db.Exec(createChecksumsTable); err != nil {
+		return fmt.Errorf("creating migration_checksums: %v", err)
+	}
+	recorded, err := readChecksums(db)
+	if err != nil {
+		return err
+	}
+	if err := compareChecksums(src, recorded); err != nil {
+		return err
+	}
+	found := make(map[uint]bool)
+	for _, c := range recorded {
+		found[c.Version] = true
+	}
+
+	version, err := src.First()
+	for err == nil && int(version) <= current {
+		if !found[version] {
+			if err := recordChecksum(db, src, version); err != nil {
+				return err
+			}
+		}
+		version, err = src.Next(version)
+	}
+	return nil
+}
+
+// compareChecksums returns an error naming each recorded migration whose SQL has changed or is missing
+func compareChecksums(src source.Driver, recorded []MigrationChecksum) error {
+	var changed []string
+	for _, c := range recorded {
+		checksum, _, err := migrationChecksum(src, c.Version)
+		if err != nil {
+			changed = append(changed, fmt.Sprintf("%03d_%s is missing", c.Version, c.Name))
+			continue
+		}
+		if checksum != c.Checksum {
+			changed = append(changed, fmt.Sprintf("%03d_%s has checksum %s but %s was applied", c.Version, c.Name, checksum, c.Checksum))
+		}
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("applied migrations were changed (%s), restore their original SQL and add a new migration instead", strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// AddMigrationAdminRoutes registers an endpoint listing the recorded checksum of each applied migration
+func AddMigrationAdminRoutes(logger log.Logger, svc *admin.Server, db *sql.DB) {
+	logger = logger.Set("package", log.String("database"))
+
+	svc.AddHandler("/migrations", func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+
+		if r.Method != "GET" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		checksums, err := readChecksums(db)
+		if err != nil {
+			logger.LogErrorf("problem listing migration checksums: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(checksums)
+	})
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratedb "github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+)
+
+// Migration is a numbered schema change from the migrations/ directory
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%03d_%s", m.Version, m.Name)
+}
+
+// PendingMigrations returns the migrations which haven't been applied to the database, in the order
+// they would run. Nothing is changed in the database.
+func PendingMigrations(logger log.Logger, config database.DatabaseConfig) ([]Migration, error) {
+	// cancelling stops the connection metrics started with the database
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := open(ctx, logger, config)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	src, driver, err := database.GetDriver(db, config)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return pendingMigrations(src, driver)
+}
+
+func pendingMigrations(src source.Driver, driver migratedb.Driver) ([]Migration, error) {
+	current, dirty, err := driver.Version()
+	if err != nil {
+		return nil, fmt.Errorf("reading database version: %v", err)
+	}
+	if dirty {
+		return nil, fmt.Errorf("database is dirty at version %d, a previous migration failed", current)
+	}
+
+	var out []Migration
+	version, err := src.First()
+	for err == nil {
+		if int(version) > current {
+			r, name, err := src.ReadUp(version)
+			if err != nil {
+				return nil, fmt.Errorf("reading migration %d: %v", version, err)
+			}
+			r.Close()
+			out = append(out, Migration{Version: version, Name: name})
+		}
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("listing migrations: %v", err)
+	}
+	return out, nil
+}
+
+// Migrate applies each pending migration one at a time, logging the name and duration of each. The
+// checksum of each migration is recorded once it's applied and Migrate fails when the SQL of an
+// applied migration has since changed.
+func Migrate(logger log.Logger, config database.DatabaseConfig) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := open(ctx, logger, config)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	src, driver, err := database.GetDriver(db, config)
+	if err != nil {
+		return err
+	}
+	pending, err := pendingMigrations(src, driver)
+	if err != nil {
+		return err
+	}
+	current, _, err := driver.Version()
+	if err != nil {
+		return fmt.Errorf("reading database version: %v", err)
+	}
+	if err := verifyChecksums(db, src, current); err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		logger.Info().Log("database already at latest version")
+		return nil
+	}
+
+	m, err := migrate.NewWithInstance("pkger", src, config.DatabaseName, driver)
+	if err != nil {
+		return fmt.Errorf("setting up migrations: %v", err)
+	}
+	for i := range pending {
+		start := time.Now()
+		if err := m.Steps(1); err != nil {
+			return fmt.Errorf("migration %s: %v", pending[i], err)
+		}
+		if err := recordChecksum(db, src, pending[i].Version); err != nil {
+			return err
+		}
+		logger.Info().Logf("applied migration %s in %v", pending[i], time.Since(start))
+	}
+	logger.Info().Logf("applied %d migrations", len(pending))
+	return nil
+}
+
+// VerifySchema checks the database has every migration applied without changing it, for deployments
+// where migrations are run separately. It fails when migrations are pending, a previous migration
+// failed or the SQL of an applied migration has since changed. Databases migrated by other tools
+// may not have checksums recorded, those aren't compared.
+func VerifySchema(logger log.Logger, config database.DatabaseConfig) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := open(ctx, logger, config)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	src, driver, err := database.GetDriver(db, config)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	pending, err := pendingMigrations(src, driver)
+	if err != nil {
+		return err
+	}
+	current, _, err := driver.Version()
+	if err != nil {
+		return fmt.Errorf("reading database version: %v", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database is at version %d but %s is expected, %d migrations are pending", current, pending[len(pending)-1], len(pending))
+	}
+
+	recorded, err := readChecksums(db)
+	if err != nil {
+		logger.Warn().Logf("skipping migration checksums: %v", err)
+	} else if err := compareChecksums(src, recorded); err != nil {
+		return err
+	}
+	logger.Info().Logf("database schema is at expected version %d", current)
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "customers-migrations")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config := database.DatabaseConfig{
+		DatabaseName: "customers",
+		SQLite: &database.SQLiteConfig{
+			Path: filepath.Join(dir, "customers.db"),
+		},
+	}
+	logger := log.NewNopLogger()
+
+	pending, err := PendingMigrations(logger, config)
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.up.sql"))
+	require.NoError(t, err)
+	require.Len(t, pending, len(files))
+	require.Equal(t, "001_create_customers", pending[0].String())
+
+	// listing doesn't apply anything
+	again, err := PendingMigrations(logger, config)
+	require.NoError(t, err)
+	require.Equal(t, pending, again)
+
+	require.NoError(t, Migrate(logger, config))
+
+	pending, err = PendingMigrations(logger, config)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	require.NoError(t, Migrate(logger, config))
+}
+
+func TestMigrations__verifySchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "customers-migrations")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config := database.DatabaseConfig{
+		DatabaseName: "customers",
+		SQLite: &database.SQLiteConfig{
+			Path: filepath.Join(dir, "customers.db"),
+		},
+	}
+	logger := log.NewNopLogger()
+
+	// verifying doesn't apply anything
+	err = VerifySchema(logger, config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "migrations are pending")
+	pending, err := PendingMigrations(logger, config)
+	require.NoError(t, err)
+	require.NotEmpty(t, pending)
+
+	require.NoError(t, Migrate(logger, config))
+	require.NoError(t, VerifySchema(logger, config))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db, err := open(ctx, logger, config)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// databases migrated by other tools don't have checksums
+	_, err = db.Exec(`drop table migration_checksums;`)
+	require.NoError(t, err)
+	require.NoError(t, VerifySchema(logger, config))
+
+	require.NoError(t, Migrate(logger, config))
+	_, err = db.Exec(`update migration_checksums set checksum = 'changed' where version = 2;`)
+	require.NoError(t, err)
+	err = VerifySchema(logger, config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "applied migrations were changed")
+}
+
+func TestMigrations__checksums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "customers-migrations")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config := database.DatabaseConfig{
+		DatabaseName: "customers",
+		SQLite: &database.SQLiteConfig{
+			Path: filepath.Join(dir, "customers.db"),
+		},
+	}
+	logger := log.NewNopLogger()
+	require.NoError(t, Migrate(logger, config))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db, err := open(ctx, logger, config)
+	require.NoError(t, err)
+	defer db.Close()
+
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.up.sql"))
+	require.NoError(t, err)
+	checksums, err := readChecksums(db)
+	require.NoError(t, err)
+	require.Len(t, checksums, len(files))
+	require.Equal(t, uint(1), checksums[0].Version)
+	require.Equal(t, "create_customers", checksums[0].Name)
+	require.Len(t, checksums[0].Checksum, 64)
+
+	// migrations applied before checksums were recorded have theirs recorded
+	_, err = db.Exec(`delete from migration_checksums where version > 1;`)
+	require.NoError(t, err)
+	require.NoError(t, Migrate(logger, config))
+	again, err := readChecksums(db)
+	require.NoError(t, err)
+	require.Len(t, again, len(files))
+	require.Equal(t, checksums[len(files)-1].Checksum, again[len(files)-1].Checksum)
+
+	// a changed migration stops migrations from running
+	_, err = db.Exec(`update migration_checksums set checksum = 'changed' where version = 2;`)
+	require.NoError(t, err)
+	err = Migrate(logger, config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "002_"+checksums[1].Name+" has checksum "+checksums[1].Checksum+" but changed was applied")
+
+	svc := admin.NewServer(":0")
+	defer svc.Shutdown()
+	AddMigrationAdminRoutes(logger, svc, db)
+	go svc.Listen()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/migrations", svc.BindAddr()))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var listed []MigrationChecksum
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	require.Len(t, listed, len(files))
+	require.Equal(t, "changed", listed[1].Checksum)
+}
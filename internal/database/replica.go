@@ -0,0 +1,263 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/config"
+)
+
+// Connect opens the database described by cfg and applies its connection limits. When a MySQL
+// replica is configured the returned handle sends SELECTs to the replica and writes, transactions
+// and locking reads to the primary. Without a replica every query goes to the primary.
+func Connect(ctx context.Context, logger log.Logger, cfg *config.Config) (*sql.DB, error) {
+	if cfg.Database.MySQL == nil || cfg.Replica.Address == "" {
+		db, err := open(ctx, logger, *cfg.Database)
+		if err != nil {
+			return nil, err
+		}
+		config.ApplyConnections(db, cfg.Connections)
+		return db, nil
+	}
+
+	mysql := cfg.Database.MySQL
+	primary := &dsnConnector{driver: &gomysql.MySQLDriver{}, dsn: mysqlDSN(mysql.User, mysql.Password, mysql.Address, cfg.Database.DatabaseName)}
+	replica := &dsnConnector{driver: &gomysql.MySQLDriver{}, dsn: mysqlDSN(mysql.User, mysql.Password, cfg.Replica.Address, cfg.Database.DatabaseName)}
+
+	// Check the replica is reachable so a bad address fails on startup
+	check := sql.OpenDB(replica)
+	defer check.Close()
+	if err := check.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("connecting to replica: %v", err)
+	}
+
+	db := newReplicaDB(primary, replica, cfg.Replica.ReadAfterWrite)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	config.ApplyConnections(db, cfg.Connections)
+
+	logger.Logf("sending reads to MySQL replica at %s", cfg.Replica.Address)
+	return db, nil
+}
+
+// mysqlDSN matches the connection settings of github.com/moov-io/base/database
+func mysqlDSN(user, pass, address, name string) string {
+	timeout := "30s"
+	if v := os.Getenv("MYSQL_TIMEOUT"); v != "" {
+		timeout = v
+	}
+	params := fmt.Sprintf("timeout=%s&charset=utf8mb4&parseTime=true&sql_mode=ALLOW_INVALID_DATES", timeout)
+	return fmt.Sprintf("%s:%s@%s/%s?%s", user, pass, address, name, params)
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns a context whose queries always go to the primary. Use it with the
+// *Context methods of sql.DB to read rows written earlier in the same request.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// replicaRouter is a driver.Connector whose connections each hold a primary and a replica connection
+type replicaRouter struct {
+	primary, replica driver.Connector
+
+	// reads go to the primary for readAfterWrite after the last write
+	readAfterWrite time.Duration
+	lastWrite      int64 // unix nanos
+}
+
+func newReplicaDB(primary, replica driver.Connector, readAfterWrite time.Duration) *sql.DB {
+	return sql.OpenDB(&replicaRouter{
+		primary:        primary,
+		replica:        replica,
+		readAfterWrite: readAfterWrite,
+	})
+}
+
+func (r *replicaRouter) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := r.primary.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &routedConn{router: r, primary: conn}, nil
+}
+
+func (r *replicaRouter) Driver() driver.Driver {
+	return r.primary.Driver()
+}
+
+func (r *replicaRouter) wrote() {
+	atomic.StoreInt64(&r.lastWrite, time.Now().UnixNano())
+}
+
+func (r *replicaRouter) recentlyWrote() bool {
+	last := atomic.LoadInt64(&r.lastWrite)
+	return last > 0 && time.Since(time.Unix(0, last)) < r.readAfterWrite
+}
+
+// isRead returns true for queries a replica can answer. Locking reads need the primary.
+func isRead(query string) bool {
+	q := strings.ToLower(strings.TrimSpace(query))
+	return strings.HasPrefix(q, "select") && !strings.Contains(q, " for update") && !strings.Contains(q, " lock in share mode")
+}
+
+type routedConn struct {
+	router  *replicaRouter
+	primary driver.Conn
+	replica driver.Conn // opened on the first read
+	inTx    bool
+}
+
+// conn returns the connection query should run on
+func (c *routedConn) conn(ctx context.Context, query string) (driver.Conn, error) {
+	if c.inTx {
+		return c.primary, nil
+	}
+	if !isRead(query) {
+		c.router.wrote()
+		return c.primary, nil
+	}
+	if force, _ := ctx.Value(primaryKey{}).(bool); force || c.router.recentlyWrote() {
+		return c.primary, nil
+	}
+	if c.replica == nil {
+		conn, err := c.router.replica.Connect(ctx)
+		if err != nil {
+			// the primary can answer reads while the replica is down
+			return c.primary, nil
+		}
+		c.replica = conn
+	}
+	return c.replica, nil
+}
+
+func (c *routedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *routedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	conn, err := c.conn(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return conn.Prepare(query)
+}
+
+func (c *routedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	conn, err := c.conn(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if q, ok := conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *routedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	conn, err := c.conn(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *routedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *routedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	var err error
+	if b, ok := c.primary.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.primary.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.inTx = true
+	return &routedTx{Tx: tx, conn: c}, nil
+}
+
+func (c *routedConn) Ping(ctx context.Context) error {
+	if p, ok := c.primary.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *routedConn) ResetSession(ctx context.Context) error {
+	for _, conn := range []driver.Conn{c.primary, c.replica} {
+		if r, ok := conn.(driver.SessionResetter); ok {
+			if err := r.ResetSession(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *routedConn) Close() error {
+	err := c.primary.Close()
+	if c.replica != nil {
+		if e := c.replica.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// routedTx sends reads back to the replica once the transaction finishes
+type routedTx struct {
+	driver.Tx
+	conn *routedConn
+}
+
+func (tx *routedTx) Commit() error {
+	tx.conn.inTx = false
+	err := tx.Tx.Commit()
+	tx.conn.router.wrote()
+	return err
+}
+
+func (tx *routedTx) Rollback() error {
+	tx.conn.inTx = false
+	return tx.Tx.Rollback()
+}
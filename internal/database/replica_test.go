@@ -0,0 +1,157 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/config"
+)
+
+type failingConnector struct{}
+
+func (failingConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("replica is down")
+}
+
+func (failingConnector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// createReplicaDB returns a handle over two SQLite databases which each have a row naming the database
+func createReplicaDB(t *testing.T, readAfterWrite time.Duration) *sql.DB {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "replica")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	connector := func(name string) *dsnConnector {
+		path := filepath.Join(dir, name+".db")
+		db, err := sql.Open("sqlite3", path)
+		require.NoError(t, err)
+		_, err = db.Exec(`create table servers (name text);`)
+		require.NoError(t, err)
+		_, err = db.Exec(`insert into servers (name) values (?);`, name)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		return &dsnConnector{driver: &sqlite3.SQLiteDriver{}, dsn: path}
+	}
+
+	db := newReplicaDB(connector("primary"), connector("replica"), readAfterWrite)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func readServer(t *testing.T, ctx context.Context, db *sql.DB) string {
+	t.Helper()
+
+	var name string
+	require.NoError(t, db.QueryRowContext(ctx, `select name from servers where name <> 'written' limit 1;`).Scan(&name))
+	return name
+}
+
+func TestReplica__routing(t *testing.T) {
+	db := createReplicaDB(t, 0)
+	ctx := context.Background()
+
+	require.Equal(t, "replica", readServer(t, ctx, db))
+	require.Equal(t, "primary", readServer(t, WithPrimary(ctx), db))
+
+	// prepared statements are routed too
+	stmt, err := db.Prepare(`select name from servers;`)
+	require.NoError(t, err)
+	defer stmt.Close()
+	var name string
+	require.NoError(t, stmt.QueryRow().Scan(&name))
+	require.Equal(t, "replica", name)
+
+	// writes go to the primary
+	_, err = db.Exec(`insert into servers (name) values ('written');`)
+	require.NoError(t, err)
+
+	var count int
+	require.NoError(t, db.QueryRowContext(WithPrimary(ctx), `select count(*) from servers where name = 'written';`).Scan(&count))
+	require.Equal(t, 1, count)
+	require.NoError(t, db.QueryRow(`select count(*) from servers where name = 'written';`).Scan(&count))
+	require.Equal(t, 0, count)
+
+	// transactions only use the primary
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.QueryRow(`select name from servers where name <> 'written';`).Scan(&name))
+	require.Equal(t, "primary", name)
+	require.NoError(t, tx.Rollback())
+
+	require.Equal(t, "replica", readServer(t, ctx, db))
+}
+
+func TestReplica__readAfterWrite(t *testing.T) {
+	db := createReplicaDB(t, time.Hour)
+	ctx := context.Background()
+
+	require.Equal(t, "replica", readServer(t, ctx, db))
+
+	_, err := db.Exec(`update servers set name = name;`)
+	require.NoError(t, err)
+	require.Equal(t, "primary", readServer(t, ctx, db))
+}
+
+func TestReplica__unavailable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replica")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	primary := &dsnConnector{driver: &sqlite3.SQLiteDriver{}, dsn: filepath.Join(dir, "primary.db")}
+	db := newReplicaDB(primary, failingConnector{}, 0)
+	defer db.Close()
+
+	_, err = db.Exec(`create table servers (name text);`)
+	require.NoError(t, err)
+	_, err = db.Exec(`insert into servers (name) values ('primary');`)
+	require.NoError(t, err)
+
+	require.Equal(t, "primary", readServer(t, context.Background(), db))
+}
+
+func TestReplica__isRead(t *testing.T) {
+	require.True(t, isRead("  SELECT * from customers;"))
+	require.False(t, isRead("select * from customers where customer_id = ? for update;"))
+	require.False(t, isRead("select * from customers lock in share mode;"))
+	require.False(t, isRead("update customers set status = ?;"))
+	require.False(t, isRead("insert into customers (customer_id) values (?);"))
+}
+
+func TestConnect__noReplica(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replica")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := config.New()
+	cfg.Database.SQLite = &database.SQLiteConfig{Path: filepath.Join(dir, "customers.db")}
+	cfg.Connections = database.ConnectionsConfig{MaxOpen: 3, MaxIdle: 1}
+	cfg.Replica.Address = "tcp(replica:3306)" // ignored outside of MySQL
+
+	db, err := Connect(context.Background(), log.NewNopLogger(), cfg)
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.Ping())
+	require.Equal(t, 3, db.Stats().MaxOpenConnections)
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	// lockRetries is how many times a transaction is retried after SQLite reports the database is locked
+	lockRetries = func() int {
+		if v := os.Getenv("SQLITE_LOCK_RETRIES"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				return n
+			}
+		}
+		return 5
+	}()
+
+	// lockBackoff is the wait before the first retry, it doubles after each attempt
+	lockBackoff = 10 * time.Millisecond
+)
+
+// SQLiteLocked returns true when the provided error is SQLite's SQLITE_BUSY or SQLITE_LOCKED
+// error, which are returned when another connection is writing to the database.
+func SQLiteLocked(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrLocked) {
+		return true
+	}
+	if e, ok := err.(sqlite3.Error); ok {
+		return e.Code == sqlite3.ErrBusy || e.Code == sqlite3.ErrLocked
+	}
+	// Repositories often wrap errors with fmt.Errorf and %v so match on the message as well.
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+}
+
+// RetryOnLock runs fn in a transaction with WithTransaction. When SQLite reports the database is
+// locked the transaction is rolled back and retried with a short backoff, up to SQLITE_LOCK_RETRIES times.
+// Any other error from fn (e.g. a unique violation) rolls back the transaction and is returned as-is.
+//
+// When db is a *sql.Tx fn isn't retried, the caller which began the transaction retries all of it.
+func RetryOnLock(db Querier, fn func(tx *sql.Tx) error) error {
+	ctx, cancelFn := QueryContext(context.Background())
+	defer cancelFn()
+	return RetryOnLockContext(ctx, db, fn)
+}
+
+// RetryOnLockContext is RetryOnLock with each transaction begun on ctx. Retries stop once ctx is
+// done and its error is returned.
+func RetryOnLockContext(ctx context.Context, db Querier, fn func(tx *sql.Tx) error) error {
+	if _, ok := db.(*sql.Tx); ok {
+		return WithTransactionContext(ctx, db, fn)
+	}
+	backoff := lockBackoff
+	for attempt := 0; ; attempt++ {
+		err := WithTransactionContext(ctx, db, fn)
+		if err == nil || !SQLiteLocked(err) || attempt >= lockRetries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
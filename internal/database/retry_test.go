@@ -0,0 +1,116 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/database"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestSQLiteLocked(t *testing.T) {
+	require.False(t, SQLiteLocked(nil))
+	require.False(t, SQLiteLocked(errors.New("bad error")))
+	require.False(t, SQLiteLocked(sqlite3.Error{Code: sqlite3.ErrConstraint}))
+
+	require.True(t, SQLiteLocked(sqlite3.Error{Code: sqlite3.ErrBusy}))
+	require.True(t, SQLiteLocked(sqlite3.Error{Code: sqlite3.ErrLocked}))
+	require.True(t, SQLiteLocked(fmt.Errorf("CreateCustomer: insert into customers: %v", errors.New("database is locked"))))
+}
+
+func TestRetryOnLock(t *testing.T) {
+	backoff := lockBackoff
+	lockBackoff = time.Millisecond
+	defer func() { lockBackoff = backoff }()
+
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	_, err := db.DB.Exec(`create table retry_test (id integer primary key);`)
+	require.NoError(t, err)
+
+	// locked transactions are retried and committed once they succeed
+	attempts := 0
+	err = RetryOnLock(db.DB, func(tx *sql.Tx) error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("insert: %v", sqlite3.Error{Code: sqlite3.ErrBusy})
+		}
+		_, err := tx.Exec(`insert into retry_test (id) values (1);`)
+		return err
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+
+	var count int
+	require.NoError(t, db.DB.QueryRow(`select count(*) from retry_test;`).Scan(&count))
+	require.Equal(t, 1, count)
+
+	// unique violations are returned without retrying
+	attempts = 0
+	err = RetryOnLock(db.DB, func(tx *sql.Tx) error {
+		attempts++
+		_, err := tx.Exec(`insert into retry_test (id) values (1);`)
+		return err
+	})
+	require.True(t, database.UniqueViolation(err))
+	require.Equal(t, 1, attempts)
+
+	// give up after lockRetries
+	attempts = 0
+	err = RetryOnLock(db.DB, func(tx *sql.Tx) error {
+		attempts++
+		return errors.New("database is locked")
+	})
+	require.True(t, SQLiteLocked(err))
+	require.Equal(t, lockRetries+1, attempts)
+
+	// failed attempts are rolled back
+	attempts = 0
+	err = RetryOnLock(db.DB, func(tx *sql.Tx) error {
+		attempts++
+		if _, err := tx.Exec(`insert into retry_test (id) values (2);`); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			return errors.New("database is locked")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.DB.QueryRow(`select count(*) from retry_test;`).Scan(&count))
+	require.Equal(t, 2, count)
+}
+
+func TestRetryOnLockContext(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	// retries stop once the context is done
+	ctx, cancelFn := context.WithCancel(context.Background())
+	attempts := 0
+	err := RetryOnLockContext(ctx, db.DB, func(tx *sql.Tx) error {
+		attempts++
+		cancelFn()
+		return errors.New("database is locked")
+	})
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, 1, attempts)
+
+	// transactions aren't begun on a cancelled context
+	err = RetryOnLockContext(ctx, db.DB, func(tx *sql.Tx) error {
+		t.Fatal("unexpected transaction")
+		return nil
+	})
+	require.True(t, errors.Is(err, context.Canceled), "%v", err)
+}
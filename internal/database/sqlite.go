@@ -0,0 +1,29 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+)
+
+// open connects to the database after creating the directory of a SQLite database, so the file can
+// be created on first start.
+func open(ctx context.Context, logger log.Logger, config database.DatabaseConfig) (*sql.DB, error) {
+	if config.SQLite != nil {
+		if dir := filepath.Dir(config.SQLite.Path); dir != "." {
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				return nil, fmt.Errorf("creating SQLite directory: %v", err)
+			}
+		}
+	}
+	return database.New(ctx, logger, config)
+}
@@ -0,0 +1,38 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// queryTimeout is the longest a query started with QueryContext can run before it's cancelled
+var queryTimeout = 10 * time.Second
+
+// SetQueryTimeout changes how long queries started with QueryContext can run, it's read from
+// DATABASE_QUERY_TIMEOUT on startup. Zero disables the timeout.
+func SetQueryTimeout(dur time.Duration) error {
+	if dur < 0 {
+		return errors.New("query timeout can't be negative")
+	}
+	queryTimeout = dur
+	return nil
+}
+
+// QueryContext returns the context to run one query with. It's cancelled when ctx is, usually
+// because the client went away, or once DATABASE_QUERY_TIMEOUT passes. The query returns
+// context.Canceled or context.DeadlineExceeded in those cases. Callers must call the returned
+// cancel func after they're done reading rows.
+func QueryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, queryTimeout)
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryContext(t *testing.T) {
+	ctx, cancelFn := QueryContext(context.Background())
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	require.WithinDuration(t, time.Now().Add(queryTimeout), deadline, time.Second)
+	cancelFn()
+	require.Equal(t, context.Canceled, ctx.Err())
+
+	// an earlier deadline on the request is kept
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancelParent()
+	ctx, cancelFn = QueryContext(parent)
+	defer cancelFn()
+	<-ctx.Done()
+	require.Equal(t, context.DeadlineExceeded, ctx.Err())
+
+	// no timeout
+	prev := queryTimeout
+	defer func() { queryTimeout = prev }()
+	queryTimeout = 0
+	ctx, cancelFn = QueryContext(nil)
+	defer cancelFn()
+	_, ok = ctx.Deadline()
+	require.False(t, ok)
+}
+
+func TestQueryContext__cancelsQuery(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	prev := queryTimeout
+	defer func() { queryTimeout = prev }()
+	queryTimeout = 50 * time.Millisecond
+
+	ctx, cancelFn := QueryContext(context.Background())
+	defer cancelFn()
+
+	// counts far beyond what can finish before the timeout
+	var n int64
+	err := db.DB.QueryRowContext(ctx, `with recursive c(x) as (select 1 union all select x + 1 from c where x < 1000000000) select count(*) from c;`).Scan(&n)
+	require.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+}
+
+func TestSetQueryTimeout(t *testing.T) {
+	prev := queryTimeout
+	defer func() { queryTimeout = prev }()
+
+	require.NoError(t, SetQueryTimeout(time.Minute))
+	require.Equal(t, time.Minute, queryTimeout)
+
+	require.NoError(t, SetQueryTimeout(0))
+	require.Equal(t, time.Duration(0), queryTimeout)
+
+	require.Error(t, SetQueryTimeout(-1*time.Second))
+	require.Equal(t, time.Duration(0), queryTimeout)
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrLocked is returned from WithTransaction when SQLite reports the database is locked by another
+// connection. The transaction was rolled back and can be retried.
+var ErrLocked = errors.New("database is locked")
+
+// Querier is implemented by both *sql.DB and *sql.Tx. Repositories which hold a Querier run their
+// queries inside a transaction when they're given a *sql.Tx, so several repositories can write in one
+// transaction with WithTransaction.
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Prepare(query string) (*sql.Stmt, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// WithTransaction begins a transaction on db, calls fn and commits when it returns nil. The
+// transaction is rolled back if fn returns an error or panics, the panic is then raised again.
+//
+// When db is already a *sql.Tx fn runs inside it and the outermost WithTransaction commits or
+// rolls back, so repository methods compose into larger transactions.
+//
+// SQLite's busy and locked errors are returned as ErrLocked. Other errors are returned as-is.
+//
+// The transaction is rolled back once DATABASE_QUERY_TIMEOUT passes, use WithTransactionContext
+// to also cancel it with a request.
+func WithTransaction(db Querier, fn func(tx *sql.Tx) error) error {
+	ctx, cancelFn := QueryContext(context.Background())
+	defer cancelFn()
+	return WithTransactionContext(ctx, db, fn)
+}
+
+// WithTransactionContext is WithTransaction with the transaction begun on ctx. It's rolled back when
+// ctx is cancelled or its deadline passes, fn should run its statements with ctx as well.
+func WithTransactionContext(ctx context.Context, db Querier, fn func(tx *sql.Tx) error) (err error) {
+	if tx, ok := db.(*sql.Tx); ok {
+		return fn(tx)
+	}
+	conn, ok := db.(interface {
+		BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	})
+	if !ok {
+		return fmt.Errorf("%T doesn't support transactions", db)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return translateError(err)
+	}
+	return translateError(tx.Commit())
+}
+
+func translateError(err error) error {
+	if err != nil && !errors.Is(err, ErrLocked) && SQLiteLocked(err) {
+		return &lockedError{err: err}
+	}
+	return err
+}
+
+// lockedError keeps SQLite's error and message while matching ErrLocked with errors.Is
+type lockedError struct {
+	err error
+}
+
+func (e *lockedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *lockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+func (e *lockedError) Unwrap() error {
+	return e.err
+}
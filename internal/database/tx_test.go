@@ -0,0 +1,92 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/moov-io/base/database"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestWithTransaction(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	_, err := db.DB.Exec(`create table tx_test (id integer primary key);`)
+	require.NoError(t, err)
+
+	count := func() int {
+		var n int
+		require.NoError(t, db.DB.QueryRow(`select count(*) from tx_test;`).Scan(&n))
+		return n
+	}
+
+	// committed
+	err = WithTransaction(db.DB, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`insert into tx_test (id) values (1);`)
+		return err
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, count())
+
+	// rolled back on error
+	expected := errors.New("bad error")
+	err = WithTransaction(db.DB, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`insert into tx_test (id) values (2);`); err != nil {
+			return err
+		}
+		return expected
+	})
+	require.Equal(t, expected, err)
+	require.Equal(t, 1, count())
+
+	// rolled back on panic
+	require.PanicsWithValue(t, "boom", func() {
+		WithTransaction(db.DB, func(tx *sql.Tx) error {
+			tx.Exec(`insert into tx_test (id) values (3);`)
+			panic("boom")
+		})
+	})
+	require.Equal(t, 1, count())
+
+	// nested calls join the outer transaction
+	err = WithTransaction(db.DB, func(tx *sql.Tx) error {
+		err := WithTransaction(tx, func(inner *sql.Tx) error {
+			require.Equal(t, tx, inner)
+			_, err := inner.Exec(`insert into tx_test (id) values (4);`)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return expected
+	})
+	require.Equal(t, expected, err)
+	require.Equal(t, 1, count())
+}
+
+func TestWithTransaction__locked(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	err := WithTransaction(db.DB, func(tx *sql.Tx) error {
+		return fmt.Errorf("insert: %v", sqlite3.Error{Code: sqlite3.ErrLocked})
+	})
+	require.True(t, errors.Is(err, ErrLocked))
+	require.True(t, SQLiteLocked(err))
+	require.Contains(t, err.Error(), "insert: ")
+
+	// other errors aren't translated
+	err = WithTransaction(db.DB, func(tx *sql.Tx) error {
+		return sqlite3.Error{Code: sqlite3.ErrConstraint}
+	})
+	require.False(t, errors.Is(err, ErrLocked))
+}
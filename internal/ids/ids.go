@@ -0,0 +1,153 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package ids generates Customer IDs in the format chosen by operators and checks IDs read from
+// requests match it.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moov-io/base"
+)
+
+// Format is how Customer IDs are generated
+type Format string
+
+const (
+	// Default is 40 random hex characters from moov-io/base.ID()
+	Default Format = "default"
+
+	// UUID is a random (version 4) UUID like 1b4e28ba-2fa1-4d2e-883f-0016d3cca427
+	UUID Format = "uuid"
+
+	// ULID is a time-sortable ULID like 01ARZ3NDEKTSV4RRFFQ69G5FAV. IDs generated later sort
+	// after earlier ones (within a millisecond their order is random), so they're a proxy for
+	// creation time and new rows are appended to the end of indexes.
+	//
+	// https://github.com/ulid/spec
+	ULID Format = "ulid"
+)
+
+var (
+	mu     sync.RWMutex
+	format = Default
+
+	patterns = map[Format]*regexp.Regexp{
+		Default: regexp.MustCompile(`^[a-f0-9]{40}$`),
+		UUID:    regexp.MustCompile(`^[a-f0-9]{8}-[a-f0-9]{4}-4[a-f0-9]{3}-[89ab][a-f0-9]{3}-[a-f0-9]{12}$`),
+		ULID:    regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`),
+	}
+)
+
+// ParseFormat reads a Format such as the CUSTOMER_ID_FORMAT environment variable. Empty values are Default.
+func ParseFormat(v string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(v))); f {
+	case "":
+		return Default, nil
+	case Default, UUID, ULID:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown ID format %q", v)
+}
+
+// SetFormat changes the format of generated Customer IDs
+func SetFormat(f Format) {
+	mu.Lock()
+	defer mu.Unlock()
+	format = f
+}
+
+func currentFormat() Format {
+	mu.RLock()
+	defer mu.RUnlock()
+	return format
+}
+
+// NewCustomerID returns a Customer ID in the configured format
+func NewCustomerID() string {
+	switch currentFormat() {
+	case UUID:
+		return newUUID()
+	case ULID:
+		return newULID(time.Now())
+	}
+	return base.ID()
+}
+
+// ValidCustomerID returns false if id can't be a Customer ID. With the Default format IDs aren't
+// checked, as they were always opaque strings. Otherwise IDs must match the configured format or be
+// a Default ID created before the format was chosen.
+func ValidCustomerID(id string) bool {
+	f := currentFormat()
+	if f == Default {
+		return id != ""
+	}
+	return patterns[f].MatchString(id) || patterns[Default].MatchString(id)
+}
+
+// IsID returns true if v looks like an ID in any Format
+func IsID(v string) bool {
+	for _, p := range patterns {
+		if p.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func newUUID() string {
+	var bs [16]byte
+	if _, err := rand.Read(bs[:]); err != nil {
+		return ""
+	}
+	bs[6] = (bs[6] & 0x0f) | 0x40 // version 4
+	bs[8] = (bs[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], bs[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], bs[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], bs[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], bs[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], bs[10:])
+	return string(buf)
+}
+
+// crockford is the Base32 alphabet ULIDs are encoded with
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID encodes the milliseconds of now as the first 10 characters and 80 random bits as the last 16
+func newULID(now time.Time) string {
+	var entropy [10]byte
+	if _, err := rand.Read(entropy[:]); err != nil {
+		return ""
+	}
+
+	out := make([]byte, 26)
+	ms := uint64(now.UnixNano() / int64(time.Millisecond))
+	for i := 9; i >= 0; i-- {
+		out[i] = crockford[ms&0x1f]
+		ms >>= 5
+	}
+
+	hi := uint64(binary.BigEndian.Uint16(entropy[0:2]))
+	lo := binary.BigEndian.Uint64(entropy[2:])
+	for i := 25; i >= 10; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = (lo >> 5) | (hi&0x1f)<<59
+		hi >>= 5
+	}
+	return string(out)
+}
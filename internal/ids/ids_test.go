@@ -0,0 +1,65 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package ids
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	for v, expected := range map[string]Format{"": Default, "default": Default, "UUID": UUID, " ulid ": ULID} {
+		f, err := ParseFormat(v)
+		require.NoError(t, err)
+		require.Equal(t, expected, f)
+	}
+	_, err := ParseFormat("snowflake")
+	require.Error(t, err)
+}
+
+func TestNewCustomerID(t *testing.T) {
+	defer SetFormat(Default)
+
+	id := NewCustomerID()
+	require.Len(t, id, 40)
+	require.True(t, ValidCustomerID(id))
+	require.True(t, ValidCustomerID("foo"), "default IDs are opaque")
+	require.False(t, ValidCustomerID(""))
+
+	SetFormat(UUID)
+	id = NewCustomerID()
+	require.Regexp(t, patterns[UUID], id)
+	require.True(t, ValidCustomerID(id))
+	require.True(t, ValidCustomerID(base.ID()), "IDs from before the format changed are still valid")
+	require.False(t, ValidCustomerID("foo"))
+	require.False(t, ValidCustomerID(newULID(time.Now())))
+
+	SetFormat(ULID)
+	id = NewCustomerID()
+	require.Regexp(t, patterns[ULID], id)
+	require.True(t, ValidCustomerID(id))
+	require.False(t, ValidCustomerID(newUUID()))
+
+	require.True(t, IsID(id))
+	require.True(t, IsID(newUUID()))
+	require.False(t, IsID("customers"))
+}
+
+func TestULID(t *testing.T) {
+	// https://github.com/ulid/spec lists 01ARYZ6S41 as the timestamp of 1469918176385
+	at := time.Unix(0, 1469918176385*int64(time.Millisecond))
+	require.Equal(t, "01ARYZ6S41", newULID(at)[:10])
+
+	var generated []string
+	start := time.Now()
+	for i := 0; i < 50; i++ {
+		generated = append(generated, newULID(start.Add(time.Duration(i)*time.Millisecond)))
+	}
+	require.True(t, sort.StringsAreSorted(generated))
+}
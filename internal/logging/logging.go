@@ -0,0 +1,114 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/internal/util"
+)
+
+// Debug is the level of verbose lines, like each HTTP request, which are dropped unless the
+// logger's level is debug
+const Debug = log.Level("debug")
+
+// redacted replaces the value of sensitive fields
+const redacted = "REDACTED"
+
+var levels = map[string]int{
+	string(Debug):     0,
+	string(log.Info):  1,
+	string(log.Warn):  2,
+	string(log.Error): 3,
+	string(log.Fatal): 4,
+}
+
+// NewLogger returns a Logger writing lines to w as logfmt (the default) or JSON which drops lines below
+// level (debug, info, warn or error, info by default). Errors logged without a level are written at the
+// error level. Sensitive fields, like SSNs and activation or verification codes, are always redacted.
+func NewLogger(w io.Writer, format, level string) (log.Logger, error) {
+	min, exists := levels[strings.ToLower(util.Or(level, string(log.Info)))]
+	if !exists || min > levels[string(log.Error)] {
+		return nil, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", level)
+	}
+
+	var writer kitlog.Logger
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "plain", "logfmt":
+		writer = kitlog.NewLogfmtLogger(kitlog.NewSyncWriter(w))
+	case "json":
+		writer = kitlog.NewJSONLogger(kitlog.NewSyncWriter(w))
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected logfmt or json", format)
+	}
+	return log.NewLogger(&filter{next: writer, min: min}), nil
+}
+
+// filter drops lines below the minimum level and redacts sensitive values before writing them
+type filter struct {
+	next kitlog.Logger
+	min  int
+}
+
+func (f *filter) Log(keyvals ...interface{}) error {
+	levelIdx, errored := -1, false
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		switch fmt.Sprint(keyvals[i]) {
+		case "level":
+			levelIdx = i + 1
+		case "errored":
+			errored = fmt.Sprint(keyvals[i+1]) == "true"
+		}
+	}
+
+	level := string(log.Info)
+	if levelIdx >= 0 {
+		level = fmt.Sprint(keyvals[levelIdx])
+	}
+	if errored && level == string(log.Info) {
+		level = string(log.Error)
+		if levelIdx >= 0 {
+			keyvals[levelIdx] = level
+		}
+	}
+	if rank, exists := levels[level]; exists && rank < f.min {
+		return nil
+	}
+
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if sensitiveKey(fmt.Sprint(keyvals[i])) {
+			keyvals[i+1] = redacted
+			continue
+		}
+		if s, ok := keyvals[i+1].(string); ok {
+			keyvals[i+1] = formattedSSN.ReplaceAllString(s, redacted)
+		}
+	}
+	return f.next.Log(keyvals...)
+}
+
+// formattedSSN matches SSNs written as 123-45-6789 in messages and values
+var formattedSSN = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+
+// sensitiveKey returns true for fields whose values are never logged
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	switch key {
+	case "code", "activationcode", "verificationcode":
+		return true
+	}
+	for _, word := range []string{"ssn", "password", "secret", "token"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogging__levels(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "", "warn")
+	require.NoError(t, err)
+
+	logger.With(Debug).Log("debug line")
+	logger.Log("info line")
+	logger.Warn().Log("warn line")
+	logger.LogErrorf("error line")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], "level=warn")
+	require.Contains(t, lines[0], `msg="warn line"`)
+	require.Contains(t, lines[1], "level=error")
+	require.Contains(t, lines[1], `msg="error line"`)
+
+	buf.Reset()
+	logger, err = NewLogger(&buf, "plain", "DEBUG")
+	require.NoError(t, err)
+	logger.With(Debug).Log("debug line")
+	require.Contains(t, buf.String(), "level=debug")
+
+	_, err = NewLogger(&buf, "", "trace")
+	require.Error(t, err)
+	_, err = NewLogger(&buf, "", "fatal")
+	require.Error(t, err)
+	_, err = NewLogger(&buf, "xml", "")
+	require.Error(t, err)
+}
+
+func TestLogging__json(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "JSON", "")
+	require.NoError(t, err)
+
+	logger.Set("customerID", log.String("foo")).LogError(errors.New("problem"))
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	require.Equal(t, "error", line["level"])
+	require.Equal(t, "problem", line["msg"])
+	require.Equal(t, "foo", line["customerID"])
+}
+
+func TestLogging__redacts(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "", "debug")
+	require.NoError(t, err)
+
+	logger.With(log.Fields{
+		"ssn":            log.String("123456789"),
+		"SSNMasked":      log.String("*****6789"),
+		"activationCode": log.String("483920"),
+		"code":           log.String("112233"),
+		"authToken":      log.String("tok"),
+		"customerID":     log.String("foo"),
+	}).Logf("customer gave SSN 987-65-4321")
+
+	out := buf.String()
+	for _, secret := range []string{"123456789", "6789", "483920", "112233", "=tok", "987-65-4321"} {
+		require.NotContains(t, out, secret)
+	}
+	require.Contains(t, out, "customerID=foo")
+	require.Contains(t, out, `msg="customer gave SSN REDACTED"`)
+}
@@ -0,0 +1,239 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package phonenumbers parses phone numbers into their E.164 form. It follows libphonenumber's
+// approach of validating a number against per-country rules, but only carries metadata
+// for the North American Numbering Plan and a handful of common countries.
+package phonenumbers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Type is the kind of line a phone number is assigned to.
+type Type string
+
+const (
+	Unknown   Type = ""
+	FixedLine Type = "fixedline"
+	Mobile    Type = "mobile"
+	VoIP      Type = "voip"
+	TollFree  Type = "tollfree"
+)
+
+// Number is a parsed phone number
+type Number struct {
+	// CountryCode is the ITU calling code, e.g. 1 for the US and Canada.
+	// It's zero when the country code isn't known to this package.
+	CountryCode int
+
+	// National is the number's digits without the country code
+	National string
+
+	// E164 is the number formatted as +<country code><national number>
+	E164 string
+
+	// Valid is true when the number matches the rules of its country
+	Valid bool
+
+	// Type is the inferred kind of line, or Unknown if it can't be determined
+	// (e.g. US numbers can be either fixed line or mobile).
+	Type Type
+}
+
+var (
+	ErrMalformed          = errors.New("malformed phone number")
+	ErrMissingCountryCode = errors.New("phone numbers outside the US and Canada must start with +<country code>")
+)
+
+const (
+	minDigits = 8  // shortest international numbers
+	maxDigits = 15 // longest number E.164 allows
+)
+
+// Parse reads a phone number which may contain common formatting characters (spaces, dots,
+// dashes, slashes and parentheses). Numbers starting with + or 00 are read as international,
+// otherwise they're read as North American numbers.
+//
+// An error is returned when raw isn't a phone number at all. Numbers which look like phone
+// numbers but don't follow their country's rules are returned with Valid set to false.
+func Parse(raw string) (*Number, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, ErrMalformed
+	}
+
+	international := strings.HasPrefix(raw, "+")
+	if international {
+		raw = raw[1:]
+	}
+
+	var digits strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '.' || r == '-' || r == '/' || r == '(' || r == ')':
+			// formatting characters
+		default:
+			return nil, fmt.Errorf("%v: unexpected character %q", ErrMalformed, r)
+		}
+	}
+	number := digits.String()
+
+	if !international && strings.HasPrefix(number, "00") {
+		international, number = true, strings.TrimPrefix(number, "00")
+	}
+	if !international {
+		switch {
+		case len(number) == 10:
+			number = "1" + number
+		case len(number) == 11 && number[0] == '1':
+		default:
+			return nil, ErrMissingCountryCode
+		}
+	}
+	if len(number) < minDigits || len(number) > maxDigits || number[0] == '0' {
+		return nil, fmt.Errorf("%v: +%s", ErrMalformed, number)
+	}
+
+	n := &Number{
+		E164: "+" + number,
+	}
+	for i := 1; i <= 3; i++ {
+		region, ok := regions[number[:i]]
+		if !ok {
+			continue
+		}
+		n.CountryCode = region.countryCode
+		n.National = number[i:]
+		n.Valid = region.valid(n.National)
+		if n.Valid {
+			n.Type = region.lineType(n.National)
+		}
+		break
+	}
+	return n, nil
+}
+
+type region struct {
+	countryCode   int
+	lengths       []int
+	validate      func(national string) bool
+	mobile        []string
+	fixedLine     []string
+	voip          []string
+	tollFree      []string
+	defaultToType Type
+}
+
+func (r region) valid(national string) bool {
+	found := false
+	for _, l := range r.lengths {
+		if len(national) == l {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	if r.validate != nil {
+		return r.validate(national)
+	}
+	return true
+}
+
+func (r region) lineType(national string) Type {
+	hasPrefix := func(prefixes []string) bool {
+		for _, p := range prefixes {
+			if strings.HasPrefix(national, p) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case hasPrefix(r.tollFree):
+		return TollFree
+	case hasPrefix(r.voip):
+		return VoIP
+	case hasPrefix(r.mobile):
+		return Mobile
+	case hasPrefix(r.fixedLine):
+		return FixedLine
+	}
+	return r.defaultToType
+}
+
+// validNANP checks the area code (NPA) and exchange (NXX) of a North American number.
+// Neither can start with 0 or 1 or be an N11 service code (e.g. 411 or 911).
+func validNANP(national string) bool {
+	valid := func(code string) bool {
+		return code[0] >= '2' && code[0] <= '9' && code[1:] != "11"
+	}
+	return valid(national[:3]) && valid(national[3:6])
+}
+
+var regions = map[string]region{
+	// North American Numbering Plan: mobile and fixed line numbers share area codes
+	"1": {
+		countryCode: 1,
+		lengths:     []int{10},
+		validate:    validNANP,
+		tollFree:    []string{"800", "833", "844", "855", "866", "877", "888"},
+	},
+	// France
+	"33": {
+		countryCode: 33,
+		lengths:     []int{9},
+		mobile:      []string{"6", "7"},
+		fixedLine:   []string{"1", "2", "3", "4", "5"},
+		voip:        []string{"9"},
+		tollFree:    []string{"80"},
+	},
+	// United Kingdom
+	"44": {
+		countryCode: 44,
+		lengths:     []int{9, 10},
+		mobile:      []string{"71", "72", "73", "74", "75", "77", "78", "79"},
+		fixedLine:   []string{"1", "2"},
+		voip:        []string{"56"},
+		tollFree:    []string{"800", "808"},
+	},
+	// Germany
+	"49": {
+		countryCode: 49,
+		lengths:     []int{6, 7, 8, 9, 10, 11, 12, 13},
+		mobile:      []string{"15", "16", "17"},
+		tollFree:    []string{"800"},
+		// Everything else is a geographic number
+		defaultToType: FixedLine,
+	},
+	// Mexico: mobile and fixed line numbers share prefixes
+	"52": {
+		countryCode: 52,
+		lengths:     []int{10},
+		tollFree:    []string{"800"},
+	},
+	// Australia
+	"61": {
+		countryCode: 61,
+		lengths:     []int{9, 10},
+		mobile:      []string{"4"},
+		fixedLine:   []string{"2", "3", "7", "8"},
+		tollFree:    []string{"180"},
+	},
+	// India
+	"91": {
+		countryCode: 91,
+		lengths:     []int{10},
+		mobile:      []string{"6", "7", "8", "9"},
+		tollFree:    []string{"1800"},
+		// Everything else is a geographic number
+		defaultToType: FixedLine,
+	},
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package phonenumbers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		raw   string
+		e164  string
+		valid bool
+		kind  Type
+	}{
+		{"+1.818.555.1212", "+18185551212", true, Unknown},
+		{"(818) 555-1212", "+18185551212", true, Unknown},
+		{"1-818-555-1212", "+18185551212", true, Unknown},
+		{"800 555 1212", "+18005551212", true, TollFree},
+		{"123.456.7890", "+11234567890", false, Unknown}, // area codes can't start with 1
+		{"818.911.1212", "+18189111212", false, Unknown}, // N11 exchange
+		{"+44 7911 123456", "+447911123456", true, Mobile},
+		{"+44 20 7946 0958", "+442079460958", true, FixedLine},
+		{"0033 6 12 34 56 78", "+33612345678", true, Mobile},
+		{"+33 1 23 45 67 89", "+33123456789", true, FixedLine},
+		{"+49 1512 3456789", "+4915123456789", true, Mobile},
+		{"+61 4 1234 5678", "+61412345678", true, Mobile},
+		{"+91 98765 43210", "+919876543210", true, Mobile},
+		{"+44 7911 12", "+44791112", false, Unknown}, // too short, but still well formed
+		{"+886 912 345 678", "+886912345678", false, Unknown},
+	}
+	for _, tc := range cases {
+		n, err := Parse(tc.raw)
+		require.NoError(t, err, tc.raw)
+		require.Equal(t, tc.e164, n.E164, tc.raw)
+		require.Equal(t, tc.valid, n.Valid, tc.raw)
+		require.Equal(t, tc.kind, n.Type, tc.raw)
+	}
+}
+
+func TestParse__malformed(t *testing.T) {
+	cases := []string{
+		"",
+		"   ",
+		"call me",
+		"555-CALL-NOW",
+		"555.1212",                 // no area code
+		"+1 818 555 1212 99999999", // too long
+		"+0 818 555 1212",
+	}
+	for _, raw := range cases {
+		_, err := Parse(raw)
+		require.Error(t, err, raw)
+	}
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -37,7 +38,7 @@ func TestRehashAccountNumber(t *testing.T) {
 		err := req.Validate()
 		require.NoError(t, err)
 
-		acc, err := repo.CreateCustomerAccount("1", "1", req)
+		acc, err := repo.CreateCustomerAccount(context.Background(), "1", "1", req)
 		require.NoError(t, err)
 		createdAccountIDs = append(createdAccountIDs, acc.AccountID)
 	}
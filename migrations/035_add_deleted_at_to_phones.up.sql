@@ -0,0 +1 @@
+ALTER TABLE phones ADD COLUMN deleted_at datetime;
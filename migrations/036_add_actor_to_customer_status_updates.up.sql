@@ -0,0 +1 @@
+ALTER TABLE customer_status_updates ADD COLUMN actor varchar(40);
@@ -0,0 +1,10 @@
+CREATE TABLE if not exists webhook_attempts (
+    event_id varchar(40),
+    event_type varchar(40),
+    customer_id varchar(40),
+    attempt integer,
+    status_code integer,
+    error varchar(512),
+    attempted_at datetime
+);
+CREATE INDEX webhook_attempts_customer_id ON webhook_attempts (customer_id);
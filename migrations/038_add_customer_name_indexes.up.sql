@@ -0,0 +1,3 @@
+CREATE INDEX customers_organization_last_name ON customers (organization, last_name);
+CREATE INDEX customers_organization_first_name ON customers (organization, first_name);
+CREATE INDEX customers_organization_nick_name ON customers (organization, nick_name);
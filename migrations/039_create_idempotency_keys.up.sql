@@ -0,0 +1,8 @@
+create table idempotency_keys(
+  idempotency_key varchar(255) not null,
+  organization varchar(40) not null,
+  customer_id varchar(40) not null,
+  created_at datetime not null,
+  completed_at datetime,
+  constraint idempotency_keys_unique_to_organization unique (idempotency_key, organization)
+);
@@ -0,0 +1 @@
+alter table representatives add column ownership_percentage double precision;
@@ -0,0 +1,9 @@
+create table representative_ofac_searches(
+  representative_id varchar(40),
+  entity_id varchar(40),
+  sdn_name varchar(40),
+  sdn_type integer,
+  percentage_match double precision (5, 2),
+  blocked boolean,
+  created_at datetime
+);
@@ -0,0 +1,9 @@
+create table customer_purges(
+  customer_id varchar(40) not null,
+  organization varchar(40) not null,
+  purged_by varchar(40) not null,
+  request_id varchar(40),
+  documents_deleted integer,
+  purged_at datetime not null,
+  primary key (customer_id)
+);
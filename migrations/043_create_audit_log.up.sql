@@ -0,0 +1,11 @@
+create table audit_log(
+  audit_id varchar(40) primary key,
+  actor varchar(40),
+  action varchar(120) not null,
+  customer_id varchar(40) not null,
+  organization varchar(40) not null,
+  request_id varchar(40),
+  diff text,
+  created_at datetime not null
+);
+create index audit_log_customer_id_created_at on audit_log (customer_id, created_at);
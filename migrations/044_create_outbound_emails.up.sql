@@ -0,0 +1,27 @@
+create table outbound_emails(
+  email_id varchar(40) primary key,
+  customer_id varchar(40),
+  email_type varchar(40) not null,
+  recipient varchar(255) not null,
+  subject varchar(255) not null,
+  body text not null,
+  attempts integer not null default 0,
+  last_error varchar(512),
+  next_attempt_at datetime not null,
+  sent_at datetime,
+  dead_lettered_at datetime,
+  created_at datetime not null
+);
+create index outbound_emails_pending on outbound_emails (sent_at, dead_lettered_at, next_attempt_at);
+
+create table email_activation_codes(
+  code_id varchar(40) primary key,
+  customer_id varchar(40) not null,
+  organization varchar(40) not null,
+  email varchar(255) not null,
+  code_hash varchar(64) not null,
+  expires_at datetime not null,
+  activated_at datetime,
+  created_at datetime not null
+);
+create index email_activation_codes_customer_id on email_activation_codes (customer_id);
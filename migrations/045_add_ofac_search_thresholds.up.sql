@@ -0,0 +1,6 @@
+alter table customer_ofac_searches add column review_required boolean default false;
+alter table customer_ofac_searches add column match_threshold double precision;
+alter table customer_ofac_searches add column review_threshold double precision;
+alter table representative_ofac_searches add column review_required boolean default false;
+alter table representative_ofac_searches add column match_threshold double precision;
+alter table representative_ofac_searches add column review_threshold double precision;
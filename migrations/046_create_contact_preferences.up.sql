@@ -0,0 +1,12 @@
+create table customer_contact_preferences(
+  customer_id varchar(40) primary key,
+  email_opt_in boolean not null default false,
+  email_opted_in_at datetime,
+  sms_opt_in boolean not null default false,
+  sms_opted_in_at datetime,
+  marketing_opt_in boolean not null default false,
+  marketing_opted_in_at datetime,
+  updated_at datetime not null
+);
+
+alter table outbound_emails add column skipped_at datetime;
@@ -0,0 +1 @@
+alter table customers add column version integer not null default 1;
@@ -0,0 +1,11 @@
+create table ofac_rescreen_runs(
+  run_id varchar(40) primary key not null,
+  last_customer_id varchar(40) not null default '',
+  screened integer not null default 0,
+  flagged integer not null default 0,
+  rejected integer not null default 0,
+  failed integer not null default 0,
+  started_at datetime not null,
+  updated_at datetime not null,
+  completed_at datetime
+);
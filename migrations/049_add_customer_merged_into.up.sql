@@ -0,0 +1 @@
+alter table customers add column merged_into varchar(40);
@@ -0,0 +1,12 @@
+create table phone_verifications(
+  verification_id varchar(40) primary key,
+  customer_id varchar(40) not null,
+  organization varchar(40) not null,
+  phone_number varchar(40) not null,
+  code_hash varchar(64) not null,
+  attempts integer not null default 0,
+  expires_at datetime not null,
+  verified_at datetime,
+  created_at datetime not null
+);
+create index phone_verifications_phone on phone_verifications (customer_id, phone_number);
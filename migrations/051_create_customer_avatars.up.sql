@@ -0,0 +1,8 @@
+create table customer_avatars(
+  customer_id varchar(40) primary key,
+  content_type varchar(40) not null,
+  etag varchar(80) not null,
+  width integer not null,
+  height integer not null,
+  uploaded_at datetime not null
+);
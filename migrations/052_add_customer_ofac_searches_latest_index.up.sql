@@ -0,0 +1 @@
+create index customer_ofac_searches_latest on customer_ofac_searches (customer_id, created_at);
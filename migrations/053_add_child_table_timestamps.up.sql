@@ -0,0 +1,26 @@
+alter table phones add column created_at datetime;
+alter table phones add column last_modified datetime;
+alter table addresses add column created_at datetime;
+alter table addresses add column last_modified datetime;
+alter table customer_metadata add column created_at datetime;
+alter table customer_metadata add column last_modified datetime;
+
+update phones set created_at = coalesce(
+  (select customers.created_at from customers where customers.customer_id = phones.owner_id),
+  (select representatives.created_at from representatives where representatives.representative_id = phones.owner_id),
+  current_timestamp
+) where created_at is null;
+update phones set last_modified = coalesce(deleted_at, created_at) where last_modified is null;
+
+update addresses set created_at = coalesce(
+  (select customers.created_at from customers where customers.customer_id = addresses.owner_id),
+  (select representatives.created_at from representatives where representatives.representative_id = addresses.owner_id),
+  current_timestamp
+) where created_at is null;
+update addresses set last_modified = coalesce(deleted_at, created_at) where last_modified is null;
+
+update customer_metadata set created_at = coalesce(
+  (select customers.created_at from customers where customers.customer_id = customer_metadata.customer_id),
+  current_timestamp
+) where created_at is null;
+update customer_metadata set last_modified = created_at where last_modified is null;
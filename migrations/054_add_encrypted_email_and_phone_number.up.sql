@@ -0,0 +1,4 @@
+ALTER TABLE customers ADD COLUMN encrypted_email VARCHAR(512);
+CREATE INDEX customers_encrypted_email ON customers (encrypted_email);
+
+ALTER TABLE phones ADD COLUMN encrypted_number VARCHAR(255);
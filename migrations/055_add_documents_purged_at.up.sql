@@ -0,0 +1,2 @@
+alter table documents add column purged_at datetime;
+create index documents_deleted_at on documents (deleted_at);
@@ -0,0 +1,2 @@
+update addresses set country = 'US' where country is null or upper(country) in ('', 'US', 'USA');
+update addresses set country = 'CA' where upper(country) = 'CAN';
@@ -0,0 +1 @@
+alter table addresses modify state varchar(100), modify postal_code varchar(16);
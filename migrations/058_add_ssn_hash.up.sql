@@ -0,0 +1,2 @@
+ALTER TABLE ssn ADD COLUMN ssn_hash VARCHAR(64);
+CREATE INDEX ssn_ssn_hash ON ssn (ssn_hash);
@@ -0,0 +1,3 @@
+alter table documents add column expires_at datetime;
+alter table documents add column expiry_notified_at datetime;
+create index documents_expires_at on documents (expires_at);
@@ -0,0 +1,23 @@
+create table customers_emails(
+  email_id varchar(40) primary key,
+  customer_id varchar(40) not null,
+  email varchar(255),
+  encrypted_email varchar(512),
+  type varchar(20) not null,
+  is_primary boolean not null default false,
+  verified boolean not null default false,
+  verified_at datetime,
+  created_at datetime not null,
+  last_modified datetime not null,
+  deleted_at datetime
+);
+create index customers_emails_customer_id on customers_emails (customer_id);
+
+insert into customers_emails (email_id, customer_id, email, encrypted_email, type, is_primary, verified, verified_at, created_at, last_modified, deleted_at)
+select customer_id, customer_id, email, encrypted_email, 'personal', true,
+  exists (select 1 from email_activation_codes where email_activation_codes.customer_id = customers.customer_id and email_activation_codes.activated_at is not null),
+  (select max(activated_at) from email_activation_codes where email_activation_codes.customer_id = customers.customer_id),
+  coalesce(created_at, current_timestamp), coalesce(last_modified, current_timestamp), deleted_at
+from customers where email <> '' or encrypted_email is not null;
+
+alter table email_activation_codes add column email_id varchar(40);
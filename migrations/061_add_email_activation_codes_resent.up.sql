@@ -0,0 +1 @@
+alter table email_activation_codes add column resent boolean not null default false;
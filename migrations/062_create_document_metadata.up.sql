@@ -0,0 +1,8 @@
+create table document_metadata(
+  document_id varchar(40) not null,
+  meta_key varchar(40) not null,
+  meta_value varchar(512) not null,
+  created_at datetime not null,
+  last_modified datetime not null,
+  constraint document_meta_key unique (document_id, meta_key)
+);
@@ -0,0 +1,10 @@
+alter table representatives add column status varchar(20) not null default 'Active';
+
+create table representative_status_updates(
+  representative_id varchar(40),
+  customer_id varchar(40),
+  future_status varchar(20),
+  comment varchar(512),
+  actor varchar(40),
+  changed_at datetime
+);
@@ -0,0 +1,24 @@
+alter table disclaimers add column version integer not null default 1;
+alter table disclaimers add column last_modified datetime;
+
+create table disclaimer_versions(
+  disclaimer_id varchar(40) not null,
+  version integer not null,
+  text text,
+  created_at datetime,
+  constraint disclaimer_versions_unique unique (disclaimer_id, version)
+);
+insert into disclaimer_versions (disclaimer_id, version, text, created_at)
+select disclaimer_id, 1, text, created_at from disclaimers;
+
+create table disclaimer_version_acceptances(
+  disclaimer_id varchar(40),
+  version integer not null default 1,
+  customer_id varchar(40),
+  accepted_at datetime,
+  constraint disclaimer_acceptance_unique_to_version unique (disclaimer_id, version, customer_id)
+);
+insert into disclaimer_version_acceptances (disclaimer_id, version, customer_id, accepted_at)
+select disclaimer_id, 1, customer_id, accepted_at from disclaimer_acceptances;
+drop table disclaimer_acceptances;
+alter table disclaimer_version_acceptances rename to disclaimer_acceptances;
@@ -0,0 +1,18 @@
+create table tags(
+  tag_id varchar(40) primary key,
+  organization varchar(40) not null,
+  name varchar(40) not null,
+  description varchar(255) not null default '',
+  created_at datetime not null,
+  constraint tags_organization_name unique (organization, name)
+);
+
+create table customer_tags(
+  customer_id varchar(40) not null,
+  tag_id varchar(40) not null,
+  actor varchar(100) not null default '',
+  created_at datetime not null,
+  constraint customer_tags_customer_tag unique (customer_id, tag_id)
+);
+
+create index customer_tags_tag_id on customer_tags (tag_id, customer_id);
@@ -0,0 +1 @@
+create index customer_status_updates_customer_id on customer_status_updates (customer_id, changed_at);
@@ -0,0 +1,21 @@
+create table document_uploads(
+  upload_id varchar(40) primary key,
+  customer_id varchar(40) not null,
+  organization varchar(40) not null,
+  type varchar(40) not null,
+  expires_at datetime,
+  size bigint not null default 0,
+  created_at datetime not null,
+  updated_at datetime not null
+);
+
+create index document_uploads_updated_at on document_uploads (updated_at);
+
+create table document_upload_chunks(
+  upload_id varchar(40) not null,
+  byte_offset bigint not null,
+  size bigint not null,
+  blob_key varchar(255) not null,
+  created_at datetime not null,
+  constraint document_upload_chunks_offset unique (upload_id, byte_offset)
+);
@@ -0,0 +1,15 @@
+create table document_scans(
+  scan_id varchar(40) primary key,
+  customer_id varchar(40) not null,
+  organization varchar(40) not null,
+  document_id varchar(40) not null,
+  type varchar(40) not null,
+  content_type varchar(255) not null,
+  size bigint not null,
+  result varchar(10) not null,
+  signature varchar(255) not null default '',
+  error varchar(512) not null default '',
+  scanned_at datetime not null
+);
+
+create index document_scans_customer_id on document_scans (customer_id, scanned_at);
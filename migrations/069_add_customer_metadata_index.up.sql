@@ -0,0 +1 @@
+create index customer_metadata_customer_id on customer_metadata (customer_id, meta_key);
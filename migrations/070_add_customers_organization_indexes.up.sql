@@ -0,0 +1,2 @@
+create index customers_organization_status on customers (organization, status);
+create index customers_organization_created_at on customers (organization, created_at);
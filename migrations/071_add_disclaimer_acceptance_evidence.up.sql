@@ -0,0 +1,2 @@
+alter table disclaimer_acceptances add column ip_address varchar(45);
+alter table disclaimer_acceptances add column user_agent varchar(512);
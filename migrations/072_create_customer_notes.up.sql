@@ -0,0 +1,10 @@
+create table customer_notes(
+  note_id varchar(40) primary key,
+  customer_id varchar(40) not null,
+  organization varchar(40) not null,
+  author varchar(40) not null,
+  body text not null,
+  created_at datetime not null,
+  deleted_at datetime
+);
+create index customer_notes_customer_id_created_at on customer_notes (customer_id, created_at);
@@ -0,0 +1,11 @@
+create table customer_relationships(
+  relationship_id varchar(40) primary key,
+  organization varchar(40) not null,
+  customer_id varchar(40) not null,
+  related_customer_id varchar(40) not null,
+  relationship_type varchar(40) not null,
+  actor varchar(40) not null,
+  created_at datetime not null,
+  unique (customer_id, related_customer_id, relationship_type)
+);
+create index customer_relationships_related_customer_id on customer_relationships (related_customer_id);
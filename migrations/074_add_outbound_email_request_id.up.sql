@@ -0,0 +1 @@
+alter table outbound_emails add column request_id varchar(64);
@@ -0,0 +1 @@
+alter table outbound_emails add column html_body text;
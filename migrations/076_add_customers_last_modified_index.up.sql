@@ -0,0 +1 @@
+create index customers_organization_last_modified on customers (organization, last_modified);
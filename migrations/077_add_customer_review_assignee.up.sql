@@ -0,0 +1,2 @@
+alter table customers add column review_assignee varchar(255);
+alter table customers add column review_assigned_at datetime;
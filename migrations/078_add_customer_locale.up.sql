@@ -0,0 +1 @@
+alter table customers add column locale varchar(35);
@@ -36,7 +36,7 @@ func (s *AccountOfacSearcher) StoreAccountOFACSearch(account *client.Account, re
 	if sdn == nil {
 		return nil
 	}
-	err = s.Repo.saveAccountOFACSearch(account.AccountID, &client.OfacSearch{
+	err = s.Repo.saveAccountOFACSearch(ctx, account.AccountID, &client.OfacSearch{
 		EntityID:  sdn.EntityID,
 		SdnName:   sdn.SdnName,
 		SdnType:   sdn.SdnType,
@@ -5,6 +5,7 @@
 package accounts
 
 import (
+	"context"
 	"github.com/moov-io/customers/pkg/client"
 )
 
@@ -16,7 +17,7 @@ type mockRepository struct {
 	Err           error
 }
 
-func (r *mockRepository) getCustomerAccount(customerID, accountID string) (*client.Account, error) {
+func (r *mockRepository) getCustomerAccount(ctx context.Context, customerID, accountID string) (*client.Account, error) {
 	if r.Err != nil {
 		return nil, r.Err
 	}
@@ -26,21 +27,21 @@ func (r *mockRepository) getCustomerAccount(customerID, accountID string) (*clie
 	return nil, nil
 }
 
-func (r *mockRepository) GetCustomerAccountsByIDs(accountIDs []string) ([]*client.Account, error) {
+func (r *mockRepository) GetCustomerAccountsByIDs(ctx context.Context, accountIDs []string) ([]*client.Account, error) {
 	if r.Err != nil {
 		return nil, r.Err
 	}
 	return r.Accounts, nil
 }
 
-func (r *mockRepository) getAccounts(customerID string, organization string) ([]*client.Account, error) {
+func (r *mockRepository) getAccounts(ctx context.Context, customerID string, organization string) ([]*client.Account, error) {
 	if r.Err != nil {
 		return nil, r.Err
 	}
 	return r.Accounts, nil
 }
 
-func (r *mockRepository) CreateCustomerAccount(customerID, userID string, req *CreateAccountRequest) (*client.Account, error) {
+func (r *mockRepository) CreateCustomerAccount(ctx context.Context, customerID, userID string, req *CreateAccountRequest) (*client.Account, error) {
 	if r.Err != nil {
 		return nil, r.Err
 	}
@@ -50,25 +51,25 @@ func (r *mockRepository) CreateCustomerAccount(customerID, userID string, req *C
 	return nil, nil
 }
 
-func (r *mockRepository) deactivateCustomerAccount(accountID string) error {
+func (r *mockRepository) deactivateCustomerAccount(ctx context.Context, accountID string) error {
 	return r.Err
 }
 
-func (r *mockRepository) updateAccountStatus(accountID string, status client.AccountStatus) error {
+func (r *mockRepository) updateAccountStatus(ctx context.Context, accountID string, status client.AccountStatus) error {
 	return r.Err
 }
 
-func (r *mockRepository) getEncryptedAccountNumber(organization, customerID, accountID string) (string, error) {
+func (r *mockRepository) getEncryptedAccountNumber(ctx context.Context, organization, customerID, accountID string) (string, error) {
 	if r.Err != nil {
 		return "", r.Err
 	}
 	return r.AccountNumber, nil
 }
 
-func (r *mockRepository) getLatestAccountOFACSearch(accountID string) (*client.OfacSearch, error) {
+func (r *mockRepository) getLatestAccountOFACSearch(ctx context.Context, accountID string) (*client.OfacSearch, error) {
 	panic("implement me")
 }
 
-func (r *mockRepository) saveAccountOFACSearch(id string, result *client.OfacSearch) error {
+func (r *mockRepository) saveAccountOFACSearch(ctx context.Context, id string, result *client.OfacSearch) error {
 	return nil
 }
@@ -5,6 +5,7 @@
 package accounts
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -13,33 +14,38 @@ import (
 
 	"github.com/moov-io/base"
 
+	customersdb "github.com/moov-io/customers/internal/database"
 	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
 
 	"github.com/moov-io/base/log"
 )
 
 type Repository interface {
-	getCustomerAccount(customerID, accountID string) (*client.Account, error)
-	GetCustomerAccountsByIDs(accountIDs []string) ([]*client.Account, error)
-	getAccounts(customerID string, organization string) ([]*client.Account, error)
+	getCustomerAccount(ctx context.Context, customerID, accountID string) (*client.Account, error)
+	GetCustomerAccountsByIDs(ctx context.Context, accountIDs []string) ([]*client.Account, error)
+	getAccounts(ctx context.Context, customerID string, organization string) ([]*client.Account, error)
 
-	CreateCustomerAccount(customerID, userID string, req *CreateAccountRequest) (*client.Account, error)
-	deactivateCustomerAccount(accountID string) error
+	CreateCustomerAccount(ctx context.Context, customerID, userID string, req *CreateAccountRequest) (*client.Account, error)
+	deactivateCustomerAccount(ctx context.Context, accountID string) error
 
-	updateAccountStatus(accountID string, status client.AccountStatus) error
+	updateAccountStatus(ctx context.Context, accountID string, status client.AccountStatus) error
 
-	getEncryptedAccountNumber(organization, customerID, accountID string) (string, error)
+	getEncryptedAccountNumber(ctx context.Context, organization, customerID, accountID string) (string, error)
 
-	getLatestAccountOFACSearch(accountID string) (*client.OfacSearch, error)
-	saveAccountOFACSearch(id string, result *client.OfacSearch) error
+	getLatestAccountOFACSearch(ctx context.Context, accountID string) (*client.OfacSearch, error)
+	saveAccountOFACSearch(ctx context.Context, id string, result *client.OfacSearch) error
 }
 
-func (r *sqlAccountRepository) GetCustomerAccountsByIDs(accountIDs []string) ([]*client.Account, error) {
+func (r *sqlAccountRepository) GetCustomerAccountsByIDs(ctx context.Context, accountIDs []string) ([]*client.Account, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	query := fmt.Sprintf(
 		`select account_id, customer_id, holder_name, masked_account_number, routing_number, status, type from accounts where account_id in (?%s) and deleted_at is null;`,
 		strings.Repeat(",?", len(accountIDs)-1),
 	)
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -48,7 +54,7 @@ func (r *sqlAccountRepository) GetCustomerAccountsByIDs(accountIDs []string) ([]
 	for _, id := range accountIDs {
 		args = append(args, id)
 	}
-	rows, err := stmt.Query(args...)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -88,16 +94,19 @@ type sqlAccountRepository struct {
 	logger log.Logger
 }
 
-func (r *sqlAccountRepository) getCustomerAccount(customerID, accountID string) (*client.Account, error) {
+func (r *sqlAccountRepository) getCustomerAccount(ctx context.Context, customerID, accountID string) (*client.Account, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	query := `select account_id, customer_id, holder_name, masked_account_number, routing_number, status, type from accounts where customer_id = ? and account_id = ? and deleted_at is null limit 1;`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
 	var a client.Account
-	row := stmt.QueryRow(customerID, accountID)
+	row := stmt.QueryRowContext(ctx, customerID, accountID)
 	if err := row.Scan(
 		&a.AccountID,
 		&a.CustomerID,
@@ -108,22 +117,25 @@ func (r *sqlAccountRepository) getCustomerAccount(customerID, accountID string)
 		&a.Type,
 	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("account: %s for customer: %s was not found", accountID, customerID)
+			return nil, route.NotFoundError(fmt.Errorf("account: %s for customer: %s was not found", accountID, customerID))
 		}
 		return nil, err
 	}
 	return &a, nil
 }
 
-func (r *sqlAccountRepository) getAccounts(customerID string, organization string) ([]*client.Account, error) {
+func (r *sqlAccountRepository) getAccounts(ctx context.Context, customerID string, organization string) ([]*client.Account, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	query := `select a.account_id from accounts as a left outer join customers as c on a.customer_id = c.customer_id where a.customer_id = ? and c.organization = ? and a.deleted_at is null;`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query(customerID, organization)
+	rows, err := stmt.QueryContext(ctx, customerID, organization)
 	if err != nil {
 		return nil, err
 	}
@@ -135,7 +147,7 @@ func (r *sqlAccountRepository) getAccounts(customerID string, organization strin
 		if err := rows.Scan(&accountID); err != nil {
 			return nil, err
 		}
-		acct, err := r.getCustomerAccount(customerID, accountID)
+		acct, err := r.getCustomerAccount(ctx, customerID, accountID)
 		if err != nil {
 			return nil, fmt.Errorf("problem reading accountID=%s error=%v", accountID, err)
 		}
@@ -149,7 +161,10 @@ func (r *sqlAccountRepository) getAccounts(customerID string, organization strin
 	return out, nil
 }
 
-func (r *sqlAccountRepository) CreateCustomerAccount(customerID, userID string, req *CreateAccountRequest) (*client.Account, error) {
+func (r *sqlAccountRepository) CreateCustomerAccount(ctx context.Context, customerID, userID string, req *CreateAccountRequest) (*client.Account, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	// TODO: remove userID
 	account := &client.Account{
 		CustomerID:          customerID,
@@ -165,14 +180,14 @@ func (r *sqlAccountRepository) CreateCustomerAccount(customerID, userID string,
   encrypted_account_number, sha256_account_number, masked_account_number,
   routing_number, status, type, created_at
 ) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
 	// TODO: remove userID
-	_, err = stmt.Exec(
+	_, err = stmt.ExecContext(ctx,
 		account.AccountID,
 		account.CustomerID,
 		userID,
@@ -191,47 +206,56 @@ func (r *sqlAccountRepository) CreateCustomerAccount(customerID, userID string,
 	return account, nil
 }
 
-func (r *sqlAccountRepository) deactivateCustomerAccount(accountID string) error {
+func (r *sqlAccountRepository) deactivateCustomerAccount(ctx context.Context, accountID string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	query := `update accounts set deleted_at = ? where account_id = ? and deleted_at is null;`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(time.Now(), accountID)
+	_, err = stmt.ExecContext(ctx, time.Now(), accountID)
 	if err == sql.ErrNoRows {
 		return nil
 	}
 	return err
 }
 
-func (r *sqlAccountRepository) updateAccountStatus(accountID string, status client.AccountStatus) error {
+func (r *sqlAccountRepository) updateAccountStatus(ctx context.Context, accountID string, status client.AccountStatus) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	query := `update accounts set status = ? where account_id = ? and deleted_at is null;`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(status, accountID)
+	_, err = stmt.ExecContext(ctx, status, accountID)
 	return err
 }
 
-func (r *sqlAccountRepository) getEncryptedAccountNumber(organization, customerID, accountID string) (string, error) {
+func (r *sqlAccountRepository) getEncryptedAccountNumber(ctx context.Context, organization, customerID, accountID string) (string, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	query := `select encrypted_account_number
 from accounts as a
 inner join customers as c on c.customer_id = a.customer_id
 where a.customer_id = ? and a.account_id = ? and c.organization = ? and a.deleted_at is null
 limit 1;`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return "", err
 	}
 	defer stmt.Close()
 
 	var encrypted string
-	if err := stmt.QueryRow(customerID, accountID, organization).Scan(&encrypted); err != nil {
+	if err := stmt.QueryRowContext(ctx, customerID, accountID, organization).Scan(&encrypted); err != nil {
 		if err == sql.ErrNoRows {
 			return "", nil
 		}
@@ -240,15 +264,18 @@ limit 1;`
 	return encrypted, nil
 }
 
-func (r *sqlAccountRepository) getLatestAccountOFACSearch(accountID string) (*client.OfacSearch, error) {
+func (r *sqlAccountRepository) getLatestAccountOFACSearch(ctx context.Context, accountID string) (*client.OfacSearch, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	query := `select entity_id, sdn_name, sdn_type, percentage_match, created_at from account_ofac_searches where account_id = ? order by created_at desc limit 1;`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("getLatestAccountOFACSearch: prepare: %v", err)
 	}
 	defer stmt.Close()
 
-	row := stmt.QueryRow(accountID)
+	row := stmt.QueryRowContext(ctx, accountID)
 	var res client.OfacSearch
 	if err := row.Scan(&res.EntityID, &res.SdnType, &res.SdnType, &res.Match, &res.CreatedAt); err != nil {
 		if err == sql.ErrNoRows {
@@ -259,9 +286,12 @@ func (r *sqlAccountRepository) getLatestAccountOFACSearch(accountID string) (*cl
 	return &res, nil
 }
 
-func (r *sqlAccountRepository) saveAccountOFACSearch(accountID string, result *client.OfacSearch) error {
+func (r *sqlAccountRepository) saveAccountOFACSearch(ctx context.Context, accountID string, result *client.OfacSearch) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	query := `insert into account_ofac_searches (account_ofac_search_id, account_id, entity_id, sdn_name, sdn_type, percentage_match, created_at) values (?, ?, ?, ?, ?, ?, ?);`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("saveAccountOFACSearch: prepare: %v", err)
 	}
@@ -271,7 +301,7 @@ func (r *sqlAccountRepository) saveAccountOFACSearch(accountID string, result *c
 		result.CreatedAt = time.Now()
 	}
 
-	if _, err := stmt.Exec(base.ID(), accountID, result.EntityID, result.SdnName, result.SdnType, result.Match, result.CreatedAt); err != nil {
+	if _, err := stmt.ExecContext(ctx, base.ID(), accountID, result.EntityID, result.SdnName, result.SdnType, result.Match, result.CreatedAt); err != nil {
 		return fmt.Errorf("saveAccountOFACSearch: exec: %v", err)
 	}
 	return nil
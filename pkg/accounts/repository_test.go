@@ -5,6 +5,7 @@
 package accounts
 
 import (
+	"context"
 	"testing"
 
 	"github.com/moov-io/base"
@@ -49,21 +50,21 @@ func TestRepository(t *testing.T) {
 		LastName:   "doe",
 		Type:       client.CUSTOMERTYPE_INDIVIDUAL,
 	}
-	err := customerRepo.CreateCustomer(customer, organization)
+	err := customerRepo.CreateCustomer(context.Background(), customer, organization)
 	require.NoError(t, err)
 
 	// look for account that does not exist
-	_, err = accountRepo.getCustomerAccount(customer.CustomerID, "xxx")
+	_, err = accountRepo.getCustomerAccount(context.Background(), customer.CustomerID, "xxx")
 	require.Error(t, err)
 
 	// initial read, find no accounts
-	accounts, err := accountRepo.getAccounts(customer.CustomerID, organization)
+	accounts, err := accountRepo.getAccounts(context.Background(), customer.CustomerID, organization)
 	if len(accounts) != 0 || err != nil {
 		t.Fatalf("got accounts=%#v error=%v", accounts, err)
 	}
 
 	// create account
-	acct, err := accountRepo.CreateCustomerAccount(customer.CustomerID, userID, &CreateAccountRequest{
+	acct, err := accountRepo.CreateCustomerAccount(context.Background(), customer.CustomerID, userID, &CreateAccountRequest{
 		AccountNumber: "123",
 		RoutingNumber: "987654320",
 		Type:          client.ACCOUNTTYPE_CHECKING,
@@ -73,7 +74,7 @@ func TestRepository(t *testing.T) {
 	}
 
 	// read after creating
-	accounts, err = accountRepo.getAccounts(customer.CustomerID, organization)
+	accounts, err = accountRepo.getAccounts(context.Background(), customer.CustomerID, organization)
 	if len(accounts) != 1 || err != nil {
 		t.Fatalf("got accounts=%#v error=%v", accounts, err)
 	}
@@ -82,10 +83,10 @@ func TestRepository(t *testing.T) {
 	}
 
 	// delete, expect no accounts
-	if err := accountRepo.deactivateCustomerAccount(acct.AccountID); err != nil {
+	if err := accountRepo.deactivateCustomerAccount(context.Background(), acct.AccountID); err != nil {
 		t.Fatal(err)
 	}
-	accounts, err = accountRepo.getAccounts(customer.CustomerID, organization)
+	accounts, err = accountRepo.getAccounts(context.Background(), customer.CustomerID, organization)
 	if len(accounts) != 0 || err != nil {
 		t.Fatalf("got accounts=%#v error=%v", accounts, err)
 	}
@@ -107,7 +108,7 @@ func TestRepository__getEncryptedAccountNumber(t *testing.T) {
 	if err := req.Disfigure(keeper, "salt"); err != nil {
 		t.Fatal(err)
 	}
-	acct, err := repo.CreateCustomerAccount(customerID, userID, req)
+	acct, err := repo.CreateCustomerAccount(context.Background(), customerID, userID, req)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -120,13 +121,13 @@ func TestRepository__getEncryptedAccountNumber(t *testing.T) {
 		LastName:   "doe",
 		Type:       client.CUSTOMERTYPE_INDIVIDUAL,
 	}
-	custErr := customerRepo.CreateCustomer(cust, organization)
+	custErr := customerRepo.CreateCustomer(context.Background(), cust, organization)
 	if custErr != nil {
 		t.Fatal(custErr)
 	}
 
 	// read encrypted account number
-	encrypted, err := repo.getEncryptedAccountNumber(organization, customerID, acct.AccountID)
+	encrypted, err := repo.getEncryptedAccountNumber(context.Background(), organization, customerID, acct.AccountID)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -150,18 +151,18 @@ func TestRepository__updateAccountStatus(t *testing.T) {
 	if err := req.Disfigure(keeper, "salt"); err != nil {
 		t.Fatal(err)
 	}
-	acct, err := repo.CreateCustomerAccount(customerID, userID, req)
+	acct, err := repo.CreateCustomerAccount(context.Background(), customerID, userID, req)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// update status
-	if err := repo.updateAccountStatus(acct.AccountID, client.ACCOUNTSTATUS_VALIDATED); err != nil {
+	if err := repo.updateAccountStatus(context.Background(), acct.AccountID, client.ACCOUNTSTATUS_VALIDATED); err != nil {
 		t.Fatal(err)
 	}
 
 	// check status after update
-	acct, err = repo.getCustomerAccount(customerID, acct.AccountID)
+	acct, err = repo.getCustomerAccount(context.Background(), customerID, acct.AccountID)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -185,11 +186,11 @@ func TestRepositoryUnique(t *testing.T) {
 		}
 
 		// first write should pass
-		if _, err := repo.CreateCustomerAccount(customerID, userID, req); err != nil {
+		if _, err := repo.CreateCustomerAccount(context.Background(), customerID, userID, req); err != nil {
 			t.Fatal(err)
 		}
 		// second write should fail
-		if _, err := repo.CreateCustomerAccount(customerID, userID, req); err != nil {
+		if _, err := repo.CreateCustomerAccount(context.Background(), customerID, userID, req); err != nil {
 			if !database.UniqueViolation(err) {
 				t.Fatalf("unexpected error: %v", err)
 			}
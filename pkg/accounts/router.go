@@ -59,9 +59,9 @@ func getCustomerAccounts(logger log.Logger, repo Repository, fedClient fed.Clien
 			return
 		}
 
-		accounts, err := repo.getAccounts(customerID, organization)
+		accounts, err := repo.getAccounts(r.Context(), customerID, organization)
 		if err != nil {
-			moovhttp.Problem(w, fmt.Errorf("getting accounts: %v", err))
+			route.Problem(w, fmt.Errorf("getting accounts: %v", err))
 			return
 		}
 		accounts = decorateInstitutionDetails(accounts, fedClient)
@@ -132,35 +132,36 @@ func (req *CreateAccountRequest) Disfigure(keeper *secrets.StringKeeper, appSalt
 func createCustomerAccount(logger log.Logger, repo Repository, fedClient fed.Client, keeper *secrets.StringKeeper, ofac *AccountOfacSearcher, appSalt string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 
 		requestID := moovhttp.GetRequestID(r)
 
 		var request CreateAccountRequest
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		if err := request.Validate(); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, route.Validation(err))
 			return
 		}
 		if err := request.Disfigure(keeper, appSalt); err != nil {
 			logger.LogErrorf("problem disfiguring account: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		if _, err := fedClient.LookupInstitution(request.RoutingNumber); err != nil {
 			logger.LogErrorf("problem looking up routing number=%q: %v", request.RoutingNumber, err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		customerID, userID := route.GetCustomerID(w, r), moovhttp.GetUserID(r)
-		account, err := repo.CreateCustomerAccount(customerID, userID, &request)
+		account, err := repo.CreateCustomerAccount(r.Context(), customerID, userID, &request)
 		if err != nil {
 			logger.LogErrorf("problem saving account: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -181,20 +182,20 @@ func getCustomerAccountByID(logger log.Logger, repo Repository, fedClient fed.Cl
 		customerID := route.GetCustomerID(w, r)
 		accountID := getAccountID(w, r)
 
-		account, err := repo.getCustomerAccount(customerID, accountID)
+		account, err := repo.getCustomerAccount(r.Context(), customerID, accountID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		if account == nil {
-			moovhttp.Problem(w, fmt.Errorf("account with customerID=%s and accountID=%s not found", customerID, accountID))
+			route.Problem(w, route.NotFoundError(fmt.Errorf("account with customerID=%s and accountID=%s not found", customerID, accountID)))
 			return
 		}
 
 		details, err := fedClient.LookupInstitution(account.RoutingNumber)
 		if err != nil {
-			moovhttp.Problem(w, fmt.Errorf("looking up institution details: %v", err))
+			route.Problem(w, fmt.Errorf("looking up institution details: %v", err))
 			return
 		}
 
@@ -211,7 +212,7 @@ func getCustomerAccountByID(logger log.Logger, repo Repository, fedClient fed.Cl
 func getAccountID(w http.ResponseWriter, r *http.Request) string {
 	v, ok := mux.Vars(r)["accountID"]
 	if !ok || v == "" {
-		moovhttp.Problem(w, errors.New("no accountID"))
+		route.Problem(w, errors.New("no accountID"))
 		return ""
 	}
 	return v
@@ -221,6 +222,10 @@ func decryptAccountNumber(logger log.Logger, repo Repository, keeper *secrets.St
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
 
+		if !route.RequireScope(w, r, route.ScopeAdmin) {
+			return
+		}
+
 		customerID, accountID := route.GetCustomerID(w, r), getAccountID(w, r)
 		if customerID == "" || accountID == "" {
 			return
@@ -230,21 +235,21 @@ func decryptAccountNumber(logger log.Logger, repo Repository, keeper *secrets.St
 		organization := route.GetOrganization(w, r)
 
 		// grab encrypted value
-		encrypted, err := repo.getEncryptedAccountNumber(organization, customerID, accountID)
+		encrypted, err := repo.getEncryptedAccountNumber(r.Context(), organization, customerID, accountID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		// decrypt from database
 		decrypted, err := keeper.DecryptString(encrypted)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		// encrypt for transit response
 		encrypted, err = transitKeeper.EncryptString(decrypted)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -261,8 +266,8 @@ func removeCustomerAccount(logger log.Logger, repo Repository) http.HandlerFunc
 		w = route.Responder(logger, w, r)
 
 		accountID := getAccountID(w, r)
-		if err := repo.deactivateCustomerAccount(accountID); err != nil {
-			moovhttp.Problem(w, err)
+		if err := repo.deactivateCustomerAccount(r.Context(), accountID); err != nil {
+			route.Problem(w, err)
 			return
 		}
 
@@ -281,9 +286,9 @@ func getAccountOfacSearch(logger log.Logger, repo Repository) func(http.Response
 			return
 		}
 
-		result, err := repo.getLatestAccountOFACSearch(accountID)
+		result, err := repo.getLatestAccountOFACSearch(r.Context(), accountID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -300,22 +305,22 @@ func refreshAccountOfac(logger log.Logger, repo Repository, ofac *AccountOfacSea
 		requestID := moovhttp.GetRequestID(r)
 		customerID, accountID := route.GetCustomerID(w, r), getAccountID(w, r)
 		if customerID == "" || accountID == "" {
-			moovhttp.Problem(w, errors.New("customerID and accountID required"))
+			route.Problem(w, errors.New("customerID and accountID required"))
 			return
 		}
-		account, err := repo.getCustomerAccount(customerID, accountID)
+		account, err := repo.getCustomerAccount(r.Context(), customerID, accountID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		err = ofac.StoreAccountOFACSearch(account, requestID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
-		result, err := repo.getLatestAccountOFACSearch(accountID)
+		result, err := repo.getLatestAccountOFACSearch(r.Context(), accountID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -326,35 +331,40 @@ func refreshAccountOfac(logger log.Logger, repo Repository, ofac *AccountOfacSea
 func updateAccountStatus(logger log.Logger, repo Repository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+
+		if !route.RequireScope(w, r, route.ScopeAdmin) {
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
 		customerID, accountID := route.GetCustomerID(w, r), getAccountID(w, r)
 		if customerID == "" || accountID == "" {
-			moovhttp.Problem(w, errors.New("customerID and accountID required"))
+			route.Problem(w, errors.New("customerID and accountID required"))
 			return
 		}
 
 		var req client.UpdateAccountStatus
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		switch req.Status {
 		case client.ACCOUNTSTATUS_NONE, client.ACCOUNTSTATUS_VALIDATED:
 			// do nothing
 		default:
-			moovhttp.Problem(w, fmt.Errorf("invalid status: %s", req.Status))
+			route.Problem(w, fmt.Errorf("invalid status: %s", req.Status))
 			return
 		}
 
-		if err := repo.updateAccountStatus(accountID, req.Status); err != nil {
-			moovhttp.Problem(w, err)
+		if err := repo.updateAccountStatus(r.Context(), accountID, req.Status); err != nil {
+			route.Problem(w, err)
 			return
 		}
 
-		account, err := repo.getCustomerAccount(customerID, accountID)
+		account, err := repo.getCustomerAccount(r.Context(), customerID, accountID)
 		if err != nil {
-			moovhttp.Problem(w, errors.New("there was an error getting the account"))
+			route.Problem(w, errors.New("there was an error getting the account"))
 			return
 		}
 
@@ -174,7 +174,7 @@ func TestGetAccountByID(t *testing.T) {
 	var err error
 	for i := 0; i < 5; i++ {
 		accounts = append(accounts, &account{customerID: base.ID()})
-		accounts[i].Account, err = repo.CreateCustomerAccount(accounts[i].customerID, base.ID(), &CreateAccountRequest{
+		accounts[i].Account, err = repo.CreateCustomerAccount(context.Background(), accounts[i].customerID, base.ID(), &CreateAccountRequest{
 			AccountNumber: fmt.Sprintf("%d", i),
 			RoutingNumber: "987654320",
 			Type:          client.ACCOUNTTYPE_CHECKING,
@@ -214,7 +214,7 @@ func TestRoutes__DecryptAccountNumber(t *testing.T) {
 	if err := req.Disfigure(keeper, "salt"); err != nil {
 		t.Fatal(err)
 	}
-	account, err := repo.CreateCustomerAccount(customerID, userID, req)
+	account, err := repo.CreateCustomerAccount(context.Background(), customerID, userID, req)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -344,7 +344,7 @@ func setupCustomerWithOrganization(t *testing.T, customerID, organization string
 		LastName:   "doe",
 		Type:       client.CUSTOMERTYPE_INDIVIDUAL,
 	}
-	custErr := customerRepo.CreateCustomer(cust, organization)
+	custErr := customerRepo.CreateCustomer(context.Background(), cust, organization)
 	if custErr != nil {
 		t.Fatal(custErr)
 	}
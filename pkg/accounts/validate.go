@@ -11,7 +11,6 @@ import (
 	"strings"
 
 	"github.com/gorilla/mux"
-	moovhttp "github.com/moov-io/base/http"
 
 	"github.com/moov-io/customers/pkg/client"
 	"github.com/moov-io/customers/pkg/route"
@@ -31,15 +30,15 @@ func getAccountValidation(logger log.Logger, accounts Repository, validations va
 		accountID := vars["accountID"]
 		validationID := vars["validationID"]
 
-		account, err := accounts.getCustomerAccount(customerID, accountID)
+		account, err := accounts.getCustomerAccount(r.Context(), customerID, accountID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		validation, err := validations.GetValidation(account.AccountID, validationID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -71,20 +70,20 @@ func initAccountValidation(logger log.Logger, accounts Repository, validations v
 			return
 		}
 
-		account, err := accounts.getCustomerAccount(customerID, accountID)
+		account, err := accounts.getCustomerAccount(r.Context(), customerID, accountID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		if !strings.EqualFold(string(account.Status), string(client.ACCOUNTSTATUS_NONE)) {
-			moovhttp.Problem(w, fmt.Errorf("expected accountID=%s status to be '%s', but it is '%s'", accountID, client.ACCOUNTSTATUS_NONE, account.Status))
+			route.Problem(w, fmt.Errorf("expected accountID=%s status to be '%s', but it is '%s'", accountID, client.ACCOUNTSTATUS_NONE, account.Status))
 			return
 		}
 
 		// decode request params
 		req := &client.InitAccountValidationRequest{}
 		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			moovhttp.Problem(w, fmt.Errorf("unable to read request: %v", err))
+			route.Problem(w, fmt.Errorf("unable to read request: %v", err))
 			return
 		}
 
@@ -101,7 +100,7 @@ func initAccountValidation(logger log.Logger, accounts Repository, validations v
 
 		strategy, found := strategies[strategyKey]
 		if !found {
-			moovhttp.Problem(w, fmt.Errorf("strategy %s for vendor %s was not found", req.Strategy, req.Vendor))
+			route.Problem(w, fmt.Errorf("strategy %s for vendor %s was not found", req.Strategy, req.Vendor))
 			return
 		}
 
@@ -115,14 +114,14 @@ func initAccountValidation(logger log.Logger, accounts Repository, validations v
 		}
 		err = validations.CreateValidation(validation)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		// execute strategy and get vendor response
 		vendorResponse, err := strategy.InitAccountValidation(organization, accountID, customerID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -157,31 +156,31 @@ func completeAccountValidation(logger log.Logger, repo Repository, validations v
 			return
 		}
 
-		account, err := repo.getCustomerAccount(customerID, accountID)
+		account, err := repo.getCustomerAccount(r.Context(), customerID, accountID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		if !strings.EqualFold(string(account.Status), string(client.ACCOUNTSTATUS_NONE)) {
-			moovhttp.Problem(w, fmt.Errorf("expected accountID=%s status to be '%s', but it is '%s'", accountID, client.ACCOUNTSTATUS_NONE, account.Status))
+			route.Problem(w, fmt.Errorf("expected accountID=%s status to be '%s', but it is '%s'", accountID, client.ACCOUNTSTATUS_NONE, account.Status))
 			return
 		}
 
 		// decode request params
 		req := &client.CompleteAccountValidationRequest{}
 		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			moovhttp.Problem(w, fmt.Errorf("unable to read request: %v", err))
+			route.Problem(w, fmt.Errorf("unable to read request: %v", err))
 			return
 		}
 
 		validation, err := validations.GetValidation(account.AccountID, validationID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		if validation.Status != validator.StatusInit {
-			moovhttp.Problem(w, fmt.Errorf("expected validation: %s status to be '%s', but it is '%s'", validationID, validator.StatusInit, validation.Status))
+			route.Problem(w, fmt.Errorf("expected validation: %s status to be '%s', but it is '%s'", validationID, validator.StatusInit, validation.Status))
 			return
 		}
 
@@ -193,41 +192,41 @@ func completeAccountValidation(logger log.Logger, repo Repository, validations v
 
 		strategy, found := strategies[strategyKey]
 		if !found {
-			moovhttp.Problem(w, fmt.Errorf("strategy %s for vendor %s was not found", strategyKey.Strategy, strategyKey.Vendor))
+			route.Problem(w, fmt.Errorf("strategy %s for vendor %s was not found", strategyKey.Strategy, strategyKey.Vendor))
 			return
 		}
 
 		// grab encrypted account number
-		encrypted, err := repo.getEncryptedAccountNumber(organization, customerID, accountID)
+		encrypted, err := repo.getEncryptedAccountNumber(r.Context(), organization, customerID, accountID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		// decrypt from database
 		accountNumber, err := keeper.DecryptString(encrypted)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		vendorRequest := validator.VendorRequest(req.VendorRequest)
 		vendorResponse, err := strategy.CompleteAccountValidation(organization, customerID, account, accountNumber, &vendorRequest)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		validation.Status = validator.StatusCompleted
 		err = validations.UpdateValidation(validation)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
-		err = repo.updateAccountStatus(accountID, client.ACCOUNTSTATUS_VALIDATED)
+		err = repo.updateAccountStatus(r.Context(), accountID, client.ACCOUNTSTATUS_VALIDATED)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -6,6 +6,7 @@ package accounts
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -37,7 +38,7 @@ func TestRouter__AccountValidation(t *testing.T) {
 	validations := &validator.MockRepository{}
 
 	// create account
-	acc, err := accounts.CreateCustomerAccount(customerID, userID, &CreateAccountRequest{
+	acc, err := accounts.CreateCustomerAccount(context.Background(), customerID, userID, &CreateAccountRequest{
 		AccountNumber: "123",
 		RoutingNumber: "987654320",
 		Type:          client.ACCOUNTTYPE_CHECKING,
@@ -110,7 +111,7 @@ func TestRouter__InitAccountValidation(t *testing.T) {
 	}
 
 	// create account
-	acc, err := accounts.CreateCustomerAccount(customerID, userID, &CreateAccountRequest{
+	acc, err := accounts.CreateCustomerAccount(context.Background(), customerID, userID, &CreateAccountRequest{
 		AccountNumber: "123",
 		RoutingNumber: "987654320",
 		Type:          client.ACCOUNTTYPE_CHECKING,
@@ -118,14 +119,14 @@ func TestRouter__InitAccountValidation(t *testing.T) {
 	require.NoError(t, err)
 
 	t.Run("Test when account is validated already", func(t *testing.T) {
-		acc, err := accounts.CreateCustomerAccount(customerID, userID, &CreateAccountRequest{
+		acc, err := accounts.CreateCustomerAccount(context.Background(), customerID, userID, &CreateAccountRequest{
 			AccountNumber: "1234",
 			RoutingNumber: "987654321",
 			Type:          client.ACCOUNTTYPE_CHECKING,
 		})
 		require.NoError(t, err)
 
-		err = accounts.updateAccountStatus(acc.AccountID, client.ACCOUNTSTATUS_VALIDATED)
+		err = accounts.updateAccountStatus(context.Background(), acc.AccountID, client.ACCOUNTSTATUS_VALIDATED)
 		require.NoError(t, err)
 
 		params := map[string]string{
@@ -264,7 +265,7 @@ func TestRouter__CompleteAccountValidation(t *testing.T) {
 		{Strategy: "test", Vendor: "moov"}: testvalidator.NewStrategy(),
 	}
 
-	acc, err := repo.CreateCustomerAccount(customerID, userID, &CreateAccountRequest{
+	acc, err := repo.CreateCustomerAccount(context.Background(), customerID, userID, &CreateAccountRequest{
 		AccountNumber: "123456",
 		RoutingNumber: "987654323",
 		Type:          client.ACCOUNTTYPE_CHECKING,
@@ -272,14 +273,14 @@ func TestRouter__CompleteAccountValidation(t *testing.T) {
 	require.NoError(t, err)
 
 	t.Run("Test when account is validated already", func(t *testing.T) {
-		acc, err := repo.CreateCustomerAccount(customerID, userID, &CreateAccountRequest{
+		acc, err := repo.CreateCustomerAccount(context.Background(), customerID, userID, &CreateAccountRequest{
 			AccountNumber: "123",
 			RoutingNumber: "987654320",
 			Type:          client.ACCOUNTTYPE_CHECKING,
 		})
 		require.NoError(t, err)
 
-		err = repo.updateAccountStatus(acc.AccountID, client.ACCOUNTSTATUS_VALIDATED)
+		err = repo.updateAccountStatus(context.Background(), acc.AccountID, client.ACCOUNTSTATUS_VALIDATED)
 		require.NoError(t, err)
 
 		// build request for test strategy
@@ -366,7 +367,7 @@ func TestRouter__CompleteAccountValidation(t *testing.T) {
 		encrypted, err := keeper.EncryptString("1234")
 		require.NoError(t, err)
 
-		acc, err := repo.CreateCustomerAccount(customerID, userID, &CreateAccountRequest{
+		acc, err := repo.CreateCustomerAccount(context.Background(), customerID, userID, &CreateAccountRequest{
 			AccountNumber:          "1234",
 			RoutingNumber:          "987654321",
 			Type:                   client.ACCOUNTTYPE_CHECKING,
@@ -381,7 +382,7 @@ func TestRouter__CompleteAccountValidation(t *testing.T) {
 			LastName:   "doe",
 			Type:       client.CUSTOMERTYPE_INDIVIDUAL,
 		}
-		custErr := customerRepo.CreateCustomer(cust, organization)
+		custErr := customerRepo.CreateCustomer(context.Background(), cust, organization)
 		if custErr != nil {
 			t.Fatal(custErr)
 		}
@@ -451,7 +452,7 @@ func TestRouter__CompleteAccountValidation(t *testing.T) {
 		encrypted, err := keeper.EncryptString("12345")
 		require.NoError(t, err)
 
-		acc, err := repo.CreateCustomerAccount(customerID, userID, &CreateAccountRequest{
+		acc, err := repo.CreateCustomerAccount(context.Background(), customerID, userID, &CreateAccountRequest{
 			AccountNumber:          "12345",
 			RoutingNumber:          "987654322",
 			Type:                   client.ACCOUNTTYPE_CHECKING,
@@ -490,7 +491,7 @@ func TestRouter__CompleteAccountValidation(t *testing.T) {
 			LastName:   "doe",
 			Type:       client.CUSTOMERTYPE_INDIVIDUAL,
 		}
-		custErr := customerRepo.CreateCustomer(cust, organization)
+		custErr := customerRepo.CreateCustomer(context.Background(), cust, organization)
 		if custErr != nil {
 			t.Fatal(custErr)
 		}
@@ -527,7 +528,7 @@ func TestRouter__CompleteAccountValidation(t *testing.T) {
 		require.Equal(t, http.StatusOK, w.Code)
 
 		// check if account status was set to validated
-		updatedAccount, err := repo.getCustomerAccount(customerID, acc.AccountID)
+		updatedAccount, err := repo.getCustomerAccount(context.Background(), customerID, acc.AccountID)
 		require.NoError(t, err)
 		require.Equal(t, client.ACCOUNTSTATUS_VALIDATED, updatedAccount.Status)
 
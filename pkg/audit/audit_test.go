@@ -0,0 +1,189 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/secrets"
+	"github.com/moov-io/customers/pkg/watchman"
+)
+
+func TestAudit__computeDiff(t *testing.T) {
+	before := &client.Customer{CustomerID: "foo", FirstName: "Jane", Status: client.CUSTOMERSTATUS_UNKNOWN}
+	after := &client.Customer{CustomerID: "foo", FirstName: "Jane", Email: "jane@example.com", Status: client.CUSTOMERSTATUS_VERIFIED}
+
+	diff, err := computeDiff(before, after)
+	require.NoError(t, err)
+	require.Len(t, diff, 2)
+	require.Equal(t, Change{From: "Unknown", To: "Verified"}, diff["status"])
+	require.Equal(t, Change{From: "", To: "jane@example.com"}, diff["email"])
+
+	diff, err = computeDiff(before, nil)
+	require.NoError(t, err)
+	require.Equal(t, Change{From: "Jane"}, diff["firstName"])
+
+	var nilCustomer *client.Customer
+	diff, err = computeDiff(nilCustomer, nilCustomer)
+	require.NoError(t, err)
+	require.Empty(t, diff)
+}
+
+func TestAudit__readListParams(t *testing.T) {
+	req := httptest.NewRequest("GET", "/customers/foo/audit?from=2020-06-01&to=2020-06-02", nil)
+	params, err := readListParams(req)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC), params.From)
+	require.Equal(t, time.Date(2020, time.June, 3, 0, 0, 0, 0, time.UTC), params.To)
+	require.Equal(t, 20, params.Limit)
+
+	req = httptest.NewRequest("GET", "/customers/foo/audit?from=2020-06-01T12:00:00Z", nil)
+	params, err = readListParams(req)
+	require.NoError(t, err)
+	require.Equal(t, 12, params.From.Hour())
+	require.True(t, params.To.IsZero())
+
+	req = httptest.NewRequest("GET", "/customers/foo/audit?from=yesterday", nil)
+	_, err = readListParams(req)
+	require.Error(t, err)
+
+	req = httptest.NewRequest("GET", "/customers/foo/audit?from=2020-06-02&to=2020-05-31", nil)
+	_, err = readListParams(req)
+	require.Error(t, err)
+}
+
+func TestAudit__middleware(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t).DB
+
+	repo := NewRepository(logger, db)
+	customerRepo := customers.NewCustomerRepo(logger, db)
+	ssnStorage := customers.NewSSNStorage(secrets.TestStringKeeper(t), customers.NewCustomerSSNRepository(logger, db), "")
+
+	router := mux.NewRouter()
+	router.Use(Middleware(logger, repo, customerRepo))
+	customers.AddCustomerRoutes(logger, router, customerRepo, ssnStorage, customers.NewOFACSearcher(customerRepo, &watchman.TestWatchmanClient{}), nil, nil)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("x-organization", "test")
+		req.Header.Set("x-user-id", "operator")
+		req.Header.Set("x-request-id", "req-1")
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/customers", `{"firstName": "Jane", "lastName": "Doe", "type": "individual"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var cust client.Customer
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&cust))
+
+	w = do("PUT", fmt.Sprintf("/customers/%s/status", cust.CustomerID), `{"status": "receiveonly"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = do("PATCH", fmt.Sprintf("/customers/%s", cust.CustomerID), `{"email": "jane@example.com"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// failed writes and reads aren't recorded
+	w = do("PUT", fmt.Sprintf("/customers/%s/status", cust.CustomerID), `{"status": "other"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = do("GET", fmt.Sprintf("/customers/%s", cust.CustomerID), "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	entries, err := repo.list(cust.CustomerID, "test", listParams{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	// newest first
+	require.Equal(t, "PATCH /customers/{customerID}", entries[0].Action)
+	require.Equal(t, Change{From: "", To: "jane@example.com"}, entries[0].Diff["email"])
+
+	require.Equal(t, "PUT /customers/{customerID}/status", entries[1].Action)
+	require.Equal(t, Change{From: "Unknown", To: "ReceiveOnly"}, entries[1].Diff["status"])
+
+	require.Equal(t, "POST /customers", entries[2].Action)
+	require.Equal(t, "operator", entries[2].Actor)
+	require.Equal(t, "req-1", entries[2].RequestID)
+	require.Equal(t, Change{To: "Jane"}, entries[2].Diff["firstName"])
+
+	// read them from the admin server
+	svc := admin.NewServer(":0")
+	defer svc.Shutdown()
+	AddAdminRoutes(logger, svc, repo)
+	go svc.Listen()
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/customers/%s/audit?to=%s", svc.BindAddr(), cust.CustomerID, time.Now().Format("2006-01-02")), nil)
+	require.NoError(t, err)
+	req.Header.Set("x-organization", "test")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var got []*Entry
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Len(t, got, 3)
+
+	// export as CSV
+	get := func(query string) *http.Response {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/customers/%s/audit?%s", svc.BindAddr(), cust.CustomerID, query), nil)
+		require.NoError(t, err)
+		req.Header.Set("x-organization", "test")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+	resp = get("format=csv")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	require.Equal(t, exportColumns, records[0])
+	require.Equal(t, got[0].AuditID, records[1][0])
+	require.Equal(t, "PATCH /customers/{customerID}", records[1][3])
+	require.Contains(t, records[1][7], `"email":{"from":"","to":"jane@example.com"}`)
+
+	resp = get("format=json&count=1")
+	defer resp.Body.Close()
+	require.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+	got = nil
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Len(t, got, 1)
+
+	// exports with no entries still have a header row
+	resp = get("format=csv&from=2099-01-01")
+	defer resp.Body.Close()
+	records, err = csv.NewReader(resp.Body).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, [][]string{exportColumns}, records)
+
+	resp = get("format=xml")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	entries, err = repo.list(cust.CustomerID, "test", listParams{From: time.Now().Add(time.Hour), Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Change is the value of a field before and after a write. From is nil for created fields
+// and To is nil for removed fields.
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Diff holds each top-level field which changed
+type Diff map[string]Change
+
+// computeDiff compares the JSON encoding of two records. Either can be nil for records which were
+// created or deleted.
+func computeDiff(before, after interface{}) (Diff, error) {
+	from, err := asFields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := asFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(Diff)
+	for k, v := range from {
+		if !reflect.DeepEqual(v, to[k]) {
+			diff[k] = Change{From: v, To: to[k]}
+		}
+	}
+	for k, v := range to {
+		if _, exists := from[k]; !exists {
+			diff[k] = Change{To: v}
+		}
+	}
+	return diff, nil
+}
+
+func asFields(record interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if record == nil {
+		return fields, nil
+	}
+	if v := reflect.ValueOf(record); v.Kind() == reflect.Ptr && v.IsNil() {
+		return fields, nil
+	}
+	bs, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bs, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
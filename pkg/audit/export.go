@@ -0,0 +1,163 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// exportColumns is the header row of a CSV export, in the order each entry is written
+var exportColumns = []string{"auditID", "createdAt", "actor", "action", "customerID", "organization", "requestID", "diff"}
+
+// exporter writes audit log entries to a response as they're read from the database
+type exporter interface {
+	contentType() string
+
+	// write is called once for each entry, then close after the last one
+	write(e *Entry) error
+	flush() error
+	close() error
+}
+
+func newExporter(format string, w io.Writer) (exporter, error) {
+	switch strings.ToLower(format) {
+	case "", "json":
+		return &jsonExporter{w: w}, nil
+	case "csv":
+		return &csvExporter{w: csv.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, expected csv or json", format)
+}
+
+// jsonExporter writes a JSON array of entries, one element at a time
+type jsonExporter struct {
+	w       io.Writer
+	written int
+}
+
+func (e *jsonExporter) contentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (e *jsonExporter) write(entry *Entry) error {
+	bs, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding entry %s: %v", entry.AuditID, err)
+	}
+	sep := ","
+	if e.written == 0 {
+		sep = "["
+	}
+	e.written++
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(bs)
+	return err
+}
+
+func (e *jsonExporter) flush() error {
+	return nil
+}
+
+func (e *jsonExporter) close() error {
+	end := "]\n"
+	if e.written == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// csvExporter writes a header row and then one row per entry. The diff is written as a JSON object.
+type csvExporter struct {
+	w       *csv.Writer
+	started bool
+}
+
+func (e *csvExporter) contentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (e *csvExporter) header() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	return e.w.Write(exportColumns)
+}
+
+func (e *csvExporter) write(entry *Entry) error {
+	if err := e.header(); err != nil {
+		return err
+	}
+	diff, err := json.Marshal(entry.Diff)
+	if err != nil {
+		return fmt.Errorf("encoding diff of %s: %v", entry.AuditID, err)
+	}
+	return e.w.Write([]string{
+		entry.AuditID,
+		entry.CreatedAt.UTC().Format(time.RFC3339),
+		entry.Actor,
+		entry.Action,
+		entry.CustomerID,
+		entry.Organization,
+		entry.RequestID,
+		string(diff),
+	})
+}
+
+func (e *csvExporter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) close() error {
+	if err := e.header(); err != nil {
+		return err
+	}
+	return e.flush()
+}
+
+// streamEntries writes each entry read by each through ex, flushing the response every so often
+// so large exports aren't held in memory. Errors before the first entry are returned so a Problem
+// can be written; after that the response has started and the export is cut short.
+func streamEntries(w http.ResponseWriter, ex exporter, each func(fn func(*Entry) error) error) (started bool, err error) {
+	flusher, _ := w.(http.Flusher)
+	start := func() {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", ex.contentType())
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+	var n int
+	err = each(func(entry *Entry) error {
+		start()
+		if err := ex.write(entry); err != nil {
+			return err
+		}
+		if n++; n%100 == 0 {
+			if err := ex.flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return started, err
+	}
+	start()
+	return true, ex.close()
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// maxCapturedBody limits how much of a response is kept to find the IDs of created Customers
+const maxCapturedBody = 1024 * 1024
+
+// Middleware records an Entry for each successful create, update or delete made to a Customer or their
+// related records. The Customer is read before and after the write to compute the Diff, so writes to
+// records which aren't part of the Customer (e.g. accounts or documents) are recorded with an empty Diff.
+//
+// Routes without a customerID path variable (e.g. POST /customers) are recorded for each Customer
+// returned in the response.
+func Middleware(logger log.Logger, repo Repository, customerRepo customers.CustomerRepository) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isWrite(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			organization := r.Header.Get("X-Organization")
+			customerID := mux.Vars(r)["customerID"]
+
+			var before *client.Customer
+			if customerID != "" && organization != "" {
+				before, _ = customerRepo.GetCustomer(r.Context(), customerID, organization)
+			}
+
+			rec := &recorder{ResponseWriter: w, capture: customerID == ""}
+			next.ServeHTTP(rec, r)
+
+			if rec.status() < 200 || rec.status() > 299 || organization == "" {
+				return
+			}
+			customerIDs := []string{customerID}
+			if customerID == "" {
+				customerIDs = createdCustomerIDs(rec.body.Bytes())
+			}
+
+			entry := Entry{
+				Actor:        route.GetActor(r),
+				Action:       action(r),
+				Organization: organization,
+				RequestID:    moovhttp.GetRequestID(r),
+			}
+			for i := range customerIDs {
+				if err := recordWrite(r.Context(), repo, customerRepo, entry, customerIDs[i], before); err != nil {
+					logger.Set("customerID", log.String(customerIDs[i])).LogErrorf("problem recording audit log: %v", err)
+				}
+				before = nil
+			}
+		})
+	}
+}
+
+func recordWrite(ctx context.Context, repo Repository, customerRepo customers.CustomerRepository, entry Entry, customerID string, before *client.Customer) error {
+	after, _ := customerRepo.GetCustomer(ctx, customerID, entry.Organization)
+
+	diff, err := computeDiff(before, after)
+	if err != nil {
+		return fmt.Errorf("computing diff: %v", err)
+	}
+	entry.CustomerID = customerID
+	entry.Diff = diff
+	return repo.record(&entry)
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case "POST", "PUT", "PATCH", "DELETE":
+		return true
+	}
+	return false
+}
+
+// action is the HTTP method and route template, e.g. "PUT /customers/{customerID}/status"
+func action(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tmpl, err := current.GetPathTemplate(); err == nil {
+			return fmt.Sprintf("%s %s", r.Method, tmpl)
+		}
+	}
+	return fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+}
+
+// createdCustomerIDs reads the IDs from a Customer response or an import report
+func createdCustomerIDs(body []byte) []string {
+	var resp struct {
+		CustomerID string `json:"customerID"`
+		Rows       []struct {
+			CustomerID string `json:"customerID"`
+		} `json:"rows"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil
+	}
+	var out []string
+	if resp.CustomerID != "" {
+		out = append(out, resp.CustomerID)
+	}
+	for i := range resp.Rows {
+		if resp.Rows[i].CustomerID != "" {
+			out = append(out, resp.Rows[i].CustomerID)
+		}
+	}
+	return out
+}
+
+// recorder keeps the status code and, when capture is set, the start of the response body
+type recorder struct {
+	http.ResponseWriter
+
+	code    int
+	capture bool
+	body    bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	if r.capture && r.body.Len() < maxCapturedBody {
+		r.body.Write(p)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *recorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
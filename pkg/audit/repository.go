@@ -0,0 +1,157 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+)
+
+// Entry is one write made to a Customer or their related records
+type Entry struct {
+	AuditID      string    `json:"auditID"`
+	Actor        string    `json:"actor,omitempty"`
+	Action       string    `json:"action"`
+	CustomerID   string    `json:"customerID"`
+	Organization string    `json:"organization"`
+	RequestID    string    `json:"requestID,omitempty"`
+	Diff         Diff      `json:"diff"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Repository stores the audit log. Entries are only ever appended.
+type Repository interface {
+	record(entry *Entry) error
+	list(customerID, organization string, params listParams) ([]*Entry, error)
+
+	// each calls fn with every matching entry as it's read, newest first, stopping at the first error
+	each(customerID, organization string, params listParams, fn func(*Entry) error) error
+}
+
+func NewRepository(logger log.Logger, db *sql.DB) Repository {
+	return &sqlRepository{db: db, logger: logger}
+}
+
+type sqlRepository struct {
+	db     *sql.DB
+	logger log.Logger
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Record appends an entry with the provided transaction, for writes made outside of an HTTP route.
+func Record(tx *sql.Tx, entry *Entry) error {
+	return insertEntry(tx, entry)
+}
+
+func (r *sqlRepository) record(entry *Entry) error {
+	return insertEntry(r.db, entry)
+}
+
+func insertEntry(db execer, entry *Entry) error {
+	if entry.AuditID == "" {
+		entry.AuditID = base.ID()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if entry.Diff == nil {
+		entry.Diff = make(Diff)
+	}
+	diff, err := json.Marshal(entry.Diff)
+	if err != nil {
+		return fmt.Errorf("audit: encoding diff: %v", err)
+	}
+
+	query := `insert into audit_log (audit_id, actor, action, customer_id, organization, request_id, diff, created_at) values (?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err := db.Exec(query, entry.AuditID, entry.Actor, entry.Action, entry.CustomerID, entry.Organization, entry.RequestID, string(diff), entry.CreatedAt); err != nil {
+		return fmt.Errorf("audit: record: %v", err)
+	}
+	return nil
+}
+
+type listParams struct {
+	From time.Time
+	To   time.Time
+
+	// Skip and Limit page through entries, a zero Limit reads every entry
+	Skip  int
+	Limit int
+}
+
+func (r *sqlRepository) list(customerID, organization string, params listParams) ([]*Entry, error) {
+	var out []*Entry
+	err := r.each(customerID, organization, params, func(e *Entry) error {
+		out = append(out, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *sqlRepository) each(customerID, organization string, params listParams, fn func(*Entry) error) error {
+	query := `select audit_id, actor, action, customer_id, organization, request_id, diff, created_at from audit_log
+where customer_id = ? and organization = ?`
+	args := []interface{}{customerID, organization}
+	if !params.From.IsZero() {
+		query += " and created_at >= ?"
+		args = append(args, params.From)
+	}
+	if !params.To.IsZero() {
+		query += " and created_at < ?"
+		args = append(args, params.To)
+	}
+	query += " order by created_at desc"
+	if params.Limit > 0 {
+		query += " limit ? offset ?"
+		args = append(args, params.Limit, params.Skip)
+	}
+	query += ";"
+
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("audit: list: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return fmt.Errorf("audit: list: query: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e Entry
+		var actor, requestID, diff *string
+		if err := rows.Scan(&e.AuditID, &actor, &e.Action, &e.CustomerID, &e.Organization, &requestID, &diff, &e.CreatedAt); err != nil {
+			return fmt.Errorf("audit: list: scan: %v", err)
+		}
+		if actor != nil {
+			e.Actor = *actor
+		}
+		if requestID != nil {
+			e.RequestID = *requestID
+		}
+		e.Diff = make(Diff)
+		if diff != nil && *diff != "" {
+			if err := json.Unmarshal([]byte(*diff), &e.Diff); err != nil {
+				return fmt.Errorf("audit: list: decoding diff of %s: %v", e.AuditID, err)
+			}
+		}
+		if err := fn(&e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
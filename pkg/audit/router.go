@@ -0,0 +1,111 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/base/admin"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/model"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// AddAdminRoutes registers an endpoint to read the audit log of a Customer
+func AddAdminRoutes(logger log.Logger, svc *admin.Server, repo Repository) {
+	logger = logger.Set("package", log.String("audit"))
+
+	svc.AddHandler("/customers/{customerID}/audit", getAuditLog(logger, repo))
+}
+
+func getAuditLog(logger log.Logger, repo Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if r.Method != "GET" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		params, err := readListParams(r)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+
+		ex, err := newExporter(r.URL.Query().Get("format"), w)
+		if err != nil {
+			route.Problem(w, route.Validation(err))
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+		started, err := streamEntries(w, ex, func(fn func(*Entry) error) error {
+			return repo.each(customerID, organization, params, fn)
+		})
+		if err != nil {
+			if started {
+				// the status has already been written, so the export is cut short
+				logger.LogErrorf("problem exporting audit log: %v", err)
+				return
+			}
+			logger.LogErrorf("problem reading audit log: %v", err)
+			route.Problem(w, err)
+		}
+	}
+}
+
+// readListParams reads the optional from and to query parameters, which are either RFC 3339 timestamps
+// or YYYY-MM-DD dates. A date for to includes the entire day. Entries are returned newest first.
+//
+// Exports (requests with a format) include every entry unless skip or count are provided.
+func readListParams(r *http.Request) (listParams, error) {
+	var params listParams
+	var err error
+
+	if params.From, err = readTime(r.URL.Query().Get("from"), false); err != nil {
+		return params, fmt.Errorf("invalid from: %v", err)
+	}
+	if params.To, err = readTime(r.URL.Query().Get("to"), true); err != nil {
+		return params, fmt.Errorf("invalid to: %v", err)
+	}
+	if !params.From.IsZero() && !params.To.IsZero() && params.To.Before(params.From) {
+		return params, fmt.Errorf("to (%v) is before from (%v)", params.To, params.From)
+	}
+
+	var paged bool
+	params.Skip, params.Limit, paged, err = moovhttp.GetSkipAndCount(r)
+	if err == nil && !paged && r.URL.Query().Get("format") != "" {
+		params.Skip, params.Limit = 0, 0
+	}
+	return params, err
+}
+
+func readTime(v string, endOfDay bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(model.YYYYMMDD_Format, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp or YYYY-MM-DD date", v)
+	}
+	if endOfDay {
+		t = t.Add(24 * time.Hour)
+	}
+	return t, nil
+}
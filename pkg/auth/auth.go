@@ -0,0 +1,141 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package auth authenticates requests to the business HTTP and gRPC servers and attaches the caller's
+// identity and scopes to each request. How credentials are checked is up to the Authenticator,
+// which can trust headers from an upstream gateway, verify JWTs or ask an OAuth2 introspection endpoint.
+//
+// Reads require the customers:read scope and writes require customers:write. Handlers check for
+// other scopes, such as customers:admin, with route.RequireScope.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+var errMissingCredentials = errors.New("missing credentials")
+
+// Authenticator checks the credentials of a request and returns who made it
+type Authenticator interface {
+	Authenticate(r *http.Request) (*route.Identity, error)
+}
+
+// Config selects and configures an Authenticator
+type Config struct {
+	// Provider is gateway, jwt or introspection. Requests aren't authenticated when it's empty.
+	Provider string
+
+	JWT           JWTConfig
+	Introspection IntrospectionConfig
+}
+
+// NewAuthenticator returns the Authenticator for cfg.Provider, or nil when authentication is disabled
+func NewAuthenticator(cfg Config) (Authenticator, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
+	case "":
+		return nil, nil
+	case "gateway":
+		return &GatewayAuthenticator{}, nil
+	case "jwt":
+		return NewJWTAuthenticator(cfg.JWT)
+	case "introspection":
+		return NewIntrospectionAuthenticator(cfg.Introspection)
+	}
+	return nil, fmt.Errorf("unknown auth provider %q", cfg.Provider)
+}
+
+// Middleware rejects requests which authenticator doesn't accept or that lack the scope for their
+// HTTP method. Routes whose path template starts with one of publicPrefixes (e.g. health checks)
+// skip authentication. A nil authenticator allows every request, as before authentication was added.
+//
+// The authenticated subject replaces any X-User-ID header so handlers and the audit log see the
+// verified caller. Identities limited to an organization fill in a missing X-Organization header and
+// are forbidden from any other organization.
+func Middleware(logger log.Logger, authenticator Authenticator, publicPrefixes ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if authenticator == nil || isPublic(r, publicPrefixes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id, err := authenticator.Authenticate(r)
+			if err != nil {
+				logger.Set("requestID", log.String(moovhttp.GetRequestID(r))).Logf("rejected unauthenticated request: %v", err)
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				route.Problem(w, route.Unauthorized(err))
+				return
+			}
+
+			if id.Organization != "" {
+				if organization := r.Header.Get("X-Organization"); organization == "" {
+					r.Header.Set("X-Organization", id.Organization)
+				} else if organization != id.Organization {
+					route.Problem(w, route.Forbidden(fmt.Errorf("not allowed to access organization %s", organization)))
+					return
+				}
+			}
+			r.Header.Set("X-User-ID", id.Subject)
+			r = r.WithContext(route.WithIdentity(r.Context(), id))
+
+			if !route.RequireScope(w, r, methodScope(r.Method)) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// methodScope returns the scope every request with method needs
+func methodScope(method string) string {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return route.ScopeRead
+	}
+	return route.ScopeWrite
+}
+
+func isPublic(r *http.Request, prefixes []string) bool {
+	path := r.URL.Path
+	if current := mux.CurrentRoute(r); current != nil {
+		if tmpl, err := current.GetPathTemplate(); err == nil {
+			path = tmpl
+		}
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// bearerToken returns the token from an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) (string, error) {
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	if header == "" {
+		return "", errMissingCredentials
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || strings.TrimSpace(parts[1]) == "" {
+		return "", errors.New("authorization header must be a bearer token")
+	}
+	return strings.TrimSpace(parts[1]), nil
+}
+
+// splitScopes reads scopes separated by spaces (as in OAuth2) or commas
+func splitScopes(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+func TestAuth__NewAuthenticator(t *testing.T) {
+	a, err := NewAuthenticator(Config{})
+	require.NoError(t, err)
+	require.Nil(t, a)
+
+	a, err = NewAuthenticator(Config{Provider: "Gateway"})
+	require.NoError(t, err)
+	require.IsType(t, &GatewayAuthenticator{}, a)
+
+	_, err = NewAuthenticator(Config{Provider: "jwt"})
+	require.Error(t, err)
+
+	_, err = NewAuthenticator(Config{Provider: "ldap"})
+	require.Error(t, err)
+}
+
+func TestAuth__HasScope(t *testing.T) {
+	var id *route.Identity
+	require.False(t, id.HasScope(route.ScopeRead))
+
+	id = &route.Identity{Scopes: []string{route.ScopeWrite, "reports:read"}}
+	require.True(t, id.HasScope(route.ScopeRead))
+	require.True(t, id.HasScope(route.ScopeWrite))
+	require.False(t, id.HasScope(route.ScopeAdmin))
+	require.True(t, id.HasScope("reports:read"))
+	require.False(t, id.HasScope("reports:write"))
+
+	id = &route.Identity{Scopes: []string{route.ScopeAdmin}}
+	require.True(t, id.HasScope(route.ScopeRead))
+}
+
+func TestAuth__splitScopes(t *testing.T) {
+	require.Equal(t, []string{"customers:read", "customers:write"}, splitScopes(" customers:read customers:write"))
+	require.Equal(t, []string{"customers:read", "customers:write"}, splitScopes("customers:read,customers:write"))
+	require.Empty(t, splitScopes(""))
+}
+
+func TestAuth__bearerToken(t *testing.T) {
+	req := httptest.NewRequest("GET", "/customers", nil)
+	_, err := bearerToken(req)
+	require.Equal(t, errMissingCredentials, err)
+
+	req.Header.Set("Authorization", "Basic Zm9vOmJhcg==")
+	_, err = bearerToken(req)
+	require.Error(t, err)
+
+	req.Header.Set("Authorization", "bearer abc.def")
+	token, err := bearerToken(req)
+	require.NoError(t, err)
+	require.Equal(t, "abc.def", token)
+}
+
+func TestAuth__Middleware(t *testing.T) {
+	var seen *route.Identity
+	var actor, organization string
+
+	router := mux.NewRouter()
+	router.Use(Middleware(log.NewNopLogger(), &GatewayAuthenticator{}, "/ping"))
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		seen, actor, organization = route.GetIdentity(r), route.GetActor(r), r.Header.Get("X-Organization")
+		if r.URL.Path == "/admin" && !route.RequireScope(w, r, route.ScopeAdmin) {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	router.Methods("GET").Path("/ping").HandlerFunc(handler)
+	router.Methods("GET", "POST").Path("/customers").HandlerFunc(handler)
+	router.Methods("POST").Path("/admin").HandlerFunc(handler)
+
+	call := func(method, path, userID, scopes string) int {
+		seen = nil
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Organization", "test")
+		if userID != "" {
+			req.Header.Set("X-User-ID", userID)
+		}
+		if scopes != "" {
+			req.Header.Set("X-Scopes", scopes)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// public routes skip authentication
+	require.Equal(t, http.StatusOK, call("GET", "/ping", "", ""))
+	require.Nil(t, seen)
+
+	require.Equal(t, http.StatusUnauthorized, call("GET", "/customers", "", route.ScopeRead))
+
+	require.Equal(t, http.StatusOK, call("GET", "/customers", "jane", route.ScopeRead))
+	require.Equal(t, "jane", seen.Subject)
+	require.Equal(t, "jane", actor)
+	require.Equal(t, "test", organization)
+
+	// writes need customers:write
+	require.Equal(t, http.StatusForbidden, call("POST", "/customers", "jane", route.ScopeRead))
+	require.Equal(t, http.StatusOK, call("POST", "/customers", "jane", route.ScopeWrite))
+
+	// handlers can require more
+	require.Equal(t, http.StatusForbidden, call("POST", "/admin", "jane", route.ScopeWrite))
+	require.Equal(t, http.StatusOK, call("POST", "/admin", "jane", route.ScopeAdmin))
+}
+
+type staticAuthenticator struct {
+	id *route.Identity
+}
+
+func (a staticAuthenticator) Authenticate(r *http.Request) (*route.Identity, error) {
+	return a.id, nil
+}
+
+func TestAuth__MiddlewareOrganization(t *testing.T) {
+	var actor, organization string
+	router := mux.NewRouter()
+	router.Use(Middleware(log.NewNopLogger(), staticAuthenticator{&route.Identity{Subject: "svc", Organization: "acme", Scopes: []string{route.ScopeRead}}}))
+	router.Methods("GET").Path("/customers").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor, organization = r.Header.Get("X-User-ID"), r.Header.Get("X-Organization")
+	})
+
+	call := func(organization string) int {
+		req := httptest.NewRequest("GET", "/customers", nil)
+		req.Header.Set("X-User-ID", "spoofed")
+		if organization != "" {
+			req.Header.Set("X-Organization", organization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, call(""))
+	require.Equal(t, "acme", organization)
+	require.Equal(t, "svc", actor)
+
+	require.Equal(t, http.StatusOK, call("acme"))
+	require.Equal(t, http.StatusForbidden, call("other"))
+}
+
+func TestAuth__MiddlewareDisabled(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Middleware(log.NewNopLogger(), nil))
+	router.Methods("POST").Path("/customers").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !route.RequireScope(w, r, route.ScopeAdmin) {
+			return
+		}
+		require.Equal(t, "jane", route.GetActor(r))
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	req := httptest.NewRequest("POST", "/customers", nil)
+	req.Header.Set("X-User-ID", "jane")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+}
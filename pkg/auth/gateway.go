@@ -0,0 +1,30 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	moovhttp "github.com/moov-io/base/http"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// GatewayAuthenticator trusts an upstream gateway which has already authenticated the caller and
+// sets the X-User-ID and X-Scopes headers. It must only be used when every request passes through
+// the gateway, otherwise callers can claim any identity.
+type GatewayAuthenticator struct{}
+
+func (*GatewayAuthenticator) Authenticate(r *http.Request) (*route.Identity, error) {
+	userID := strings.TrimSpace(moovhttp.GetUserID(r))
+	if userID == "" {
+		return nil, errMissingCredentials
+	}
+	return &route.Identity{
+		Subject: userID,
+		Scopes:  splitScopes(r.Header.Get("X-Scopes")),
+	}, nil
+}
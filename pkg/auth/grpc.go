@@ -0,0 +1,77 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/moov-io/base/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// UnaryServerInterceptor authenticates gRPC requests with the same Authenticator as the HTTP server.
+// Metadata is read like HTTP headers, so callers send "authorization: Bearer <token>". scopes maps each
+// full method name to the scope it needs and methods missing from it need customers:write.
+// A nil authenticator allows every request.
+//
+// As with Middleware the authenticated subject replaces any x-user-id metadata and identities limited
+// to an organization fill in a missing x-organization and are denied any other organization.
+func UnaryServerInterceptor(logger log.Logger, authenticator Authenticator, scopes map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if authenticator == nil {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		md = md.Copy()
+
+		r, err := http.NewRequestWithContext(ctx, "POST", info.FullMethod, nil)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		for k, vs := range md {
+			if strings.HasPrefix(k, ":") {
+				continue
+			}
+			for i := range vs {
+				r.Header.Add(k, vs[i])
+			}
+		}
+
+		id, err := authenticator.Authenticate(r)
+		if err != nil {
+			logger.Set("method", log.String(info.FullMethod)).Logf("rejected unauthenticated request: %v", err)
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		if id.Organization != "" {
+			if vs := md.Get("x-organization"); len(vs) == 0 || strings.TrimSpace(vs[0]) == "" {
+				md.Set("x-organization", id.Organization)
+			} else if organization := strings.TrimSpace(vs[0]); organization != id.Organization {
+				return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("not allowed to access organization %s", organization))
+			}
+		}
+		md.Set("x-user-id", id.Subject)
+
+		scope, ok := scopes[info.FullMethod]
+		if !ok {
+			scope = route.ScopeWrite
+		}
+		if !id.HasScope(scope) {
+			return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("missing %s scope", scope))
+		}
+
+		ctx = metadata.NewIncomingContext(route.WithIdentity(ctx, id), md)
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+func TestAuth__UnaryServerInterceptor(t *testing.T) {
+	var actor, organization string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		actor, organization = md.Get("x-user-id")[0], md.Get("x-organization")[0]
+		return "ok", nil
+	}
+	call := func(authenticator Authenticator, method string, kv ...string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(append(kv, "x-organization", "test")...))
+		interceptor := UnaryServerInterceptor(log.NewNopLogger(), authenticator, map[string]string{
+			"/svc/Read": route.ScopeRead,
+		})
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+	gateway := &GatewayAuthenticator{}
+
+	require.Equal(t, codes.Unauthenticated, status.Code(call(gateway, "/svc/Read")))
+
+	require.NoError(t, call(gateway, "/svc/Read", "x-user-id", "jane", "x-scopes", route.ScopeRead))
+	require.Equal(t, "jane", actor)
+	require.Equal(t, "test", organization)
+
+	// methods without a scope need customers:write
+	require.Equal(t, codes.PermissionDenied, status.Code(call(gateway, "/svc/Write", "x-user-id", "jane", "x-scopes", route.ScopeRead)))
+	require.NoError(t, call(gateway, "/svc/Write", "x-user-id", "jane", "x-scopes", route.ScopeWrite))
+
+	// the authenticated subject replaces x-user-id
+	svc := staticAuthenticator{&route.Identity{Subject: "svc", Scopes: []string{route.ScopeRead}}}
+	require.NoError(t, call(svc, "/svc/Read", "x-user-id", "spoofed"))
+	require.Equal(t, "svc", actor)
+
+	// identities limited to an organization can't use another
+	limited := staticAuthenticator{&route.Identity{Subject: "svc", Organization: "acme", Scopes: []string{route.ScopeRead}}}
+	require.Equal(t, codes.PermissionDenied, status.Code(call(limited, "/svc/Read")))
+
+	// a nil authenticator allows every request
+	require.NoError(t, call(nil, "/svc/Write", "x-user-id", "jane"))
+}
@@ -0,0 +1,169 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	hashlru "github.com/hashicorp/golang-lru"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// IntrospectionConfig holds the OAuth2 token introspection endpoint bearer tokens are checked against
+type IntrospectionConfig struct {
+	Endpoint string
+
+	// ClientID and ClientSecret authenticate this service to the endpoint with HTTP basic auth
+	ClientID     string
+	ClientSecret string
+
+	// CacheTTL is how long an active token's result is reused before asking the endpoint again.
+	// Tokens are checked on every request when it's zero.
+	CacheTTL time.Duration
+
+	// OrganizationClaim names the response field limiting a token to one organization. (Default: organization)
+	OrganizationClaim string
+}
+
+// IntrospectionAuthenticator checks bearer tokens with an OAuth2 token introspection endpoint (RFC 7662)
+type IntrospectionAuthenticator struct {
+	cfg        IntrospectionConfig
+	httpClient *http.Client
+	cache      *hashlru.Cache
+
+	now func() time.Time
+}
+
+type cachedIdentity struct {
+	id      *route.Identity
+	expires time.Time
+}
+
+func NewIntrospectionAuthenticator(cfg IntrospectionConfig) (*IntrospectionAuthenticator, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("introspection: invalid endpoint %q", cfg.Endpoint)
+	}
+	if cfg.OrganizationClaim == "" {
+		cfg.OrganizationClaim = "organization"
+	}
+	a := &IntrospectionAuthenticator{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+	}
+	if cfg.CacheTTL > 0 {
+		a.cache, _ = hashlru.New(1024)
+	}
+	return a, nil
+}
+
+func (a *IntrospectionAuthenticator) Authenticate(r *http.Request) (*route.Identity, error) {
+	token, err := bearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// tokens are only kept as a hash
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	if a.cache != nil {
+		if v, ok := a.cache.Get(key); ok {
+			if cached, _ := v.(cachedIdentity); a.now().Before(cached.expires) {
+				return cached.id, nil
+			}
+			a.cache.Remove(key)
+		}
+	}
+
+	id, expires, err := a.introspect(token)
+	if err != nil {
+		return nil, err
+	}
+	if a.cache != nil {
+		if ttl := a.now().Add(a.cfg.CacheTTL); expires.IsZero() || ttl.Before(expires) {
+			expires = ttl
+		}
+		a.cache.Add(key, cachedIdentity{id: id, expires: expires})
+	}
+	return id, nil
+}
+
+func (a *IntrospectionAuthenticator) introspect(token string) (*route.Identity, time.Time, error) {
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequest("POST", a.cfg.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("introspection: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if a.cfg.ClientID != "" {
+		req.SetBasicAuth(a.cfg.ClientID, a.cfg.ClientSecret)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("introspection: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("introspection: unexpected status %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("introspection: reading response: %v", err)
+	}
+	var result struct {
+		Active   bool   `json:"active"`
+		Subject  string `json:"sub"`
+		Username string `json:"username"`
+		Scope    string `json:"scope"`
+		Expiry   int64  `json:"exp"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, time.Time{}, fmt.Errorf("introspection: decoding response: %v", err)
+	}
+	if !result.Active {
+		return nil, time.Time{}, errors.New("token is not active")
+	}
+
+	id := &route.Identity{
+		Subject: result.Subject,
+		Scopes:  splitScopes(result.Scope),
+	}
+	if id.Subject == "" {
+		id.Subject = result.Username
+	}
+	if id.Subject == "" {
+		return nil, time.Time{}, errors.New("token is missing its subject")
+	}
+	extra := make(map[string]interface{})
+	if err := json.Unmarshal(body, &extra); err == nil {
+		if org, ok := extra[a.cfg.OrganizationClaim].(string); ok {
+			id.Organization = org
+		}
+	}
+
+	var expires time.Time
+	if result.Expiry > 0 {
+		expires = time.Unix(result.Expiry, 0)
+	}
+	return id, expires, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntrospectionAuthenticator(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "customers" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		require.NoError(t, r.ParseForm())
+		switch r.PostForm.Get("token") {
+		case "good":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"active":       true,
+				"sub":          "jane",
+				"scope":        "customers:read",
+				"organization": "acme",
+				"exp":          time.Now().Add(time.Hour).Unix(),
+			})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"active": false})
+		}
+	}))
+	defer server.Close()
+
+	_, err := NewIntrospectionAuthenticator(IntrospectionConfig{Endpoint: "not a url"})
+	require.Error(t, err)
+
+	a, err := NewIntrospectionAuthenticator(IntrospectionConfig{
+		Endpoint:     server.URL,
+		ClientID:     "customers",
+		ClientSecret: "secret",
+		CacheTTL:     time.Minute,
+	})
+	require.NoError(t, err)
+
+	call := func(token string) error {
+		req := httptest.NewRequest("GET", "/customers", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		id, err := a.Authenticate(req)
+		if err == nil {
+			require.Equal(t, "jane", id.Subject)
+			require.Equal(t, "acme", id.Organization)
+			require.Equal(t, []string{"customers:read"}, id.Scopes)
+		}
+		return err
+	}
+
+	require.NoError(t, call("good"))
+	require.Equal(t, 1, calls)
+
+	// active tokens are cached
+	require.NoError(t, call("good"))
+	require.Equal(t, 1, calls)
+
+	now := time.Now()
+	a.now = func() time.Time { return now.Add(2 * time.Minute) }
+	require.NoError(t, call("good"))
+	require.Equal(t, 2, calls)
+
+	require.Error(t, call("revoked"))
+	require.Error(t, call("revoked"))
+	require.Equal(t, 4, calls)
+
+	a.cfg.ClientSecret = "wrong"
+	a.cache.Purge()
+	require.Error(t, call("good"))
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// JWTConfig holds the key and expected claims of bearer JWTs
+type JWTConfig struct {
+	// Secret verifies HMAC (HS256, HS384 and HS512) signed tokens
+	Secret string
+
+	// PublicKey is a PEM encoded RSA or ECDSA public key (or certificate) which verifies
+	// RS*, PS* or ES* signed tokens. Only one of Secret and PublicKey can be set.
+	PublicKey []byte
+
+	// Issuer and Audience are required to match the iss and aud claims when set
+	Issuer   string
+	Audience string
+
+	// OrganizationClaim names the claim limiting a token to one organization. (Default: organization)
+	OrganizationClaim string
+}
+
+// JWTAuthenticator verifies JWTs sent as bearer tokens. The sub claim is the caller, scopes are read from
+// the scope claim (space separated, as in OAuth2) or a scp list and every token must expire.
+type JWTAuthenticator struct {
+	key        interface{}
+	algorithms map[jose.SignatureAlgorithm]bool
+	expected   jwt.Expected
+	orgClaim   string
+
+	now func() time.Time
+}
+
+func NewJWTAuthenticator(cfg JWTConfig) (*JWTAuthenticator, error) {
+	a := &JWTAuthenticator{
+		expected: jwt.Expected{Issuer: cfg.Issuer},
+		orgClaim: cfg.OrganizationClaim,
+		now:      time.Now,
+	}
+	if cfg.Audience != "" {
+		a.expected.Audience = jwt.Audience{cfg.Audience}
+	}
+	if a.orgClaim == "" {
+		a.orgClaim = "organization"
+	}
+
+	switch {
+	case cfg.Secret != "" && len(cfg.PublicKey) > 0:
+		return nil, errors.New("jwt: only one of a secret and public key can be set")
+	case cfg.Secret != "":
+		a.key = []byte(cfg.Secret)
+		a.algorithms = algorithms(jose.HS256, jose.HS384, jose.HS512)
+	case len(cfg.PublicKey) > 0:
+		key, err := parsePublicKey(cfg.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %v", err)
+		}
+		a.key = key
+		switch key.(type) {
+		case *rsa.PublicKey:
+			a.algorithms = algorithms(jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512)
+		case *ecdsa.PublicKey:
+			a.algorithms = algorithms(jose.ES256, jose.ES384, jose.ES512)
+		default:
+			return nil, fmt.Errorf("jwt: unsupported public key %T", key)
+		}
+	default:
+		return nil, errors.New("jwt: missing secret or public key")
+	}
+	return a, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*route.Identity, error) {
+	raw, err := bearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := jwt.ParseSigned(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+	if len(tok.Headers) != 1 || !a.algorithms[jose.SignatureAlgorithm(tok.Headers[0].Algorithm)] {
+		return nil, errors.New("token is signed with an unexpected algorithm")
+	}
+
+	var claims jwt.Claims
+	var custom struct {
+		Scope scopeList `json:"scope"`
+		Scp   scopeList `json:"scp"`
+	}
+	extra := make(map[string]interface{})
+	if err := tok.Claims(a.key, &claims, &custom, &extra); err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+	if claims.Expiry == nil {
+		return nil, errors.New("token doesn't expire")
+	}
+	if err := claims.ValidateWithLeeway(a.expected.WithTime(a.now()), jwt.DefaultLeeway); err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token is missing its subject")
+	}
+
+	id := &route.Identity{
+		Subject: claims.Subject,
+		Scopes:  append(custom.Scope, custom.Scp...),
+	}
+	if org, ok := extra[a.orgClaim].(string); ok {
+		id.Organization = org
+	}
+	return id, nil
+}
+
+func algorithms(algs ...jose.SignatureAlgorithm) map[jose.SignatureAlgorithm]bool {
+	out := make(map[jose.SignatureAlgorithm]bool)
+	for i := range algs {
+		out[algs[i]] = true
+	}
+	return out
+}
+
+func parsePublicKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("public key isn't PEM encoded")
+	}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// scopeList reads scopes written as one space separated string or a list of strings
+type scopeList []string
+
+func (s *scopeList) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = splitScopes(str)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("scopes must be a string or list of strings")
+	}
+	*s = list
+	return nil
+}
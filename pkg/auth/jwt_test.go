@@ -0,0 +1,132 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func signToken(t *testing.T, alg jose.SignatureAlgorithm, key interface{}, claims ...interface{}) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, nil)
+	require.NoError(t, err)
+	builder := jwt.Signed(signer)
+	for i := range claims {
+		builder = builder.Claims(claims[i])
+	}
+	raw, err := builder.CompactSerialize()
+	require.NoError(t, err)
+	return raw
+}
+
+func authenticate(a *JWTAuthenticator, token string) error {
+	req := httptest.NewRequest("GET", "/customers", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	_, err := a.Authenticate(req)
+	return err
+}
+
+func TestJWTAuthenticator__HMAC(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	a, err := NewJWTAuthenticator(JWTConfig{Secret: string(secret), Issuer: "moov", Audience: "customers"})
+	require.NoError(t, err)
+
+	now := time.Now()
+	claims := jwt.Claims{
+		Subject:  "jane",
+		Issuer:   "moov",
+		Audience: jwt.Audience{"customers"},
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+	token := signToken(t, jose.HS256, secret, claims, map[string]interface{}{
+		"scope":        "customers:read customers:write",
+		"organization": "acme",
+	})
+
+	req := httptest.NewRequest("GET", "/customers", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	id, err := a.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, "jane", id.Subject)
+	require.Equal(t, "acme", id.Organization)
+	require.Equal(t, []string{"customers:read", "customers:write"}, id.Scopes)
+
+	// scp lists are read as well
+	token = signToken(t, jose.HS512, secret, claims, map[string]interface{}{"scp": []string{"customers:admin"}})
+	req.Header.Set("Authorization", "Bearer "+token)
+	id, err = a.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, []string{"customers:admin"}, id.Scopes)
+
+	// expired
+	a.now = func() time.Time { return now.Add(2 * time.Hour) }
+	require.Error(t, authenticate(a, token))
+	a.now = time.Now
+
+	// tokens must expire
+	noExpiry := claims
+	noExpiry.Expiry = nil
+	require.Error(t, authenticate(a, signToken(t, jose.HS256, secret, noExpiry)))
+
+	// wrong issuer, audience and secret
+	wrong := claims
+	wrong.Issuer = "other"
+	require.Error(t, authenticate(a, signToken(t, jose.HS256, secret, wrong)))
+	wrong = claims
+	wrong.Audience = jwt.Audience{"paygate"}
+	require.Error(t, authenticate(a, signToken(t, jose.HS256, secret, wrong)))
+	require.Error(t, authenticate(a, signToken(t, jose.HS256, []byte("another secret which is long enough"), claims)))
+
+	// missing subject
+	wrong = claims
+	wrong.Subject = ""
+	require.Error(t, authenticate(a, signToken(t, jose.HS256, secret, wrong)))
+
+	require.Error(t, authenticate(a, "not-a-jwt"))
+}
+
+func TestJWTAuthenticator__publicKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+
+	a, err := NewJWTAuthenticator(JWTConfig{PublicKey: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})})
+	require.NoError(t, err)
+
+	claims := jwt.Claims{Subject: "svc", Expiry: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+	require.NoError(t, authenticate(a, signToken(t, jose.RS256, rsaKey, claims)))
+	require.NoError(t, authenticate(a, signToken(t, jose.PS256, rsaKey, claims)))
+
+	// HMAC tokens aren't accepted with a public key
+	require.Error(t, authenticate(a, signToken(t, jose.HS256, der, claims)))
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err = x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	require.NoError(t, err)
+	a, err = NewJWTAuthenticator(JWTConfig{PublicKey: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})})
+	require.NoError(t, err)
+	require.NoError(t, authenticate(a, signToken(t, jose.ES256, ecKey, claims)))
+	require.Error(t, authenticate(a, signToken(t, jose.RS256, rsaKey, claims)))
+
+	_, err = NewJWTAuthenticator(JWTConfig{PublicKey: []byte("not pem")})
+	require.Error(t, err)
+	_, err = NewJWTAuthenticator(JWTConfig{Secret: "secret", PublicKey: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})})
+	require.Error(t, err)
+}
@@ -1080,6 +1080,7 @@ func (a *CustomersApiService) UpdateAddress(ctx _context.Context, customerID str
 type UpdateCustomerOpts struct {
 	XRequestID    optional.String
 	XOrganization optional.String
+	IfMatch       optional.String
 }
 
 /*
@@ -1091,6 +1092,7 @@ Update a Customer object
  * @param optional nil or *UpdateCustomerOpts - Optional Parameters:
  * @param "XRequestID" (optional.String) -  Optional requestID allows application developer to trace requests through the systems logs
  * @param "XOrganization" (optional.String) -  Value used to separate and identify models
+ * @param "IfMatch" (optional.String) -  ETag of the Customer version being updated, or * to update any version
 @return Customer
 */
 func (a *CustomersApiService) UpdateCustomer(ctx _context.Context, customerID string, createCustomer CreateCustomer, localVarOptionals *UpdateCustomerOpts) (Customer, *_nethttp.Response, error) {
@@ -1134,6 +1136,9 @@ func (a *CustomersApiService) UpdateCustomer(ctx _context.Context, customerID st
 	if localVarOptionals != nil && localVarOptionals.XOrganization.IsSet() {
 		localVarHeaderParams["X-Organization"] = parameterToString(localVarOptionals.XOrganization.Value(), "")
 	}
+	if localVarOptionals != nil && localVarOptionals.IfMatch.IsSet() {
+		localVarHeaderParams["If-Match"] = parameterToString(localVarOptionals.IfMatch.Value(), "")
+	}
 	// body params
 	localVarPostBody = &createCustomer
 	r, err := a.client.prepareRequest(ctx, localVarPath, localVarHTTPMethod, localVarPostBody, localVarHeaderParams, localVarQueryParams, localVarFormParams, localVarFormFileName, localVarFileName, localVarFileBytes)
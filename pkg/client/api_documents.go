@@ -225,6 +225,9 @@ func (a *DocumentsApiService) GetCustomerDocumentContents(ctx _context.Context,
 type GetCustomerDocumentsOpts struct {
 	XRequestID    optional.String
 	XOrganization optional.String
+	Type_         optional.String
+	Count         optional.String
+	Cursor        optional.String
 }
 
 /*
@@ -235,6 +238,9 @@ Get documents for a customer
  * @param optional nil or *GetCustomerDocumentsOpts - Optional Parameters:
  * @param "XRequestID" (optional.String) -  Optional requestID allows application developer to trace requests through the systems logs
  * @param "XOrganization" (optional.String) -  Value used to separate and identify models
+ * @param "Type_" (optional.String) -  Optional parameter to only return Documents of the given type
+ * @param "Count" (optional.String) -  Optional parameter for specifying the amount of Documents to return, 20 by default and at most 200
+ * @param "Cursor" (optional.String) -  Optional parameter to read the next page of Documents from a previous response's X-Next-Cursor header
 @return []Document
 */
 func (a *DocumentsApiService) GetCustomerDocuments(ctx _context.Context, customerID string, localVarOptionals *GetCustomerDocumentsOpts) ([]Document, *_nethttp.Response, error) {
@@ -255,6 +261,15 @@ func (a *DocumentsApiService) GetCustomerDocuments(ctx _context.Context, custome
 	localVarQueryParams := _neturl.Values{}
 	localVarFormParams := _neturl.Values{}
 
+	if localVarOptionals != nil && localVarOptionals.Type_.IsSet() {
+		localVarQueryParams.Add("type", parameterToString(localVarOptionals.Type_.Value(), ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.Count.IsSet() {
+		localVarQueryParams.Add("count", parameterToString(localVarOptionals.Count.Value(), ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.Cursor.IsSet() {
+		localVarQueryParams.Add("cursor", parameterToString(localVarOptionals.Cursor.Value(), ""))
+	}
 	// to determine the Content-Type header
 	localVarHTTPContentTypes := []string{}
 
@@ -17,8 +17,10 @@ type CreateAddress struct {
 	// Second line of the address
 	Address2 string `json:"address2,omitempty"`
 	City     string `json:"city"`
-	// two character code of a US state
+	// State, province or region. US states and Canadian provinces are their two character code, other countries accept any region.
 	State      string `json:"state"`
+	// Postal code, which is checked against the format used in the country when it's known
 	PostalCode string `json:"postalCode"`
+	// ISO 3166-1 alpha-2 country code. Alpha-3 codes are accepted and stored as their alpha-2 code. Defaults to US.
 	Country    string `json:"country"`
 }
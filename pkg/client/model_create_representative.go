@@ -19,6 +19,8 @@ type CreateRepresentative struct {
 	JobTitle string `json:"jobTitle,omitempty"`
 	// Legal date of birth
 	BirthDate string `json:"birthDate,omitempty"`
+	// Percentage of the business owned by this representative
+	OwnershipPercentage float32 `json:"ownershipPercentage,omitempty"`
 	// Customer Representative's Social Security Number (SSN)
 	SSN       string          `json:"SSN,omitempty"`
 	Phones    []CreatePhone   `json:"phones,omitempty"`
@@ -40,20 +40,26 @@ type Customer struct {
 	SICCode   SicCode   `json:"SICCode,omitempty"`
 	NAICSCode NaicsCode `json:"NAICSCode,omitempty"`
 	// Legal date of birth
-	BirthDate string         `json:"birthDate,omitempty"`
-	Status    CustomerStatus `json:"status"`
+	BirthDate string `json:"birthDate,omitempty"`
+	// Age in years computed from birthDate
+	Age    int32          `json:"age,omitempty"`
+	Status CustomerStatus `json:"status"`
 	// Primary email address of customer name@domain.com
 	Email string `json:"email"`
 	// Company Website for business type customers
 	Website string `json:"website,omitempty"`
 	// Date business was established for business type customers
-	DateBusinessEstablished string           `json:"dateBusinessEstablished,omitempty"`
-	Phones                  []Phone          `json:"phones,omitempty"`
-	Addresses               []Address        `json:"addresses,omitempty"`
-	Representatives         []Representative `json:"representatives,omitempty"`
+	DateBusinessEstablished string `json:"dateBusinessEstablished,omitempty"`
+	// Preferred language of the Customer as a BCP 47 tag, used to localize emails
+	Locale          string           `json:"locale,omitempty"`
+	Phones          []Phone          `json:"phones,omitempty"`
+	Addresses       []Address        `json:"addresses,omitempty"`
+	Representatives []Representative `json:"representatives,omitempty"`
 	// Map of unique keys associated to values to act as foreign key relationships or arbitrary data associated to a Customer.
-	Metadata  map[string]string `json:"metadata,omitempty"`
-	CreatedAt time.Time         `json:"createdAt"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Tax forms, any one of which the Customer needs on file before they can be Verified. Empty when no tax form is required or one is on file.
+	MissingTaxForms []string  `json:"missingTaxForms,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
 	// Last time the object was modified
 	LastModified time.Time `json:"lastModified"`
 }
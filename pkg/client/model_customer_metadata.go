@@ -9,8 +9,16 @@
 
 package client
 
+import (
+	"time"
+)
+
 // CustomerMetadata struct for CustomerMetadata
 type CustomerMetadata struct {
 	// Map of unique keys associated to values to act as foreign key relationships or arbitrary data associated to a Customer.
 	Metadata map[string]string `json:"metadata"`
+	// When the first of the current keys was added
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	// Last time a key was added or its value changed
+	LastModified *time.Time `json:"lastModified,omitempty"`
 }
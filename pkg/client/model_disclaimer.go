@@ -21,6 +21,10 @@ type Disclaimer struct {
 	Text string `json:"text"`
 	// Optional documentID which references a Document included in the disclaimer
 	DocumentID string `json:"documentID,omitempty"`
-	// Timestamp if disclaimer has been accepted, a timestamp before the year 2000 indicates no acceptance.
+	// Version of the disclaimer's text, which increases each time the text is changed
+	Version int32 `json:"version,omitempty"`
+	// SHA-256 hash of the text, hex encoded
+	Hash string `json:"hash,omitempty"`
+	// Timestamp if the current version of the disclaimer has been accepted, a timestamp before the year 2000 indicates no acceptance.
 	AcceptedAt time.Time `json:"acceptedAt,omitempty"`
 }
@@ -22,4 +22,10 @@ type Document struct {
 	// Optional array of errors encountered dring automated parsing.
 	ParseErrors []string  `json:"parseErrors,omitempty"`
 	UploadedAt  time.Time `json:"uploadedAt"`
+	// Timestamp of when the document was deleted, only included when listing deleted documents.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// Optional timestamp of when the document, such as a driver's license or passport, expires.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// True when expiresAt has passed and a new document should be requested.
+	Expired bool `json:"expired,omitempty"`
 }
@@ -11,6 +11,12 @@ package client
 
 // Error struct for Error
 type Error struct {
-	// An error message describing the problem intended for humans.
+	// An error message describing the problem intended for humans. Kept for older clients, it matches message.
 	Error string `json:"error"`
+	// Stable machine readable code clients can branch on
+	Code string `json:"code"`
+	// An error message describing the problem intended for humans.
+	Message string `json:"message,omitempty"`
+	// Optional context about the error
+	Details map[string]string `json:"details,omitempty"`
 }
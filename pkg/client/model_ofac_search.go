@@ -19,11 +19,17 @@ type OfacSearch struct {
 	EntityID string `json:"entityID"`
 	// If the search resulted in a positive match against a sanctions list and should be blocked from making transfers or other operations.
 	Blocked bool `json:"blocked"`
+	// If the search matched above the review threshold, but not the match threshold, and should be reviewed by an operator.
+	ReviewRequired bool `json:"reviewRequired"`
 	// Name of the SDN entity
 	SdnName string `json:"sdnName"`
 	// SDN entity type
 	SdnType string `json:"sdnType"`
 	// Percentage of similarity between the Customer name and this OFAC entity
-	Match     float32   `json:"match"`
-	CreatedAt time.Time `json:"createdAt"`
+	Match float32 `json:"match"`
+	// Match percentage above which the search is blocked
+	MatchThreshold float32 `json:"matchThreshold,omitempty"`
+	// Match percentage above which the search requires a review
+	ReviewThreshold float32   `json:"reviewThreshold,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
 }
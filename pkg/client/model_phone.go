@@ -9,12 +9,19 @@
 
 package client
 
+import (
+	"time"
+)
+
 // Phone struct for Phone
 type Phone struct {
 	// phone number
 	Number    string    `json:"number"`
 	OwnerType OwnerType `json:"ownerType,omitempty"`
 	// phone number has been validated to connect with customer
-	Valid bool      `json:"valid"`
-	Type  PhoneType `json:"type"`
+	Valid     bool      `json:"valid"`
+	Type      PhoneType `json:"type"`
+	CreatedAt time.Time `json:"createdAt,omitempty"`
+	// Last time the object was modified
+	LastModified time.Time `json:"lastModified,omitempty"`
 }
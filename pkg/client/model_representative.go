@@ -26,8 +26,11 @@ type Representative struct {
 	// Job title of this representative
 	JobTitle string `json:"jobTitle,omitempty"`
 	// Legal date of birth
-	BirthDate string    `json:"birthDate,omitempty"`
-	CreatedAt time.Time `json:"createdAt"`
+	BirthDate string `json:"birthDate,omitempty"`
+	// Percentage of the business owned by this representative
+	OwnershipPercentage float32              `json:"ownershipPercentage,omitempty"`
+	Status              RepresentativeStatus `json:"status,omitempty"`
+	CreatedAt           time.Time            `json:"createdAt"`
 	// Last time the object was modified
 	LastModified time.Time `json:"lastModified"`
 	// Customer Representative's Social Security Number (SSN)
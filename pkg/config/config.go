@@ -1,15 +1,40 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/moov-io/base/database"
 )
 
 type Config struct {
 	Database *database.DatabaseConfig
+
+	// Connections limits the connection pool of every database type
+	Connections database.ConnectionsConfig
+
+	// Replica is an optional MySQL read replica
+	Replica ReplicaConfig
+
+	// SQLitePath is the SQLite database file, it replaces SQLITE_DB_PATH for programs embedding
+	// the server. Either one must pass ValidSQLitePath.
+	SQLitePath string
+}
+
+// DefaultSQLitePath is used when neither SQLitePath or SQLITE_DB_PATH are set
+const DefaultSQLitePath = "customers.db"
+
+// ReplicaConfig is a MySQL read replica which reads are sent to instead of the primary
+type ReplicaConfig struct {
+	// Address of the replica, it shares the user, password and database name of the primary
+	Address string
+
+	// ReadAfterWrite is how long reads go to the primary after a write, covering replication lag
+	ReadAfterWrite time.Duration
 }
 
 func New() *Config {
@@ -22,11 +47,13 @@ func (c *Config) Load() error {
 	dbType := strings.ToLower(os.Getenv("DATABASE_TYPE"))
 	switch dbType {
 	case "sqlite", "":
-		path := os.Getenv("SQLITE_DB_PATH")
-		if path == "" || strings.Contains(path, "..") {
-			// set default if empty or trying to escape
-			// don't filepath.ABS to avoid full-fs reads
-			path = "customers.db"
+		path := c.SQLitePath
+		if path == "" {
+			path = os.Getenv("SQLITE_DB_PATH")
+		}
+		path, err := ValidSQLitePath(path)
+		if err != nil {
+			return fmt.Errorf("invalid SQLite path: %v", err)
 		}
 
 		c.Database.SQLite = &database.SQLiteConfig{
@@ -34,16 +61,66 @@ func (c *Config) Load() error {
 		}
 
 	case "mysql":
+		password, err := Secret("MYSQL_PASSWORD")
+		if err != nil {
+			return err
+		}
 		c.Database.MySQL = &database.MySQLConfig{
 			Address:  os.Getenv("MYSQL_ADDRESS"),
 			User:     os.Getenv("MYSQL_USER"),
-			Password: os.Getenv("MYSQL_PASSWORD"),
+			Password: password,
 		}
 		c.Database.DatabaseName = os.Getenv("MYSQL_DATABASE")
 
+		c.Replica.Address = os.Getenv("MYSQL_REPLICA_ADDRESS")
+		if v := os.Getenv("MYSQL_REPLICA_READ_AFTER_WRITE"); v != "" {
+			dur, err := time.ParseDuration(v)
+			if err != nil || dur < 0 {
+				return fmt.Errorf("invalid MYSQL_REPLICA_READ_AFTER_WRITE: %q", v)
+			}
+			c.Replica.ReadAfterWrite = dur
+		} else {
+			c.Replica.ReadAfterWrite = time.Second
+		}
+
 	default:
 		return fmt.Errorf("unknown database type: %q", dbType)
 	}
 
+	conns, err := loadConnections()
+	if err != nil {
+		return err
+	}
+	c.Connections = conns
+
 	return nil
 }
+
+// ValidSQLitePath cleans the path of a SQLite database file. Relative paths must stay within the
+// working directory once cleaned, and absolute paths can't contain ".." at all because where they
+// end up depends on the rest of the filesystem. An empty path is DefaultSQLitePath.
+func ValidSQLitePath(path string) (string, error) {
+	if path == "" {
+		return DefaultSQLitePath, nil
+	}
+	if strings.ContainsRune(path, 0) {
+		return "", errors.New("path contains a NUL byte")
+	}
+	if strings.HasSuffix(path, "/") || strings.HasSuffix(path, string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is a directory", path)
+	}
+
+	cleaned := filepath.Clean(path)
+	if filepath.IsAbs(cleaned) {
+		for _, elem := range strings.Split(filepath.ToSlash(path), "/") {
+			if elem == ".." {
+				return "", fmt.Errorf("%q contains ..", path)
+			}
+		}
+		return cleaned, nil
+	}
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is outside of the working directory", path)
+	}
+	return cleaned, nil
+}
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/moov-io/base/database"
 	"github.com/stretchr/testify/require"
@@ -20,6 +24,24 @@ func TestConfig(t *testing.T) {
 		require.Equal(t, conf.Database.SQLite.Path, "customers.db")
 	})
 
+	t.Run("SQLite path", func(t *testing.T) {
+		setenv(t, "DATABASE_TYPE", "sqlite")
+		setenv(t, "SQLITE_DB_PATH", "./data//customers.db")
+
+		conf := New()
+		require.NoError(t, conf.Load())
+		require.Equal(t, filepath.FromSlash("data/customers.db"), conf.Database.SQLite.Path)
+
+		// set programmatically
+		conf = New()
+		conf.SQLitePath = "customers/embedded.db"
+		require.NoError(t, conf.Load())
+		require.Equal(t, filepath.FromSlash("customers/embedded.db"), conf.Database.SQLite.Path)
+
+		setenv(t, "SQLITE_DB_PATH", "../customers.db")
+		require.Error(t, New().Load())
+	})
+
 	t.Run("When DATABASE_TYPE is set to mysql", func(t *testing.T) {
 		setenv(t, "DATABASE_TYPE", "mysql")
 		setenv(t, "MYSQL_USER", "user")
@@ -39,6 +61,102 @@ func TestConfig(t *testing.T) {
 		}
 		require.Equal(t, want, conf.Database.MySQL)
 	})
+
+	t.Run("MySQL read replica", func(t *testing.T) {
+		setenv(t, "DATABASE_TYPE", "mysql")
+		setenv(t, "MYSQL_REPLICA_ADDRESS", "tcp(replica:3306)")
+
+		conf := New()
+		require.NoError(t, conf.Load())
+		require.Equal(t, ReplicaConfig{Address: "tcp(replica:3306)", ReadAfterWrite: time.Second}, conf.Replica)
+
+		setenv(t, "MYSQL_REPLICA_READ_AFTER_WRITE", "250ms")
+		require.NoError(t, conf.Load())
+		require.Equal(t, 250*time.Millisecond, conf.Replica.ReadAfterWrite)
+
+		setenv(t, "MYSQL_REPLICA_READ_AFTER_WRITE", "soon")
+		require.Error(t, New().Load())
+	})
+
+	t.Run("Connection pool defaults", func(t *testing.T) {
+		setenv(t, "DATABASE_TYPE", "")
+
+		conf := New()
+		require.NoError(t, conf.Load())
+
+		want := database.ConnectionsConfig{
+			MaxOpen:     16,
+			MaxIdle:     4,
+			MaxLifetime: 5 * time.Minute,
+		}
+		require.Equal(t, want, conf.Connections)
+	})
+
+	t.Run("Connection pool is configured", func(t *testing.T) {
+		setenv(t, "DATABASE_TYPE", "")
+		setenv(t, "DATABASE_MAX_OPEN_CONNECTIONS", "2")
+		setenv(t, "DATABASE_MAX_IDLE_CONNECTIONS", "10")
+		setenv(t, "DATABASE_CONN_MAX_LIFETIME", "1m")
+
+		conf := New()
+		require.NoError(t, conf.Load())
+
+		want := database.ConnectionsConfig{
+			MaxOpen:     2,
+			MaxIdle:     2, // limited to MaxOpen
+			MaxLifetime: time.Minute,
+		}
+		require.Equal(t, want, conf.Connections)
+
+		db := database.CreateTestSQLiteDB(t)
+		defer db.Close()
+
+		ApplyConnections(db.DB, conf.Connections)
+		require.Equal(t, 2, db.DB.Stats().MaxOpenConnections)
+	})
+
+	t.Run("Invalid connection pool settings", func(t *testing.T) {
+		setenv(t, "DATABASE_TYPE", "")
+		setenv(t, "DATABASE_MAX_OPEN_CONNECTIONS", "zero")
+
+		require.Error(t, New().Load())
+	})
+}
+
+func TestValidSQLitePath(t *testing.T) {
+	valid := map[string]string{
+		"":                     DefaultSQLitePath,
+		"customers.db":         "customers.db",
+		"./customers.db":       "customers.db",
+		"data/../customers.db": "customers.db",
+		"data//./customers.db": "data/customers.db",
+		"my..customers.db":     "my..customers.db",
+		"..customers.db":       "..customers.db",
+	}
+	invalid := []string{
+		"..",
+		"../customers.db",
+		"data/../../customers.db",
+		"./../customers.db",
+		".",
+		"data/",
+		"customers\x00.db",
+	}
+	if runtime.GOOS != "windows" {
+		valid["/tmp/customers.db"] = "/tmp/customers.db"
+		valid["/tmp//data/customers.db"] = "/tmp/data/customers.db"
+		invalid = append(invalid, "/tmp/../etc/customers.db", "/../customers.db")
+	}
+
+	for path, want := range valid {
+		got, err := ValidSQLitePath(path)
+		require.NoError(t, err, path)
+		require.Equal(t, filepath.FromSlash(want), got, path)
+	}
+	for _, path := range invalid {
+		_, err := ValidSQLitePath(path)
+		require.Error(t, err, path)
+	}
 }
 
 // setenv restores env variables after test
@@ -55,3 +173,49 @@ func setenv(t *testing.T, key, val string) {
 
 	os.Setenv(key, val)
 }
+
+func TestSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "customers-secrets")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "secret")
+	require.NoError(t, ioutil.WriteFile(path, []byte("from-file\n"), 0600))
+
+	setenv(t, "CUSTOMERS_TEST_SECRET", "from-env")
+	setenv(t, "CUSTOMERS_TEST_SECRET_FILE", "")
+	v, err := Secret("CUSTOMERS_TEST_SECRET")
+	require.NoError(t, err)
+	require.Equal(t, "from-env", v)
+
+	setenv(t, "CUSTOMERS_TEST_SECRET_FILE", path)
+	_, err = Secret("CUSTOMERS_TEST_SECRET")
+	require.Error(t, err)
+
+	setenv(t, "CUSTOMERS_TEST_SECRET", "")
+	v, err = Secret("CUSTOMERS_TEST_SECRET")
+	require.NoError(t, err)
+	require.Equal(t, "from-file", v)
+
+	setenv(t, "CUSTOMERS_TEST_SECRET_FILE", filepath.Join(dir, "missing"))
+	_, err = Secret("CUSTOMERS_TEST_SECRET")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "CUSTOMERS_TEST_SECRET_FILE")
+}
+
+func TestConfig__mysqlPasswordFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "customers-secrets")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "mysql-password")
+	require.NoError(t, ioutil.WriteFile(path, []byte("password\n"), 0600))
+
+	setenv(t, "DATABASE_TYPE", "mysql")
+	setenv(t, "MYSQL_PASSWORD", "")
+	setenv(t, "MYSQL_PASSWORD_FILE", path)
+
+	conf := New()
+	require.NoError(t, conf.Load())
+	require.Equal(t, "password", conf.Database.MySQL.Password)
+}
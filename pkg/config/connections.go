@@ -0,0 +1,83 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/moov-io/base/database"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultMaxOpenConnections = 16
+	defaultMaxIdleConnections = 4
+	defaultConnMaxLifetime    = 5 * time.Minute
+)
+
+var (
+	connectionLimits = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Name: "database_connection_limits",
+		Help: "Configured limits of the database connection pool.",
+	}, []string{"limit"})
+)
+
+func loadConnections() (database.ConnectionsConfig, error) {
+	conns := database.ConnectionsConfig{
+		MaxOpen:     defaultMaxOpenConnections,
+		MaxIdle:     defaultMaxIdleConnections,
+		MaxLifetime: defaultConnMaxLifetime,
+	}
+
+	// MYSQL_MAX_CONNECTIONS was the only pool setting before and is still read
+	maxOpen := os.Getenv("DATABASE_MAX_OPEN_CONNECTIONS")
+	if maxOpen == "" {
+		maxOpen = os.Getenv("MYSQL_MAX_CONNECTIONS")
+	}
+	if maxOpen != "" {
+		n, err := strconv.Atoi(maxOpen)
+		if err != nil || n <= 0 {
+			return conns, fmt.Errorf("invalid DATABASE_MAX_OPEN_CONNECTIONS: %q", maxOpen)
+		}
+		conns.MaxOpen = n
+	}
+	if v := os.Getenv("DATABASE_MAX_IDLE_CONNECTIONS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return conns, fmt.Errorf("invalid DATABASE_MAX_IDLE_CONNECTIONS: %q", v)
+		}
+		conns.MaxIdle = n
+	}
+	if v := os.Getenv("DATABASE_CONN_MAX_LIFETIME"); v != "" {
+		dur, err := time.ParseDuration(v)
+		if err != nil || dur < 0 {
+			return conns, fmt.Errorf("invalid DATABASE_CONN_MAX_LIFETIME: %q", v)
+		}
+		conns.MaxLifetime = dur
+	}
+
+	if conns.MaxIdle > conns.MaxOpen {
+		conns.MaxIdle = conns.MaxOpen
+	}
+	return conns, nil
+}
+
+// ApplyConnections configures the connection pool of db and records the limits in
+// Prometheus metrics. A MaxIdle or MaxLifetime of zero disables idle connections and
+// connection expiry respectively.
+func ApplyConnections(db *sql.DB, conns database.ConnectionsConfig) {
+	if db == nil {
+		return
+	}
+
+	db.SetMaxOpenConns(conns.MaxOpen)
+	db.SetMaxIdleConns(conns.MaxIdle)
+	db.SetConnMaxLifetime(conns.MaxLifetime)
+
+	connectionLimits.With("limit", "max_open").Set(float64(conns.MaxOpen))
+	connectionLimits.With("limit", "max_idle").Set(float64(conns.MaxIdle))
+	connectionLimits.With("limit", "max_lifetime_seconds").Set(conns.MaxLifetime.Seconds())
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// SecretFileSuffix is appended to the name of an environment variable holding a secret to read the
+// secret from a file instead, like Docker and Kubernetes secrets. SSN_SECRET_KEY_FILE=/run/secrets/ssn
+// is read in place of SSN_SECRET_KEY. Secrets in files don't show up in the process's environment.
+const SecretFileSuffix = "_FILE"
+
+// Secret returns the value of the environment variable key or, when key+SecretFileSuffix is set, the
+// contents of that file without trailing newlines. It's an error to set both.
+func Secret(key string) (string, error) {
+	path := os.Getenv(key + SecretFileSuffix)
+	if path == "" {
+		return os.Getenv(key), nil
+	}
+	if os.Getenv(key) != "" {
+		return "", fmt.Errorf("only one of %s and %s%s can be set", key, key, SecretFileSuffix)
+	}
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s%s: %v", key, SecretFileSuffix, err)
+	}
+	return strings.TrimRight(string(bs), "\r\n"), nil
+}
@@ -17,7 +17,6 @@ import (
 	"strings"
 	"time"
 
-	moovhttp "github.com/moov-io/base/http"
 	"github.com/moov-io/customers/pkg/client"
 	"github.com/moov-io/customers/pkg/documents/storage"
 	"github.com/moov-io/customers/pkg/route"
@@ -71,7 +70,7 @@ func getOrganizationConfig(logger log.Logger, repo Repository) http.HandlerFunc
 		}
 		cfg, err := repo.Get(organization)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -83,6 +82,10 @@ func updateOrganizationConfig(logger log.Logger, repo Repository) http.HandlerFu
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
 
+		if !route.RequireScope(w, r, route.ScopeAdmin) {
+			return
+		}
+
 		organization := route.GetOrganization(w, r)
 		if organization == "" {
 			return
@@ -90,12 +93,12 @@ func updateOrganizationConfig(logger log.Logger, repo Repository) http.HandlerFu
 
 		var body client.OrganizationConfiguration
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		cfg, err := repo.Update(organization, &body)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -116,7 +119,7 @@ func getOrganizationLogo(logger log.Logger, repo Repository, bucketFactory stora
 		bucket, err := bucketFactory()
 		if err != nil {
 			logger.LogErrorf("problem retrieving logo image: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		defer bucket.Close()
@@ -125,18 +128,18 @@ func getOrganizationLogo(logger log.Logger, repo Repository, bucketFactory stora
 		if err != nil {
 			if gcerrors.Code(err) == gcerrors.NotFound {
 				logger.LogErrorf("logo file not found: %v", err)
-				http.NotFound(w, r)
+				route.NotFound(w, r)
 				return
 			}
 
-			moovhttp.Problem(w, logger.LogErrorf("error retrieving logo file: %v", err).Err())
+			route.Problem(w, logger.LogErrorf("error retrieving logo file: %v", err).Err())
 			return
 		}
 		defer rdr.Close()
 
 		fBytes, err := ioutil.ReadAll(rdr)
 		if err != nil || fBytes == nil {
-			moovhttp.Problem(w, logger.LogErrorf("problem reading logo file: %v", err).Err())
+			route.Problem(w, logger.LogErrorf("problem reading logo file: %v", err).Err())
 			return
 		}
 
@@ -148,6 +151,10 @@ func getOrganizationLogo(logger log.Logger, repo Repository, bucketFactory stora
 
 func uploadOrganizationLogo(logger log.Logger, repo Repository, bucketFactory storage.BucketFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !route.RequireScope(w, r, route.ScopeAdmin) {
+			return
+		}
+
 		organization := route.GetOrganization(w, r)
 		if organization == "" {
 			logger.Log("upload logo called with no organization header")
@@ -157,7 +164,7 @@ func uploadOrganizationLogo(logger log.Logger, repo Repository, bucketFactory st
 		file, _, err := r.FormFile("file")
 		if file == nil || err != nil {
 			logger.LogErrorf("%s: %v", errMissingFile.Error(), err)
-			moovhttp.Problem(w, errMissingFile)
+			route.Problem(w, errMissingFile)
 			return
 		}
 		defer file.Close()
@@ -166,21 +173,21 @@ func uploadOrganizationLogo(logger log.Logger, repo Repository, bucketFactory st
 		buf := make([]byte, 512)
 		if _, err := file.Read(buf); err != nil && err != io.EOF {
 			logger.LogErrorf("problem reading file: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		contentType := http.DetectContentType(buf)
 		if !allowedContentTypes[contentType] {
 			logger.Set("contentType", log.String(contentType)).Log("unsupported content type for logo image file")
-			moovhttp.Problem(w, errUnsupportedType)
+			route.Problem(w, errUnsupportedType)
 			return
 		}
 
 		bucket, err := bucketFactory()
 		if err != nil {
 			logger.LogErrorf("problem uploading logo image: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		defer bucket.Close()
@@ -194,7 +201,7 @@ func uploadOrganizationLogo(logger log.Logger, repo Repository, bucketFactory st
 		})
 		if err != nil {
 			logger.LogErrorf("problem uploading logo image: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		defer writer.Close()
@@ -202,7 +209,7 @@ func uploadOrganizationLogo(logger log.Logger, repo Repository, bucketFactory st
 		written, err := io.Copy(writer, io.LimitReader(io.MultiReader(bytes.NewReader(buf), file), maxImageSize))
 		if err != nil || written == 0 {
 			logger.LogErrorf("problem uploading logo image: %v", err)
-			moovhttp.Problem(w, fmt.Errorf("problem writing file - wrote %d bytes with error=%v", written, err))
+			route.Problem(w, fmt.Errorf("problem writing file - wrote %d bytes with error=%v", written, err))
 			return
 		}
 
@@ -19,19 +19,21 @@ var (
 	ErrAddressTypeDuplicate = errors.New("customer already has an address with type 'primary'")
 )
 
-func AddCustomerAddressRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository) {
+func AddCustomerAddressRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository, verifier AddressVerifier) {
 	logger = logger.Set("package", log.String("customers"))
 
-	r.Methods("POST").Path("/customers/{customerID}/addresses").HandlerFunc(createAddress(logger, client.OWNERTYPE_CUSTOMER, repo))
-	r.Methods("PUT").Path("/customers/{customerID}/addresses/{addressID}").HandlerFunc(updateAddress(logger, client.OWNERTYPE_CUSTOMER, repo))
+	r.Methods("POST").Path("/customers/{customerID}/addresses").HandlerFunc(createAddress(logger, client.OWNERTYPE_CUSTOMER, repo, verifier))
+	r.Methods("PUT").Path("/customers/{customerID}/addresses/{addressID}").HandlerFunc(updateAddress(logger, client.OWNERTYPE_CUSTOMER, repo, verifier))
 	r.Methods("DELETE").Path("/customers/{customerID}/addresses/{addressID}").HandlerFunc(deleteAddress(logger, client.OWNERTYPE_CUSTOMER, repo))
+	r.Methods("PUT").Path("/customers/{customerID}/addresses/{addressID}/primary").HandlerFunc(setPrimaryAddress(logger, client.OWNERTYPE_CUSTOMER, repo))
 
-	r.Methods("POST").Path("/customers/{customerID}/representatives/{representativeID}/addresses").HandlerFunc(createAddress(logger, client.OWNERTYPE_REPRESENTATIVE, repo))
-	r.Methods("PUT").Path("/customers/{customerID}/representatives/{representativeID}/addresses/{addressID}").HandlerFunc(updateAddress(logger, client.OWNERTYPE_REPRESENTATIVE, repo))
+	r.Methods("POST").Path("/customers/{customerID}/representatives/{representativeID}/addresses").HandlerFunc(createAddress(logger, client.OWNERTYPE_REPRESENTATIVE, repo, verifier))
+	r.Methods("PUT").Path("/customers/{customerID}/representatives/{representativeID}/addresses/{addressID}").HandlerFunc(updateAddress(logger, client.OWNERTYPE_REPRESENTATIVE, repo, verifier))
 	r.Methods("DELETE").Path("/customers/{customerID}/representatives/{representativeID}/addresses/{addressID}").HandlerFunc(deleteAddress(logger, client.OWNERTYPE_REPRESENTATIVE, repo))
+	r.Methods("PUT").Path("/customers/{customerID}/representatives/{representativeID}/addresses/{addressID}/primary").HandlerFunc(setPrimaryAddress(logger, client.OWNERTYPE_REPRESENTATIVE, repo))
 }
 
-func createAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerRepository) http.HandlerFunc {
+func createAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerRepository, verifier AddressVerifier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		customerID, requestID := route.GetCustomerID(w, r), moovhttp.GetRequestID(r)
 		if customerID == "" {
@@ -47,13 +49,13 @@ func createAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerR
 		var addresses []client.Address
 		if ownerType == client.OWNERTYPE_CUSTOMER {
 			ownerID = customerID
-			cust, err := repo.GetCustomer(customerID, organization)
+			cust, err := repo.GetCustomer(r.Context(), customerID, organization)
 			if err != nil {
-				moovhttp.Problem(w, err)
+				route.Problem(w, err)
 				return
 			}
 			if cust == nil {
-				w.WriteHeader(http.StatusNotFound)
+				route.NotFound(w, r)
 				return
 			}
 			addresses = cust.Addresses
@@ -62,13 +64,13 @@ func createAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerR
 			if representativeID == "" {
 				return
 			}
-			rep, err := repo.GetRepresentative(representativeID)
+			rep, err := repo.GetRepresentative(r.Context(), representativeID)
 			if err != nil {
-				moovhttp.Problem(w, err)
+				route.Problem(w, err)
 				return
 			}
 			if rep == nil {
-				http.NotFound(w, r)
+				route.NotFound(w, r)
 				return
 			}
 			addresses = rep.Addresses
@@ -78,7 +80,7 @@ func createAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerR
 
 		var reqAddr address
 		if err := json.NewDecoder(r.Body).Decode(&reqAddr); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -96,24 +98,29 @@ func createAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerR
 				Country:    addr.Country,
 			})
 		}
-		if err := validateAddresses(append(addrs, reqAddr)); err != nil {
-			moovhttp.Problem(w, err)
+		addrs = append(addrs, reqAddr)
+		if err := validateAddresses(addrs); err != nil {
+			route.Problem(w, err)
 			return
 		}
+		reqAddr = addrs[len(addrs)-1]
 
-		if err := repo.addAddress(ownerID, ownerType, reqAddr); err != nil {
-			moovhttp.Problem(w, err)
+		reqAddr.validated, _ = verifyAddress(logger, verifier, &reqAddr)
+
+		if err := repo.addAddress(r.Context(), ownerID, ownerType, reqAddr); err != nil {
+			route.Problem(w, err)
 			return
 		}
 
 		logger.Logf("added address for %s=%s", string(ownerType), ownerID)
-		respondWithCustomer(logger, w, customerID, organization, requestID, repo)
+		respondWithCustomer(r.Context(), logger, w, customerID, organization, requestID, repo)
 	}
 }
 
-func updateAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerRepository) http.HandlerFunc {
+func updateAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerRepository, verifier AddressVerifier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 
 		customerID := route.GetCustomerID(w, r)
 		if customerID == "" {
@@ -142,28 +149,28 @@ func updateAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerR
 
 		var req updateAddressRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		if err := req.validate(); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, route.Validation(err))
 			return
 		}
 
 		if req.Type == "primary" {
 			var addresses []client.Address
 			if ownerType == client.OWNERTYPE_CUSTOMER {
-				cust, err := repo.GetCustomer(customerID, organization)
+				cust, err := repo.GetCustomer(r.Context(), customerID, organization)
 				if err != nil {
-					moovhttp.Problem(w, err)
+					route.Problem(w, err)
 					return
 				}
 				addresses = cust.Addresses
 			} else {
-				rep, err := repo.GetRepresentative(representativeID)
+				rep, err := repo.GetRepresentative(r.Context(), representativeID)
 				if err != nil {
-					moovhttp.Problem(w, err)
+					route.Problem(w, err)
 					return
 				}
 				addresses = rep.Addresses
@@ -172,27 +179,33 @@ func updateAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerR
 
 			for _, addr := range addresses {
 				if addr.Type == "primary" && addr.AddressID != addressId {
-					moovhttp.Problem(w, ErrAddressTypeDuplicate)
+					route.Problem(w, ErrAddressTypeDuplicate)
 					return
 				}
 			}
 		}
 
-		if err := repo.updateAddress(ownerID, addressId, ownerType, req); err != nil {
+		// when a verifier supports the address's country it decides if the address is valid
+		if valid, verified := verifyAddress(logger, verifier, &req.address); verified {
+			req.Validated = valid
+		}
+
+		if err := repo.updateAddress(r.Context(), ownerID, addressId, ownerType, req); err != nil {
 			logger.LogErrorf("error updating %s's address: %s=%s address=%s: %v", string(ownerType), string(ownerType), ownerID, addressId, err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		logger.Logf("updating address=%s for %s=%s", addressId, string(ownerType), customerID)
 
-		respondWithCustomer(logger, w, customerID, organization, requestID, repo)
+		respondWithCustomer(r.Context(), logger, w, customerID, organization, requestID, repo)
 	}
 }
 
 func deleteAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 
 		customerID, addressId := route.GetCustomerID(w, r), getAddressID(w, r)
 		if customerID == "" {
@@ -212,10 +225,10 @@ func deleteAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerR
 			ownerID = representativeID
 		}
 
-		err := repo.deleteAddress(ownerID, ownerType, addressId)
+		err := repo.deleteAddress(r.Context(), ownerID, ownerType, addressId)
 		if err != nil {
 			logger.LogErrorf("error deleting %s's address: %s=%s address=%s: %v", string(ownerType), string(ownerType), customerID, addressId, err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -225,11 +238,69 @@ func deleteAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerR
 	}
 }
 
+// setPrimaryAddress makes an existing address the owner's primary address, their previous primary
+// address becomes secondary.
+func setPrimaryAddress(logger log.Logger, ownerType client.OwnerType, repo CustomerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, addressID := route.GetCustomerID(w, r), getAddressID(w, r)
+		if customerID == "" || addressID == "" {
+			return
+		}
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		cust, err := repo.GetCustomer(r.Context(), customerID, organization)
+		if err != nil && err != errCustomerNotFound {
+			route.Problem(w, err)
+			return
+		}
+		if cust == nil {
+			route.NotFound(w, r)
+			return
+		}
+
+		ownerID := customerID
+		if ownerType == client.OWNERTYPE_REPRESENTATIVE {
+			ownerID = route.GetRepresentativeID(w, r)
+			if ownerID == "" {
+				return
+			}
+			rep, err := repo.GetRepresentative(r.Context(), ownerID)
+			if err != nil {
+				route.Problem(w, err)
+				return
+			}
+			if rep == nil || rep.CustomerID != customerID {
+				route.NotFound(w, r)
+				return
+			}
+		}
+
+		if err := repo.setPrimaryAddress(r.Context(), ownerID, ownerType, addressID); err != nil {
+			if err == errAddressNotFound {
+				route.NotFound(w, r)
+				return
+			}
+			logger.LogErrorf("error setting primary address=%s for %s=%s: %v", addressID, string(ownerType), ownerID, err)
+			route.Problem(w, err)
+			return
+		}
+
+		logger.Logf("set primary address=%s for %s=%s", addressID, string(ownerType), ownerID)
+		respondWithCustomer(r.Context(), logger, w, customerID, organization, moovhttp.GetRequestID(r), repo)
+	}
+}
+
 func getAddressID(w http.ResponseWriter, r *http.Request) string {
 	varName := "addressID"
 	v, ok := mux.Vars(r)[varName]
 	if !ok || v == "" {
-		moovhttp.Problem(w, fmt.Errorf("path variable %s not found in url", varName))
+		route.Problem(w, fmt.Errorf("path variable %s not found in url", varName))
 		return ""
 	}
 	return v
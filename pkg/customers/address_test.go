@@ -2,6 +2,7 @@ package customers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -25,7 +26,7 @@ func TestCustomers__addAddress(t *testing.T) {
 	}
 	cust, _, _ := customerRequest.asCustomer(testCustomerSSNStorage(t))
 	organization := "organization"
-	err := repo.CreateCustomer(cust, organization)
+	err := repo.CreateCustomer(context.Background(), cust, organization)
 	require.NoError(t, err)
 
 	addrPayload := address{
@@ -46,7 +47,7 @@ func TestCustomers__addAddress(t *testing.T) {
 	req.Header.Set("x-request-id", "test")
 
 	router := mux.NewRouter()
-	AddCustomerAddressRoutes(log.NewNopLogger(), router, repo)
+	AddCustomerAddressRoutes(log.NewNopLogger(), router, repo, nil)
 	router.ServeHTTP(w, req)
 	require.Equal(t, http.StatusOK, w.Code)
 
@@ -62,9 +63,12 @@ func TestCustomers__addAddress(t *testing.T) {
 		City:       addrPayload.City,
 		State:      addrPayload.State,
 		PostalCode: addrPayload.PostalCode,
-		Country:    addrPayload.Country,
+		Country:    "US", // stored as the alpha-2 code
 	}
 
+	require.False(t, got.CreatedAt.IsZero())
+	require.Equal(t, got.CreatedAt, got.LastModified)
+	want.CreatedAt, want.LastModified = got.CreatedAt, got.LastModified
 	require.Equal(t, want, got)
 
 	/* Error on duplicate type primary */
@@ -92,7 +96,7 @@ func TestCustomers__updateAddress(t *testing.T) {
 	}
 	cust, _, _ := customerRequest.asCustomer(testCustomerSSNStorage(t))
 	organization := "organization"
-	err := repo.CreateCustomer(cust, organization)
+	err := repo.CreateCustomer(context.Background(), cust, organization)
 	require.NoError(t, err)
 
 	addrRequests := []address{
@@ -114,8 +118,8 @@ func TestCustomers__updateAddress(t *testing.T) {
 		},
 	}
 	for _, req := range addrRequests {
-		require.NoError(t, repo.addAddress(cust.CustomerID, client.OWNERTYPE_CUSTOMER, req))
-		cust, err = repo.GetCustomer(cust.CustomerID, organization) // refresh customer object after updating address
+		require.NoError(t, repo.addAddress(context.Background(), cust.CustomerID, client.OWNERTYPE_CUSTOMER, req))
+		cust, err = repo.GetCustomer(context.Background(), cust.CustomerID, organization) // refresh customer object after updating address
 		require.NoError(t, err)
 	}
 
@@ -146,7 +150,7 @@ func TestCustomers__updateAddress(t *testing.T) {
 	require.NoError(t, err)
 
 	router := mux.NewRouter()
-	AddCustomerAddressRoutes(log.NewNopLogger(), router, repo)
+	AddCustomerAddressRoutes(log.NewNopLogger(), router, repo, nil)
 
 	url := fmt.Sprintf("/customers/%s/addresses/%s", cust.CustomerID, primaryAddressID)
 	req, err := http.NewRequest("PUT", url, bytes.NewReader(payload))
@@ -177,9 +181,11 @@ func TestCustomers__updateAddress(t *testing.T) {
 		City:       updateReq.City,
 		State:      updateReq.State,
 		PostalCode: updateReq.PostalCode,
-		Country:    updateReq.Country,
+		Country:    "US",
 		Validated:  updateReq.Validated,
 	}
+	require.False(t, got.LastModified.Before(got.CreatedAt))
+	want.CreatedAt, want.LastModified = got.CreatedAt, got.LastModified
 	require.Equal(t, want, got)
 
 	/* Error when trying to update a secondary address to primary when one already exists */
@@ -210,7 +216,7 @@ func TestCustomers__deleteAddress(t *testing.T) {
 	}
 	cust, _, _ := customerRequest.asCustomer(testCustomerSSNStorage(t))
 	organization := "organization"
-	err := repo.CreateCustomer(cust, organization)
+	err := repo.CreateCustomer(context.Background(), cust, organization)
 	require.NoError(t, err)
 
 	address := address{
@@ -221,14 +227,14 @@ func TestCustomers__deleteAddress(t *testing.T) {
 		Country:    "USA",
 		Type:       "primary",
 	}
-	require.NoError(t, repo.addAddress(cust.CustomerID, client.OWNERTYPE_CUSTOMER, address))
+	require.NoError(t, repo.addAddress(context.Background(), cust.CustomerID, client.OWNERTYPE_CUSTOMER, address))
 
-	cust, err = repo.GetCustomer(cust.CustomerID, organization)
+	cust, err = repo.GetCustomer(context.Background(), cust.CustomerID, organization)
 	require.NoError(t, err)
 	addressID := cust.Addresses[0].AddressID
 
 	router := mux.NewRouter()
-	AddCustomerAddressRoutes(log.NewNopLogger(), router, repo)
+	AddCustomerAddressRoutes(log.NewNopLogger(), router, repo, nil)
 
 	url := fmt.Sprintf("/customers/%s/addresses/%s", cust.CustomerID, addressID)
 	req, err := http.NewRequest("DELETE", url, nil)
@@ -240,7 +246,7 @@ func TestCustomers__deleteAddress(t *testing.T) {
 	router.ServeHTTP(res, req)
 	require.Equal(t, http.StatusNoContent, res.Code)
 
-	cust, err = repo.GetCustomer(cust.CustomerID, organization)
+	cust, err = repo.GetCustomer(context.Background(), cust.CustomerID, organization)
 	require.NoError(t, err)
 	require.Empty(t, cust.Addresses)
 }
@@ -250,7 +256,7 @@ func TestCustomers__updateCustomerAddressFailure(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/customers/foo/address/bar", nil)
-	updateAddress(log.NewNopLogger(), client.OWNERTYPE_CUSTOMER, repo)(w, req)
+	updateAddress(log.NewNopLogger(), client.OWNERTYPE_CUSTOMER, repo, nil)(w, req)
 	w.Flush()
 
 	if w.Code != http.StatusBadRequest {
@@ -260,7 +266,7 @@ func TestCustomers__updateCustomerAddressFailure(t *testing.T) {
 	// try the proper HTTP verb
 	w = httptest.NewRecorder()
 	req = httptest.NewRequest("PUT", "/customers/foo/address/bar", nil)
-	updateAddress(log.NewNopLogger(), client.OWNERTYPE_CUSTOMER, repo)(w, req)
+	updateAddress(log.NewNopLogger(), client.OWNERTYPE_CUSTOMER, repo, nil)(w, req)
 	w.Flush()
 
 	if w.Code != http.StatusBadRequest {
@@ -278,7 +284,7 @@ func TestCustomerRepository__updateAddress(t *testing.T) {
 	}
 	cust, _, _ := customerRequest.asCustomer(testCustomerSSNStorage(t))
 	organization := "organization"
-	err := repo.CreateCustomer(cust, organization)
+	err := repo.CreateCustomer(context.Background(), cust, organization)
 	require.NoError(t, err)
 
 	addrRequest := address{
@@ -289,9 +295,9 @@ func TestCustomerRepository__updateAddress(t *testing.T) {
 		Country:    "USA",
 		Type:       "primary",
 	}
-	require.NoError(t, repo.addAddress(cust.CustomerID, client.OWNERTYPE_CUSTOMER, addrRequest))
+	require.NoError(t, repo.addAddress(context.Background(), cust.CustomerID, client.OWNERTYPE_CUSTOMER, addrRequest))
 
-	cust, err = repo.GetCustomer(cust.CustomerID, organization)
+	cust, err = repo.GetCustomer(context.Background(), cust.CustomerID, organization)
 	require.NoError(t, err)
 
 	addressID := cust.Addresses[0].AddressID
@@ -307,10 +313,10 @@ func TestCustomerRepository__updateAddress(t *testing.T) {
 		},
 		Validated: true,
 	}
-	err = repo.updateAddress(cust.CustomerID, addressID, client.OWNERTYPE_CUSTOMER, updateReq)
+	err = repo.updateAddress(context.Background(), cust.CustomerID, addressID, client.OWNERTYPE_CUSTOMER, updateReq)
 	require.NoError(t, err)
 
-	cust, err = repo.GetCustomer(cust.CustomerID, organization)
+	cust, err = repo.GetCustomer(context.Background(), cust.CustomerID, organization)
 	require.NoError(t, err)
 
 	require.Len(t, cust.Addresses, 1)
@@ -327,6 +333,7 @@ func TestCustomerRepository__updateAddress(t *testing.T) {
 		Validated:  updateReq.Validated,
 	}
 	got := cust.Addresses[0]
+	want.CreatedAt, want.LastModified = got.CreatedAt, got.LastModified
 	require.Equal(t, want, got)
 }
 
@@ -340,7 +347,7 @@ func TestCustomerRepository__deleteAddress(t *testing.T) {
 	}
 	cust, _, _ := customerRequest.asCustomer(testCustomerSSNStorage(t))
 	organization := "organization"
-	err := repo.CreateCustomer(cust, organization)
+	err := repo.CreateCustomer(context.Background(), cust, organization)
 	require.NoError(t, err)
 
 	address := address{
@@ -351,17 +358,80 @@ func TestCustomerRepository__deleteAddress(t *testing.T) {
 		Country:    "USA",
 		Type:       "primary",
 	}
-	require.NoError(t, repo.addAddress(cust.CustomerID, client.OWNERTYPE_CUSTOMER, address))
+	require.NoError(t, repo.addAddress(context.Background(), cust.CustomerID, client.OWNERTYPE_CUSTOMER, address))
 
-	cust, err = repo.GetCustomer(cust.CustomerID, organization)
+	cust, err = repo.GetCustomer(context.Background(), cust.CustomerID, organization)
 	require.NoError(t, err)
 
 	addressID := cust.Addresses[0].AddressID
-	err = repo.deleteAddress(cust.CustomerID, client.OWNERTYPE_CUSTOMER, addressID)
+	err = repo.deleteAddress(context.Background(), cust.CustomerID, client.OWNERTYPE_CUSTOMER, addressID)
 	require.NoError(t, err)
 
-	cust, err = repo.GetCustomer(cust.CustomerID, organization)
+	cust, err = repo.GetCustomer(context.Background(), cust.CustomerID, organization)
 	require.NoError(t, err)
 
 	require.Len(t, cust.Addresses, 0)
 }
+
+func TestCustomers__setPrimaryAddress(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	cust, _, _ := (customerRequest{FirstName: "Jane", LastName: "Doe"}).asCustomer(testCustomerSSNStorage(t))
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, "organization"))
+
+	router := mux.NewRouter()
+	AddCustomerAddressRoutes(log.NewNopLogger(), router, repo, nil)
+
+	setPrimary := func(addressID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", fmt.Sprintf("/customers/%s/addresses/%s/primary", cust.CustomerID, addressID), nil)
+		req.Header.Set("x-organization", "organization")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// customers without addresses have nothing to make primary
+	w := setPrimary("missing")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	for i, addrType := range []client.AddressType{client.ADDRESSTYPE_PRIMARY, client.ADDRESSTYPE_SECONDARY} {
+		require.NoError(t, repo.addAddress(context.Background(), cust.CustomerID, client.OWNERTYPE_CUSTOMER, address{
+			Type:       addrType,
+			Address1:   fmt.Sprintf("%d 1st st", i+1),
+			City:       "Denver",
+			State:      "CO",
+			PostalCode: "80202",
+			Country:    "US",
+		}))
+	}
+	before, err := repo.GetCustomer(context.Background(), cust.CustomerID, "organization")
+	require.NoError(t, err)
+	require.Len(t, before.Addresses, 2)
+	require.Equal(t, client.ADDRESSTYPE_PRIMARY, before.Addresses[0].Type)
+	secondary := before.Addresses[1]
+
+	w = setPrimary(secondary.AddressID)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var got client.Customer
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.Len(t, got.Addresses, 2)
+
+	// the primary address is listed first and the previous one was demoted
+	require.Equal(t, secondary.AddressID, got.Addresses[0].AddressID)
+	require.Equal(t, client.ADDRESSTYPE_PRIMARY, got.Addresses[0].Type)
+	require.Equal(t, client.ADDRESSTYPE_SECONDARY, got.Addresses[1].Type)
+
+	// deleted addresses can't become primary
+	require.NoError(t, repo.deleteAddress(context.Background(), cust.CustomerID, client.OWNERTYPE_CUSTOMER, got.Addresses[1].AddressID))
+	w = setPrimary(got.Addresses[1].AddressID)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// unknown representatives are rejected
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/customers/%s/representatives/other/addresses/%s/primary", cust.CustomerID, secondary.AddressID), nil)
+	req.Header.Set("x-organization", "organization")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,199 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/internal/util"
+	"github.com/moov-io/customers/pkg/config"
+)
+
+// AddressVerifier checks an address against a third-party provider and returns
+// the provider's canonical form of it.
+type AddressVerifier interface {
+	Verify(addr address) (*VerifiedAddress, error)
+}
+
+// VerifiedAddress is the result of verifying an address. Valid is true when the
+// provider found a deliverable match for the address.
+type VerifiedAddress struct {
+	Valid bool
+
+	Address1   string
+	Address2   string
+	City       string
+	State      string
+	PostalCode string
+}
+
+// ErrUnsupportedCountry is returned by an AddressVerifier for addresses in countries it can't verify.
+// Those addresses are skipped rather than marked invalid.
+var ErrUnsupportedCountry = errors.New("address verification is not supported in this country")
+
+// NewAddressVerifier returns an AddressVerifier configured from environment variables
+// or nil if address verification is not configured.
+func NewAddressVerifier(logger log.Logger) (AddressVerifier, error) {
+	verifiers := make(CountryVerifiers)
+
+	authToken, err := config.Secret("SMARTYSTREETS_AUTH_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	if authID := os.Getenv("SMARTYSTREETS_AUTH_ID"); authID != "" && authToken != "" {
+		logger.Log("US address verification enabled with SmartyStreets")
+		verifiers["US"] = &smartyStreetsVerifier{
+			baseURL:   util.Or(os.Getenv("SMARTYSTREETS_ENDPOINT"), "https://us-street.api.smartystreets.com"),
+			authID:    authID,
+			authToken: authToken,
+			httpClient: &http.Client{
+				Timeout: 10 * time.Second,
+			},
+		}
+	}
+
+	if len(verifiers) == 0 {
+		return nil, nil
+	}
+	return verifiers, nil
+}
+
+// CountryVerifiers dispatches each address to the AddressVerifier for its ISO 3166-1 alpha-2
+// country code and returns ErrUnsupportedCountry for countries without one.
+type CountryVerifiers map[string]AddressVerifier
+
+func (vs CountryVerifiers) Verify(addr address) (*VerifiedAddress, error) {
+	if v, exists := vs[strings.ToUpper(addr.Country)]; exists && v != nil {
+		return v.Verify(addr)
+	}
+	return nil, ErrUnsupportedCountry
+}
+
+var (
+	// canonicalizeAddresses controls if verified addresses replace the city, state and
+	// postal code provided by the caller.
+	canonicalizeAddresses = util.Yes(os.Getenv("ADDRESS_VERIFICATION_CANONICALIZE"))
+)
+
+// verifyAddress runs addr through verifier and reports if it's valid and if it was verified at all.
+// Addresses are not verified without a verifier or when their country isn't supported. Errors from
+// the verifier are logged rather than returned so a provider outage doesn't block writes.
+func verifyAddress(logger log.Logger, verifier AddressVerifier, addr *address) (valid bool, verified bool) {
+	if verifier == nil || addr == nil {
+		return false, false
+	}
+	result, err := verifier.Verify(*addr)
+	if err != nil {
+		if err == ErrUnsupportedCountry {
+			return false, false
+		}
+		logger.LogErrorf("problem verifying address: %v", err)
+		return false, true
+	}
+	if result == nil || !result.Valid {
+		return false, true
+	}
+	if canonicalizeAddresses {
+		addr.Address1 = util.Or(result.Address1, addr.Address1)
+		addr.Address2 = result.Address2
+		addr.City = util.Or(result.City, addr.City)
+		addr.State = util.Or(result.State, addr.State)
+		addr.PostalCode = util.Or(result.PostalCode, addr.PostalCode)
+	}
+	return true, true
+}
+
+// verifyAddresses sets if each of addrs is valid, like verifyAddress does for a single address
+func verifyAddresses(logger log.Logger, verifier AddressVerifier, addrs []address) {
+	for i := range addrs {
+		addrs[i].validated, _ = verifyAddress(logger, verifier, &addrs[i])
+	}
+}
+
+// smartyStreetsVerifier verifies US addresses with the SmartyStreets US Street API.
+// See https://smartystreets.com/docs/cloud/us-street-api
+type smartyStreetsVerifier struct {
+	baseURL    string
+	authID     string
+	authToken  string
+	httpClient *http.Client
+}
+
+type smartyStreetsCandidate struct {
+	DeliveryLine1 string `json:"delivery_line_1"`
+	DeliveryLine2 string `json:"delivery_line_2"`
+	Components    struct {
+		CityName          string `json:"city_name"`
+		StateAbbreviation string `json:"state_abbreviation"`
+		Zipcode           string `json:"zipcode"`
+		Plus4Code         string `json:"plus4_code"`
+	} `json:"components"`
+	Analysis struct {
+		DPVMatchCode string `json:"dpv_match_code"`
+	} `json:"analysis"`
+}
+
+func (v *smartyStreetsVerifier) Verify(addr address) (*VerifiedAddress, error) {
+	switch strings.ToUpper(addr.Country) {
+	case "", "US", "USA":
+	default:
+		return nil, ErrUnsupportedCountry
+	}
+
+	params := url.Values{}
+	params.Set("auth-id", v.authID)
+	params.Set("auth-token", v.authToken)
+	params.Set("street", addr.Address1)
+	params.Set("secondary", addr.Address2)
+	params.Set("city", addr.City)
+	params.Set("state", addr.State)
+	params.Set("zipcode", addr.PostalCode)
+	params.Set("candidates", "1")
+
+	resp, err := v.httpClient.Get(fmt.Sprintf("%s/street-address?%s", strings.TrimSuffix(v.baseURL, "/"), params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("smartystreets: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("smartystreets: unexpected status: %s", resp.Status)
+	}
+
+	var candidates []smartyStreetsCandidate
+	if err := json.NewDecoder(resp.Body).Decode(&candidates); err != nil {
+		return nil, fmt.Errorf("smartystreets: decoding response: %v", err)
+	}
+	if len(candidates) == 0 {
+		return &VerifiedAddress{Valid: false}, nil
+	}
+
+	cand := candidates[0]
+	if cand.Components.Zipcode == "" {
+		return nil, errors.New("smartystreets: candidate missing zipcode")
+	}
+	postalCode := cand.Components.Zipcode
+	if cand.Components.Plus4Code != "" {
+		postalCode = fmt.Sprintf("%s-%s", postalCode, cand.Components.Plus4Code)
+	}
+	return &VerifiedAddress{
+		// Y: confirmed, S: confirmed ignoring secondary, D: confirmed but missing secondary
+		Valid:      cand.Analysis.DPVMatchCode == "Y" || cand.Analysis.DPVMatchCode == "S" || cand.Analysis.DPVMatchCode == "D",
+		Address1:   cand.DeliveryLine1,
+		Address2:   cand.DeliveryLine2,
+		City:       cand.Components.CityName,
+		State:      cand.Components.StateAbbreviation,
+		PostalCode: postalCode,
+	}, nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+type testAddressVerifier struct {
+	result *VerifiedAddress
+	err    error
+}
+
+func (v *testAddressVerifier) Verify(addr address) (*VerifiedAddress, error) {
+	return v.result, v.err
+}
+
+func TestAddressVerifier__smartyStreets(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("auth-id") != "id" || r.URL.Query().Get("auth-token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("street") == "1 Nowhere" {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Write([]byte(`[{"delivery_line_1": "123 1st St", "components": {"city_name": "Denver", "state_abbreviation": "CO", "zipcode": "80202", "plus4_code": "1234"}, "analysis": {"dpv_match_code": "Y"}}]`))
+	}))
+	defer svr.Close()
+
+	verifier := &smartyStreetsVerifier{
+		baseURL:    svr.URL,
+		authID:     "id",
+		authToken:  "token",
+		httpClient: svr.Client(),
+	}
+
+	result, err := verifier.Verify(address{Address1: "123 1st st", City: "denver", State: "CO", PostalCode: "80202", Country: "US"})
+	require.NoError(t, err)
+	require.True(t, result.Valid)
+	require.Equal(t, "123 1st St", result.Address1)
+	require.Equal(t, "Denver", result.City)
+	require.Equal(t, "80202-1234", result.PostalCode)
+
+	result, err = verifier.Verify(address{Address1: "1 Nowhere", City: "Denver", State: "CO", Country: "US"})
+	require.NoError(t, err)
+	require.False(t, result.Valid)
+
+	// non-US addresses are not sent
+	_, err = verifier.Verify(address{Address1: "1 Main St", City: "Toronto", Country: "CA"})
+	require.Equal(t, ErrUnsupportedCountry, err)
+
+	verifier.authToken = "wrong"
+	_, err = verifier.Verify(address{Address1: "123 1st st", City: "Denver", State: "CO", Country: "US"})
+	require.Error(t, err)
+}
+
+func TestAddressVerifier__verifyAddress(t *testing.T) {
+	logger := log.NewNopLogger()
+	addr := &address{Address1: "123 1st st", City: "denver", State: "CO", PostalCode: "80202"}
+
+	check := func(verifier AddressVerifier, valid, verified bool) {
+		t.Helper()
+		v, ok := verifyAddress(logger, verifier, addr)
+		require.Equal(t, valid, v)
+		require.Equal(t, verified, ok)
+	}
+	check(nil, false, false)
+	check(&testAddressVerifier{err: errors.New("bad")}, false, true)
+	check(&testAddressVerifier{err: ErrUnsupportedCountry}, false, false)
+	check(&testAddressVerifier{result: &VerifiedAddress{Valid: false}}, false, true)
+
+	verifier := &testAddressVerifier{result: &VerifiedAddress{Valid: true, Address1: "123 1st St", City: "Denver", State: "CO", PostalCode: "80202-1234"}}
+	check(verifier, true, true)
+	require.Equal(t, "denver", addr.City)
+
+	canonicalizeAddresses = true
+	defer func() { canonicalizeAddresses = false }()
+
+	check(verifier, true, true)
+	require.Equal(t, "Denver", addr.City)
+	require.Equal(t, "80202-1234", addr.PostalCode)
+}
+
+func TestAddressVerifier__CountryVerifiers(t *testing.T) {
+	verifiers := CountryVerifiers{"US": &testAddressVerifier{result: &VerifiedAddress{Valid: true}}}
+
+	result, err := verifiers.Verify(address{Country: "US"})
+	require.NoError(t, err)
+	require.True(t, result.Valid)
+
+	_, err = verifiers.Verify(address{Country: "GB"})
+	require.Equal(t, ErrUnsupportedCountry, err)
+}
+
+func TestCustomers__addAddressVerified(t *testing.T) {
+	db := createTestCustomerRepository(t)
+	repo := NewCustomerRepo(log.NewNopLogger(), db.db)
+
+	cust, _, _ := (customerRequest{FirstName: "Jane", LastName: "Doe"}).asCustomer(testCustomerSSNStorage(t))
+	organization := "organization"
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, organization))
+
+	send := func(address1 string, verifier AddressVerifier) *client.Customer {
+		payload, err := json.Marshal(address{
+			Address1:   address1,
+			City:       "Denver",
+			State:      "CO",
+			PostalCode: "12345",
+			Country:    "USA",
+			Type:       "secondary",
+			OwnerType:  "customer",
+		})
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", fmt.Sprintf("/customers/%s/addresses", cust.CustomerID), bytes.NewReader(payload))
+		req.Header.Set("x-organization", organization)
+
+		router := mux.NewRouter()
+		AddCustomerAddressRoutes(log.NewNopLogger(), router, repo, verifier)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp client.Customer
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return &resp
+	}
+
+	resp := send("123 1st st", &testAddressVerifier{result: &VerifiedAddress{Valid: true}})
+	require.Len(t, resp.Addresses, 1)
+	require.True(t, resp.Addresses[0].Validated)
+
+	// verifier failures don't block the write
+	resp = send("456 2nd st", &testAddressVerifier{err: errors.New("service unavailable")})
+	require.Len(t, resp.Addresses, 2)
+	for _, addr := range resp.Addresses {
+		require.Equal(t, addr.Address1 == "123 1st st", addr.Validated)
+	}
+}
+
+func TestCustomers__createCustomerAddressVerified(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	verifier := &testAddressVerifier{result: &VerifiedAddress{Valid: true}}
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, verifier)
+
+	send := func(method, path, body string) *client.Customer {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("x-organization", "test")
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var cust client.Customer
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&cust))
+		return &cust
+	}
+	body := func(address1 string) string {
+		return `{"firstName": "Jane", "lastName": "Doe", "type": "individual", "addresses": [{"type": "primary", "address1": "` + address1 + `", "city": "Denver", "state": "CO", "postalCode": "80202", "country": "US", "ownerType": "customer"}]}`
+	}
+
+	// addresses embedded in the customer are verified before they're saved
+	cust := send("POST", "/customers", body("123 1st St"))
+	require.Len(t, cust.Addresses, 1)
+	require.True(t, cust.Addresses[0].Validated)
+
+	verifier.result = &VerifiedAddress{Valid: false}
+	cust = send("PUT", "/customers/"+cust.CustomerID, body("456 2nd St"))
+	require.Len(t, cust.Addresses, 1)
+	require.False(t, cust.Addresses[0].Validated)
+
+	verifier.result = &VerifiedAddress{Valid: true}
+	cust = send("PATCH", "/customers/"+cust.CustomerID, `{"addresses": [{"type": "primary", "address1": "789 3rd St", "city": "Denver", "state": "CO", "postalCode": "80202", "country": "US", "ownerType": "customer"}]}`)
+	require.Len(t, cust.Addresses, 1)
+	require.True(t, cust.Addresses[0].Validated)
+
+	found, err := repo.GetCustomer(context.Background(), cust.CustomerID, "test")
+	require.NoError(t, err)
+	require.True(t, found.Addresses[0].Validated)
+}
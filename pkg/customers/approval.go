@@ -5,6 +5,7 @@
 package customers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -13,13 +14,19 @@ import (
 
 	"github.com/moov-io/customers/pkg/client"
 	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/webhooks"
 
 	"github.com/moov-io/base/log"
 )
 
-func updateCustomerStatus(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
+func updateCustomerStatus(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage, notifier webhooks.Notifier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if !route.RequireScope(w, r, route.ScopeAdmin) {
+			return
+		}
 
 		customerID := route.GetCustomerID(w, r)
 		if customerID == "" {
@@ -33,26 +40,46 @@ func updateCustomerStatus(logger log.Logger, repo CustomerRepository) http.Handl
 
 		var req client.UpdateCustomerStatus
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
-		cust, err := repo.GetCustomer(customerID, organization)
+		status, err := changeCustomerStatus(r.Context(), repo, customerSSNStorage.repo, customerID, organization, req, route.GetActor(r))
 		if err != nil {
-			moovhttp.Problem(w, err)
-			return
-		}
-		if cust == nil {
-			moovhttp.Problem(w, fmt.Errorf("customerID=%s not found", customerID))
+			route.Problem(w, err)
 			return
 		}
+		notify(notifier, webhooks.CustomerStatusUpdated, customerID, organization, status)
+		rollupRepresentatives(r.Context(), logger, repo, notifier, customerID, organization)
 
-		if err := repo.updateCustomerStatus(customerID, req.Status, req.Comment); err != nil {
-			moovhttp.Problem(w, err)
-			return
+		requestID := moovhttp.GetRequestID(r)
+		respondWithCustomer(r.Context(), logger, w, customerID, organization, requestID, repo)
+	}
+}
+
+// changeCustomerStatus moves a Customer to the requested status if the transition is allowed
+// and returns the status which was saved.
+func changeCustomerStatus(ctx context.Context, repo CustomerRepository, ssnRepo SSNRepository, customerID, organization string, req client.UpdateCustomerStatus, actor string) (client.CustomerStatus, error) {
+	cust, err := repo.GetCustomer(ctx, customerID, organization)
+	if err != nil {
+		return "", err
+	}
+	if cust == nil {
+		return "", route.NotFoundError(fmt.Errorf("customerID=%s not found", customerID))
+	}
+
+	if err := TransitionAllowed(cust.Status, req.Status); err != nil {
+		return "", err
+	}
+	status, _ := readCustomerStatus(string(req.Status))
+	if status == client.CUSTOMERSTATUS_VERIFIED {
+		if err := checkVerificationRequirements(ctx, cust, organization, repo, ssnRepo); err != nil {
+			return "", fmt.Errorf("unable to verify customer: %v", err)
 		}
+	}
 
-		requestID := moovhttp.GetRequestID(r)
-		respondWithCustomer(logger, w, customerID, organization, requestID, repo)
+	if err := repo.updateCustomerStatus(ctx, customerID, status, req.Comment, actor); err != nil {
+		return "", err
 	}
+	return status, nil
 }
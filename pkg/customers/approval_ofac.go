@@ -12,14 +12,18 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	moovhttp "github.com/moov-io/base/http"
 	watchmanClient "github.com/moov-io/watchman/client"
 
+	"github.com/moov-io/customers/internal/util"
 	"github.com/moov-io/customers/pkg/client"
 	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/tracing"
 	"github.com/moov-io/customers/pkg/watchman"
+	"github.com/moov-io/customers/pkg/webhooks"
 
 	"github.com/gorilla/mux"
 	"github.com/moov-io/base/log"
@@ -35,6 +39,30 @@ var (
 		}
 		return 0.99 // default, 99%
 	}()
+
+	// ofacReviewThreshold flags searches which matched above it, but not above ofacMatchThreshold,
+	// for manual review instead of rejecting the Customer. Zero disables reviews.
+	ofacReviewThreshold float32 = func() float32 {
+		if v := os.Getenv("OFAC_REVIEW_THRESHOLD"); v != "" {
+			f, err := strconv.ParseFloat(v, 32)
+			if err == nil && f >= 0.00 {
+				return float32(f)
+			}
+		}
+		return 0.90 // default, 90%
+	}()
+
+	// ofacSearchCacheDuration is how long a passing OFAC search is reused before
+	// a refresh runs a new search against Watchman.
+	ofacSearchCacheDuration time.Duration = func() time.Duration {
+		if v := os.Getenv("OFAC_SEARCH_CACHE_DURATION"); v != "" {
+			dur, err := time.ParseDuration(v)
+			if err == nil && dur >= 0 {
+				return dur
+			}
+		}
+		return 24 * time.Hour // default
+	}()
 )
 
 type OFACSearcher struct {
@@ -72,24 +100,10 @@ func (s *OFACSearcher) storeCustomerOFACSearch(cust *client.Customer, requestID
 	}
 	// Save the higher matching SDN (from name search or nick name)
 	switch {
-	case nickSDN != nil && nickSDN.Match > sdn.Match:
-		err = s.repo.saveCustomerOFACSearch(cust.CustomerID, client.OfacSearch{
-			EntityID:  nickSDN.EntityID,
-			Blocked:   nickSDN.Match > ofacMatchThreshold,
-			SdnName:   nickSDN.SdnName,
-			SdnType:   nickSDN.SdnType,
-			Match:     nickSDN.Match,
-			CreatedAt: time.Now(),
-		})
+	case nickSDN != nil && (sdn == nil || nickSDN.Match > sdn.Match):
+		err = s.repo.saveCustomerOFACSearch(ctx, cust.CustomerID, newOFACSearch(nickSDN))
 	case sdn != nil:
-		err = s.repo.saveCustomerOFACSearch(cust.CustomerID, client.OfacSearch{
-			EntityID:  sdn.EntityID,
-			Blocked:   sdn.Match > ofacMatchThreshold,
-			SdnName:   sdn.SdnName,
-			SdnType:   sdn.SdnType,
-			Match:     sdn.Match,
-			CreatedAt: time.Now(),
-		})
+		err = s.repo.saveCustomerOFACSearch(ctx, cust.CustomerID, newOFACSearch(sdn))
 	}
 	if err != nil {
 		return fmt.Errorf("OFACSearcher.storeCustomerOFACSearch: saveCustomerOFACSearch customer=%s: %v", cust.CustomerID, err)
@@ -97,11 +111,106 @@ func (s *OFACSearcher) storeCustomerOFACSearch(cust *client.Customer, requestID
 	return nil
 }
 
-func AddOFACRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository, ofac *OFACSearcher) {
+// storeRepresentativeOFACSearch performs an OFAC search against the Representative's name and stores the result.
+func (s *OFACSearcher) storeRepresentativeOFACSearch(rep *client.Representative, requestID string) error {
+	ctx, cancelFn := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancelFn()
+
+	if rep == nil {
+		return errors.New("nil Representative")
+	}
+
+	name := strings.TrimSpace(strings.TrimSpace(rep.FirstName) + " " + strings.TrimSpace(rep.LastName))
+	sdn, err := s.watchmanClient.Search(ctx, name, requestID)
+	if err != nil {
+		return fmt.Errorf("OFACSearcher.storeRepresentativeOFACSearch: name search for representative=%s: %v", rep.RepresentativeID, err)
+	}
+	if sdn == nil {
+		return nil
+	}
+	err = s.repo.saveRepresentativeOFACSearch(ctx, rep.RepresentativeID, newOFACSearch(sdn))
+	if err != nil {
+		return fmt.Errorf("OFACSearcher.storeRepresentativeOFACSearch: saveRepresentativeOFACSearch representative=%s: %v", rep.RepresentativeID, err)
+	}
+	return nil
+}
+
+// newOFACSearch applies the configured thresholds to a Watchman result. The thresholds are saved
+// with the search so each result shows the policy it was judged by.
+func newOFACSearch(sdn *watchmanClient.OfacSdn) client.OfacSearch {
+	blocked := sdn.Match > ofacMatchThreshold
+	return client.OfacSearch{
+		EntityID:        sdn.EntityID,
+		Blocked:         blocked,
+		ReviewRequired:  !blocked && ofacReviewThreshold > 0 && sdn.Match > ofacReviewThreshold,
+		SdnName:         sdn.SdnName,
+		SdnType:         sdn.SdnType,
+		Match:           sdn.Match,
+		MatchThreshold:  ofacMatchThreshold,
+		ReviewThreshold: ofacReviewThreshold,
+		CreatedAt:       time.Now(),
+	}
+}
+
+// cachedSearch returns the Customer's latest OFAC search if it's recent enough to reuse.
+// Searches which matched above the threshold are never reused.
+func (s *OFACSearcher) cachedSearch(ctx context.Context, customerID, organization string) (*client.OfacSearch, error) {
+	if ofacSearchCacheDuration <= 0 {
+		return nil, nil
+	}
+	result, err := s.repo.getLatestCustomerOFACSearch(ctx, customerID, organization)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	if exceedsOFACThreshold(result) || time.Since(result.CreatedAt) > ofacSearchCacheDuration {
+		return nil, nil
+	}
+	return result, nil
+}
+
+func exceedsOFACThreshold(result *client.OfacSearch) bool {
+	return result != nil && (result.Blocked || result.Match > ofacMatchThreshold)
+}
+
+// rejectBlockedCustomer sets the Customer's status to Rejected when their OFAC search matched
+// above the threshold. It returns true if the Customer was rejected. Searches which only need a
+// review leave the Customer's status alone.
+func rejectBlockedCustomer(ctx context.Context, logger log.Logger, repo CustomerRepository, cust *client.Customer, result *client.OfacSearch, comment, actor string) (bool, error) {
+	if cust == nil || result == nil {
+		return false, nil
+	}
+	if !exceedsOFACThreshold(result) {
+		if result.ReviewRequired {
+			logger.Logf("customer=%s matched against OFAC entity=%s with a score of %.2f - flagged for review", cust.CustomerID, result.EntityID, result.Match)
+		}
+		return false, nil
+	}
+	if err := TransitionAllowed(cust.Status, client.CUSTOMERSTATUS_REJECTED); err != nil {
+		return false, nil // e.g. already Rejected or Deceased
+	}
+
+	logger.LogErrorf("customer=%s matched against OFAC entity=%s with a score of %.2f - rejecting customer", cust.CustomerID, result.EntityID, result.Match)
+
+	comment = fmt.Sprintf("%s: matched OFAC entity=%s (%s) with a score of %.2f", comment, result.EntityID, result.SdnName, result.Match)
+	if err := repo.updateCustomerStatus(ctx, cust.CustomerID, client.CUSTOMERSTATUS_REJECTED, comment, actor); err != nil {
+		return false, fmt.Errorf("rejecting customer=%s: %v", cust.CustomerID, err)
+	}
+
+	// Block the Customer's representatives too
+	rejected := *cust
+	rejected.Status = client.CUSTOMERSTATUS_REJECTED
+	if _, err := applyStatusRollup(ctx, logger, repo, &rejected); err != nil {
+		logger.LogErrorf("error blocking representatives of customer=%s: %v", cust.CustomerID, err)
+	}
+	return true, nil
+}
+
+func AddOFACRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository, ofac *OFACSearcher, notifier webhooks.Notifier) {
 	logger = logger.Set("package", log.String("customers"))
 
 	r.Methods("GET").Path("/customers/{customerID}/ofac").HandlerFunc(getLatestCustomerOFACSearch(logger, repo))
-	r.Methods("PUT").Path("/customers/{customerID}/refresh/ofac").HandlerFunc(refreshOFACSearch(logger, repo, ofac))
+	r.Methods("PUT").Path("/customers/{customerID}/refresh/ofac").HandlerFunc(refreshOFACSearch(logger, repo, ofac, notifier))
+	r.Methods("POST").Path("/customers/ofac-search").HandlerFunc(screenOFAC(logger, ofac))
 }
 
 func getLatestCustomerOFACSearch(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
@@ -119,9 +228,9 @@ func getLatestCustomerOFACSearch(logger log.Logger, repo CustomerRepository) htt
 			return
 		}
 
-		result, err := repo.getLatestCustomerOFACSearch(customerID, organization)
+		result, err := repo.getLatestCustomerOFACSearch(r.Context(), customerID, organization)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -130,9 +239,10 @@ func getLatestCustomerOFACSearch(logger log.Logger, repo CustomerRepository) htt
 	}
 }
 
-func refreshOFACSearch(logger log.Logger, repo CustomerRepository, ofac *OFACSearcher) http.HandlerFunc {
+func refreshOFACSearch(logger log.Logger, repo CustomerRepository, ofac *OFACSearcher, notifier webhooks.Notifier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
 		requestID := moovhttp.GetRequestID(r)
@@ -146,38 +256,68 @@ func refreshOFACSearch(logger log.Logger, repo CustomerRepository, ofac *OFACSea
 			return
 		}
 
-		cust, err := repo.GetCustomer(customerID, organization)
+		cust, err := repo.GetCustomer(r.Context(), customerID, organization)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
+			return
+		}
+		if cust == nil {
+			route.NotFound(w, r)
 			return
 		}
 
-		logger.Logf("running live OFAC search for customer=%s", customerID)
-
-		if err := ofac.storeCustomerOFACSearch(cust, requestID); err != nil {
-			logger.LogErrorf("error refreshing ofac search: %v", err)
-			moovhttp.Problem(w, err)
+		result, rejected, err := refreshCustomerOFACSearch(r.Context(), logger, repo, ofac, cust, organization, requestID, route.GetActor(r), util.Yes(r.URL.Query().Get("forceRefresh")))
+		if err != nil {
+			route.Problem(w, err)
 			return
 		}
+		if rejected {
+			notify(notifier, webhooks.CustomerStatusUpdated, cust.CustomerID, organization, client.CUSTOMERSTATUS_REJECTED)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// refreshCustomerOFACSearch returns a recent OFAC search of the Customer, or runs a new one when
+// none exists or force is set, and rejects the Customer if they're blocked.
+func refreshCustomerOFACSearch(ctx context.Context, logger log.Logger, repo CustomerRepository, ofac *OFACSearcher, cust *client.Customer, organization, requestID, actor string, force bool) (*client.OfacSearch, bool, error) {
+	var result *client.OfacSearch
+	var err error
 
-		result, err := repo.getLatestCustomerOFACSearch(customerID, organization)
+	// Reuse a recent search unless compliance asks for a fresh one
+	if !force {
+		result, err = ofac.cachedSearch(ctx, cust.CustomerID, organization)
 		if err != nil {
 			logger.LogErrorf("error getting latest ofac search: %v", err)
-			moovhttp.Problem(w, err)
-			return
+			return nil, false, err
 		}
+	}
+	if result != nil {
+		logger.Logf("using OFAC search from %v for customer=%s", result.CreatedAt, cust.CustomerID)
+	} else {
+		logger.Logf("running live OFAC search for customer=%s", cust.CustomerID)
 
-		if result.Blocked {
-			logger.LogErrorf("customer=%s matched against OFAC entity=%s with a score of %.2f - rejecting customer", cust.CustomerID, result.EntityID, result.Match)
+		_, span := tracing.StartSpan(ctx, "OFACSearch", cust.CustomerID)
+		err := ofac.storeCustomerOFACSearch(cust, requestID)
+		tracing.EndSpan(span, err)
+		if err != nil {
+			logger.LogErrorf("error refreshing ofac search: %v", err)
+			return nil, false, err
+		}
 
-			if err := repo.updateCustomerStatus(cust.CustomerID, client.CUSTOMERSTATUS_REJECTED, "manual OFAC refresh"); err != nil {
-				logger.LogErrorf("error updating customer=%s error=%v", cust.CustomerID, err)
-				moovhttp.Problem(w, err)
-				return
-			}
+		result, err = repo.getLatestCustomerOFACSearch(ctx, cust.CustomerID, organization)
+		if err != nil {
+			logger.LogErrorf("error getting latest ofac search: %v", err)
+			return nil, false, err
 		}
+	}
 
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(result)
+	rejected, err := rejectBlockedCustomer(ctx, logger, repo, cust, result, "manual OFAC refresh", actor)
+	if err != nil {
+		logger.LogErrorf("error updating customer=%s error=%v", cust.CustomerID, err)
+		return nil, false, err
 	}
+	return result, rejected, nil
 }
@@ -5,12 +5,14 @@
 package customers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/moov-io/base"
@@ -18,6 +20,7 @@ import (
 	"github.com/moov-io/customers/pkg/client"
 	"github.com/moov-io/customers/pkg/watchman"
 	watchmanClient "github.com/moov-io/watchman/client"
+	"github.com/stretchr/testify/require"
 )
 
 func createTestOFACSearcher(repo CustomerRepository, client watchman.Client) *OFACSearcher {
@@ -61,14 +64,14 @@ func TestOFACSearcher__storeCustomerOFACSearch(t *testing.T) {
 		LastName:   "Doe",
 	}
 	cust, _, _ := customerRequest.asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Fatal(err)
 	}
 
 	if err := searcher.storeCustomerOFACSearch(&client.Customer{CustomerID: customerID}, "requestID"); err != nil {
 		t.Fatal(err)
 	}
-	res, err := repo.getLatestCustomerOFACSearch(customerID, organization)
+	res, err := repo.getLatestCustomerOFACSearch(context.Background(), customerID, organization)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -109,11 +112,11 @@ func TestOFACApproval__getLatest(t *testing.T) {
 		LastName:   "Doe",
 	}
 	cust, _, _ := customerRequest.asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Fatal(err)
 	}
 
-	AddOFACRoutes(logger, router, repo, nil)
+	AddOFACRoutes(logger, router, repo, nil, nil)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", fmt.Sprintf("/customers/%s/ofac", customerID), nil)
@@ -159,7 +162,7 @@ func TestOFACApproval__refresh(t *testing.T) {
 		watchmanClient: testWatchmanClient,
 	}
 
-	AddOFACRoutes(logger, router, repo, ofac)
+	AddOFACRoutes(logger, router, repo, ofac, nil)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("PUT", fmt.Sprintf("/customers/%s/refresh/ofac", customerID), nil)
@@ -210,7 +213,7 @@ func TestOFACApproval__refreshErr(t *testing.T) {
 		watchmanClient: testWatchmanClient,
 	}
 
-	AddOFACRoutes(logger, router, repo, ofac)
+	AddOFACRoutes(logger, router, repo, ofac, nil)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("PUT", fmt.Sprintf("/customers/%s/refresh/ofac", customerID), nil)
@@ -221,3 +224,106 @@ func TestOFACApproval__refreshErr(t *testing.T) {
 		t.Errorf("bogus HTTP status: %d - %s", w.Code, w.Body.String())
 	}
 }
+
+func TestOFACApproval__refreshCached(t *testing.T) {
+	logger := log.NewNopLogger()
+	router := mux.NewRouter()
+
+	customerID := base.ID()
+	repo := &testCustomerRepository{
+		customer: &client.Customer{
+			CustomerID: customerID,
+			Status:     client.CUSTOMERSTATUS_VERIFIED,
+		},
+		savedSearchResult: &client.OfacSearch{
+			EntityID:  "142",
+			Match:     0.20,
+			CreatedAt: time.Now().Add(-1 * time.Hour),
+		},
+	}
+	// Watchman is down, so only cached searches succeed
+	ofac := &OFACSearcher{
+		repo:           repo,
+		watchmanClient: watchman.NewTestWatchmanClient(nil, errors.New("bad error")),
+	}
+	AddOFACRoutes(logger, router, repo, ofac, nil)
+
+	refresh := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", fmt.Sprintf("/customers/%s/refresh/ofac%s", customerID, query), nil)
+		req.Header.Set("x-organization", "organization")
+		router.ServeHTTP(w, req)
+		w.Flush()
+		return w
+	}
+
+	w := refresh("")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = refresh("?forceRefresh=true")
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	// old searches aren't reused
+	repo.savedSearchResult.CreatedAt = time.Now().Add(-48 * time.Hour)
+	w = refresh("")
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	// matches above the threshold are not reused and reject the customer
+	ofac.watchmanClient = watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{EntityID: "142", Match: 1.0}, nil)
+	repo.savedSearchResult = &client.OfacSearch{EntityID: "142", Match: 1.0, CreatedAt: time.Now()}
+	w = refresh("")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, client.CUSTOMERSTATUS_REJECTED, repo.updatedStatus)
+}
+
+func TestOFACSearcher__thresholds(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	organization := "organization"
+	cust, _, _ := (&customerRequest{FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}).asCustomer(testCustomerSSNStorage(t))
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, organization))
+
+	search := func(match float32) *client.OfacSearch {
+		ofac := createTestOFACSearcher(repo, watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{
+			EntityID: "1241421",
+			SdnName:  "JANE DOE",
+			Match:    match,
+		}, nil))
+		require.NoError(t, ofac.storeCustomerOFACSearch(cust, "requestID"))
+
+		res, err := repo.getLatestCustomerOFACSearch(context.Background(), cust.CustomerID, organization)
+		require.NoError(t, err)
+		return res
+	}
+
+	res := search(0.50)
+	require.False(t, res.Blocked)
+	require.False(t, res.ReviewRequired)
+	require.Equal(t, ofacMatchThreshold, res.MatchThreshold)
+	require.Equal(t, ofacReviewThreshold, res.ReviewThreshold)
+
+	// between the review and match thresholds only flags the customer
+	res = search(0.95)
+	require.False(t, res.Blocked)
+	require.True(t, res.ReviewRequired)
+
+	rejected, err := rejectBlockedCustomer(context.Background(), log.NewNopLogger(), repo, cust, res, "OFAC search on create", "")
+	require.NoError(t, err)
+	require.False(t, rejected)
+
+	// above the match threshold rejects the customer
+	res = search(1.0)
+	require.True(t, res.Blocked)
+	require.False(t, res.ReviewRequired)
+
+	rejected, err = rejectBlockedCustomer(context.Background(), log.NewNopLogger(), repo, cust, res, "OFAC search on create", "")
+	require.NoError(t, err)
+	require.True(t, rejected)
+
+	history, err := repo.getStatusHistory(context.Background(), cust.CustomerID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, client.CUSTOMERSTATUS_REJECTED, history[0].Status)
+	require.Equal(t, "OFAC search on create: matched OFAC entity=1241421 (JANE DOE) with a score of 1.00", history[0].Comment)
+}
@@ -28,7 +28,7 @@ func TestCustomers__updateCustomerStatus(t *testing.T) {
 		},
 	}
 	router := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(repo, nil))
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(repo, nil), nil, nil)
 
 	updateStatusRequest := client.UpdateCustomerStatus{
 		Status:  "ReceiveOnly",
@@ -53,4 +53,14 @@ func TestCustomers__updateCustomerStatus(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, customer)
 	require.Equal(t, updateStatusRequest.Status, repo.updatedStatus)
+
+	// unknown customers aren't found
+	repo.customer = nil
+	req, err = http.NewRequest("PUT", "/customers/_id_/status", bytes.NewReader(payload))
+	require.NoError(t, err)
+	req.Header.Set("x-organization", "test")
+
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusNotFound, res.Code, res.Body.String())
 }
@@ -0,0 +1,60 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/moov-io/customers/pkg/model"
+)
+
+// maxCustomerAge rejects birth dates which are more likely typos than real, like 1820 instead of 1920
+const maxCustomerAge = 130
+
+// minimumCustomerAge is how old individual Customers must be, zero allows any age
+var minimumCustomerAge = func() int {
+	n, err := strconv.Atoi(os.Getenv("CUSTOMER_MINIMUM_AGE"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}()
+
+// customerAge returns how many full years old someone born on birthDate is at now
+func customerAge(birthDate, now time.Time) int {
+	now = now.UTC()
+	age := now.Year() - birthDate.Year()
+	if now.Month() < birthDate.Month() || (now.Month() == birthDate.Month() && now.Day() < birthDate.Day()) {
+		age--
+	}
+	return age
+}
+
+// validateBirthDate rejects birth dates in the future or more than maxCustomerAge years ago, along with
+// Customers younger than minimumAge when it's set.
+func validateBirthDate(birthDate model.YYYYMMDD, minimumAge int, now time.Time) error {
+	if birthDate == "" {
+		return nil
+	}
+	born, err := time.Parse(model.YYYYMMDD_Format, string(birthDate))
+	if err != nil {
+		return fmt.Errorf("%s is not a YYYY-MM-DD date", birthDate)
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	if born.After(today) {
+		return fmt.Errorf("%s is in the future", birthDate)
+	}
+	age := customerAge(born, now)
+	if age > maxCustomerAge {
+		return fmt.Errorf("%s is more than %d years ago", birthDate, maxCustomerAge)
+	}
+	if age < minimumAge {
+		return fmt.Errorf("customer must be at least %d years old", minimumAge)
+	}
+	return nil
+}
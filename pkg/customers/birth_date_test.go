@@ -0,0 +1,81 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestCustomerAge(t *testing.T) {
+	born := time.Date(2000, time.February, 29, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, 17, customerAge(born, time.Date(2018, time.February, 28, 12, 0, 0, 0, time.UTC)))
+	require.Equal(t, 18, customerAge(born, time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC)))
+	require.Equal(t, 0, customerAge(born, born))
+}
+
+func TestValidateBirthDate(t *testing.T) {
+	now := time.Date(2020, time.June, 15, 10, 0, 0, 0, time.UTC)
+
+	require.NoError(t, validateBirthDate("", 18, now))
+	require.NoError(t, validateBirthDate("1990-01-01", 0, now))
+	require.NoError(t, validateBirthDate("2020-06-15", 0, now))
+	require.NoError(t, validateBirthDate("2002-06-15", 18, now))
+
+	require.EqualError(t, validateBirthDate("2020-06-16", 0, now), "2020-06-16 is in the future")
+	require.EqualError(t, validateBirthDate("1820-01-01", 0, now), "1820-01-01 is more than 130 years ago")
+	require.EqualError(t, validateBirthDate("2002-06-16", 18, now), "customer must be at least 18 years old")
+	require.Error(t, validateBirthDate("06/15/2002", 0, now))
+}
+
+func TestCustomers__minimumAge(t *testing.T) {
+	defer func(age int) { minimumCustomerAge = age }(minimumCustomerAge)
+	minimumCustomerAge = 18
+
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/customers", strings.NewReader(body))
+		req.Header.Set("X-Organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	minor := time.Now().AddDate(-17, 0, 0).Format("2006-01-02")
+
+	w := create(`{"firstName": "Jane", "lastName": "Doe", "type": "individual", "birthDate": "` + minor + `"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), "at least 18 years old")
+
+	// only individuals need to be old enough
+	w = create(`{"firstName": "Jane", "lastName": "Doe", "type": "business", "businessName": "Doe LLC", "birthDate": "` + minor + `"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	adult := time.Now().AddDate(-30, 0, -1).Format("2006-01-02")
+	w = create(`{"firstName": "Jane", "lastName": "Doe", "type": "individual", "birthDate": "` + adult + `"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var cust client.Customer
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&cust))
+	require.Equal(t, int32(30), cust.Age)
+
+	found, err := repo.GetCustomer(context.Background(), cust.CustomerID, "test")
+	require.NoError(t, err)
+	require.Equal(t, int32(30), found.Age)
+}
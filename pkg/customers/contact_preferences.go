@@ -0,0 +1,228 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// ContactCategory is a kind of message a Customer consents to receive
+type ContactCategory string
+
+const (
+	ContactEmail     ContactCategory = "email"
+	ContactSMS       ContactCategory = "sms"
+	ContactMarketing ContactCategory = "marketing"
+)
+
+// ContactPreferences records how a Customer has consented to be contacted, as required by CAN-SPAM
+// and the TCPA. Each timestamp is when the Customer last opted in and is unset once they opt out.
+// Customers who have never set their preferences haven't opted in to anything.
+type ContactPreferences struct {
+	EmailOptIn         bool       `json:"emailOptIn"`
+	EmailOptedInAt     *time.Time `json:"emailOptedInAt,omitempty"`
+	SMSOptIn           bool       `json:"smsOptIn"`
+	SMSOptedInAt       *time.Time `json:"smsOptedInAt,omitempty"`
+	MarketingOptIn     bool       `json:"marketingOptIn"`
+	MarketingOptedInAt *time.Time `json:"marketingOptedInAt,omitempty"`
+	UpdatedAt          *time.Time `json:"updatedAt,omitempty"`
+}
+
+// OptedIn returns true if the Customer consents to messages of category
+func (p *ContactPreferences) OptedIn(category ContactCategory) bool {
+	if p == nil {
+		return false
+	}
+	switch category {
+	case ContactEmail:
+		return p.EmailOptIn
+	case ContactSMS:
+		return p.SMSOptIn
+	case ContactMarketing:
+		return p.MarketingOptIn
+	}
+	return false
+}
+
+// contactPreferencesRequest holds the preferences to change, omitted fields are left as they are
+type contactPreferencesRequest struct {
+	EmailOptIn     *bool `json:"emailOptIn"`
+	SMSOptIn       *bool `json:"smsOptIn"`
+	MarketingOptIn *bool `json:"marketingOptIn"`
+}
+
+// apply updates prefs from the request, setting the opt-in time of each category the Customer
+// newly consents to.
+func (req contactPreferencesRequest) apply(prefs *ContactPreferences, now time.Time) {
+	update := func(requested *bool, optIn *bool, optedInAt **time.Time) {
+		if requested == nil {
+			return
+		}
+		switch {
+		case !*requested:
+			*optedInAt = nil
+		case !*optIn:
+			*optedInAt = &now
+		}
+		*optIn = *requested
+	}
+	update(req.EmailOptIn, &prefs.EmailOptIn, &prefs.EmailOptedInAt)
+	update(req.SMSOptIn, &prefs.SMSOptIn, &prefs.SMSOptedInAt)
+	update(req.MarketingOptIn, &prefs.MarketingOptIn, &prefs.MarketingOptedInAt)
+	prefs.UpdatedAt = &now
+}
+
+func AddContactPreferenceRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository, prefsRepo ContactPreferencesRepository) {
+	logger = logger.Set("package", log.String("customers"))
+
+	r.Methods("GET").Path("/customers/{customerID}/contact-preferences").HandlerFunc(getContactPreferences(logger, repo, prefsRepo))
+	r.Methods("PUT").Path("/customers/{customerID}/contact-preferences").HandlerFunc(updateContactPreferences(logger, repo, prefsRepo))
+}
+
+func getContactPreferences(logger log.Logger, repo CustomerRepository, prefsRepo ContactPreferencesRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		prefs, err := prefsRepo.GetContactPreferences(customerID)
+		if err != nil {
+			logger.Set("customerID", log.String(customerID)).LogErrorf("problem reading contact preferences: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(prefs)
+	}
+}
+
+func updateContactPreferences(logger log.Logger, repo CustomerRepository, prefsRepo ContactPreferencesRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		var req contactPreferencesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, fmt.Errorf("reading contact preferences: %v", err))
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+		prefs, err := prefsRepo.GetContactPreferences(customerID)
+		if err != nil {
+			logger.LogErrorf("problem reading contact preferences: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		req.apply(prefs, time.Now())
+		if err := prefsRepo.updateContactPreferences(customerID, prefs); err != nil {
+			logger.LogErrorf("problem saving contact preferences: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		logger.Info().Log("updated contact preferences")
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(prefs)
+	}
+}
+
+// customerExists writes a 404 and returns false when the Customer isn't found in organization
+func customerExists(w http.ResponseWriter, r *http.Request, repo CustomerRepository, customerID, organization string) bool {
+	cust, err := repo.GetCustomer(r.Context(), customerID, organization)
+	if err != nil && err != errCustomerNotFound {
+		route.Problem(w, err)
+		return false
+	}
+	if cust == nil {
+		route.NotFound(w, r)
+		return false
+	}
+	return true
+}
+
+type ContactPreferencesRepository interface {
+	// GetContactPreferences returns the Customer's preferences, which are all opted out if the
+	// Customer has never set them.
+	GetContactPreferences(customerID string) (*ContactPreferences, error)
+	updateContactPreferences(customerID string, prefs *ContactPreferences) error
+}
+
+func NewContactPreferencesRepository(logger log.Logger, db customersdb.Querier) ContactPreferencesRepository {
+	return &sqlContactPreferencesRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+type sqlContactPreferencesRepository struct {
+	db     customersdb.Querier
+	logger log.Logger
+}
+
+func (r *sqlContactPreferencesRepository) GetContactPreferences(customerID string) (*ContactPreferences, error) {
+	query := `select email_opt_in, email_opted_in_at, sms_opt_in, sms_opted_in_at, marketing_opt_in, marketing_opted_in_at, updated_at from customer_contact_preferences
+where customer_id = ? limit 1;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("GetContactPreferences: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	var prefs ContactPreferences
+	err = stmt.QueryRow(customerID).Scan(&prefs.EmailOptIn, &prefs.EmailOptedInAt, &prefs.SMSOptIn, &prefs.SMSOptedInAt, &prefs.MarketingOptIn, &prefs.MarketingOptedInAt, &prefs.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("GetContactPreferences: scan: %v", err)
+	}
+	return &prefs, nil
+}
+
+func (r *sqlContactPreferencesRepository) updateContactPreferences(customerID string, prefs *ContactPreferences) error {
+	query := `replace into customer_contact_preferences (customer_id, email_opt_in, email_opted_in_at, sms_opt_in, sms_opted_in_at, marketing_opt_in, marketing_opted_in_at, updated_at)
+values (?, ?, ?, ?, ?, ?, ?, ?);`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("updateContactPreferences: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	updatedAt := time.Now()
+	if prefs.UpdatedAt != nil {
+		updatedAt = *prefs.UpdatedAt
+	}
+	_, err = stmt.Exec(customerID, prefs.EmailOptIn, prefs.EmailOptedInAt, prefs.SMSOptIn, prefs.SMSOptedInAt, prefs.MarketingOptIn, prefs.MarketingOptedInAt, updatedAt)
+	if err != nil {
+		return fmt.Errorf("updateContactPreferences: exec: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestContactPreferences__apply(t *testing.T) {
+	yes, no := true, false
+	first := time.Now().Add(-time.Hour)
+	prefs := &ContactPreferences{}
+
+	contactPreferencesRequest{EmailOptIn: &yes, SMSOptIn: &no}.apply(prefs, first)
+	require.True(t, prefs.EmailOptIn)
+	require.Equal(t, first, *prefs.EmailOptedInAt)
+	require.False(t, prefs.SMSOptIn)
+	require.Nil(t, prefs.SMSOptedInAt)
+
+	// opting in again keeps when consent was first given
+	now := time.Now()
+	contactPreferencesRequest{EmailOptIn: &yes, MarketingOptIn: &yes}.apply(prefs, now)
+	require.Equal(t, first, *prefs.EmailOptedInAt)
+	require.Equal(t, now, *prefs.MarketingOptedInAt)
+	require.Equal(t, now, *prefs.UpdatedAt)
+
+	contactPreferencesRequest{EmailOptIn: &no}.apply(prefs, now)
+	require.False(t, prefs.OptedIn(ContactEmail))
+	require.Nil(t, prefs.EmailOptedInAt)
+	require.True(t, prefs.OptedIn(ContactMarketing))
+
+	var empty *ContactPreferences
+	require.False(t, empty.OptedIn(ContactEmail))
+}
+
+func TestContactPreferences__routes(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+	prefsRepo := NewContactPreferencesRepository(log.NewNopLogger(), repo.db)
+
+	cust := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, "test"))
+
+	router := mux.NewRouter()
+	AddContactPreferenceRoutes(log.NewNopLogger(), router, repo, prefsRepo)
+
+	call := func(method, customerID, body string) (*httptest.ResponseRecorder, ContactPreferences) {
+		req := httptest.NewRequest(method, "/customers/"+customerID+"/contact-preferences", strings.NewReader(body))
+		req.Header.Set("X-Organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var prefs ContactPreferences
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&prefs))
+		}
+		return w, prefs
+	}
+
+	// customers start opted out of everything
+	w, prefs := call("GET", cust.CustomerID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, ContactPreferences{}, prefs)
+
+	w, prefs = call("PUT", cust.CustomerID, `{"emailOptIn": true, "smsOptIn": true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.True(t, prefs.EmailOptIn)
+	require.NotNil(t, prefs.EmailOptedInAt)
+
+	w, prefs = call("PUT", cust.CustomerID, `{"smsOptIn": false}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, prefs.EmailOptIn)
+	require.False(t, prefs.SMSOptIn)
+	require.Nil(t, prefs.SMSOptedInAt)
+
+	saved, err := prefsRepo.GetContactPreferences(cust.CustomerID)
+	require.NoError(t, err)
+	require.True(t, saved.OptedIn(ContactEmail))
+	require.False(t, saved.OptedIn(ContactSMS))
+	require.NotNil(t, saved.UpdatedAt)
+
+	w, _ = call("PUT", cust.CustomerID, `{"emailOptIn": "yes"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = call("GET", base.ID(), "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestContactPreferences__transaction(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+	logger := log.NewNopLogger()
+
+	save := func(fail error) *client.Customer {
+		cust := &client.Customer{
+			CustomerID: base.ID(),
+			FirstName:  "Jane",
+			LastName:   "Doe",
+			Type:       client.CUSTOMERTYPE_INDIVIDUAL,
+			Phones:     []client.Phone{{Number: "+18185551212", Type: client.PHONETYPE_MOBILE}},
+			Addresses:  []client.Address{{Type: client.ADDRESSTYPE_PRIMARY, Address1: "123 1st St", City: "Denver", State: "CO", PostalCode: "12345", Country: "US"}},
+		}
+		err := customersdb.WithTransaction(repo.db, func(tx *sql.Tx) error {
+			if err := NewCustomerRepo(logger, tx).CreateCustomer(context.Background(), cust, "test"); err != nil {
+				return err
+			}
+			if err := NewContactPreferencesRepository(logger, tx).updateContactPreferences(cust.CustomerID, &ContactPreferences{EmailOptIn: true}); err != nil {
+				return err
+			}
+			return fail
+		})
+		require.Equal(t, fail, err)
+		return cust
+	}
+
+	// writes from both repositories are rolled back together
+	cust := save(errors.New("bad error"))
+	found, err := repo.GetCustomer(context.Background(), cust.CustomerID, "test")
+	require.Error(t, err)
+	require.Nil(t, found)
+
+	prefs, err := NewContactPreferencesRepository(logger, repo.db).GetContactPreferences(cust.CustomerID)
+	require.NoError(t, err)
+	require.False(t, prefs.EmailOptIn)
+
+	// and committed together
+	cust = save(nil)
+	found, err = repo.GetCustomer(context.Background(), cust.CustomerID, "test")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	require.Len(t, found.Phones, 1)
+	require.Len(t, found.Addresses, 1)
+
+	prefs, err = NewContactPreferencesRepository(logger, repo.db).GetContactPreferences(cust.CustomerID)
+	require.NoError(t, err)
+	require.True(t, prefs.EmailOptIn)
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// Modes of checking new Customers for duplicates, read from CUSTOMER_DEDUPLICATION
+const (
+	// DeduplicationReject refuses to create a Customer with the same email or SSN as an active Customer
+	DeduplicationReject = "reject"
+
+	// DeduplicationWarn creates the Customer and returns the duplicate's ID in DuplicateCustomerHeader
+	DeduplicationWarn = "warn"
+)
+
+// DuplicateCustomerHeader is set on created Customers to the ID of an existing Customer with the same
+// email or SSN when CUSTOMER_DEDUPLICATION is warn.
+const DuplicateCustomerHeader = "X-Duplicate-Customer-ID"
+
+var (
+	// deduplication controls if new Customers are checked against existing ones, see DeduplicationReject
+	// and DeduplicationWarn. Customers aren't checked when it's empty.
+	deduplication = strings.ToLower(strings.TrimSpace(os.Getenv("CUSTOMER_DEDUPLICATION")))
+)
+
+// duplicateCustomer is an active Customer with the same email or SSN as one being created
+type duplicateCustomer struct {
+	CustomerID string
+
+	// Field is "email" or "ssn"
+	Field string
+}
+
+// duplicateError is returned when a Customer is rejected as a duplicate. The existing Customer's ID is
+// included so clients can use them instead.
+func duplicateError(dup *duplicateCustomer) error {
+	return &route.Error{
+		Status:  http.StatusConflict,
+		Code:    route.CodeConflict,
+		Message: fmt.Sprintf("customer=%s has the same %s", dup.CustomerID, dup.Field),
+		Details: map[string]string{
+			"customerID": dup.CustomerID,
+			"field":      dup.Field,
+		},
+	}
+}
+
+// createDedupedCustomer looks for an active Customer in the organization with the same email or SSN
+// as c in the transaction which creates c and saves their ssn, so concurrent requests see each other.
+// Neither c nor ssn are saved when reject is true and a duplicate is found. Either way the duplicate
+// is returned.
+//
+// Emails are compared by their stored form (plaintext or deterministic ciphertext) and SSNs by their
+// salted hash, so neither is decrypted.
+func (r *sqlCustomerRepository) createDedupedCustomer(ctx context.Context, c *client.Customer, ssn *SSN, organization string, reject bool) (*duplicateCustomer, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	var ssnHash string
+	if ssn != nil {
+		ssnHash = ssn.hash
+	}
+
+	var dup *duplicateCustomer
+	err := customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		var err error
+		dup, err = r.findDuplicateCustomer(ctx, tx, c, organization, ssnHash)
+		if err != nil {
+			return err
+		}
+		if dup != nil && reject {
+			return nil
+		}
+		if err := r.insertCustomer(ctx, tx, c, organization); err != nil {
+			return err
+		}
+		return r.saveSSNTx(tx, ssn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if dup == nil || !reject {
+		customersCreated.With("type", string(c.Type)).Add(1)
+	}
+	return dup, nil
+}
+
+func (r *sqlCustomerRepository) findDuplicateCustomer(ctx context.Context, tx *sql.Tx, c *client.Customer, organization, ssnHash string) (*duplicateCustomer, error) {
+	if c.Email != "" {
+		customerID, err := r.findEmailOwner(ctx, tx, organization, c.CustomerID, c.Email)
+		if err != nil {
+			return nil, fmt.Errorf("findDuplicateCustomer: email: %v", err)
+		}
+		if customerID != "" {
+			return &duplicateCustomer{CustomerID: customerID, Field: "email"}, nil
+		}
+	}
+
+	if ssnHash != "" {
+		query := `select c.customer_id from ssn s inner join customers c on c.customer_id = s.owner_id
+where s.ssn_hash = ? and s.owner_type = ? and c.organization = ? and c.deleted_at is null and c.customer_id <> ?
+order by c.created_at asc limit 1;`
+		customerID, err := queryCustomerID(ctx, tx, query, ssnHash, string(client.OWNERTYPE_CUSTOMER), organization, c.CustomerID)
+		if err != nil {
+			return nil, fmt.Errorf("findDuplicateCustomer: ssn: %v", err)
+		}
+		if customerID != "" {
+			return &duplicateCustomer{CustomerID: customerID, Field: "ssn"}, nil
+		}
+	}
+	return nil, nil
+}
+
+// findEmailOwner returns the ID of the oldest active Customer in the organization, other than customerID,
+// with email as one of their addresses. Deleted Customers and removed addresses are ignored so their
+// emails can be used by new Customers.
+func (r *sqlCustomerRepository) findEmailOwner(ctx context.Context, tx *sql.Tx, organization, customerID, email string) (string, error) {
+	email = normalizeEmail(email)
+	if email == "" {
+		return "", nil
+	}
+	query := `select c.customer_id from customers_emails e inner join customers c on c.customer_id = e.customer_id
+where c.organization = ? and c.deleted_at is null and e.deleted_at is null and c.customer_id <> ? and (lower(e.email) = ?`
+	args := []interface{}{organization, customerID, email}
+	if encrypted := r.encryptedEmailSearch(email); len(encrypted) > 0 {
+		query += fmt.Sprintf(" or e.encrypted_email in (?%s)", strings.Repeat(",?", len(encrypted)-1))
+		for _, v := range encrypted {
+			args = append(args, v)
+		}
+	}
+	query += ") order by c.created_at asc limit 1;"
+	return queryCustomerID(ctx, tx, query, args...)
+}
+
+// checkEmailAvailable rejects giving an existing Customer an email another active Customer has when
+// duplicates are rejected. Addresses the Customer already has aren't checked, so duplicates created
+// before aren't in the way of other changes.
+func (r *sqlCustomerRepository) checkEmailAvailable(ctx context.Context, tx *sql.Tx, customerID, email string) error {
+	if deduplication != DeduplicationReject || normalizeEmail(email) == "" {
+		return nil
+	}
+	existing, err := r.readEmailsTx(ctx, tx, customerID)
+	if err != nil {
+		return err
+	}
+	if sameEmail(existing, email, "") != nil {
+		return nil
+	}
+
+	var organization string
+	if err := tx.QueryRowContext(ctx, `select organization from customers where customer_id = ?;`, customerID).Scan(&organization); err != nil {
+		if err == sql.ErrNoRows {
+			return nil // the update finds the Customer is missing
+		}
+		return fmt.Errorf("checkEmailAvailable: %v", err)
+	}
+	owner, err := r.findEmailOwner(ctx, tx, organization, customerID, email)
+	if err != nil {
+		return fmt.Errorf("checkEmailAvailable: %v", err)
+	}
+	if owner != "" {
+		return duplicateError(&duplicateCustomer{CustomerID: owner, Field: "email"})
+	}
+	return nil
+}
+
+func queryCustomerID(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (string, error) {
+	var customerID string
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&customerID); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	return customerID, nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+func TestCustomers__createDeduplicated(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	ssnRepo := NewCustomerSSNRepository(log.NewNopLogger(), repo.db)
+	storage := NewSSNStorage(secrets.TestStringKeeper(t), ssnRepo, "salt")
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, storage, createTestOFACSearcher(repo, nil), nil, nil)
+
+	create := func(organization, email, ssn string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(map[string]interface{}{
+			"firstName": "Jane",
+			"lastName":  "Doe",
+			"type":      client.CUSTOMERTYPE_INDIVIDUAL,
+			"email":     email,
+			"SSN":       ssn,
+		})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/customers", bytes.NewReader(body))
+		req.Header.Set("X-Organization", organization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	customerID := func(w *httptest.ResponseRecorder) string {
+		var cust client.Customer
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&cust))
+		return cust.CustomerID
+	}
+
+	defer func() { deduplication = "" }()
+	deduplication = DeduplicationReject
+
+	w := create("acme", "jane@example.com", "123-45-6789")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	existingID := customerID(w)
+
+	// emails are compared case insensitively
+	w = create("acme", "Jane@Example.com", "")
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	var resp struct {
+		Code    string            `json:"code"`
+		Details map[string]string `json:"details"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Equal(t, "conflict", resp.Code)
+	require.Equal(t, map[string]string{"customerID": existingID, "field": "email"}, resp.Details)
+
+	w = create("acme", "", "123456789")
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Contains(t, w.Body.String(), `"field":"ssn"`)
+
+	// rejected customers' SSNs aren't saved
+	w = create("acme", "jane@example.com", "234-56-7890")
+	require.Equal(t, http.StatusConflict, w.Code)
+	var ssns int
+	require.NoError(t, repo.db.QueryRow(`select count(*) from ssn;`).Scan(&ssns))
+	require.Equal(t, 1, ssns)
+
+	// customers in other organizations aren't duplicates
+	w = create("other", "jane@example.com", "123-45-6789")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	deduplication = DeduplicationWarn
+	w = create("acme", "jane@example.com", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, existingID, w.Header().Get(DuplicateCustomerHeader))
+	secondID := customerID(w)
+
+	// neither are deleted customers
+	require.NoError(t, repo.deleteCustomer(context.Background(), existingID))
+	require.NoError(t, repo.deleteCustomer(context.Background(), secondID))
+	deduplication = DeduplicationReject
+	w = create("acme", "jane@example.com", "123-45-6789")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Empty(t, w.Header().Get(DuplicateCustomerHeader))
+
+	// disabled
+	deduplication = ""
+	w = create("acme", "jane@example.com", "123-45-6789")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Empty(t, w.Header().Get(DuplicateCustomerHeader))
+}
+
+func TestCustomers__createDeduplicatedEncrypted(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+	repo.fields = secrets.TestFieldEncryptor(t)
+
+	first := &client.Customer{CustomerID: "first", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com"}
+	dup, err := repo.createDedupedCustomer(context.Background(), first, nil, "acme", true)
+	require.NoError(t, err)
+	require.Nil(t, dup)
+
+	second := &client.Customer{CustomerID: "second", FirstName: "Jane", LastName: "Doe", Email: " JANE@example.com"}
+	dup, err = repo.createDedupedCustomer(context.Background(), second, nil, "acme", true)
+	require.NoError(t, err)
+	require.Equal(t, &duplicateCustomer{CustomerID: "first", Field: "email"}, dup)
+
+	_, err = repo.GetCustomer(context.Background(), "second", "acme")
+	require.Error(t, err)
+}
+
+func TestCustomers__deduplicatedEmailChanges(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	defer func() { deduplication = "" }()
+	deduplication = DeduplicationReject
+
+	jane := &client.Customer{CustomerID: "jane", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com"}
+	require.NoError(t, repo.CreateCustomer(context.Background(), jane, "acme"))
+	john := &client.Customer{CustomerID: "john", FirstName: "John", LastName: "Doe", Email: "john@example.com"}
+	require.NoError(t, repo.CreateCustomer(context.Background(), john, "acme"))
+
+	conflict := func(err error) {
+		t.Helper()
+		require.Error(t, err)
+		require.Equal(t, http.StatusConflict, route.Classify(err).Status, err.Error())
+	}
+
+	// active customers can't take each other's emails
+	john.Email = "JANE@example.com"
+	conflict(repo.updateCustomer(context.Background(), john, nil, "acme", anyVersion))
+	conflict(repo.createEmail(context.Background(), "john", &CustomerEmail{EmailID: "john-2", Email: "jane@example.com", Type: EmailWork}))
+
+	emails, err := repo.GetEmails(context.Background(), "john")
+	require.NoError(t, err)
+	primary := *emails[0]
+	primary.Email = "jane@example.com"
+	conflict(repo.updateEmail(context.Background(), "john", &primary))
+
+	// secondary emails count too, until they're removed
+	work := &CustomerEmail{EmailID: "jane-work", Email: "jane@work.example.com", Type: EmailWork}
+	require.NoError(t, repo.createEmail(context.Background(), "jane", work))
+	dup, err := repo.createDedupedCustomer(context.Background(), &client.Customer{CustomerID: "other", FirstName: "Jane", LastName: "Doe", Email: "jane@work.example.com"}, nil, "acme", true)
+	require.NoError(t, err)
+	require.Equal(t, &duplicateCustomer{CustomerID: "jane", Field: "email"}, dup)
+	require.NoError(t, repo.deleteEmail(context.Background(), "jane", "jane-work"))
+	john.Email = "jane@work.example.com"
+	require.NoError(t, repo.updateCustomer(context.Background(), john, nil, "acme", anyVersion))
+
+	// a deleted customer's email can be used again
+	require.NoError(t, repo.deleteCustomer(context.Background(), "jane"))
+	john.Email = "jane@example.com"
+	require.NoError(t, repo.updateCustomer(context.Background(), john, nil, "acme", anyVersion))
+
+	// duplicates created before don't prevent other changes
+	deduplication = ""
+	require.NoError(t, repo.CreateCustomer(context.Background(), &client.Customer{CustomerID: "jane2", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com"}, "acme"))
+	deduplication = DeduplicationReject
+	john.FirstName = "Johnny"
+	require.NoError(t, repo.updateCustomer(context.Background(), john, nil, "acme", anyVersion))
+}
@@ -0,0 +1,563 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+// EmailType describes whose inbox an email address is
+type EmailType string
+
+const (
+	EmailPersonal EmailType = "personal"
+	EmailWork     EmailType = "work"
+)
+
+// CustomerEmail is one of a Customer's email addresses. The primary address is also the Customer's
+// email field, so clients which only know of one address keep working. Changing the primary
+// address through either one updates the other.
+type CustomerEmail struct {
+	EmailID      string     `json:"emailID"`
+	Email        string     `json:"email"`
+	Type         EmailType  `json:"type"`
+	Primary      bool       `json:"primary"`
+	Verified     bool       `json:"verified"`
+	VerifiedAt   *time.Time `json:"verifiedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	LastModified time.Time  `json:"lastModified"`
+}
+
+var errEmailNotFound = route.NewError(http.StatusNotFound, route.CodeNotFound, "email not found")
+
+// CustomerEmailRepository reads and writes the email addresses of Customers
+type CustomerEmailRepository interface {
+	// GetEmails returns the Customer's email addresses, primary first
+	GetEmails(ctx context.Context, customerID string) ([]*CustomerEmail, error)
+
+	// GetEmail returns one of the Customer's email addresses or nil if it isn't found
+	GetEmail(ctx context.Context, customerID, emailID string) (*CustomerEmail, error)
+
+	// MarkEmailVerified records that the Customer confirmed they own the address, as long as it's
+	// still email. It returns false when the address was changed or removed since.
+	MarkEmailVerified(ctx context.Context, customerID, emailID, email string, verifiedAt time.Time) (bool, error)
+
+	createEmail(ctx context.Context, customerID string, email *CustomerEmail) error
+	updateEmail(ctx context.Context, customerID string, email *CustomerEmail) error
+	deleteEmail(ctx context.Context, customerID, emailID string) error
+}
+
+// NewCustomerEmailRepository returns a CustomerEmailRepository which encrypts addresses with fields
+// the same way NewCustomerRepoWithEncryption does. fields can be nil.
+func NewCustomerEmailRepository(logger log.Logger, db customersdb.Querier, fields *secrets.FieldEncryptor) CustomerEmailRepository {
+	return &sqlCustomerRepository{
+		db:     db,
+		logger: logger,
+		fields: fields,
+	}
+}
+
+func AddCustomerEmailRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository, emails CustomerEmailRepository) {
+	logger = logger.Set("package", log.String("customers"))
+
+	r.Methods("GET").Path("/customers/{customerID}/emails").HandlerFunc(getCustomerEmails(logger, repo, emails))
+	r.Methods("POST").Path("/customers/{customerID}/emails").HandlerFunc(createCustomerEmail(logger, repo, emails))
+	r.Methods("PUT").Path("/customers/{customerID}/emails/{emailID}").HandlerFunc(updateCustomerEmail(logger, repo, emails))
+	r.Methods("DELETE").Path("/customers/{customerID}/emails/{emailID}").HandlerFunc(deleteCustomerEmail(logger, repo, emails))
+}
+
+// customerEmailRequest creates or changes an email address, omitted fields are left as they are
+type customerEmailRequest struct {
+	Email   *string    `json:"email"`
+	Type    *EmailType `json:"type"`
+	Primary *bool      `json:"primary"`
+}
+
+func (req customerEmailRequest) apply(email *CustomerEmail) error {
+	if req.Email != nil {
+		addr := strings.TrimSpace(*req.Email)
+		if _, err := mail.ParseAddress(addr); err != nil || strings.Contains(addr, "<") {
+			return route.Validation(fmt.Errorf("invalid email: %q", addr))
+		}
+		if normalizeEmail(addr) != normalizeEmail(email.Email) {
+			email.Verified, email.VerifiedAt = false, nil
+		}
+		email.Email = addr
+	}
+	if req.Type != nil {
+		switch t := EmailType(strings.ToLower(string(*req.Type))); t {
+		case EmailPersonal, EmailWork:
+			email.Type = t
+		default:
+			return route.Validation(fmt.Errorf("unknown email type: %s", *req.Type))
+		}
+	}
+	if req.Primary != nil {
+		if email.Primary && !*req.Primary {
+			return route.Validation(errors.New("make another email primary instead"))
+		}
+		email.Primary = *req.Primary
+	}
+	return nil
+}
+
+// sameEmail returns the email of emails with the same address as email, ignoring case, other than skipID
+func sameEmail(emails []*CustomerEmail, email, skipID string) *CustomerEmail {
+	for i := range emails {
+		if emails[i].EmailID != skipID && normalizeEmail(emails[i].Email) == normalizeEmail(email) {
+			return emails[i]
+		}
+	}
+	return nil
+}
+
+func duplicateEmailError(dup *CustomerEmail) error {
+	return &route.Error{
+		Status:  http.StatusConflict,
+		Code:    route.CodeConflict,
+		Message: "customer already has this email",
+		Details: map[string]string{"emailID": dup.EmailID},
+	}
+}
+
+func getCustomerEmails(logger log.Logger, repo CustomerRepository, emails CustomerEmailRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		out, err := emails.GetEmails(r.Context(), customerID)
+		if err != nil {
+			logger.Set("customerID", log.String(customerID)).LogErrorf("problem reading emails: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(out)
+	}
+}
+
+func createCustomerEmail(logger log.Logger, repo CustomerRepository, emails CustomerEmailRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		var req customerEmailRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, fmt.Errorf("reading email: %v", err))
+			return
+		}
+		if req.Email == nil {
+			route.Problem(w, route.Validation(errors.New("missing email")))
+			return
+		}
+		email := &CustomerEmail{
+			EmailID: base.ID(),
+			Type:    EmailPersonal,
+		}
+		if err := req.apply(email); err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+		existing, err := emails.GetEmails(r.Context(), customerID)
+		if err != nil {
+			logger.LogErrorf("problem reading emails: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		if dup := sameEmail(existing, email.Email, ""); dup != nil {
+			route.Problem(w, duplicateEmailError(dup))
+			return
+		}
+
+		if err := emails.createEmail(r.Context(), customerID, email); err != nil {
+			logger.LogErrorf("problem saving email: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		logger.Set("emailID", log.String(email.EmailID)).Log("added email")
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(email)
+	}
+}
+
+func updateCustomerEmail(logger log.Logger, repo CustomerRepository, emails CustomerEmailRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		emailID := mux.Vars(r)["emailID"]
+
+		var req customerEmailRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, fmt.Errorf("reading email: %v", err))
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID)).Set("emailID", log.String(emailID))
+		existing, err := emails.GetEmails(r.Context(), customerID)
+		if err != nil {
+			logger.LogErrorf("problem reading emails: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		var email *CustomerEmail
+		for i := range existing {
+			if existing[i].EmailID == emailID {
+				email = existing[i]
+			}
+		}
+		if email == nil {
+			route.NotFound(w, r)
+			return
+		}
+		if err := req.apply(email); err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if dup := sameEmail(existing, email.Email, emailID); dup != nil {
+			route.Problem(w, duplicateEmailError(dup))
+			return
+		}
+
+		if err := emails.updateEmail(r.Context(), customerID, email); err != nil {
+			logger.LogErrorf("problem updating email: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		logger.Log("updated email")
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(email)
+	}
+}
+
+func deleteCustomerEmail(logger log.Logger, repo CustomerRepository, emails CustomerEmailRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		emailID := mux.Vars(r)["emailID"]
+		if err := emails.deleteEmail(r.Context(), customerID, emailID); err != nil {
+			if err != errEmailNotFound {
+				logger.Set("customerID", log.String(customerID)).LogErrorf("problem deleting email: %v", err)
+			}
+			route.Problem(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+const customerEmailColumns = `email_id, email, encrypted_email, type, is_primary, verified, verified_at, created_at, last_modified`
+
+func (r *sqlCustomerRepository) scanEmails(rows *sql.Rows) ([]*CustomerEmail, error) {
+	defer rows.Close()
+
+	out := make([]*CustomerEmail, 0)
+	for rows.Next() {
+		var e CustomerEmail
+		var plain, encrypted sql.NullString
+		if err := rows.Scan(&e.EmailID, &plain, &encrypted, &e.Type, &e.Primary, &e.Verified, &e.VerifiedAt, &e.CreatedAt, &e.LastModified); err != nil {
+			return nil, fmt.Errorf("scanning emails: %v", err)
+		}
+		addr, err := r.readEmail(plain, encrypted)
+		if err != nil {
+			return nil, err
+		}
+		e.Email = addr
+		out = append(out, &e)
+	}
+	return out, rows.Err()
+}
+
+func (r *sqlCustomerRepository) readEmailsTx(ctx context.Context, tx *sql.Tx, customerID string) ([]*CustomerEmail, error) {
+	query := `select ` + customerEmailColumns + ` from customers_emails where customer_id = ? and deleted_at is null order by is_primary desc, created_at asc, email_id asc;`
+	rows, err := tx.QueryContext(ctx, query, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("reading emails: %v", err)
+	}
+	return r.scanEmails(rows)
+}
+
+func (r *sqlCustomerRepository) GetEmails(ctx context.Context, customerID string) ([]*CustomerEmail, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select ` + customerEmailColumns + ` from customers_emails where customer_id = ? and deleted_at is null order by is_primary desc, created_at asc, email_id asc;`
+	rows, err := r.db.QueryContext(ctx, query, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("GetEmails: %v", err)
+	}
+	return r.scanEmails(rows)
+}
+
+func (r *sqlCustomerRepository) GetEmail(ctx context.Context, customerID, emailID string) (*CustomerEmail, error) {
+	emails, err := r.GetEmails(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range emails {
+		if emails[i].EmailID == emailID {
+			return emails[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *sqlCustomerRepository) createEmail(ctx context.Context, customerID string, email *CustomerEmail) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	return customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		existing, err := r.readEmailsTx(ctx, tx, customerID)
+		if err != nil {
+			return err
+		}
+		if err := r.checkEmailAvailable(ctx, tx, customerID, email.Email); err != nil {
+			return err
+		}
+		if len(existing) == 0 || !existing[0].Primary {
+			email.Primary = true // the first email is always primary
+		}
+		now := time.Now()
+		email.CreatedAt, email.LastModified = now, now
+		return r.insertEmailTx(ctx, tx, customerID, email)
+	})
+}
+
+func (r *sqlCustomerRepository) insertEmailTx(ctx context.Context, tx *sql.Tx, customerID string, email *CustomerEmail) error {
+	if email.Primary {
+		if err := r.setPrimaryEmailTx(ctx, tx, customerID, email.EmailID, email.Email, email.LastModified); err != nil {
+			return err
+		}
+	}
+	plain, encrypted, err := r.emailColumns(email.Email)
+	if err != nil {
+		return err
+	}
+	query := `insert into customers_emails (email_id, customer_id, email, encrypted_email, type, is_primary, verified, verified_at, created_at, last_modified) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err := tx.ExecContext(ctx, query, email.EmailID, customerID, plain, encrypted, email.Type, email.Primary, email.Verified, email.VerifiedAt, email.CreatedAt, email.LastModified); err != nil {
+		return fmt.Errorf("inserting email: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlCustomerRepository) updateEmail(ctx context.Context, customerID string, email *CustomerEmail) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	return customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		if err := r.checkEmailAvailable(ctx, tx, customerID, email.Email); err != nil {
+			return err
+		}
+		email.LastModified = time.Now()
+		if email.Primary {
+			if err := r.setPrimaryEmailTx(ctx, tx, customerID, email.EmailID, email.Email, email.LastModified); err != nil {
+				return err
+			}
+		}
+		plain, encrypted, err := r.emailColumns(email.Email)
+		if err != nil {
+			return err
+		}
+		query := `update customers_emails set email = ?, encrypted_email = ?, type = ?, is_primary = ?, verified = ?, verified_at = ?, last_modified = ?
+where customer_id = ? and email_id = ? and deleted_at is null;`
+		res, err := tx.ExecContext(ctx, query, plain, encrypted, email.Type, email.Primary, email.Verified, email.VerifiedAt, email.LastModified, customerID, email.EmailID)
+		if err != nil {
+			return fmt.Errorf("updating email: %v", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errEmailNotFound
+		}
+		return nil
+	})
+}
+
+func (r *sqlCustomerRepository) deleteEmail(ctx context.Context, customerID, emailID string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	return customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		var primary bool
+		query := `select is_primary from customers_emails where customer_id = ? and email_id = ? and deleted_at is null;`
+		if err := tx.QueryRowContext(ctx, query, customerID, emailID).Scan(&primary); err != nil {
+			if err == sql.ErrNoRows {
+				return errEmailNotFound
+			}
+			return fmt.Errorf("deleting email: %v", err)
+		}
+		now := time.Now()
+		query = `update customers_emails set deleted_at = ?, is_primary = ?, last_modified = ? where email_id = ?;`
+		if _, err := tx.ExecContext(ctx, query, now, false, now, emailID); err != nil {
+			return fmt.Errorf("deleting email: %v", err)
+		}
+		if primary {
+			return r.writeCustomerEmailTx(ctx, tx, customerID, "", now)
+		}
+		return nil
+	})
+}
+
+func (r *sqlCustomerRepository) MarkEmailVerified(ctx context.Context, customerID, emailID, email string, verifiedAt time.Time) (bool, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	var verified bool
+	err := customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		existing, err := r.readEmailsTx(ctx, tx, customerID)
+		if err != nil {
+			return err
+		}
+		if e := sameEmail(existing, email, ""); e == nil || e.EmailID != emailID {
+			verified = false
+			return nil
+		}
+		query := `update customers_emails set verified = ?, verified_at = ?, last_modified = ? where email_id = ?;`
+		if _, err := tx.ExecContext(ctx, query, true, verifiedAt, verifiedAt, emailID); err != nil {
+			return fmt.Errorf("verifying email: %v", err)
+		}
+		verified = true
+		return nil
+	})
+	return verified, err
+}
+
+// setPrimaryEmailTx demotes the Customer's other emails and copies email into their email field
+func (r *sqlCustomerRepository) setPrimaryEmailTx(ctx context.Context, tx *sql.Tx, customerID, emailID, email string, now time.Time) error {
+	query := `update customers_emails set is_primary = ?, last_modified = ? where customer_id = ? and email_id <> ? and is_primary = ?;`
+	if _, err := tx.ExecContext(ctx, query, false, now, customerID, emailID, true); err != nil {
+		return fmt.Errorf("demoting primary email: %v", err)
+	}
+	return r.writeCustomerEmailTx(ctx, tx, customerID, email, now)
+}
+
+// writeCustomerEmailTx changes the Customer's email field without touching their emails
+func (r *sqlCustomerRepository) writeCustomerEmailTx(ctx context.Context, tx *sql.Tx, customerID, email string, now time.Time) error {
+	plain, encrypted, err := r.emailColumns(email)
+	if err != nil {
+		return err
+	}
+	query := `update customers set email = ?, encrypted_email = ?, last_modified = ?, version = version + 1 where customer_id = ?;`
+	if _, err := tx.ExecContext(ctx, query, plain, encrypted, now, customerID); err != nil {
+		return fmt.Errorf("updating customer email: %v", err)
+	}
+	return nil
+}
+
+// syncPrimaryEmail keeps the Customer's emails in step after their email field is written by a
+// create or update. An email the Customer already has becomes primary, otherwise the primary
+// email's address is replaced (and is no longer verified) or a primary email is added. Emptying
+// the field deletes the primary email.
+func (r *sqlCustomerRepository) syncPrimaryEmail(ctx context.Context, tx *sql.Tx, customerID, email string) error {
+	existing, err := r.readEmailsTx(ctx, tx, customerID)
+	if err != nil {
+		return err
+	}
+	var primary *CustomerEmail
+	if len(existing) > 0 && existing[0].Primary {
+		primary = existing[0]
+	}
+
+	now := time.Now()
+	switch match := sameEmail(existing, email, ""); {
+	case email == "":
+		if primary == nil {
+			return nil
+		}
+		query := `update customers_emails set deleted_at = ?, is_primary = ?, last_modified = ? where email_id = ?;`
+		if _, err := tx.ExecContext(ctx, query, now, false, now, primary.EmailID); err != nil {
+			return fmt.Errorf("deleting primary email: %v", err)
+		}
+
+	case match != nil:
+		if match.Primary {
+			return nil
+		}
+		query := `update customers_emails set is_primary = ?, last_modified = ? where customer_id = ? and email_id <> ? and is_primary = ?;`
+		if _, err := tx.ExecContext(ctx, query, false, now, customerID, match.EmailID, true); err != nil {
+			return fmt.Errorf("demoting primary email: %v", err)
+		}
+		query = `update customers_emails set is_primary = ?, last_modified = ? where email_id = ?;`
+		if _, err := tx.ExecContext(ctx, query, true, now, match.EmailID); err != nil {
+			return fmt.Errorf("promoting email: %v", err)
+		}
+
+	case primary != nil:
+		plain, encrypted, err := r.emailColumns(email)
+		if err != nil {
+			return err
+		}
+		query := `update customers_emails set email = ?, encrypted_email = ?, verified = ?, verified_at = null, last_modified = ? where email_id = ?;`
+		if _, err := tx.ExecContext(ctx, query, plain, encrypted, false, now, primary.EmailID); err != nil {
+			return fmt.Errorf("updating primary email: %v", err)
+		}
+
+	default:
+		plain, encrypted, err := r.emailColumns(email)
+		if err != nil {
+			return err
+		}
+		query := `insert into customers_emails (email_id, customer_id, email, encrypted_email, type, is_primary, verified, created_at, last_modified) values (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+		if _, err := tx.ExecContext(ctx, query, base.ID(), customerID, plain, encrypted, EmailPersonal, true, false, now, now); err != nil {
+			return fmt.Errorf("inserting primary email: %v", err)
+		}
+	}
+	return nil
+}
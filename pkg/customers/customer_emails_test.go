@@ -0,0 +1,125 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestCustomerEmails(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	emails := NewCustomerEmailRepository(log.NewNopLogger(), repo.db, nil)
+	router := mux.NewRouter()
+	AddCustomerEmailRoutes(log.NewNopLogger(), router, repo, emails)
+
+	cust := &client.Customer{CustomerID: "jane", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, "test"))
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/customers/jane/emails"+path, strings.NewReader(body))
+		req.Header.Set("X-Organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	list := func() []*CustomerEmail {
+		w := call("GET", "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var out []*CustomerEmail
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&out))
+		return out
+	}
+	customerEmail := func() string {
+		got, err := repo.GetCustomer(context.Background(), "jane", "test")
+		require.NoError(t, err)
+		return got.Email
+	}
+
+	// the customer's email is their primary email
+	got := list()
+	require.Len(t, got, 1)
+	require.Equal(t, "jane@example.com", got[0].Email)
+	require.True(t, got[0].Primary)
+	require.Equal(t, EmailPersonal, got[0].Type)
+	primaryID := got[0].EmailID
+
+	w := call("POST", "", `{"email": "jane@work.example.com", "type": "Work"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var work CustomerEmail
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&work))
+	require.Equal(t, EmailWork, work.Type)
+	require.False(t, work.Primary)
+
+	require.Equal(t, http.StatusConflict, call("POST", "", `{"email": "JANE@example.com"}`).Code)
+	require.Equal(t, http.StatusBadRequest, call("POST", "", `{"email": "not an email"}`).Code)
+	require.Equal(t, http.StatusBadRequest, call("POST", "", `{"email": "jane@home.example.com", "type": "school"}`).Code)
+	require.Equal(t, http.StatusBadRequest, call("PUT", "/"+primaryID, `{"primary": false}`).Code)
+	require.Equal(t, http.StatusNotFound, call("PUT", "/missing", `{"type": "work"}`).Code)
+
+	// making another email primary updates the customer
+	w = call("PUT", "/"+work.EmailID, `{"primary": true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "jane@work.example.com", customerEmail())
+	got = list()
+	require.Equal(t, work.EmailID, got[0].EmailID)
+	require.False(t, got[1].Primary)
+
+	// verifying only applies while the address is unchanged
+	verified, err := emails.MarkEmailVerified(context.Background(), "jane", work.EmailID, "jane@work.example.com", time.Now())
+	require.NoError(t, err)
+	require.True(t, verified)
+	w = call("PUT", "/"+work.EmailID, `{"email": "jane@new-work.example.com"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "jane@new-work.example.com", customerEmail())
+	got = list()
+	require.False(t, got[0].Verified)
+	verified, err = emails.MarkEmailVerified(context.Background(), "jane", work.EmailID, "jane@work.example.com", time.Now())
+	require.NoError(t, err)
+	require.False(t, verified)
+
+	// updating the customer's email changes their primary email
+	cust.Email = "jane@example.com"
+	require.NoError(t, repo.updateCustomer(context.Background(), cust, nil, "test", anyVersion))
+	got = list()
+	require.Len(t, got, 2)
+	require.Equal(t, primaryID, got[0].EmailID)
+	require.True(t, got[0].Primary)
+
+	cust.Email = "jane@other.example.com"
+	require.NoError(t, repo.updateCustomer(context.Background(), cust, nil, "test", anyVersion))
+	got = list()
+	require.Len(t, got, 2)
+	require.Equal(t, primaryID, got[0].EmailID)
+	require.Equal(t, "jane@other.example.com", got[0].Email)
+
+	// deleting the primary email clears the customer's
+	require.Equal(t, http.StatusNoContent, call("DELETE", "/"+primaryID, "").Code)
+	require.Equal(t, http.StatusNotFound, call("DELETE", "/"+primaryID, "").Code)
+	require.Empty(t, customerEmail())
+	got = list()
+	require.Len(t, got, 1)
+	require.False(t, got[0].Primary)
+
+	// customers in other organizations aren't found
+	req := httptest.NewRequest("GET", "/customers/jane/emails", nil)
+	req.Header.Set("X-Organization", "other")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
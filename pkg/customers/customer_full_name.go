@@ -0,0 +1,121 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"errors"
+	"strings"
+)
+
+// fullNameMetadataKey holds the original full name of Customers whose name was parsed from it
+const fullNameMetadataKey = "fullName"
+
+var errIncompleteFullName = errors.New("full name must include a first and last name")
+
+// ParsedName is a full name split into a Customer's name fields
+type ParsedName struct {
+	FirstName  string
+	MiddleName string
+	LastName   string
+	Suffix     string
+}
+
+var (
+	// nameTitles are dropped from the start of a full name
+	nameTitles = map[string]bool{
+		"mr": true, "mrs": true, "ms": true, "miss": true, "mx": true, "dr": true,
+	}
+
+	// nameSuffixes are generational and professional suffixes found at the end of a full name
+	nameSuffixes = map[string]bool{
+		"jr": true, "sr": true, "ii": true, "iii": true, "iv": true,
+		"md": true, "phd": true, "dds": true, "esq": true, "cpa": true,
+	}
+
+	// lastNameParticles start a multi-word last name, like "van der Berg" or "de la Cruz"
+	lastNameParticles = map[string]bool{
+		"da": true, "das": true, "de": true, "del": true, "della": true, "der": true, "di": true, "dos": true,
+		"du": true, "la": true, "le": true, "st": true, "ten": true, "ter": true, "van": true, "von": true,
+		"bin": true, "ibn": true, "al": true,
+	}
+)
+
+func nameWord(token string) string {
+	return strings.ToLower(strings.Trim(token, ".,"))
+}
+
+// ParseFullName splits a single full name, like "Dr. Jane A. van der Berg Jr.", into its first, middle
+// and last names and suffix. Titles are dropped. Names written last name first with a comma, like
+// "Doe, Jane A", are understood. Particles such as "van" or "de" are kept with the last name.
+func ParseFullName(fullName string) (ParsedName, error) {
+	var name ParsedName
+
+	tokens := strings.Fields(fullName)
+	for len(tokens) > 0 && nameTitles[nameWord(tokens[0])] {
+		tokens = tokens[1:]
+	}
+	for len(tokens) > 1 && nameSuffixes[nameWord(tokens[len(tokens)-1])] {
+		suffix := strings.TrimRight(tokens[len(tokens)-1], ",")
+		if name.Suffix != "" {
+			suffix += " " + name.Suffix
+		}
+		name.Suffix = suffix
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) > 0 {
+		tokens[len(tokens)-1] = strings.TrimRight(tokens[len(tokens)-1], ",")
+	}
+
+	// "Last, First Middle"
+	for i := range tokens {
+		if strings.HasSuffix(tokens[i], ",") && i < len(tokens)-1 {
+			last := strings.Join(tokens[:i+1], " ")
+			rest := tokens[i+1:]
+			name.LastName = strings.TrimRight(last, ",")
+			name.FirstName = rest[0]
+			name.MiddleName = strings.Join(rest[1:], " ")
+			return name, nil
+		}
+	}
+
+	if len(tokens) < 2 {
+		return name, errIncompleteFullName
+	}
+
+	// The last name starts at the final word, or earlier for a run of particles after the first name
+	start := len(tokens) - 1
+	for start > 1 && lastNameParticles[nameWord(tokens[start-1])] {
+		start--
+	}
+	name.FirstName = tokens[0]
+	name.MiddleName = strings.Join(tokens[1:start], " ")
+	name.LastName = strings.Join(tokens[start:], " ")
+	return name, nil
+}
+
+// applyFullName fills in the Customer's name from FullName when neither their first nor last name is
+// given and keeps the original in their metadata.
+func (req *customerRequest) applyFullName() error {
+	full := strings.TrimSpace(req.FullName)
+	if full == "" || req.FirstName != "" || req.LastName != "" {
+		return nil
+	}
+	name, err := ParseFullName(full)
+	if err != nil {
+		return err
+	}
+	req.FirstName, req.MiddleName, req.LastName = name.FirstName, name.MiddleName, name.LastName
+	if req.Suffix == "" {
+		req.Suffix = name.Suffix
+	}
+
+	if req.Metadata == nil {
+		req.Metadata = make(map[string]string)
+	}
+	if _, exists := req.Metadata[fullNameMetadataKey]; !exists {
+		req.Metadata[fullNameMetadataKey] = full
+	}
+	return nil
+}
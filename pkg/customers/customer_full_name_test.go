@@ -0,0 +1,86 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestParseFullName(t *testing.T) {
+	cases := map[string]ParsedName{
+		"Jane Doe":                     {FirstName: "Jane", LastName: "Doe"},
+		"  Jane   Ann  Doe ":           {FirstName: "Jane", MiddleName: "Ann", LastName: "Doe"},
+		"Dr. Jane A. Doe":              {FirstName: "Jane", MiddleName: "A.", LastName: "Doe"},
+		"John Smith Jr.":               {FirstName: "John", LastName: "Smith", Suffix: "Jr."},
+		"John Smith, Jr.":              {FirstName: "John", LastName: "Smith", Suffix: "Jr."},
+		"John Paul Smith III":          {FirstName: "John", MiddleName: "Paul", LastName: "Smith", Suffix: "III"},
+		"John Smith Jr MD":             {FirstName: "John", LastName: "Smith", Suffix: "Jr MD"},
+		"Ludwig van Beethoven":         {FirstName: "Ludwig", LastName: "van Beethoven"},
+		"Maria Elena de la Cruz":       {FirstName: "Maria", MiddleName: "Elena", LastName: "de la Cruz"},
+		"Jane Smith-Jones":             {FirstName: "Jane", LastName: "Smith-Jones"},
+		"Doe, Jane A":                  {FirstName: "Jane", MiddleName: "A", LastName: "Doe"},
+		"de la Cruz, Maria":            {FirstName: "Maria", LastName: "de la Cruz"},
+		"Mrs. Jane van der Berg, Esq.": {FirstName: "Jane", LastName: "van der Berg", Suffix: "Esq."},
+		"Van Morrison":                 {FirstName: "Van", LastName: "Morrison"},
+	}
+	for full, expected := range cases {
+		name, err := ParseFullName(full)
+		require.NoError(t, err, full)
+		require.Equal(t, expected, name, full)
+	}
+
+	for _, full := range []string{"", "   ", "Jane", "Dr. Jane", "Doe,", "Jr."} {
+		_, err := ParseFullName(full)
+		require.Equal(t, errIncompleteFullName, err, full)
+	}
+}
+
+func TestCustomers__createWithFullName(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/customers", strings.NewReader(body))
+		req.Header.Set("X-Organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := create(`{"fullName": "Dr. Jane Ann van Doe Jr.", "type": "individual", "metadata": {"source": "crm"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var cust client.Customer
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&cust))
+	require.Equal(t, "Jane", cust.FirstName)
+	require.Equal(t, "Ann", cust.MiddleName)
+	require.Equal(t, "van Doe", cust.LastName)
+	require.Equal(t, "Jr.", cust.Suffix)
+	require.Equal(t, "Dr. Jane Ann van Doe Jr.", cust.Metadata[fullNameMetadataKey])
+	require.Equal(t, "crm", cust.Metadata["source"])
+
+	// structured fields take precedence
+	w = create(`{"firstName": "John", "lastName": "Doe", "fullName": "Jim Smith", "type": "individual"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var structured client.Customer
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&structured))
+	require.Equal(t, "John", structured.FirstName)
+	require.Empty(t, structured.Metadata[fullNameMetadataKey])
+
+	w = create(`{"fullName": "Jane", "type": "individual"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
@@ -0,0 +1,303 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/internal/util"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+const (
+	maxImportSize = 10 * 1024 * 1024 // 10MB
+	maxImportRows = 10000
+
+	// importBatchSize is how many Customers are written in each transaction
+	importBatchSize = 100
+)
+
+// importColumns are the CSV header names an import can contain. Header names are case-insensitive
+// and columns can be in any order. Only firstName and lastName, or fullName, are required.
+var importColumns = map[string]func(req *customerRequest, value string){
+	"firstname":  func(req *customerRequest, v string) { req.FirstName = v },
+	"lastname":   func(req *customerRequest, v string) { req.LastName = v },
+	"fullname":   func(req *customerRequest, v string) { req.FullName = v },
+	"email":      func(req *customerRequest, v string) { req.Email = v },
+	"phone":      func(req *customerRequest, v string) { importPhone(req).Number = v },
+	"phonetype":  func(req *customerRequest, v string) { importPhone(req).Type = client.PhoneType(v) },
+	"address1":   func(req *customerRequest, v string) { importAddress(req).Address1 = v },
+	"address2":   func(req *customerRequest, v string) { importAddress(req).Address2 = v },
+	"city":       func(req *customerRequest, v string) { importAddress(req).City = v },
+	"state":      func(req *customerRequest, v string) { importAddress(req).State = v },
+	"postalcode": func(req *customerRequest, v string) { importAddress(req).PostalCode = v },
+	"country":    func(req *customerRequest, v string) { importAddress(req).Country = v },
+}
+
+func importPhone(req *customerRequest) *phone {
+	if len(req.Phones) == 0 {
+		req.Phones = append(req.Phones, phone{OwnerType: client.OWNERTYPE_CUSTOMER})
+	}
+	return &req.Phones[0]
+}
+
+func importAddress(req *customerRequest) *address {
+	if len(req.Addresses) == 0 {
+		req.Addresses = append(req.Addresses, address{Type: client.ADDRESSTYPE_PRIMARY, OwnerType: client.OWNERTYPE_CUSTOMER})
+	}
+	return &req.Addresses[0]
+}
+
+type importReport struct {
+	DryRun  bool              `json:"dryRun"`
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Rows    []importRowResult `json:"rows"`
+}
+
+type importRowResult struct {
+	// Row is the record number in the CSV file, the header is row 1
+	Row        int    `json:"row"`
+	CustomerID string `json:"customerID,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	// DuplicateCustomerID is an existing Customer with the same email when CUSTOMER_DEDUPLICATION is warn
+	DuplicateCustomerID string `json:"duplicateCustomerID,omitempty"`
+
+	// OFACRejected is set when the post-import OFAC search rejected the Customer
+	OFACRejected bool   `json:"ofacRejected,omitempty"`
+	OFACError    string `json:"ofacError,omitempty"`
+
+	customer *client.Customer
+}
+
+type importRow struct {
+	line int
+	req  customerRequest
+}
+
+// readImportRows parses the CSV header and each row into a customerRequest.
+// Rows are returned even if they're invalid.
+func readImportRows(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // rows with the wrong number of columns are reported per row
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, errors.New("empty CSV file")
+		}
+		return nil, fmt.Errorf("reading CSV header: %v", err)
+	}
+	columns := make([]string, len(header))
+	seen := make(map[string]bool)
+	for i := range header {
+		name := strings.ToLower(strings.TrimSpace(header[i]))
+		if _, ok := importColumns[name]; !ok {
+			return nil, fmt.Errorf("unknown CSV column %q", header[i])
+		}
+		columns[i], seen[name] = name, true
+	}
+	if (!seen["firstname"] || !seen["lastname"]) && !seen["fullname"] {
+		return nil, errors.New("CSV header must include firstName and lastName or fullName")
+	}
+
+	var rows []importRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) >= maxImportRows {
+			return nil, fmt.Errorf("imports are limited to %d rows", maxImportRows)
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("reading CSV: %v", err)
+			}
+			rows = append(rows, importRow{line: line})
+			continue
+		}
+
+		row := importRow{
+			line: line,
+			req:  customerRequest{Type: client.CUSTOMERTYPE_INDIVIDUAL},
+		}
+		if len(record) != len(columns) {
+			rows = append(rows, row)
+			continue
+		}
+		for i := range record {
+			if v := strings.TrimSpace(record[i]); v != "" {
+				importColumns[columns[i]](&row.req, v)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// importCustomers creates Customers from an uploaded CSV file. Each row is validated on its own so invalid
+// rows are reported without aborting the import.
+//
+// With dryRun=true rows are only validated. With ofac=true an OFAC search is run on each created Customer.
+func importCustomers(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage, ofac *OFACSearcher, notifier webhooks.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		requestID, organization := moovhttp.GetRequestID(r), route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			route.Problem(w, fmt.Errorf("expected multipart upload with key of 'file' error=%v", err))
+			return
+		}
+		defer file.Close()
+
+		rows, err := readImportRows(file)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+
+		report := &importReport{
+			DryRun: util.Yes(r.URL.Query().Get("dryRun")),
+		}
+		var valid []*importRowResult
+		for i := range rows {
+			result := importRowResult{Row: rows[i].line}
+			if err := validateImportRow(&rows[i]); err != nil {
+				result.Error = err.Error()
+			} else if result.customer, _, err = rows[i].req.asCustomer(customerSSNStorage); err != nil {
+				result.Error = err.Error()
+			}
+			report.Rows = append(report.Rows, result)
+		}
+		for i := range report.Rows {
+			if report.Rows[i].Error == "" {
+				valid = append(valid, &report.Rows[i])
+			}
+		}
+
+		if !report.DryRun {
+			saveImportedCustomers(r.Context(), logger, repo, organization, valid)
+		}
+
+		for i := range report.Rows {
+			res := &report.Rows[i]
+			if res.Error != "" {
+				report.Failed++
+				continue
+			}
+			if report.DryRun {
+				continue
+			}
+			report.Created++
+			res.CustomerID = res.customer.CustomerID
+			notify(notifier, webhooks.CustomerCreated, res.CustomerID, organization, client.CUSTOMERSTATUS_UNKNOWN)
+
+			if ofac != nil && util.Yes(r.URL.Query().Get("ofac")) {
+				screenImportedCustomer(r.Context(), logger, repo, ofac, organization, requestID, route.GetActor(r), res)
+			}
+		}
+		logger.Logf("imported %d customers (%d failed, dryRun=%v)", report.Created, report.Failed, report.DryRun)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+func validateImportRow(row *importRow) error {
+	if row.req.FirstName == "" && row.req.LastName == "" && row.req.FullName == "" && row.req.Email == "" {
+		return errors.New("invalid row: unexpected number of columns or empty row")
+	}
+	if err := row.req.applyFullName(); err != nil {
+		return err
+	}
+	if len(row.req.Addresses) > 0 && row.req.Addresses[0].Address1 == "" {
+		return errors.New("invalid customer addresses: missing address1")
+	}
+	return row.req.validate()
+}
+
+// saveImportedCustomers writes Customers in batches. If a batch fails each Customer from it is retried
+// on its own so one bad row doesn't fail the others.
+//
+// When CUSTOMER_DEDUPLICATION is set each Customer is checked for duplicates and created on its own, like
+// those created over HTTP, so rows matching an existing Customer or an earlier row are rejected or flagged.
+func saveImportedCustomers(ctx context.Context, logger log.Logger, repo CustomerRepository, organization string, rows []*importRowResult) {
+	if deduplication == DeduplicationReject || deduplication == DeduplicationWarn {
+		for i := range rows {
+			dup, err := saveNewCustomer(ctx, logger, repo, nil, rows[i].customer, nil, organization)
+			if err != nil {
+				rows[i].Error = err.Error()
+			} else if dup != nil {
+				rows[i].DuplicateCustomerID = dup.CustomerID
+			}
+		}
+		return
+	}
+	for start := 0; start < len(rows); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		batch := rows[start:end]
+
+		var customers []*client.Customer
+		for i := range batch {
+			customers = append(customers, batch[i].customer)
+		}
+		err := repo.createCustomers(ctx, customers, organization)
+		if err == nil {
+			continue
+		}
+		logger.LogErrorf("problem creating batch of imported customers, retrying individually: %v", err)
+
+		for i := range batch {
+			if err := repo.CreateCustomer(ctx, batch[i].customer, organization); err != nil {
+				batch[i].Error = err.Error()
+			}
+		}
+	}
+}
+
+func screenImportedCustomer(ctx context.Context, logger log.Logger, repo CustomerRepository, ofac *OFACSearcher, organization, requestID, actor string, res *importRowResult) {
+	if err := ofac.storeCustomerOFACSearch(res.customer, requestID); err != nil {
+		logger.LogErrorf("error with OFAC search for customer=%s: %v", res.CustomerID, err)
+		res.OFACError = err.Error()
+		return
+	}
+	result, err := repo.getLatestCustomerOFACSearch(ctx, res.CustomerID, organization)
+	if err != nil {
+		res.OFACError = err.Error()
+		return
+	}
+	rejected, err := rejectBlockedCustomer(ctx, logger, repo, res.customer, result, "OFAC search on import", actor)
+	if err != nil {
+		res.OFACError = err.Error()
+	}
+	res.OFACRejected = rejected
+}
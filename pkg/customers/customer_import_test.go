@@ -0,0 +1,217 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/watchman"
+
+	watchmanClient "github.com/moov-io/watchman/client"
+)
+
+func importCSV(t *testing.T, router *mux.Router, query string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, err := writer.CreateFormFile("file", "customers.csv")
+	require.NoError(t, err)
+	part.Write([]byte(body))
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/customers/import"+query, &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("x-organization", "test")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	w.Flush()
+	return w
+}
+
+func TestCustomers__readImportRows(t *testing.T) {
+	rows, err := readImportRows(strings.NewReader("FirstName, lastName,email\njane,doe,jane@example.com\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, 2, rows[0].line)
+	require.Equal(t, "jane", rows[0].req.FirstName)
+	require.Equal(t, "doe", rows[0].req.LastName)
+	require.Equal(t, "jane@example.com", rows[0].req.Email)
+
+	_, err = readImportRows(strings.NewReader(""))
+	require.EqualError(t, err, "empty CSV file")
+
+	_, err = readImportRows(strings.NewReader("firstName,lastName,ssn\n"))
+	require.EqualError(t, err, `unknown CSV column "ssn"`)
+
+	rows, err = readImportRows(strings.NewReader("fullName,email\nJane Doe,jane@example.com\nJane,\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.NoError(t, validateImportRow(&rows[0]))
+	require.Equal(t, "Jane", rows[0].req.FirstName)
+	require.Equal(t, "Doe", rows[0].req.LastName)
+	require.Equal(t, "Jane Doe", rows[0].req.Metadata[fullNameMetadataKey])
+	require.Equal(t, errIncompleteFullName, validateImportRow(&rows[1]))
+
+	_, err = readImportRows(strings.NewReader("firstName,email\n"))
+	require.EqualError(t, err, "CSV header must include firstName and lastName or fullName")
+}
+
+func TestCustomers__importCustomers(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(repo, nil), nil, nil)
+
+	body := strings.Join([]string{
+		"firstName,lastName,email,phone,phoneType,address1,city,state,postalCode,country",
+		"jane,doe,jane@example.com,+15555551234,mobile,123 1st St,Anytown,CA,90210,US",
+		"john,doe,,,,,,,,",
+		"jim,doe,,,,123 1st St,Anytown,ZZ,90210,US", // invalid state
+		",doe,,,,,,,,",                              // missing firstName
+		"jack,doe",                                  // wrong number of columns
+	}, "\n")
+
+	// validate without creating anything
+	w := importCSV(t, router, "?dryRun=true", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var report importReport
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	require.True(t, report.DryRun)
+	require.Equal(t, 0, report.Created)
+	require.Equal(t, 3, report.Failed)
+	require.Len(t, report.Rows, 5)
+	require.Empty(t, report.Rows[0].CustomerID)
+
+	customers, err := repo.searchCustomers(context.Background(), SearchParams{Organization: "test", Count: 10})
+	require.NoError(t, err)
+	require.Empty(t, customers)
+
+	// now import them
+	w = importCSV(t, router, "", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	report = importReport{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	require.False(t, report.DryRun)
+	require.Equal(t, 2, report.Created)
+	require.Equal(t, 3, report.Failed)
+
+	for i, row := range report.Rows {
+		require.Equal(t, i+2, row.Row)
+	}
+	require.NotEmpty(t, report.Rows[0].CustomerID)
+	require.NotEmpty(t, report.Rows[1].CustomerID)
+	require.Contains(t, report.Rows[2].Error, "state")
+	require.Contains(t, report.Rows[3].Error, "name")
+	require.Contains(t, report.Rows[4].Error, "invalid row")
+
+	cust, err := repo.GetCustomer(context.Background(), report.Rows[0].CustomerID, "test")
+	require.NoError(t, err)
+	require.Equal(t, "jane", cust.FirstName)
+	require.Len(t, cust.Phones, 1)
+	require.Equal(t, "+15555551234", cust.Phones[0].Number)
+	require.Len(t, cust.Addresses, 1)
+	require.Equal(t, "Anytown", cust.Addresses[0].City)
+}
+
+func TestCustomers__importCustomersDeduplicated(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(repo, nil), nil, nil)
+
+	existing := &client.Customer{CustomerID: "existing", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com"}
+	require.NoError(t, repo.CreateCustomer(context.Background(), existing, "test"))
+
+	defer func() { deduplication = "" }()
+	deduplication = DeduplicationReject
+
+	body := strings.Join([]string{
+		"firstName,lastName,email",
+		"jane,doe,JANE@example.com", // an existing customer
+		"john,doe,john@example.com",
+		"johnny,doe,john@example.com", // an earlier row
+	}, "\n")
+	w := importCSV(t, router, "", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var report importReport
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	require.Equal(t, 1, report.Created)
+	require.Equal(t, 2, report.Failed)
+	require.Equal(t, "customer=existing has the same email", report.Rows[0].Error)
+	require.NotEmpty(t, report.Rows[1].CustomerID)
+	require.Equal(t, fmt.Sprintf("customer=%s has the same email", report.Rows[1].CustomerID), report.Rows[2].Error)
+
+	// warn creates them and reports the duplicate
+	deduplication = DeduplicationWarn
+	w = importCSV(t, router, "", "firstName,lastName,email\njane,doe,jane@example.com\n")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	report = importReport{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	require.Equal(t, 1, report.Created)
+	require.NotEmpty(t, report.Rows[0].CustomerID)
+	require.Equal(t, "existing", report.Rows[0].DuplicateCustomerID)
+}
+
+func TestCustomers__importCustomersOFAC(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	ofacClient := watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{
+		EntityID: "1241421",
+		SdnName:  "Jane Doe",
+		Match:    1.0,
+	}, nil)
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(repo, ofacClient), nil, nil)
+
+	w := importCSV(t, router, "?ofac=true", "firstName,lastName\njane,doe\n")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var report importReport
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	require.Equal(t, 1, report.Created)
+	require.True(t, report.Rows[0].OFACRejected)
+
+	cust, err := repo.GetCustomer(context.Background(), report.Rows[0].CustomerID, "test")
+	require.NoError(t, err)
+	require.Equal(t, client.CUSTOMERSTATUS_REJECTED, cust.Status)
+}
+
+func TestCustomers__importCustomersErr(t *testing.T) {
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, &testCustomerRepository{}, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
+
+	w := importCSV(t, router, "", "firstName,ssn\n")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// missing multipart file
+	req := httptest.NewRequest("POST", "/customers/import", strings.NewReader("firstName,lastName\n"))
+	req.Header.Set("x-organization", "test")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// nameSearch builds the SQL used to find Customers by their names. Each search term has to
+// match one of first_name, middle_name, last_name or nick_name.
+//
+// likeNameSearch works on every database we support. A backend with full-text search
+// (e.g. MySQL's MATCH ... AGAINST) can provide its own nameSearch.
+type nameSearch interface {
+	// filter returns a condition to be added to a where clause
+	filter(terms []string) (string, []interface{})
+
+	// rank returns an expression for ordering results, lower values are better matches
+	rank(terms []string) (string, []interface{})
+}
+
+var customerNameSearch nameSearch = likeNameSearch{}
+
+var nameSearchColumns = []string{"first_name", "middle_name", "last_name", "nick_name"}
+
+type likeNameSearch struct{}
+
+func (likeNameSearch) filter(terms []string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for _, term := range terms {
+		var columns []string
+		for _, col := range nameSearchColumns {
+			columns = append(columns, fmt.Sprintf("lower(%s) like ?", col))
+			args = append(args, "%"+escapeLike(term)+"%")
+		}
+		conditions = append(conditions, "("+strings.Join(columns, " or ")+")")
+	}
+	return strings.Join(conditions, " and "), args
+}
+
+// rank scores each term as 0 for an exact match on any name column, 1 for a prefix match and 2 otherwise.
+func (likeNameSearch) rank(terms []string) (string, []interface{}) {
+	var scores []string
+	var args []interface{}
+	for _, term := range terms {
+		var exact, prefix []string
+		for _, col := range nameSearchColumns {
+			exact = append(exact, fmt.Sprintf("lower(%s) = ?", col))
+			prefix = append(prefix, fmt.Sprintf("lower(%s) like ?", col))
+		}
+		scores = append(scores, fmt.Sprintf("(case when %s then 0 when %s then 1 else 2 end)", strings.Join(exact, " or "), strings.Join(prefix, " or ")))
+		for range nameSearchColumns {
+			args = append(args, term)
+		}
+		for range nameSearchColumns {
+			args = append(args, escapeLike(term)+"%")
+		}
+	}
+	return strings.Join(scores, " + "), args
+}
+
+// escapeLike returns v with LIKE wildcards removed so they're not treated as patterns
+func escapeLike(v string) string {
+	return strings.NewReplacer("%", "", "_", "").Replace(v)
+}
+
+// readNameTerms splits a name query into lowercase search terms
+func readNameTerms(name string) []string {
+	var terms []string
+	for _, t := range strings.Fields(strings.ToLower(name)) {
+		if t = escapeLike(t); t != "" {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+var errMissingName = errors.New("missing name query parameter")
+
+// searchCustomersByName finds Customers by partial matches of their names.
+// Results are ranked by how closely they match and paginated like searchCustomers.
+func searchCustomersByName(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		params, err := parseSearchParams(r)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		params.Organization = organization
+		params.Query = "" // only search on the name terms
+
+		params.NameTerms = readNameTerms(r.URL.Query().Get("name"))
+		if len(params.NameTerms) == 0 {
+			route.Problem(w, errMissingName)
+			return
+		}
+
+		respondWithSearchResults(r.Context(), logger, w, repo, params)
+	}
+}
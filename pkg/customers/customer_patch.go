@@ -0,0 +1,247 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/model"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// patchCustomer applies a JSON merge patch (RFC 7386) to a Customer. Fields which are omitted are unchanged
+// and fields set to null are cleared. Keys of metadata are merged the same way while phones, addresses
+// and representatives are replaced as a whole.
+func patchCustomer(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage, verifier AddressVerifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		version, err := readIfMatch(r)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+
+		var patch map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			route.Problem(w, fmt.Errorf("invalid merge patch: %v", err))
+			return
+		}
+
+		existing, err := repo.GetCustomer(r.Context(), customerID, organization)
+		if err != nil {
+			if err == errCustomerNotFound {
+				route.NotFound(w, r)
+				return
+			}
+			route.Problem(w, err)
+			return
+		}
+
+		req, err := applyCustomerPatch(existing, patch)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if err := req.validate(); err != nil {
+			logger.LogErrorf("error validating customer patch: %v", err)
+			route.Problem(w, route.Validation(err))
+			return
+		}
+		if _, ok := patch["addresses"]; ok {
+			verifyAddresses(logger, verifier, req.Addresses)
+		}
+
+		cust, ssn, err := req.asCustomer(customerSSNStorage)
+		if err != nil {
+			logger.LogErrorf("transforming patch into Customer=%s: %v", customerID, err)
+			route.Problem(w, err)
+			return
+		}
+		// keep the IDs of nested records which weren't patched
+		if _, ok := patch["phones"]; !ok {
+			cust.Phones = existing.Phones
+		}
+		if _, ok := patch["addresses"]; !ok {
+			cust.Addresses = existing.Addresses
+		}
+		if _, ok := patch["customerRepresentatives"]; !ok {
+			cust.Representatives = existing.Representatives
+		}
+
+		if err := repo.updateCustomer(r.Context(), cust, ssn, organization, version); err != nil {
+			if err == errVersionMismatch {
+				route.Problem(w, err)
+				return
+			}
+			logger.LogErrorf("error patching customer: %v", err)
+			route.Problem(w, fmt.Errorf("updating customer: %v", err))
+			return
+		}
+		if _, ok := patch["metadata"]; ok {
+			if err := repo.replaceCustomerMetadata(r.Context(), customerID, cust.Metadata); err != nil {
+				logger.LogErrorf("error updating metadata for customer=%s: %v", customerID, err)
+				route.Problem(w, err)
+				return
+			}
+		}
+
+		if err := rejectDeniedSSN(r.Context(), logger, repo, customerID, organization, ssn); err != nil {
+			logger.LogErrorf("problem checking SSN denylist for customer=%s: %v", customerID, err)
+		}
+
+		logger.Logf("patched customer=%s", customerID)
+		cust, err = repo.GetCustomer(r.Context(), customerID, organization)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if err := setETag(r.Context(), w, repo, customerID, organization); err != nil {
+			logger.LogErrorf("error reading version of customer=%s: %v", customerID, err)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(cust)
+	}
+}
+
+var errClearSSN = errors.New("SSN can't be cleared")
+
+// applyCustomerPatch merges the patch into the request form of an existing Customer
+func applyCustomerPatch(existing *client.Customer, patch map[string]interface{}) (*customerRequest, error) {
+	if v, ok := patch["SSN"]; ok && v == nil {
+		return nil, errClearSSN
+	}
+
+	current, err := json.Marshal(customerRequestFrom(existing))
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(current, &doc); err != nil {
+		return nil, err
+	}
+	if doc["birthDate"] == "" {
+		delete(doc, "birthDate") // an empty date isn't valid JSON for model.YYYYMMDD
+	}
+
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return nil, err
+	}
+	var req customerRequest
+	if err := json.Unmarshal(merged, &req); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %v", err)
+	}
+	req.CustomerID = existing.CustomerID
+	req.Status = existing.Status
+	return &req, nil
+}
+
+// mergePatch applies an RFC 7386 merge patch onto doc
+func mergePatch(doc, patch map[string]interface{}) map[string]interface{} {
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(doc, k)
+			continue
+		}
+		if obj, ok := v.(map[string]interface{}); ok {
+			target, _ := doc[k].(map[string]interface{})
+			doc[k] = mergePatch(target, obj)
+			continue
+		}
+		doc[k] = v
+	}
+	return doc
+}
+
+// customerRequestFrom returns the request which would create the existing Customer. The SSN isn't included.
+func customerRequestFrom(c *client.Customer) customerRequest {
+	req := customerRequest{
+		CustomerID:              c.CustomerID,
+		FirstName:               c.FirstName,
+		MiddleName:              c.MiddleName,
+		LastName:                c.LastName,
+		NickName:                c.NickName,
+		Suffix:                  c.Suffix,
+		Type:                    c.Type,
+		BusinessName:            c.BusinessName,
+		DoingBusinessAs:         c.DoingBusinessAs,
+		BusinessType:            c.BusinessType,
+		EIN:                     c.EIN,
+		DUNS:                    c.DUNS,
+		SICCode:                 c.SICCode,
+		NAICSCode:               c.NAICSCode,
+		Status:                  c.Status,
+		Email:                   c.Email,
+		Website:                 c.Website,
+		DateBusinessEstablished: c.DateBusinessEstablished,
+		Locale:                  c.Locale,
+		Metadata:                c.Metadata,
+	}
+	if len(c.BirthDate) >= len(model.YYYYMMDD_Format) {
+		req.BirthDate = model.YYYYMMDD(c.BirthDate[:len(model.YYYYMMDD_Format)])
+	}
+	for i := range c.Phones {
+		req.Phones = append(req.Phones, phoneRequestFrom(c.Phones[i]))
+	}
+	for i := range c.Addresses {
+		req.Addresses = append(req.Addresses, addressRequestFrom(c.Addresses[i]))
+	}
+	for i := range c.Representatives {
+		rep := customerRepresentative{
+			FirstName:           c.Representatives[i].FirstName,
+			LastName:            c.Representatives[i].LastName,
+			JobTitle:            c.Representatives[i].JobTitle,
+			BirthDate:           c.Representatives[i].BirthDate,
+			OwnershipPercentage: c.Representatives[i].OwnershipPercentage,
+		}
+		for j := range c.Representatives[i].Phones {
+			rep.Phones = append(rep.Phones, phoneRequestFrom(c.Representatives[i].Phones[j]))
+		}
+		for j := range c.Representatives[i].Addresses {
+			rep.Addresses = append(rep.Addresses, addressRequestFrom(c.Representatives[i].Addresses[j]))
+		}
+		req.Representatives = append(req.Representatives, rep)
+	}
+	return req
+}
+
+func phoneRequestFrom(p client.Phone) phone {
+	return phone{
+		Number:    p.Number,
+		Type:      p.Type,
+		OwnerType: p.OwnerType,
+	}
+}
+
+func addressRequestFrom(a client.Address) address {
+	return address{
+		Type:       a.Type,
+		OwnerType:  a.OwnerType,
+		Address1:   a.Address1,
+		Address2:   a.Address2,
+		City:       a.City,
+		State:      a.State,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+	}
+}
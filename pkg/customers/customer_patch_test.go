@@ -0,0 +1,126 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestCustomers__mergePatch(t *testing.T) {
+	doc := map[string]interface{}{
+		"firstName": "Jane",
+		"email":     "jane@example.com",
+		"metadata":  map[string]interface{}{"a": "1", "b": "2"},
+	}
+	patch := map[string]interface{}{
+		"email":    nil,
+		"nickName": "JD",
+		"metadata": map[string]interface{}{"a": nil, "c": "3"},
+	}
+	out := mergePatch(doc, patch)
+	require.Equal(t, map[string]interface{}{
+		"firstName": "Jane",
+		"nickName":  "JD",
+		"metadata":  map[string]interface{}{"b": "2", "c": "3"},
+	}, out)
+}
+
+func TestCustomers__patchCustomer(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	createReq := &customerRequest{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		NickName:  "JD",
+		Type:      "individual",
+		BirthDate: "1999-01-01",
+		Email:     "jane@example.com",
+		Phones: []phone{
+			{Number: "+15555551234", Type: "mobile", OwnerType: "customer"},
+		},
+		Addresses: []address{
+			{Address1: "123 1st st", City: "fake city", State: "CA", PostalCode: "90210", Country: "US", Type: "primary", OwnerType: "customer"},
+		},
+		Metadata: map[string]string{"key": "value", "other": "value"},
+	}
+	customer, _, err := createReq.asCustomer(testCustomerSSNStorage(t))
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateCustomer(context.Background(), customer, "test"))
+	require.NoError(t, repo.updateCustomerStatus(context.Background(), customer.CustomerID, client.CUSTOMERSTATUS_RECEIVE_ONLY, "", ""))
+	require.NoError(t, repo.replaceCustomerMetadata(context.Background(), customer.CustomerID, customer.Metadata))
+
+	before, err := repo.GetCustomer(context.Background(), customer.CustomerID, "test")
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(repo, nil), nil, nil)
+
+	patch := func(customerID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/customers/"+customerID, strings.NewReader(body))
+		req.Header.Set("x-organization", "test")
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	time.Sleep(10 * time.Millisecond) // so last_modified changes
+	w := patch(customer.CustomerID, `{"email": "jane@moov.io", "nickName": null, "metadata": {"other": null}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var got client.Customer
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.Equal(t, "jane@moov.io", got.Email)
+	require.Empty(t, got.NickName)
+	require.Equal(t, map[string]string{"key": "value"}, got.Metadata)
+
+	// unpatched fields are unchanged
+	require.Equal(t, "Jane", got.FirstName)
+	require.Equal(t, client.CUSTOMERSTATUS_RECEIVE_ONLY, got.Status)
+	require.Equal(t, before.BirthDate, got.BirthDate)
+	require.Equal(t, before.Phones, got.Phones)
+	require.Equal(t, before.Addresses[0].AddressID, got.Addresses[0].AddressID)
+	require.True(t, got.LastModified.After(before.LastModified))
+
+	w = patch(customer.CustomerID, `{"birthDate": null}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = patch(customer.CustomerID, `{"lastName": "Smith"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var cleared client.Customer
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&cleared))
+	require.Empty(t, cleared.BirthDate)
+	require.Equal(t, "Smith", cleared.LastName)
+
+	// required fields can't be cleared
+	w = patch(customer.CustomerID, `{"firstName": null}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "empty name")
+
+	w = patch(customer.CustomerID, `{"SSN": null}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = patch(customer.CustomerID, `{"birthDate": "yesterday"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = patch(customer.CustomerID, `[]`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = patch("missing", `{"email": "jane@moov.io"}`)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
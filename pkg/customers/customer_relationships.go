@@ -0,0 +1,270 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// Relationship links two Customers of an organization, like spouses or members of a household.
+// Directional relationships read as "CustomerID is the <type> of RelatedCustomerID", while the others
+// apply to both Customers equally.
+type Relationship struct {
+	RelationshipID    string    `json:"relationshipID"`
+	CustomerID        string    `json:"customerID"`
+	RelatedCustomerID string    `json:"relatedCustomerID"`
+	Type              string    `json:"type"`
+	Directional       bool      `json:"directional"`
+	Actor             string    `json:"actor,omitempty"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// relationshipTypes are the supported types of Relationship and whether each is directional
+var relationshipTypes = map[string]bool{
+	"spouse":    false,
+	"household": false,
+	"parent":    true,
+	// authorized-user is a Customer allowed to use the related Customer's accounts
+	"authorized-user": true,
+}
+
+var errRelationshipNotFound = route.NewError(http.StatusNotFound, route.CodeNotFound, "relationship not found")
+
+// RelationshipRepository reads and writes the Relationships between an organization's Customers
+type RelationshipRepository interface {
+	// GetRelationships returns every Relationship of a Customer, in either direction, with Customers
+	// which haven't been deleted. They're ordered by when they were created.
+	GetRelationships(customerID, organization string) ([]*Relationship, error)
+
+	addRelationship(organization string, rel *Relationship) error
+	removeRelationship(customerID, organization, relationshipID string) error
+}
+
+func NewRelationshipRepository(logger log.Logger, db customersdb.Querier) RelationshipRepository {
+	return &sqlCustomerRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func AddRelationshipRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository, relationships RelationshipRepository) {
+	logger = logger.Set("package", log.String("customers"))
+
+	r.Methods("GET").Path("/customers/{customerID}/relationships").HandlerFunc(getRelationships(logger, repo, relationships))
+	r.Methods("POST").Path("/customers/{customerID}/relationships").HandlerFunc(addRelationship(logger, repo, relationships))
+	r.Methods("DELETE").Path("/customers/{customerID}/relationships/{relationshipID}").HandlerFunc(removeRelationship(logger, repo, relationships))
+}
+
+type addRelationshipRequest struct {
+	RelatedCustomerID string `json:"relatedCustomerID"`
+	Type              string `json:"type"`
+}
+
+func (req addRelationshipRequest) validate(customerID string) error {
+	if req.RelatedCustomerID == "" {
+		return errors.New("missing relatedCustomerID")
+	}
+	if req.RelatedCustomerID == customerID {
+		return errors.New("a customer can't be related to themselves")
+	}
+	if _, ok := relationshipTypes[req.Type]; !ok {
+		var types []string
+		for t := range relationshipTypes {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return fmt.Errorf("unknown relationship type %q, expected one of %s", req.Type, strings.Join(types, ", "))
+	}
+	return nil
+}
+
+func getRelationships(logger log.Logger, repo CustomerRepository, relationships RelationshipRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		found, err := relationships.GetRelationships(customerID, organization)
+		if err != nil {
+			logger.Set("customerID", log.String(customerID)).LogErrorf("problem reading relationships: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(found)
+	}
+}
+
+func addRelationship(logger log.Logger, repo CustomerRepository, relationships RelationshipRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		var req addRelationshipRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, route.Validation(fmt.Errorf("reading relationship: %v", err)))
+			return
+		}
+		req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+		if err := req.validate(customerID); err != nil {
+			route.Problem(w, route.Validation(err))
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		rel := &Relationship{
+			RelationshipID:    base.ID(),
+			CustomerID:        customerID,
+			RelatedCustomerID: req.RelatedCustomerID,
+			Type:              req.Type,
+			Directional:       relationshipTypes[req.Type],
+			Actor:             route.GetActor(r),
+			CreatedAt:         time.Now(),
+		}
+		logger = logger.Set("customerID", log.String(customerID))
+		if err := relationships.addRelationship(organization, rel); err != nil {
+			var e *route.Error
+			if !errors.As(err, &e) {
+				logger.LogErrorf("problem adding relationship: %v", err)
+			}
+			route.Problem(w, err)
+			return
+		}
+		logger.Info().Set("relationshipID", log.String(rel.RelationshipID)).Logf("added %s relationship", rel.Type)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(rel)
+	}
+}
+
+func removeRelationship(logger log.Logger, repo CustomerRepository, relationships RelationshipRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+		relationshipID := mux.Vars(r)["relationshipID"]
+		if err := relationships.removeRelationship(customerID, organization, relationshipID); err != nil {
+			if err != errRelationshipNotFound {
+				logger.LogErrorf("problem removing relationship: %v", err)
+			}
+			route.Problem(w, err)
+			return
+		}
+		logger.Info().Set("relationshipID", log.String(relationshipID)).Log("removed relationship")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (r *sqlCustomerRepository) GetRelationships(customerID, organization string) ([]*Relationship, error) {
+	// only Relationships whose other Customer hasn't been deleted are returned
+	query := `select r.relationship_id, r.customer_id, r.related_customer_id, r.relationship_type, r.actor, r.created_at
+from customer_relationships r
+inner join customers c on c.customer_id = (case when r.customer_id = ? then r.related_customer_id else r.customer_id end) and c.deleted_at is null
+where r.organization = ? and (r.customer_id = ? or r.related_customer_id = ?)
+order by r.created_at, r.relationship_id;`
+	rows, err := r.db.Query(query, customerID, organization, customerID, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("GetRelationships: %v", err)
+	}
+	defer rows.Close()
+
+	out := make([]*Relationship, 0)
+	for rows.Next() {
+		var rel Relationship
+		if err := rows.Scan(&rel.RelationshipID, &rel.CustomerID, &rel.RelatedCustomerID, &rel.Type, &rel.Actor, &rel.CreatedAt); err != nil {
+			return nil, fmt.Errorf("GetRelationships: scan: %v", err)
+		}
+		rel.Directional = relationshipTypes[rel.Type]
+		out = append(out, &rel)
+	}
+	return out, rows.Err()
+}
+
+// addRelationship links two Customers of the organization. Linking Customers which already have the
+// Relationship, in either direction for types which aren't directional, is a conflict.
+func (r *sqlCustomerRepository) addRelationship(organization string, rel *Relationship) error {
+	return customersdb.RetryOnLock(r.db, func(tx *sql.Tx) error {
+		var n int
+		query := `select count(*) from customers where customer_id = ? and organization = ? and deleted_at is null;`
+		if err := tx.QueryRow(query, rel.RelatedCustomerID, organization).Scan(&n); err != nil {
+			return fmt.Errorf("reading related customer: %v", err)
+		}
+		if n == 0 {
+			return route.NewError(http.StatusNotFound, route.CodeNotFound, "related customer not found")
+		}
+
+		query = `select count(*) from customer_relationships where relationship_type = ? and ((customer_id = ? and related_customer_id = ?)`
+		args := []interface{}{rel.Type, rel.CustomerID, rel.RelatedCustomerID}
+		if !rel.Directional {
+			query += ` or (customer_id = ? and related_customer_id = ?)`
+			args = append(args, rel.RelatedCustomerID, rel.CustomerID)
+		}
+		if err := tx.QueryRow(query+`);`, args...).Scan(&n); err != nil {
+			return fmt.Errorf("reading relationships: %v", err)
+		}
+		if n > 0 {
+			return route.Conflict(fmt.Errorf("customers already have a %s relationship", rel.Type))
+		}
+
+		query = `insert into customer_relationships (relationship_id, organization, customer_id, related_customer_id, relationship_type, actor, created_at) values (?, ?, ?, ?, ?, ?, ?);`
+		if _, err := tx.Exec(query, rel.RelationshipID, organization, rel.CustomerID, rel.RelatedCustomerID, rel.Type, rel.Actor, rel.CreatedAt); err != nil {
+			return fmt.Errorf("adding relationship: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *sqlCustomerRepository) removeRelationship(customerID, organization, relationshipID string) error {
+	query := `delete from customer_relationships where relationship_id = ? and organization = ? and (customer_id = ? or related_customer_id = ?);`
+	res, err := r.db.Exec(query, relationshipID, organization, customerID, customerID)
+	if err != nil {
+		return fmt.Errorf("removing relationship: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errRelationshipNotFound
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestCustomerRelationships__routes(t *testing.T) {
+	scope := Setup(t)
+	organization := "organization"
+	john := scope.CreateCustomer(context.Background(), "John", "Doe", organization, "john@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	jane := scope.CreateCustomer(context.Background(), "Jane", "Doe", organization, "jane@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	jim := scope.CreateCustomer(context.Background(), "Jim", "Doe", organization, "jim@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	other := scope.CreateCustomer(context.Background(), "Jack", "Doe", "other", "jack@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+
+	router := mux.NewRouter()
+	AddRelationshipRoutes(log.NewNopLogger(), router, scope.customerRepo, NewRelationshipRepository(log.NewNopLogger(), scope.customerRepo.db))
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Organization", organization)
+		req.Header.Set("X-User-ID", "operator")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	link := func(from, to client.Customer, relType string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"relatedCustomerID": %q, "type": %q}`, to.CustomerID, relType)
+		return send("POST", "/customers/"+from.CustomerID+"/relationships", body)
+	}
+	list := func(cust client.Customer) []Relationship {
+		w := send("GET", "/customers/"+cust.CustomerID+"/relationships", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var found []Relationship
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&found))
+		return found
+	}
+
+	w := link(john, jane, "Spouse")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var spouse Relationship
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&spouse))
+	require.Equal(t, "spouse", spouse.Type)
+	require.False(t, spouse.Directional)
+	require.Equal(t, "operator", spouse.Actor)
+
+	// undirected relationships can't be added again from either side
+	w = link(jane, john, "spouse")
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	w = link(john, jane, "spouse")
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+
+	// directional relationships can run both ways
+	time.Sleep(5 * time.Millisecond)
+	w = link(john, jim, "parent")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = link(john, jim, "parent")
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	w = link(jim, john, "authorized-user")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = link(john, john, "spouse")
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	w = link(john, jane, "cousin")
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	w = link(john, other, "household")
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	w = send("POST", "/customers/missing/relationships", fmt.Sprintf(`{"relatedCustomerID": %q, "type": "household"}`, jane.CustomerID))
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	// relationships are listed from both sides
+	found := list(john)
+	require.Len(t, found, 3)
+	require.Equal(t, spouse.RelationshipID, found[0].RelationshipID)
+	require.Equal(t, john.CustomerID, found[1].CustomerID)
+	require.True(t, found[1].Directional)
+	require.Equal(t, jim.CustomerID, found[2].CustomerID)
+
+	found = list(jane)
+	require.Len(t, found, 1)
+	require.Equal(t, jane.CustomerID, found[0].RelatedCustomerID)
+
+	// deleted customers are left out
+	_, err := scope.customerRepo.db.Exec(`update customers set deleted_at = ? where customer_id = ?;`, time.Now(), jim.CustomerID)
+	require.NoError(t, err)
+	require.Len(t, list(john), 1)
+
+	// either customer can remove a relationship
+	path := "/customers/" + jane.CustomerID + "/relationships/" + spouse.RelationshipID
+	w = send("DELETE", "/customers/"+other.CustomerID+"/relationships/"+spouse.RelationshipID, "")
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	w = send("DELETE", path, "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	w = send("DELETE", path, "")
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	require.Empty(t, list(jane))
+}
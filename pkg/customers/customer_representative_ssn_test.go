@@ -35,7 +35,7 @@ func TestRepresentativeSSNStorage(t *testing.T) {
 	if ssn.ownerID != representativeID {
 		t.Errorf("ssn.ownerID=%s", ssn.ownerID)
 	}
-	if ssn.masked != "9#######1" {
+	if ssn.masked != "#####4321" {
 		t.Errorf("ssn.masked=%s", ssn.masked)
 	}
 
@@ -5,6 +5,8 @@
 package customers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,35 +19,109 @@ import (
 	"github.com/moov-io/base"
 	moovhttp "github.com/moov-io/base/http"
 
+	customersdb "github.com/moov-io/customers/internal/database"
 	"github.com/moov-io/customers/pkg/client"
 	"github.com/moov-io/customers/pkg/model"
 	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/webhooks"
 
 	"github.com/moov-io/base/log"
 )
 
-func AddRepresentativeRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository, customerSSNStorage *ssnStorage) {
+func AddRepresentativeRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository, customerSSNStorage *ssnStorage, ofac *OFACSearcher, notifier webhooks.Notifier) {
 	logger = logger.Set("package", log.String("customers"))
 
-	r.Methods("PUT").Path("/customers/{customerID}/representatives/{representativeID}").HandlerFunc(updateRepresentative(logger, repo, customerSSNStorage))
-	r.Methods("DELETE").Path("/customers/{customerID}/representatives/{representativeID}").HandlerFunc(deleteRepresentative(logger, repo))
-	r.Methods("POST").Path("/customers/{customerID}/representatives").HandlerFunc(createRepresentative(logger, repo, customerSSNStorage))
+	r.Methods("GET").Path("/customers/{customerID}/representatives/{representativeID}/ofac").HandlerFunc(getLatestRepresentativeOFACSearch(logger, repo))
+	r.Methods("GET").Path("/customers/{customerID}/representatives/{representativeID}").HandlerFunc(getRepresentative(logger, repo))
+	r.Methods("PUT").Path("/customers/{customerID}/representatives/{representativeID}").HandlerFunc(updateRepresentative(logger, repo, customerSSNStorage, ofac, notifier))
+	r.Methods("DELETE").Path("/customers/{customerID}/representatives/{representativeID}").HandlerFunc(deleteRepresentative(logger, repo, notifier))
+	r.Methods("GET").Path("/customers/{customerID}/representatives").HandlerFunc(listRepresentatives(logger, repo))
+	r.Methods("POST").Path("/customers/{customerID}/representatives").HandlerFunc(createRepresentative(logger, repo, customerSSNStorage, ofac, notifier))
 }
 
-func deleteRepresentative(logger log.Logger, repo CustomerRepository) func(http.ResponseWriter, *http.Request) {
+// getCustomerRepresentative reads the Representative from the route and returns nil
+// (after writing a 404) if they don't belong to the route's Customer.
+func getCustomerRepresentative(w http.ResponseWriter, r *http.Request, repo CustomerRepository) *client.Representative {
+	customerID := route.GetCustomerID(w, r)
+	if customerID == "" {
+		return nil
+	}
+	representativeID := route.GetRepresentativeID(w, r)
+	if representativeID == "" {
+		return nil
+	}
+	representative, err := repo.GetRepresentative(r.Context(), representativeID)
+	if err != nil || representative == nil || representative.CustomerID != customerID {
+		route.NotFound(w, r)
+		return nil
+	}
+	return representative
+}
+
+func getRepresentative(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+
+		representative := getCustomerRepresentative(w, r, repo)
+		if representative == nil {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(representative)
+	}
+}
+
+func listRepresentatives(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+
+		customerID := route.GetCustomerID(w, r)
+		if customerID == "" {
+			return
+		}
+
+		representatives, err := repo.listRepresentatives(r.Context(), customerID)
+		if err != nil {
+			route.Problem(w, fmt.Errorf("listing customer representatives: %v", err))
+			return
+		}
+		if representatives == nil {
+			representatives = []client.Representative{} // return an empty array rather than null
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(representatives)
+	}
+}
+
+func deleteRepresentative(logger log.Logger, repo CustomerRepository, notifier webhooks.Notifier) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+		customerID := route.GetCustomerID(w, r)
+		if customerID == "" {
+			return
+		}
 
 		representativeID := route.GetRepresentativeID(w, r)
 		if representativeID == "" {
 			return
 		}
 
-		err := repo.deleteRepresentative(representativeID)
+		err := repo.deleteRepresentative(r.Context(), representativeID)
 		if err != nil {
-			moovhttp.Problem(w, fmt.Errorf("deleting customer representative: %v", err))
+			route.Problem(w, fmt.Errorf("deleting customer representative: %v", err))
 			return
 		}
+		rollupRepresentatives(r.Context(), logger, repo, notifier, customerID, organization)
 
 		w.WriteHeader(http.StatusNoContent)
 	}
@@ -62,51 +138,65 @@ type customerRepresentativeRequest struct {
 	SSN              string         `json:"SSN,omitempty"`
 	Phones           []phone        `json:"phones,omitempty"`
 	Addresses        []address      `json:"addresses,omitempty"`
+
+	OwnershipPercentage float32 `json:"ownershipPercentage,omitempty"`
 }
 
-func createRepresentative(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage) http.HandlerFunc {
+func createRepresentative(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage, ofac *OFACSearcher, notifier webhooks.Notifier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
 		var req customerRepresentativeRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		if err := req.validate(); err != nil {
 			logger.LogErrorf("error validating new customer representative: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, route.Validation(err))
 			return
 		}
 
 		req.CustomerID = route.GetCustomerID(w, r)
+		if err := checkRepresentativeOwnership(r.Context(), repo, req.CustomerID, req.RepresentativeID, req.OwnershipPercentage); err != nil {
+			route.Problem(w, err)
+			return
+		}
 
 		representative, ssn, err := req.asRepresentative(customerSSNStorage)
 		if err != nil {
 			logger.LogErrorf("problem transforming request into Customer Representative=%s: %v", representative.RepresentativeID, err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		if ssn != nil {
 			err := customerSSNStorage.repo.saveSSN(ssn)
 			if err != nil {
 				logger.LogErrorf("problem saving SSN for Customer Representative=%s: %v", representative.RepresentativeID, err)
-				moovhttp.Problem(w, fmt.Errorf("saveSSN: %v", err))
+				route.Problem(w, fmt.Errorf("saveSSN: %v", err))
 				return
 			}
 		}
-		if err := repo.CreateRepresentative(representative, req.CustomerID); err != nil {
+		if err := repo.CreateRepresentative(r.Context(), representative, req.CustomerID); err != nil {
 			logger.LogErrorf("createCustomer: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		logger.Logf("created customer representative=%s", representative.RepresentativeID)
+		screenRepresentative(logger, ofac, representative, moovhttp.GetRequestID(r))
+		rollupRepresentatives(r.Context(), logger, repo, notifier, req.CustomerID, organization)
 
-		representative, err = repo.GetRepresentative(representative.RepresentativeID)
+		representative, err = repo.GetRepresentative(r.Context(), representative.RepresentativeID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -115,9 +205,10 @@ func createRepresentative(logger log.Logger, repo CustomerRepository, customerSS
 	}
 }
 
-func updateRepresentative(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage) http.HandlerFunc {
+func updateRepresentative(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage, ofac *OFACSearcher, notifier webhooks.Notifier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
 		organization := route.GetOrganization(w, r)
@@ -132,39 +223,50 @@ func updateRepresentative(logger log.Logger, repo CustomerRepository, customerSS
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		if err := req.validate(); err != nil {
 			logger.LogErrorf("error validating customer payload: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, route.Validation(err))
+			return
+		}
+
+		req.CustomerID = route.GetCustomerID(w, r)
+		if req.CustomerID == "" {
+			return
+		}
+		if err := checkRepresentativeOwnership(r.Context(), repo, req.CustomerID, req.RepresentativeID, req.OwnershipPercentage); err != nil {
+			route.Problem(w, err)
 			return
 		}
 
 		representative, ssn, err := req.asRepresentative(customerSSNStorage)
 		if err != nil {
 			logger.LogErrorf("transforming request into Customer Representative=%s: %v", representative.RepresentativeID, err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		if ssn != nil {
 			err := customerSSNStorage.repo.saveSSN(ssn)
 			if err != nil {
 				logger.LogErrorf("error saving SSN for Customer Representative=%s: %v", representative.RepresentativeID, err)
-				moovhttp.Problem(w, fmt.Errorf("saving customer's SSN: %v", err))
+				route.Problem(w, fmt.Errorf("saving customer's SSN: %v", err))
 				return
 			}
 		}
-		if err := repo.updateRepresentative(representative, req.CustomerID); err != nil {
+		if err := repo.updateRepresentative(r.Context(), representative, req.CustomerID); err != nil {
 			logger.LogErrorf("error updating customer representative: %v", err)
-			moovhttp.Problem(w, fmt.Errorf("updating customer representative: %v", err))
+			route.Problem(w, fmt.Errorf("updating customer representative: %v", err))
 			return
 		}
 
 		logger.Logf("updated customer representative=%s", representative.RepresentativeID)
-		representative, err = repo.GetRepresentative(representative.RepresentativeID)
+		screenRepresentative(logger, ofac, representative, moovhttp.GetRequestID(r))
+		rollupRepresentatives(r.Context(), logger, repo, notifier, req.CustomerID, organization)
+		representative, err = repo.GetRepresentative(r.Context(), representative.RepresentativeID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -183,29 +285,128 @@ func (req customerRepresentativeRequest) validate() error {
 	if err := validatePhones(req.Phones); err != nil {
 		return fmt.Errorf("invalid customer representative phone: %v", err)
 	}
+	if req.SSN != "" {
+		if err := validateSSN(req.SSN); err != nil {
+			return fmt.Errorf("invalid customer representative SSN: %v", err)
+		}
+	}
+	if err := validateOwnershipPercentage(req.OwnershipPercentage); err != nil {
+		return fmt.Errorf("invalid customer representative: %v", err)
+	}
 
 	return nil
 }
 
-func (r *sqlCustomerRepository) GetRepresentative(representativeID string) (*client.Representative, error) {
-	reps, err := r.getRepresentativesByIds([]string{representativeID})
+var errOwnershipExceeded = errors.New("representatives can't own more than 100% of a customer")
+
+func validateOwnershipPercentage(pct float32) error {
+	if pct < 0 || pct > 100 {
+		return fmt.Errorf("ownership percentage must be between 0 and 100, got %v", pct)
+	}
+	return nil
+}
+
+// checkRepresentativeOwnership returns an error if giving the representative pct ownership would
+// mean the Customer's representatives own more than 100% of the Customer.
+func checkRepresentativeOwnership(ctx context.Context, repo CustomerRepository, customerID, representativeID string, pct float32) error {
+	if pct <= 0 {
+		return nil
+	}
+	representatives, err := repo.listRepresentatives(ctx, customerID)
+	if err != nil {
+		return fmt.Errorf("checking representative ownership: %v", err)
+	}
+	total := pct
+	for i := range representatives {
+		if representatives[i].RepresentativeID != representativeID {
+			total += representatives[i].OwnershipPercentage
+		}
+	}
+	if total > 100 {
+		return errOwnershipExceeded
+	}
+	return nil
+}
+
+// screenRepresentative runs an OFAC search of the representative. Failures are logged since the
+// representative has already been saved, the search can be retried with a later update.
+func screenRepresentative(logger log.Logger, ofac *OFACSearcher, representative *client.Representative, requestID string) {
+	if ofac == nil || representative == nil {
+		return
+	}
+	if err := ofac.storeRepresentativeOFACSearch(representative, requestID); err != nil {
+		logger.LogErrorf("error with OFAC search for representative=%s: %v", representative.RepresentativeID, err)
+	}
+}
+
+// rollupRepresentatives recomputes the status of the Customer and their Representatives after a
+// Representative changed. Failures are logged since the Representative has already been saved.
+func rollupRepresentatives(ctx context.Context, logger log.Logger, repo CustomerRepository, notifier webhooks.Notifier, customerID, organization string) {
+	status, err := rollupRepresentativeStatus(ctx, logger, repo, customerID, organization)
+	if err != nil {
+		logger.LogErrorf("error rolling up representative status for customer=%s: %v", customerID, err)
+		return
+	}
+	if status != "" {
+		notify(notifier, webhooks.CustomerStatusUpdated, customerID, organization, status)
+	}
+}
+
+func getLatestRepresentativeOFACSearch(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+
+		representative := getCustomerRepresentative(w, r, repo)
+		if representative == nil {
+			return
+		}
+
+		result, err := repo.getLatestRepresentativeOFACSearch(r.Context(), representative.RepresentativeID)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if result == nil {
+			route.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func (r *sqlCustomerRepository) GetRepresentative(ctx context.Context, representativeID string) (*client.Representative, error) {
+	reps, err := r.getRepresentativesByIds(ctx, []string{representativeID})
 	if err != nil {
 		return nil, fmt.Errorf("getting customer representative: %v", err)
 	}
 
 	if len(reps) == 0 {
-		return nil, errors.New("customer representative not found")
+		return nil, route.NotFoundError(errors.New("customer representative not found"))
 	}
 
 	return reps[representativeID], nil
 }
 
-func (r *sqlCustomerRepository) getRepresentatives(customerIDs []string) (map[string][]client.Representative, error) {
+func (r *sqlCustomerRepository) listRepresentatives(ctx context.Context, customerID string) ([]client.Representative, error) {
+	representatives, err := r.getRepresentatives(ctx, []string{customerID})
+	if err != nil {
+		return nil, fmt.Errorf("listRepresentatives: %v", err)
+	}
+	return representatives[customerID], nil
+}
+
+func (r *sqlCustomerRepository) getRepresentatives(ctx context.Context, customerIDs []string) (map[string][]client.Representative, error) {
 	query := fmt.Sprintf(
-		"select representative_id, customer_id, first_name, last_name, job_title, birth_date from representatives where customer_id in (?%s) and deleted_at is null;",
+		"select representative_id, customer_id, first_name, last_name, job_title, birth_date, ownership_percentage, status from representatives where customer_id in (?%s) and deleted_at is null;",
 		strings.Repeat(",?", len(customerIDs)-1),
 	)
-	rows, err := r.queryRowsByCustomerIDs(query, customerIDs)
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	rows, err := r.queryRowsByCustomerIDs(ctx, query, customerIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -216,6 +417,7 @@ func (r *sqlCustomerRepository) getRepresentatives(customerIDs []string) (map[st
 		var c client.Representative
 		var jobTitle *string
 		var birthDate *time.Time
+		var ownership *float64
 		if err := rows.Scan(
 			&c.RepresentativeID,
 			&c.CustomerID,
@@ -223,6 +425,8 @@ func (r *sqlCustomerRepository) getRepresentatives(customerIDs []string) (map[st
 			&c.LastName,
 			&jobTitle,
 			&birthDate,
+			&ownership,
+			&c.Status,
 		); err != nil {
 			return nil, fmt.Errorf("scanning row: %v", err)
 		}
@@ -232,12 +436,15 @@ func (r *sqlCustomerRepository) getRepresentatives(customerIDs []string) (map[st
 		if jobTitle != nil {
 			c.JobTitle = *jobTitle
 		}
-		phonesByCustomerID, err := r.GetPhones([]string{c.RepresentativeID}, client.OWNERTYPE_REPRESENTATIVE)
+		if ownership != nil {
+			c.OwnershipPercentage = float32(*ownership)
+		}
+		phonesByCustomerID, err := r.GetPhones(ctx, []string{c.RepresentativeID}, client.OWNERTYPE_REPRESENTATIVE, false)
 		if err != nil {
 			return nil, fmt.Errorf("fetching customer representative phones: %v", err)
 		}
 		c.Phones = phonesByCustomerID[c.RepresentativeID]
-		addressesByCustomerID, err := r.GetAddresses([]string{c.RepresentativeID}, client.OWNERTYPE_REPRESENTATIVE)
+		addressesByCustomerID, err := r.GetAddresses(ctx, []string{c.RepresentativeID}, client.OWNERTYPE_REPRESENTATIVE, false)
 		if err != nil {
 			return nil, fmt.Errorf("fetching customer representative addresses: %v", err)
 		}
@@ -248,12 +455,15 @@ func (r *sqlCustomerRepository) getRepresentatives(customerIDs []string) (map[st
 	return ret, nil
 }
 
-func (r *sqlCustomerRepository) getRepresentativesByIds(representativeIDs []string) (map[string]*client.Representative, error) {
+func (r *sqlCustomerRepository) getRepresentativesByIds(ctx context.Context, representativeIDs []string) (map[string]*client.Representative, error) {
 	query := fmt.Sprintf(
-		"select representative_id, customer_id, first_name, last_name, job_title, birth_date from representatives where representative_id in (?%s) and deleted_at is null;",
+		"select representative_id, customer_id, first_name, last_name, job_title, birth_date, ownership_percentage, status from representatives where representative_id in (?%s) and deleted_at is null;",
 		strings.Repeat(",?", len(representativeIDs)-1),
 	)
-	rows, err := r.queryRowsByCustomerIDs(query, representativeIDs)
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	rows, err := r.queryRowsByCustomerIDs(ctx, query, representativeIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -264,6 +474,7 @@ func (r *sqlCustomerRepository) getRepresentativesByIds(representativeIDs []stri
 		var c client.Representative
 		var jobTitle *string
 		var birthDate *time.Time
+		var ownership *float64
 		if err := rows.Scan(
 			&c.RepresentativeID,
 			&c.CustomerID,
@@ -271,6 +482,8 @@ func (r *sqlCustomerRepository) getRepresentativesByIds(representativeIDs []stri
 			&c.LastName,
 			&jobTitle,
 			&birthDate,
+			&ownership,
+			&c.Status,
 		); err != nil {
 			return nil, fmt.Errorf("scanning row: %v", err)
 		}
@@ -280,12 +493,15 @@ func (r *sqlCustomerRepository) getRepresentativesByIds(representativeIDs []stri
 		if jobTitle != nil {
 			c.JobTitle = *jobTitle
 		}
-		phonesByCustomerID, err := r.GetPhones([]string{c.RepresentativeID}, client.OWNERTYPE_REPRESENTATIVE)
+		if ownership != nil {
+			c.OwnershipPercentage = float32(*ownership)
+		}
+		phonesByCustomerID, err := r.GetPhones(ctx, []string{c.RepresentativeID}, client.OWNERTYPE_REPRESENTATIVE, false)
 		if err != nil {
 			return nil, fmt.Errorf("fetching customer representative phones: %v", err)
 		}
 		c.Phones = phonesByCustomerID[c.RepresentativeID]
-		addressesByCustomerID, err := r.GetAddresses([]string{c.RepresentativeID}, client.OWNERTYPE_REPRESENTATIVE)
+		addressesByCustomerID, err := r.GetAddresses(ctx, []string{c.RepresentativeID}, client.OWNERTYPE_REPRESENTATIVE, false)
 		if err != nil {
 			return nil, fmt.Errorf("fetching customer representative addresses: %v", err)
 		}
@@ -296,102 +512,127 @@ func (r *sqlCustomerRepository) getRepresentativesByIds(representativeIDs []stri
 	return ret, nil
 }
 
-func (r *sqlCustomerRepository) CreateRepresentative(c *client.Representative, customerID string) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
-	}
-
-	// Insert customer record
-	query := `insert into representatives (representative_id, customer_id, first_name, last_name, job_title, birth_date, created_at, last_modified)
-values (?, ?, ?, ?, ?, ?, ?, ?);`
-	stmt, err := tx.Prepare(query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+func (r *sqlCustomerRepository) CreateRepresentative(ctx context.Context, c *client.Representative, customerID string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
 
-	var birthDate *string
-	if c.BirthDate != "" {
-		birthDate = &c.BirthDate
-	}
+	return customersdb.WithTransactionContext(ctx, r.db, func(tx *sql.Tx) error {
+		// Insert customer record
+		query := `insert into representatives (representative_id, customer_id, first_name, last_name, job_title, birth_date, ownership_percentage, created_at, last_modified)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
 
-	now := time.Now()
-	_, err = stmt.Exec(c.RepresentativeID, customerID, c.FirstName, c.LastName, c.JobTitle, birthDate, now, now)
-	if err != nil {
-		return fmt.Errorf("CreateRepresentative: insert into representatives err=%v | rollback=%v", err, tx.Rollback())
-	}
+		var birthDate *string
+		if c.BirthDate != "" {
+			birthDate = &c.BirthDate
+		}
 
-	err = r.updatePhonesByOwnerID(tx, c.RepresentativeID, client.OWNERTYPE_REPRESENTATIVE, c.Phones)
-	if err != nil {
-		return fmt.Errorf("updating customer representative's phones: %v", err)
-	}
+		now := time.Now()
+		_, err = stmt.ExecContext(ctx, c.RepresentativeID, customerID, c.FirstName, c.LastName, c.JobTitle, birthDate, c.OwnershipPercentage, now, now)
+		if err != nil {
+			return fmt.Errorf("CreateRepresentative: insert into representatives: %v", err)
+		}
 
-	err = r.updateAddressesByOwnerID(tx, c.RepresentativeID, client.OWNERTYPE_REPRESENTATIVE, c.Addresses)
-	if err != nil {
-		return fmt.Errorf("updating customer representative's addresses: %v", err)
-	}
+		err = r.updatePhonesByOwnerID(ctx, tx, c.RepresentativeID, client.OWNERTYPE_REPRESENTATIVE, c.Phones)
+		if err != nil {
+			return fmt.Errorf("updating customer representative's phones: %v", err)
+		}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("CreateRepresentative: tx.Commit: %v", err)
-	}
-	return nil
+		err = r.updateAddressesByOwnerID(ctx, tx, c.RepresentativeID, client.OWNERTYPE_REPRESENTATIVE, c.Addresses)
+		if err != nil {
+			return fmt.Errorf("updating customer representative's addresses: %v", err)
+		}
+		return nil
+	})
 }
 
-func (r *sqlCustomerRepository) updateRepresentative(c *client.Representative, customerID string) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+func (r *sqlCustomerRepository) updateRepresentative(ctx context.Context, c *client.Representative, customerID string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
 
-	query := `update representatives set first_name = ?, last_name = ?, job_title = ?, birth_date = ?, last_modified = ? where representative_id = ? and customer_id = ? and deleted_at is null;`
-	stmt, err := tx.Prepare(query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+	return customersdb.WithTransactionContext(ctx, r.db, func(tx *sql.Tx) error {
+		query := `update representatives set first_name = ?, last_name = ?, job_title = ?, birth_date = ?, ownership_percentage = ?, last_modified = ? where representative_id = ? and customer_id = ? and deleted_at is null;`
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
 
-	now := time.Now()
-	res, err := stmt.Exec(c.FirstName, c.LastName, c.JobTitle, c.BirthDate, now, c.RepresentativeID, customerID)
-	if err != nil {
-		return fmt.Errorf("updating customer representative: %v", err)
-	}
+		now := time.Now()
+		res, err := stmt.ExecContext(ctx, c.FirstName, c.LastName, c.JobTitle, c.BirthDate, c.OwnershipPercentage, now, c.RepresentativeID, customerID)
+		if err != nil {
+			return fmt.Errorf("updating customer representative: %v", err)
+		}
 
-	numRows, err := res.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("getting rows affected: %v", err)
-	}
+		numRows, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("getting rows affected: %v", err)
+		}
 
-	if numRows == 0 {
-		return fmt.Errorf("no records to update with customer representative id=%s", c.RepresentativeID)
-	}
+		if numRows == 0 {
+			return fmt.Errorf("no records to update with customer representative id=%s", c.RepresentativeID)
+		}
 
-	err = r.updatePhonesByOwnerID(tx, c.RepresentativeID, client.OWNERTYPE_REPRESENTATIVE, c.Phones)
-	if err != nil {
-		return fmt.Errorf("updating customer representative's phones: %v", err)
-	}
+		err = r.updatePhonesByOwnerID(ctx, tx, c.RepresentativeID, client.OWNERTYPE_REPRESENTATIVE, c.Phones)
+		if err != nil {
+			return fmt.Errorf("updating customer representative's phones: %v", err)
+		}
 
-	err = r.updateAddressesByOwnerID(tx, c.RepresentativeID, client.OWNERTYPE_REPRESENTATIVE, c.Addresses)
-	if err != nil {
-		return fmt.Errorf("updating customer representative's addresses: %v", err)
-	}
+		err = r.updateAddressesByOwnerID(ctx, tx, c.RepresentativeID, client.OWNERTYPE_REPRESENTATIVE, c.Addresses)
+		if err != nil {
+			return fmt.Errorf("updating customer representative's addresses: %v", err)
+		}
+		return nil
+	})
+}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("updateRepresentative: tx.Commit: %v", err)
-	}
-	return nil
+// updateRepresentativeStatus sets the Representative's status and records the change
+func (r *sqlCustomerRepository) updateRepresentativeStatus(ctx context.Context, representativeID, customerID string, status client.RepresentativeStatus, comment, actor string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	return customersdb.WithTransactionContext(ctx, r.db, func(tx *sql.Tx) error {
+		query := `update representatives set status = ? where representative_id = ? and customer_id = ? and deleted_at is null;`
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("updateRepresentativeStatus: update prepare: %v", err)
+		}
+		defer stmt.Close()
+
+		if _, err := stmt.ExecContext(ctx, status, representativeID, customerID); err != nil {
+			return fmt.Errorf("updateRepresentativeStatus: update exec: %v", err)
+		}
+
+		query = `insert into representative_status_updates (representative_id, customer_id, future_status, comment, actor, changed_at) values (?, ?, ?, ?, ?, ?);`
+		insert, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("updateRepresentativeStatus: insert prepare: %v", err)
+		}
+		defer insert.Close()
+
+		if _, err := insert.ExecContext(ctx, representativeID, customerID, status, comment, actor, time.Now()); err != nil {
+			return fmt.Errorf("updateRepresentativeStatus: insert exec: %v", err)
+		}
+		return nil
+	})
 }
 
-func (r *sqlCustomerRepository) deleteRepresentative(representativeID string) error {
+func (r *sqlCustomerRepository) deleteRepresentative(ctx context.Context, representativeID string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	query := `update representatives set deleted_at = ? where representative_id = ? and deleted_at is null;`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(time.Now(), representativeID)
+	_, err = stmt.ExecContext(ctx, time.Now(), representativeID)
 	return err
 }
 
@@ -407,11 +648,14 @@ func (req customerRepresentativeRequest) asRepresentative(storage *ssnStorage) (
 		LastName:         req.LastName,
 		JobTitle:         req.JobTitle,
 		BirthDate:        string(req.BirthDate),
+
+		OwnershipPercentage: req.OwnershipPercentage,
 	}
 
 	for i := range req.Phones {
 		representative.Phones = append(representative.Phones, client.Phone{
 			Number:    req.Phones[i].Number,
+			Valid:     req.Phones[i].valid,
 			Type:      req.Phones[i].Type,
 			OwnerType: req.Phones[i].OwnerType,
 		})
@@ -21,9 +21,11 @@ import (
 	"github.com/moov-io/base/database"
 
 	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/watchman"
 
 	"github.com/gorilla/mux"
 	"github.com/moov-io/base/log"
+	watchmanClient "github.com/moov-io/watchman/client"
 )
 
 func setupMockOrganizationCustomerAndRepresentative(t *testing.T, repo *sqlCustomerRepository) (string, *client.Customer, *client.Representative) {
@@ -36,7 +38,7 @@ func setupMockOrganizationCustomerAndRepresentative(t *testing.T, repo *sqlCusto
 		BusinessType: client.BUSINESSTYPE_CORPORATION,
 		EIN:          "123-456-789",
 	}).asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Fatal(err)
 	}
 
@@ -46,7 +48,7 @@ func setupMockOrganizationCustomerAndRepresentative(t *testing.T, repo *sqlCusto
 		LastName:   "Doe",
 		JobTitle:   "CEO",
 	}).asRepresentative(testCustomerSSNStorage(t))
-	if err := repo.CreateRepresentative(rep, cust.CustomerID); err != nil {
+	if err := repo.CreateRepresentative(context.Background(), rep, cust.CustomerID); err != nil {
 		t.Fatal(err)
 	}
 
@@ -63,7 +65,7 @@ func setupMockCustomer(t *testing.T, repo *sqlCustomerRepository) (*client.Custo
 		BusinessType: client.BUSINESSTYPE_CORPORATION,
 		EIN:          "123-456-789",
 	}).asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Fatal(err)
 	}
 
@@ -76,21 +78,22 @@ func TestCustomers__DeleteRepresentative(t *testing.T) {
 
 	_, cust, rep := setupMockOrganizationCustomerAndRepresentative(t, repo)
 
-	got, err := repo.GetRepresentative(rep.RepresentativeID)
+	got, err := repo.GetRepresentative(context.Background(), rep.RepresentativeID)
 	require.NoError(t, err)
 	require.NotNil(t, got)
 
 	router := mux.NewRouter()
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("DELETE", fmt.Sprintf("/customers/%s/representatives/%s", cust.CustomerID, rep.RepresentativeID), nil)
+	req.Header.Set("X-Organization", "test")
 
-	AddRepresentativeRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t))
+	AddRepresentativeRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(repo, nil), nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
 	require.Equal(t, http.StatusNoContent, w.Code)
 	require.Empty(t, w.Body)
-	got, err = repo.GetRepresentative(rep.RepresentativeID)
+	got, err = repo.GetRepresentative(context.Background(), rep.RepresentativeID)
 	require.Error(t, err)
 	require.Nil(t, got)
 }
@@ -99,7 +102,7 @@ func TestCustomerRepository__CreateRepresentative(t *testing.T) {
 	check := func(t *testing.T, repo *sqlCustomerRepository) {
 		organization, cust, rep := setupMockOrganizationCustomerAndRepresentative(t, repo)
 
-		cust, err := repo.GetCustomer(cust.CustomerID, organization)
+		cust, err := repo.GetCustomer(context.Background(), cust.CustomerID, organization)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -107,7 +110,7 @@ func TestCustomerRepository__CreateRepresentative(t *testing.T) {
 			t.Error("got nil Customer")
 		}
 
-		rep, err = repo.GetRepresentative(rep.RepresentativeID)
+		rep, err = repo.GetRepresentative(context.Background(), rep.RepresentativeID)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -119,12 +122,12 @@ func TestCustomerRepository__CreateRepresentative(t *testing.T) {
 	// SQLite tests
 	sqliteDB := database.CreateTestSQLiteDB(t)
 	defer sqliteDB.Close()
-	check(t, &sqlCustomerRepository{sqliteDB.DB, log.NewNopLogger()})
+	check(t, &sqlCustomerRepository{db: sqliteDB.DB, logger: log.NewNopLogger()})
 
 	// MySQL tests
 	mysqlDB := database.CreateTestMySQLDB(t)
 	defer mysqlDB.Close()
-	check(t, &sqlCustomerRepository{mysqlDB.DB, log.NewNopLogger()})
+	check(t, &sqlCustomerRepository{db: mysqlDB.DB, logger: log.NewNopLogger()})
 }
 
 func TestCustomers__customerRepresentativeRequest(t *testing.T) {
@@ -189,7 +192,7 @@ func TestCustomers__CreateRepresentative(t *testing.T) {
 	w := httptest.NewRecorder()
 	phone := `{"number": "555.555.5555", "type": "mobile", "ownerType": "representative"}`
 	address := `{"type": "primary", "ownerType": "representative", "address1": "123 1st St", "city": "Denver", "state": "CO", "postalCode": "12345", "country": "USA"}`
-	body := fmt.Sprintf(`{"firstName": "jane", "lastName": "doe", "birthDate": "1991-04-01", "ssn": "987654321", "phones": [%s], "addresses": [%s]}`, phone, address)
+	body := fmt.Sprintf(`{"firstName": "jane", "lastName": "doe", "birthDate": "1991-04-01", "ssn": "587654321", "phones": [%s], "addresses": [%s]}`, phone, address)
 	req := httptest.NewRequest("POST", fmt.Sprintf("/customers/%s/representatives", newCust.CustomerID), strings.NewReader(body))
 	req.Header.Set("x-organization", organization)
 	req.Header.Set("x-request-id", "test")
@@ -197,7 +200,7 @@ func TestCustomers__CreateRepresentative(t *testing.T) {
 	customerSSNStorage := testCustomerSSNStorage(t)
 
 	router := mux.NewRouter()
-	AddRepresentativeRoutes(log.NewNopLogger(), router, repo, customerSSNStorage)
+	AddRepresentativeRoutes(log.NewNopLogger(), router, repo, customerSSNStorage, createTestOFACSearcher(repo, nil), nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -260,7 +263,7 @@ func TestCustomers__updateRepresentative(t *testing.T) {
 		EIN:          "123-456-789",
 	}
 	cust, _, _ := createCustomerReq.asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Fatal(err)
 	}
 
@@ -289,11 +292,11 @@ func TestCustomers__updateRepresentative(t *testing.T) {
 		},
 	}
 	rep, _, _ := createRepresentativeReq.asRepresentative(testCustomerSSNStorage(t))
-	if err := repo.CreateRepresentative(rep, cust.CustomerID); err != nil {
+	if err := repo.CreateRepresentative(context.Background(), rep, cust.CustomerID); err != nil {
 		t.Fatal(err)
 	}
 
-	_, err := repo.GetRepresentative(rep.RepresentativeID)
+	_, err := repo.GetRepresentative(context.Background(), rep.RepresentativeID)
 	require.NoError(t, err)
 
 	router := mux.NewRouter()
@@ -329,15 +332,18 @@ func TestCustomers__updateRepresentative(t *testing.T) {
 	req := httptest.NewRequest("PUT", fmt.Sprintf("/customers/%s/representatives/%s", cust.CustomerID, rep.RepresentativeID), bytes.NewReader(payload))
 	req.Header.Set("x-organization", organization)
 	req.Header.Set("x-request-id", "test")
-	AddRepresentativeRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t))
+	AddRepresentativeRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(repo, nil), nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 	require.Equal(t, http.StatusOK, w.Code)
 
 	var got *client.Representative
 	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.NoError(t, updateReq.validate()) // normalizes phone numbers
 	want, _, _ := updateReq.asRepresentative(testCustomerSSNStorage(t))
 	require.NoError(t, err)
+	want.Status = client.REPRESENTATIVESTATUS_ACTIVE
+	copyChildTimestamps(t, want.Phones, got.Phones, want.Addresses, got.Addresses)
 	require.Equal(t, want, got)
 
 	/* Error when settings two addresses as primary */
@@ -389,7 +395,7 @@ func TestCustomerRepository__updateRepresentative(t *testing.T) {
 		EIN:          "123-456-789",
 	}
 	cust, _, _ := createReq.asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Fatal(err)
 	}
 
@@ -417,7 +423,7 @@ func TestCustomerRepository__updateRepresentative(t *testing.T) {
 		},
 	}
 	rep, _, _ := createRepresentativeReq.asRepresentative(testCustomerSSNStorage(t))
-	err := repo.CreateRepresentative(rep, cust.CustomerID)
+	err := repo.CreateRepresentative(context.Background(), rep, cust.CustomerID)
 	require.NoError(t, err)
 
 	updateReq := customerRepresentativeRequest{
@@ -443,7 +449,7 @@ func TestCustomerRepository__updateRepresentative(t *testing.T) {
 	}
 
 	updatedRep, _, _ := updateReq.asRepresentative(testCustomerSSNStorage(t))
-	err = repo.updateRepresentative(updatedRep, cust.CustomerID)
+	err = repo.updateRepresentative(context.Background(), updatedRep, cust.CustomerID)
 	require.NoError(t, err)
 
 	require.Equal(t, updateReq.FirstName, updatedRep.FirstName)
@@ -467,7 +473,7 @@ func TestCustomersRepository__addRepresentativeAddress(t *testing.T) {
 		EIN:          "123-456-789",
 	}
 	cust, _, _ := createReq.asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Fatal(err)
 	}
 
@@ -484,12 +490,12 @@ func TestCustomersRepository__addRepresentativeAddress(t *testing.T) {
 		},
 	}
 	rep, _, _ := createRepresentativeReq.asRepresentative(testCustomerSSNStorage(t))
-	if err := repo.CreateRepresentative(rep, cust.CustomerID); err != nil {
+	if err := repo.CreateRepresentative(context.Background(), rep, cust.CustomerID); err != nil {
 		t.Fatal(err)
 	}
 
 	// add an address
-	if err := repo.addAddress(rep.RepresentativeID, client.OWNERTYPE_REPRESENTATIVE, address{
+	if err := repo.addAddress(context.Background(), rep.RepresentativeID, client.OWNERTYPE_REPRESENTATIVE, address{
 		Address1:   "123 1st st",
 		City:       "fake city",
 		State:      "CA",
@@ -503,7 +509,7 @@ func TestCustomersRepository__addRepresentativeAddress(t *testing.T) {
 	}
 
 	// re-read
-	rep, err := repo.GetRepresentative(rep.RepresentativeID)
+	rep, err := repo.GetRepresentative(context.Background(), rep.RepresentativeID)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -528,7 +534,7 @@ func TestRepresentatives__minimumFields(t *testing.T) {
 	req.Header.Set("x-request-id", "test")
 
 	router := mux.NewRouter()
-	AddRepresentativeRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t))
+	AddRepresentativeRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(repo, nil), nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -549,7 +555,7 @@ func TestRepresentatives__BadReq(t *testing.T) {
 	req.Header.Set("x-request-id", "test")
 
 	router := mux.NewRouter()
-	AddRepresentativeRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t))
+	AddRepresentativeRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(repo, nil), nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -557,3 +563,86 @@ func TestRepresentatives__BadReq(t *testing.T) {
 		t.Errorf("Expected SSN error received %s", w.Body.String())
 	}
 }
+
+func TestCustomers__validateRepresentativesOwnership(t *testing.T) {
+	reps := []customerRepresentative{
+		{FirstName: "Jane", LastName: "Doe", OwnershipPercentage: 60},
+		{FirstName: "John", LastName: "Doe", OwnershipPercentage: 40},
+	}
+	require.NoError(t, validateRepresentatives(reps))
+
+	reps[1].OwnershipPercentage = 40.5
+	require.Equal(t, errOwnershipExceeded, validateRepresentatives(reps))
+
+	reps[1].OwnershipPercentage = -1
+	require.Error(t, validateRepresentatives(reps))
+}
+
+func TestCustomers__representativeOwnershipAndOFAC(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	cust, organization := setupMockCustomer(t, repo)
+
+	router := mux.NewRouter()
+	ofacClient := watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{
+		EntityID: "1241421",
+		SdnName:  "Jane Doe",
+		Match:    0.5,
+	}, nil)
+	AddRepresentativeRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(repo, ofacClient), nil)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("x-organization", organization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		w.Flush()
+		return w
+	}
+	path := fmt.Sprintf("/customers/%s/representatives", cust.CustomerID)
+
+	w := send("POST", path, `{"firstName": "Jane", "lastName": "Doe", "ownershipPercentage": 60}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var first client.Representative
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&first))
+	require.Equal(t, float32(60), first.OwnershipPercentage)
+
+	// the second representative would own too much
+	w = send("POST", path, `{"firstName": "John", "lastName": "Doe", "ownershipPercentage": 50}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), errOwnershipExceeded.Error())
+
+	w = send("POST", path, `{"firstName": "John", "lastName": "Doe", "ownershipPercentage": 40}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// updating a representative doesn't count their current ownership
+	w = send("PUT", path+"/"+first.RepresentativeID, `{"firstName": "Jane", "lastName": "Doe", "ownershipPercentage": 55}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = send("PUT", path+"/"+first.RepresentativeID, `{"firstName": "Jane", "lastName": "Doe", "ownershipPercentage": 61}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send("GET", path, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var reps []client.Representative
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&reps))
+	require.Len(t, reps, 2)
+
+	w = send("GET", path+"/"+first.RepresentativeID, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got client.Representative
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.Equal(t, float32(55), got.OwnershipPercentage)
+
+	// representatives are only found under their customer
+	w = send("GET", fmt.Sprintf("/customers/other/representatives/%s", first.RepresentativeID), "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// each representative was screened
+	w = send("GET", path+"/"+first.RepresentativeID+"/ofac", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var search client.OfacSearch
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&search))
+	require.Equal(t, "1241421", search.EntityID)
+	require.False(t, search.Blocked)
+}
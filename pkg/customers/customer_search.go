@@ -5,25 +5,41 @@
 package customers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/moov-io/base/admin"
 	moovhttp "github.com/moov-io/base/http"
 
 	"github.com/moov-io/base/log"
 
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/internal/util"
 	"github.com/moov-io/customers/pkg/client"
 	"github.com/moov-io/customers/pkg/model"
 	"github.com/moov-io/customers/pkg/route"
 )
 
+// AddCustomerAdminRoutes registers routes on the admin server, which are not exposed to end users.
+func AddCustomerAdminRoutes(logger log.Logger, svc *admin.Server, repo CustomerRepository) {
+	logger = logger.Set("package", log.String("customers"))
+
+	svc.AddHandler("/customers", adminSearchCustomers(logger, repo))
+}
+
 func searchCustomers(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 
 		organization := route.GetOrganization(w, r)
 		if organization == "" {
@@ -32,25 +48,67 @@ func searchCustomers(logger log.Logger, repo CustomerRepository) http.HandlerFun
 
 		params, err := parseSearchParams(r)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		params.Organization = organization
 
-		customers, err := repo.searchCustomers(params)
+		respondWithSearchResults(r.Context(), logger, w, repo, params)
+	}
+}
+
+// adminSearchCustomers is searchCustomers but can return deleted Customers with includeDeleted=true
+func adminSearchCustomers(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if r.Method != "GET" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		params, err := parseSearchParams(r)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
+		params.Organization = organization
+		params.IncludeDeleted = util.Yes(r.URL.Query().Get("includeDeleted"))
 
-		logger.Logf("found %d customers in search", len(customers))
+		respondWithSearchResults(r.Context(), logger, w, repo, params)
+	}
+}
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(customers)
+func respondWithSearchResults(ctx context.Context, logger log.Logger, w http.ResponseWriter, repo CustomerRepository, params SearchParams) {
+	customers, err := repo.searchCustomers(ctx, params)
+	if err != nil {
+		route.Problem(w, err)
+		return
 	}
+	total, err := repo.countCustomers(ctx, params)
+	if err != nil {
+		route.Problem(w, err)
+		return
+	}
+
+	logger.Logf("found %d customers in search", len(customers))
+
+	w.Header().Set(totalCountHeaderKey, fmt.Sprintf("%d", total))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(customers)
 }
 
+// totalCountHeaderKey is set on search responses with the number of Customers matching
+// the filters, ignoring skip and count, so clients can build links to the next page.
+const totalCountHeaderKey = "X-Total-Count"
+
 type SearchParams struct {
 	Organization string
 	Query        string
@@ -60,6 +118,38 @@ type SearchParams struct {
 	Skip         int64
 	Count        int64
 	CustomerIDs  []string
+
+	// NameTerms are matched against each of a Customer's names, results are ranked by how well they match.
+	NameTerms []string
+
+	// Tags limits results to Customers with each of these Tags, or any of them with AnyTag.
+	Tags   []string
+	AnyTag bool
+
+	// Metadata limits results to Customers with each of these metadata keys set to exactly its value.
+	Metadata map[string]string
+
+	// IncludeDeleted returns tombstoned Customers as well, it's only read from admin requests.
+	IncludeDeleted bool
+
+	// UpdatedSince limits results to Customers created or changed at or after it, including changes to
+	// their phones, addresses and metadata.
+	UpdatedSince time.Time
+
+	// CreatedAfter, CreatedBefore, ModifiedAfter and ModifiedBefore limit results to Customers created or
+	// last modified within a range. The After bounds are inclusive and the Before bounds are exclusive.
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+
+	// byCustomerID orders results by customer_id, starting after afterCustomerID, so every Customer
+	// can be read in batches without skipping or repeating any as others are created.
+	byCustomerID    bool
+	afterCustomerID string
+
+	// encryptedEmails are the encrypted_email values Email matches when emails are encrypted
+	encryptedEmails []string
 }
 
 func parseSearchParams(r *http.Request) (SearchParams, error) {
@@ -78,34 +168,158 @@ func parseSearchParams(r *http.Request) (SearchParams, error) {
 		params.CustomerIDs = strings.Split(customerIDsInput, ",")
 	}
 
-	skip, count, exists, err := moovhttp.GetSkipAndCount(r)
+	// tags can be repeated (tag=vip&tag=manual-review) or comma separated (tag=vip,manual-review)
+	seen := make(map[string]bool)
+	for _, v := range queryParams["tag"] {
+		for _, name := range strings.Split(v, ",") {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			name, err := normalizeTagName(name)
+			if err != nil {
+				return params, err
+			}
+			if !seen[name] {
+				seen[name] = true
+				params.Tags = append(params.Tags, name)
+			}
+		}
+	}
+	switch match := getQueryParam("tagMatch"); match {
+	case "", "all":
+	case "any":
+		params.AnyTag = true
+	default:
+		return params, route.Validation(fmt.Errorf("unknown tagMatch %q, expected all or any", match))
+	}
+
+	// metadata filters are repeated key=value pairs (metadata=partner_id=acme&metadata=tier=gold)
+	for _, v := range queryParams["metadata"] {
+		key, value, err := readMetadataFilter(v)
+		if err != nil {
+			return params, err
+		}
+		if params.Metadata == nil {
+			params.Metadata = make(map[string]string)
+		}
+		if prev, exists := params.Metadata[key]; exists && prev != value {
+			return params, route.Validation(fmt.Errorf("metadata key %s is filtered on more than once", key))
+		}
+		params.Metadata[key] = value
+	}
+	if len(params.Metadata) > maxMetadataFilters {
+		return params, route.Validation(fmt.Errorf("at most %d metadata filters can be used", maxMetadataFilters))
+	}
+
+	for _, filter := range []struct {
+		key string
+		dst *time.Time
+	}{
+		{"createdAfter", &params.CreatedAfter},
+		{"createdBefore", &params.CreatedBefore},
+		{"modifiedAfter", &params.ModifiedAfter},
+		{"modifiedBefore", &params.ModifiedBefore},
+	} {
+		if v := strings.TrimSpace(queryParams.Get(filter.key)); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return params, route.Validation(fmt.Errorf("%s %q is not an RFC 3339 timestamp", filter.key, v))
+			}
+			*filter.dst = t
+		}
+	}
+	if !params.CreatedAfter.IsZero() && !params.CreatedBefore.IsZero() && !params.CreatedAfter.Before(params.CreatedBefore) {
+		return params, route.Validation(errors.New("createdAfter must be before createdBefore"))
+	}
+	if !params.ModifiedAfter.IsZero() && !params.ModifiedBefore.IsZero() && !params.ModifiedAfter.Before(params.ModifiedBefore) {
+		return params, route.Validation(errors.New("modifiedAfter must be before modifiedBefore"))
+	}
+
+	skip, _, exists, err := moovhttp.GetSkipAndCount(r)
 	if exists && err != nil {
 		return params, err
 	}
+	count, err := readSearchCount(queryParams.Get("count"))
+	if err != nil {
+		return params, err
+	}
 
 	params.Skip = int64(skip)
-	params.Count = int64(count)
+	params.Count = count
 
 	return params, nil
 }
 
-func (r *sqlCustomerRepository) searchCustomers(params SearchParams) ([]*client.Customer, error) {
+// Searches return larger pages than the other listings, which use moovhttp.GetSkipAndCount's
+// default of 20 and limit of 200.
+const (
+	defaultSearchCount = 100
+	maxSearchCount     = 1000
+)
+
+// readSearchCount returns how many Customers to return for the count query parameter. It's
+// defaultSearchCount when missing or zero and capped at maxSearchCount.
+func readSearchCount(v string) (int64, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return defaultSearchCount, nil
+	}
+	count, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, route.Validation(fmt.Errorf("invalid count %q", v))
+	}
+	switch {
+	case count <= 0:
+		return defaultSearchCount, nil
+	case count > maxSearchCount:
+		return maxSearchCount, nil
+	}
+	return count, nil
+}
+
+// maxMetadataFilters limits how many metadata pairs are looked up for one search
+const maxMetadataFilters = 5
+
+// readMetadataFilter splits a key=value metadata filter. Both are required and matched exactly, so
+// each pair is found with the (meta_key, meta_value) index instead of scanning customer_metadata.
+func readMetadataFilter(v string) (string, string, error) {
+	idx := strings.Index(v, "=")
+	if idx < 0 {
+		return "", "", route.Validation(fmt.Errorf("metadata filter %q must be key=value", v))
+	}
+	key, value := strings.TrimSpace(v[:idx]), strings.TrimSpace(v[idx+1:])
+	if key == "" || utf8.RuneCountInString(key) > maxMetadataKeyLength {
+		return "", "", route.Validation(fmt.Errorf("metadata key %q must be between 1 and %d characters", key, maxMetadataKeyLength))
+	}
+	if value == "" || utf8.RuneCountInString(value) > maxMetadataValueLength {
+		return "", "", route.Validation(fmt.Errorf("metadata key %s value must be between 1 and %d characters", key, maxMetadataValueLength))
+	}
+	return key, value, nil
+}
+
+func (r *sqlCustomerRepository) searchCustomers(ctx context.Context, params SearchParams) ([]*client.Customer, error) {
+	params.encryptedEmails = r.encryptedEmailSearch(params.Email)
 	query, args := buildSearchQuery(params)
 
-	stmt, err := r.db.Prepare(query)
+	queryCtx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	stmt, err := r.db.PrepareContext(queryCtx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
 	customers := make([]*client.Customer, 0)
-	rows, err := stmt.Query(args...)
+	rows, err := stmt.QueryContext(queryCtx, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var c client.Customer
 		var birthDate *time.Time
+		var email, encryptedEmail, locale sql.NullString
 		err := rows.Scan(
 			&c.CustomerID,
 			&c.FirstName,
@@ -123,9 +337,11 @@ func (r *sqlCustomerRepository) searchCustomers(params SearchParams) ([]*client.
 			&c.NAICSCode,
 			&birthDate,
 			&c.Status,
-			&c.Email,
+			&email,
+			&encryptedEmail,
 			&c.Website,
 			&c.DateBusinessEstablished,
+			&locale,
 			&c.CreatedAt,
 			&c.LastModified,
 		)
@@ -134,7 +350,12 @@ func (r *sqlCustomerRepository) searchCustomers(params SearchParams) ([]*client.
 		}
 		if birthDate != nil {
 			c.BirthDate = birthDate.Format(model.YYYYMMDD_Format)
+			c.Age = int32(customerAge(*birthDate, time.Now()))
 		}
+		if c.Email, err = r.readEmail(email, encryptedEmail); err != nil {
+			return nil, fmt.Errorf("customer=%s: %v", c.CustomerID, err)
+		}
+		c.Locale = customerLocale(locale.String)
 		customers = append(customers, &c)
 	}
 	if err := rows.Err(); err != nil {
@@ -150,21 +371,21 @@ func (r *sqlCustomerRepository) searchCustomers(params SearchParams) ([]*client.
 		customerIDs = append(customerIDs, c.CustomerID)
 	}
 
-	phonesByCustomerID, err := r.GetPhones(customerIDs, client.OWNERTYPE_CUSTOMER)
+	phonesByCustomerID, err := r.GetPhones(ctx, customerIDs, client.OWNERTYPE_CUSTOMER, params.IncludeDeleted)
 	if err != nil {
-		return nil, fmt.Errorf("fetching customer phones: %v", err)
+		return nil, fmt.Errorf("fetching customer phones: %w", err)
 	}
-	addressesByCustomerID, err := r.GetAddresses(customerIDs, client.OWNERTYPE_CUSTOMER)
+	addressesByCustomerID, err := r.GetAddresses(ctx, customerIDs, client.OWNERTYPE_CUSTOMER, params.IncludeDeleted)
 	if err != nil {
-		return nil, fmt.Errorf("fetching customer addresses: %v", err)
+		return nil, fmt.Errorf("fetching customer addresses: %w", err)
 	}
-	representativesByCustomerID, err := r.getRepresentatives(customerIDs)
+	representativesByCustomerID, err := r.getRepresentatives(ctx, customerIDs)
 	if err != nil {
-		return nil, fmt.Errorf("fetching customer representatives: %v", err)
+		return nil, fmt.Errorf("fetching customer representatives: %w", err)
 	}
-	metadataByCustomerID, err := r.getMetadata(customerIDs)
+	metadataByCustomerID, err := r.getMetadata(ctx, customerIDs)
 	if err != nil {
-		return nil, fmt.Errorf("fetching customer metadata: %v", err)
+		return nil, fmt.Errorf("fetching customer metadata: %w", err)
 	}
 
 	for _, c := range customers {
@@ -177,10 +398,63 @@ func (r *sqlCustomerRepository) searchCustomers(params SearchParams) ([]*client.
 	return customers, nil
 }
 
+func (r *sqlCustomerRepository) countCustomers(ctx context.Context, params SearchParams) (int64, error) {
+	params.encryptedEmails = r.encryptedEmailSearch(params.Email)
+	where, args := buildSearchFilters(params)
+
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	stmt, err := r.db.PrepareContext(ctx, `select count(*) from customers`+where+";")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var total int64
+	if err := stmt.QueryRowContext(ctx, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("counting customers: %w", err)
+	}
+	return total, nil
+}
+
 func buildSearchQuery(params SearchParams) (string, []interface{}) {
+	where, args := buildSearchFilters(params)
+	query := `select customer_id, first_name, middle_name, last_name, nick_name, suffix, type, business_name, doing_business_as, business_type, ein, duns, sic_code, naics_code, birth_date, status, email, encrypted_email, website, date_business_established, locale, created_at, last_modified
+from customers` + where
+
+	if params.byCustomerID {
+		query += " order by customer_id asc limit ?;"
+		return query, append(args, fmt.Sprintf("%d", params.Count))
+	}
+
+	// customer_id breaks ties between rows created at the same time so pages never overlap
+	query += " order by "
+	if len(params.NameTerms) > 0 {
+		rank, rankArgs := customerNameSearch.rank(params.NameTerms)
+		query += rank + ", "
+		args = append(args, rankArgs...)
+	}
+	query += "created_at desc, customer_id desc limit ?"
+	args = append(args, fmt.Sprintf("%d", params.Count))
+
+	if params.Skip > 0 {
+		query += " offset ?"
+		args = append(args, fmt.Sprintf("%d", params.Skip))
+	}
+	query += ";"
+	return query, args
+}
+
+// buildSearchFilters returns the where clause (and its arguments) shared by searching
+// and counting Customers.
+func buildSearchFilters(params SearchParams) (string, []interface{}) {
 	var args []interface{}
-	query := `select customer_id, first_name, middle_name, last_name, nick_name, suffix, type, business_name, doing_business_as, business_type, ein, duns, sic_code, naics_code, birth_date, status, email, website, date_business_established, created_at, last_modified
-from customers where deleted_at is null`
+	query := " where 1 = 1"
+
+	if !params.IncludeDeleted {
+		query += " and deleted_at is null"
+	}
 
 	if params.Organization != "" {
 		query += " and organization = ?"
@@ -193,9 +467,23 @@ from customers where deleted_at is null`
 		args = append(args, fmt.Sprintf("%%%s%%", params.Query))
 	}
 
+	if len(params.NameTerms) > 0 {
+		filter, filterArgs := customerNameSearch.filter(params.NameTerms)
+		query += " and " + filter
+		args = append(args, filterArgs...)
+	}
+
 	if params.Email != "" {
-		query += " and lower(email) like ?"
+		// encrypted emails can only be matched exactly
+		query += " and (lower(email) like ?"
 		args = append(args, "%"+params.Email)
+		if len(params.encryptedEmails) > 0 {
+			query += fmt.Sprintf(" or encrypted_email in (?%s)", strings.Repeat(",?", len(params.encryptedEmails)-1))
+			for _, v := range params.encryptedEmails {
+				args = append(args, v)
+			}
+		}
+		query += ")"
 	}
 
 	if params.Status != "" {
@@ -215,24 +503,93 @@ from customers where deleted_at is null`
 		}
 	}
 
-	query += " order by created_at desc limit ?"
-	args = append(args, fmt.Sprintf("%d", params.Count))
+	if params.afterCustomerID != "" {
+		query += " and customer_id > ?"
+		args = append(args, params.afterCustomerID)
+	}
 
-	if params.Skip > 0 {
-		query += " offset ?"
-		args = append(args, fmt.Sprintf("%d", params.Skip))
+	if !params.UpdatedSince.IsZero() {
+		query += ` and (coalesce(last_modified, created_at) >= ?
+or customer_id in (select owner_id from phones where owner_type = 'customer' and last_modified >= ?)
+or customer_id in (select owner_id from addresses where owner_type = 'customer' and last_modified >= ?)
+or customer_id in (select customer_id from customer_metadata where last_modified >= ?))`
+		for i := 0; i < 4; i++ {
+			args = append(args, params.UpdatedSince)
+		}
+	}
+
+	if !params.CreatedAfter.IsZero() {
+		query += " and created_at >= ?"
+		args = append(args, params.CreatedAfter)
+	}
+	if !params.CreatedBefore.IsZero() {
+		query += " and created_at < ?"
+		args = append(args, params.CreatedBefore)
+	}
+	if !params.ModifiedAfter.IsZero() {
+		query += " and last_modified >= ?"
+		args = append(args, params.ModifiedAfter)
+	}
+	if !params.ModifiedBefore.IsZero() {
+		query += " and last_modified < ?"
+		args = append(args, params.ModifiedBefore)
+	}
+
+	if len(params.Tags) > 0 {
+		// customer_tags is indexed by tag_id so only Customers with the Tags are read
+		query += fmt.Sprintf(" and customer_id in (select ct.customer_id from customer_tags ct inner join tags t on t.tag_id = ct.tag_id where t.name in (?%s)", strings.Repeat(",?", len(params.Tags)-1))
+		for _, name := range params.Tags {
+			args = append(args, name)
+		}
+		if params.Organization != "" {
+			query += " and t.organization = ?"
+			args = append(args, params.Organization)
+		}
+		if !params.AnyTag {
+			query += " group by ct.customer_id having count(distinct ct.tag_id) = ?"
+			args = append(args, len(params.Tags))
+		}
+		query += ")"
+	}
+
+	if len(params.Metadata) > 0 {
+		keys := make([]string, 0, len(params.Metadata))
+		for k := range params.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		// each pair is an exact match on the (meta_key, meta_value) index, Customers must have all of them
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = "(meta_key = ? and meta_value = ?)"
+			args = append(args, k, params.Metadata[k])
+		}
+		query += fmt.Sprintf(" and customer_id in (select customer_id from customer_metadata where %s group by customer_id having count(distinct meta_key) = ?)", strings.Join(pairs, " or "))
+		args = append(args, len(keys))
 	}
-	query += ";"
 	return query, args
 }
 
-func (r *sqlCustomerRepository) GetPhones(ownerIDs []string, ownerType client.OwnerType) (map[string][]client.Phone, error) {
+// ownerDeletedFilter limits phones and addresses to live rows. With includeDeleted it also keeps
+// rows soft-deleted along with their customer, but not ones replaced before the customer was deleted.
+func ownerDeletedFilter(table string, includeDeleted bool) string {
+	if !includeDeleted {
+		return "deleted_at is null"
+	}
+	return fmt.Sprintf("(deleted_at is null or deleted_at = (select c.deleted_at from customers c where c.customer_id = %s.owner_id))", table)
+}
+
+func (r *sqlCustomerRepository) GetPhones(ctx context.Context, ownerIDs []string, ownerType client.OwnerType, includeDeleted bool) (map[string][]client.Phone, error) {
 	query := fmt.Sprintf(
-		"select owner_id, owner_type, number, valid, type from phones where owner_id in (?%s) and owner_type = ?",
-		strings.Repeat(",?", len(ownerIDs)-1),
+		"select owner_id, owner_type, number, encrypted_number, valid, type, created_at, last_modified from phones where owner_id in (?%s) and owner_type = ? and %s;",
+		strings.Repeat(",?", len(ownerIDs)-1), ownerDeletedFilter("phones", includeDeleted),
 	)
 
-	rows, err := r.queryRowsByCustomerIDsAndOwnerType(query, ownerIDs, ownerType)
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	rows, err := r.queryRowsByCustomerIDsAndOwnerType(ctx, query, ownerIDs, ownerType)
 	if err != nil {
 		return nil, err
 	}
@@ -242,16 +599,25 @@ func (r *sqlCustomerRepository) GetPhones(ownerIDs []string, ownerType client.Ow
 	for rows.Next() {
 		var p client.Phone
 		var ownerID string
+		var number, encryptedNumber sql.NullString
+		var createdAt, lastModified *time.Time
 		err := rows.Scan(
 			&ownerID,
 			&p.OwnerType,
-			&p.Number,
+			&number,
+			&encryptedNumber,
 			&p.Valid,
 			&p.Type,
+			&createdAt,
+			&lastModified,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scanning row: %v", err)
 		}
+		if p.Number, err = r.readPhoneNumber(number, encryptedNumber); err != nil {
+			return nil, fmt.Errorf("owner=%s: %v", ownerID, err)
+		}
+		p.CreatedAt, p.LastModified = readTimestamps(createdAt, lastModified)
 		ret[ownerID] = append(ret[ownerID], p)
 	}
 
@@ -262,12 +628,16 @@ func (r *sqlCustomerRepository) GetPhones(ownerIDs []string, ownerType client.Ow
 	return ret, nil
 }
 
-func (r *sqlCustomerRepository) GetAddresses(customerIDs []string, ownerType client.OwnerType) (map[string][]client.Address, error) {
+func (r *sqlCustomerRepository) GetAddresses(ctx context.Context, customerIDs []string, ownerType client.OwnerType, includeDeleted bool) (map[string][]client.Address, error) {
 	query := fmt.Sprintf(
-		"select owner_id, owner_type, address_id, type, address1, address2, city, state, postal_code, country, validated from addresses where owner_id in (?%s) and owner_type = ? and deleted_at is null;",
-		strings.Repeat(",?", len(customerIDs)-1),
+		"select owner_id, owner_type, address_id, type, address1, address2, city, state, postal_code, country, validated, created_at, last_modified from addresses where owner_id in (?%s) and owner_type = ? and %s order by case when type = 'primary' then 0 else 1 end, address1;",
+		strings.Repeat(",?", len(customerIDs)-1), ownerDeletedFilter("addresses", includeDeleted),
 	)
-	rows, err := r.queryRowsByCustomerIDsAndOwnerType(query, customerIDs, ownerType)
+
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	rows, err := r.queryRowsByCustomerIDsAndOwnerType(ctx, query, customerIDs, ownerType)
 	if err != nil {
 		return nil, err
 	}
@@ -277,6 +647,7 @@ func (r *sqlCustomerRepository) GetAddresses(customerIDs []string, ownerType cli
 	for rows.Next() {
 		var a client.Address
 		var ownerID string
+		var createdAt, lastModified *time.Time
 		if err := rows.Scan(
 			&ownerID,
 			&a.OwnerType,
@@ -289,9 +660,12 @@ func (r *sqlCustomerRepository) GetAddresses(customerIDs []string, ownerType cli
 			&a.PostalCode,
 			&a.Country,
 			&a.Validated,
+			&createdAt,
+			&lastModified,
 		); err != nil {
 			return nil, fmt.Errorf("scanning row: %v", err)
 		}
+		a.CreatedAt, a.LastModified = readTimestamps(createdAt, lastModified)
 		ret[ownerID] = append(ret[ownerID], a)
 	}
 
@@ -302,29 +676,42 @@ func (r *sqlCustomerRepository) GetAddresses(customerIDs []string, ownerType cli
 	return ret, nil
 }
 
-func (r *sqlCustomerRepository) getMetadata(customerIDs []string) (map[string]client.CustomerMetadata, error) {
+func (r *sqlCustomerRepository) getMetadata(ctx context.Context, customerIDs []string) (map[string]client.CustomerMetadata, error) {
 	query := fmt.Sprintf(
-		"select customer_id, meta_key, meta_value from customer_metadata where customer_id in (?%s);",
+		"select customer_id, meta_key, meta_value, created_at, last_modified from customer_metadata where customer_id in (?%s);",
 		strings.Repeat(",?", len(customerIDs)-1),
 	)
-	rows, err := r.queryRowsByCustomerIDs(query, customerIDs)
+
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	rows, err := r.queryRowsByCustomerIDs(ctx, query, customerIDs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	result := make(map[string]client.CustomerMetadata)
-	m := client.CustomerMetadata{
-		Metadata: make(map[string]string),
-	}
-
 	for rows.Next() {
 		var customerID string
 		var k, v string
-		if err := rows.Scan(&customerID, &k, &v); err != nil {
+		var createdAt, lastModified *time.Time
+		if err := rows.Scan(&customerID, &k, &v, &createdAt, &lastModified); err != nil {
 			return nil, fmt.Errorf("scanning row: %v", err)
 		}
+		m, exists := result[customerID]
+		if !exists {
+			m = client.CustomerMetadata{Metadata: make(map[string]string)}
+		}
 		m.Metadata[k] = v
+
+		// the metadata was created with its oldest key and modified with its newest change
+		if createdAt != nil && (m.CreatedAt == nil || createdAt.Before(*m.CreatedAt)) {
+			m.CreatedAt = createdAt
+		}
+		if lastModified != nil && (m.LastModified == nil || lastModified.After(*m.LastModified)) {
+			m.LastModified = lastModified
+		}
 		result[customerID] = m
 	}
 
@@ -335,10 +722,24 @@ func (r *sqlCustomerRepository) getMetadata(customerIDs []string) (map[string]cl
 	return result, nil
 }
 
-func (r *sqlCustomerRepository) queryRowsByCustomerIDsAndOwnerType(query string, customerIDs []string, ownerType client.OwnerType) (*sql.Rows, error) {
-	stmt, err := r.db.Prepare(query)
+// readTimestamps returns the created_at and last_modified of a phone or address. Both are set for
+// every row, but are read as nullable since they were added after the tables were created.
+func readTimestamps(createdAt, lastModified *time.Time) (time.Time, time.Time) {
+	var c, m time.Time
+	if createdAt != nil {
+		c = *createdAt
+	}
+	if lastModified != nil {
+		m = *lastModified
+	}
+	return c, m
+}
+
+// queryRowsByCustomerIDsAndOwnerType runs query with ctx, which must stay open while the rows are read
+func (r *sqlCustomerRepository) queryRowsByCustomerIDsAndOwnerType(ctx context.Context, query string, customerIDs []string, ownerType client.OwnerType) (*sql.Rows, error) {
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("preparing query: %v", err)
+		return nil, fmt.Errorf("preparing query: %w", err)
 	}
 	defer stmt.Close()
 
@@ -349,18 +750,19 @@ func (r *sqlCustomerRepository) queryRowsByCustomerIDsAndOwnerType(query string,
 
 	args = append(args, string(ownerType))
 
-	rows, err := stmt.Query(args...)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
-		return nil, fmt.Errorf("executing query: %v", err)
+		return nil, fmt.Errorf("executing query: %w", err)
 	}
 
 	return rows, nil
 }
 
-func (r *sqlCustomerRepository) queryRowsByCustomerIDs(query string, customerIDs []string) (*sql.Rows, error) {
-	stmt, err := r.db.Prepare(query)
+// queryRowsByCustomerIDs runs query with ctx, which must stay open while the rows are read
+func (r *sqlCustomerRepository) queryRowsByCustomerIDs(ctx context.Context, query string, customerIDs []string) (*sql.Rows, error) {
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("preparing query: %v", err)
+		return nil, fmt.Errorf("preparing query: %w", err)
 	}
 	defer stmt.Close()
 
@@ -369,9 +771,9 @@ func (r *sqlCustomerRepository) queryRowsByCustomerIDs(query string, customerIDs
 		args = append(args, id)
 	}
 
-	rows, err := stmt.Query(args...)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
-		return nil, fmt.Errorf("executing query: %v", err)
+		return nil, fmt.Errorf("executing query: %w", err)
 	}
 
 	return rows, nil
@@ -1,6 +1,7 @@
 package customers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http/httptest"
 	"testing"
@@ -31,7 +32,7 @@ func Setup(t *testing.T) Scope {
 
 func (scope *Scope) GetCustomers(query, organization string) ([]*client.Customer, error) {
 	router := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router, scope.customerRepo, nil, nil)
+	AddCustomerRoutes(log.NewNopLogger(), router, scope.customerRepo, nil, nil, nil, nil)
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/customers"+query, nil)
 	req.Header.Set("X-Organization", organization)
@@ -53,20 +54,20 @@ func (scope *Scope) CreateCustomers(count int, customerType client.CustomerType,
 		scope.fuzzer.Fuzz(&firstName)
 		scope.fuzzer.Fuzz(&lastName)
 		scope.fuzzer.Fuzz(&email)
-		customer := scope.CreateCustomer(firstName, lastName, organization, email, customerType)
+		customer := scope.CreateCustomer(context.Background(), firstName, lastName, organization, email, customerType)
 		customers = append(customers, customer)
 	}
 	return customers
 }
 
-func (scope *Scope) CreateCustomer(firstName, lastName, organization, email string, customerType client.CustomerType) client.Customer {
+func (scope *Scope) CreateCustomer(ctx context.Context, firstName, lastName, organization, email string, customerType client.CustomerType) client.Customer {
 	cust, _, _ := (customerRequest{
 		FirstName: firstName,
 		LastName:  lastName,
 		Email:     email,
 		Type:      customerType,
 	}).asCustomer(testCustomerSSNStorage(scope.t))
-	if err := scope.customerRepo.CreateCustomer(cust, organization); err != nil {
+	if err := scope.customerRepo.CreateCustomer(ctx, cust, organization); err != nil {
 		scope.t.Error(err)
 	}
 	return *cust
@@ -5,20 +5,25 @@
 package customers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/moov-io/base/admin"
 	"github.com/moov-io/base/log"
 	"github.com/stretchr/testify/require"
 
 	"github.com/moov-io/base/database"
 	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
 )
 
 func TestCustomersSearchRouter(t *testing.T) {
@@ -27,7 +32,7 @@ func TestCustomersSearchRouter(t *testing.T) {
 	defer db.Close()
 
 	router := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router, repo, nil, nil)
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, nil, nil, nil, nil)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/customers?query=jane+doe", nil)
@@ -48,7 +53,7 @@ func TestCustomersSearchRouter(t *testing.T) {
 		LastName:  "Doe",
 		Email:     "jane@example.com",
 	}).asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, "organization"); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, "organization"); err != nil {
 		t.Error(err)
 	}
 
@@ -125,7 +130,7 @@ func TestRepository__searchCustomers(t *testing.T) {
 			Metadata: map[string]string{"key": "val"},
 		}
 		cust, _, _ := req.asCustomer(testCustomerSSNStorage(t))
-		require.NoError(t, repo.CreateCustomer(cust, org))
+		require.NoError(t, repo.CreateCustomer(context.Background(), cust, org))
 		return cust
 	}
 
@@ -159,7 +164,7 @@ func TestRepository__searchCustomers(t *testing.T) {
 				Organization: org,
 				Count:        5,
 			}
-			got, err := repo.searchCustomers(params)
+			got, err := repo.searchCustomers(context.Background(), params)
 			require.NoError(t, err)
 			require.Len(t, got, int(params.Count))
 
@@ -169,7 +174,7 @@ func TestRepository__searchCustomers(t *testing.T) {
 				Count:        1,
 				Email:        "JaNe-3@moov.com",
 			}
-			got, err = repo.searchCustomers(params)
+			got, err = repo.searchCustomers(context.Background(), params)
 			require.NoError(t, err)
 			require.Equal(t, strings.ToLower(params.Email), got[0].Email)
 
@@ -180,7 +185,7 @@ func TestRepository__searchCustomers(t *testing.T) {
 					Count:        100,
 					Query:        "jane-10",
 				}
-				got, err = repo.searchCustomers(params)
+				got, err = repo.searchCustomers(context.Background(), params)
 				require.NoError(t, err)
 				require.Len(t, got, 1)
 			}
@@ -191,7 +196,7 @@ func TestRepository__searchCustomers(t *testing.T) {
 				wantIDs[i] = customers[i].CustomerID
 			}
 
-			got, err = repo.searchCustomers(SearchParams{
+			got, err = repo.searchCustomers(context.Background(), SearchParams{
 				Organization: org,
 				CustomerIDs:  wantIDs,
 				Count:        10,
@@ -218,12 +223,33 @@ func TestCustomerSearchEmpty(t *testing.T) {
 	}
 }
 
-func TestGet20MostRecentlyCreatedCustomersByDefault(t *testing.T) {
+func TestGet100MostRecentlyCreatedCustomersByDefault(t *testing.T) {
 	scope := Setup(t)
 	organization := "organization"
-	scope.CreateCustomers(100, client.CUSTOMERTYPE_INDIVIDUAL, organization)
+	scope.CreateCustomers(120, client.CUSTOMERTYPE_INDIVIDUAL, organization)
 	customers, _ := scope.GetCustomers("", organization)
-	scope.assert.Equal(20, len(customers))
+	scope.assert.Equal(100, len(customers))
+}
+
+func TestReadSearchCount(t *testing.T) {
+	count, err := readSearchCount("")
+	require.NoError(t, err)
+	require.Equal(t, int64(defaultSearchCount), count)
+
+	count, err = readSearchCount("0")
+	require.NoError(t, err)
+	require.Equal(t, int64(defaultSearchCount), count)
+
+	count, err = readSearchCount("250")
+	require.NoError(t, err)
+	require.Equal(t, int64(250), count)
+
+	count, err = readSearchCount("5000")
+	require.NoError(t, err)
+	require.Equal(t, int64(maxSearchCount), count)
+
+	_, err = readSearchCount("abc")
+	require.Error(t, err)
 }
 
 func TestGet10MostRecentlyCreatedCustomersByDefault(t *testing.T) {
@@ -254,11 +280,11 @@ func TestSearchCustomersWithVerifiedStatus(t *testing.T) {
 	// Create two customers. 1 with Unknown STATUS and 1 with Verified
 	scope := Setup(t)
 	organization := "organization"
-	customer := scope.CreateCustomer("John", "Doe", organization, "john.doe@email.com", client.CUSTOMERTYPE_INDIVIDUAL)
-	if err := scope.customerRepo.updateCustomerStatus(customer.CustomerID, client.CUSTOMERSTATUS_VERIFIED, "test comment"); err != nil {
+	customer := scope.CreateCustomer(context.Background(), "John", "Doe", organization, "john.doe@email.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	if err := scope.customerRepo.updateCustomerStatus(context.Background(), customer.CustomerID, client.CUSTOMERSTATUS_VERIFIED, "test comment", ""); err != nil {
 		print(err)
 	}
-	scope.CreateCustomer("Jane", "Doe", organization, "jane.doe@email.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	scope.CreateCustomer(context.Background(), "Jane", "Doe", organization, "jane.doe@email.com", client.CUSTOMERTYPE_INDIVIDUAL)
 
 	// Should have 1 Verified Status
 	verifiedCustomers, _ := scope.GetCustomers("?status=Verified&count=20", organization)
@@ -282,8 +308,8 @@ func TestSearchCustomersByName(t *testing.T) {
 		db:     database.CreateTestMySQLDB(t).DB,
 	}
 	organization := "organization"
-	_ = scope.CreateCustomer("Jane", "Doe", organization, "jane.doe@gmail.com", client.CUSTOMERTYPE_INDIVIDUAL)
-	_ = scope.CreateCustomer("John", "Doe", organization, "jane.doe@gmail.com", client.CUSTOMERTYPE_BUSINESS)
+	_ = scope.CreateCustomer(context.Background(), "Jane", "Doe", organization, "jane.doe@gmail.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	_ = scope.CreateCustomer(context.Background(), "John", "Doe", organization, "jane.doe@gmail.com", client.CUSTOMERTYPE_BUSINESS)
 
 	customers, _ := scope.GetCustomers("?query=jane", organization)
 	scope.assert.Equal(1, len(customers))
@@ -307,8 +333,8 @@ func TestSearchCustomersByName(t *testing.T) {
 func TestSearchCustomersByEmail(t *testing.T) {
 	scope := Setup(t)
 	organization := "organization"
-	_ = scope.CreateCustomer("Jane", "Doe", organization, "jane.doe@gmail.com", client.CUSTOMERTYPE_INDIVIDUAL)
-	_ = scope.CreateCustomer("John", "Doe", organization, "john.doe@gmail.com", client.CUSTOMERTYPE_BUSINESS)
+	_ = scope.CreateCustomer(context.Background(), "Jane", "Doe", organization, "jane.doe@gmail.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	_ = scope.CreateCustomer(context.Background(), "John", "Doe", organization, "john.doe@gmail.com", client.CUSTOMERTYPE_BUSINESS)
 
 	customers, _ := scope.GetCustomers("?email=jane.doe@gmail.com", organization)
 	scope.assert.Equal(1, len(customers))
@@ -327,8 +353,8 @@ func TestSearchCustomersByNameAndEmail(t *testing.T) {
 		db:     database.CreateTestMySQLDB(t).DB,
 	}
 	organization := "organization"
-	_ = scope.CreateCustomer("Jane", "Doe", organization, "jane.doe@gmail.com", client.CUSTOMERTYPE_INDIVIDUAL)
-	_ = scope.CreateCustomer("John", "Doe", organization, "john.doe@gmail.com", client.CUSTOMERTYPE_BUSINESS)
+	_ = scope.CreateCustomer(context.Background(), "Jane", "Doe", organization, "jane.doe@gmail.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	_ = scope.CreateCustomer(context.Background(), "John", "Doe", organization, "john.doe@gmail.com", client.CUSTOMERTYPE_BUSINESS)
 
 	customers, _ := scope.GetCustomers("?query=jane+doe&email=jane.doe@gmail.com", organization)
 	scope.assert.Equal(1, len(customers))
@@ -374,6 +400,136 @@ func TestSearchCustomersUsingPaging(t *testing.T) {
 	scope.assert.Equal(0, len(customers))
 }
 
+func TestSearchCustomersTotalCount(t *testing.T) {
+	scope := Setup(t)
+	organization := "organization"
+	created := scope.CreateCustomers(30, client.CUSTOMERTYPE_INDIVIDUAL, organization)
+	_ = scope.CreateCustomers(5, client.CUSTOMERTYPE_BUSINESS, organization)
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, scope.customerRepo, nil, nil, nil, nil)
+
+	seen := make(map[string]bool)
+	for skip := 0; skip < 30; skip += 10 {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", fmt.Sprintf("/customers?type=individual&skip=%d&count=10", skip), nil)
+		req.Header.Set("X-Organization", organization)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "30", w.Header().Get("X-Total-Count"))
+
+		var customers []*client.Customer
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&customers))
+		require.Len(t, customers, 10)
+		for _, c := range customers {
+			require.False(t, seen[c.CustomerID], "customer=%s returned on multiple pages", c.CustomerID)
+			seen[c.CustomerID] = true
+		}
+	}
+	require.Len(t, seen, len(created))
+}
+
+func TestSearchCustomersByMetadata(t *testing.T) {
+	scope := Setup(t)
+	organization := "organization"
+
+	acme := scope.CreateCustomer(context.Background(), "John", "Doe", organization, "john@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	globex := scope.CreateCustomer(context.Background(), "Jane", "Doe", organization, "jane@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	scope.CreateCustomer(context.Background(), "Jim", "Doe", organization, "jim@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	require.NoError(t, scope.customerRepo.replaceCustomerMetadata(context.Background(), acme.CustomerID, map[string]string{"partner_id": "acme", "tier": "gold", "ref": "a=b"}))
+	require.NoError(t, scope.customerRepo.replaceCustomerMetadata(context.Background(), globex.CustomerID, map[string]string{"partner_id": "globex", "tier": "silver"}))
+
+	ids := func(query string) []string {
+		customers, err := scope.GetCustomers(query, organization)
+		require.NoError(t, err)
+		var out []string
+		for i := range customers {
+			out = append(out, customers[i].CustomerID)
+		}
+		return out
+	}
+	require.Equal(t, []string{acme.CustomerID}, ids("?metadata=partner_id=acme"))
+	require.Equal(t, []string{acme.CustomerID}, ids("?metadata=partner_id%3Dacme&metadata=tier%3Dgold"))
+	require.Equal(t, []string{acme.CustomerID}, ids("?metadata=ref=a=b"))
+	require.Equal(t, []string{globex.CustomerID}, ids("?metadata=tier=silver&type=individual"))
+	require.Empty(t, ids("?metadata=partner_id=acme&metadata=tier=silver"))
+	require.Empty(t, ids("?metadata=partner_id=ACME"))
+
+	// metadata is scoped to the organization
+	customers, err := scope.GetCustomers("?metadata=partner_id=acme", "other")
+	require.NoError(t, err)
+	require.Empty(t, customers)
+
+	total, err := scope.customerRepo.countCustomers(context.Background(), SearchParams{Organization: organization, Metadata: map[string]string{"partner_id": "globex"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), total)
+
+	for _, query := range []string{
+		"?metadata=partner_id",
+		"?metadata==acme",
+		"?metadata=partner_id=",
+		"?metadata=partner_id=acme&metadata=partner_id=globex",
+		"?metadata=a=1&metadata=b=2&metadata=c=3&metadata=d=4&metadata=e=5&metadata=f=6",
+	} {
+		_, err := parseSearchParams(httptest.NewRequest("GET", "/customers"+query, nil))
+		require.Error(t, err, query)
+	}
+}
+
+func TestSearchCustomersByDateRange(t *testing.T) {
+	scope := Setup(t)
+	organization := "organization"
+	custs := scope.CreateCustomers(3, client.CUSTOMERTYPE_INDIVIDUAL, organization)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	days := func(n int) time.Time { return now.Add(time.Duration(n) * 24 * time.Hour) }
+	for i, offsets := range [][2]int{{-30, -20}, {-10, -5}, {-3, -1}} {
+		_, err := scope.customerRepo.db.Exec(`update customers set created_at = ?, last_modified = ? where customer_id = ?;`, days(offsets[0]), days(offsets[1]), custs[i].CustomerID)
+		require.NoError(t, err)
+	}
+
+	ids := func(query string) []string {
+		customers, err := scope.GetCustomers(query, organization)
+		require.NoError(t, err)
+		var out []string
+		for i := range customers {
+			out = append(out, customers[i].CustomerID)
+		}
+		return out
+	}
+	format := func(n int) string { return days(n).Format(time.RFC3339) }
+
+	require.Equal(t, []string{custs[2].CustomerID, custs[1].CustomerID}, ids("?createdAfter="+format(-10)))
+	require.Equal(t, []string{custs[0].CustomerID}, ids("?createdBefore="+format(-10)))
+	require.Equal(t, []string{custs[1].CustomerID}, ids("?createdAfter="+format(-15)+"&createdBefore="+format(-3)))
+	require.Equal(t, []string{custs[2].CustomerID}, ids("?modifiedAfter="+format(-2)))
+	require.Equal(t, []string{custs[1].CustomerID, custs[0].CustomerID}, ids("?modifiedBefore="+format(-1)))
+	require.Equal(t, []string{custs[1].CustomerID}, ids("?createdBefore="+format(-4)+"&modifiedAfter="+format(-6)+"&count=1"))
+	require.Equal(t, []string{custs[1].CustomerID}, ids("?createdAfter="+format(-15)+"&skip=1&count=1"))
+	require.Empty(t, ids("?createdAfter="+format(-15)+"&type=business"))
+
+	total, err := scope.customerRepo.countCustomers(context.Background(), SearchParams{Organization: organization, ModifiedAfter: days(-6)})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, scope.customerRepo, nil, nil, nil, nil)
+	for _, query := range []string{
+		"?createdAfter=yesterday",
+		"?createdBefore=2020-01-02",
+		"?modifiedAfter=2020-01-02T15:04:05",
+		"?createdAfter=" + format(-1) + "&createdBefore=" + format(-2),
+		"?modifiedAfter=" + format(-1) + "&modifiedBefore=" + format(-1),
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/customers"+query, nil)
+		req.Header.Set("X-Organization", organization)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
 func TestSearchCustomersUsingPagingFailure(t *testing.T) {
 	scope := Setup(t)
 	organization := "organization"
@@ -401,3 +557,108 @@ func TestSearchCustomersByType(t *testing.T) {
 	businessCustomers, _ := scope.GetCustomers("?type=business", organization)
 	scope.assert.Equal(20, len(businessCustomers))
 }
+
+func TestCustomersAdmin__searchIncludeDeleted(t *testing.T) {
+	scope := Setup(t)
+	organization := "organization"
+	customers := scope.CreateCustomers(3, client.CUSTOMERTYPE_INDIVIDUAL, organization)
+	require.NoError(t, scope.customerRepo.deleteCustomer(context.Background(), customers[0].CustomerID))
+
+	svc := admin.NewServer(":0")
+	defer svc.Shutdown()
+	AddCustomerAdminRoutes(log.NewNopLogger(), svc, scope.customerRepo)
+	go svc.Listen()
+
+	search := func(query string) []*client.Customer {
+		req, err := http.NewRequest("GET", "http://"+svc.BindAddr()+"/customers"+query, nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Organization", organization)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var out []*client.Customer
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return out
+	}
+	require.Len(t, search(""), 2)
+	require.Len(t, search("?includeDeleted=true"), 3)
+
+	// public routes ignore includeDeleted
+	found, err := scope.GetCustomers("?includeDeleted=true", organization)
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+}
+
+func TestSearchCustomersByNameRanked(t *testing.T) {
+	scope := Setup(t)
+	organization := "organization"
+	_ = scope.CreateCustomer(context.Background(), "Jane", "Doe", organization, "jane.doe@gmail.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	_ = scope.CreateCustomer(context.Background(), "John", "Doerr", organization, "john.doerr@gmail.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	_ = scope.CreateCustomer(context.Background(), "Ado", "Smith", organization, "ado@gmail.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	_ = scope.CreateCustomer(context.Background(), "Jane", "Doe", "other", "jane.doe@gmail.com", client.CUSTOMERTYPE_INDIVIDUAL)
+
+	// exact matches come before prefix and partial matches
+	customers, err := scope.GetCustomers("/search?name=DOE", organization)
+	scope.assert.NoError(err)
+	scope.assert.Len(customers, 2)
+	scope.assert.Equal("Doe", customers[0].LastName)
+	scope.assert.Equal("Doerr", customers[1].LastName)
+
+	customers, err = scope.GetCustomers("/search?name=do", organization)
+	scope.assert.NoError(err)
+	scope.assert.Len(customers, 3)
+	scope.assert.Equal("Ado", customers[2].FirstName)
+
+	// every term has to match
+	customers, err = scope.GetCustomers("/search?name=jane+doe", organization)
+	scope.assert.NoError(err)
+	scope.assert.Len(customers, 1)
+
+	customers, err = scope.GetCustomers("/search?name=do&email=john.doerr@gmail.com", organization)
+	scope.assert.NoError(err)
+	scope.assert.Len(customers, 1)
+
+	customers, err = scope.GetCustomers("/search?name=do&count=1", organization)
+	scope.assert.NoError(err)
+	scope.assert.Len(customers, 1)
+
+	// wildcards aren't patterns
+	customers, err = scope.GetCustomers("/search?name=%25", organization)
+	scope.assert.Error(err)
+	scope.assert.Empty(customers)
+}
+
+func TestSearchCustomers__cancelled(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, nil, nil, nil, nil)
+
+	search := func(ctx context.Context) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/customers", nil).WithContext(ctx)
+		req.Header.Set("X-Organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// the request timed out
+	ctx, cancelFn := context.WithTimeout(context.Background(), -time.Second)
+	defer cancelFn()
+	w := search(ctx)
+	require.Equal(t, http.StatusGatewayTimeout, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), route.CodeTimeout)
+
+	// the client went away
+	ctx, cancelFn = context.WithCancel(context.Background())
+	cancelFn()
+	w = search(ctx)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+
+	_, err := repo.countCustomers(ctx, SearchParams{Organization: "test"})
+	require.True(t, errors.Is(err, context.Canceled))
+}
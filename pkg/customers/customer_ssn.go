@@ -6,13 +6,16 @@ package customers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"github.com/moov-io/customers/pkg/client"
 	"strings"
 	"time"
 	"unicode/utf8"
 
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/client"
 	"github.com/moov-io/customers/pkg/secrets"
+	"github.com/moov-io/customers/pkg/secrets/hash"
 
 	"github.com/moov-io/base/log"
 )
@@ -22,6 +25,12 @@ type SSN struct {
 	ownerType client.OwnerType
 	encrypted string
 	masked    string
+
+	// hash is a salted SHA256 of the SSN used to find Customers with the same SSN
+	hash string
+
+	// denied is the reason the SSN is on the denylist, if it is
+	denied string
 }
 
 func (s *SSN) String() string {
@@ -31,15 +40,27 @@ func (s *SSN) String() string {
 type ssnStorage struct {
 	keeper *secrets.StringKeeper
 	repo   SSNRepository
+
+	// salt is prepended to SSNs before they're hashed
+	salt string
+
+	// denylist rejects Customers given one of its SSNs, nothing is denied when it's nil
+	denylist *SSNDenylist
 }
 
-func NewSSNStorage(keeper *secrets.StringKeeper, repo SSNRepository) *ssnStorage {
+func NewSSNStorage(keeper *secrets.StringKeeper, repo SSNRepository, salt string) *ssnStorage {
 	return &ssnStorage{
 		keeper: keeper,
 		repo:   repo,
+		salt:   salt,
 	}
 }
 
+// UseDenylist checks each SSN stored against denylist
+func (s *ssnStorage) UseDenylist(denylist *SSNDenylist) {
+	s.denylist = denylist
+}
+
 func (s *ssnStorage) encryptRaw(ownerID string, ownerType client.OwnerType, raw string) (*SSN, error) {
 	defer func() {
 		raw = ""
@@ -47,35 +68,102 @@ func (s *ssnStorage) encryptRaw(ownerID string, ownerType client.OwnerType, raw
 	if ownerID == "" || raw == "" {
 		return nil, fmt.Errorf("missing parent=%s and/or SSN", ownerID)
 	}
+	raw = normalizeSSN(raw)
 	encrypted, err := s.keeper.EncryptString(raw)
 	if err != nil {
 		return nil, fmt.Errorf("ssnStorage: encrypt owner=%s: %v", ownerID, err)
 	}
+	hashed, err := hash.SHA256Hash(s.salt, raw)
+	if err != nil {
+		return nil, fmt.Errorf("ssnStorage: hash owner=%s: %v", ownerID, err)
+	}
 	return &SSN{
 		ownerID:   ownerID,
 		ownerType: ownerType,
 		encrypted: encrypted,
 		masked:    maskSSN(raw),
+		hash:      hashed,
+		denied:    s.denylist.match(raw),
 	}, nil
 }
 
+// decryptRaw returns the plaintext SSN. Values which were not encrypted by our keeper (e.g. plaintext
+// rows written before encryption) return an error instead of garbage.
+func (s *ssnStorage) decryptRaw(ssn *SSN) (string, error) {
+	if ssn == nil || ssn.encrypted == "" {
+		return "", errors.New("ssnStorage: missing encrypted SSN")
+	}
+	raw, err := s.keeper.DecryptString(ssn.encrypted)
+	if err != nil {
+		return "", fmt.Errorf("ssnStorage: decrypt owner=%s: %v", ssn.ownerID, err)
+	}
+	return raw, nil
+}
+
+var (
+	errSSNFormat = errors.New("SSN must be nine digits")
+	errSSNArea   = errors.New("SSN area number is invalid")
+	errSSNGroup  = errors.New("SSN group number can't be 00")
+	errSSNSerial = errors.New("SSN serial number can't be 0000")
+)
+
+// normalizeSSN removes the separators commonly used when writing an SSN, e.g. 123-45-6789
+func normalizeSSN(raw string) string {
+	return strings.NewReplacer("-", "", ".", "", " ", "").Replace(strings.TrimSpace(raw))
+}
+
+// validateSSN checks raw follows the rules for issued SSNs: nine digits, an area number that isn't
+// 000, 666 or 900-999, a group number that isn't 00 and a serial number that isn't 0000.
+//
+// Errors never contain the SSN so they're safe to return and log.
+func validateSSN(raw string) error {
+	ssn := normalizeSSN(raw)
+	if len(ssn) != 9 {
+		return errSSNFormat
+	}
+	for _, r := range ssn {
+		if r < '0' || r > '9' {
+			return errSSNFormat
+		}
+	}
+	if area := ssn[0:3]; area == "000" || area == "666" || area[0] == '9' {
+		return errSSNArea
+	}
+	if ssn[3:5] == "00" {
+		return errSSNGroup
+	}
+	if ssn[5:9] == "0000" {
+		return errSSNSerial
+	}
+	return nil
+}
+
+// LastFour returns the last four digits of an SSN, or an empty string if it's too short.
+func LastFour(ssn string) string {
+	ssn = normalizeSSN(ssn)
+	if utf8.RuneCountInString(ssn) < 4 {
+		return ""
+	}
+	return ssn[len(ssn)-4:]
+}
+
 func maskSSN(s string) string {
-	s = strings.NewReplacer("-", "", ".", "").Replace(strings.TrimSpace(s))
-	if utf8.RuneCountInString(s) < 3 {
+	s = normalizeSSN(s)
+	last := LastFour(s)
+	if last == "" {
 		return "##" // too short, we can't mask anything
-	} else {
-		// turn '123456789' into '1******9'
-		first, last := s[0:1], s[len(s)-1:]
-		return fmt.Sprintf("%s%s%s", first, strings.Repeat("#", len(s)-2), last)
 	}
+	// turn '123456789' into '#####6789'
+	return strings.Repeat("#", len(s)-len(last)) + last
 }
 
 type SSNRepository interface {
 	saveSSN(*SSN) error
 	getSSN(ownerID string, ownerType client.OwnerType) (*SSN, error)
+	deleteSSN(ownerID string, ownerType client.OwnerType) error
 }
 
-func NewCustomerSSNRepository(logger log.Logger, db *sql.DB) SSNRepository {
+func NewCustomerSSNRepository(logger log.Logger, db customersdb.Querier) SSNRepository {
 	return &sqlSSNRepository{
 		db:     db,
 		logger: logger,
@@ -83,21 +171,25 @@ func NewCustomerSSNRepository(logger log.Logger, db *sql.DB) SSNRepository {
 }
 
 type sqlSSNRepository struct {
-	db     *sql.DB
+	db     customersdb.Querier
 	logger log.Logger
 }
 
 //
 
 func (r *sqlSSNRepository) saveSSN(ssn *SSN) error {
-	query := `replace into ssn (owner_id, owner_type, ssn, ssn_masked, created_at) values (?, ?, ?, ?, ?);`
+	query := `replace into ssn (owner_id, owner_type, ssn, ssn_masked, ssn_hash, created_at) values (?, ?, ?, ?, ?, ?);`
 	stmt, err := r.db.Prepare(query)
 	if err != nil {
 		return fmt.Errorf("sqlSSNRepository: saveSSN prepare: %v", err)
 	}
 	defer stmt.Close()
 
-	if _, err := stmt.Exec(ssn.ownerID, string(ssn.ownerType), ssn.encrypted, ssn.masked, time.Now()); err != nil {
+	var hashed *string
+	if ssn.hash != "" {
+		hashed = &ssn.hash
+	}
+	if _, err := stmt.Exec(ssn.ownerID, string(ssn.ownerType), ssn.encrypted, ssn.masked, hashed, time.Now()); err != nil {
 		return fmt.Errorf("sqlSSNRepository: saveSSN: exec: %v", err)
 	}
 	return nil
@@ -122,3 +214,11 @@ func (r *sqlSSNRepository) getSSN(ownerID string, ownerType client.OwnerType) (*
 	}
 	return &ssn, nil
 }
+
+func (r *sqlSSNRepository) deleteSSN(ownerID string, ownerType client.OwnerType) error {
+	query := `delete from ssn where owner_id = ? and owner_type = ?;`
+	if _, err := r.db.Exec(query, ownerID, string(ownerType)); err != nil {
+		return fmt.Errorf("sqlSSNRepository: deleteSSN: %v", err)
+	}
+	return nil
+}
@@ -35,6 +35,11 @@ func (r *testCustomerSSNRepository) saveSSN(*SSN) error {
 	return r.err
 }
 
+func (r *testCustomerSSNRepository) deleteSSN(ownerID string, ownerType client.OwnerType) error {
+	r.ssn = nil
+	return r.err
+}
+
 func (r *testCustomerSSNRepository) getSSN(ownerID string, ownerType client.OwnerType) (*SSN, error) {
 	if r.ssn != nil {
 		return r.ssn, nil
@@ -61,7 +66,7 @@ func TestCustomerSSNStorage(t *testing.T) {
 	if ssn.ownerID != customerID {
 		t.Errorf("ssn.ownerID=%s", ssn.ownerID)
 	}
-	if ssn.masked != "1#######9" {
+	if ssn.masked != "#####6789" {
 		t.Errorf("ssn.masked=%s", ssn.masked)
 	}
 
@@ -72,6 +77,30 @@ func TestCustomerSSNStorage(t *testing.T) {
 	if decrypted != "123456789" {
 		t.Errorf("decrypted SSN=%s", decrypted)
 	}
+
+	raw, err := storage.decryptRaw(ssn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw != "123456789" {
+		t.Errorf("raw SSN=%s", raw)
+	}
+}
+
+func TestCustomerSSNStorage__decryptPlaintext(t *testing.T) {
+	storage := testCustomerSSNStorage(t)
+
+	if _, err := storage.decryptRaw(nil); err == nil {
+		t.Error("expected error")
+	}
+
+	// rows stored before encryption hold the raw SSN
+	for _, v := range []string{"123456789", "123-45-6789", base64.StdEncoding.EncodeToString([]byte("123456789"))} {
+		ssn := &SSN{ownerID: base.ID(), ownerType: client.OWNERTYPE_CUSTOMER, encrypted: v, masked: "1#######9"}
+		if raw, err := storage.decryptRaw(ssn); err == nil {
+			t.Errorf("expected error for %q, got %q", v, raw)
+		}
+	}
 }
 
 func TestCustomerSSNRepository(t *testing.T) {
@@ -117,3 +146,46 @@ func TestCustomerSSNRepository(t *testing.T) {
 	defer mysqlDB.Close()
 	check(t, &sqlSSNRepository{mysqlDB.DB, log.NewNopLogger()})
 }
+
+func TestCustomerSSN__validateSSN(t *testing.T) {
+	valid := []string{"123456789", "123-45-6789", " 123.45.6789 ", "665-01-0001"}
+	for i := range valid {
+		if err := validateSSN(valid[i]); err != nil {
+			t.Errorf("%s: unexpected error: %v", valid[i], err)
+		}
+	}
+
+	cases := map[string]error{
+		"":            errSSNFormat,
+		"12345678":    errSSNFormat,
+		"1234567890":  errSSNFormat,
+		"12345678a":   errSSNFormat,
+		"000-12-3456": errSSNArea,
+		"666-12-3456": errSSNArea,
+		"900-12-3456": errSSNArea,
+		"987654321":   errSSNArea,
+		"123-00-4567": errSSNGroup,
+		"123-45-0000": errSSNSerial,
+		"１２３４５６７８９":   errSSNFormat, // non-ASCII digits
+	}
+	for ssn, want := range cases {
+		if err := validateSSN(ssn); err != want {
+			t.Errorf("%q: got %v, expected %v", ssn, err, want)
+		}
+	}
+}
+
+func TestCustomerSSN__LastFour(t *testing.T) {
+	if v := LastFour("123-45-6789"); v != "6789" {
+		t.Errorf("got %q", v)
+	}
+	if v := LastFour("123"); v != "" {
+		t.Errorf("got %q", v)
+	}
+	if v := maskSSN("123-45-6789"); v != "#####6789" {
+		t.Errorf("got %q", v)
+	}
+	if v := maskSSN("12"); v != "##" {
+		t.Errorf("got %q", v)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+type updateCustomerSSNRequest struct {
+	SSN string `json:"SSN"`
+}
+
+// updateCustomerSSN sets or replaces the SSN of a Customer. A Customer only has one SSN, so it's
+// updated in place. Changing identity data screens the Customer against OFAC again.
+//
+// The SSN is never logged, the audit log only records the route and any status change.
+func updateCustomerSSN(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage, ofac *OFACSearcher, notifier webhooks.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		var req updateCustomerSSNRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, route.Validation(fmt.Errorf("reading SSN: %v", err)))
+			return
+		}
+		if err := validateSSN(req.SSN); err != nil {
+			route.Problem(w, route.Validation(fmt.Errorf("invalid customer SSN: %v", err)))
+			return
+		}
+
+		cust, err := repo.GetCustomer(r.Context(), customerID, organization)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if cust == nil {
+			route.NotFound(w, r)
+			return
+		}
+
+		ssn, err := customerSSNStorage.encryptRaw(customerID, client.OWNERTYPE_CUSTOMER, req.SSN)
+		req.SSN = ""
+		if err != nil {
+			logger.LogErrorf("problem encrypting SSN for customer=%s: %v", customerID, err)
+			route.Problem(w, fmt.Errorf("encrypting customer's SSN: %v", err))
+			return
+		}
+		if err := customerSSNStorage.repo.saveSSN(ssn); err != nil {
+			logger.LogErrorf("problem saving SSN for customer=%s: %v", customerID, err)
+			route.Problem(w, fmt.Errorf("saving customer's SSN: %v", err))
+			return
+		}
+		logger.Logf("updated SSN of customer=%s", customerID)
+
+		if err := rejectDeniedSSN(r.Context(), logger, repo, customerID, organization, ssn); err != nil {
+			logger.LogErrorf("problem checking SSN denylist for customer=%s: %v", customerID, err)
+		}
+
+		// The SSN is saved by now, so a failed search is left for the periodic rescreen to retry
+		requestID := route.GetRequestID(r)
+		if _, _, err := refreshCustomerOFACSearch(r.Context(), logger, repo, ofac, cust, organization, requestID, route.GetActor(r), true); err != nil {
+			logger.LogErrorf("problem with OFAC search after SSN update for customer=%s: %v", customerID, err)
+		}
+
+		if after, err := repo.GetCustomer(r.Context(), customerID, organization); err == nil && after != nil && after.Status != cust.Status {
+			notify(notifier, webhooks.CustomerStatusUpdated, customerID, organization, after.Status)
+		}
+		respondWithCustomer(r.Context(), logger, w, customerID, organization, requestID, repo)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/secrets"
+	"github.com/moov-io/customers/pkg/watchman"
+	watchmanClient "github.com/moov-io/watchman/client"
+)
+
+func TestCustomers__updateCustomerSSN(t *testing.T) {
+	scope := Setup(t)
+	organization := "organization"
+	cust := scope.CreateCustomer(context.Background(), "John", "Doe", organization, "john@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+
+	storage := NewSSNStorage(secrets.TestStringKeeper(t), NewCustomerSSNRepository(log.NewNopLogger(), scope.customerRepo.db), "salt")
+	ofacClient := watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{EntityID: "142", SdnName: "JOHN DOE", Match: 0.50}, nil)
+	ofac := createTestOFACSearcher(scope.customerRepo, ofacClient)
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, scope.customerRepo, storage, ofac, nil, nil)
+
+	update := func(customerID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/customers/"+customerID+"/ssn", strings.NewReader(body))
+		req.Header.Set("X-Organization", organization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := update(cust.CustomerID, `{"SSN": "123-45-6789"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NotContains(t, w.Body.String(), "6789")
+
+	ssn, err := storage.repo.getSSN(cust.CustomerID, client.OWNERTYPE_CUSTOMER)
+	require.NoError(t, err)
+	require.Equal(t, "#####6789", ssn.masked)
+	raw, err := storage.decryptRaw(ssn)
+	require.NoError(t, err)
+	require.Equal(t, "123456789", raw)
+
+	search, err := scope.customerRepo.getLatestCustomerOFACSearch(context.Background(), cust.CustomerID, organization)
+	require.NoError(t, err)
+	require.Equal(t, "142", search.EntityID)
+
+	// invalid SSNs aren't echoed back
+	w = update(cust.CustomerID, `{"SSN": "987654321"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	require.NotContains(t, w.Body.String(), "987654321")
+
+	// the SSN is replaced and the customer screened again
+	ofac.watchmanClient = watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{EntityID: "143", SdnName: "JOHN DOE", Match: 1.0}, nil)
+	w = update(cust.CustomerID, `{"SSN": "223456780"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	ssn, err = storage.repo.getSSN(cust.CustomerID, client.OWNERTYPE_CUSTOMER)
+	require.NoError(t, err)
+	require.Equal(t, "#####6780", ssn.masked)
+
+	var n int
+	require.NoError(t, scope.customerRepo.db.QueryRow(`select count(*) from ssn where owner_id = ?;`, cust.CustomerID).Scan(&n))
+	require.Equal(t, 1, n)
+
+	found, err := scope.customerRepo.GetCustomer(context.Background(), cust.CustomerID, organization)
+	require.NoError(t, err)
+	require.Equal(t, client.CUSTOMERSTATUS_REJECTED, found.Status)
+
+	w = update(cust.CustomerID, `{"SSN": ""}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	w = update("missing", `{"SSN": "123456789"}`)
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+}
@@ -0,0 +1,291 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	hashlru "github.com/hashicorp/golang-lru"
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/model"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+const (
+	// defaultStatsDays is how many days, ending today, Customers onboarded are counted over by default
+	defaultStatsDays = 30
+
+	// maxStatsDays limits how many days are counted in one request
+	maxStatsDays = 366
+)
+
+// customerStatsCacheDuration is how long the stats of an organization and range are reused before they're
+// counted again. Stats are counted on every request when it's zero.
+var customerStatsCacheDuration = func() time.Duration {
+	if v := os.Getenv("CUSTOMER_STATS_CACHE_DURATION"); v != "" {
+		if dur, err := time.ParseDuration(v); err == nil && dur >= 0 {
+			return dur
+		}
+	}
+	return 30 * time.Second
+}()
+
+// CustomerStats summarizes the Customers of an organization
+type CustomerStats struct {
+	// Statuses counts Customers by their current status
+	Statuses map[client.CustomerStatus]int64 `json:"statuses"`
+
+	// Onboarded counts Customers created on each day (in UTC) of the range, including days without any
+	Onboarded []DailyCount `json:"onboarded"`
+
+	// OFAC counts Customers by the result of their latest OFAC search
+	OFAC OFACStats `json:"ofac"`
+
+	GeneratedAt time.Time `json:"generatedAt"`
+}
+
+type DailyCount struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+type OFACStats struct {
+	Blocked        int64 `json:"blocked"`
+	ReviewRequired int64 `json:"reviewRequired"`
+}
+
+type statsParams struct {
+	organization string
+
+	// from and to are the first and last days (in UTC) Customers onboarded are counted over
+	from, to time.Time
+}
+
+func (p statsParams) key() string {
+	return fmt.Sprintf("%s/%s/%s", p.organization, p.from.Format(model.YYYYMMDD_Format), p.to.Format(model.YYYYMMDD_Format))
+}
+
+// statsCache keeps recently counted stats to spare the database from dashboards refreshing
+type statsCache struct {
+	repo  CustomerRepository
+	ttl   time.Duration
+	cache *hashlru.Cache
+	mu    sync.Mutex // requests wait while stats are counted so a burst of them only counts once
+
+	now func() time.Time
+}
+
+type cachedStats struct {
+	stats   *CustomerStats
+	expires time.Time
+}
+
+func newStatsCache(repo CustomerRepository, ttl time.Duration) *statsCache {
+	c := &statsCache{repo: repo, ttl: ttl, now: time.Now}
+	if ttl > 0 {
+		c.cache, _ = hashlru.New(256)
+	}
+	return c
+}
+
+func (c *statsCache) get(ctx context.Context, params statsParams) (*CustomerStats, error) {
+	if c.cache == nil {
+		return c.count(ctx, params)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := params.key()
+	if v, ok := c.cache.Get(key); ok {
+		if cached, _ := v.(cachedStats); c.now().Before(cached.expires) {
+			return cached.stats, nil
+		}
+		c.cache.Remove(key)
+	}
+	stats, err := c.count(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Add(key, cachedStats{stats: stats, expires: c.now().Add(c.ttl)})
+	return stats, nil
+}
+
+func (c *statsCache) count(ctx context.Context, params statsParams) (*CustomerStats, error) {
+	stats := &CustomerStats{GeneratedAt: c.now().UTC()}
+
+	var err error
+	if stats.Statuses, err = c.repo.countCustomersByStatus(ctx, params.organization); err != nil {
+		return nil, err
+	}
+
+	onboarded, err := c.repo.countCustomersCreatedByDay(ctx, params.organization, params.from, params.to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	for day := params.from; !day.After(params.to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(model.YYYYMMDD_Format)
+		stats.Onboarded = append(stats.Onboarded, DailyCount{Date: date, Count: onboarded[date]})
+	}
+
+	if stats.OFAC.Blocked, err = c.repo.countOFACMatches(ctx, OFACMatchParams{Organization: params.organization, Results: []string{ofacResultBlocked}}); err != nil {
+		return nil, err
+	}
+	if stats.OFAC.ReviewRequired, err = c.repo.countOFACMatches(ctx, OFACMatchParams{Organization: params.organization, Results: []string{ofacResultReview}}); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func getCustomerStats(logger log.Logger, stats *statsCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+		params, err := readStatsParams(r, stats.now())
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		params.organization = organization
+
+		found, err := stats.get(r.Context(), params)
+		if err != nil {
+			logger.LogErrorf("problem counting customer stats: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(found)
+	}
+}
+
+// readStatsParams reads the optional from and to days. The range ends today and covers defaultStatsDays
+// when they're missing.
+func readStatsParams(r *http.Request, now time.Time) (statsParams, error) {
+	var params statsParams
+	q := r.URL.Query()
+
+	readDay := func(key string) (time.Time, error) {
+		v := strings.TrimSpace(q.Get(key))
+		if v == "" {
+			return time.Time{}, nil
+		}
+		day, err := time.Parse(model.YYYYMMDD_Format, v)
+		if err != nil {
+			return day, route.Validation(fmt.Errorf("%s must be a YYYY-MM-DD date", key))
+		}
+		return day, nil
+	}
+	var err error
+	if params.from, err = readDay("from"); err != nil {
+		return params, err
+	}
+	if params.to, err = readDay("to"); err != nil {
+		return params, err
+	}
+
+	if params.to.IsZero() {
+		params.to = now.UTC().Truncate(24 * time.Hour)
+		if !params.from.IsZero() && params.from.After(params.to) {
+			params.to = params.from
+		}
+	}
+	if params.from.IsZero() {
+		params.from = params.to.AddDate(0, 0, -(defaultStatsDays - 1))
+	}
+	if params.from.After(params.to) {
+		return params, route.Validation(errors.New("from is after to"))
+	}
+	if params.to.Sub(params.from) >= maxStatsDays*24*time.Hour {
+		return params, route.Validation(fmt.Errorf("stats are limited to %d days", maxStatsDays))
+	}
+	return params, nil
+}
+
+// countCustomersByStatus returns how many of the organization's Customers have each status
+func (r *sqlCustomerRepository) countCustomersByStatus(ctx context.Context, organization string) (map[client.CustomerStatus]int64, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select status, count(*) from customers where organization = ? and deleted_at is null group by status;`
+	rows, err := r.db.QueryContext(ctx, query, organization)
+	if err != nil {
+		return nil, fmt.Errorf("countCustomersByStatus: query: %v", err)
+	}
+	defer rows.Close()
+
+	out := make(map[client.CustomerStatus]int64)
+	for rows.Next() {
+		var status *string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("countCustomersByStatus: scan: %v", err)
+		}
+		// older records may store statuses differently, like "none" for Unknown
+		var v string
+		if status != nil {
+			v = *status
+		}
+		if s, err := readCustomerStatus(v); err == nil {
+			out[s] += n
+		} else {
+			out[client.CustomerStatus(v)] += n
+		}
+	}
+	return out, rows.Err()
+}
+
+// countCustomersCreatedByDay returns how many of the organization's Customers were created on each day,
+// as YYYY-MM-DD in UTC, from start until before end. Days without any are missing.
+func (r *sqlCustomerRepository) countCustomersCreatedByDay(ctx context.Context, organization string, start, end time.Time) (map[string]int64, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select date(created_at) as day, count(*) from customers
+where organization = ? and deleted_at is null and created_at >= ? and created_at < ?
+group by day;`
+	rows, err := r.db.QueryContext(ctx, query, organization, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("countCustomersCreatedByDay: query: %v", err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]int64)
+	for rows.Next() {
+		var day interface{}
+		var n int64
+		if err := rows.Scan(&day, &n); err != nil {
+			return nil, fmt.Errorf("countCustomersCreatedByDay: scan: %v", err)
+		}
+		// SQLite returns the day as text while MySQL returns a DATE
+		switch v := day.(type) {
+		case time.Time:
+			out[v.Format(model.YYYYMMDD_Format)] += n
+		case []byte:
+			out[string(v)] += n
+		case string:
+			out[v] += n
+		}
+	}
+	return out, rows.Err()
+}
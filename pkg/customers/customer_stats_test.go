@@ -0,0 +1,123 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestCustomerStats__readStatsParams(t *testing.T) {
+	now := time.Date(2020, time.June, 10, 15, 0, 0, 0, time.UTC)
+	read := func(query string) (statsParams, error) {
+		return readStatsParams(httptest.NewRequest("GET", "/customers/stats?"+query, nil), now)
+	}
+
+	params, err := read("")
+	require.NoError(t, err)
+	require.Equal(t, "2020-05-12", params.from.Format("2006-01-02"))
+	require.Equal(t, "2020-06-10", params.to.Format("2006-01-02"))
+
+	params, err = read("from=2020-06-01&to=2020-06-03")
+	require.NoError(t, err)
+	require.Equal(t, "/2020-06-01/2020-06-03", params.key())
+
+	params, err = read("from=2020-07-01")
+	require.NoError(t, err)
+	require.True(t, params.from.Equal(params.to))
+
+	_, err = read("from=06/01/2020")
+	require.Error(t, err)
+	_, err = read("from=2020-06-03&to=2020-06-01")
+	require.Error(t, err)
+	_, err = read("from=2019-01-01&to=2020-06-01")
+	require.Error(t, err)
+}
+
+func TestCustomerStats(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	create := func(organization string, status client.CustomerStatus, createdAt time.Time) string {
+		cust, _, _ := (customerRequest{FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}).asCustomer(testCustomerSSNStorage(t))
+		require.NoError(t, repo.CreateCustomer(context.Background(), cust, organization))
+		_, err := repo.db.Exec(`update customers set status = ?, created_at = ? where customer_id = ?;`, status, createdAt, cust.CustomerID)
+		require.NoError(t, err)
+		return cust.CustomerID
+	}
+	day := func(d int, hour int) time.Time {
+		return time.Date(2020, time.June, d, hour, 30, 0, 0, time.UTC)
+	}
+
+	blocked := create("test", client.CUSTOMERSTATUS_REJECTED, day(1, 0))
+	review := create("test", client.CUSTOMERSTATUS_UNKNOWN, day(1, 23))
+	create("test", client.CUSTOMERSTATUS_VERIFIED, day(3, 12))
+	create("test", client.CUSTOMERSTATUS_VERIFIED, day(5, 12)) // after the range
+	create("other", client.CUSTOMERSTATUS_VERIFIED, day(2, 12))
+
+	require.NoError(t, repo.saveCustomerOFACSearch(context.Background(), blocked, client.OfacSearch{EntityID: "1", Match: 0.99, Blocked: true}))
+	require.NoError(t, repo.saveCustomerOFACSearch(context.Background(), review, client.OfacSearch{EntityID: "2", Match: 0.91, ReviewRequired: true}))
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
+
+	call := func() *CustomerStats {
+		req := httptest.NewRequest("GET", "/customers/stats?from=2020-06-01&to=2020-06-03", nil)
+		req.Header.Set("X-Organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var stats CustomerStats
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+		return &stats
+	}
+
+	stats := call()
+	require.Equal(t, map[client.CustomerStatus]int64{
+		client.CUSTOMERSTATUS_REJECTED: 1,
+		client.CUSTOMERSTATUS_UNKNOWN:  1,
+		client.CUSTOMERSTATUS_VERIFIED: 2,
+	}, stats.Statuses)
+	require.Equal(t, []DailyCount{
+		{Date: "2020-06-01", Count: 2},
+		{Date: "2020-06-02", Count: 0},
+		{Date: "2020-06-03", Count: 1},
+	}, stats.Onboarded)
+	require.Equal(t, OFACStats{Blocked: 1, ReviewRequired: 1}, stats.OFAC)
+
+	// stats are reused until they expire
+	create("test", client.CUSTOMERSTATUS_UNKNOWN, day(2, 12))
+	require.Equal(t, stats.GeneratedAt, call().GeneratedAt)
+
+	cache := newStatsCache(repo, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	params := statsParams{organization: "test", from: day(2, 0).Truncate(24 * time.Hour), to: day(2, 0).Truncate(24 * time.Hour)}
+
+	found, err := cache.get(context.Background(), params)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), found.Onboarded[0].Count)
+
+	create("test", client.CUSTOMERSTATUS_UNKNOWN, day(2, 13))
+	found, err = cache.get(context.Background(), params)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), found.Onboarded[0].Count)
+
+	now = now.Add(2 * time.Minute)
+	found, err = cache.get(context.Background(), params)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), found.Onboarded[0].Count)
+}
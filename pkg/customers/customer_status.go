@@ -0,0 +1,151 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+var (
+	allCustomerStatuses = []client.CustomerStatus{
+		client.CUSTOMERSTATUS_DECEASED,
+		client.CUSTOMERSTATUS_REJECTED,
+		client.CUSTOMERSTATUS_UNKNOWN,
+		client.CUSTOMERSTATUS_RECEIVE_ONLY,
+		client.CUSTOMERSTATUS_VERIFIED,
+		client.CUSTOMERSTATUS_FROZEN,
+	}
+
+	// customerStatusTransitions holds the statuses a Customer can move to from a given status.
+	// Deceased is final and a Rejected Customer needs to be reviewed again (Unknown) before
+	// being approved.
+	customerStatusTransitions = map[client.CustomerStatus][]client.CustomerStatus{
+		client.CUSTOMERSTATUS_DECEASED: nil,
+		client.CUSTOMERSTATUS_REJECTED: {
+			client.CUSTOMERSTATUS_UNKNOWN,
+			client.CUSTOMERSTATUS_DECEASED,
+		},
+		client.CUSTOMERSTATUS_UNKNOWN: {
+			client.CUSTOMERSTATUS_RECEIVE_ONLY,
+			client.CUSTOMERSTATUS_VERIFIED,
+			client.CUSTOMERSTATUS_REJECTED,
+			client.CUSTOMERSTATUS_FROZEN,
+			client.CUSTOMERSTATUS_DECEASED,
+		},
+		client.CUSTOMERSTATUS_RECEIVE_ONLY: {
+			client.CUSTOMERSTATUS_UNKNOWN,
+			client.CUSTOMERSTATUS_VERIFIED,
+			client.CUSTOMERSTATUS_REJECTED,
+			client.CUSTOMERSTATUS_FROZEN,
+			client.CUSTOMERSTATUS_DECEASED,
+		},
+		client.CUSTOMERSTATUS_VERIFIED: {
+			client.CUSTOMERSTATUS_RECEIVE_ONLY,
+			client.CUSTOMERSTATUS_REJECTED,
+			client.CUSTOMERSTATUS_FROZEN,
+			client.CUSTOMERSTATUS_DECEASED,
+		},
+		client.CUSTOMERSTATUS_FROZEN: {
+			client.CUSTOMERSTATUS_UNKNOWN,
+			client.CUSTOMERSTATUS_RECEIVE_ONLY,
+			client.CUSTOMERSTATUS_VERIFIED,
+			client.CUSTOMERSTATUS_REJECTED,
+			client.CUSTOMERSTATUS_DECEASED,
+		},
+	}
+
+	errMissingOFACSearch = errors.New("customer has no OFAC search")
+	errMissingSSN        = errors.New("customer has no SSN")
+	errMissingEIN        = errors.New("customer has no EIN")
+)
+
+// StatusUpdate is a recorded change of a Customer's status
+type StatusUpdate struct {
+	Status    client.CustomerStatus `json:"status"`
+	Comment   string                `json:"comment,omitempty"`
+	Actor     string                `json:"actor,omitempty"`
+	ChangedAt time.Time             `json:"changedAt"`
+}
+
+// readCustomerStatus returns the CustomerStatus matching v, ignoring case.
+// Older records may store "none" or an empty status, those are read as Unknown.
+func readCustomerStatus(v string) (client.CustomerStatus, error) {
+	v = strings.TrimSpace(v)
+	if v == "" || strings.EqualFold(v, "none") {
+		return client.CUSTOMERSTATUS_UNKNOWN, nil
+	}
+	for i := range allCustomerStatuses {
+		if strings.EqualFold(v, string(allCustomerStatuses[i])) {
+			return allCustomerStatuses[i], nil
+		}
+	}
+	return "", fmt.Errorf("unknown customer status: %s", v)
+}
+
+// TransitionAllowed returns an error if a Customer is not allowed to move from one status to another.
+func TransitionAllowed(from, to client.CustomerStatus) error {
+	current, err := readCustomerStatus(string(from))
+	if err != nil {
+		return err
+	}
+	future, err := readCustomerStatus(string(to))
+	if err != nil {
+		return err
+	}
+	if current == future {
+		return fmt.Errorf("customer is already %s", current)
+	}
+	for _, allowed := range customerStatusTransitions[current] {
+		if future == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid customer status transition from %s to %s", current, future)
+}
+
+// checkVerificationRequirements returns an error if the Customer is missing what's required
+// to become Verified: a passing OFAC search and an SSN (or EIN for businesses).
+func checkVerificationRequirements(ctx context.Context, cust *client.Customer, organization string, repo CustomerRepository, ssnRepo SSNRepository) error {
+	search, err := repo.getLatestCustomerOFACSearch(ctx, cust.CustomerID, organization)
+	if err != nil {
+		return fmt.Errorf("checkVerificationRequirements: %v", err)
+	}
+	if search == nil {
+		return errMissingOFACSearch
+	}
+	if search.Blocked {
+		return fmt.Errorf("customer is blocked by OFAC search (entityID=%s)", search.EntityID)
+	}
+
+	missing, err := missingTaxForms(ctx, repo, cust)
+	if err != nil {
+		return fmt.Errorf("checkVerificationRequirements: %v", err)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("customer has no tax form on file, expected one of %s", strings.Join(missing, ", "))
+	}
+
+	if cust.Type == client.CUSTOMERTYPE_BUSINESS {
+		if cust.EIN == "" {
+			return errMissingEIN
+		}
+		return nil
+	}
+
+	ssn, err := ssnRepo.getSSN(cust.CustomerID, client.OWNERTYPE_CUSTOMER)
+	if err != nil {
+		return fmt.Errorf("checkVerificationRequirements: %v", err)
+	}
+	if ssn == nil {
+		return errMissingSSN
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+// maxBulkStatusUpdates is how many Customers one bulk status update can change
+const maxBulkStatusUpdates = 1000
+
+type bulkStatusRequest struct {
+	CustomerIDs []string              `json:"customerIDs"`
+	Status      client.CustomerStatus `json:"status"`
+	Comment     string                `json:"comment"`
+}
+
+type bulkStatusReport struct {
+	Status  client.CustomerStatus `json:"status"`
+	Updated int                   `json:"updated"`
+	Failed  int                   `json:"failed"`
+	Results []bulkStatusResult    `json:"results"`
+}
+
+type bulkStatusResult struct {
+	CustomerID string `json:"customerID"`
+	Error      string `json:"error,omitempty"`
+}
+
+// customerIDs returns the requested IDs in order without blanks or duplicates
+func (req bulkStatusRequest) customerIDs() []string {
+	seen := make(map[string]bool)
+	var out []string
+	for i := range req.CustomerIDs {
+		id := strings.TrimSpace(req.CustomerIDs[i])
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
+
+// bulkUpdateCustomerStatus moves each Customer to the requested status. Customers are updated one at a
+// time with the same checks as a single status update, so one which can't transition is reported without
+// stopping the others. Each change is recorded in the Customer's status history.
+func bulkUpdateCustomerStatus(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage, notifier webhooks.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if !route.RequireScope(w, r, route.ScopeAdmin) {
+			return
+		}
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		var req bulkStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, route.Validation(fmt.Errorf("reading bulk status update: %v", err)))
+			return
+		}
+		status, err := readCustomerStatus(string(req.Status))
+		if err != nil || req.Status == "" {
+			route.Problem(w, route.Validation(fmt.Errorf("invalid customer status: %q", req.Status)))
+			return
+		}
+		customerIDs := req.customerIDs()
+		if len(customerIDs) == 0 {
+			route.Problem(w, route.Validation(errors.New("no customerIDs to update")))
+			return
+		}
+		if len(customerIDs) > maxBulkStatusUpdates {
+			route.Problem(w, route.Validation(fmt.Errorf("bulk status updates are limited to %d customers", maxBulkStatusUpdates)))
+			return
+		}
+
+		actor := route.GetActor(r)
+		update := client.UpdateCustomerStatus{Status: status, Comment: req.Comment}
+		report := &bulkStatusReport{Status: status}
+		for _, customerID := range customerIDs {
+			result := bulkStatusResult{CustomerID: customerID}
+			if _, err := changeCustomerStatus(r.Context(), repo, customerSSNStorage.repo, customerID, organization, update, actor); err != nil {
+				result.Error = err.Error()
+				report.Failed++
+			} else {
+				report.Updated++
+				notify(notifier, webhooks.CustomerStatusUpdated, customerID, organization, status)
+				rollupRepresentatives(r.Context(), logger, repo, notifier, customerID, organization)
+			}
+			report.Results = append(report.Results, result)
+		}
+		logger.Logf("bulk status update to %s changed %d customers, %d failed", status, report.Updated, report.Failed)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestCustomers__bulkUpdateCustomerStatus(t *testing.T) {
+	scope := Setup(t)
+	organization := "organization"
+
+	jane := scope.CreateCustomer(context.Background(), "Jane", "Doe", organization, "jane@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	john := scope.CreateCustomer(context.Background(), "John", "Doe", organization, "john@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	deceased := scope.CreateCustomer(context.Background(), "Jim", "Doe", organization, "jim@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	require.NoError(t, scope.customerRepo.updateCustomerStatus(context.Background(), deceased.CustomerID, client.CUSTOMERSTATUS_DECEASED, "", ""))
+	other := scope.CreateCustomer(context.Background(), "Jen", "Doe", "other", "jen@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, scope.customerRepo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/customers/status", strings.NewReader(body))
+		req.Header.Set("X-Organization", organization)
+		req.Header.Set("X-User-ID", "compliance")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	ids := `["` + jane.CustomerID + `", "` + john.CustomerID + `", "` + jane.CustomerID + `", "` + deceased.CustomerID + `", "` + other.CustomerID + `", "missing"]`
+	w := send(`{"customerIDs": ` + ids + `, "status": "frozen", "comment": "sanctions list"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var report bulkStatusReport
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	require.Equal(t, client.CUSTOMERSTATUS_FROZEN, report.Status)
+	require.Equal(t, 2, report.Updated)
+	require.Equal(t, 3, report.Failed)
+
+	// duplicates are only updated once and failures don't stop the others
+	require.Len(t, report.Results, 5)
+	require.Equal(t, bulkStatusResult{CustomerID: jane.CustomerID}, report.Results[0])
+	require.Equal(t, bulkStatusResult{CustomerID: john.CustomerID}, report.Results[1])
+	require.Contains(t, report.Results[2].Error, "invalid customer status transition from Deceased to Frozen")
+	require.Contains(t, report.Results[3].Error, "not found")
+	require.Contains(t, report.Results[4].Error, "not found")
+
+	history, err := scope.customerRepo.getStatusHistory(context.Background(), jane.CustomerID)
+	require.NoError(t, err)
+	latest := history[len(history)-1]
+	require.Equal(t, client.CUSTOMERSTATUS_FROZEN, latest.Status)
+	require.Equal(t, "sanctions list", latest.Comment)
+	require.Equal(t, "compliance", latest.Actor)
+
+	found, err := scope.customerRepo.GetCustomer(context.Background(), other.CustomerID, "other")
+	require.NoError(t, err)
+	require.Equal(t, client.CUSTOMERSTATUS_UNKNOWN, found.Status)
+
+	// each transition gets the same checks as a single update
+	w = send(`{"customerIDs": ["` + jane.CustomerID + `"], "status": "verified"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	require.Equal(t, 1, report.Failed)
+	require.Contains(t, report.Results[0].Error, "unable to verify customer")
+
+	w = send(`{"customerIDs": ["` + jane.CustomerID + `"], "status": "other"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	w = send(`{"customerIDs": [" "], "status": "frozen"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	tooMany := make([]string, maxBulkStatusUpdates+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("%q", fmt.Sprintf("customer-%d", i))
+	}
+	w = send(`{"customerIDs": [` + strings.Join(tooMany, ", ") + `], "status": "frozen"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
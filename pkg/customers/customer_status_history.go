@@ -0,0 +1,174 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/model"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// StatusHistoryParams filters and pages through a Customer's status changes
+type StatusHistoryParams struct {
+	// From and To limit changes to those made at or after From and before To, either can be zero
+	From time.Time
+	To   time.Time
+
+	Skip  int
+	Count int
+}
+
+// readStatusHistoryParams reads the optional from and to query parameters, which are either RFC 3339
+// timestamps or YYYY-MM-DD dates, along with skip and count. A date for to includes the entire day.
+func readStatusHistoryParams(r *http.Request) (StatusHistoryParams, error) {
+	var params StatusHistoryParams
+	var err error
+
+	if params.From, err = readHistoryTime(r.URL.Query().Get("from"), false); err != nil {
+		return params, fmt.Errorf("invalid from: %v", err)
+	}
+	if params.To, err = readHistoryTime(r.URL.Query().Get("to"), true); err != nil {
+		return params, fmt.Errorf("invalid to: %v", err)
+	}
+	if !params.From.IsZero() && !params.To.IsZero() && params.To.Before(params.From) {
+		return params, fmt.Errorf("to (%v) is before from (%v)", params.To, params.From)
+	}
+
+	params.Skip, params.Count, _, err = moovhttp.GetSkipAndCount(r)
+	return params, err
+}
+
+func readHistoryTime(v string, endOfDay bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(model.YYYYMMDD_Format, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp or YYYY-MM-DD date", v)
+	}
+	if endOfDay {
+		t = t.Add(24 * time.Hour)
+	}
+	return t, nil
+}
+
+// getCustomerStatusHistory returns the Customer's status changes oldest first, so compliance reviews
+// can see how they reached their current status.
+func getCustomerStatusHistory(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		params, err := readStatusHistoryParams(r)
+		if err != nil {
+			route.Problem(w, route.Validation(err))
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+		history, err := repo.listStatusHistory(r.Context(), customerID, params)
+		if err != nil {
+			logger.LogErrorf("problem reading status history: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		total, err := repo.countStatusHistory(r.Context(), customerID, params)
+		if err != nil {
+			logger.LogErrorf("problem counting status history: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		if history == nil {
+			history = []StatusUpdate{}
+		}
+
+		w.Header().Set(totalCountHeaderKey, fmt.Sprintf("%d", total))
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(history)
+	}
+}
+
+func statusHistoryFilters(customerID string, params StatusHistoryParams) (string, []interface{}) {
+	where := " where customer_id = ?"
+	args := []interface{}{customerID}
+	if !params.From.IsZero() {
+		where += " and changed_at >= ?"
+		args = append(args, params.From)
+	}
+	if !params.To.IsZero() {
+		where += " and changed_at < ?"
+		args = append(args, params.To)
+	}
+	return where, args
+}
+
+func (r *sqlCustomerRepository) listStatusHistory(ctx context.Context, customerID string, params StatusHistoryParams) ([]StatusUpdate, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	where, args := statusHistoryFilters(customerID, params)
+	query := `select future_status, comment, actor, changed_at from customer_status_updates` + where + " order by changed_at asc"
+	if params.Count > 0 {
+		query += " limit ? offset ?"
+		args = append(args, params.Count, params.Skip)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query+";", args...)
+	if err != nil {
+		return nil, fmt.Errorf("listStatusHistory: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []StatusUpdate
+	for rows.Next() {
+		var update StatusUpdate
+		var comment, actor *string
+		if err := rows.Scan(&update.Status, &comment, &actor, &update.ChangedAt); err != nil {
+			return nil, fmt.Errorf("listStatusHistory: scan: %v", err)
+		}
+		if comment != nil {
+			update.Comment = *comment
+		}
+		if actor != nil {
+			update.Actor = *actor
+		}
+		out = append(out, update)
+	}
+	return out, rows.Err()
+}
+
+func (r *sqlCustomerRepository) countStatusHistory(ctx context.Context, customerID string, params StatusHistoryParams) (int64, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	where, args := statusHistoryFilters(customerID, params)
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, `select count(*) from customer_status_updates`+where+";", args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("countStatusHistory: %v", err)
+	}
+	return total, nil
+}
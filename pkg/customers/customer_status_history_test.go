@@ -0,0 +1,96 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestCustomerStatusHistory(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	cust, organization := setupMockCustomer(t, repo)
+
+	// replace the history written on create with changes on known days
+	_, err := repo.db.Exec(`delete from customer_status_updates where customer_id = ?;`, cust.CustomerID)
+	require.NoError(t, err)
+	insert := func(status client.CustomerStatus, comment string, changedAt time.Time) {
+		_, err := repo.db.Exec(`insert into customer_status_updates (customer_id, future_status, comment, actor, changed_at) values (?, ?, ?, 'operator', ?);`,
+			cust.CustomerID, status, comment, changedAt)
+		require.NoError(t, err)
+	}
+	insert(client.CUSTOMERSTATUS_UNKNOWN, "created", time.Date(2020, time.June, 1, 10, 0, 0, 0, time.UTC))
+	insert(client.CUSTOMERSTATUS_RECEIVE_ONLY, "receive only", time.Date(2020, time.June, 2, 10, 0, 0, 0, time.UTC))
+	insert(client.CUSTOMERSTATUS_VERIFIED, "kyc passed", time.Date(2020, time.June, 3, 10, 0, 0, 0, time.UTC))
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
+
+	get := func(query string) ([]StatusUpdate, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest("GET", "/customers/"+cust.CustomerID+"/status-history"+query, nil)
+		req.Header.Set("X-Organization", organization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var history []StatusUpdate
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&history))
+		}
+		return history, w
+	}
+
+	history, w := get("")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "3", w.Header().Get(totalCountHeaderKey))
+	require.Len(t, history, 3)
+	require.Equal(t, client.CUSTOMERSTATUS_UNKNOWN, history[0].Status)
+	require.Equal(t, "kyc passed", history[2].Comment)
+	require.Equal(t, "operator", history[2].Actor)
+
+	// a date for to includes that day
+	history, w = get("?from=2020-06-02&to=2020-06-03")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "2", w.Header().Get(totalCountHeaderKey))
+	require.Len(t, history, 2)
+	require.Equal(t, client.CUSTOMERSTATUS_RECEIVE_ONLY, history[0].Status)
+
+	history, w = get("?from=2020-06-02T12:00:00Z")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, history, 1)
+
+	// the count header covers every match, not just the page
+	history, w = get("?skip=1&count=1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "3", w.Header().Get(totalCountHeaderKey))
+	require.Len(t, history, 1)
+	require.Equal(t, client.CUSTOMERSTATUS_RECEIVE_ONLY, history[0].Status)
+
+	history, w = get("?from=2021-01-01")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, history)
+	require.Empty(t, history)
+
+	for _, query := range []string{"?from=yesterday", "?from=2020-06-03&to=2020-06-01"} {
+		_, w = get(query)
+		require.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	req := httptest.NewRequest("GET", "/customers/"+cust.CustomerID+"/status-history", nil)
+	req.Header.Set("X-Organization", "other")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+type testNotifier struct {
+	events []webhooks.EventType
+}
+
+func (n *testNotifier) Notify(eventType webhooks.EventType, customerID, organization, status string) {
+	n.events = append(n.events, eventType)
+}
+
+func TestCustomerStatus__readCustomerStatus(t *testing.T) {
+	status, err := readCustomerStatus("none")
+	require.NoError(t, err)
+	require.Equal(t, client.CUSTOMERSTATUS_UNKNOWN, status)
+
+	status, err = readCustomerStatus("receiveonly")
+	require.NoError(t, err)
+	require.Equal(t, client.CUSTOMERSTATUS_RECEIVE_ONLY, status)
+
+	_, err = readCustomerStatus("other")
+	require.Error(t, err)
+}
+
+func TestCustomerStatus__TransitionAllowed(t *testing.T) {
+	cases := []struct {
+		from, to client.CustomerStatus
+		allowed  bool
+	}{
+		{client.CUSTOMERSTATUS_UNKNOWN, client.CUSTOMERSTATUS_VERIFIED, true},
+		{client.CUSTOMERSTATUS_UNKNOWN, client.CUSTOMERSTATUS_RECEIVE_ONLY, true},
+		{client.CUSTOMERSTATUS_VERIFIED, client.CUSTOMERSTATUS_FROZEN, true},
+		{client.CUSTOMERSTATUS_FROZEN, client.CUSTOMERSTATUS_VERIFIED, true},
+		{client.CUSTOMERSTATUS_REJECTED, client.CUSTOMERSTATUS_UNKNOWN, true},
+		{client.CUSTOMERSTATUS_VERIFIED, client.CUSTOMERSTATUS_DECEASED, true},
+		{"none", client.CUSTOMERSTATUS_RECEIVE_ONLY, true},
+
+		{client.CUSTOMERSTATUS_VERIFIED, client.CUSTOMERSTATUS_VERIFIED, false},
+		{client.CUSTOMERSTATUS_VERIFIED, client.CUSTOMERSTATUS_UNKNOWN, false},
+		{client.CUSTOMERSTATUS_REJECTED, client.CUSTOMERSTATUS_VERIFIED, false},
+		{client.CUSTOMERSTATUS_DECEASED, client.CUSTOMERSTATUS_UNKNOWN, false},
+		{client.CUSTOMERSTATUS_UNKNOWN, "other", false},
+	}
+	for _, tc := range cases {
+		err := TransitionAllowed(tc.from, tc.to)
+		if tc.allowed {
+			require.NoError(t, err, "%s -> %s", tc.from, tc.to)
+		} else {
+			require.Error(t, err, "%s -> %s", tc.from, tc.to)
+		}
+	}
+}
+
+func TestCustomers__updateCustomerStatusTransitions(t *testing.T) {
+	repo := &testCustomerRepository{
+		customer: &client.Customer{
+			CustomerID: base.ID(),
+			Type:       client.CUSTOMERTYPE_INDIVIDUAL,
+			Status:     client.CUSTOMERSTATUS_DECEASED,
+		},
+	}
+	ssnRepo := &testCustomerSSNRepository{}
+	storage := testCustomerSSNStorage(t)
+	storage.repo = ssnRepo
+
+	notifier := &testNotifier{}
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, storage, createTestOFACSearcher(repo, nil), notifier, nil)
+
+	update := func(status client.CustomerStatus) *httptest.ResponseRecorder {
+		payload, err := json.Marshal(&client.UpdateCustomerStatus{Status: status, Comment: "test"})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("PUT", "/customers/foo/status", bytes.NewReader(payload))
+		req.Header.Set("x-organization", "test")
+		req.Header.Set("x-user-id", "operator")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Deceased customers can't change
+	w := update(client.CUSTOMERSTATUS_VERIFIED)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "invalid customer status transition")
+	require.Empty(t, repo.updatedStatus)
+
+	// Verified requires an OFAC search and SSN
+	repo.customer.Status = client.CUSTOMERSTATUS_UNKNOWN
+	w = update(client.CUSTOMERSTATUS_VERIFIED)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), errMissingOFACSearch.Error())
+
+	repo.searchResult = &client.OfacSearch{EntityID: "1234", Match: 0.10}
+	w = update(client.CUSTOMERSTATUS_VERIFIED)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), errMissingSSN.Error())
+
+	ssnRepo.ssn = &SSN{ownerID: repo.customer.CustomerID, ownerType: client.OWNERTYPE_CUSTOMER}
+	w = update(client.CUSTOMERSTATUS_VERIFIED)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, client.CUSTOMERSTATUS_VERIFIED, repo.updatedStatus)
+	require.Equal(t, []webhooks.EventType{webhooks.CustomerStatusUpdated}, notifier.events)
+}
+
+func TestCustomerRepository__updateCustomerStatusActor(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	cust, _, _ := (customerRequest{FirstName: "Jane", LastName: "Doe"}).asCustomer(testCustomerSSNStorage(t))
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, "organization"))
+	require.NoError(t, repo.updateCustomerStatus(context.Background(), cust.CustomerID, client.CUSTOMERSTATUS_RECEIVE_ONLY, "test", "operator"))
+
+	var status, actor string
+	row := repo.db.QueryRow(`select future_status, actor from customer_status_updates where customer_id = ?;`, cust.CustomerID)
+	require.NoError(t, row.Scan(&status, &actor))
+	require.Equal(t, string(client.CUSTOMERSTATUS_RECEIVE_ONLY), status)
+	require.Equal(t, "operator", actor)
+}
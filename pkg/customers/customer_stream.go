@@ -0,0 +1,149 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/model"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// streamBatchSize is how many Customers are read from the database at a time while streaming
+const streamBatchSize = 100
+
+// StreamedCustomer is one line of the NDJSON export, a Customer with their masked SSN
+type StreamedCustomer struct {
+	*client.Customer
+	SSN string `json:"ssn,omitempty"`
+}
+
+// CustomerStreamer reads every Customer of an organization in batches for bulk exports
+type CustomerStreamer struct {
+	repo       CustomerRepository
+	ssnStorage *ssnStorage
+	batchSize  int
+}
+
+func NewCustomerStreamer(repo CustomerRepository, storage *ssnStorage) *CustomerStreamer {
+	return &CustomerStreamer{
+		repo:       repo,
+		ssnStorage: storage,
+		batchSize:  streamBatchSize,
+	}
+}
+
+// Each calls fn with every Customer of the organization changed at or after updatedSince, or every
+// Customer when it's zero, ordered by customerID. Only one batch is held in memory at a time.
+func (s *CustomerStreamer) Each(ctx context.Context, organization string, updatedSince time.Time, fn func(*StreamedCustomer) error) error {
+	params := SearchParams{
+		Organization: organization,
+		UpdatedSince: updatedSince,
+		Count:        int64(s.batchSize),
+		byCustomerID: true,
+	}
+	for {
+		custs, err := s.repo.searchCustomers(ctx, params)
+		if err != nil {
+			return fmt.Errorf("streaming customers after %q: %v", params.afterCustomerID, err)
+		}
+		for _, cust := range custs {
+			streamed := &StreamedCustomer{Customer: cust}
+			if s.ssnStorage != nil {
+				ssn, err := s.ssnStorage.repo.getSSN(cust.CustomerID, client.OWNERTYPE_CUSTOMER)
+				if err != nil {
+					return fmt.Errorf("streaming customers: reading SSN of customer=%s: %v", cust.CustomerID, err)
+				}
+				if ssn != nil {
+					streamed.SSN = ssn.masked
+				}
+			}
+			if err := fn(streamed); err != nil {
+				return err
+			}
+		}
+		if len(custs) < s.batchSize {
+			return nil
+		}
+		params.afterCustomerID = custs[len(custs)-1].CustomerID
+	}
+}
+
+// AddCustomerStreamAdminRoutes registers the NDJSON export of every Customer on the admin server
+func AddCustomerStreamAdminRoutes(logger log.Logger, svc *admin.Server, streamer *CustomerStreamer) {
+	logger = logger.Set("package", log.String("customers"))
+
+	svc.AddHandler("/customers/export", streamCustomers(logger, streamer))
+}
+
+// streamCustomers writes one JSON object per line for each of the organization's Customers. Customers
+// changed since a previous sync are returned with ?updatedSince, an RFC 3339 timestamp or YYYY-MM-DD date.
+func streamCustomers(logger log.Logger, streamer *CustomerStreamer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if r.Method != "GET" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		var updatedSince time.Time
+		if v := r.URL.Query().Get("updatedSince"); v != "" {
+			var err error
+			if updatedSince, err = time.Parse(time.RFC3339, v); err != nil {
+				if updatedSince, err = time.Parse(model.YYYYMMDD_Format, v); err != nil {
+					route.Problem(w, route.Validation(fmt.Errorf("updatedSince %q is not an RFC 3339 timestamp or YYYY-MM-DD date", v)))
+					return
+				}
+			}
+		}
+
+		flusher, _ := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		var written int
+		err := streamer.Each(r.Context(), organization, updatedSince, func(cust *StreamedCustomer) error {
+			if written == 0 {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				w.WriteHeader(http.StatusOK)
+			}
+			if err := enc.Encode(cust); err != nil {
+				return err
+			}
+			if written++; written%streamBatchSize == 0 && flusher != nil {
+				flusher.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			if written > 0 {
+				// the status has already been written, so the export is cut short
+				logger.LogErrorf("problem streaming customers after %d: %v", written, err)
+				return
+			}
+			logger.LogErrorf("problem streaming customers: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		if written == 0 {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+		}
+		logger.Logf("streamed %d customers", written)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+func TestCustomerStream(t *testing.T) {
+	scope := Setup(t)
+	organization := "organization"
+	custs := scope.CreateCustomers(5, client.CUSTOMERTYPE_INDIVIDUAL, organization)
+	scope.CreateCustomer(context.Background(), "Jack", "Doe", "other", "jack@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+
+	storage := NewSSNStorage(secrets.TestStringKeeper(t), NewCustomerSSNRepository(log.NewNopLogger(), scope.customerRepo.db), "salt")
+	ssn, err := storage.encryptRaw(custs[0].CustomerID, client.OWNERTYPE_CUSTOMER, "123456789")
+	require.NoError(t, err)
+	require.NoError(t, storage.repo.saveSSN(ssn))
+
+	streamer := NewCustomerStreamer(scope.customerRepo, storage)
+	streamer.batchSize = 2
+
+	svc := admin.NewServer(":0")
+	defer svc.Shutdown()
+	AddCustomerStreamAdminRoutes(log.NewNopLogger(), svc, streamer)
+	go svc.Listen()
+
+	stream := func(query string) []StreamedCustomer {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/customers/export%s", svc.BindAddr(), query), nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Organization", organization)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+		var out []StreamedCustomer
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var cust StreamedCustomer
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &cust))
+			out = append(out, cust)
+		}
+		require.NoError(t, scanner.Err())
+		return out
+	}
+
+	// every customer is read across batches, ordered by ID
+	found := stream("")
+	require.Len(t, found, 5)
+	var ids []string
+	for i := range found {
+		ids = append(ids, found[i].CustomerID)
+		if found[i].CustomerID == custs[0].CustomerID {
+			require.Equal(t, "#####6789", found[i].SSN)
+		} else {
+			require.Empty(t, found[i].SSN)
+		}
+	}
+	require.True(t, sort.StringsAreSorted(ids))
+
+	// only customers changed since are included
+	old := time.Now().Add(-48 * time.Hour)
+	_, err = scope.customerRepo.db.Exec(`update customers set created_at = ?, last_modified = ?;`, old, old)
+	require.NoError(t, err)
+	_, err = scope.customerRepo.db.Exec(`update phones set last_modified = ?;`, old)
+	require.NoError(t, err)
+	_, err = scope.customerRepo.db.Exec(`update addresses set last_modified = ?;`, old)
+	require.NoError(t, err)
+	require.Empty(t, stream("?updatedSince="+time.Now().Add(-time.Hour).Format(time.RFC3339)))
+
+	require.NoError(t, scope.customerRepo.updateCustomerStatus(context.Background(), custs[2].CustomerID, client.CUSTOMERSTATUS_RECEIVE_ONLY, "", ""))
+	found = stream("?updatedSince=" + time.Now().Add(-time.Hour).Format(time.RFC3339))
+	require.Len(t, found, 1)
+	require.Equal(t, custs[2].CustomerID, found[0].CustomerID)
+	require.Len(t, stream("?updatedSince="+old.Add(-24*time.Hour).Format("2006-01-02")), 5)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/customers/export?updatedSince=yesterday", svc.BindAddr()))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,389 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// Tag is a label an organization defines, like "vip" or "high-risk", which can be added to its
+// Customers. Unlike metadata tags are a fixed vocabulary, so Customers can be searched by them.
+type Tag struct {
+	TagID       string    `json:"tagID"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// CustomerTag is a Tag added to a Customer
+type CustomerTag struct {
+	Name    string    `json:"name"`
+	Actor   string    `json:"actor,omitempty"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+var (
+	errTagNotFound = route.NewError(http.StatusNotFound, route.CodeNotFound, "tag not found")
+
+	tagNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+)
+
+const maxTagNameLength = 40
+
+// normalizeTagName lowercases name and checks it's a valid Tag name
+func normalizeTagName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) > maxTagNameLength || !tagNamePattern.MatchString(name) {
+		return "", route.Validation(fmt.Errorf("invalid tag %q: tags are up to %d letters, numbers, dashes and underscores", name, maxTagNameLength))
+	}
+	return name, nil
+}
+
+// TagRepository reads and writes each organization's Tags and the Tags of each Customer
+type TagRepository interface {
+	// ListTags returns the organization's Tags ordered by name
+	ListTags(organization string) ([]*Tag, error)
+
+	// GetCustomerTags returns the Tags of a Customer ordered by name
+	GetCustomerTags(customerID string) ([]*CustomerTag, error)
+
+	createTag(organization string, tag *Tag) error
+	deleteTag(organization, name string) error
+
+	addCustomerTag(customerID, organization, name, actor string) (*CustomerTag, error)
+	removeCustomerTag(customerID, organization, name string) error
+}
+
+func NewTagRepository(logger log.Logger, db customersdb.Querier) TagRepository {
+	return &sqlCustomerRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func AddTagRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository, tags TagRepository) {
+	logger = logger.Set("package", log.String("customers"))
+
+	r.Methods("GET").Path("/tags").HandlerFunc(listTags(logger, tags))
+	r.Methods("POST").Path("/tags").HandlerFunc(createTag(logger, tags))
+	r.Methods("DELETE").Path("/tags/{tagName}").HandlerFunc(deleteTag(logger, tags))
+
+	r.Methods("GET").Path("/customers/{customerID}/tags").HandlerFunc(getCustomerTags(logger, repo, tags))
+	r.Methods("PUT").Path("/customers/{customerID}/tags/{tagName}").HandlerFunc(addCustomerTag(logger, repo, tags))
+	r.Methods("DELETE").Path("/customers/{customerID}/tags/{tagName}").HandlerFunc(removeCustomerTag(logger, repo, tags))
+}
+
+type createTagRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func listTags(logger log.Logger, tags TagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		found, err := tags.ListTags(organization)
+		if err != nil {
+			logger.LogErrorf("problem listing tags: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(found)
+	}
+}
+
+func createTag(logger log.Logger, tags TagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		var req createTagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, fmt.Errorf("reading tag: %v", err))
+			return
+		}
+		name, err := normalizeTagName(req.Name)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if len(req.Description) > 255 {
+			route.Problem(w, route.Validation(fmt.Errorf("tag description is longer than 255 characters")))
+			return
+		}
+
+		tag := &Tag{
+			TagID:       base.ID(),
+			Name:        name,
+			Description: strings.TrimSpace(req.Description),
+			CreatedAt:   time.Now(),
+		}
+		if err := tags.createTag(organization, tag); err != nil {
+			logger.LogErrorf("problem creating tag: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		logger.Info().Set("tag", log.String(name)).Log("created tag")
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(tag)
+	}
+}
+
+// deleteTag removes a Tag from the organization's vocabulary and from every Customer it was added to
+func deleteTag(logger log.Logger, tags TagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		name := strings.ToLower(mux.Vars(r)["tagName"])
+		if err := tags.deleteTag(organization, name); err != nil {
+			if err != errTagNotFound {
+				logger.LogErrorf("problem deleting tag: %v", err)
+			}
+			route.Problem(w, err)
+			return
+		}
+		logger.Info().Set("tag", log.String(name)).Log("deleted tag")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getCustomerTags(logger log.Logger, repo CustomerRepository, tags TagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		found, err := tags.GetCustomerTags(customerID)
+		if err != nil {
+			logger.Set("customerID", log.String(customerID)).LogErrorf("problem reading tags: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(found)
+	}
+}
+
+// addCustomerTag adds one of the organization's Tags to a Customer. Adding a Tag the Customer
+// already has returns it unchanged.
+func addCustomerTag(logger log.Logger, repo CustomerRepository, tags TagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+		name := strings.ToLower(mux.Vars(r)["tagName"])
+		tag, err := tags.addCustomerTag(customerID, organization, name, route.GetActor(r))
+		if err != nil {
+			if err != errTagNotFound {
+				logger.LogErrorf("problem adding tag: %v", err)
+			}
+			route.Problem(w, err)
+			return
+		}
+		logger.Info().Set("tag", log.String(name)).Log("added tag")
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(tag)
+	}
+}
+
+func removeCustomerTag(logger log.Logger, repo CustomerRepository, tags TagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+		name := strings.ToLower(mux.Vars(r)["tagName"])
+		if err := tags.removeCustomerTag(customerID, organization, name); err != nil {
+			if err != errTagNotFound {
+				logger.LogErrorf("problem removing tag: %v", err)
+			}
+			route.Problem(w, err)
+			return
+		}
+		logger.Info().Set("tag", log.String(name)).Log("removed tag")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (r *sqlCustomerRepository) ListTags(organization string) ([]*Tag, error) {
+	query := `select tag_id, name, description, created_at from tags where organization = ? order by name;`
+	rows, err := r.db.Query(query, organization)
+	if err != nil {
+		return nil, fmt.Errorf("ListTags: %v", err)
+	}
+	defer rows.Close()
+
+	out := make([]*Tag, 0)
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.TagID, &tag.Name, &tag.Description, &tag.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ListTags: scan: %v", err)
+		}
+		out = append(out, &tag)
+	}
+	return out, rows.Err()
+}
+
+func (r *sqlCustomerRepository) GetCustomerTags(customerID string) ([]*CustomerTag, error) {
+	query := `select t.name, ct.actor, ct.created_at from customer_tags ct
+inner join tags t on t.tag_id = ct.tag_id
+where ct.customer_id = ? order by t.name;`
+	rows, err := r.db.Query(query, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("GetCustomerTags: %v", err)
+	}
+	defer rows.Close()
+
+	out := make([]*CustomerTag, 0)
+	for rows.Next() {
+		var tag CustomerTag
+		if err := rows.Scan(&tag.Name, &tag.Actor, &tag.AddedAt); err != nil {
+			return nil, fmt.Errorf("GetCustomerTags: scan: %v", err)
+		}
+		out = append(out, &tag)
+	}
+	return out, rows.Err()
+}
+
+func (r *sqlCustomerRepository) createTag(organization string, tag *Tag) error {
+	query := `insert into tags (tag_id, organization, name, description, created_at) values (?, ?, ?, ?, ?);`
+	if _, err := r.db.Exec(query, tag.TagID, organization, tag.Name, tag.Description, tag.CreatedAt); err != nil {
+		return fmt.Errorf("creating tag: %w", err)
+	}
+	return nil
+}
+
+// readTagIDTx returns the ID of the organization's Tag called name, or errTagNotFound
+func readTagIDTx(tx *sql.Tx, organization, name string) (string, error) {
+	var tagID string
+	err := tx.QueryRow(`select tag_id from tags where organization = ? and name = ?;`, organization, name).Scan(&tagID)
+	if err == sql.ErrNoRows {
+		return "", errTagNotFound
+	}
+	return tagID, err
+}
+
+func (r *sqlCustomerRepository) deleteTag(organization, name string) error {
+	return customersdb.RetryOnLock(r.db, func(tx *sql.Tx) error {
+		tagID, err := readTagIDTx(tx, organization, name)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`delete from customer_tags where tag_id = ?;`, tagID); err != nil {
+			return fmt.Errorf("deleting tag from customers: %v", err)
+		}
+		if _, err := tx.Exec(`delete from tags where tag_id = ?;`, tagID); err != nil {
+			return fmt.Errorf("deleting tag: %v", err)
+		}
+		return nil
+	})
+}
+
+func (r *sqlCustomerRepository) addCustomerTag(customerID, organization, name, actor string) (*CustomerTag, error) {
+	tag := &CustomerTag{Name: name}
+	err := customersdb.RetryOnLock(r.db, func(tx *sql.Tx) error {
+		tagID, err := readTagIDTx(tx, organization, name)
+		if err != nil {
+			return err
+		}
+		query := `select actor, created_at from customer_tags where customer_id = ? and tag_id = ?;`
+		err = tx.QueryRow(query, customerID, tagID).Scan(&tag.Actor, &tag.AddedAt)
+		if err != sql.ErrNoRows {
+			return err // nil when the Customer already has the tag
+		}
+		tag.Actor, tag.AddedAt = actor, time.Now()
+		query = `insert into customer_tags (customer_id, tag_id, actor, created_at) values (?, ?, ?, ?);`
+		if _, err := tx.Exec(query, customerID, tagID, actor, tag.AddedAt); err != nil {
+			return fmt.Errorf("adding tag: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (r *sqlCustomerRepository) removeCustomerTag(customerID, organization, name string) error {
+	return customersdb.RetryOnLock(r.db, func(tx *sql.Tx) error {
+		tagID, err := readTagIDTx(tx, organization, name)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec(`delete from customer_tags where customer_id = ? and tag_id = ?;`, customerID, tagID)
+		if err != nil {
+			return fmt.Errorf("removing tag: %v", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errTagNotFound
+		}
+		return nil
+	})
+}
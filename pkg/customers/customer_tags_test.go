@@ -0,0 +1,168 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestCustomerTags__normalizeTagName(t *testing.T) {
+	name, err := normalizeTagName(" High-Risk ")
+	require.NoError(t, err)
+	require.Equal(t, "high-risk", name)
+
+	for _, name := range []string{"", "-vip", "manual review", "vip!", strings.Repeat("a", 41)} {
+		_, err := normalizeTagName(name)
+		require.Error(t, err, name)
+	}
+}
+
+func TestCustomerTags__routes(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	cust, organization := setupMockCustomer(t, repo)
+
+	router := mux.NewRouter()
+	AddTagRoutes(log.NewNopLogger(), router, repo, NewTagRepository(log.NewNopLogger(), repo.db))
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Organization", organization)
+		req.Header.Set("X-User-ID", "operator")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	path := "/customers/" + cust.CustomerID + "/tags"
+
+	// tags must be created before they're added to Customers
+	w := send("PUT", path+"/vip", "")
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	w = send("POST", "/tags", `{"name": "VIP", "description": "high value customers"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var tag Tag
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tag))
+	require.Equal(t, "vip", tag.Name)
+
+	w = send("POST", "/tags", `{"name": "vip"}`)
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	w = send("POST", "/tags", `{"name": "not valid"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	w = send("GET", "/tags", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var tags []Tag
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tags))
+	require.Len(t, tags, 1)
+
+	// adding a tag twice keeps the first
+	w = send("PUT", path+"/vip", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var added CustomerTag
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&added))
+	require.Equal(t, "operator", added.Actor)
+
+	w = send("PUT", path+"/VIP", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var again CustomerTag
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&again))
+	require.True(t, added.AddedAt.Equal(again.AddedAt))
+
+	w = send("GET", path, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var customerTags []CustomerTag
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&customerTags))
+	require.Len(t, customerTags, 1)
+	require.Equal(t, "vip", customerTags[0].Name)
+
+	// other organizations can't see the Customer or the tag
+	req := httptest.NewRequest("GET", "/tags", nil)
+	req.Header.Set("X-Organization", "other")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, "[]\n", w.Body.String())
+
+	w = send("GET", "/customers/missing/tags", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = send("DELETE", path+"/vip", "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	w = send("DELETE", path+"/vip", "")
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	// deleting a tag removes it from every Customer
+	send("PUT", path+"/vip", "")
+	w = send("DELETE", "/tags/vip", "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	found, err := repo.GetCustomerTags(cust.CustomerID)
+	require.NoError(t, err)
+	require.Empty(t, found)
+	w = send("DELETE", "/tags/vip", "")
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+}
+
+func TestSearchCustomersByTag(t *testing.T) {
+	scope := Setup(t)
+	organization := "organization"
+	tags := NewTagRepository(log.NewNopLogger(), scope.customerRepo.db)
+
+	for _, name := range []string{"vip", "high-risk"} {
+		require.NoError(t, tags.createTag(organization, &Tag{TagID: name, Name: name}))
+	}
+	both := scope.CreateCustomer(context.Background(), "John", "Doe", organization, "john@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	vip := scope.CreateCustomer(context.Background(), "Jane", "Doe", organization, "jane@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	scope.CreateCustomer(context.Background(), "Jim", "Doe", organization, "jim@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+
+	for _, name := range []string{"vip", "high-risk"} {
+		_, err := tags.addCustomerTag(both.CustomerID, organization, name, "")
+		require.NoError(t, err)
+	}
+	_, err := tags.addCustomerTag(vip.CustomerID, organization, "vip", "")
+	require.NoError(t, err)
+
+	ids := func(query string) []string {
+		customers, err := scope.GetCustomers(query, organization)
+		require.NoError(t, err)
+		var out []string
+		for i := range customers {
+			out = append(out, customers[i].CustomerID)
+		}
+		return out
+	}
+	require.ElementsMatch(t, []string{both.CustomerID, vip.CustomerID}, ids("?tag=vip"))
+	require.ElementsMatch(t, []string{both.CustomerID}, ids("?tag=vip&tag=high-risk"))
+	require.ElementsMatch(t, []string{both.CustomerID}, ids("?tag=vip,high-risk&tagMatch=all"))
+	require.ElementsMatch(t, []string{both.CustomerID, vip.CustomerID}, ids("?tag=vip,high-risk&tagMatch=any"))
+	require.Empty(t, ids("?tag=manual-review"))
+
+	// tags are scoped to the organization
+	customers, err := scope.GetCustomers("?tag=vip", "other")
+	require.NoError(t, err)
+	require.Empty(t, customers)
+
+	total, err := scope.customerRepo.countCustomers(context.Background(), SearchParams{Organization: organization, Tags: []string{"vip", "high-risk"}, AnyTag: true})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+
+	req := httptest.NewRequest("GET", "/customers?tag=vip&tagMatch=some", nil)
+	_, err = parseSearchParams(req)
+	require.Error(t, err)
+	req = httptest.NewRequest("GET", "/customers?tag=not+valid", nil)
+	_, err = parseSearchParams(req)
+	require.Error(t, err)
+}
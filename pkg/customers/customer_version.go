@@ -0,0 +1,87 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// Customers have a version which is incremented each time their record changes. It's returned as
+// the ETag of GET /customers/{customerID} and must be sent back in the If-Match header of updates,
+// which are rejected if the Customer has since been changed by someone else.
+
+var (
+	errMissingIfMatch  = route.NewError(http.StatusPreconditionRequired, route.CodePreconditionRequired, "If-Match header with the Customer's ETag is required")
+	errVersionMismatch = route.NewError(http.StatusPreconditionFailed, route.CodePreconditionFailed, "customer has been modified since it was read")
+)
+
+// anyVersion is read from If-Match: * and skips checking the Customer's version
+const anyVersion int64 = 0
+
+func formatETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// readIfMatch returns the Customer version from the request's If-Match header
+func readIfMatch(r *http.Request) (int64, error) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" {
+		return anyVersion, errMissingIfMatch
+	}
+	if v == "*" {
+		return anyVersion, nil
+	}
+	v = strings.TrimPrefix(v, "W/")
+	if unquoted, err := strconv.Unquote(v); err == nil {
+		v = unquoted
+	}
+	version, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || version <= 0 {
+		return anyVersion, fmt.Errorf("invalid If-Match header: %s", r.Header.Get("If-Match"))
+	}
+	return version, nil
+}
+
+// setETag writes the ETag of the Customer's current version
+func setETag(ctx context.Context, w http.ResponseWriter, repo CustomerRepository, customerID, organization string) error {
+	version, err := repo.getCustomerVersion(ctx, customerID, organization)
+	if err != nil {
+		return err
+	}
+	if version > 0 {
+		w.Header().Set("ETag", formatETag(version))
+	}
+	return nil
+}
+
+// getCustomerVersion returns the Customer's version, or zero if they aren't found
+func (r *sqlCustomerRepository) getCustomerVersion(ctx context.Context, customerID, organization string) (int64, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select version from customers where customer_id = ? and organization = ? and deleted_at is null limit 1;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("getCustomerVersion: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	var version int64
+	if err := stmt.QueryRowContext(ctx, customerID, organization).Scan(&version); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("getCustomerVersion: scan: %v", err)
+	}
+	return version, nil
+}
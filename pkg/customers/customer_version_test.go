@@ -0,0 +1,157 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+func TestCustomers__readIfMatch(t *testing.T) {
+	read := func(v string) (int64, error) {
+		req := httptest.NewRequest("PUT", "/customers/foo", nil)
+		if v != "" {
+			req.Header.Set("If-Match", v)
+		}
+		return readIfMatch(req)
+	}
+
+	_, err := read("")
+	require.Equal(t, errMissingIfMatch, err)
+
+	for _, v := range []string{`"3"`, `W/"3"`, "3"} {
+		version, err := read(v)
+		require.NoError(t, err, v)
+		require.Equal(t, int64(3), version, v)
+	}
+
+	version, err := read("*")
+	require.NoError(t, err)
+	require.Equal(t, anyVersion, version)
+
+	for _, v := range []string{`"abc"`, `"0"`, `"-1"`} {
+		_, err = read(v)
+		require.Error(t, err, v)
+	}
+}
+
+func TestCustomers__optimisticLocking(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	customer, _, err := (customerRequest{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Email:     "jane@example.com",
+		Type:      client.CUSTOMERTYPE_INDIVIDUAL,
+	}).asCustomer(testCustomerSSNStorage(t))
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateCustomer(context.Background(), customer, "test"))
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(repo, nil), nil, nil)
+
+	do := func(method, ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/customers/"+customer.CustomerID, strings.NewReader(body))
+		req.Header.Set("x-organization", "test")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("GET", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.Equal(t, `"1"`, etag)
+
+	// updates must name the version they were made from
+	w = do("PATCH", "", `{"nickName": "jj"}`)
+	require.Equal(t, http.StatusPreconditionRequired, w.Code)
+
+	w = do("PATCH", etag, `{"nickName": "jj"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	// a second update from the same read is rejected
+	w = do("PATCH", etag, `{"nickName": "jane"}`)
+	require.Equal(t, http.StatusPreconditionFailed, w.Code, w.Body.String())
+
+	w = do("PUT", etag, `{"firstName": "Jane", "lastName": "Doe", "type": "individual"}`)
+	require.Equal(t, http.StatusPreconditionFailed, w.Code, w.Body.String())
+
+	// status changes are new versions too
+	require.NoError(t, repo.updateCustomerStatus(context.Background(), customer.CustomerID, client.CUSTOMERSTATUS_RECEIVE_ONLY, "", ""))
+	version, err := repo.getCustomerVersion(context.Background(), customer.CustomerID, "test")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), version)
+
+	err = repo.updateCustomer(context.Background(), customer, nil, "test", 2)
+	require.Equal(t, errVersionMismatch, err)
+	require.NoError(t, repo.updateCustomer(context.Background(), customer, nil, "test", 3))
+
+	// unknown customers have no version
+	version, err = repo.getCustomerVersion(context.Background(), "other", "test")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), version)
+}
+
+func TestCustomers__optimisticLockingSSN(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	storage := NewSSNStorage(secrets.TestStringKeeper(t), NewCustomerSSNRepository(log.NewNopLogger(), repo.db), "salt")
+	customer, ssn, err := (customerRequest{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Type:      client.CUSTOMERTYPE_INDIVIDUAL,
+		BirthDate: "1990-01-02",
+		SSN:       "123456789",
+	}).asCustomer(storage)
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateCustomer(context.Background(), customer, "test"))
+	require.NoError(t, storage.repo.saveSSN(ssn))
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, storage, createTestOFACSearcher(repo, nil), nil, nil)
+
+	do := func(method, ifMatch, body string) int {
+		req := httptest.NewRequest(method, "/customers/"+customer.CustomerID, strings.NewReader(body))
+		req.Header.Set("x-organization", "test")
+		req.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	maskedSSN := func() string {
+		stored, err := storage.repo.getSSN(customer.CustomerID, client.OWNERTYPE_CUSTOMER)
+		require.NoError(t, err)
+		return stored.masked
+	}
+	require.Equal(t, http.StatusOK, do("PATCH", `"1"`, `{"nickName": "jj"}`))
+
+	// stale updates don't change the SSN
+	body := `{"firstName": "Jane", "lastName": "Doe", "type": "individual", "birthDate": "1990-01-02", "SSN": "587654321"}`
+	require.Equal(t, http.StatusPreconditionFailed, do("PUT", `"1"`, body))
+	require.Equal(t, "#####6789", maskedSSN())
+
+	require.Equal(t, http.StatusPreconditionFailed, do("PATCH", `"1"`, `{"SSN": "587654321"}`))
+	require.Equal(t, "#####6789", maskedSSN())
+
+	require.Equal(t, http.StatusOK, do("PUT", `"2"`, body))
+	require.Equal(t, "#####4321", maskedSSN())
+}
@@ -5,37 +5,66 @@
 package customers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
 
 	"github.com/moov-io/base"
+	"github.com/moov-io/base/database"
 	moovhttp "github.com/moov-io/base/http"
 
-	"github.com/moov-io/customers/internal/usstates"
+	"github.com/moov-io/customers/internal/countries"
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/internal/ids"
+	"github.com/moov-io/customers/internal/phonenumbers"
+	"github.com/moov-io/customers/internal/util"
 	"github.com/moov-io/customers/pkg/client"
 	"github.com/moov-io/customers/pkg/model"
 	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/secrets"
+	"github.com/moov-io/customers/pkg/tracing"
+	"github.com/moov-io/customers/pkg/webhooks"
 
+	"github.com/go-kit/kit/metrics/prometheus"
 	"github.com/gorilla/mux"
 	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
 )
 
-func AddCustomerRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository, customerSSNStorage *ssnStorage, ofac *OFACSearcher) {
+func AddCustomerRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository, customerSSNStorage *ssnStorage, ofac *OFACSearcher, notifier webhooks.Notifier, verifier AddressVerifier) {
 	logger = logger.Set("package", log.String("customers"))
 
 	r.Methods("GET").Path("/customers").HandlerFunc(searchCustomers(logger, repo))
+	r.Methods("GET").Path("/customers/search").HandlerFunc(searchCustomersByName(logger, repo))
+	r.Methods("GET").Path("/customers/ofac-matches").HandlerFunc(searchOFACMatches(logger, repo))
+	r.Methods("GET").Path("/customers/stats").HandlerFunc(getCustomerStats(logger, newStatsCache(repo, customerStatsCacheDuration)))
 	r.Methods("GET").Path("/customers/{customerID}").HandlerFunc(getCustomer(logger, repo))
-	r.Methods("PUT").Path("/customers/{customerID}").HandlerFunc(updateCustomer(logger, repo, customerSSNStorage))
-	r.Methods("DELETE").Path("/customers/{customerID}").HandlerFunc(deleteCustomer(logger, repo))
-	r.Methods("POST").Path("/customers").HandlerFunc(createCustomer(logger, repo, customerSSNStorage, ofac))
+	r.Methods("PUT").Path("/customers/{customerID}").HandlerFunc(updateCustomer(logger, repo, customerSSNStorage, verifier))
+	r.Methods("PATCH").Path("/customers/{customerID}").HandlerFunc(patchCustomer(logger, repo, customerSSNStorage, verifier))
+	r.Methods("DELETE").Path("/customers/{customerID}").HandlerFunc(deleteCustomer(logger, repo, notifier))
+	r.Methods("POST").Path("/customers").HandlerFunc(createCustomer(logger, repo, customerSSNStorage, ofac, notifier, verifier))
+	r.Methods("POST").Path("/customers/import").HandlerFunc(importCustomers(logger, repo, customerSSNStorage, ofac, notifier))
+	r.Methods("POST").Path("/customers/status").HandlerFunc(bulkUpdateCustomerStatus(logger, repo, customerSSNStorage, notifier))
+	r.Methods("GET").Path("/customers/{customerID}/metadata").HandlerFunc(getCustomerMetadata(logger, repo))
 	r.Methods("PUT").Path("/customers/{customerID}/metadata").HandlerFunc(replaceCustomerMetadata(logger, repo))
-	r.Methods("PUT").Path("/customers/{customerID}/status").HandlerFunc(updateCustomerStatus(logger, repo))
+	r.Methods("PUT").Path("/customers/{customerID}/status").HandlerFunc(updateCustomerStatus(logger, repo, customerSSNStorage, notifier))
+	r.Methods("PUT").Path("/customers/{customerID}/ssn").HandlerFunc(updateCustomerSSN(logger, repo, customerSSNStorage, ofac, notifier))
+	r.Methods("GET").Path("/customers/{customerID}/status-history").HandlerFunc(getCustomerStatusHistory(logger, repo))
+}
+
+// notify sends a webhook for the Customer if a notifier is configured
+func notify(notifier webhooks.Notifier, eventType webhooks.EventType, customerID, organization string, status client.CustomerStatus) {
+	if notifier != nil {
+		notifier.Notify(eventType, customerID, organization, string(status))
+	}
 }
 
 // formatCustomerName returns a Customer's name joined as one string. It accounts for
@@ -58,6 +87,7 @@ func formatCustomerName(c *client.Customer) string {
 func getCustomer(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 
 		customerID, requestID := route.GetCustomerID(w, r), moovhttp.GetRequestID(r)
 		if customerID == "" {
@@ -69,11 +99,11 @@ func getCustomer(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
 			return
 		}
 
-		respondWithCustomer(logger, w, customerID, organization, requestID, repo)
+		respondWithCustomer(r.Context(), logger, w, customerID, organization, requestID, repo)
 	}
 }
 
-func deleteCustomer(logger log.Logger, repo CustomerRepository) func(http.ResponseWriter, *http.Request) {
+func deleteCustomer(logger log.Logger, repo CustomerRepository, notifier webhooks.Notifier) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
 
@@ -82,26 +112,32 @@ func deleteCustomer(logger log.Logger, repo CustomerRepository) func(http.Respon
 			return
 		}
 
-		err := repo.deleteCustomer(customerID)
+		err := repo.deleteCustomer(r.Context(), customerID)
 		if err != nil {
-			moovhttp.Problem(w, fmt.Errorf("deleting customer: %v", err))
+			route.Problem(w, fmt.Errorf("deleting customer: %v", err))
 			return
 		}
+		notify(notifier, webhooks.CustomerDeleted, customerID, r.Header.Get("X-Organization"), "")
 
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
-func respondWithCustomer(logger log.Logger, w http.ResponseWriter, customerID, organization string, requestID string, repo CustomerRepository) {
-	cust, err := repo.GetCustomer(customerID, organization)
+func respondWithCustomer(ctx context.Context, logger log.Logger, w http.ResponseWriter, customerID, organization string, requestID string, repo CustomerRepository) {
+	_, span := tracing.StartSpan(ctx, "GetCustomer", customerID)
+	cust, err := repo.GetCustomer(ctx, customerID, organization)
+	tracing.EndSpan(span, err)
 	if err != nil {
 		logger.LogErrorf("getCustomer: lookup: %v", err)
-		moovhttp.Problem(w, err)
+		route.Problem(w, err)
 		return
 	}
 	if cust == nil {
-		w.WriteHeader(http.StatusNotFound)
+		route.Problem(w, errCustomerNotFound)
 	} else {
+		if err := setETag(ctx, w, repo, customerID, organization); err != nil {
+			logger.LogErrorf("getCustomer: reading version: %v", err)
+		}
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(cust)
@@ -119,6 +155,7 @@ type customerRequest struct {
 	LastName                string                   `json:"lastName"`
 	NickName                string                   `json:"nickName"`
 	Suffix                  string                   `json:"suffix"`
+	FullName                string                   `json:"fullName"`
 	Type                    client.CustomerType      `json:"type"`
 	BusinessName            string                   `json:"businessName"`
 	DoingBusinessAs         string                   `json:"doingBusinessAs"`
@@ -132,6 +169,7 @@ type customerRequest struct {
 	Email                   string                   `json:"email"`
 	Website                 string                   `json:"website"`
 	DateBusinessEstablished string                   `json:"dateBusinessEstablished"`
+	Locale                  string                   `json:"locale"`
 	SSN                     string                   `json:"SSN"`
 	Phones                  []phone                  `json:"phones"`
 	Addresses               []address                `json:"addresses"`
@@ -143,14 +181,30 @@ type phone struct {
 	Number    string           `json:"number"`
 	Type      client.PhoneType `json:"type"`
 	OwnerType client.OwnerType `json:"ownerType"`
+
+	// valid is set by the server after parsing the number
+	valid bool
 }
 
+// validate normalizes the phone number to E.164 and infers its type when one isn't given.
 func (p *phone) validate() error {
 	p.Type = client.PhoneType(strings.ToLower(string(p.Type)))
 	p.OwnerType = client.OwnerType(strings.ToLower(string(p.OwnerType)))
 
+	num, err := phonenumbers.Parse(p.Number)
+	if err != nil {
+		return err
+	}
+	p.Number = num.E164
+	p.valid = num.Valid
+
+	if p.Type == "" {
+		p.Type = inferPhoneType(num.Type)
+	}
 	switch p.Type {
 	case client.PHONETYPE_HOME, client.PHONETYPE_MOBILE, client.PHONETYPE_WORK:
+	case "":
+		return errors.New("type is required when it can't be inferred from the number")
 	default:
 		return fmt.Errorf("unknown type: %s", p.Type)
 	}
@@ -170,9 +224,12 @@ type address struct {
 	Address1   string             `json:"address1"`
 	Address2   string             `json:"address2,omitempty"`
 	City       string             `json:"city"`
-	State      string             `json:"state"`
-	PostalCode string             `json:"postalCode"` // TODO(adam): validate against US postal codes
-	Country    string             `json:"country"`
+	State      string             `json:"state"` // state, province or region
+	PostalCode string             `json:"postalCode"`
+	Country    string             `json:"country"` // ISO 3166-1 alpha-2 code, defaults to US
+
+	// validated is set by the server after verifying the address
+	validated bool
 }
 
 func (add *address) validate() error {
@@ -191,8 +248,18 @@ func (add *address) validate() error {
 		return fmt.Errorf("unknown owner type: %s", add.OwnerType)
 	}
 
-	if !usstates.Valid(add.State) {
-		return fmt.Errorf("create customer: invalid state=%s", add.State)
+	// Addresses were US-only before countries were validated, so a missing country is the US.
+	country, ok := countries.Normalize(util.Or(add.Country, "US"))
+	if !ok {
+		return fmt.Errorf("invalid country=%s", add.Country)
+	}
+	add.Country = country
+
+	if !countries.ValidRegion(add.Country, add.State) {
+		return fmt.Errorf("create customer: invalid state=%s for country=%s", add.State, add.Country)
+	}
+	if add.PostalCode != "" && !countries.ValidPostalCode(add.Country, add.PostalCode) {
+		return fmt.Errorf("invalid postal code=%s for country=%s", add.PostalCode, add.Country)
 	}
 	return nil
 }
@@ -204,13 +271,18 @@ type customerRepresentative struct {
 	BirthDate string    `json:"birthDate,omitempty"`
 	Addresses []address `json:"addresses,omitempty"`
 	Phones    []phone   `json:"phones,omitempty"`
+
+	OwnershipPercentage float32 `json:"ownershipPercentage,omitempty"`
 }
 
 func (rep *customerRepresentative) validate() error {
 	if rep.FirstName == "" || rep.LastName == "" {
 		return errors.New("invalid customer representative fields: empty name field(s)")
 	}
-	return nil
+	if err := validatePhones(rep.Phones); err != nil {
+		return fmt.Errorf("invalid customer representative phone: %v", err)
+	}
+	return validateOwnershipPercentage(rep.OwnershipPercentage)
 }
 
 func (req customerRequest) validate() error {
@@ -220,6 +292,16 @@ func (req customerRequest) validate() error {
 	if err := validateCustomerType(req.Type); err != nil {
 		return fmt.Errorf("invalid customer type: %v", err)
 	}
+	minimumAge := 0
+	if strings.EqualFold(string(req.Type), string(client.CUSTOMERTYPE_INDIVIDUAL)) {
+		minimumAge = minimumCustomerAge
+	}
+	if err := validateBirthDate(req.BirthDate, minimumAge, time.Now()); err != nil {
+		return fmt.Errorf("invalid customer birthDate: %v", err)
+	}
+	if _, err := normalizeLocale(req.Locale); err != nil {
+		return fmt.Errorf("invalid customer locale: %v", err)
+	}
 	if err := validateMetadata(req.Metadata); err != nil {
 		return fmt.Errorf("invalid customer metadata: %v", err)
 	}
@@ -232,6 +314,11 @@ func (req customerRequest) validate() error {
 	if err := validateRepresentatives(req.Representatives); err != nil {
 		return fmt.Errorf("invalid customer representative: %v", err)
 	}
+	if req.SSN != "" {
+		if err := validateSSN(req.SSN); err != nil {
+			return fmt.Errorf("invalid customer SSN: %v", err)
+		}
+	}
 
 	return nil
 }
@@ -247,13 +334,34 @@ func validateCustomerType(t client.CustomerType) error {
 	return fmt.Errorf("unknown type: %s", t)
 }
 
+var (
+	maxMetadataKeys = readMetadataLimit("CUSTOMER_METADATA_MAX_KEYS", 100)
+
+	// maxMetadataValueLength defaults to the size of the meta_value column
+	maxMetadataValueLength = readMetadataLimit("CUSTOMER_METADATA_MAX_VALUE_LENGTH", 512)
+)
+
+const maxMetadataKeyLength = 40 // size of the meta_key column
+
+func readMetadataLimit(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		n, err := strconv.Atoi(v)
+		if err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
+
 func validateMetadata(meta map[string]string) error {
-	// both are arbitrary limits, open an issue if this needs bumped
-	if len(meta) > 100 {
-		return errors.New("metadata is limited to 100 entries")
+	if len(meta) > maxMetadataKeys {
+		return fmt.Errorf("metadata is limited to %d entries", maxMetadataKeys)
 	}
 	for k, v := range meta {
-		if utf8.RuneCountInString(v) > 1000 {
+		if k == "" || utf8.RuneCountInString(k) > maxMetadataKeyLength {
+			return fmt.Errorf("metadata key %q must be between 1 and %d characters", k, maxMetadataKeyLength)
+		}
+		if utf8.RuneCountInString(v) > maxMetadataValueLength {
 			return fmt.Errorf("metadata key %s value is too long", k)
 		}
 	}
@@ -262,7 +370,8 @@ func validateMetadata(meta map[string]string) error {
 
 func validateAddresses(addrs []address) error {
 	hasPrimaryAddr := false
-	for _, addr := range addrs {
+	for i := range addrs {
+		addr := &addrs[i]
 		if hasPrimaryAddr && addr.Type == client.ADDRESSTYPE_PRIMARY {
 			return ErrAddressTypeDuplicate
 		}
@@ -279,9 +388,22 @@ func validateAddresses(addrs []address) error {
 	return nil
 }
 
+// inferPhoneType maps the line type of a parsed number onto the phone types we offer
+func inferPhoneType(t phonenumbers.Type) client.PhoneType {
+	switch t {
+	case phonenumbers.Mobile:
+		return client.PHONETYPE_MOBILE
+	case phonenumbers.FixedLine:
+		return client.PHONETYPE_HOME
+	case phonenumbers.TollFree:
+		return client.PHONETYPE_WORK
+	}
+	return ""
+}
+
 func validatePhones(phones []phone) error {
-	for _, p := range phones {
-		if err := p.validate(); err != nil {
+	for i := range phones {
+		if err := phones[i].validate(); err != nil {
 			return err
 		}
 	}
@@ -290,10 +412,15 @@ func validatePhones(phones []phone) error {
 }
 
 func validateRepresentatives(representatives []customerRepresentative) error {
+	var total float32
 	for _, r := range representatives {
 		if err := r.validate(); err != nil {
 			return err
 		}
+		total += r.OwnershipPercentage
+	}
+	if total > 100 {
+		return errOwnershipExceeded
 	}
 
 	return nil
@@ -301,7 +428,7 @@ func validateRepresentatives(representatives []customerRepresentative) error {
 
 func (req customerRequest) asCustomer(storage *ssnStorage) (*client.Customer, *SSN, error) {
 	if req.CustomerID == "" {
-		req.CustomerID = base.ID()
+		req.CustomerID = ids.NewCustomerID()
 	}
 
 	if req.Status == "" {
@@ -327,13 +454,18 @@ func (req customerRequest) asCustomer(storage *ssnStorage) (*client.Customer, *S
 		Email:                   req.Email,
 		Website:                 req.Website,
 		DateBusinessEstablished: req.DateBusinessEstablished,
+		Locale:                  customerLocale(req.Locale),
 		Status:                  req.Status,
 		Metadata:                req.Metadata,
 	}
+	if born, err := time.Parse(model.YYYYMMDD_Format, customer.BirthDate); err == nil {
+		customer.Age = int32(customerAge(born, time.Now()))
+	}
 
 	for i := range req.Phones {
 		customer.Phones = append(customer.Phones, client.Phone{
 			Number:    req.Phones[i].Number,
+			Valid:     req.Phones[i].valid,
 			Type:      req.Phones[i].Type,
 			OwnerType: req.Phones[i].OwnerType,
 		})
@@ -349,6 +481,7 @@ func (req customerRequest) asCustomer(storage *ssnStorage) (*client.Customer, *S
 			Country:    req.Addresses[i].Country,
 			Type:       req.Addresses[i].Type,
 			OwnerType:  req.Addresses[i].OwnerType,
+			Validated:  req.Addresses[i].validated,
 		})
 	}
 	for i := range req.Representatives {
@@ -358,6 +491,8 @@ func (req customerRequest) asCustomer(storage *ssnStorage) (*client.Customer, *S
 			LastName:         req.Representatives[i].LastName,
 			JobTitle:         req.Representatives[i].JobTitle,
 			BirthDate:        req.Representatives[i].BirthDate,
+
+			OwnershipPercentage: req.Representatives[i].OwnershipPercentage,
 		}
 
 		for j := range req.Representatives[i].Addresses {
@@ -376,6 +511,7 @@ func (req customerRequest) asCustomer(storage *ssnStorage) (*client.Customer, *S
 		for j := range req.Representatives[i].Phones {
 			custRep.Phones = append(custRep.Phones, client.Phone{
 				Number:    req.Representatives[i].Phones[j].Number,
+				Valid:     req.Representatives[i].Phones[j].valid,
 				Type:      req.Representatives[i].Phones[j].Type,
 				OwnerType: req.Representatives[i].Phones[j].OwnerType,
 			})
@@ -389,9 +525,10 @@ func (req customerRequest) asCustomer(storage *ssnStorage) (*client.Customer, *S
 	return customer, nil, nil
 }
 
-func createCustomer(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage, ofac *OFACSearcher) http.HandlerFunc {
+func createCustomer(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage, ofac *OFACSearcher, notifier webhooks.Notifier, verifier AddressVerifier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
 		requestID, organization := moovhttp.GetRequestID(r), route.GetOrganization(w, r)
@@ -401,61 +538,161 @@ func createCustomer(logger log.Logger, repo CustomerRepository, customerSSNStora
 
 		var req customerRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
+			return
+		}
+		if err := req.applyFullName(); err != nil {
+			route.Problem(w, route.Validation(err))
 			return
 		}
 		if err := req.validate(); err != nil {
 			logger.LogErrorf("error validating new customer: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, route.Validation(err))
 			return
 		}
+		verifyAddresses(logger, verifier, req.Addresses)
 
 		cust, ssn, err := req.asCustomer(customerSSNStorage)
 		if err != nil {
 			logger.LogErrorf("problem transforming request into Customer=%s: %v", cust.CustomerID, err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
-		if ssn != nil {
-			err := customerSSNStorage.repo.saveSSN(ssn)
+
+		// Return the original Customer for retried requests
+		created := false
+		idempotencyKey, err := readIdempotencyKey(r.Header.Get(IdempotencyKeyHeader))
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if idempotencyKey != "" {
+			existing, err := repo.reserveIdempotencyKey(r.Context(), idempotencyKey, organization, cust.CustomerID)
 			if err != nil {
-				logger.LogErrorf("problem saving SSN for Customer=%s: %v", cust.CustomerID, err)
-				moovhttp.Problem(w, fmt.Errorf("saveCustomerSSN: %v", err))
+				logger.LogErrorf("problem reserving idempotency key: %v", err)
+				route.Problem(w, err)
+				return
+			}
+			if existing != nil {
+				customerID, err := waitForIdempotentCustomer(r.Context(), repo, idempotencyKey, organization, existing)
+				if err != nil {
+					route.Problem(w, err)
+					return
+				}
+				logger.Logf("returning customer=%s for repeated idempotency key", customerID)
+				respondWithCustomer(r.Context(), logger, w, customerID, organization, requestID, repo)
 				return
 			}
+
+			customerID := cust.CustomerID
+			defer func() {
+				var err error
+				if created {
+					err = repo.completeIdempotencyKey(r.Context(), idempotencyKey, organization)
+				} else {
+					// let the client retry
+					err = repo.releaseIdempotencyKey(r.Context(), idempotencyKey, organization)
+				}
+				if err != nil {
+					logger.LogErrorf("problem saving idempotency key for customer=%s: %v", customerID, err)
+				}
+			}()
 		}
-		if err := repo.CreateCustomer(cust, organization); err != nil {
-			logger.LogErrorf("createCustomer: %v", err)
-			moovhttp.Problem(w, err)
+
+		dup, err := saveNewCustomer(r.Context(), logger, repo, customerSSNStorage, cust, ssn, organization)
+		if err != nil {
+			route.Problem(w, err)
 			return
 		}
-		if err := repo.replaceCustomerMetadata(cust.CustomerID, cust.Metadata); err != nil {
+		created = true
+		if dup != nil {
+			w.Header().Set(DuplicateCustomerHeader, dup.CustomerID)
+		}
+		if err := repo.replaceCustomerMetadata(r.Context(), cust.CustomerID, cust.Metadata); err != nil {
 			logger.LogErrorf("updating metadata for customer=%s failed: %v", cust.CustomerID, err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
-
-		// Perform an OFAC search with the Customer information
-		if err := ofac.storeCustomerOFACSearch(cust, requestID); err != nil {
-			logger.LogErrorf("error with OFAC search for customer=%s: %v", cust.CustomerID, err)
+		if err := rejectDeniedSSN(r.Context(), logger, repo, cust.CustomerID, organization, ssn); err != nil {
+			logger.LogErrorf("problem checking SSN denylist for customer=%s: %v", cust.CustomerID, err)
 		}
+		screenNewCustomer(r.Context(), logger, repo, ofac, cust, organization, requestID, route.GetActor(r))
 
 		logger.Logf("created customer=%s", cust.CustomerID)
 
-		cust, err = repo.GetCustomer(cust.CustomerID, organization)
+		cust, err = repo.GetCustomer(r.Context(), cust.CustomerID, organization)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
+		notify(notifier, webhooks.CustomerCreated, cust.CustomerID, organization, cust.Status)
 
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(cust)
 	}
 }
 
-func updateCustomer(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage) http.HandlerFunc {
+// saveNewCustomer stores a Customer and their encrypted SSN. When CUSTOMER_DEDUPLICATION is set both
+// are written in the transaction which checks for duplicates.
+func saveNewCustomer(ctx context.Context, logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage, cust *client.Customer, ssn *SSN, organization string) (*duplicateCustomer, error) {
+	deduped := deduplication == DeduplicationReject || deduplication == DeduplicationWarn
+	if ssn != nil && !deduped {
+		if err := customerSSNStorage.repo.saveSSN(ssn); err != nil {
+			logger.LogErrorf("problem saving SSN for Customer=%s: %v", cust.CustomerID, err)
+			return nil, fmt.Errorf("saveCustomerSSN: %v", err)
+		}
+	}
+	_, span := tracing.StartSpan(ctx, "CreateCustomer", cust.CustomerID)
+	var dup *duplicateCustomer
+	var err error
+	if deduped {
+		// the SSN is saved along with the Customer, after checking for duplicates
+		dup, err = repo.createDedupedCustomer(ctx, cust, ssn, organization, deduplication == DeduplicationReject)
+	} else {
+		err = repo.CreateCustomer(ctx, cust, organization)
+	}
+	tracing.EndSpan(span, err)
+	if err != nil {
+		logger.LogErrorf("createCustomer: %v", err)
+		return nil, err
+	}
+	if dup == nil {
+		return nil, nil
+	}
+	if deduplication == DeduplicationReject {
+		logger.Logf("rejected customer=%s with the same %s as customer=%s", cust.CustomerID, dup.Field, dup.CustomerID)
+		return nil, duplicateError(dup)
+	}
+	logger.Logf("created customer=%s with the same %s as customer=%s", cust.CustomerID, dup.Field, dup.CustomerID)
+	return dup, nil
+}
+
+// screenNewCustomer performs an OFAC search with the Customer information and rejects them
+// if they're blocked. Errors are only logged as the Customer has already been created.
+func screenNewCustomer(ctx context.Context, logger log.Logger, repo CustomerRepository, ofac *OFACSearcher, cust *client.Customer, organization, requestID, actor string) {
+	_, span := tracing.StartSpan(ctx, "OFACSearch", cust.CustomerID)
+	err := ofac.storeCustomerOFACSearch(cust, requestID)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		logger.LogErrorf("error with OFAC search for customer=%s: %v", cust.CustomerID, err)
+	} else {
+		result, err := repo.getLatestCustomerOFACSearch(ctx, cust.CustomerID, organization)
+		if err != nil {
+			logger.LogErrorf("error getting OFAC search for customer=%s: %v", cust.CustomerID, err)
+		}
+		if _, err := rejectBlockedCustomer(ctx, logger, repo, cust, result, "OFAC search on create", actor); err != nil {
+			logger.LogErrorf("error with OFAC search for customer=%s: %v", cust.CustomerID, err)
+		}
+	}
+	for i := range cust.Representatives {
+		screenRepresentative(logger, ofac, &cust.Representatives[i], requestID)
+	}
+}
+
+func updateCustomer(logger log.Logger, repo CustomerRepository, customerSSNStorage *ssnStorage, verifier AddressVerifier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
 		organization := route.GetOrganization(w, r)
@@ -468,49 +705,58 @@ func updateCustomer(logger log.Logger, repo CustomerRepository, customerSSNStora
 		if req.CustomerID == "" {
 			return
 		}
+		version, err := readIfMatch(r)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		if err := req.validate(); err != nil {
 			logger.LogErrorf("error validating customer payload: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, route.Validation(err))
 			return
 		}
+		verifyAddresses(logger, verifier, req.Addresses)
 
 		cust, ssn, err := req.asCustomer(customerSSNStorage)
 		if err != nil {
 			logger.LogErrorf("transforming request into Customer=%s: %v", cust.CustomerID, err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
-		if ssn != nil {
-			err := customerSSNStorage.repo.saveSSN(ssn)
-			if err != nil {
-				logger.LogErrorf("error saving SSN for Customer=%s: %v", cust.CustomerID, err)
-				moovhttp.Problem(w, fmt.Errorf("saving customer's SSN: %v", err))
+		if err := repo.updateCustomer(r.Context(), cust, ssn, organization, version); err != nil {
+			if err == errVersionMismatch {
+				route.Problem(w, err)
 				return
 			}
-		}
-		if err := repo.updateCustomer(cust, organization); err != nil {
 			logger.LogErrorf("error updating customer: %v", err)
-			moovhttp.Problem(w, fmt.Errorf("updating customer: %v", err))
+			route.Problem(w, fmt.Errorf("updating customer: %v", err))
 			return
 		}
 
-		if err := repo.replaceCustomerMetadata(cust.CustomerID, cust.Metadata); err != nil {
+		if err := repo.replaceCustomerMetadata(r.Context(), cust.CustomerID, cust.Metadata); err != nil {
 			logger.LogErrorf("error updating metadata for customer=%s: %v", cust.CustomerID, err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
+		if err := rejectDeniedSSN(r.Context(), logger, repo, cust.CustomerID, organization, ssn); err != nil {
+			logger.LogErrorf("problem checking SSN denylist for customer=%s: %v", cust.CustomerID, err)
+		}
+
 		logger.Logf("updated customer=%s", cust.CustomerID)
-		cust, err = repo.GetCustomer(cust.CustomerID, organization)
+		cust, err = repo.GetCustomer(r.Context(), cust.CustomerID, organization)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
+		if err := setETag(r.Context(), w, repo, cust.CustomerID, organization); err != nil {
+			logger.LogErrorf("error reading version of customer=%s: %v", cust.CustomerID, err)
+		}
 
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(cust)
@@ -521,15 +767,57 @@ type replaceMetadataRequest struct {
 	Metadata map[string]string `json:"metadata"`
 }
 
+func getCustomerMetadata(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+
+		customerID := route.GetCustomerID(w, r)
+		if customerID == "" {
+			return
+		}
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		cust, err := repo.GetCustomer(r.Context(), customerID, organization)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if cust == nil {
+			route.NotFound(w, r)
+			return
+		}
+
+		metadata, err := repo.getMetadata(r.Context(), []string{customerID})
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		meta := metadata[customerID]
+		if meta.Metadata == nil {
+			meta.Metadata = make(map[string]string)
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(meta)
+	}
+}
+
 func replaceCustomerMetadata(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
 		var req replaceMetadataRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		if err := validateMetadata(req.Metadata); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		organization := route.GetOrganization(w, r)
@@ -541,77 +829,190 @@ func replaceCustomerMetadata(logger log.Logger, repo CustomerRepository) http.Ha
 		if customerID == "" {
 			return
 		}
-		if err := repo.replaceCustomerMetadata(customerID, req.Metadata); err != nil {
-			moovhttp.Problem(w, err)
+		if err := repo.replaceCustomerMetadata(r.Context(), customerID, req.Metadata); err != nil {
+			route.Problem(w, err)
 			return
 		}
 
-		respondWithCustomer(logger, w, customerID, organization, requestID, repo)
+		respondWithCustomer(r.Context(), logger, w, customerID, organization, requestID, repo)
 	}
 }
 
 type CustomerRepository interface {
-	GetCustomer(customerID, organization string) (*client.Customer, error)
-	CreateCustomer(c *client.Customer, organization string) error
-	updateCustomer(c *client.Customer, organization string) error
-	updateCustomerStatus(customerID string, status client.CustomerStatus, comment string) error
-	deleteCustomer(customerID string) error
-
-	searchCustomers(params SearchParams) ([]*client.Customer, error)
+	GetCustomer(ctx context.Context, customerID, organization string) (*client.Customer, error)
+	CreateCustomer(ctx context.Context, c *client.Customer, organization string) error
+	createDedupedCustomer(ctx context.Context, c *client.Customer, ssn *SSN, organization string, reject bool) (*duplicateCustomer, error)
+	createCustomers(ctx context.Context, customers []*client.Customer, organization string) error
+	updateCustomer(ctx context.Context, c *client.Customer, ssn *SSN, organization string, version int64) error
+	getCustomerVersion(ctx context.Context, customerID, organization string) (int64, error)
+	updateCustomerStatus(ctx context.Context, customerID string, status client.CustomerStatus, comment, actor string) error
+	getStatusHistory(ctx context.Context, customerID string) ([]StatusUpdate, error)
+	listStatusHistory(ctx context.Context, customerID string, params StatusHistoryParams) ([]StatusUpdate, error)
+	countStatusHistory(ctx context.Context, customerID string, params StatusHistoryParams) (int64, error)
+	deleteCustomer(ctx context.Context, customerID string) error
+
+	searchCustomers(ctx context.Context, params SearchParams) ([]*client.Customer, error)
+	countCustomers(ctx context.Context, params SearchParams) (int64, error)
+	countCustomersByStatus(ctx context.Context, organization string) (map[client.CustomerStatus]int64, error)
+	countCustomersCreatedByDay(ctx context.Context, organization string, start, end time.Time) (map[string]int64, error)
+
+	getMetadata(ctx context.Context, customerIDs []string) (map[string]client.CustomerMetadata, error)
+	replaceCustomerMetadata(ctx context.Context, customerID string, metadata map[string]string) error
+
+	GetRepresentative(ctx context.Context, representativeID string) (*client.Representative, error)
+	listRepresentatives(ctx context.Context, customerID string) ([]client.Representative, error)
+	CreateRepresentative(ctx context.Context, c *client.Representative, customerID string) error
+	updateRepresentative(ctx context.Context, c *client.Representative, customerID string) error
+	updateRepresentativeStatus(ctx context.Context, representativeID, customerID string, status client.RepresentativeStatus, comment, actor string) error
+	deleteRepresentative(ctx context.Context, representativeID string) error
+
+	addAddress(ctx context.Context, ownerID string, ownerType client.OwnerType, address address) error
+	updateAddress(ctx context.Context, ownerID, addressID string, ownerType client.OwnerType, req updateAddressRequest) error
+	deleteAddress(ctx context.Context, ownerID string, ownerType client.OwnerType, addressID string) error
+	setPrimaryAddress(ctx context.Context, ownerID string, ownerType client.OwnerType, addressID string) error
+
+	getLatestCustomerOFACSearch(ctx context.Context, customerID, organization string) (*client.OfacSearch, error)
+	getCustomerOFACSearches(ctx context.Context, customerID, organization string) ([]*client.OfacSearch, error)
+	saveCustomerOFACSearch(ctx context.Context, customerID string, result client.OfacSearch) error
+	searchOFACMatches(ctx context.Context, params OFACMatchParams) ([]*OFACMatch, error)
+	countOFACMatches(ctx context.Context, params OFACMatchParams) (int64, error)
+
+	getLatestRepresentativeOFACSearch(ctx context.Context, representativeID string) (*client.OfacSearch, error)
+	saveRepresentativeOFACSearch(ctx context.Context, representativeID string, result client.OfacSearch) error
+
+	reserveIdempotencyKey(ctx context.Context, key, organization, customerID string) (*idempotencyRecord, error)
+	getIdempotencyKey(ctx context.Context, key, organization string) (*idempotencyRecord, error)
+	completeIdempotencyKey(ctx context.Context, key, organization string) error
+	releaseIdempotencyKey(ctx context.Context, key, organization string) error
+
+	getTaxForms(ctx context.Context, customerID string, now time.Time) ([]string, error)
+}
 
-	replaceCustomerMetadata(customerID string, metadata map[string]string) error
+// NewCustomerRepo returns a CustomerRepository which stores emails and phone numbers in plaintext.
+// db is a *sql.DB or a *sql.Tx to write to Customers along with other tables in one transaction.
+func NewCustomerRepo(logger log.Logger, db customersdb.Querier) CustomerRepository {
+	return NewCustomerRepoWithEncryption(logger, db, nil)
+}
 
-	GetRepresentative(representativeID string) (*client.Representative, error)
-	CreateRepresentative(c *client.Representative, customerID string) error
-	updateRepresentative(c *client.Representative, customerID string) error
-	deleteRepresentative(representativeID string) error
+var (
+	customersCreated = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "customers_created",
+		Help: "Counter of Customers created",
+	}, []string{"type"})
+
+	customerStatusChanges = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "customer_status_transitions",
+		Help: "Counter of Customer status changes by their previous and new status",
+	}, []string{"from", "to"})
+
+	ofacSearches = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "ofac_searches",
+		Help: "Counter of OFAC searches saved for Customers and Representatives by their result",
+	}, []string{"owner", "result"})
+
+	ofacMatchScores = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Name:    "ofac_match_scores",
+		Help:    "Histogram of the highest OFAC match score from each search",
+		Buckets: []float64{0.5, 0.6, 0.7, 0.8, 0.85, 0.9, 0.95, 0.99, 1.0},
+	}, []string{"owner"})
+)
 
-	addAddress(ownerID string, ownerType client.OwnerType, address address) error
-	updateAddress(ownerID, addressID string, ownerType client.OwnerType, req updateAddressRequest) error
-	deleteAddress(ownerID string, ownerType client.OwnerType, addressID string) error
+type sqlCustomerRepository struct {
+	// db is a *sql.DB or a *sql.Tx when the repository writes inside a caller's transaction
+	db     customersdb.Querier
+	logger log.Logger
 
-	getLatestCustomerOFACSearch(customerID, organization string) (*client.OfacSearch, error)
-	saveCustomerOFACSearch(customerID string, result client.OfacSearch) error
+	// fields encrypts emails and phone numbers, they're stored in plaintext when nil
+	fields *secrets.FieldEncryptor
 }
 
-func NewCustomerRepo(logger log.Logger, db *sql.DB) CustomerRepository {
-	return &sqlCustomerRepository{
-		db:     db,
-		logger: logger,
+func (r *sqlCustomerRepository) close() error {
+	if db, ok := r.db.(*sql.DB); ok {
+		return db.Close()
 	}
+	return nil
 }
 
-type sqlCustomerRepository struct {
-	db     *sql.DB
-	logger log.Logger
-}
+// deleteCustomer marks the Customer as deleted along with their phones, addresses, representatives,
+// documents and emails. Rows are never removed so we retain an audit trail.
+func (r *sqlCustomerRepository) deleteCustomer(ctx context.Context, customerID string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
 
-func (r *sqlCustomerRepository) close() error {
-	return r.db.Close()
+	return customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		return r.deleteCustomerTx(ctx, tx, customerID)
+	})
 }
 
-func (r *sqlCustomerRepository) deleteCustomer(customerID string) error {
-	query := `update customers set deleted_at = ? where customer_id = ? and deleted_at is null;`
-	stmt, err := r.db.Prepare(query)
-	if err != nil {
-		return err
+func (r *sqlCustomerRepository) deleteCustomerTx(ctx context.Context, tx *sql.Tx, customerID string) error {
+	now := time.Now()
+	queries := []string{
+		`update customers set deleted_at = ? where customer_id = ? and deleted_at is null;`,
+		`update phones set deleted_at = ?, last_modified = ? where owner_type = 'representative' and deleted_at is null and owner_id in (select representative_id from representatives where customer_id = ?);`,
+		`update addresses set deleted_at = ?, last_modified = ? where owner_type = 'representative' and deleted_at is null and owner_id in (select representative_id from representatives where customer_id = ?);`,
+		`update representatives set deleted_at = ? where customer_id = ? and deleted_at is null;`,
+		`update phones set deleted_at = ?, last_modified = ? where owner_type = 'customer' and owner_id = ? and deleted_at is null;`,
+		`update addresses set deleted_at = ?, last_modified = ? where owner_type = 'customer' and owner_id = ? and deleted_at is null;`,
+		`update documents set deleted_at = ? where customer_id = ? and deleted_at is null;`,
+		`update customers_emails set deleted_at = ?, last_modified = ? where customer_id = ? and deleted_at is null;`,
+	}
+	for i := range queries {
+		stmt, err := tx.PrepareContext(ctx, queries[i])
+		if err != nil {
+			return fmt.Errorf("deleteCustomer: prepare: %v", err)
+		}
+		args := []interface{}{now, customerID}
+		if strings.Contains(queries[i], "last_modified = ?") {
+			args = []interface{}{now, now, customerID}
+		}
+		_, err = stmt.ExecContext(ctx, args...)
+		stmt.Close()
+		if err != nil {
+			return fmt.Errorf("deleteCustomer: exec: %v", err)
+		}
 	}
-	defer stmt.Close()
+	return nil
+}
+
+func (r *sqlCustomerRepository) CreateCustomer(ctx context.Context, c *client.Customer, organization string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
 
-	_, err = stmt.Exec(time.Now(), customerID)
+	err := customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		return r.insertCustomer(ctx, tx, c, organization)
+	})
+	if err == nil {
+		customersCreated.With("type", string(c.Type)).Add(1)
+	}
 	return err
 }
 
-func (r *sqlCustomerRepository) CreateCustomer(c *client.Customer, organization string) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
+// createCustomers inserts each Customer in one transaction, so either all or none are saved.
+func (r *sqlCustomerRepository) createCustomers(ctx context.Context, customers []*client.Customer, organization string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	err := customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		for i := range customers {
+			if err := r.insertCustomer(ctx, tx, customers[i], organization); err != nil {
+				return fmt.Errorf("createCustomers: customer=%s: %v", customers[i].CustomerID, err)
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		for i := range customers {
+			customersCreated.With("type", string(customers[i].Type)).Add(1)
+		}
 	}
+	return err
+}
 
+func (r *sqlCustomerRepository) insertCustomer(ctx context.Context, tx *sql.Tx, c *client.Customer, organization string) error {
 	// Insert customer record
-	query := `insert into customers (customer_id, first_name, middle_name, last_name, nick_name, suffix, type, business_name, doing_business_as, business_type, ein, duns, sic_code, naics_code, birth_date, status, email, website, date_business_established, created_at, last_modified, organization)
-values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
-	stmt, err := tx.Prepare(query)
+	query := `insert into customers (customer_id, first_name, middle_name, last_name, nick_name, suffix, type, business_name, doing_business_as, business_type, ein, duns, sic_code, naics_code, birth_date, status, email, encrypted_email, website, date_business_established, locale, created_at, last_modified, organization)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
@@ -622,51 +1023,77 @@ values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
 		birthDate = &c.BirthDate
 	}
 
+	email, encryptedEmail, err := r.emailColumns(c.Email)
+	if err != nil {
+		return fmt.Errorf("CreateCustomer: %v", err)
+	}
+
 	now := time.Now()
-	_, err = stmt.Exec(c.CustomerID, c.FirstName, c.MiddleName, c.LastName, c.NickName, c.Suffix, c.Type, c.BusinessName, c.DoingBusinessAs, c.BusinessType, c.EIN, c.DUNS, c.SICCode, c.NAICSCode, birthDate, client.CUSTOMERSTATUS_UNKNOWN, c.Email, c.Website, c.DateBusinessEstablished, now, now, organization)
+	_, err = stmt.ExecContext(ctx, c.CustomerID, c.FirstName, c.MiddleName, c.LastName, c.NickName, c.Suffix, c.Type, c.BusinessName, c.DoingBusinessAs, c.BusinessType, c.EIN, c.DUNS, c.SICCode, c.NAICSCode, birthDate, client.CUSTOMERSTATUS_UNKNOWN, email, encryptedEmail, c.Website, c.DateBusinessEstablished, customerLocale(c.Locale), now, now, organization)
 	if err != nil {
-		return fmt.Errorf("CreateCustomer: insert into customers err=%v | rollback=%v", err, tx.Rollback())
+		return fmt.Errorf("CreateCustomer: insert into customers: %v", err)
+	}
+	if err := r.syncPrimaryEmail(ctx, tx, c.CustomerID, c.Email); err != nil {
+		return fmt.Errorf("CreateCustomer: %v", err)
 	}
 
-	err = r.updatePhonesByOwnerID(tx, c.CustomerID, client.OWNERTYPE_CUSTOMER, c.Phones)
+	err = r.updatePhonesByOwnerID(ctx, tx, c.CustomerID, client.OWNERTYPE_CUSTOMER, c.Phones)
 	if err != nil {
 		return fmt.Errorf("updating customer's phones: %v", err)
 	}
 
-	err = r.updateAddressesByOwnerID(tx, c.CustomerID, client.OWNERTYPE_CUSTOMER, c.Addresses)
+	err = r.updateAddressesByOwnerID(ctx, tx, c.CustomerID, client.OWNERTYPE_CUSTOMER, c.Addresses)
 	if err != nil {
 		return fmt.Errorf("updating customer's addresses: %v", err)
 	}
 
-	err = r.updateRepresentativesByCustomerID(tx, c.CustomerID, c.Representatives)
+	err = r.updateRepresentativesByCustomerID(ctx, tx, c.CustomerID, c.Representatives)
 	if err != nil {
 		return fmt.Errorf("updating customer's representatives: %v", err)
 	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("CreateCustomer: tx.Commit: %v", err)
-	}
 	return nil
 }
 
-func (r *sqlCustomerRepository) updateCustomer(c *client.Customer, organization string) error {
-	tx, err := r.db.Begin()
-	if err != nil {
+// updateCustomer saves the Customer if they're still at version, or at any version when it's
+// anyVersion, and increments their version. A non-nil ssn replaces their SSN in the same transaction
+// so it's only changed when the version matches.
+func (r *sqlCustomerRepository) updateCustomer(ctx context.Context, c *client.Customer, ssn *SSN, organization string, version int64) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	return customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		return r.updateCustomerTx(ctx, tx, c, ssn, organization, version)
+	})
+}
+
+// updateCustomerTx leaves the Customer's status alone, it only changes with updateCustomerStatus
+// so every transition is checked and recorded.
+func (r *sqlCustomerRepository) updateCustomerTx(ctx context.Context, tx *sql.Tx, c *client.Customer, ssn *SSN, organization string, version int64) error {
+	if err := r.checkEmailAvailable(ctx, tx, c.CustomerID, c.Email); err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	query := `update customers set first_name = ?, middle_name = ?, last_name = ?, nick_name = ?, suffix = ?, type = ?, business_name = ?, doing_business_as = ?, business_type = ?, ein = ?, duns = ?, sic_code = ?, naics_code = ?, birth_date = ?, status = ?, email =?,
-	website = ?, date_business_established = ?, last_modified = ?,
-	organization = ? where customer_id = ? and deleted_at is null;`
-	stmt, err := tx.Prepare(query)
+	query := `update customers set first_name = ?, middle_name = ?, last_name = ?, nick_name = ?, suffix = ?, type = ?, business_name = ?, doing_business_as = ?, business_type = ?, ein = ?, duns = ?, sic_code = ?, naics_code = ?, birth_date = ?, email = ?, encrypted_email = ?,
+	website = ?, date_business_established = ?, locale = ?, last_modified = ?,
+	organization = ?, version = version + 1 where customer_id = ? and deleted_at is null and (? = 0 or version = ?);`
+	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
+	var birthDate *string
+	if c.BirthDate != "" {
+		birthDate = &c.BirthDate
+	}
+
+	email, encryptedEmail, err := r.emailColumns(c.Email)
+	if err != nil {
+		return fmt.Errorf("updating customer: %v", err)
+	}
+
 	now := time.Now()
-	res, err := stmt.Exec(c.FirstName, c.MiddleName, c.LastName, c.NickName, c.Suffix, c.Type, c.BusinessName, c.DoingBusinessAs, c.BusinessType, c.EIN, c.DUNS, c.SICCode, c.NAICSCode, c.BirthDate, c.Status, c.Email, c.Website, c.DateBusinessEstablished, now, organization, c.CustomerID)
+	res, err := stmt.ExecContext(ctx, c.FirstName, c.MiddleName, c.LastName, c.NickName, c.Suffix, c.Type, c.BusinessName, c.DoingBusinessAs, c.BusinessType, c.EIN, c.DUNS, c.SICCode, c.NAICSCode, birthDate, email, encryptedEmail, c.Website, c.DateBusinessEstablished, customerLocale(c.Locale), now, organization, c.CustomerID, version, version)
 	if err != nil {
 		return fmt.Errorf("updating customer: %v", err)
 	}
@@ -677,63 +1104,93 @@ func (r *sqlCustomerRepository) updateCustomer(c *client.Customer, organization
 	}
 
 	if numRows == 0 {
+		var exists int
+		err := tx.QueryRowContext(ctx, `select count(*) from customers where customer_id = ? and deleted_at is null;`, c.CustomerID).Scan(&exists)
+		if err == nil && exists > 0 {
+			return errVersionMismatch
+		}
 		return fmt.Errorf("no records to update with customer id=%s", c.CustomerID)
 	}
+	if err := r.saveSSNTx(tx, ssn); err != nil {
+		return err
+	}
+	if err := r.syncPrimaryEmail(ctx, tx, c.CustomerID, c.Email); err != nil {
+		return fmt.Errorf("updating customer: %v", err)
+	}
 
-	err = r.updatePhonesByOwnerID(tx, c.CustomerID, client.OWNERTYPE_CUSTOMER, c.Phones)
+	err = r.updatePhonesByOwnerID(ctx, tx, c.CustomerID, client.OWNERTYPE_CUSTOMER, c.Phones)
 	if err != nil {
 		return fmt.Errorf("updating customer's phones: %v", err)
 	}
 
-	err = r.updateAddressesByOwnerID(tx, c.CustomerID, client.OWNERTYPE_CUSTOMER, c.Addresses)
+	err = r.updateAddressesByOwnerID(ctx, tx, c.CustomerID, client.OWNERTYPE_CUSTOMER, c.Addresses)
 	if err != nil {
 		return fmt.Errorf("updating customer's addresses: %v", err)
 	}
 
-	err = r.updateRepresentativesByCustomerID(tx, c.CustomerID, c.Representatives)
+	err = r.updateRepresentativesByCustomerID(ctx, tx, c.CustomerID, c.Representatives)
 	if err != nil {
 		return fmt.Errorf("updating customer's representatives: %v", err)
 	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("CreateCustomer: tx.Commit: %v", err)
-	}
 	return nil
 }
 
-func (r *sqlCustomerRepository) updatePhonesByOwnerID(tx *sql.Tx, ownerID string, ownerType client.OwnerType, phones []client.Phone) error {
-	deleteQuery := `delete from phones where owner_id = ? and owner_type = ?`
-	var args []interface{}
-	args = append(args, ownerID, ownerType)
-	if len(phones) > 0 {
-		deleteQuery = fmt.Sprintf("%s and number not in (?%s)", deleteQuery, strings.Repeat(",?", len(phones)-1))
-		for _, p := range phones {
-			args = append(args, p.Number)
-		}
+// saveSSNTx saves ssn in tx so it's written along with the Customer. Nothing is saved when ssn is nil.
+func (r *sqlCustomerRepository) saveSSNTx(tx *sql.Tx, ssn *SSN) error {
+	if ssn == nil {
+		return nil
 	}
-	deleteQuery = fmt.Sprintf("%s;", deleteQuery)
-
-	stmt, err := tx.Prepare(deleteQuery)
-	if err != nil {
-		return fmt.Errorf("preparing query: %v", err)
+	if err := NewCustomerSSNRepository(r.logger, tx).saveSSN(ssn); err != nil {
+		return fmt.Errorf("saving customer's SSN: %v", err)
 	}
-	defer stmt.Close()
+	return nil
+}
 
-	_, err = stmt.Exec(args...)
+// updatePhonesByOwnerID saves phones for the owner. Numbers which are no longer present are marked
+// as deleted rather than removed so changes to contact information can be audited.
+func (r *sqlCustomerRepository) updatePhonesByOwnerID(ctx context.Context, tx *sql.Tx, ownerID string, ownerType client.OwnerType, phones []client.Phone) error {
+	// encrypted numbers can't be compared in SQL, so the owner's phones are matched after they're read
+	existing, err := r.readStoredPhones(tx, ownerID, ownerType)
 	if err != nil {
-		return fmt.Errorf("executing query: %v", err)
+		return err
 	}
 
-	replaceQuery := `replace into phones (owner_id, owner_type, number, valid, type) values (?, ?, ?, ?, ?);`
-	stmt, err = tx.Prepare(replaceQuery)
-	if err != nil {
-		return fmt.Errorf("preparing query: %v", err)
+	now := time.Now()
+	keep := make(map[string]bool)
+	for _, p := range phones {
+		keep[p.Number] = true
+	}
+	for number, prev := range existing {
+		if keep[number] {
+			continue
+		}
+		where, arg := prev.where()
+		query := `update phones set deleted_at = ?, last_modified = ? where owner_id = ? and owner_type = ? and ` + where + ` and deleted_at is null;`
+		if _, err := tx.ExecContext(ctx, query, now, now, ownerID, ownerType, arg); err != nil {
+			return fmt.Errorf("executing query: %v", err)
+		}
 	}
-	defer stmt.Close()
 
 	for _, phone := range phones {
-		_, err := stmt.Exec(ownerID, string(ownerType), phone.Number, phone.Valid, phone.Type)
-		if err != nil {
+		prev, ok := existing[phone.Number]
+		if !ok {
+			// replace overwrites a deleted row with the same plaintext number
+			number, encrypted, err := r.phoneColumns(phone.Number)
+			if err != nil {
+				return err
+			}
+			query := `replace into phones (owner_id, owner_type, number, encrypted_number, valid, type, created_at, last_modified) values (?, ?, ?, ?, ?, ?, ?, ?);`
+			if _, err := tx.ExecContext(ctx, query, ownerID, string(ownerType), number, encrypted, phone.Valid, phone.Type, now, now); err != nil {
+				return fmt.Errorf("executing update on customer's phone: %v", err)
+			}
+			continue
+		}
+		if prev.Valid == phone.Valid && prev.Type == phone.Type {
+			continue
+		}
+		where, arg := prev.where()
+		query := `update phones set valid = ?, type = ?, last_modified = ? where owner_id = ? and owner_type = ? and ` + where + ` and deleted_at is null;`
+		if _, err := tx.ExecContext(ctx, query, phone.Valid, phone.Type, now, ownerID, ownerType, arg); err != nil {
 			return fmt.Errorf("executing update on customer's phone: %v", err)
 		}
 	}
@@ -741,7 +1198,7 @@ func (r *sqlCustomerRepository) updatePhonesByOwnerID(tx *sql.Tx, ownerID string
 	return nil
 }
 
-func (r *sqlCustomerRepository) updateAddressesByOwnerID(tx *sql.Tx, ownerID string, ownerType client.OwnerType, addresses []client.Address) error {
+func (r *sqlCustomerRepository) updateAddressesByOwnerID(ctx context.Context, tx *sql.Tx, ownerID string, ownerType client.OwnerType, addresses []client.Address) error {
 	deleteQuery := `delete from addresses where owner_id = ? and owner_type = ?`
 	var args []interface{}
 	args = append(args, ownerID, ownerType)
@@ -753,26 +1210,55 @@ func (r *sqlCustomerRepository) updateAddressesByOwnerID(tx *sql.Tx, ownerID str
 	}
 	deleteQuery = fmt.Sprintf("%s;", deleteQuery)
 
-	stmt, err := tx.Prepare(deleteQuery)
+	stmt, err := tx.PrepareContext(ctx, deleteQuery)
 	if err != nil {
 		return fmt.Errorf("preparing query: %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(args...)
+	_, err = stmt.ExecContext(ctx, args...)
 	if err != nil {
 		panic(err)
 	}
 
-	replaceQuery := `replace into addresses(address_id, owner_id, owner_type, type, address1, address2, city, state, postal_code, country, validated) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
-	stmt, err = tx.Prepare(replaceQuery)
+	// replacing a row resets it, so read the timestamps of addresses the owner already has
+	existing := make(map[string]client.Address)
+	rows, err := tx.QueryContext(ctx, `select address_id, type, address1, address2, city, state, postal_code, country, validated, created_at, last_modified from addresses where owner_id = ? and owner_type = ? and deleted_at is null;`, ownerID, ownerType)
+	if err != nil {
+		return fmt.Errorf("reading addresses: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a client.Address
+		var createdAt, lastModified *time.Time
+		if err := rows.Scan(&a.AddressID, &a.Type, &a.Address1, &a.Address2, &a.City, &a.State, &a.PostalCode, &a.Country, &a.Validated, &createdAt, &lastModified); err != nil {
+			return fmt.Errorf("reading addresses: %v", err)
+		}
+		a.CreatedAt, a.LastModified = readTimestamps(createdAt, lastModified)
+		existing[a.AddressID] = a
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading addresses: %v", err)
+	}
+	rows.Close()
+
+	replaceQuery := `replace into addresses(address_id, owner_id, owner_type, type, address1, address2, city, state, postal_code, country, validated, created_at, last_modified) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	stmt, err = tx.PrepareContext(ctx, replaceQuery)
 	if err != nil {
 		return fmt.Errorf("preparing query: %v", err)
 	}
 	defer stmt.Close()
 
+	now := time.Now()
 	for _, addr := range addresses {
-		_, err := stmt.Exec(addr.AddressID, ownerID, string(ownerType), addr.Type, addr.Address1, addr.Address2, addr.City, addr.State, addr.PostalCode, addr.Country, addr.Validated)
+		createdAt, lastModified := now, now
+		if prev, ok := existing[addr.AddressID]; ok && !prev.CreatedAt.IsZero() {
+			createdAt = prev.CreatedAt
+			if sameAddress(prev, addr) {
+				lastModified = prev.LastModified
+			}
+		}
+		_, err := stmt.ExecContext(ctx, addr.AddressID, ownerID, string(ownerType), addr.Type, addr.Address1, addr.Address2, addr.City, addr.State, addr.PostalCode, addr.Country, addr.Validated, createdAt, lastModified)
 		if err != nil {
 			return fmt.Errorf("executing query: %v", err)
 		}
@@ -781,29 +1267,37 @@ func (r *sqlCustomerRepository) updateAddressesByOwnerID(tx *sql.Tx, ownerID str
 	return nil
 }
 
-func (r *sqlCustomerRepository) updateRepresentativesByCustomerID(tx *sql.Tx, customerID string, representatives []client.Representative) error {
+// sameAddress returns true if the stored fields of the addresses match, ignoring timestamps
+func sameAddress(a, b client.Address) bool {
+	a.OwnerType, b.OwnerType = "", ""
+	a.CreatedAt, b.CreatedAt = time.Time{}, time.Time{}
+	a.LastModified, b.LastModified = time.Time{}, time.Time{}
+	return a == b
+}
+
+func (r *sqlCustomerRepository) updateRepresentativesByCustomerID(ctx context.Context, tx *sql.Tx, customerID string, representatives []client.Representative) error {
 	deleteQuery := `delete from representatives where customer_id = ?;`
 
-	stmt, err := tx.Prepare(deleteQuery)
+	stmt, err := tx.PrepareContext(ctx, deleteQuery)
 	if err != nil {
 		return fmt.Errorf("preparing query: %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(customerID)
+	_, err = stmt.ExecContext(ctx, customerID)
 	if err != nil {
 		panic(err)
 	}
 
-	replaceQuery := `replace into representatives(representative_id, customer_id, first_name, last_name, job_title, birth_date) values (?, ?, ?, ?, ?, ?);`
-	stmt, err = tx.Prepare(replaceQuery)
+	replaceQuery := `replace into representatives(representative_id, customer_id, first_name, last_name, job_title, birth_date, ownership_percentage) values (?, ?, ?, ?, ?, ?, ?);`
+	stmt, err = tx.PrepareContext(ctx, replaceQuery)
 	if err != nil {
 		return fmt.Errorf("preparing query: %v", err)
 	}
 	defer stmt.Close()
 
 	for _, rep := range representatives {
-		_, err := stmt.Exec(rep.RepresentativeID, customerID, rep.FirstName, rep.LastName, rep.JobTitle, rep.BirthDate)
+		_, err := stmt.ExecContext(ctx, rep.RepresentativeID, customerID, rep.FirstName, rep.LastName, rep.JobTitle, rep.BirthDate, rep.OwnershipPercentage)
 		if err != nil {
 			return fmt.Errorf("executing query: %v", err)
 		}
@@ -812,113 +1306,225 @@ func (r *sqlCustomerRepository) updateRepresentativesByCustomerID(tx *sql.Tx, cu
 	return nil
 }
 
-func (r *sqlCustomerRepository) GetCustomer(customerID, organization string) (*client.Customer, error) {
-	custs, err := r.searchCustomers(SearchParams{
+var errCustomerNotFound = route.NewError(http.StatusNotFound, route.CodeNotFound, "customer not found")
+
+func (r *sqlCustomerRepository) GetCustomer(ctx context.Context, customerID, organization string) (*client.Customer, error) {
+	custs, err := r.searchCustomers(ctx, SearchParams{
 		Count:        1,
 		CustomerIDs:  []string{customerID},
 		Organization: organization,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("getting customer: %v", err)
+		return nil, fmt.Errorf("getting customer: %w", err)
 	}
 
 	if len(custs) == 0 {
-		return nil, errors.New("customer not found")
+		return nil, errCustomerNotFound
+	}
+
+	cust := custs[0]
+	if cust.MissingTaxForms, err = missingTaxForms(ctx, r, cust); err != nil {
+		return nil, fmt.Errorf("getting customer: %w", err)
 	}
+	return cust, nil
+}
+
+func (r *sqlCustomerRepository) updateCustomerStatus(ctx context.Context, customerID string, status client.CustomerStatus, comment, actor string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
 
-	return custs[0], nil
+	var previous client.CustomerStatus
+	err := customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		var err error
+		previous, err = r.updateCustomerStatusTx(ctx, tx, customerID, status, comment, actor)
+		return err
+	})
+	if err == nil {
+		customerStatusChanges.With("from", string(previous), "to", string(status)).Add(1)
+	}
+	return err
 }
 
-func (r *sqlCustomerRepository) updateCustomerStatus(customerID string, status client.CustomerStatus, comment string) error {
-	tx, err := r.db.Begin()
+// updateCustomerStatusTx sets the Customer's status and records the change, returning the status
+// which was replaced.
+func (r *sqlCustomerRepository) updateCustomerStatusTx(ctx context.Context, tx *sql.Tx, customerID string, status client.CustomerStatus, comment, actor string) (client.CustomerStatus, error) {
+	var previous client.CustomerStatus
+	stmt, err := tx.PrepareContext(ctx, `select status from customers where customer_id = ?;`)
 	if err != nil {
-		return fmt.Errorf("updateCustomerStatus: tx begin: %v", err)
+		return previous, fmt.Errorf("updateCustomerStatus: select status prepare: %v", err)
+	}
+	if err := stmt.QueryRowContext(ctx, customerID).Scan(&previous); err != nil && err != sql.ErrNoRows {
+		stmt.Close()
+		return previous, fmt.Errorf("updateCustomerStatus: select status scan: %v", err)
 	}
+	stmt.Close()
 
 	// update 'customers' table
-	query := `update customers set status = ? where customer_id = ?;`
-	stmt, err := tx.Prepare(query)
+	query := `update customers set status = ?, last_modified = ?, version = version + 1 where customer_id = ?;`
+	stmt, err = tx.PrepareContext(ctx, query)
 	if err != nil {
-		return fmt.Errorf("updateCustomerStatus: update customers prepare: %v", err)
+		return previous, fmt.Errorf("updateCustomerStatus: update customers prepare: %v", err)
 	}
-	if _, err := stmt.Exec(status, customerID); err != nil {
+	if _, err := stmt.ExecContext(ctx, status, time.Now(), customerID); err != nil {
 		stmt.Close()
-		return fmt.Errorf("updateCustomerStatus: update customers exec: %v", err)
+		return previous, fmt.Errorf("updateCustomerStatus: update customers exec: %v", err)
 	}
 	stmt.Close()
 
 	// update 'customer_status_updates' table
-	query = `insert into customer_status_updates (customer_id, future_status, comment, changed_at) values (?, ?, ?, ?);`
-	stmt, err = tx.Prepare(query)
+	query = `insert into customer_status_updates (customer_id, future_status, comment, actor, changed_at) values (?, ?, ?, ?, ?);`
+	stmt, err = tx.PrepareContext(ctx, query)
 	if err != nil {
-		return fmt.Errorf("updateCustomerStatus: insert status prepare: %v", err)
+		return previous, fmt.Errorf("updateCustomerStatus: insert status prepare: %v", err)
 	}
 	defer stmt.Close()
-	if _, err := stmt.Exec(customerID, status, comment, time.Now()); err != nil {
-		return fmt.Errorf("updateCustomerStatus: insert status exec: %v", err)
+	if _, err := stmt.ExecContext(ctx, customerID, status, comment, actor, time.Now()); err != nil {
+		return previous, fmt.Errorf("updateCustomerStatus: insert status exec: %v", err)
 	}
-	return tx.Commit()
+	return previous, nil
 }
 
-func (r *sqlCustomerRepository) replaceCustomerMetadata(customerID string, metadata map[string]string) error {
-	tx, err := r.db.Begin()
+// getStatusHistory returns each status change of the Customer, oldest first
+func (r *sqlCustomerRepository) getStatusHistory(ctx context.Context, customerID string) ([]StatusUpdate, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select future_status, comment, actor, changed_at from customer_status_updates where customer_id = ? order by changed_at asc;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("getStatusHistory: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, customerID)
 	if err != nil {
-		return fmt.Errorf("replaceCustomerMetadata: tx begin: %v", err)
+		return nil, fmt.Errorf("getStatusHistory: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []StatusUpdate
+	for rows.Next() {
+		var update StatusUpdate
+		var comment, actor *string
+		if err := rows.Scan(&update.Status, &comment, &actor, &update.ChangedAt); err != nil {
+			return nil, fmt.Errorf("getStatusHistory: scan: %v", err)
+		}
+		if comment != nil {
+			update.Comment = *comment
+		}
+		if actor != nil {
+			update.Actor = *actor
+		}
+		out = append(out, update)
 	}
+	return out, rows.Err()
+}
+
+func (r *sqlCustomerRepository) replaceCustomerMetadata(ctx context.Context, customerID string, metadata map[string]string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	return customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		return r.replaceCustomerMetadataTx(ctx, tx, customerID, metadata)
+	})
+}
+
+func (r *sqlCustomerRepository) replaceCustomerMetadataTx(ctx context.Context, tx *sql.Tx, customerID string, metadata map[string]string) error {
+	// Keep the timestamps of existing keys so rewriting the metadata doesn't reset them
+	type metaTimes struct {
+		value                   string
+		createdAt, lastModified time.Time
+	}
+	existing := make(map[string]metaTimes)
+	rows, err := tx.QueryContext(ctx, `select meta_key, meta_value, created_at, last_modified from customer_metadata where customer_id = ?;`, customerID)
+	if err != nil {
+		return fmt.Errorf("replaceCustomerMetadata: select: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var prev metaTimes
+		var createdAt, lastModified *time.Time
+		if err := rows.Scan(&key, &prev.value, &createdAt, &lastModified); err != nil {
+			return fmt.Errorf("replaceCustomerMetadata: scan: %v", err)
+		}
+		prev.createdAt, prev.lastModified = readTimestamps(createdAt, lastModified)
+		existing[key] = prev
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("replaceCustomerMetadata: select: %v", err)
+	}
+	rows.Close()
 
 	// Delete each existing k/v pair
 	query := `delete from customer_metadata where customer_id = ?;`
-	stmt, err := tx.Prepare(query)
+	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("replaceCustomerMetadata: delete prepare: %v", err)
 	}
-	if _, err := stmt.Exec(customerID); err != nil {
+	if _, err := stmt.ExecContext(ctx, customerID); err != nil {
 		stmt.Close()
 		return fmt.Errorf("replaceCustomerMetadata: delete exec: %v", err)
 	}
 	stmt.Close()
 
-	query = `insert into customer_metadata (customer_id, meta_key, meta_value) values (?, ?, ?);`
-	stmt, err = tx.Prepare(query)
+	query = `insert into customer_metadata (customer_id, meta_key, meta_value, created_at, last_modified) values (?, ?, ?, ?, ?);`
+	stmt, err = tx.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("replaceCustomerMetadata: insert prepare: %v", err)
 	}
 	defer stmt.Close()
+	now := time.Now()
 	for k, v := range metadata {
-		if _, err := stmt.Exec(customerID, k, v); err != nil {
+		createdAt, lastModified := now, now
+		if prev, ok := existing[k]; ok && !prev.createdAt.IsZero() {
+			createdAt = prev.createdAt
+			if prev.value == v {
+				lastModified = prev.lastModified
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, customerID, k, v, createdAt, lastModified); err != nil {
+			// customer_metadata has a unique (meta_key, meta_value) constraint
+			if database.UniqueViolation(err) {
+				return route.Conflict(fmt.Errorf("metadata key %s with the same value already exists", k))
+			}
 			return fmt.Errorf("replaceCustomerMetadata: insert %s: %v", k, err)
 		}
 	}
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("replaceCustomerMetadata: commit: %v", err)
-	}
 	return nil
 }
 
-func (r *sqlCustomerRepository) addAddress(ownerID string, ownerType client.OwnerType, req address) error {
-	query := `insert into addresses (address_id, owner_id, owner_type, type, address1, address2, city, state, postal_code, country, validated) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
-	stmt, err := r.db.Prepare(query)
+func (r *sqlCustomerRepository) addAddress(ctx context.Context, ownerID string, ownerType client.OwnerType, req address) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `insert into addresses (address_id, owner_id, owner_type, type, address1, address2, city, state, postal_code, country, validated, created_at, last_modified) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("addAddress: prepare: %v", err)
 	}
 	defer stmt.Close()
 
-	if _, err := stmt.Exec(base.ID(), ownerID, string(ownerType), req.Type, req.Address1, req.Address2, req.City, req.State, req.PostalCode, req.Country, false); err != nil {
+	now := time.Now()
+	if _, err := stmt.ExecContext(ctx, base.ID(), ownerID, string(ownerType), req.Type, req.Address1, req.Address2, req.City, req.State, req.PostalCode, req.Country, req.validated, now, now); err != nil {
 		return fmt.Errorf("addAddress: exec: %v", err)
 	}
 	return nil
 }
 
-func (r *sqlCustomerRepository) updateAddress(ownerID, addressID string, ownerType client.OwnerType, req updateAddressRequest) error {
+func (r *sqlCustomerRepository) updateAddress(ctx context.Context, ownerID, addressID string, ownerType client.OwnerType, req updateAddressRequest) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	query := `update addresses set type = ?, address1 = ?, address2 = ?, city = ?, state = ?, postal_code = ?, country = ?,
-	validated = ? where owner_id = ? and owner_type = ? and address_id = ? and deleted_at is null;`
-	stmt, err := r.db.Prepare(query)
+	validated = ?, last_modified = ? where owner_id = ? and owner_type = ? and address_id = ? and deleted_at is null;`
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("updateAddress: prepare: %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(
+	_, err = stmt.ExecContext(ctx,
 		req.Type,
 		req.Address1,
 		req.Address2,
@@ -927,6 +1533,7 @@ func (r *sqlCustomerRepository) updateAddress(ownerID, addressID string, ownerTy
 		req.PostalCode,
 		req.Country,
 		req.Validated,
+		time.Now(),
 		ownerID,
 		string(ownerType),
 		addressID)
@@ -936,43 +1543,114 @@ func (r *sqlCustomerRepository) updateAddress(ownerID, addressID string, ownerTy
 	return nil
 }
 
-func (r *sqlCustomerRepository) deleteAddress(ownerID string, ownerType client.OwnerType, addressID string) error {
-	query := `update addresses set deleted_at = ? where owner_id = ? and owner_type = ? and address_id = ? and deleted_at is null;`
-	stmt, err := r.db.Prepare(query)
+func (r *sqlCustomerRepository) deleteAddress(ctx context.Context, ownerID string, ownerType client.OwnerType, addressID string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `update addresses set deleted_at = ?, last_modified = ? where owner_id = ? and owner_type = ? and address_id = ? and deleted_at is null;`
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(time.Now(), ownerID, string(ownerType), addressID)
+	now := time.Now()
+	_, err = stmt.ExecContext(ctx, now, now, ownerID, string(ownerType), addressID)
 	return err
 }
 
-func (r *sqlCustomerRepository) getLatestCustomerOFACSearch(customerID, organization string) (*client.OfacSearch, error) {
-	query := `select entity_id, blocked, sdn_name, sdn_type, percentage_match, cos.created_at
+var errAddressNotFound = route.NewError(http.StatusNotFound, route.CodeNotFound, "address not found")
+
+// setPrimaryAddress makes the address the owner's primary address. Their previous primary address
+// becomes secondary in the same transaction so there's at most one primary address.
+func (r *sqlCustomerRepository) setPrimaryAddress(ctx context.Context, ownerID string, ownerType client.OwnerType, addressID string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	return customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		var found string
+		query := `select address_id from addresses where owner_id = ? and owner_type = ? and address_id = ? and deleted_at is null limit 1;`
+		if err := tx.QueryRowContext(ctx, query, ownerID, string(ownerType), addressID).Scan(&found); err != nil {
+			if err == sql.ErrNoRows {
+				return errAddressNotFound
+			}
+			return fmt.Errorf("setPrimaryAddress: select: %v", err)
+		}
+
+		now := time.Now()
+		query = `update addresses set type = ?, last_modified = ? where owner_id = ? and owner_type = ? and type = ? and address_id <> ? and deleted_at is null;`
+		if _, err := tx.ExecContext(ctx, query, client.ADDRESSTYPE_SECONDARY, now, ownerID, string(ownerType), client.ADDRESSTYPE_PRIMARY, addressID); err != nil {
+			return fmt.Errorf("setPrimaryAddress: demote: %v", err)
+		}
+		query = `update addresses set type = ?, last_modified = ? where address_id = ? and type <> ?;`
+		if _, err := tx.ExecContext(ctx, query, client.ADDRESSTYPE_PRIMARY, now, addressID, client.ADDRESSTYPE_PRIMARY); err != nil {
+			return fmt.Errorf("setPrimaryAddress: promote: %v", err)
+		}
+		return nil
+	})
+}
+
+func (r *sqlCustomerRepository) getLatestCustomerOFACSearch(ctx context.Context, customerID, organization string) (*client.OfacSearch, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select entity_id, blocked, review_required, sdn_name, sdn_type, percentage_match, match_threshold, review_threshold, cos.created_at
 from customer_ofac_searches as cos
 inner join customers as c on c.customer_id = cos.customer_id
 where cos.customer_id = ? and c.organization = ? order by cos.created_at desc limit 1;`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("getLatestCustomerOFACSearch: prepare: %v", err)
 	}
 	defer stmt.Close()
 
-	row := stmt.QueryRow(customerID, organization)
-	var res client.OfacSearch
-	if err := row.Scan(&res.EntityID, &res.Blocked, &res.SdnName, &res.SdnType, &res.Match, &res.CreatedAt); err != nil {
+	res, err := scanOFACSearch(stmt.QueryRowContext(ctx, customerID, organization))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // nothing found
 		}
 		return nil, fmt.Errorf("getLatestCustomerOFACSearch: scan: %v", err)
 	}
-	return &res, nil
+	return res, nil
+}
+
+func (r *sqlCustomerRepository) getCustomerOFACSearches(ctx context.Context, customerID, organization string) ([]*client.OfacSearch, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select entity_id, blocked, review_required, sdn_name, sdn_type, percentage_match, match_threshold, review_threshold, cos.created_at
+from customer_ofac_searches as cos
+inner join customers as c on c.customer_id = cos.customer_id
+where cos.customer_id = ? and c.organization = ? order by cos.created_at asc;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("getCustomerOFACSearches: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, customerID, organization)
+	if err != nil {
+		return nil, fmt.Errorf("getCustomerOFACSearches: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []*client.OfacSearch
+	for rows.Next() {
+		res, err := scanOFACSearch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("getCustomerOFACSearches: scan: %v", err)
+		}
+		out = append(out, res)
+	}
+	return out, rows.Err()
 }
 
-func (r *sqlCustomerRepository) saveCustomerOFACSearch(customerID string, result client.OfacSearch) error {
-	query := `insert into customer_ofac_searches (customer_id, blocked, entity_id, sdn_name, sdn_type, percentage_match, created_at) values (?, ?, ?, ?, ?, ?, ?);`
-	stmt, err := r.db.Prepare(query)
+func (r *sqlCustomerRepository) saveCustomerOFACSearch(ctx context.Context, customerID string, result client.OfacSearch) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `insert into customer_ofac_searches (customer_id, blocked, review_required, entity_id, sdn_name, sdn_type, percentage_match, match_threshold, review_threshold, created_at) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("saveCustomerOFACSearch: prepare: %v", err)
 	}
@@ -982,8 +1660,91 @@ func (r *sqlCustomerRepository) saveCustomerOFACSearch(customerID string, result
 		result.CreatedAt = time.Now()
 	}
 
-	if _, err := stmt.Exec(customerID, result.Blocked, result.EntityID, result.SdnName, result.SdnType, result.Match, result.CreatedAt); err != nil {
+	if _, err := stmt.ExecContext(ctx, customerID, result.Blocked, result.ReviewRequired, result.EntityID, result.SdnName, result.SdnType, result.Match, result.MatchThreshold, result.ReviewThreshold, result.CreatedAt); err != nil {
 		return fmt.Errorf("saveCustomerOFACSearch: exec: %v", err)
 	}
+	recordOFACSearch("customer", result)
+	return nil
+}
+
+func (r *sqlCustomerRepository) getLatestRepresentativeOFACSearch(ctx context.Context, representativeID string) (*client.OfacSearch, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select entity_id, blocked, review_required, sdn_name, sdn_type, percentage_match, match_threshold, review_threshold, created_at from representative_ofac_searches
+where representative_id = ? order by created_at desc limit 1;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("getLatestRepresentativeOFACSearch: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	res, err := scanOFACSearch(stmt.QueryRowContext(ctx, representativeID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // nothing found
+		}
+		return nil, fmt.Errorf("getLatestRepresentativeOFACSearch: scan: %v", err)
+	}
+	return res, nil
+}
+
+func (r *sqlCustomerRepository) saveRepresentativeOFACSearch(ctx context.Context, representativeID string, result client.OfacSearch) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `insert into representative_ofac_searches (representative_id, blocked, review_required, entity_id, sdn_name, sdn_type, percentage_match, match_threshold, review_threshold, created_at) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("saveRepresentativeOFACSearch: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	if result.CreatedAt.IsZero() {
+		result.CreatedAt = time.Now()
+	}
+
+	if _, err := stmt.ExecContext(ctx, representativeID, result.Blocked, result.ReviewRequired, result.EntityID, result.SdnName, result.SdnType, result.Match, result.MatchThreshold, result.ReviewThreshold, result.CreatedAt); err != nil {
+		return fmt.Errorf("saveRepresentativeOFACSearch: exec: %v", err)
+	}
+	recordOFACSearch("representative", result)
 	return nil
 }
+
+// recordOFACSearch counts a saved search by whether it blocked, flagged for review or cleared the
+// owner, and observes its match score.
+func recordOFACSearch(owner string, result client.OfacSearch) {
+	outcome := "clear"
+	switch {
+	case result.Blocked:
+		outcome = "blocked"
+	case result.ReviewRequired:
+		outcome = "review"
+	}
+	ofacSearches.With("owner", owner, "result", outcome).Add(1)
+	ofacMatchScores.With("owner", owner).Observe(float64(result.Match))
+}
+
+// scanOFACSearch reads a row of customer_ofac_searches or representative_ofac_searches. Searches saved
+// before thresholds were recorded have null thresholds.
+func scanOFACSearch(row interface {
+	Scan(dest ...interface{}) error
+}) (*client.OfacSearch, error) {
+	var res client.OfacSearch
+	var reviewRequired *bool
+	var matchThreshold, reviewThreshold *float32
+	err := row.Scan(&res.EntityID, &res.Blocked, &reviewRequired, &res.SdnName, &res.SdnType, &res.Match, &matchThreshold, &reviewThreshold, &res.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if reviewRequired != nil {
+		res.ReviewRequired = *reviewRequired
+	}
+	if matchThreshold != nil {
+		res.MatchThreshold = *matchThreshold
+	}
+	if reviewThreshold != nil {
+		res.ReviewThreshold = *reviewThreshold
+	}
+	return &res, nil
+}
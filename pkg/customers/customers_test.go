@@ -16,6 +16,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -27,6 +28,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
 )
 
 var _ CustomerRepository = (*testCustomerRepository)(nil)
@@ -41,36 +43,69 @@ type testCustomerRepository struct {
 	savedSearchResult *client.OfacSearch
 
 	customerRepresentative *client.Representative
+
+	idempotencyRecord *idempotencyRecord
 }
 
-func (r *testCustomerRepository) GetCustomer(customerID, organization string) (*client.Customer, error) {
+func (r *testCustomerRepository) GetCustomer(ctx context.Context, customerID, organization string) (*client.Customer, error) {
 	if r.err != nil {
 		return nil, r.err
 	}
 	return r.customer, nil
 }
 
-func (r *testCustomerRepository) CreateCustomer(c *client.Customer, organization string) error {
+func (r *testCustomerRepository) CreateCustomer(ctx context.Context, c *client.Customer, organization string) error {
+	r.createdCustomer = c
+	return r.err
+}
+
+func (r *testCustomerRepository) createDedupedCustomer(ctx context.Context, c *client.Customer, ssn *SSN, organization string, reject bool) (*duplicateCustomer, error) {
 	r.createdCustomer = c
+	return nil, r.err
+}
+
+func (r *testCustomerRepository) createCustomers(ctx context.Context, customers []*client.Customer, organization string) error {
+	if len(customers) > 0 {
+		r.createdCustomer = customers[len(customers)-1]
+	}
 	return r.err
 }
 
-func (r *testCustomerRepository) deleteCustomer(customerID string) error {
+func (r *testCustomerRepository) deleteCustomer(ctx context.Context, customerID string) error {
 	r.customer = nil
 	return r.err
 }
 
-func (r *testCustomerRepository) updateCustomer(c *client.Customer, organization string) error {
+func (r *testCustomerRepository) updateCustomer(ctx context.Context, c *client.Customer, ssn *SSN, organization string, version int64) error {
 	r.customer = c
 	return r.err
 }
 
-func (r *testCustomerRepository) updateCustomerStatus(customerID string, status client.CustomerStatus, comment string) error {
+func (r *testCustomerRepository) getCustomerVersion(ctx context.Context, customerID, organization string) (int64, error) {
+	if r.customer == nil {
+		return 0, r.err
+	}
+	return 1, r.err
+}
+
+func (r *testCustomerRepository) updateCustomerStatus(ctx context.Context, customerID string, status client.CustomerStatus, comment, actor string) error {
 	r.updatedStatus = status
 	return r.err
 }
 
-func (r *testCustomerRepository) searchCustomers(params SearchParams) ([]*client.Customer, error) {
+func (r *testCustomerRepository) getStatusHistory(ctx context.Context, customerID string) ([]StatusUpdate, error) {
+	return nil, r.err
+}
+
+func (r *testCustomerRepository) listStatusHistory(ctx context.Context, customerID string, params StatusHistoryParams) ([]StatusUpdate, error) {
+	return nil, r.err
+}
+
+func (r *testCustomerRepository) countStatusHistory(ctx context.Context, customerID string, params StatusHistoryParams) (int64, error) {
+	return 0, r.err
+}
+
+func (r *testCustomerRepository) searchCustomers(ctx context.Context, params SearchParams) ([]*client.Customer, error) {
 	if r.err != nil {
 		return nil, r.err
 	}
@@ -80,23 +115,53 @@ func (r *testCustomerRepository) searchCustomers(params SearchParams) ([]*client
 	return nil, nil
 }
 
-func (r *testCustomerRepository) replaceCustomerMetadata(customerID string, metadata map[string]string) error {
+func (r *testCustomerRepository) countCustomersByStatus(ctx context.Context, organization string) (map[client.CustomerStatus]int64, error) {
+	return nil, r.err
+}
+
+func (r *testCustomerRepository) countCustomersCreatedByDay(ctx context.Context, organization string, start, end time.Time) (map[string]int64, error) {
+	return nil, r.err
+}
+
+func (r *testCustomerRepository) countCustomers(ctx context.Context, params SearchParams) (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.customer != nil {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (r *testCustomerRepository) getMetadata(ctx context.Context, customerIDs []string) (map[string]client.CustomerMetadata, error) {
+	out := make(map[string]client.CustomerMetadata)
+	if r.customer != nil {
+		out[r.customer.CustomerID] = client.CustomerMetadata{Metadata: r.customer.Metadata}
+	}
+	return out, r.err
+}
+
+func (r *testCustomerRepository) replaceCustomerMetadata(ctx context.Context, customerID string, metadata map[string]string) error {
 	return r.err
 }
 
-func (r *testCustomerRepository) addAddress(ownerID string, ownerType client.OwnerType, address address) error {
+func (r *testCustomerRepository) addAddress(ctx context.Context, ownerID string, ownerType client.OwnerType, address address) error {
 	return r.err
 }
 
-func (r *testCustomerRepository) updateAddress(ownerID, addressID string, ownerType client.OwnerType, req updateAddressRequest) error {
+func (r *testCustomerRepository) updateAddress(ctx context.Context, ownerID, addressID string, ownerType client.OwnerType, req updateAddressRequest) error {
 	return r.err
 }
 
-func (r *testCustomerRepository) deleteAddress(ownerID string, ownerType client.OwnerType, addressID string) error {
+func (r *testCustomerRepository) deleteAddress(ctx context.Context, ownerID string, ownerType client.OwnerType, addressID string) error {
 	return r.err
 }
 
-func (r *testCustomerRepository) getLatestCustomerOFACSearch(customerID, organization string) (*client.OfacSearch, error) {
+func (r *testCustomerRepository) setPrimaryAddress(ctx context.Context, ownerID string, ownerType client.OwnerType, addressID string) error {
+	return r.err
+}
+
+func (r *testCustomerRepository) getLatestCustomerOFACSearch(ctx context.Context, customerID, organization string) (*client.OfacSearch, error) {
 	if r.err != nil {
 		return nil, r.err
 	}
@@ -106,27 +171,88 @@ func (r *testCustomerRepository) getLatestCustomerOFACSearch(customerID, organiz
 	return r.searchResult, nil
 }
 
-func (r *testCustomerRepository) saveCustomerOFACSearch(customerID string, result client.OfacSearch) error {
+func (r *testCustomerRepository) getCustomerOFACSearches(ctx context.Context, customerID, organization string) ([]*client.OfacSearch, error) {
+	search, err := r.getLatestCustomerOFACSearch(ctx, customerID, organization)
+	if search == nil {
+		return nil, err
+	}
+	return []*client.OfacSearch{search}, err
+}
+
+func (r *testCustomerRepository) saveCustomerOFACSearch(ctx context.Context, customerID string, result client.OfacSearch) error {
 	r.savedSearchResult = &result
 	return r.err
 }
 
-func (r *testCustomerRepository) GetRepresentative(representativeID string) (*client.Representative, error) {
+func (r *testCustomerRepository) searchOFACMatches(ctx context.Context, params OFACMatchParams) ([]*OFACMatch, error) {
+	return nil, r.err
+}
+
+func (r *testCustomerRepository) countOFACMatches(ctx context.Context, params OFACMatchParams) (int64, error) {
+	return 0, r.err
+}
+
+func (r *testCustomerRepository) getLatestRepresentativeOFACSearch(ctx context.Context, representativeID string) (*client.OfacSearch, error) {
+	return r.getLatestCustomerOFACSearch(ctx, representativeID, "")
+}
+
+func (r *testCustomerRepository) saveRepresentativeOFACSearch(ctx context.Context, representativeID string, result client.OfacSearch) error {
+	return r.saveCustomerOFACSearch(ctx, representativeID, result)
+}
+
+func (r *testCustomerRepository) reserveIdempotencyKey(ctx context.Context, key, organization, customerID string) (*idempotencyRecord, error) {
+	return r.idempotencyRecord, r.err
+}
+
+func (r *testCustomerRepository) getIdempotencyKey(ctx context.Context, key, organization string) (*idempotencyRecord, error) {
+	return r.idempotencyRecord, r.err
+}
+
+func (r *testCustomerRepository) completeIdempotencyKey(ctx context.Context, key, organization string) error {
+	return r.err
+}
+
+func (r *testCustomerRepository) releaseIdempotencyKey(ctx context.Context, key, organization string) error {
+	return r.err
+}
+
+func (r *testCustomerRepository) getTaxForms(ctx context.Context, customerID string, now time.Time) ([]string, error) {
+	return nil, r.err
+}
+
+func (r *testCustomerRepository) GetRepresentative(ctx context.Context, representativeID string) (*client.Representative, error) {
 	if r.err != nil {
 		return nil, r.err
 	}
 	return r.customerRepresentative, nil
 }
 
-func (r *testCustomerRepository) CreateRepresentative(c *client.Representative, customerID string) error {
+func (r *testCustomerRepository) listRepresentatives(ctx context.Context, customerID string) ([]client.Representative, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.customerRepresentative != nil {
+		return []client.Representative{*r.customerRepresentative}, nil
+	}
+	return nil, nil
+}
+
+func (r *testCustomerRepository) CreateRepresentative(ctx context.Context, c *client.Representative, customerID string) error {
+	return r.err
+}
+
+func (r *testCustomerRepository) updateRepresentative(ctx context.Context, c *client.Representative, customerID string) error {
 	return r.err
 }
 
-func (r *testCustomerRepository) updateRepresentative(c *client.Representative, customerID string) error {
+func (r *testCustomerRepository) updateRepresentativeStatus(ctx context.Context, representativeID, customerID string, status client.RepresentativeStatus, comment, actor string) error {
+	if r.err == nil && r.customerRepresentative != nil {
+		r.customerRepresentative.Status = status
+	}
 	return r.err
 }
 
-func (r *testCustomerRepository) deleteRepresentative(representativeID string) error {
+func (r *testCustomerRepository) deleteRepresentative(ctx context.Context, representativeID string) error {
 	return r.err
 }
 
@@ -166,7 +292,7 @@ func TestCustomers__GetCustomer(t *testing.T) {
 		LastName:  "Doe",
 		Email:     "jane@example.com",
 	}).asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Fatal(err)
 	}
 
@@ -176,7 +302,7 @@ func TestCustomers__GetCustomer(t *testing.T) {
 	req.Header.Set("x-request-id", "test")
 
 	router := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil))
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -202,7 +328,7 @@ func TestCustomers__GetCustomerEmpty(t *testing.T) {
 	req.Header.Set("x-request-id", "test")
 
 	router := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil))
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -223,10 +349,10 @@ func TestCustomers__DeleteCustomer(t *testing.T) {
 	}
 	customer, _, _ := cr.asCustomer(testCustomerSSNStorage(t))
 	require.NoError(t,
-		repo.CreateCustomer(customer, organization),
+		repo.CreateCustomer(context.Background(), customer, organization),
 	)
 
-	got, err := repo.GetCustomer(customer.CustomerID, organization)
+	got, err := repo.GetCustomer(context.Background(), customer.CustomerID, organization)
 	require.NoError(t, err)
 	require.NotNil(t, got)
 
@@ -234,13 +360,13 @@ func TestCustomers__DeleteCustomer(t *testing.T) {
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("DELETE", fmt.Sprintf("/customers/%s", customer.CustomerID), nil)
 
-	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil))
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
 	require.Equal(t, http.StatusNoContent, w.Code)
 	require.Empty(t, w.Body)
-	got, err = repo.GetCustomer(customer.CustomerID, organization)
+	got, err = repo.GetCustomer(context.Background(), customer.CustomerID, organization)
 	require.Error(t, err)
 	require.Nil(t, got)
 }
@@ -253,11 +379,11 @@ func TestCustomerRepository__CreateCustomer(t *testing.T) {
 			LastName:  "Doe",
 			Email:     "jane@example.com",
 		}).asCustomer(testCustomerSSNStorage(t))
-		if err := repo.CreateCustomer(cust, organization); err != nil {
+		if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 			t.Fatal(err)
 		}
 
-		cust, err := repo.GetCustomer(cust.CustomerID, organization)
+		cust, err := repo.GetCustomer(context.Background(), cust.CustomerID, organization)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -269,12 +395,12 @@ func TestCustomerRepository__CreateCustomer(t *testing.T) {
 	// SQLite tests
 	sqliteDB := database.CreateTestSQLiteDB(t)
 	defer sqliteDB.Close()
-	check(t, &sqlCustomerRepository{sqliteDB.DB, log.NewNopLogger()})
+	check(t, &sqlCustomerRepository{db: sqliteDB.DB, logger: log.NewNopLogger()})
 
 	// MySQL tests
 	mysqlDB := database.CreateTestMySQLDB(t)
 	defer mysqlDB.Close()
-	check(t, &sqlCustomerRepository{mysqlDB.DB, log.NewNopLogger()})
+	check(t, &sqlCustomerRepository{db: mysqlDB.DB, logger: log.NewNopLogger()})
 }
 
 func TestCustomers__searchCustomersError(t *testing.T) {
@@ -286,7 +412,7 @@ func TestCustomers__searchCustomersError(t *testing.T) {
 	req.Header.Set("x-request-id", "test")
 
 	router := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil))
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -364,6 +490,90 @@ func TestCustomers__addressValidate(t *testing.T) {
 	}
 }
 
+func TestCustomers__addressValidateInternational(t *testing.T) {
+	add := address{Type: "primary", OwnerType: "customer", State: "co", PostalCode: "80202-1234", Country: "usa"}
+	require.NoError(t, add.validate())
+	require.Equal(t, "US", add.Country)
+
+	add = address{Type: "primary", OwnerType: "customer", City: "Toronto", State: "ON", PostalCode: "M5V 2T6", Country: "CA"}
+	require.NoError(t, add.validate())
+
+	add.State = "CO"
+	require.Error(t, add.validate())
+
+	add = address{Type: "primary", OwnerType: "customer", City: "London", State: "Greater London", PostalCode: "SW1A 1AA", Country: "GBR"}
+	require.NoError(t, add.validate())
+	require.Equal(t, "GB", add.Country)
+
+	// regions are optional outside of the US and Canada
+	add.State = ""
+	require.NoError(t, add.validate())
+
+	add.PostalCode = "90210"
+	require.Error(t, add.validate())
+
+	add = address{Type: "primary", OwnerType: "customer", Country: "Atlantis"}
+	require.Error(t, add.validate())
+}
+
+func TestCustomers__phoneValidate(t *testing.T) {
+	p := phone{Number: "+1 (818) 555-1212", Type: "Mobile", OwnerType: "customer"}
+	require.NoError(t, p.validate())
+	require.Equal(t, "+18185551212", p.Number)
+	require.Equal(t, client.PHONETYPE_MOBILE, p.Type)
+	require.True(t, p.valid)
+
+	// infer the type
+	p = phone{Number: "+44 20 7946 0958", OwnerType: "customer"}
+	require.NoError(t, p.validate())
+	require.Equal(t, client.PHONETYPE_HOME, p.Type)
+
+	p = phone{Number: "123.456.7890", Type: "work", OwnerType: "customer"}
+	require.NoError(t, p.validate())
+	require.False(t, p.valid)
+
+	// US numbers could be mobile or home
+	p = phone{Number: "818.555.1212", OwnerType: "customer"}
+	require.Error(t, p.validate())
+
+	p = phone{Number: "555-CALL-NOW", Type: "mobile", OwnerType: "customer"}
+	require.Error(t, p.validate())
+}
+
+func TestCustomers__createCustomerMalformedPhone(t *testing.T) {
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, &testCustomerRepository{}, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
+
+	body := `{"firstName": "jane", "lastName": "doe", "type": "individual", "phones": [{"number": "not a number", "type": "mobile", "ownerType": "customer"}]}`
+	req := httptest.NewRequest("POST", "/customers", strings.NewReader(body))
+	req.Header.Set("x-organization", "test")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	w.Flush()
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "malformed phone number")
+	require.Contains(t, w.Body.String(), `"code":"validation_failed"`)
+}
+
+func TestCustomers__createCustomerInvalidSSN(t *testing.T) {
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, &testCustomerRepository{}, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
+
+	body := `{"firstName": "jane", "lastName": "doe", "type": "individual", "ssn": "666-12-3456"}`
+	req := httptest.NewRequest("POST", "/customers", strings.NewReader(body))
+	req.Header.Set("x-organization", "test")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	w.Flush()
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "SSN area number is invalid")
+	require.NotContains(t, w.Body.String(), "3456")
+}
+
 func TestCustomers__CreateCustomer(t *testing.T) {
 	w := httptest.NewRecorder()
 	phone := `{"number": "555.555.5555", "type": "mobile", "ownerType": "customer"}`
@@ -379,7 +589,7 @@ func TestCustomers__CreateCustomer(t *testing.T) {
 	customerSSNStorage := testCustomerSSNStorage(t)
 
 	router := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router, repo, customerSSNStorage, createTestOFACSearcher(nil, nil))
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, customerSSNStorage, createTestOFACSearcher(nil, nil), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -461,10 +671,10 @@ func TestCustomers__updateCustomer(t *testing.T) {
 	}
 	customer, _, _ := createReq.asCustomer(testCustomerSSNStorage(t))
 	require.NoError(t,
-		repo.CreateCustomer(customer, organization),
+		repo.CreateCustomer(context.Background(), customer, organization),
 	)
 
-	_, err := repo.GetCustomer(customer.CustomerID, organization)
+	_, err := repo.GetCustomer(context.Background(), customer.CustomerID, organization)
 	require.NoError(t, err)
 
 	router := mux.NewRouter()
@@ -499,13 +709,15 @@ func TestCustomers__updateCustomer(t *testing.T) {
 	req := httptest.NewRequest("PUT", fmt.Sprintf("/customers/%s", customer.CustomerID), bytes.NewReader(payload))
 	req.Header.Set("x-organization", "test")
 	req.Header.Set("x-request-id", "test")
-	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil))
+	req.Header.Set("If-Match", `"1"`)
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 	require.Equal(t, http.StatusOK, w.Code)
 
 	var got *client.Customer
 	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.NoError(t, updateReq.validate()) // normalizes phone numbers
 	want, _, _ := updateReq.asCustomer(testCustomerSSNStorage(t))
 	require.NoError(t, err)
 
@@ -515,6 +727,7 @@ func TestCustomers__updateCustomer(t *testing.T) {
 	want.Status = got.Status
 	want.CreatedAt = got.CreatedAt
 	want.LastModified = got.LastModified
+	copyChildTimestamps(t, want.Phones, got.Phones, want.Addresses, got.Addresses)
 	require.Equal(t, want, got)
 
 	/* Error when settings two addresses as primary */
@@ -552,11 +765,49 @@ func TestCustomers__updateCustomer(t *testing.T) {
 	require.Contains(t, errResp.ErrorMsg, ErrAddressTypeDuplicate.Error())
 }
 
+func TestCustomers__updateCustomerKeepsStatus(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	organization := "test"
+	createReq := &customerRequest{FirstName: "Jane", LastName: "Doe", Type: "individual", BirthDate: "1999-01-01", Email: "jane@example.com"}
+	customer, _, _ := createReq.asCustomer(testCustomerSSNStorage(t))
+	require.NoError(t, repo.CreateCustomer(context.Background(), customer, organization))
+	require.NoError(t, repo.updateCustomerStatus(context.Background(), customer.CustomerID, client.CUSTOMERSTATUS_VERIFIED, "", "operator"))
+	history, err := repo.getStatusHistory(context.Background(), customer.CustomerID)
+	require.NoError(t, err)
+
+	updateReq := *createReq
+	updateReq.FirstName = "Jim"
+	payload, err := json.Marshal(&updateReq)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
+
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/customers/%s", customer.CustomerID), bytes.NewReader(payload))
+	req.Header.Set("x-organization", organization)
+	req.Header.Set("If-Match", "*")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// status only changes through the status endpoint
+	got, err := repo.GetCustomer(context.Background(), customer.CustomerID, organization)
+	require.NoError(t, err)
+	require.Equal(t, "Jim", got.FirstName)
+	require.Equal(t, client.CUSTOMERSTATUS_VERIFIED, got.Status)
+
+	after, err := repo.getStatusHistory(context.Background(), customer.CustomerID)
+	require.NoError(t, err)
+	require.Len(t, after, len(history))
+}
+
 func createTestCustomerRepository(t *testing.T) *sqlCustomerRepository {
 	t.Helper()
 
 	db := database.CreateTestSQLiteDB(t)
-	return &sqlCustomerRepository{db.DB, log.NewNopLogger()}
+	return &sqlCustomerRepository{db: db.DB, logger: log.NewNopLogger()}
 }
 
 func TestCustomers__repository(t *testing.T) {
@@ -564,7 +815,7 @@ func TestCustomers__repository(t *testing.T) {
 	defer repo.close()
 
 	organization := "organization"
-	cust, err := repo.GetCustomer(base.ID(), organization)
+	cust, err := repo.GetCustomer(context.Background(), base.ID(), organization)
 	if err == nil {
 		t.Errorf("expected not found error: %v", err)
 	}
@@ -594,7 +845,7 @@ func TestCustomers__repository(t *testing.T) {
 			},
 		},
 	}).asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Error(err)
 	}
 	if cust == nil {
@@ -605,7 +856,7 @@ func TestCustomers__repository(t *testing.T) {
 	}
 
 	// read
-	cust, err = repo.GetCustomer(cust.CustomerID, organization)
+	cust, err = repo.GetCustomer(context.Background(), cust.CustomerID, organization)
 	if err != nil {
 		t.Error(err)
 	}
@@ -634,7 +885,7 @@ func TestCustomerRepository__delete(t *testing.T) {
 		}
 		cust, _, _ := cr.asCustomer(testCustomerSSNStorage(t))
 		require.NoError(t,
-			repo.CreateCustomer(cust, "organization"),
+			repo.CreateCustomer(context.Background(), cust, "organization"),
 		)
 
 		customers[i] = &customer{
@@ -648,7 +899,7 @@ func TestCustomerRepository__delete(t *testing.T) {
 		require.Less(t, idx, len(customers))
 		customers[idx].deleted = true
 		require.NoError(t,
-			repo.deleteCustomer(customers[idx].CustomerID),
+			repo.deleteCustomer(context.Background(), customers[idx].CustomerID),
 		)
 	}
 
@@ -673,6 +924,63 @@ func TestCustomerRepository__delete(t *testing.T) {
 	}
 }
 
+func TestCustomerRepository__deleteCascades(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	organization := "organization"
+	cust, _, _ := (customerRequest{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Phones:    []phone{{Number: "123.456.7890", Type: "mobile", OwnerType: "customer"}},
+		Addresses: []address{{Type: "primary", OwnerType: "customer", Address1: "123 1st St", City: "Denver", State: "CO", PostalCode: "12345", Country: "USA"}},
+		Representatives: []customerRepresentative{{
+			FirstName: "John",
+			LastName:  "Doe",
+		}},
+	}).asCustomer(testCustomerSSNStorage(t))
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, organization))
+
+	rep := cust.Representatives[0]
+	_, err := repo.db.Exec(`insert into phones (owner_id, owner_type, number, valid, type) values (?, 'representative', '987.654.3210', false, 'work');`, rep.RepresentativeID)
+	require.NoError(t, err)
+
+	_, err = repo.db.Exec(`insert into documents (document_id, customer_id, type, content_type, uploaded_at) values (?, ?, 'passport', 'image/png', ?);`, base.ID(), cust.CustomerID, time.Now())
+	require.NoError(t, err)
+
+	require.NoError(t, repo.deleteCustomer(context.Background(), cust.CustomerID))
+
+	countRemaining := func(query string, args ...interface{}) int {
+		var n int
+		require.NoError(t, repo.db.QueryRow(query, args...).Scan(&n))
+		return n
+	}
+	// every row is kept but marked as deleted
+	require.Equal(t, 2, countRemaining(`select count(*) from phones where deleted_at is not null;`))
+	require.Equal(t, 0, countRemaining(`select count(*) from phones where deleted_at is null;`))
+	require.Equal(t, 1, countRemaining(`select count(*) from addresses where owner_id = ? and deleted_at is not null;`, cust.CustomerID))
+	require.Equal(t, 1, countRemaining(`select count(*) from representatives where customer_id = ? and deleted_at is not null;`, cust.CustomerID))
+	require.Equal(t, 1, countRemaining(`select count(*) from documents where customer_id = ? and deleted_at is not null;`, cust.CustomerID))
+
+	// deleted customers are hidden unless requested
+	custs, err := repo.searchCustomers(context.Background(), SearchParams{Organization: organization, Count: 10})
+	require.NoError(t, err)
+	require.Len(t, custs, 0)
+
+	// a phone replaced before the customer was deleted stays hidden
+	_, err = repo.db.Exec(`insert into phones (owner_id, owner_type, number, valid, type, deleted_at) values (?, 'customer', '555.555.5555', false, 'home', ?);`, cust.CustomerID, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	custs, err = repo.searchCustomers(context.Background(), SearchParams{Organization: organization, Count: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, custs, 1)
+	require.Equal(t, cust.CustomerID, custs[0].CustomerID)
+	require.Len(t, custs[0].Phones, 1)
+	require.Equal(t, "123.456.7890", custs[0].Phones[0].Number)
+	require.Len(t, custs[0].Addresses, 1)
+	require.Equal(t, "123 1st St", custs[0].Addresses[0].Address1)
+}
+
 func TestCustomerRepository__updateCustomer(t *testing.T) {
 	repo := createTestCustomerRepository(t)
 	defer repo.close()
@@ -700,7 +1008,7 @@ func TestCustomerRepository__updateCustomer(t *testing.T) {
 		},
 	}
 	newCust, _, _ := createReq.asCustomer(testCustomerSSNStorage(t))
-	err := repo.CreateCustomer(newCust, organization)
+	err := repo.CreateCustomer(context.Background(), newCust, organization)
 	require.NoError(t, err)
 
 	updateReq := customerRequest{
@@ -726,7 +1034,7 @@ func TestCustomerRepository__updateCustomer(t *testing.T) {
 	}
 
 	updatedCust, _, _ := updateReq.asCustomer(testCustomerSSNStorage(t))
-	err = repo.updateCustomer(updatedCust, organization)
+	err = repo.updateCustomer(context.Background(), updatedCust, nil, organization, anyVersion)
 	require.NoError(t, err)
 
 	require.Equal(t, newCust.CustomerID, updatedCust.CustomerID)
@@ -738,6 +1046,35 @@ func TestCustomerRepository__updateCustomer(t *testing.T) {
 	require.Equal(t, updateReq.Addresses[0].City, updatedCust.Addresses[0].City)
 }
 
+func TestCustomerRepository__updatePhonesHistory(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	organization := "organization"
+	cust := &client.Customer{
+		CustomerID: base.ID(),
+		FirstName:  "Jane",
+		LastName:   "Doe",
+		Type:       client.CUSTOMERTYPE_INDIVIDUAL,
+		Phones:     []client.Phone{{Number: "+18185551212", Type: "mobile", OwnerType: "customer", Valid: true}},
+	}
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, organization))
+
+	cust.Phones = []client.Phone{{Number: "+18185559999", Type: "mobile", OwnerType: "customer", Valid: true}}
+	require.NoError(t, repo.updateCustomer(context.Background(), cust, nil, organization, anyVersion))
+
+	got, err := repo.GetCustomer(context.Background(), cust.CustomerID, organization)
+	require.NoError(t, err)
+	require.Len(t, got.Phones, 1)
+	require.Equal(t, "+18185559999", got.Phones[0].Number)
+
+	// the previous number is kept
+	var deleted int
+	row := repo.db.QueryRow(`select count(*) from phones where owner_id = ? and number = ? and deleted_at is not null;`, cust.CustomerID, "+18185551212")
+	require.NoError(t, row.Scan(&deleted))
+	require.Equal(t, 1, deleted)
+}
+
 func TestCustomerRepository__updateCustomerStatus(t *testing.T) {
 	repo := createTestCustomerRepository(t)
 	defer repo.close()
@@ -748,23 +1085,83 @@ func TestCustomerRepository__updateCustomerStatus(t *testing.T) {
 		LastName:  "Doe",
 		Email:     "jane@example.com",
 	}).asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Fatal(err)
 	}
 
 	// update status
-	if err := repo.updateCustomerStatus(cust.CustomerID, client.CUSTOMERSTATUS_VERIFIED, "test comment"); err != nil {
+	if err := repo.updateCustomerStatus(context.Background(), cust.CustomerID, client.CUSTOMERSTATUS_VERIFIED, "test comment", ""); err != nil {
 		t.Fatal(err)
 	}
 
 	// read the status back
-	customer, err := repo.GetCustomer(cust.CustomerID, organization)
+	customer, err := repo.GetCustomer(context.Background(), cust.CustomerID, organization)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if customer.Status != client.CUSTOMERSTATUS_VERIFIED {
 		t.Errorf("unexpected status: %s", customer.Status)
 	}
+
+	// the client went away, nothing is written
+	ctx, cancelFn := context.WithCancel(context.Background())
+	cancelFn()
+	err = repo.updateCustomerStatus(ctx, cust.CustomerID, client.CUSTOMERSTATUS_REJECTED, "test comment", "")
+	require.True(t, errors.Is(err, context.Canceled), "%v", err)
+
+	customer, err = repo.GetCustomer(context.Background(), cust.CustomerID, organization)
+	require.NoError(t, err)
+	require.Equal(t, client.CUSTOMERSTATUS_VERIFIED, customer.Status)
+}
+
+func TestCustomerRepository__metrics(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	transitions := func() float64 {
+		return counterValue(t, "customer_status_transitions", map[string]string{"from": "Unknown", "to": "Verified"})
+	}
+	created := counterValue(t, "customers_created", map[string]string{"type": "individual"})
+	before := transitions()
+
+	cust, _, _ := (customerRequest{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Email:     "jane@example.com",
+		Type:      client.CUSTOMERTYPE_INDIVIDUAL,
+	}).asCustomer(testCustomerSSNStorage(t))
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, "organization"))
+	require.Equal(t, created+1, counterValue(t, "customers_created", map[string]string{"type": "individual"}))
+
+	require.NoError(t, repo.updateCustomerStatus(context.Background(), cust.CustomerID, client.CUSTOMERSTATUS_VERIFIED, "", ""))
+	require.Equal(t, before+1, transitions())
+
+	searches := counterValue(t, "ofac_searches", map[string]string{"owner": "customer", "result": "review"})
+	require.NoError(t, repo.saveCustomerOFACSearch(context.Background(), cust.CustomerID, client.OfacSearch{EntityID: "1", Match: 0.91, ReviewRequired: true}))
+	require.Equal(t, searches+1, counterValue(t, "ofac_searches", map[string]string{"owner": "customer", "result": "review"}))
+}
+
+// counterValue reads a counter from the default Prometheus registry, or zero if it hasn't been incremented
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := stdprometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range family.GetMetric() {
+			for _, pair := range m.GetLabel() {
+				if labels[pair.GetName()] != pair.GetValue() {
+					continue metrics
+				}
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
 }
 
 func TestCustomersRepository__addAddress(t *testing.T) {
@@ -777,12 +1174,12 @@ func TestCustomersRepository__addAddress(t *testing.T) {
 		LastName:  "Doe",
 		Email:     "jane@example.com",
 	}).asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Fatal(err)
 	}
 
 	// add an address
-	if err := repo.addAddress(cust.CustomerID, client.OWNERTYPE_CUSTOMER, address{
+	if err := repo.addAddress(context.Background(), cust.CustomerID, client.OWNERTYPE_CUSTOMER, address{
 		Address1:   "123 1st st",
 		City:       "fake city",
 		State:      "CA",
@@ -796,7 +1193,7 @@ func TestCustomersRepository__addAddress(t *testing.T) {
 	}
 
 	// re-read
-	cust, err := repo.GetCustomer(cust.CustomerID, organization)
+	cust, err := repo.GetCustomer(context.Background(), cust.CustomerID, organization)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -818,7 +1215,7 @@ func TestCustomers__replaceCustomerMetadata(t *testing.T) {
 		LastName:  "Doe",
 		Email:     "jane@example.com",
 	}).asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Fatal(err)
 	}
 
@@ -840,7 +1237,7 @@ func TestCustomers__replaceCustomerMetadata(t *testing.T) {
 	req.Header.Set("x-request-id", "test")
 
 	router := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil))
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -863,7 +1260,7 @@ func TestCustomers__replaceCustomerMetadata(t *testing.T) {
 	req.Header.Set("x-request-id", "test")
 
 	router2 := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router2, repo2, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil))
+	AddCustomerRoutes(log.NewNopLogger(), router2, repo2, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -872,6 +1269,58 @@ func TestCustomers__replaceCustomerMetadata(t *testing.T) {
 	}
 }
 
+func TestCustomers__getCustomerMetadata(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	organization := "organization"
+	var customerIDs []string
+	for i := 0; i < 2; i++ {
+		cust, _, _ := (customerRequest{FirstName: "Jane", LastName: "Doe"}).asCustomer(testCustomerSSNStorage(t))
+		require.NoError(t, repo.CreateCustomer(context.Background(), cust, organization))
+		customerIDs = append(customerIDs, cust.CustomerID)
+	}
+	require.NoError(t, repo.replaceCustomerMetadata(context.Background(), customerIDs[0], map[string]string{"key-1": "val-1", "key-2": "val-2"}))
+	require.NoError(t, repo.replaceCustomerMetadata(context.Background(), customerIDs[1], map[string]string{"key-3": "val-3"}))
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
+
+	get := func(customerID string) (int, client.CustomerMetadata) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", fmt.Sprintf("/customers/%s/metadata", customerID), nil)
+		req.Header.Set("x-organization", organization)
+		router.ServeHTTP(w, req)
+
+		var meta client.CustomerMetadata
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&meta))
+		}
+		return w.Code, meta
+	}
+
+	// each customer only has their metadata
+	code, meta := get(customerIDs[0])
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, map[string]string{"key-1": "val-1", "key-2": "val-2"}, meta.Metadata)
+
+	code, meta = get(customerIDs[1])
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, map[string]string{"key-3": "val-3"}, meta.Metadata)
+
+	code, _ = get(base.ID()) // customer not found
+	require.Equal(t, http.StatusNotFound, code)
+
+	// a conflicting key/value doesn't partially replace metadata
+	err := repo.replaceCustomerMetadata(context.Background(), customerIDs[1], map[string]string{"key-4": "val-4", "key-1": "val-1"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "already exists")
+
+	code, meta = get(customerIDs[1])
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, map[string]string{"key-3": "val-3"}, meta.Metadata)
+}
+
 func TestCustomers__replaceCustomerMetadataInvalid(t *testing.T) {
 	repo := createTestCustomerRepository(t)
 	defer repo.close()
@@ -881,7 +1330,7 @@ func TestCustomers__replaceCustomerMetadataInvalid(t *testing.T) {
 		LastName:  "Doe",
 		Email:     "jane@example.com",
 	}).asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, "organization"); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, "organization"); err != nil {
 		t.Fatal(err)
 	}
 
@@ -899,7 +1348,7 @@ func TestCustomers__replaceCustomerMetadataInvalid(t *testing.T) {
 	req.Header.Set("x-request-id", "test")
 
 	router := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil))
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -932,7 +1381,7 @@ func TestCustomers__replaceCustomerMetadataError(t *testing.T) {
 	req.Header.Set("x-request-id", "test")
 
 	router := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil))
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -946,7 +1395,7 @@ func TestCustomerRepository__metadata(t *testing.T) {
 	defer repo.close()
 
 	getMetadata := func(customerID string) map[string]string {
-		meta, err := repo.getMetadata([]string{customerID})
+		meta, err := repo.getMetadata(context.Background(), []string{customerID})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -960,7 +1409,7 @@ func TestCustomerRepository__metadata(t *testing.T) {
 	}
 
 	// replace
-	if err := repo.replaceCustomerMetadata(customerID, map[string]string{"key": "bar"}); err != nil {
+	if err := repo.replaceCustomerMetadata(context.Background(), customerID, map[string]string{"key": "bar"}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -986,7 +1435,13 @@ func TestCustomers__validateMetadata(t *testing.T) {
 		t.Error("expected error")
 	}
 
-	meta["bar"] = "baz"         // valid again
+	meta["bar"] = "baz" // valid again
+	meta[strings.Repeat("k", 100)] = "long key"
+	if err := validateMetadata(meta); err == nil {
+		t.Error("expected error")
+	}
+	delete(meta, strings.Repeat("k", 100))
+
 	for i := 0; i < 1000; i++ { // add too many keys
 		meta[fmt.Sprintf("key-%d", i)] = fmt.Sprintf("%d", i)
 	}
@@ -1008,11 +1463,11 @@ func TestCustomerRepository__OFAC(t *testing.T) {
 		LastName:   "Doe",
 		Email:      "jane@example.com",
 	}).asCustomer(testCustomerSSNStorage(t))
-	if err := repo.CreateCustomer(cust, organization); err != nil {
+	if err := repo.CreateCustomer(context.Background(), cust, organization); err != nil {
 		t.Fatal(err)
 	}
 
-	res, err := repo.getLatestCustomerOFACSearch(customerID, organization)
+	res, err := repo.getLatestCustomerOFACSearch(context.Background(), customerID, organization)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1021,10 +1476,10 @@ func TestCustomerRepository__OFAC(t *testing.T) {
 	}
 
 	// save a record and read it back
-	if err := repo.saveCustomerOFACSearch(customerID, client.OfacSearch{EntityID: "14141"}); err != nil {
+	if err := repo.saveCustomerOFACSearch(context.Background(), customerID, client.OfacSearch{EntityID: "14141"}); err != nil {
 		t.Fatal(err)
 	}
-	res, err = repo.getLatestCustomerOFACSearch(customerID, organization)
+	res, err = repo.getLatestCustomerOFACSearch(context.Background(), customerID, organization)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1033,10 +1488,10 @@ func TestCustomerRepository__OFAC(t *testing.T) {
 	}
 
 	// save another and get it back
-	if err := repo.saveCustomerOFACSearch(customerID, client.OfacSearch{EntityID: "777121"}); err != nil {
+	if err := repo.saveCustomerOFACSearch(context.Background(), customerID, client.OfacSearch{EntityID: "777121"}); err != nil {
 		t.Fatal(err)
 	}
-	res, err = repo.getLatestCustomerOFACSearch(customerID, organization)
+	res, err = repo.getLatestCustomerOFACSearch(context.Background(), customerID, organization)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1102,7 +1557,7 @@ func TestCustomers__minimumFields(t *testing.T) {
 	customerSSNStorage := testCustomerSSNStorage(t)
 
 	router := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router, repo, customerSSNStorage, createTestOFACSearcher(nil, nil))
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, customerSSNStorage, createTestOFACSearcher(nil, nil), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -1123,7 +1578,7 @@ func TestCustomers__BadReq(t *testing.T) {
 	customerSSNStorage := testCustomerSSNStorage(t)
 
 	router := mux.NewRouter()
-	AddCustomerRoutes(log.NewNopLogger(), router, nil, customerSSNStorage, createTestOFACSearcher(nil, nil))
+	AddCustomerRoutes(log.NewNopLogger(), router, nil, customerSSNStorage, createTestOFACSearcher(nil, nil), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -1131,3 +1586,81 @@ func TestCustomers__BadReq(t *testing.T) {
 		t.Errorf("Expected SSN error received %s", w.Body.String())
 	}
 }
+
+func TestCustomers__childTimestamps(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	cust := &client.Customer{
+		CustomerID: base.ID(),
+		FirstName:  "Jane",
+		LastName:   "Doe",
+		Type:       client.CUSTOMERTYPE_INDIVIDUAL,
+		Phones: []client.Phone{
+			{Number: "+15555551111", Type: client.PHONETYPE_MOBILE},
+			{Number: "+15555552222", Type: client.PHONETYPE_HOME},
+		},
+		Addresses: []client.Address{
+			{AddressID: base.ID(), Type: client.ADDRESSTYPE_PRIMARY, Address1: "123 1st st", City: "Denver", State: "CO", PostalCode: "80202", Country: "US"},
+		},
+	}
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, "test"))
+	require.NoError(t, repo.replaceCustomerMetadata(context.Background(), cust.CustomerID, map[string]string{"key-1": "val-1", "key-2": "val-2"}))
+
+	before, err := repo.GetCustomer(context.Background(), cust.CustomerID, "test")
+	require.NoError(t, err)
+	require.Len(t, before.Phones, 2)
+	for _, p := range before.Phones {
+		require.False(t, p.CreatedAt.IsZero())
+		require.Equal(t, p.CreatedAt, p.LastModified)
+	}
+	metaBefore, err := repo.getMetadata(context.Background(), []string{cust.CustomerID})
+	require.NoError(t, err)
+	require.NotNil(t, metaBefore[cust.CustomerID].CreatedAt)
+
+	time.Sleep(10 * time.Millisecond)
+
+	// rewrite the customer with one phone changed and the address untouched
+	cust.Phones[1].Type = client.PHONETYPE_WORK
+	require.NoError(t, repo.updateCustomer(context.Background(), cust, nil, "test", anyVersion))
+	require.NoError(t, repo.replaceCustomerMetadata(context.Background(), cust.CustomerID, map[string]string{"key-1": "val-1", "key-2": "changed"}))
+
+	after, err := repo.GetCustomer(context.Background(), cust.CustomerID, "test")
+	require.NoError(t, err)
+	require.Len(t, after.Phones, 2)
+	phones := make(map[string]client.Phone)
+	for _, p := range before.Phones {
+		phones[p.Number] = p
+	}
+	for _, p := range after.Phones {
+		prev := phones[p.Number]
+		require.True(t, prev.CreatedAt.Equal(p.CreatedAt), p.Number)
+		if p.Number == "+15555552222" {
+			require.True(t, p.LastModified.After(prev.LastModified))
+		} else {
+			require.True(t, prev.LastModified.Equal(p.LastModified))
+		}
+	}
+	require.True(t, before.Addresses[0].CreatedAt.Equal(after.Addresses[0].CreatedAt))
+	require.True(t, before.Addresses[0].LastModified.Equal(after.Addresses[0].LastModified))
+
+	metaAfter, err := repo.getMetadata(context.Background(), []string{cust.CustomerID})
+	require.NoError(t, err)
+	require.True(t, metaBefore[cust.CustomerID].CreatedAt.Equal(*metaAfter[cust.CustomerID].CreatedAt))
+	require.True(t, metaAfter[cust.CustomerID].LastModified.After(*metaBefore[cust.CustomerID].LastModified))
+}
+
+// copyChildTimestamps copies the timestamps the database set on phones and addresses onto the
+// expected values after checking they were set.
+func copyChildTimestamps(t *testing.T, wantPhones, gotPhones []client.Phone, wantAddrs, gotAddrs []client.Address) {
+	t.Helper()
+
+	for i := range wantPhones {
+		require.False(t, gotPhones[i].CreatedAt.IsZero())
+		wantPhones[i].CreatedAt, wantPhones[i].LastModified = gotPhones[i].CreatedAt, gotPhones[i].LastModified
+	}
+	for i := range wantAddrs {
+		require.False(t, gotAddrs[i].CreatedAt.IsZero())
+		wantAddrs[i].CreatedAt, wantAddrs[i].LastModified = gotAddrs[i].CreatedAt, gotAddrs[i].LastModified
+	}
+}
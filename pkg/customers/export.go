@@ -0,0 +1,85 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+// ExportRecords are the stored records of a Customer which are included in a data export
+type ExportRecords struct {
+	Customer      *client.Customer
+	SSN           string
+	StatusHistory []StatusUpdate
+	OFACSearches  []*client.OfacSearch
+
+	// Emails are each of the Customer's addresses and if they were verified
+	Emails []*CustomerEmail
+}
+
+// Exporter reads every record held about a Customer for data portability requests.
+type Exporter struct {
+	repo       CustomerRepository
+	emails     CustomerEmailRepository
+	ssnStorage *ssnStorage
+}
+
+func NewExporter(repo CustomerRepository, emails CustomerEmailRepository, storage *ssnStorage) *Exporter {
+	return &Exporter{
+		repo:       repo,
+		emails:     emails,
+		ssnStorage: storage,
+	}
+}
+
+// Export returns the Customer's records or nil if the Customer doesn't exist. The SSN is masked
+// unless includeSSN is set, in which case it's decrypted.
+func (e *Exporter) Export(ctx context.Context, customerID, organization string, includeSSN bool) (*ExportRecords, error) {
+	cust, err := e.repo.GetCustomer(ctx, customerID, organization)
+	if err != nil {
+		if err == errCustomerNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("export: get customer: %v", err)
+	}
+	if cust == nil {
+		return nil, nil
+	}
+	records := &ExportRecords{Customer: cust}
+
+	if e.ssnStorage != nil {
+		ssn, err := e.ssnStorage.repo.getSSN(customerID, client.OWNERTYPE_CUSTOMER)
+		if err != nil {
+			return nil, fmt.Errorf("export: get SSN: %v", err)
+		}
+		if ssn != nil {
+			records.SSN = ssn.masked
+			if includeSSN {
+				if records.SSN, err = e.ssnStorage.decryptRaw(ssn); err != nil {
+					return nil, fmt.Errorf("export: %v", err)
+				}
+			}
+		}
+	}
+
+	records.StatusHistory, err = e.repo.getStatusHistory(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("export: %v", err)
+	}
+	records.OFACSearches, err = e.repo.getCustomerOFACSearches(ctx, customerID, organization)
+	if err != nil {
+		return nil, fmt.Errorf("export: %v", err)
+	}
+	if e.emails != nil {
+		records.Emails, err = e.emails.GetEmails(ctx, customerID)
+		if err != nil {
+			return nil, fmt.Errorf("export: %v", err)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,446 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+// Emails are encrypted deterministically into customers.encrypted_email so customers can still be
+// searched by email, phone numbers are randomized into phones.encrypted_number since they're only
+// ever read as part of their owner. Rows written before encryption was enabled keep their plaintext
+// in the email and number columns until they're re-encrypted.
+
+// normalizeEmail lowercases emails before they're encrypted so searches ignore case
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// emailColumns returns the values of the email and encrypted_email columns for email
+func (r *sqlCustomerRepository) emailColumns(email string) (string, *string, error) {
+	if !r.fields.Enabled() || email == "" {
+		return email, nil, nil
+	}
+	encrypted, err := r.fields.EncryptDeterministic(normalizeEmail(email))
+	if err != nil {
+		return "", nil, fmt.Errorf("encrypting email: %v", err)
+	}
+	return "", &encrypted, nil
+}
+
+// readEmail returns the plaintext of an email read from the email and encrypted_email columns
+func (r *sqlCustomerRepository) readEmail(email, encrypted sql.NullString) (string, error) {
+	if encrypted.String != "" {
+		out, err := r.fields.Decrypt(encrypted.String)
+		if err != nil {
+			return "", fmt.Errorf("decrypting email: %v", err)
+		}
+		return out, nil
+	}
+	return email.String, nil
+}
+
+// encryptedEmailSearch returns the encrypted_email values a search for email matches
+func (r *sqlCustomerRepository) encryptedEmailSearch(email string) []string {
+	if !r.fields.Enabled() || email == "" {
+		return nil
+	}
+	return r.fields.SearchValues(normalizeEmail(email))[1:]
+}
+
+// phoneColumns returns the values of the number and encrypted_number columns for number
+func (r *sqlCustomerRepository) phoneColumns(number string) (*string, *string, error) {
+	if !r.fields.Enabled() {
+		return &number, nil, nil
+	}
+	encrypted, err := r.fields.Encrypt(number)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encrypting phone: %v", err)
+	}
+	return nil, &encrypted, nil
+}
+
+// storedPhone is a phones row along with the column values which identify it
+type storedPhone struct {
+	client.Phone
+
+	number    sql.NullString
+	encrypted sql.NullString
+}
+
+// where returns the filter matching the row, phones have no ID so they're found by their stored
+// number. Randomized ciphertexts are unique so they identify the row as well as a plaintext number.
+func (p storedPhone) where() (string, interface{}) {
+	if p.encrypted.String != "" {
+		return "encrypted_number = ?", p.encrypted.String
+	}
+	return "number = ?", p.number.String
+}
+
+// readStoredPhones returns the owner's phones which aren't deleted keyed by their plaintext number
+func (r *sqlCustomerRepository) readStoredPhones(tx *sql.Tx, ownerID string, ownerType client.OwnerType) (map[string]storedPhone, error) {
+	query := `select number, encrypted_number, valid, type, created_at, last_modified from phones where owner_id = ? and owner_type = ? and deleted_at is null;`
+	rows, err := tx.Query(query, ownerID, ownerType)
+	if err != nil {
+		return nil, fmt.Errorf("reading phones: %v", err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]storedPhone)
+	for rows.Next() {
+		var p storedPhone
+		var createdAt, lastModified *time.Time
+		if err := rows.Scan(&p.number, &p.encrypted, &p.Valid, &p.Type, &createdAt, &lastModified); err != nil {
+			return nil, fmt.Errorf("reading phones: %v", err)
+		}
+		if p.Number, err = r.readPhoneNumber(p.number, p.encrypted); err != nil {
+			return nil, err
+		}
+		p.OwnerType = ownerType
+		p.CreatedAt, p.LastModified = readTimestamps(createdAt, lastModified)
+		out[p.Number] = p
+	}
+	return out, rows.Err()
+}
+
+// readPhoneNumber returns the plaintext of a phone read from the number and encrypted_number columns
+func (r *sqlCustomerRepository) readPhoneNumber(number, encrypted sql.NullString) (string, error) {
+	if encrypted.String != "" {
+		out, err := r.fields.Decrypt(encrypted.String)
+		if err != nil {
+			return "", fmt.Errorf("decrypting phone: %v", err)
+		}
+		return out, nil
+	}
+	return number.String, nil
+}
+
+// reencryptFields rewrites the email, emails and phones of up to limit Customers after customerID which aren't
+// encrypted by the current key, along with the phones of their Representatives. It returns the last
+// Customer read, which is empty once every Customer has been read, how many Customers were read and
+// how many values were rewritten.
+func (r *sqlCustomerRepository) reencryptFields(afterCustomerID string, limit int) (string, int, int, error) {
+	var lastID string
+	var read, rewritten int
+	err := customersdb.RetryOnLock(r.db, func(tx *sql.Tx) error {
+		lastID, read, rewritten = "", 0, 0
+
+		query := `select customer_id, email, encrypted_email from customers where customer_id > ? order by customer_id asc limit ?;`
+		rows, err := tx.Query(query, afterCustomerID, limit)
+		if err != nil {
+			return fmt.Errorf("reencryptFields: customers: %v", err)
+		}
+		type row struct {
+			customerID       string
+			email, encrypted sql.NullString
+		}
+		var batch []row
+		for rows.Next() {
+			var rw row
+			if err := rows.Scan(&rw.customerID, &rw.email, &rw.encrypted); err != nil {
+				rows.Close()
+				return fmt.Errorf("reencryptFields: scan: %v", err)
+			}
+			batch = append(batch, rw)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("reencryptFields: customers: %v", err)
+		}
+
+		for _, rw := range batch {
+			lastID = rw.customerID
+			read++
+
+			if !r.fields.Current(rw.email.String) || !r.fields.Current(rw.encrypted.String) {
+				email, err := r.readEmail(rw.email, rw.encrypted)
+				if err != nil {
+					return fmt.Errorf("reencryptFields: customer=%s: %v", rw.customerID, err)
+				}
+				plain, encrypted, err := r.emailColumns(email)
+				if err != nil {
+					return err
+				}
+				query := `update customers set email = ?, encrypted_email = ? where customer_id = ?;`
+				if _, err := tx.Exec(query, plain, encrypted, rw.customerID); err != nil {
+					return fmt.Errorf("reencryptFields: updating customer=%s: %v", rw.customerID, err)
+				}
+				rewritten++
+			}
+
+			n, err := r.reencryptPhones(tx, rw.customerID)
+			if err != nil {
+				return fmt.Errorf("reencryptFields: customer=%s: %v", rw.customerID, err)
+			}
+			rewritten += n
+
+			n, err = r.reencryptEmails(tx, rw.customerID)
+			if err != nil {
+				return fmt.Errorf("reencryptFields: customer=%s: %v", rw.customerID, err)
+			}
+			rewritten += n
+		}
+		return nil
+	})
+	return lastID, read, rewritten, err
+}
+
+// reencryptPhones rewrites each phone of the Customer and their Representatives which isn't
+// encrypted by the current key, including deleted phones.
+func (r *sqlCustomerRepository) reencryptPhones(tx *sql.Tx, customerID string) (int, error) {
+	query := `select owner_id, owner_type, number, encrypted_number from phones
+where (owner_type = 'customer' and owner_id = ?) or (owner_type = 'representative' and owner_id in (select representative_id from representatives where customer_id = ?));`
+	rows, err := tx.Query(query, customerID, customerID)
+	if err != nil {
+		return 0, fmt.Errorf("reading phones: %v", err)
+	}
+	var phones []storedPhone
+	var owners []string
+	for rows.Next() {
+		var p storedPhone
+		var ownerID string
+		if err := rows.Scan(&ownerID, &p.OwnerType, &p.number, &p.encrypted); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("reading phones: %v", err)
+		}
+		if r.fields.Current(p.number.String) && r.fields.Current(p.encrypted.String) {
+			continue
+		}
+		phones = append(phones, p)
+		owners = append(owners, ownerID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("reading phones: %v", err)
+	}
+
+	for i, p := range phones {
+		number, err := r.readPhoneNumber(p.number, p.encrypted)
+		if err != nil {
+			return 0, err
+		}
+		plain, encrypted, err := r.phoneColumns(number)
+		if err != nil {
+			return 0, err
+		}
+		where, arg := p.where()
+		query := `update phones set number = ?, encrypted_number = ? where owner_id = ? and owner_type = ? and ` + where + `;`
+		if _, err := tx.Exec(query, plain, encrypted, owners[i], p.OwnerType, arg); err != nil {
+			return 0, fmt.Errorf("updating phone: %v", err)
+		}
+	}
+	return len(phones), nil
+}
+
+// reencryptEmails rewrites each of the Customer's emails which isn't encrypted by the current key,
+// including deleted emails.
+func (r *sqlCustomerRepository) reencryptEmails(tx *sql.Tx, customerID string) (int, error) {
+	rows, err := tx.Query(`select email_id, email, encrypted_email from customers_emails where customer_id = ?;`, customerID)
+	if err != nil {
+		return 0, fmt.Errorf("reading emails: %v", err)
+	}
+	stale := make(map[string]string)
+	for rows.Next() {
+		var emailID string
+		var email, encrypted sql.NullString
+		if err := rows.Scan(&emailID, &email, &encrypted); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("reading emails: %v", err)
+		}
+		if r.fields.Current(email.String) && r.fields.Current(encrypted.String) {
+			continue
+		}
+		if stale[emailID], err = r.readEmail(email, encrypted); err != nil {
+			rows.Close()
+			return 0, err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("reading emails: %v", err)
+	}
+
+	for emailID, email := range stale {
+		plain, encrypted, err := r.emailColumns(email)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`update customers_emails set email = ?, encrypted_email = ? where email_id = ?;`, plain, encrypted, emailID); err != nil {
+			return 0, fmt.Errorf("updating email: %v", err)
+		}
+	}
+	return len(stale), nil
+}
+
+// FieldReencryption is the progress of one pass re-encrypting every Customer's email and phones
+type FieldReencryption struct {
+	Customers int `json:"customers"`
+	Rewritten int `json:"rewritten"`
+
+	StartedAt   time.Time  `json:"startedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// FieldReencryptor rewrites values encrypted by older keys (or stored in plaintext) under the current
+// field encryption key. Customers are rewritten a batch at a time while the service keeps running
+// since reads decrypt with any configured key. A pass can be repeated safely, values already under
+// the current key are skipped.
+type FieldReencryptor struct {
+	logger    log.Logger
+	repo      *sqlCustomerRepository
+	batchSize int
+
+	mu      sync.Mutex
+	running bool
+	last    *FieldReencryption
+}
+
+func NewFieldReencryptor(logger log.Logger, repo CustomerRepository, batchSize int) *FieldReencryptor {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	r, _ := repo.(*sqlCustomerRepository)
+	return &FieldReencryptor{
+		logger:    logger.Set("package", log.String("customers")),
+		repo:      r,
+		batchSize: batchSize,
+	}
+}
+
+var errReencryptionRunning = route.NewError(http.StatusConflict, route.CodeConflict, "a field re-encryption is already running")
+
+func (f *FieldReencryptor) begin() (*FieldReencryption, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.repo == nil || !f.repo.fields.Enabled() {
+		return nil, route.Validation(fmt.Errorf("field encryption isn't enabled, see FIELD_ENCRYPTION_KEYS"))
+	}
+	if f.running {
+		return nil, errReencryptionRunning
+	}
+	f.running = true
+	f.last = &FieldReencryption{StartedAt: time.Now()}
+	return f.last, nil
+}
+
+// Run re-encrypts every Customer's email and phones. Only one pass runs at a time.
+func (f *FieldReencryptor) Run(ctx context.Context) (*FieldReencryption, error) {
+	run, err := f.begin()
+	if err != nil {
+		return nil, err
+	}
+	return f.process(ctx, run), nil
+}
+
+func (f *FieldReencryptor) process(ctx context.Context, run *FieldReencryption) *FieldReencryption {
+	var after string
+	var err error
+	for {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		var last string
+		var read, rewritten int
+		last, read, rewritten, err = f.repo.reencryptFields(after, f.batchSize)
+		if err != nil || last == "" {
+			break
+		}
+
+		f.mu.Lock()
+		run.Customers += read
+		run.Rewritten += rewritten
+		f.mu.Unlock()
+		after = last
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		run.Error = err.Error()
+		f.logger.LogErrorf("problem re-encrypting fields after customer=%s: %v", after, err)
+	} else {
+		now := time.Now()
+		run.CompletedAt = &now
+		f.logger.Logf("re-encrypted %d fields", run.Rewritten)
+	}
+	f.running = false
+	return run
+}
+
+func (f *FieldReencryptor) status() *FieldReencryption {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.last == nil {
+		return nil
+	}
+	run := *f.last
+	return &run
+}
+
+func AddFieldReencryptionAdminRoutes(logger log.Logger, svc *admin.Server, reencryptor *FieldReencryptor) {
+	logger = logger.Set("package", log.String("customers"))
+
+	svc.AddHandler("/customers/reencrypt", fieldReencryption(logger, reencryptor))
+}
+
+func fieldReencryption(logger log.Logger, reencryptor *FieldReencryptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		switch r.Method {
+		case "GET":
+			run := reencryptor.status()
+			if run == nil {
+				route.NotFound(w, r)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(run)
+
+		case "POST":
+			run, err := reencryptor.begin()
+			if err != nil {
+				route.Problem(w, err)
+				return
+			}
+			started := *run // the run keeps changing once it's processed
+			go reencryptor.process(context.Background(), run)
+
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(started)
+
+		default:
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+		}
+	}
+}
+
+// NewCustomerRepoWithEncryption returns a CustomerRepository which encrypts the email and phone
+// numbers it saves with fields. Values written before encryption was enabled are still read.
+func NewCustomerRepoWithEncryption(logger log.Logger, db customersdb.Querier, fields *secrets.FieldEncryptor) CustomerRepository {
+	return &sqlCustomerRepository{
+		db:     db,
+		logger: logger,
+		fields: fields,
+	}
+}
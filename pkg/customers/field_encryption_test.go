@@ -0,0 +1,184 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+func readStoredFields(t *testing.T, db *sql.DB, customerID string) (string, string, []string) {
+	t.Helper()
+
+	var email, encrypted sql.NullString
+	require.NoError(t, db.QueryRow(`select email, encrypted_email from customers where customer_id = ?;`, customerID).Scan(&email, &encrypted))
+
+	rows, err := db.Query(`select coalesce(encrypted_number, number) from phones where owner_id = ? and deleted_at is null order by coalesce(encrypted_number, number);`, customerID)
+	require.NoError(t, err)
+	defer rows.Close()
+	var phones []string
+	for rows.Next() {
+		var number string
+		require.NoError(t, rows.Scan(&number))
+		phones = append(phones, number)
+	}
+	require.NoError(t, rows.Err())
+	return email.String, encrypted.String, phones
+}
+
+func TestCustomers__fieldEncryption(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	repo := NewCustomerRepoWithEncryption(log.NewNopLogger(), db.DB, secrets.TestFieldEncryptor(t)).(*sqlCustomerRepository)
+
+	cust := &client.Customer{
+		CustomerID: base.ID(),
+		FirstName:  "Jane",
+		LastName:   "Doe",
+		Email:      "Jane@Example.com",
+		Type:       client.CUSTOMERTYPE_INDIVIDUAL,
+		Phones: []client.Phone{
+			{Number: "+15555551234", Type: client.PHONETYPE_MOBILE},
+			{Number: "+15555550000", Type: client.PHONETYPE_HOME},
+		},
+	}
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, "test"))
+
+	// nothing is stored in plaintext
+	email, encrypted, phones := readStoredFields(t, db.DB, cust.CustomerID)
+	require.Empty(t, email)
+	require.True(t, strings.HasPrefix(encrypted, "enc:test:"))
+	require.Len(t, phones, 2)
+	for _, p := range phones {
+		require.True(t, strings.HasPrefix(p, "enc:test:"))
+		require.NotContains(t, p, "555")
+	}
+
+	got, err := repo.GetCustomer(context.Background(), cust.CustomerID, "test")
+	require.NoError(t, err)
+	require.Equal(t, "jane@example.com", got.Email)
+	require.ElementsMatch(t, []string{"+15555551234", "+15555550000"}, []string{got.Phones[0].Number, got.Phones[1].Number})
+
+	// searches match the whole email, ignoring case
+	custs, err := repo.searchCustomers(context.Background(), SearchParams{Organization: "test", Email: "jane@EXAMPLE.com", Count: 10})
+	require.NoError(t, err)
+	require.Len(t, custs, 1)
+	total, err := repo.countCustomers(context.Background(), SearchParams{Organization: "test", Email: "john@example.com", Count: 10})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), total)
+
+	// unchanged phones keep their ciphertext, removed phones are deleted
+	cust.Phones = []client.Phone{{Number: "+15555551234", Type: client.PHONETYPE_WORK}}
+	require.NoError(t, repo.updateCustomer(context.Background(), cust, nil, "test", anyVersion))
+	_, _, after := readStoredFields(t, db.DB, cust.CustomerID)
+	require.Len(t, after, 1)
+	require.Contains(t, phones, after[0])
+
+	got, err = repo.GetCustomer(context.Background(), cust.CustomerID, "test")
+	require.NoError(t, err)
+	require.Len(t, got.Phones, 1)
+	require.Equal(t, client.PHONETYPE_WORK, got.Phones[0].Type)
+}
+
+func TestCustomers__fieldReencryption(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	// one customer saved before encryption was enabled and one under an old key
+	plain := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Type: client.CUSTOMERTYPE_INDIVIDUAL,
+		Phones: []client.Phone{{Number: "+15555551234", Type: client.PHONETYPE_MOBILE}}}
+	require.NoError(t, NewCustomerRepo(log.NewNopLogger(), db.DB).CreateCustomer(context.Background(), plain, "test"))
+
+	old := &client.Customer{CustomerID: base.ID(), FirstName: "John", LastName: "Doe", Email: "john@example.com", Type: client.CUSTOMERTYPE_INDIVIDUAL,
+		Phones: []client.Phone{{Number: "+15555550000", Type: client.PHONETYPE_HOME}}}
+	require.NoError(t, NewCustomerRepoWithEncryption(log.NewNopLogger(), db.DB, secrets.TestFieldEncryptor(t, "old")).CreateCustomer(context.Background(), old, "test"))
+
+	// both are readable and searchable once a new key is added
+	repo := NewCustomerRepoWithEncryption(log.NewNopLogger(), db.DB, secrets.TestFieldEncryptor(t, "new", "old"))
+	for _, c := range []*client.Customer{plain, old} {
+		got, err := repo.GetCustomer(context.Background(), c.CustomerID, "test")
+		require.NoError(t, err)
+		require.Equal(t, c.Email, got.Email)
+		require.Equal(t, c.Phones[0].Number, got.Phones[0].Number)
+	}
+	total, err := repo.countCustomers(context.Background(), SearchParams{Organization: "test", Email: "john@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), total)
+
+	reencryptor := NewFieldReencryptor(log.NewNopLogger(), repo, 1)
+	run, err := reencryptor.Run(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, run.CompletedAt)
+	require.Equal(t, 2, run.Customers)
+	require.Equal(t, 6, run.Rewritten) // each customer's email, emails row and phone
+
+	for _, c := range []*client.Customer{plain, old} {
+		email, encrypted, phones := readStoredFields(t, db.DB, c.CustomerID)
+		require.Empty(t, email)
+		require.True(t, strings.HasPrefix(encrypted, "enc:new:"))
+		require.True(t, strings.HasPrefix(phones[0], "enc:new:"))
+		var stored string
+		require.NoError(t, db.DB.QueryRow(`select encrypted_email from customers_emails where customer_id = ?;`, c.CustomerID).Scan(&stored))
+		require.Equal(t, encrypted, stored)
+
+		// the old key is no longer needed
+		got, err := NewCustomerRepoWithEncryption(log.NewNopLogger(), db.DB, secrets.TestFieldEncryptor(t, "new")).GetCustomer(context.Background(), c.CustomerID, "test")
+		require.NoError(t, err)
+		require.Equal(t, c.Email, got.Email)
+		require.Equal(t, c.Phones[0].Number, got.Phones[0].Number)
+	}
+
+	// a second pass has nothing to rewrite
+	run, err = reencryptor.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, run.Rewritten)
+}
+
+func TestCustomers__fieldReencryptionRoutes(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	svc := admin.NewServer(":0")
+	go svc.Listen()
+	defer svc.Shutdown()
+
+	plaintext := NewFieldReencryptor(log.NewNopLogger(), NewCustomerRepo(log.NewNopLogger(), db.DB), 10)
+	AddFieldReencryptionAdminRoutes(log.NewNopLogger(), svc, plaintext)
+
+	req := httptest.NewRequest("POST", "/customers/reencrypt", nil)
+	w := httptest.NewRecorder()
+	fieldReencryption(log.NewNopLogger(), plaintext)(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	fieldReencryption(log.NewNopLogger(), plaintext)(w, httptest.NewRequest("GET", "/customers/reencrypt", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	reencryptor := NewFieldReencryptor(log.NewNopLogger(), NewCustomerRepoWithEncryption(log.NewNopLogger(), db.DB, secrets.TestFieldEncryptor(t)), 10)
+	w = httptest.NewRecorder()
+	fieldReencryption(log.NewNopLogger(), reencryptor)(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), `"startedAt"`)
+
+	require.Eventually(t, func() bool {
+		run := reencryptor.status()
+		return run != nil && run.CompletedAt != nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
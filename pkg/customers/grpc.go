@@ -0,0 +1,356 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/moov-io/base/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/moov-io/customers/pkg/auth"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customerspb"
+	"github.com/moov-io/customers/pkg/model"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+// Metadata keys read from gRPC requests. They match the HTTP headers set by the upstream gateway.
+const (
+	grpcOrganizationKey = "x-organization"
+	grpcUserIDKey       = "x-user-id"
+	grpcRequestIDKey    = "x-request-id"
+)
+
+var errMissingOrganization = status.Error(codes.Unauthenticated, "missing x-organization metadata")
+
+// grpcMethodScopes are the scopes each gRPC method needs, matching the HTTP routes
+var grpcMethodScopes = map[string]string{
+	"/moov.customers.v1.Customers/CreateCustomer":       route.ScopeWrite,
+	"/moov.customers.v1.Customers/GetCustomer":          route.ScopeRead,
+	"/moov.customers.v1.Customers/ListCustomers":        route.ScopeRead,
+	"/moov.customers.v1.Customers/UpdateCustomerStatus": route.ScopeAdmin,
+	"/moov.customers.v1.Customers/SearchOFAC":           route.ScopeWrite,
+}
+
+// NewGRPCServer returns a gRPC server for the Customers service. It shares the repositories,
+// OFAC searcher and authenticator with the HTTP routes.
+func NewGRPCServer(logger log.Logger, authenticator auth.Authenticator, repo CustomerRepository, customerSSNStorage *ssnStorage, ofac *OFACSearcher, notifier webhooks.Notifier) *grpc.Server {
+	logger = logger.Set("package", log.String("customers")).Set("transport", log.String("grpc"))
+
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		auth.UnaryServerInterceptor(logger, authenticator, grpcMethodScopes),
+		grpcCallerInterceptor(logger),
+	))
+	customerspb.RegisterCustomersServer(srv, &grpcServer{
+		logger:             logger,
+		repo:               repo,
+		customerSSNStorage: customerSSNStorage,
+		ofac:               ofac,
+		notifier:           notifier,
+	})
+	return srv
+}
+
+type grpcServer struct {
+	customerspb.UnimplementedCustomersServer
+
+	logger             log.Logger
+	repo               CustomerRepository
+	customerSSNStorage *ssnStorage
+	ofac               *OFACSearcher
+	notifier           webhooks.Notifier
+}
+
+// grpcCaller is who made a gRPC request, read from its metadata
+type grpcCaller struct {
+	organization string
+	userID       string
+	requestID    string
+}
+
+type grpcCallerKey struct{}
+
+func callerFromContext(ctx context.Context) grpcCaller {
+	caller, _ := ctx.Value(grpcCallerKey{}).(grpcCaller)
+	return caller
+}
+
+// grpcCallerInterceptor requires the organization on every request and converts
+// errors into gRPC statuses. It runs after authentication, which replaces x-user-id
+// with the authenticated subject.
+func grpcCallerInterceptor(logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		first := func(key string) string {
+			if vs := md.Get(key); len(vs) > 0 {
+				return strings.TrimSpace(vs[0])
+			}
+			return ""
+		}
+		caller := grpcCaller{
+			organization: first(grpcOrganizationKey),
+			userID:       first(grpcUserIDKey),
+			requestID:    first(grpcRequestIDKey),
+		}
+		if caller.organization == "" {
+			return nil, errMissingOrganization
+		}
+
+		resp, err := handler(context.WithValue(ctx, grpcCallerKey{}, caller), req)
+		if err != nil {
+			logger.Set("method", log.String(info.FullMethod)).Set("requestID", log.String(caller.requestID)).LogErrorf("%v", err)
+			return nil, grpcError(err)
+		}
+		return resp, nil
+	}
+}
+
+// grpcError returns err as a gRPC status using the same classification as HTTP error responses
+func grpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	e := route.Classify(err)
+
+	code := codes.Unknown
+	switch e.Status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusPreconditionFailed, http.StatusPreconditionRequired:
+		code = codes.FailedPrecondition
+	}
+	return status.Error(code, e.Message)
+}
+
+func (s *grpcServer) CreateCustomer(ctx context.Context, in *customerspb.CreateCustomerRequest) (*customerspb.Customer, error) {
+	caller := callerFromContext(ctx)
+
+	req := customerRequest{
+		FirstName:    in.FirstName,
+		MiddleName:   in.MiddleName,
+		LastName:     in.LastName,
+		NickName:     in.NickName,
+		Suffix:       in.Suffix,
+		Type:         client.CustomerType(in.Type),
+		BusinessName: in.BusinessName,
+		Email:        in.Email,
+		SSN:          in.Ssn,
+		Metadata:     in.Metadata,
+	}
+	if in.BirthDate != "" {
+		t, err := time.Parse(model.YYYYMMDD_Format, in.BirthDate)
+		if err != nil {
+			return nil, route.Validation(fmt.Errorf("birthDate: %v", err))
+		}
+		req.BirthDate = model.YYYYMMDD(t.Format(model.YYYYMMDD_Format))
+	}
+	for _, p := range in.Phones {
+		req.Phones = append(req.Phones, phone{
+			Number:    p.Number,
+			Type:      client.PhoneType(p.Type),
+			OwnerType: client.OWNERTYPE_CUSTOMER,
+		})
+	}
+	for _, a := range in.Addresses {
+		req.Addresses = append(req.Addresses, address{
+			Type:       client.AddressType(a.Type),
+			OwnerType:  client.OWNERTYPE_CUSTOMER,
+			Address1:   a.Address1,
+			Address2:   a.Address2,
+			City:       a.City,
+			State:      a.State,
+			PostalCode: a.PostalCode,
+			Country:    a.Country,
+		})
+	}
+	if err := req.validate(); err != nil {
+		return nil, route.Validation(err)
+	}
+
+	cust, ssn, err := req.asCustomer(s.customerSSNStorage)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := saveNewCustomer(ctx, s.logger, s.repo, s.customerSSNStorage, cust, ssn, caller.organization); err != nil {
+		return nil, err
+	}
+	if err := s.repo.replaceCustomerMetadata(ctx, cust.CustomerID, cust.Metadata); err != nil {
+		return nil, err
+	}
+	screenNewCustomer(ctx, s.logger, s.repo, s.ofac, cust, caller.organization, caller.requestID, caller.userID)
+
+	s.logger.Logf("created customer=%s", cust.CustomerID)
+
+	cust, err = s.repo.GetCustomer(ctx, cust.CustomerID, caller.organization)
+	if err != nil {
+		return nil, err
+	}
+	notify(s.notifier, webhooks.CustomerCreated, cust.CustomerID, caller.organization, cust.Status)
+
+	return customerToProto(cust), nil
+}
+
+func (s *grpcServer) GetCustomer(ctx context.Context, in *customerspb.GetCustomerRequest) (*customerspb.Customer, error) {
+	cust, err := s.getCustomer(ctx, in.CustomerId)
+	if err != nil {
+		return nil, err
+	}
+	return customerToProto(cust), nil
+}
+
+func (s *grpcServer) getCustomer(ctx context.Context, customerID string) (*client.Customer, error) {
+	cust, err := s.repo.GetCustomer(ctx, customerID, callerFromContext(ctx).organization)
+	if err != nil {
+		return nil, err
+	}
+	if cust == nil {
+		return nil, errCustomerNotFound
+	}
+	return cust, nil
+}
+
+func (s *grpcServer) ListCustomers(ctx context.Context, in *customerspb.ListCustomersRequest) (*customerspb.ListCustomersResponse, error) {
+	clean := func(v string) string {
+		return strings.ToLower(strings.TrimSpace(v))
+	}
+	params := SearchParams{
+		Organization: callerFromContext(ctx).organization,
+		Query:        clean(in.Query),
+		Email:        clean(in.Email),
+		Status:       clean(in.Status),
+		Type:         clean(in.Type),
+		Skip:         in.Skip,
+		Count:        in.Count,
+	}
+	// same limits as GET /customers
+	switch {
+	case params.Skip < 0:
+		params.Skip = 0
+	case params.Skip > 10000:
+		params.Skip = 10000
+	}
+	switch {
+	case params.Count <= 0:
+		params.Count = 20
+	case params.Count > 200:
+		params.Count = 200
+	}
+
+	customers, err := s.repo.searchCustomers(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.repo.countCustomers(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &customerspb.ListCustomersResponse{Total: total}
+	for i := range customers {
+		resp.Customers = append(resp.Customers, customerToProto(customers[i]))
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) UpdateCustomerStatus(ctx context.Context, in *customerspb.UpdateCustomerStatusRequest) (*customerspb.Customer, error) {
+	caller := callerFromContext(ctx)
+
+	req := client.UpdateCustomerStatus{
+		Status:  client.CustomerStatus(in.Status),
+		Comment: in.Comment,
+	}
+	updated, err := changeCustomerStatus(ctx, s.repo, s.customerSSNStorage.repo, in.CustomerId, caller.organization, req, caller.userID)
+	if err != nil {
+		return nil, err
+	}
+	notify(s.notifier, webhooks.CustomerStatusUpdated, in.CustomerId, caller.organization, updated)
+
+	return s.GetCustomer(ctx, &customerspb.GetCustomerRequest{CustomerId: in.CustomerId})
+}
+
+func (s *grpcServer) SearchOFAC(ctx context.Context, in *customerspb.SearchOFACRequest) (*customerspb.OFACSearch, error) {
+	caller := callerFromContext(ctx)
+
+	cust, err := s.getCustomer(ctx, in.CustomerId)
+	if err != nil {
+		return nil, err
+	}
+	result, rejected, err := refreshCustomerOFACSearch(ctx, s.logger, s.repo, s.ofac, cust, caller.organization, caller.requestID, caller.userID, in.ForceRefresh)
+	if err != nil {
+		return nil, err
+	}
+	if rejected {
+		notify(s.notifier, webhooks.CustomerStatusUpdated, cust.CustomerID, caller.organization, client.CUSTOMERSTATUS_REJECTED)
+	}
+	if result == nil {
+		return nil, errors.New("no OFAC search found")
+	}
+	return &customerspb.OFACSearch{
+		EntityId:       result.EntityID,
+		SdnName:        result.SdnName,
+		SdnType:        result.SdnType,
+		Match:          result.Match,
+		Blocked:        result.Blocked,
+		ReviewRequired: result.ReviewRequired,
+		CreatedAt:      timestamppb.New(result.CreatedAt),
+	}, nil
+}
+
+func customerToProto(cust *client.Customer) *customerspb.Customer {
+	out := &customerspb.Customer{
+		CustomerId:   cust.CustomerID,
+		FirstName:    cust.FirstName,
+		MiddleName:   cust.MiddleName,
+		LastName:     cust.LastName,
+		NickName:     cust.NickName,
+		Suffix:       cust.Suffix,
+		Type:         string(cust.Type),
+		BusinessName: cust.BusinessName,
+		BirthDate:    cust.BirthDate,
+		Status:       string(cust.Status),
+		Email:        cust.Email,
+		Metadata:     cust.Metadata,
+		CreatedAt:    timestamppb.New(cust.CreatedAt),
+		LastModified: timestamppb.New(cust.LastModified),
+	}
+	for _, p := range cust.Phones {
+		out.Phones = append(out.Phones, &customerspb.Phone{
+			Number: p.Number,
+			Type:   string(p.Type),
+			Valid:  p.Valid,
+		})
+	}
+	for _, a := range cust.Addresses {
+		out.Addresses = append(out.Addresses, &customerspb.Address{
+			AddressId:  a.AddressID,
+			Type:       string(a.Type),
+			Address1:   a.Address1,
+			Address2:   a.Address2,
+			City:       a.City,
+			State:      a.State,
+			PostalCode: a.PostalCode,
+			Country:    a.Country,
+			Validated:  a.Validated,
+		})
+	}
+	return out
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/base/log"
+	"github.com/moov-io/customers/pkg/auth"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customerspb"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/watchman"
+	watchmanClient "github.com/moov-io/watchman/client"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func createTestGRPCClient(t *testing.T, authenticator auth.Authenticator, repo CustomerRepository, ofac *OFACSearcher) customerspb.CustomersClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	srv := NewGRPCServer(log.NewNopLogger(), authenticator, repo, testCustomerSSNStorage(t), ofac, nil)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return customerspb.NewCustomersClient(conn)
+}
+
+func TestGRPCServer(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	ofac := createTestOFACSearcher(repo, watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{
+		EntityID: "1241421",
+		SdnName:  "Jane Doe",
+		Match:    0.50,
+	}, nil))
+	svc := createTestGRPCClient(t, nil, repo, ofac)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-organization", "test", "x-user-id", "operator")
+
+	// the organization is required
+	_, err := svc.GetCustomer(context.Background(), &customerspb.GetCustomerRequest{CustomerId: "foo"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = svc.CreateCustomer(ctx, &customerspb.CreateCustomerRequest{FirstName: "Jane"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	cust, err := svc.CreateCustomer(ctx, &customerspb.CreateCustomerRequest{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Type:      "individual",
+		BirthDate: "1990-01-02",
+		Email:     "jane@example.com",
+		Phones: []*customerspb.Phone{
+			{Number: "+1 555-555-1234", Type: "mobile"},
+		},
+		Addresses: []*customerspb.Address{
+			{Type: "primary", Address1: "123 1st St", City: "Anytown", State: "CA", PostalCode: "90210", Country: "US"},
+		},
+		Metadata: map[string]string{"source": "grpc"},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, cust.CustomerId)
+	require.Equal(t, "1990-01-02", cust.BirthDate)
+	require.Equal(t, string(client.CUSTOMERSTATUS_UNKNOWN), cust.Status)
+	require.Equal(t, "+15555551234", cust.Phones[0].Number)
+	require.Len(t, cust.Addresses, 1)
+	require.Equal(t, map[string]string{"source": "grpc"}, cust.Metadata)
+
+	// HTTP and gRPC read the same records
+	found, err := repo.GetCustomer(ctx, cust.CustomerId, "test")
+	require.NoError(t, err)
+	require.Equal(t, "jane@example.com", found.Email)
+
+	got, err := svc.GetCustomer(ctx, &customerspb.GetCustomerRequest{CustomerId: cust.CustomerId})
+	require.NoError(t, err)
+	require.Equal(t, cust.CustomerId, got.CustomerId)
+
+	otherOrg := metadata.AppendToOutgoingContext(context.Background(), "x-organization", "other")
+	_, err = svc.GetCustomer(otherOrg, &customerspb.GetCustomerRequest{CustomerId: cust.CustomerId})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	list, err := svc.ListCustomers(ctx, &customerspb.ListCustomersRequest{Email: "JANE@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), list.Total)
+	require.Len(t, list.Customers, 1)
+
+	list, err = svc.ListCustomers(otherOrg, &customerspb.ListCustomersRequest{})
+	require.NoError(t, err)
+	require.Zero(t, list.Total)
+
+	search, err := svc.SearchOFAC(ctx, &customerspb.SearchOFACRequest{CustomerId: cust.CustomerId})
+	require.NoError(t, err)
+	require.Equal(t, "1241421", search.EntityId)
+	require.False(t, search.Blocked)
+
+	_, err = svc.UpdateCustomerStatus(ctx, &customerspb.UpdateCustomerStatusRequest{CustomerId: cust.CustomerId, Status: "deceased"})
+	require.NoError(t, err)
+
+	_, err = svc.UpdateCustomerStatus(ctx, &customerspb.UpdateCustomerStatusRequest{CustomerId: cust.CustomerId, Status: "verified"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	history, err := repo.getStatusHistory(ctx, cust.CustomerId)
+	require.NoError(t, err)
+	require.Equal(t, "operator", history[len(history)-1].Actor)
+}
+
+// tokenAuthenticator accepts the bearer tokens it holds
+type tokenAuthenticator map[string]*route.Identity
+
+func (a tokenAuthenticator) Authenticate(r *http.Request) (*route.Identity, error) {
+	if id, ok := a[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]; ok {
+		return id, nil
+	}
+	return nil, errors.New("unknown token")
+}
+
+func TestGRPCServer__Auth(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	ofac := createTestOFACSearcher(repo, watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{EntityID: "1241421", SdnName: "Jane Doe", Match: 0.50}, nil))
+	svc := createTestGRPCClient(t, tokenAuthenticator{
+		"reader": {Subject: "reader", Scopes: []string{route.ScopeRead}},
+		"writer": {Subject: "writer", Scopes: []string{route.ScopeWrite}},
+		"admin":  {Subject: "admin", Organization: "test", Scopes: []string{route.ScopeAdmin}},
+	}, repo, ofac)
+
+	with := func(token string, kv ...string) context.Context {
+		kv = append(kv, "x-organization", "test", "x-user-id", "spoofed")
+		if token != "" {
+			kv = append(kv, "authorization", "Bearer "+token)
+		}
+		return metadata.AppendToOutgoingContext(context.Background(), kv...)
+	}
+	create := &customerspb.CreateCustomerRequest{FirstName: "Jane", LastName: "Doe", Type: "individual"}
+
+	// a token is required
+	_, err := svc.CreateCustomer(with(""), create)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = svc.ListCustomers(with("unknown"), &customerspb.ListCustomersRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// reads need customers:read and writes need customers:write
+	_, err = svc.CreateCustomer(with("reader"), create)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	cust, err := svc.CreateCustomer(with("writer"), create)
+	require.NoError(t, err)
+
+	_, err = svc.GetCustomer(with("reader"), &customerspb.GetCustomerRequest{CustomerId: cust.CustomerId})
+	require.NoError(t, err)
+
+	_, err = svc.SearchOFAC(with("reader"), &customerspb.SearchOFACRequest{CustomerId: cust.CustomerId})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// status updates need customers:admin, like over HTTP
+	update := &customerspb.UpdateCustomerStatusRequest{CustomerId: cust.CustomerId, Status: "deceased"}
+	_, err = svc.UpdateCustomerStatus(with("writer"), update)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// identities limited to an organization can't use another
+	_, err = svc.UpdateCustomerStatus(metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer admin", "x-organization", "other"), update)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = svc.UpdateCustomerStatus(with("admin"), update)
+	require.NoError(t, err)
+
+	// the audit actor is the authenticated subject, not x-user-id
+	history, err := repo.getStatusHistory(context.Background(), cust.CustomerId)
+	require.NoError(t, err)
+	require.Equal(t, "admin", history[len(history)-1].Actor)
+}
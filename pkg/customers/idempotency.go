@@ -0,0 +1,174 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/moov-io/base/database"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+)
+
+// IdempotencyKeyHeader is the HTTP header clients set so retried create requests return
+// the originally created Customer.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255 // size of the idempotency_key column
+
+var (
+	// idempotencyKeyExpiration is how long a key is remembered for. After this a request
+	// with the same key creates a new Customer.
+	idempotencyKeyExpiration = func() time.Duration {
+		if v := os.Getenv("IDEMPOTENCY_KEY_EXPIRATION"); v != "" {
+			dur, err := time.ParseDuration(v)
+			if err == nil && dur > 0 {
+				return dur
+			}
+		}
+		return 24 * time.Hour
+	}()
+
+	// idempotencyKeyWait is how long a request waits on another in-flight request with the same key
+	idempotencyKeyWait     = 10 * time.Second
+	idempotencyKeyInterval = 100 * time.Millisecond
+
+	errIdempotencyKeyTooLong    = fmt.Errorf("%s header is limited to %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength)
+	errIdempotencyKeyInProgress = fmt.Errorf("a request with this %s is still in progress", IdempotencyKeyHeader)
+	errIdempotencyKeyFailed     = fmt.Errorf("a request with this %s failed, retry the request", IdempotencyKeyHeader)
+
+	// errIdempotencyKeyTaken rolls back reserving a key another request has already claimed
+	errIdempotencyKeyTaken = errors.New("idempotency key taken")
+)
+
+type idempotencyRecord struct {
+	CustomerID string
+	Completed  bool
+}
+
+func readIdempotencyKey(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if len(v) > maxIdempotencyKeyLength {
+		return "", errIdempotencyKeyTooLong
+	}
+	return v, nil
+}
+
+// waitForIdempotentCustomer blocks until the request which reserved key has created its Customer
+// and returns the Customer's ID.
+func waitForIdempotentCustomer(ctx context.Context, repo CustomerRepository, key, organization string, record *idempotencyRecord) (string, error) {
+	deadline := time.Now().Add(idempotencyKeyWait)
+	for {
+		if record == nil {
+			return "", errIdempotencyKeyFailed
+		}
+		if record.Completed {
+			return record.CustomerID, nil
+		}
+		if time.Now().After(deadline) {
+			return "", errIdempotencyKeyInProgress
+		}
+		time.Sleep(idempotencyKeyInterval)
+
+		var err error
+		record, err = repo.getIdempotencyKey(ctx, key, organization)
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// reserveIdempotencyKey claims key for customerID. If another request has already claimed key
+// their record is returned instead. Expired keys are replaced.
+func (r *sqlCustomerRepository) reserveIdempotencyKey(ctx context.Context, key, organization, customerID string) (*idempotencyRecord, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	err := customersdb.WithTransactionContext(ctx, r.db, func(tx *sql.Tx) error {
+		query := `delete from idempotency_keys where idempotency_key = ? and organization = ? and created_at < ?;`
+		if _, err := tx.ExecContext(ctx, query, key, organization, time.Now().Add(-1*idempotencyKeyExpiration)); err != nil {
+			return fmt.Errorf("reserveIdempotencyKey: delete expired: %v", err)
+		}
+
+		query = `insert into idempotency_keys (idempotency_key, organization, customer_id, created_at) values (?, ?, ?, ?);`
+		if _, err := tx.ExecContext(ctx, query, key, organization, customerID, time.Now()); err != nil {
+			if database.UniqueViolation(err) {
+				return errIdempotencyKeyTaken
+			}
+			return fmt.Errorf("reserveIdempotencyKey: insert: %v", err)
+		}
+		return nil
+	})
+	if err != errIdempotencyKeyTaken {
+		return nil, err
+	}
+
+	rec, err := r.getIdempotencyKey(ctx, key, organization)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		// the other request failed between our insert and read
+		return nil, errIdempotencyKeyFailed
+	}
+	return rec, nil
+}
+
+func (r *sqlCustomerRepository) getIdempotencyKey(ctx context.Context, key, organization string) (*idempotencyRecord, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select customer_id, completed_at from idempotency_keys where idempotency_key = ? and organization = ? limit 1;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("getIdempotencyKey: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	var rec idempotencyRecord
+	var completedAt *time.Time
+	if err := stmt.QueryRowContext(ctx, key, organization).Scan(&rec.CustomerID, &completedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getIdempotencyKey: scan: %v", err)
+	}
+	rec.Completed = completedAt != nil
+	return &rec, nil
+}
+
+// completeIdempotencyKey marks the Customer as created so requests with the same key return it
+func (r *sqlCustomerRepository) completeIdempotencyKey(ctx context.Context, key, organization string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `update idempotency_keys set completed_at = ? where idempotency_key = ? and organization = ?;`
+	res, err := r.db.ExecContext(ctx, query, time.Now(), key, organization)
+	if err != nil {
+		return fmt.Errorf("completeIdempotencyKey: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("completeIdempotencyKey: key not found")
+	}
+	return nil
+}
+
+// releaseIdempotencyKey removes the reservation of a request that failed so it can be retried
+func (r *sqlCustomerRepository) releaseIdempotencyKey(ctx context.Context, key, organization string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `delete from idempotency_keys where idempotency_key = ? and organization = ? and completed_at is null;`
+	if _, err := r.db.ExecContext(ctx, query, key, organization); err != nil {
+		return fmt.Errorf("releaseIdempotencyKey: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestIdempotency__readIdempotencyKey(t *testing.T) {
+	key, err := readIdempotencyKey(" abc ")
+	require.NoError(t, err)
+	require.Equal(t, "abc", key)
+
+	_, err = readIdempotencyKey(strings.Repeat("a", maxIdempotencyKeyLength+1))
+	require.Equal(t, errIdempotencyKeyTooLong, err)
+}
+
+func TestIdempotencyRepository(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	key, organization := base.ID(), "organization"
+	customerID := base.ID()
+
+	rec, err := repo.reserveIdempotencyKey(context.Background(), key, organization, customerID)
+	require.NoError(t, err)
+	require.Nil(t, rec)
+
+	// a second request sees the first is in progress
+	rec, err = repo.reserveIdempotencyKey(context.Background(), key, organization, base.ID())
+	require.NoError(t, err)
+	require.Equal(t, &idempotencyRecord{CustomerID: customerID}, rec)
+
+	// keys are separated by organization
+	rec, err = repo.reserveIdempotencyKey(context.Background(), key, "other", base.ID())
+	require.NoError(t, err)
+	require.Nil(t, rec)
+
+	require.NoError(t, repo.completeIdempotencyKey(context.Background(), key, organization))
+	require.NoError(t, repo.releaseIdempotencyKey(context.Background(), key, organization)) // completed keys are kept
+
+	rec, err = repo.getIdempotencyKey(context.Background(), key, organization)
+	require.NoError(t, err)
+	require.Equal(t, &idempotencyRecord{CustomerID: customerID, Completed: true}, rec)
+
+	// expired keys are replaced
+	_, err = repo.db.Exec(`update idempotency_keys set created_at = ? where idempotency_key = ?;`, time.Now().Add(-2*idempotencyKeyExpiration), key)
+	require.NoError(t, err)
+	rec, err = repo.reserveIdempotencyKey(context.Background(), key, organization, base.ID())
+	require.NoError(t, err)
+	require.Nil(t, rec)
+
+	// failed requests release their key
+	require.NoError(t, repo.releaseIdempotencyKey(context.Background(), key, organization))
+	rec, err = repo.getIdempotencyKey(context.Background(), key, organization)
+	require.NoError(t, err)
+	require.Nil(t, rec)
+}
+
+func TestCustomers__createCustomerIdempotent(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
+
+	create := func(key string) string {
+		body := `{"firstName": "jane", "lastName": "doe", "type": "individual"}`
+		req := httptest.NewRequest("POST", "/customers", strings.NewReader(body))
+		req.Header.Set("x-organization", "test")
+		req.Header.Set(IdempotencyKeyHeader, key)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		w.Flush()
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var cust client.Customer
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&cust))
+		return cust.CustomerID
+	}
+
+	first := create("key1")
+	require.Equal(t, first, create("key1"))
+	require.NotEqual(t, first, create("key2"))
+	require.NotEqual(t, create(""), create(""))
+
+	// concurrent requests with the same key create one Customer
+	var wg sync.WaitGroup
+	ids := make([]string, 3)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i] = create("key3")
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, ids[0], ids[1])
+	require.Equal(t, ids[0], ids[2])
+}
+
+func TestCustomers__waitForIdempotentCustomer(t *testing.T) {
+	interval, wait := idempotencyKeyInterval, idempotencyKeyWait
+	idempotencyKeyInterval, idempotencyKeyWait = time.Millisecond, 10*time.Millisecond
+	defer func() {
+		idempotencyKeyInterval, idempotencyKeyWait = interval, wait
+	}()
+
+	repo := &testCustomerRepository{
+		idempotencyRecord: &idempotencyRecord{CustomerID: "foo"},
+	}
+	_, err := waitForIdempotentCustomer(context.Background(), repo, "key", "organization", repo.idempotencyRecord)
+	require.Equal(t, errIdempotencyKeyInProgress, err)
+
+	repo.idempotencyRecord.Completed = true
+	customerID, err := waitForIdempotentCustomer(context.Background(), repo, "key", "organization", repo.idempotencyRecord)
+	require.NoError(t, err)
+	require.Equal(t, "foo", customerID)
+
+	_, err = waitForIdempotentCustomer(context.Background(), repo, "key", "organization", nil)
+	require.Equal(t, errIdempotencyKeyFailed, err)
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// supportedLocales are the BCP 47 tags accepted as a Customer's locale, in their canonical form. Language
+// only tags are accepted for Customers without a regional preference.
+var supportedLocales = map[string]bool{
+	"ar": true, "ar-SA": true,
+	"de": true, "de-AT": true, "de-CH": true, "de-DE": true,
+	"en": true, "en-AU": true, "en-CA": true, "en-GB": true, "en-IE": true, "en-IN": true, "en-NZ": true, "en-US": true,
+	"es": true, "es-419": true, "es-ES": true, "es-MX": true, "es-US": true,
+	"fr": true, "fr-BE": true, "fr-CA": true, "fr-CH": true, "fr-FR": true,
+	"hi": true, "hi-IN": true,
+	"it": true, "it-IT": true,
+	"ja": true, "ja-JP": true,
+	"ko": true, "ko-KR": true,
+	"nl": true, "nl-NL": true,
+	"pl": true, "pl-PL": true,
+	"pt": true, "pt-BR": true, "pt-PT": true,
+	"ru": true, "ru-RU": true,
+	"tl": true, "tl-PH": true,
+	"uk": true, "uk-UA": true,
+	"vi": true, "vi-VN": true,
+	"zh": true, "zh-CN": true, "zh-Hans": true, "zh-Hant": true, "zh-HK": true, "zh-TW": true,
+}
+
+// defaultCustomerLocale is the locale of Customers created without one and of those created before
+// locales were recorded.
+var defaultCustomerLocale = func() string {
+	if locale, err := normalizeLocale(os.Getenv("CUSTOMER_DEFAULT_LOCALE")); err == nil && locale != "" {
+		return locale
+	}
+	return "en-US"
+}()
+
+// normalizeLocale returns the canonical form of a BCP 47 tag, e.g. en-us becomes en-US and zh_hant
+// becomes zh-Hant, and rejects tags which aren't supported. Empty tags are returned as-is.
+func normalizeLocale(tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return "", nil
+	}
+	parts := strings.Split(strings.ReplaceAll(tag, "_", "-"), "-")
+	for i := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(parts[i])
+		case len(parts[i]) == 4:
+			parts[i] = strings.Title(strings.ToLower(parts[i]))
+		default:
+			parts[i] = strings.ToUpper(parts[i])
+		}
+	}
+	locale := strings.Join(parts, "-")
+	if !supportedLocales[locale] {
+		return "", fmt.Errorf("%q is not a supported locale", tag)
+	}
+	return locale, nil
+}
+
+// customerLocale returns the locale for a Customer, using defaultCustomerLocale when none is recorded
+func customerLocale(locale string) string {
+	if normalized, err := normalizeLocale(locale); err == nil && normalized != "" {
+		return normalized
+	}
+	return defaultCustomerLocale
+}
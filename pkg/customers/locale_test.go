@@ -0,0 +1,93 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	for tag, expected := range map[string]string{
+		"":        "",
+		"en-US":   "en-US",
+		"en-us":   "en-US",
+		"EN_gb":   "en-GB",
+		"fr":      "fr",
+		"zh-hant": "zh-Hant",
+		"es-419":  "es-419",
+	} {
+		locale, err := normalizeLocale(tag)
+		require.NoError(t, err, tag)
+		require.Equal(t, expected, locale, tag)
+	}
+	for _, tag := range []string{"english", "en-XX", "xx", "en--US"} {
+		_, err := normalizeLocale(tag)
+		require.Error(t, err, tag)
+	}
+
+	require.Equal(t, "fr-CA", customerLocale("fr-ca"))
+	require.Equal(t, defaultCustomerLocale, customerLocale(""))
+	require.Equal(t, defaultCustomerLocale, customerLocale("other"))
+}
+
+func TestCustomers__locale(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), createTestOFACSearcher(nil, nil), nil, nil)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Organization", "test")
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	read := func(w *httptest.ResponseRecorder) client.Customer {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var cust client.Customer
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&cust))
+		return cust
+	}
+
+	cust := read(send("POST", "/customers", `{"firstName": "Jane", "lastName": "Doe", "type": "individual"}`))
+	require.Equal(t, defaultCustomerLocale, cust.Locale)
+
+	cust = read(send("POST", "/customers", `{"firstName": "Jean", "lastName": "Doe", "type": "individual", "locale": "fr-ca"}`))
+	require.Equal(t, "fr-CA", cust.Locale)
+
+	w := send("POST", "/customers", `{"firstName": "Jane", "lastName": "Doe", "type": "individual", "locale": "klingon"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), "not a supported locale")
+
+	cust = read(send("PATCH", "/customers/"+cust.CustomerID, `{"locale": "es-MX"}`))
+	require.Equal(t, "es-MX", cust.Locale)
+	w = send("PATCH", "/customers/"+cust.CustomerID, `{"locale": "xx"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	found, err := repo.GetCustomer(context.Background(), cust.CustomerID, "test")
+	require.NoError(t, err)
+	require.Equal(t, "es-MX", found.Locale)
+
+	// customers created before locales were recorded use the default
+	_, err = repo.db.Exec(`update customers set locale = null where customer_id = ?;`, cust.CustomerID)
+	require.NoError(t, err)
+	found, err = repo.GetCustomer(context.Background(), cust.CustomerID, "test")
+	require.NoError(t, err)
+	require.Equal(t, defaultCustomerLocale, found.Locale)
+}
@@ -0,0 +1,242 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// OFAC search results a worklist can be filtered by, matching the result label of the ofac_searches metric
+const (
+	ofacResultBlocked = "blocked"
+	ofacResultReview  = "review"
+	ofacResultClear   = "clear"
+)
+
+// OFACMatch is a Customer with their latest OFAC search
+type OFACMatch struct {
+	CustomerID     string                `json:"customerID"`
+	FirstName      string                `json:"firstName"`
+	LastName       string                `json:"lastName"`
+	BusinessName   string                `json:"businessName,omitempty"`
+	Status         client.CustomerStatus `json:"status"`
+	EntityID       string                `json:"entityID"`
+	SdnName        string                `json:"sdnName"`
+	Match          float32               `json:"match"`
+	Blocked        bool                  `json:"blocked"`
+	ReviewRequired bool                  `json:"reviewRequired"`
+	SearchedAt     time.Time             `json:"searchedAt"`
+}
+
+type OFACMatchParams struct {
+	Organization string
+
+	// MinMatch and MaxMatch limit the match score of the latest search, inclusive
+	MinMatch *float64
+	MaxMatch *float64
+
+	// Results is any of blocked, review and clear
+	Results []string
+
+	Skip  int64
+	Count int64
+}
+
+func searchOFACMatches(logger log.Logger, repo CustomerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		params, err := parseOFACMatchParams(r)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		params.Organization = organization
+
+		matches, err := repo.searchOFACMatches(r.Context(), params)
+		if err != nil {
+			logger.LogErrorf("problem searching OFAC matches: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		total, err := repo.countOFACMatches(r.Context(), params)
+		if err != nil {
+			logger.LogErrorf("problem counting OFAC matches: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set(totalCountHeaderKey, fmt.Sprintf("%d", total))
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(matches)
+	}
+}
+
+func parseOFACMatchParams(r *http.Request) (OFACMatchParams, error) {
+	var params OFACMatchParams
+	q := r.URL.Query()
+
+	readMatch := func(key string) (*float64, error) {
+		v := strings.TrimSpace(q.Get(key))
+		if v == "" {
+			return nil, nil
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n < 0 || n > 1 {
+			return nil, route.Validation(fmt.Errorf("%s must be a number between 0 and 1", key))
+		}
+		return &n, nil
+	}
+	var err error
+	if params.MinMatch, err = readMatch("minMatch"); err != nil {
+		return params, err
+	}
+	if params.MaxMatch, err = readMatch("maxMatch"); err != nil {
+		return params, err
+	}
+	if params.MinMatch != nil && params.MaxMatch != nil && *params.MinMatch > *params.MaxMatch {
+		return params, route.Validation(errors.New("minMatch is larger than maxMatch"))
+	}
+
+	if v := q.Get("result"); v != "" {
+		for _, res := range strings.Split(v, ",") {
+			res = strings.ToLower(strings.TrimSpace(res))
+			switch res {
+			case ofacResultBlocked, ofacResultReview, ofacResultClear:
+				params.Results = append(params.Results, res)
+			default:
+				return params, route.Validation(fmt.Errorf("unknown OFAC result %q", res))
+			}
+		}
+	}
+
+	skip, count, exists, err := moovhttp.GetSkipAndCount(r)
+	if exists && err != nil {
+		return params, err
+	}
+	params.Skip = int64(skip)
+	params.Count = int64(count)
+
+	return params, nil
+}
+
+// buildOFACMatchFilters returns the joins and where clause (and its arguments) shared by searching
+// and counting OFAC matches. Only each Customer's latest search is considered.
+func buildOFACMatchFilters(params OFACMatchParams) (string, []interface{}) {
+	query := ` from customers as c
+inner join customer_ofac_searches as cos on cos.customer_id = c.customer_id
+where c.organization = ? and c.deleted_at is null
+and cos.created_at = (select max(latest.created_at) from customer_ofac_searches as latest where latest.customer_id = c.customer_id)`
+	args := []interface{}{params.Organization}
+
+	if params.MinMatch != nil {
+		query += " and cos.percentage_match >= ?"
+		args = append(args, *params.MinMatch)
+	}
+	if params.MaxMatch != nil {
+		query += " and cos.percentage_match <= ?"
+		args = append(args, *params.MaxMatch)
+	}
+
+	if len(params.Results) > 0 {
+		var results []string
+		for _, res := range params.Results {
+			switch res {
+			case ofacResultBlocked:
+				results = append(results, "cos.blocked = ?")
+				args = append(args, true)
+			case ofacResultReview:
+				results = append(results, "(cos.blocked = ? and coalesce(cos.review_required, ?) = ?)")
+				args = append(args, false, false, true)
+			case ofacResultClear:
+				results = append(results, "(cos.blocked = ? and coalesce(cos.review_required, ?) = ?)")
+				args = append(args, false, false, false)
+			}
+		}
+		query += " and (" + strings.Join(results, " or ") + ")"
+	}
+	return query, args
+}
+
+func (r *sqlCustomerRepository) searchOFACMatches(ctx context.Context, params OFACMatchParams) ([]*OFACMatch, error) {
+	filters, args := buildOFACMatchFilters(params)
+	query := `select c.customer_id, c.first_name, c.last_name, c.business_name, c.status, cos.entity_id, cos.sdn_name, cos.percentage_match, cos.blocked, cos.review_required, cos.created_at` +
+		filters + ` order by cos.percentage_match desc, c.customer_id asc limit ?`
+	args = append(args, params.Count)
+	if params.Skip > 0 {
+		query += " offset ?"
+		args = append(args, params.Skip)
+	}
+
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	stmt, err := r.db.PrepareContext(ctx, query+";")
+	if err != nil {
+		return nil, fmt.Errorf("searchOFACMatches: prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searchOFACMatches: query: %w", err)
+	}
+	defer rows.Close()
+
+	matches := make([]*OFACMatch, 0)
+	for rows.Next() {
+		var m OFACMatch
+		var reviewRequired *bool
+		err := rows.Scan(&m.CustomerID, &m.FirstName, &m.LastName, &m.BusinessName, &m.Status, &m.EntityID, &m.SdnName, &m.Match, &m.Blocked, &reviewRequired, &m.SearchedAt)
+		if err != nil {
+			return nil, fmt.Errorf("searchOFACMatches: scan: %v", err)
+		}
+		if reviewRequired != nil {
+			m.ReviewRequired = *reviewRequired
+		}
+		matches = append(matches, &m)
+	}
+	return matches, rows.Err()
+}
+
+func (r *sqlCustomerRepository) countOFACMatches(ctx context.Context, params OFACMatchParams) (int64, error) {
+	filters, args := buildOFACMatchFilters(params)
+
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	stmt, err := r.db.PrepareContext(ctx, `select count(*)`+filters+";")
+	if err != nil {
+		return 0, fmt.Errorf("countOFACMatches: prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	var total int64
+	if err := stmt.QueryRowContext(ctx, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("countOFACMatches: scan: %w", err)
+	}
+	return total, nil
+}
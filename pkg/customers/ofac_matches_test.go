@@ -0,0 +1,102 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestOFACMatches__parseParams(t *testing.T) {
+	req := httptest.NewRequest("GET", "/customers/ofac-matches?minMatch=0.8&maxMatch=1&result=Review,blocked&skip=10&count=5", nil)
+	params, err := parseOFACMatchParams(req)
+	require.NoError(t, err)
+	require.Equal(t, 0.8, *params.MinMatch)
+	require.Equal(t, 1.0, *params.MaxMatch)
+	require.Equal(t, []string{"review", "blocked"}, params.Results)
+	require.Equal(t, int64(10), params.Skip)
+	require.Equal(t, int64(5), params.Count)
+
+	for _, q := range []string{"minMatch=high", "maxMatch=80", "minMatch=0.9&maxMatch=0.5", "result=pending"} {
+		_, err := parseOFACMatchParams(httptest.NewRequest("GET", "/customers/ofac-matches?"+q, nil))
+		require.Error(t, err, q)
+	}
+}
+
+func TestOFACMatches(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	create := func(customerID, organization string, searches ...client.OfacSearch) {
+		cust := &client.Customer{CustomerID: customerID, FirstName: "Jane", LastName: customerID, Type: client.CUSTOMERTYPE_INDIVIDUAL}
+		require.NoError(t, repo.CreateCustomer(context.Background(), cust, organization))
+		for i := range searches {
+			searches[i].CreatedAt = time.Now().Add(time.Duration(i-len(searches)) * time.Minute)
+			require.NoError(t, repo.saveCustomerOFACSearch(context.Background(), customerID, searches[i]))
+		}
+	}
+	create("blocked", "test", client.OfacSearch{EntityID: "1", SdnName: "Jane Blocked", Match: 0.99, Blocked: true})
+	create("review", "test", client.OfacSearch{EntityID: "2", SdnName: "Jane Review", Match: 0.90, ReviewRequired: true})
+	create("cleared", "test",
+		client.OfacSearch{EntityID: "3", SdnName: "Jane Old", Match: 0.95, ReviewRequired: true},
+		client.OfacSearch{EntityID: "4", SdnName: "Jane Cleared", Match: 0.40},
+	)
+	create("unsearched", "test")
+	create("other", "other", client.OfacSearch{EntityID: "5", SdnName: "Jane Other", Match: 0.99, Blocked: true})
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), nil, nil, nil)
+
+	search := func(query string) ([]*OFACMatch, string) {
+		req := httptest.NewRequest("GET", "/customers/ofac-matches?"+query, nil)
+		req.Header.Set("X-Organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var matches []*OFACMatch
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&matches))
+		return matches, w.Header().Get(totalCountHeaderKey)
+	}
+	ids := func(matches []*OFACMatch) []string {
+		var out []string
+		for i := range matches {
+			out = append(out, matches[i].CustomerID)
+		}
+		return out
+	}
+
+	// only the latest search of each customer is used, highest matches first
+	matches, total := search("")
+	require.Equal(t, []string{"blocked", "review", "cleared"}, ids(matches))
+	require.Equal(t, "3", total)
+	require.Equal(t, "4", matches[2].EntityID)
+	require.Equal(t, "Jane Cleared", matches[2].SdnName)
+
+	matches, total = search("result=review,blocked")
+	require.Equal(t, []string{"blocked", "review"}, ids(matches))
+	require.Equal(t, "2", total)
+
+	matches, _ = search("result=review")
+	require.Equal(t, []string{"review"}, ids(matches))
+	require.True(t, matches[0].ReviewRequired)
+
+	matches, _ = search("minMatch=0.5&maxMatch=0.95")
+	require.Equal(t, []string{"review"}, ids(matches))
+
+	matches, total = search("skip=1&count=1")
+	require.Equal(t, []string{"review"}, ids(matches))
+	require.Equal(t, "3", total)
+}
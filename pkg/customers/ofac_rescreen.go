@@ -0,0 +1,435 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+const ofacRescreenActor = "ofac-rescreen"
+
+var (
+	errRescreenRunning = route.NewError(http.StatusConflict, route.CodeConflict, "an OFAC rescreen is already running")
+
+	ofacRescreenedCustomers = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "ofac_rescreen_customers",
+		Help: "Counter of customers screened by the OFAC rescreen job by their result",
+	}, []string{"result"})
+
+	ofacRescreenProgress = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Name: "ofac_rescreen_progress",
+		Help: "Customers screened so far in the current OFAC rescreen and the total to screen",
+	}, []string{"count"})
+)
+
+// RescreenConfig holds the settings for periodically screening every Customer against OFAC
+type RescreenConfig struct {
+	// Interval is how often a new rescreen starts. Zero only runs rescreens triggered by an admin.
+	Interval time.Duration
+
+	// Delay is the pause between each Customer to limit the load put on Watchman
+	Delay time.Duration
+
+	// BatchSize is how many Customers are read at once, progress is saved after each batch
+	BatchSize int
+}
+
+// RescreenRun is the progress of one pass over every Customer
+type RescreenRun struct {
+	RunID string `json:"runID"`
+
+	// LastCustomerID is the last Customer screened, an interrupted run resumes after it
+	LastCustomerID string `json:"lastCustomerID,omitempty"`
+
+	Screened int `json:"screened"`
+	Flagged  int `json:"flagged"`
+	Rejected int `json:"rejected"`
+	Failed   int `json:"failed"`
+
+	StartedAt   time.Time  `json:"startedAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// OFACRescreener runs a new OFAC search for every Customer which isn't deleted, so Customers who
+// match a newly added SDN are caught. Customers matching above the threshold are rejected and those
+// above the review threshold are flagged. Runs save their progress so a restart resumes where the
+// last run stopped instead of starting over.
+type OFACRescreener struct {
+	logger    log.Logger
+	repo      rescreenRepository
+	customers CustomerRepository
+	ofac      *OFACSearcher
+	notifier  webhooks.Notifier
+	cfg       RescreenConfig
+
+	mu      sync.Mutex
+	running bool
+}
+
+func NewOFACRescreener(logger log.Logger, db customersdb.Querier, customers CustomerRepository, ofac *OFACSearcher, notifier webhooks.Notifier, cfg RescreenConfig) *OFACRescreener {
+	if cfg.Delay < 0 {
+		cfg.Delay = 0
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	logger = logger.Set("package", log.String("customers"))
+	return &OFACRescreener{
+		logger:    logger,
+		repo:      &sqlRescreenRepository{db: db, logger: logger},
+		customers: customers,
+		ofac:      ofac,
+		notifier:  notifier,
+		cfg:       cfg,
+	}
+}
+
+// Start resumes an interrupted run and then starts a new run every interval until ctx is cancelled.
+func (s *OFACRescreener) Start(ctx context.Context) {
+	if run, err := s.repo.latestRun(); err != nil {
+		s.logger.LogErrorf("problem reading latest OFAC rescreen: %v", err)
+	} else if run != nil && run.CompletedAt == nil {
+		s.logger.Logf("resuming OFAC rescreen run=%s after customer=%s", run.RunID, run.LastCustomerID)
+		if _, err := s.Run(ctx); err != nil {
+			s.logger.LogErrorf("problem resuming OFAC rescreen: %v", err)
+		}
+	}
+	if s.cfg.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Run(ctx); err != nil {
+				s.logger.LogErrorf("problem running OFAC rescreen: %v", err)
+			}
+		}
+	}
+}
+
+// Run screens every Customer, continuing an unfinished run if there is one. It returns
+// errRescreenRunning if another run is in progress.
+func (s *OFACRescreener) Run(ctx context.Context) (*RescreenRun, error) {
+	run, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer s.finish()
+
+	return run, s.process(ctx, run)
+}
+
+// begin claims the rescreener and returns the run to work on
+func (s *OFACRescreener) begin() (*RescreenRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return nil, errRescreenRunning
+	}
+
+	run, err := s.repo.latestRun()
+	if err != nil {
+		return nil, err
+	}
+	if run == nil || run.CompletedAt != nil {
+		now := time.Now()
+		run = &RescreenRun{
+			RunID:     base.ID(),
+			StartedAt: now,
+			UpdatedAt: now,
+		}
+		if err := s.repo.createRun(run); err != nil {
+			return nil, err
+		}
+	}
+	s.running = true
+	return run, nil
+}
+
+func (s *OFACRescreener) finish() {
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+}
+
+// process screens each Customer after run.LastCustomerID, saving progress after every batch
+func (s *OFACRescreener) process(ctx context.Context, run *RescreenRun) error {
+	logger := s.logger.Set("runID", log.String(run.RunID))
+
+	total, err := s.repo.countCustomers()
+	if err != nil {
+		return err
+	}
+	ofacRescreenProgress.With("count", "total").Set(float64(total))
+
+	first := true
+	for {
+		refs, err := s.repo.nextCustomers(run.LastCustomerID, s.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(refs) == 0 {
+			break
+		}
+		for i := range refs {
+			if !first {
+				select {
+				case <-ctx.Done():
+					return s.save(run)
+				case <-time.After(s.cfg.Delay):
+				}
+			}
+			first = false
+
+			result := s.screen(ctx, logger, refs[i])
+			switch result {
+			case "failed":
+				run.Failed++
+			case "review":
+				run.Flagged++
+			case "rejected":
+				run.Rejected++
+			}
+			ofacRescreenedCustomers.With("result", result).Add(1)
+
+			run.Screened++
+			run.LastCustomerID = refs[i].customerID
+			ofacRescreenProgress.With("count", "screened").Set(float64(run.Screened))
+		}
+		if err := s.save(run); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	run.CompletedAt = &now
+	if err := s.save(run); err != nil {
+		return err
+	}
+	logger.Logf("finished OFAC rescreen of %d customers: %d flagged, %d rejected, %d failed", run.Screened, run.Flagged, run.Rejected, run.Failed)
+	return nil
+}
+
+func (s *OFACRescreener) save(run *RescreenRun) error {
+	run.UpdatedAt = time.Now()
+	return s.repo.saveRun(run)
+}
+
+// screen searches Watchman for one Customer and returns its result: clear, review, rejected or failed
+func (s *OFACRescreener) screen(ctx context.Context, logger log.Logger, ref customerRef) string {
+	logger = logger.Set("customerID", log.String(ref.customerID))
+
+	cust, err := s.customers.GetCustomer(ctx, ref.customerID, ref.organization)
+	if err != nil || cust == nil {
+		logger.LogErrorf("problem reading customer for OFAC rescreen: %v", err)
+		return "failed"
+	}
+
+	// stored timestamps can lose their fractional seconds
+	searchedAt := time.Now().Add(-time.Second)
+	if err := s.ofac.storeCustomerOFACSearch(cust, ""); err != nil {
+		logger.LogErrorf("problem running OFAC rescreen: %v", err)
+		return "failed"
+	}
+	result, err := s.customers.getLatestCustomerOFACSearch(ctx, ref.customerID, ref.organization)
+	if err != nil {
+		logger.LogErrorf("problem reading OFAC rescreen result: %v", err)
+		return "failed"
+	}
+	if result == nil || result.CreatedAt.Before(searchedAt) {
+		return "clear" // Watchman had no match so nothing was saved
+	}
+
+	rejected, err := rejectBlockedCustomer(ctx, logger, s.customers, cust, result, "OFAC rescreen", ofacRescreenActor)
+	if err != nil {
+		logger.LogErrorf("problem rejecting customer: %v", err)
+		return "failed"
+	}
+	switch {
+	case rejected:
+		notify(s.notifier, webhooks.CustomerStatusUpdated, cust.CustomerID, ref.organization, client.CUSTOMERSTATUS_REJECTED)
+		return "rejected"
+	case result.ReviewRequired:
+		return "review"
+	}
+	return "clear"
+}
+
+// AddOFACRescreenAdminRoutes registers the admin routes to read and trigger OFAC rescreens
+func AddOFACRescreenAdminRoutes(logger log.Logger, svc *admin.Server, rescreener *OFACRescreener) {
+	logger = logger.Set("package", log.String("customers"))
+
+	svc.AddHandler("/ofac/rescreen", ofacRescreen(logger, rescreener))
+}
+
+func ofacRescreen(logger log.Logger, rescreener *OFACRescreener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		switch r.Method {
+		case "GET":
+			run, err := rescreener.repo.latestRun()
+			if err != nil {
+				route.Problem(w, err)
+				return
+			}
+			if run == nil {
+				route.NotFound(w, r)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(run)
+
+		case "POST":
+			run, err := rescreener.begin()
+			if err != nil {
+				route.Problem(w, err)
+				return
+			}
+			started := *run // the run keeps changing once it's processed
+			go func() {
+				defer rescreener.finish()
+				if err := rescreener.process(context.Background(), run); err != nil {
+					logger.LogErrorf("problem running OFAC rescreen: %v", err)
+				}
+			}()
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(started)
+
+		default:
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+		}
+	}
+}
+
+type customerRef struct {
+	customerID   string
+	organization string
+}
+
+type rescreenRepository interface {
+	latestRun() (*RescreenRun, error)
+	createRun(run *RescreenRun) error
+	saveRun(run *RescreenRun) error
+
+	countCustomers() (int, error)
+	nextCustomers(afterCustomerID string, limit int) ([]customerRef, error)
+}
+
+type sqlRescreenRepository struct {
+	db     customersdb.Querier
+	logger log.Logger
+}
+
+func (r *sqlRescreenRepository) latestRun() (*RescreenRun, error) {
+	query := `select run_id, last_customer_id, screened, flagged, rejected, failed, started_at, updated_at, completed_at
+from ofac_rescreen_runs order by started_at desc limit 1;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("latestRun: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	var run RescreenRun
+	err = stmt.QueryRow().Scan(&run.RunID, &run.LastCustomerID, &run.Screened, &run.Flagged, &run.Rejected, &run.Failed, &run.StartedAt, &run.UpdatedAt, &run.CompletedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("latestRun: scan: %v", err)
+	}
+	return &run, nil
+}
+
+func (r *sqlRescreenRepository) createRun(run *RescreenRun) error {
+	query := `insert into ofac_rescreen_runs (run_id, started_at, updated_at) values (?, ?, ?);`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("createRun: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(run.RunID, run.StartedAt, run.UpdatedAt); err != nil {
+		return fmt.Errorf("createRun: exec: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlRescreenRepository) saveRun(run *RescreenRun) error {
+	query := `update ofac_rescreen_runs set last_customer_id = ?, screened = ?, flagged = ?, rejected = ?, failed = ?, updated_at = ?, completed_at = ?
+where run_id = ?;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("saveRun: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(run.LastCustomerID, run.Screened, run.Flagged, run.Rejected, run.Failed, run.UpdatedAt, run.CompletedAt, run.RunID)
+	if err != nil {
+		return fmt.Errorf("saveRun: exec: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlRescreenRepository) countCustomers() (int, error) {
+	var n int
+	if err := r.db.QueryRow(`select count(*) from customers where deleted_at is null;`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("countCustomers: %v", err)
+	}
+	return n, nil
+}
+
+func (r *sqlRescreenRepository) nextCustomers(afterCustomerID string, limit int) ([]customerRef, error) {
+	query := `select customer_id, organization from customers where deleted_at is null and customer_id > ?
+order by customer_id limit ?;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("nextCustomers: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(afterCustomerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("nextCustomers: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []customerRef
+	for rows.Next() {
+		var ref customerRef
+		if err := rows.Scan(&ref.customerID, &ref.organization); err != nil {
+			return nil, fmt.Errorf("nextCustomers: scan: %v", err)
+		}
+		out = append(out, ref)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,162 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/watchman"
+	watchmanClient "github.com/moov-io/watchman/client"
+	"github.com/stretchr/testify/require"
+)
+
+func createRescreenCustomers(t *testing.T, repo *sqlCustomerRepository, n int) []string {
+	t.Helper()
+
+	var customerIDs []string
+	for i := 0; i < n; i++ {
+		req := customerRequest{
+			CustomerID: base.ID(),
+			FirstName:  "Jane",
+			LastName:   "Doe",
+		}
+		cust, _, _ := req.asCustomer(testCustomerSSNStorage(t))
+		require.NoError(t, repo.CreateCustomer(context.Background(), cust, "organization"))
+		customerIDs = append(customerIDs, cust.CustomerID)
+	}
+	sort.Strings(customerIDs)
+	return customerIDs
+}
+
+func TestOFACRescreener__Run(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	customerIDs := createRescreenCustomers(t, repo, 3)
+
+	ofacClient := watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{
+		EntityID: "1241421",
+		SdnName:  "Jane Doe",
+		Match:    1.0,
+	}, nil)
+	rescreener := NewOFACRescreener(log.NewNopLogger(), repo.db, repo, createTestOFACSearcher(repo, ofacClient), nil, RescreenConfig{
+		BatchSize: 2,
+	})
+
+	run, err := rescreener.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, run.Screened)
+	require.Equal(t, 3, run.Rejected)
+	require.Equal(t, customerIDs[2], run.LastCustomerID)
+	require.NotNil(t, run.CompletedAt)
+
+	for _, customerID := range customerIDs {
+		cust, err := repo.GetCustomer(context.Background(), customerID, "organization")
+		require.NoError(t, err)
+		require.Equal(t, client.CUSTOMERSTATUS_REJECTED, cust.Status)
+
+		result, err := repo.getLatestCustomerOFACSearch(context.Background(), customerID, "organization")
+		require.NoError(t, err)
+		require.Equal(t, "1241421", result.EntityID)
+	}
+
+	// the next run starts over and doesn't reject anyone twice
+	next, err := rescreener.Run(context.Background())
+	require.NoError(t, err)
+	require.NotEqual(t, run.RunID, next.RunID)
+	require.Equal(t, 3, next.Screened)
+	require.Equal(t, 0, next.Rejected)
+}
+
+func TestOFACRescreener__resume(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	customerIDs := createRescreenCustomers(t, repo, 3)
+
+	ofacClient := watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{
+		EntityID: "1241421",
+		Match:    0.50,
+	}, nil)
+	rescreener := NewOFACRescreener(log.NewNopLogger(), repo.db, repo, createTestOFACSearcher(repo, ofacClient), nil, RescreenConfig{})
+
+	// an earlier run stopped after the first customer
+	interrupted := &RescreenRun{
+		RunID:     base.ID(),
+		StartedAt: time.Now().Add(-time.Hour),
+		UpdatedAt: time.Now().Add(-time.Hour),
+	}
+	require.NoError(t, rescreener.repo.createRun(interrupted))
+	interrupted.LastCustomerID = customerIDs[0]
+	interrupted.Screened = 1
+	require.NoError(t, rescreener.repo.saveRun(interrupted))
+
+	run, err := rescreener.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, interrupted.RunID, run.RunID)
+	require.Equal(t, 3, run.Screened)
+	require.Equal(t, 0, run.Rejected)
+	require.NotNil(t, run.CompletedAt)
+
+	result, err := repo.getLatestCustomerOFACSearch(context.Background(), customerIDs[0], "organization")
+	require.NoError(t, err)
+	require.Nil(t, result) // never searched by this run
+
+	latest, err := rescreener.repo.latestRun()
+	require.NoError(t, err)
+	require.Equal(t, run.RunID, latest.RunID)
+	require.NotNil(t, latest.CompletedAt)
+}
+
+func TestOFACRescreener__admin(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	createRescreenCustomers(t, repo, 1)
+
+	rescreener := NewOFACRescreener(log.NewNopLogger(), repo.db, repo, createTestOFACSearcher(repo, nil), nil, RescreenConfig{})
+	handler := ofacRescreen(log.NewNopLogger(), rescreener)
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/ofac/rescreen", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// only one run happens at a time
+	rescreener.running = true
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/ofac/rescreen", nil))
+	require.Equal(t, http.StatusConflict, w.Code)
+	rescreener.running = false
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/ofac/rescreen", nil))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+	var started RescreenRun
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&started))
+	require.NotEmpty(t, started.RunID)
+
+	require.Eventually(t, func() bool {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/ofac/rescreen", nil))
+
+		var run RescreenRun
+		json.NewDecoder(w.Body).Decode(&run)
+		return w.Code == http.StatusOK && run.RunID == started.RunID && run.CompletedAt != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("DELETE", "/ofac/rescreen", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+)
+
+var (
+	ofacSearchesPurged = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "ofac_searches_purged",
+		Help: "Counter of customer OFAC searches deleted after the retention period",
+	}, nil)
+)
+
+// OFACRetentionConfig holds the settings for deleting old OFAC searches
+type OFACRetentionConfig struct {
+	// Period is how long OFAC searches are kept. Searches are kept forever when Period is zero.
+	Period time.Duration
+
+	// Interval is how often old searches are deleted
+	Interval time.Duration
+
+	// BatchSize is how many Customers are read from the database at once
+	BatchSize int
+}
+
+// OFACSearchPurger deletes Customer OFAC searches older than the retention period, except each
+// Customer's latest search which is always kept for audit and to decide if they can be approved.
+//
+// Deletes only depend on the stored searches, so running a sweep twice (or one after an interrupted
+// sweep) is safe.
+type OFACSearchPurger struct {
+	logger log.Logger
+	db     customersdb.Querier
+	cfg    OFACRetentionConfig
+}
+
+func NewOFACSearchPurger(logger log.Logger, db customersdb.Querier, cfg OFACRetentionConfig) *OFACSearchPurger {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &OFACSearchPurger{
+		logger: logger.Set("package", log.String("customers")),
+		db:     db,
+		cfg:    cfg,
+	}
+}
+
+// Start deletes old OFAC searches every interval until ctx is cancelled. It returns right away
+// when no retention period is set.
+func (p *OFACSearchPurger) Start(ctx context.Context) {
+	if p.cfg.Period <= 0 {
+		return
+	}
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := p.Sweep(ctx, time.Now()); err != nil {
+			p.logger.LogErrorf("problem purging old OFAC searches: %v", err)
+		} else if n > 0 {
+			p.logger.Logf("purged %d old OFAC searches", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep deletes every OFAC search created before now minus the retention period which isn't its
+// Customer's latest and returns how many were deleted
+func (p *OFACSearchPurger) Sweep(ctx context.Context, now time.Time) (int64, error) {
+	if p.cfg.Period <= 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-p.cfg.Period)
+
+	var purged int64
+	after := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		customerIDs, err := p.customersWithOldSearches(cutoff, after)
+		if err != nil {
+			return purged, err
+		}
+		for _, customerID := range customerIDs {
+			n, err := p.purgeCustomer(customerID, cutoff)
+			if err != nil {
+				return purged, err
+			}
+			ofacSearchesPurged.Add(float64(n))
+			purged += n
+		}
+		if len(customerIDs) < p.cfg.BatchSize {
+			return purged, nil
+		}
+		after = customerIDs[len(customerIDs)-1]
+	}
+}
+
+// customersWithOldSearches returns Customers, ordered by ID, with more than one search and at least
+// one created before cutoff
+func (p *OFACSearchPurger) customersWithOldSearches(cutoff time.Time, after string) ([]string, error) {
+	query := `select customer_id from customer_ofac_searches where customer_id > ?
+group by customer_id having min(created_at) < ? and count(*) > 1
+order by customer_id asc limit ?;`
+	rows, err := p.db.Query(query, after, cutoff, p.cfg.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("customersWithOldSearches: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var customerID string
+		if err := rows.Scan(&customerID); err != nil {
+			return nil, fmt.Errorf("customersWithOldSearches: scan: %v", err)
+		}
+		out = append(out, customerID)
+	}
+	return out, rows.Err()
+}
+
+// purgeCustomer deletes the Customer's searches created before cutoff and before their latest search.
+// The latest search is read first because MySQL can't delete from a table it's selecting from.
+func (p *OFACSearchPurger) purgeCustomer(customerID string, cutoff time.Time) (int64, error) {
+	var purged int64
+	err := customersdb.RetryOnLock(p.db, func(tx *sql.Tx) error {
+		var latest time.Time
+		query := `select created_at from customer_ofac_searches where customer_id = ? order by created_at desc limit 1;`
+		if err := tx.QueryRow(query, customerID).Scan(&latest); err != nil {
+			if err == sql.ErrNoRows {
+				return nil // e.g. the Customer was purged
+			}
+			return fmt.Errorf("purgeCustomer: latest: %v", err)
+		}
+		before := cutoff
+		if latest.Before(before) {
+			before = latest
+		}
+
+		res, err := tx.Exec(`delete from customer_ofac_searches where customer_id = ? and created_at < ?;`, customerID, before)
+		if err != nil {
+			return fmt.Errorf("purgeCustomer: delete: %v", err)
+		}
+		purged, _ = res.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("purging OFAC searches of customer=%s: %v", customerID, err)
+	}
+	return purged, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOFACSearchPurger(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	now := time.Now()
+	insert := func(customerID string, age time.Duration) {
+		query := `insert into customer_ofac_searches (customer_id, entity_id, sdn_name, blocked, created_at) values (?, '1234', 'Jane', false, ?);`
+		_, err := repo.db.Exec(query, customerID, now.Add(-age))
+		require.NoError(t, err)
+	}
+	searches := func(customerID string) int {
+		var n int
+		require.NoError(t, repo.db.QueryRow(`select count(*) from customer_ofac_searches where customer_id = ?;`, customerID).Scan(&n))
+		return n
+	}
+
+	// searched recently, only old searches are deleted
+	recent := base.ID()
+	insert(recent, 100*24*time.Hour)
+	insert(recent, 95*24*time.Hour)
+	insert(recent, time.Hour)
+
+	// only searched long ago, the latest search is kept
+	stale := base.ID()
+	insert(stale, 200*24*time.Hour)
+	insert(stale, 120*24*time.Hour)
+
+	// nothing old enough
+	fresh := base.ID()
+	insert(fresh, 2*24*time.Hour)
+	insert(fresh, time.Hour)
+
+	// a single old search is never deleted
+	single := base.ID()
+	insert(single, 300*24*time.Hour)
+
+	purger := NewOFACSearchPurger(log.NewNopLogger(), repo.db, OFACRetentionConfig{Period: 90 * 24 * time.Hour, BatchSize: 1})
+
+	purged, err := purger.Sweep(context.Background(), now)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), purged)
+	require.Equal(t, 1, searches(recent))
+	require.Equal(t, 1, searches(stale))
+	require.Equal(t, 2, searches(fresh))
+	require.Equal(t, 1, searches(single))
+
+	// the kept search is the latest
+	var kept time.Time
+	require.NoError(t, repo.db.QueryRow(`select created_at from customer_ofac_searches where customer_id = ?;`, stale).Scan(&kept))
+	require.WithinDuration(t, now.Add(-120*24*time.Hour), kept, time.Second)
+
+	// running again finds nothing
+	purged, err = purger.Sweep(context.Background(), now)
+	require.NoError(t, err)
+	require.Zero(t, purged)
+
+	// searches are kept forever without a retention period
+	insert(stale, 130*24*time.Hour)
+	purged, err = NewOFACSearchPurger(log.NewNopLogger(), repo.db, OFACRetentionConfig{}).Sweep(context.Background(), now)
+	require.NoError(t, err)
+	require.Zero(t, purged)
+	require.Equal(t, 2, searches(stale))
+}
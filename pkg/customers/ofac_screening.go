@@ -0,0 +1,178 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/watchman"
+)
+
+const (
+	defaultOFACScreeningLimit = 10
+	maxOFACScreeningLimit     = 50
+)
+
+// OFACScreeningRequest is a name, and optionally an address and birth date, to screen against
+// OFAC before a Customer is created
+type OFACScreeningRequest struct {
+	Name      string                `json:"name"`
+	Address   *client.CreateAddress `json:"address,omitempty"`
+	BirthDate string                `json:"birthDate,omitempty"`
+	Limit     int                   `json:"limit,omitempty"`
+}
+
+func (req OFACScreeningRequest) validate() error {
+	if strings.TrimSpace(req.Name) == "" {
+		return errors.New("missing name")
+	}
+	if req.BirthDate != "" {
+		if _, err := time.Parse("2006-01-02", req.BirthDate); err != nil {
+			return fmt.Errorf("invalid birthDate %q, expected YYYY-MM-DD", req.BirthDate)
+		}
+	}
+	if req.Limit < 0 || req.Limit > maxOFACScreeningLimit {
+		return fmt.Errorf("limit must be between 1 and %d", maxOFACScreeningLimit)
+	}
+	return nil
+}
+
+// OFACScreening is the result of screening a name against OFAC. Nothing is saved.
+type OFACScreening struct {
+	Candidates      []OFACCandidate `json:"candidates"`
+	Blocked         bool            `json:"blocked"`
+	ReviewRequired  bool            `json:"reviewRequired"`
+	MatchThreshold  float32         `json:"matchThreshold"`
+	ReviewThreshold float32         `json:"reviewThreshold"`
+}
+
+// OFACCandidate is an SDN similar to the screened name
+type OFACCandidate struct {
+	EntityID       string   `json:"entityID"`
+	SdnName        string   `json:"sdnName"`
+	SdnType        string   `json:"sdnType"`
+	Programs       []string `json:"programs,omitempty"`
+	Remarks        string   `json:"remarks,omitempty"`
+	Match          float32  `json:"match"`
+	AddressMatch   *float32 `json:"addressMatch,omitempty"`
+	BirthDateMatch *bool    `json:"birthDateMatch,omitempty"`
+	Blocked        bool     `json:"blocked"`
+	ReviewRequired bool     `json:"reviewRequired"`
+}
+
+// screen searches Watchman for SDNs similar to the request without writing any OFAC search.
+// Candidates are judged with the same thresholds as a Customer's search.
+func (s *OFACSearcher) screen(ctx context.Context, req OFACScreeningRequest, requestID string) (*OFACScreening, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelFn()
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultOFACScreeningLimit
+	}
+	sdns, err := s.watchmanClient.Candidates(ctx, strings.TrimSpace(req.Name), limit, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("OFACSearcher.screen: name search: %v", err)
+	}
+
+	var addressMatches map[string]float32
+	if req.Address != nil && len(sdns) > 0 {
+		addressMatches, err = s.watchmanClient.AddressMatches(ctx, watchman.Address{
+			Address:    strings.TrimSpace(req.Address.Address1 + " " + req.Address.Address2),
+			City:       req.Address.City,
+			State:      req.Address.State,
+			PostalCode: req.Address.PostalCode,
+			Country:    req.Address.Country,
+		}, requestID)
+		if err != nil {
+			return nil, fmt.Errorf("OFACSearcher.screen: address search: %v", err)
+		}
+	}
+
+	out := &OFACScreening{
+		Candidates:      make([]OFACCandidate, 0, len(sdns)),
+		MatchThreshold:  ofacMatchThreshold,
+		ReviewThreshold: ofacReviewThreshold,
+	}
+	for i := range sdns {
+		result := newOFACSearch(&sdns[i])
+		cand := OFACCandidate{
+			EntityID:       sdns[i].EntityID,
+			SdnName:        sdns[i].SdnName,
+			SdnType:        sdns[i].SdnType,
+			Programs:       sdns[i].Programs,
+			Remarks:        sdns[i].Remarks,
+			Match:          sdns[i].Match,
+			Blocked:        result.Blocked,
+			ReviewRequired: result.ReviewRequired,
+		}
+		if req.Address != nil {
+			match := addressMatches[cand.EntityID]
+			cand.AddressMatch = &match
+		}
+		if req.BirthDate != "" {
+			matched := remarksContainBirthDate(sdns[i].Remarks, req.BirthDate)
+			cand.BirthDateMatch = &matched
+		}
+		out.Blocked = out.Blocked || cand.Blocked
+		out.ReviewRequired = out.ReviewRequired || cand.ReviewRequired
+		out.Candidates = append(out.Candidates, cand)
+	}
+	out.ReviewRequired = !out.Blocked && out.ReviewRequired
+	return out, nil
+}
+
+// remarksContainBirthDate reports if an SDN's remarks list birthDate, which OFAC writes as "DOB 02 Jan 2006"
+func remarksContainBirthDate(remarks, birthDate string) bool {
+	dob, err := time.Parse("2006-01-02", birthDate)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(remarks), strings.ToLower("DOB "+dob.Format("02 Jan 2006")))
+}
+
+func screenOFAC(logger log.Logger, ofac *OFACSearcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		var req OFACScreeningRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, route.Validation(err))
+			return
+		}
+		if err := req.validate(); err != nil {
+			route.Problem(w, route.Validation(err))
+			return
+		}
+
+		result, err := ofac.screen(r.Context(), req, moovhttp.GetRequestID(r))
+		if err != nil {
+			logger.LogErrorf("error screening name against OFAC: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	watchmanClient "github.com/moov-io/watchman/client"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/watchman"
+)
+
+func TestOFACScreening(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	ofac := createTestOFACSearcher(repo, watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{
+		EntityID: "22790",
+		SdnName:  "MADURO MOROS, Nicolas",
+		SdnType:  "individual",
+		Programs: []string{"VENEZUELA-EO13692"},
+		Remarks:  "DOB 23 Nov 1962; POB Caracas, Venezuela; nationality Venezuela; Gender Male",
+		Match:    0.95,
+	}, nil))
+
+	router := mux.NewRouter()
+	AddOFACRoutes(log.NewNopLogger(), router, repo, ofac, nil)
+
+	call := func(body string) (*httptest.ResponseRecorder, OFACScreening) {
+		req := httptest.NewRequest("POST", "/customers/ofac-search", strings.NewReader(body))
+		req.Header.Set("X-Organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var result OFACScreening
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		}
+		return w, result
+	}
+
+	w, result := call(`{"name": "Nicolas Maduro", "address": {"city": "Caracas", "country": "VE"}, "birthDate": "1962-11-23"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, result.Candidates, 1)
+	cand := result.Candidates[0]
+	require.Equal(t, "22790", cand.EntityID)
+	require.Equal(t, []string{"VENEZUELA-EO13692"}, cand.Programs)
+	require.InDelta(t, 0.95, cand.Match, 0.001)
+	require.NotNil(t, cand.AddressMatch)
+	require.True(t, *cand.BirthDateMatch)
+	require.False(t, result.Blocked)
+	require.Equal(t, result.Blocked, cand.Blocked)
+	require.Equal(t, ofacReviewThreshold > 0 && 0.95 > ofacReviewThreshold, result.ReviewRequired)
+	require.Equal(t, ofacMatchThreshold, result.MatchThreshold)
+
+	// a name alone leaves the optional matches out
+	w, result = call(`{"name": "Nicolas Maduro", "limit": 3}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Nil(t, result.Candidates[0].AddressMatch)
+	require.Nil(t, result.Candidates[0].BirthDateMatch)
+
+	w, result = call(`{"name": "Nicolas Maduro", "birthDate": "1970-01-01"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.False(t, *result.Candidates[0].BirthDateMatch)
+
+	// nothing was saved
+	searches, err := repo.getCustomerOFACSearches(context.Background(), "22790", "test")
+	require.NoError(t, err)
+	require.Empty(t, searches)
+	var count int
+	require.NoError(t, repo.db.QueryRow(`select count(*) from customer_ofac_searches;`).Scan(&count))
+	require.Zero(t, count)
+
+	for _, body := range []string{`{}`, `{"name": "  "}`, `{"name": "Jane", "birthDate": "01/02/1990"}`, `{"name": "Jane", "limit": 500}`, `{`} {
+		w, _ = call(body)
+		require.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	ofac.watchmanClient = watchman.NewTestWatchmanClient(nil, errors.New("bad error"))
+	w, _ = call(`{"name": "Nicolas Maduro"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRemarksContainBirthDate(t *testing.T) {
+	require.True(t, remarksContainBirthDate("DOB 23 Nov 1962; POB Caracas", "1962-11-23"))
+	require.True(t, remarksContainBirthDate("dob 05 Jan 1980", "1980-01-05"))
+	require.False(t, remarksContainBirthDate("DOB 23 Nov 1962", "1962-11-24"))
+	require.False(t, remarksContainBirthDate("", "1962-11-23"))
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+// representativeRollupActor is recorded on every status change made by the rollup rules
+const representativeRollupActor = "representative-rollup"
+
+// statusRollup is what the rollup rules decided for a Customer and their Representatives. Only
+// changes are included.
+type statusRollup struct {
+	customerStatus  client.CustomerStatus // empty to leave the Customer alone
+	customerComment string
+
+	representatives map[string]representativeChange
+}
+
+type representativeChange struct {
+	status  client.RepresentativeStatus
+	comment string
+}
+
+// computeStatusRollup applies the rollup rules to a Customer and their Representatives:
+//
+//  1. A Representative whose latest OFAC search is blocked is Blocked.
+//  2. If any Representative is blocked by OFAC the Customer is Rejected.
+//  3. Every Representative of a Rejected Customer is Blocked.
+//  4. Other Representatives are Active.
+//  5. A Customer the rollup rejected (who hasn't changed status since) returns to Unknown once
+//     no Representative is blocked by OFAC, so they must be verified again.
+//
+// searches holds the latest OFAC search of each Representative, keyed by RepresentativeID.
+func computeStatusRollup(cust *client.Customer, reps []client.Representative, searches map[string]*client.OfacSearch, rejectedByRollup bool) statusRollup {
+	out := statusRollup{
+		representatives: make(map[string]representativeChange),
+	}
+
+	// Rules 1 and 2
+	var blocking *client.Representative
+	var blockingSearch *client.OfacSearch
+	for i := range reps {
+		if search := searches[reps[i].RepresentativeID]; exceedsOFACThreshold(search) {
+			blocking, blockingSearch = &reps[i], search
+			break
+		}
+	}
+	status := cust.Status
+	switch {
+	case blocking != nil && status != client.CUSTOMERSTATUS_REJECTED:
+		if TransitionAllowed(status, client.CUSTOMERSTATUS_REJECTED) == nil {
+			status = client.CUSTOMERSTATUS_REJECTED
+			out.customerStatus = status
+			out.customerComment = fmt.Sprintf("representative=%s matched OFAC entity=%s (%s) with a score of %.2f",
+				blocking.RepresentativeID, blockingSearch.EntityID, blockingSearch.SdnName, blockingSearch.Match)
+		}
+	case blocking == nil && status == client.CUSTOMERSTATUS_REJECTED && rejectedByRollup:
+		// Rule 5
+		status = client.CUSTOMERSTATUS_UNKNOWN
+		out.customerStatus = status
+		out.customerComment = "no representatives match OFAC"
+	}
+
+	// Rules 1, 3 and 4
+	for i := range reps {
+		want, comment := client.REPRESENTATIVESTATUS_ACTIVE, "representative and customer are not blocked"
+		switch search := searches[reps[i].RepresentativeID]; {
+		case exceedsOFACThreshold(search):
+			want = client.REPRESENTATIVESTATUS_BLOCKED
+			comment = fmt.Sprintf("matched OFAC entity=%s (%s) with a score of %.2f", search.EntityID, search.SdnName, search.Match)
+		case status == client.CUSTOMERSTATUS_REJECTED:
+			want, comment = client.REPRESENTATIVESTATUS_BLOCKED, "customer is rejected"
+		}
+		current := reps[i].Status
+		if current == "" {
+			current = client.REPRESENTATIVESTATUS_ACTIVE
+		}
+		if current != want {
+			out.representatives[reps[i].RepresentativeID] = representativeChange{status: want, comment: comment}
+		}
+	}
+	return out
+}
+
+// rollupRepresentativeStatus recomputes the status of a Customer and their Representatives and
+// saves every change, recording each one in the status history. It returns the Customer's new
+// status, or an empty status if it didn't change.
+func rollupRepresentativeStatus(ctx context.Context, logger log.Logger, repo CustomerRepository, customerID, organization string) (client.CustomerStatus, error) {
+	cust, err := repo.GetCustomer(ctx, customerID, organization)
+	if err != nil || cust == nil {
+		return "", err
+	}
+	return applyStatusRollup(ctx, logger, repo, cust)
+}
+
+// applyStatusRollup is rollupRepresentativeStatus for a Customer which has already been read
+func applyStatusRollup(ctx context.Context, logger log.Logger, repo CustomerRepository, cust *client.Customer) (client.CustomerStatus, error) {
+	customerID := cust.CustomerID
+	reps, err := repo.listRepresentatives(ctx, customerID)
+	if err != nil {
+		return "", err
+	}
+	searches := make(map[string]*client.OfacSearch)
+	for i := range reps {
+		search, err := repo.getLatestRepresentativeOFACSearch(ctx, reps[i].RepresentativeID)
+		if err != nil {
+			return "", err
+		}
+		searches[reps[i].RepresentativeID] = search
+	}
+	rejectedByRollup := false
+	if cust.Status == client.CUSTOMERSTATUS_REJECTED {
+		history, err := repo.getStatusHistory(ctx, customerID)
+		if err != nil {
+			return "", err
+		}
+		if n := len(history); n > 0 {
+			rejectedByRollup = history[n-1].Actor == representativeRollupActor
+		}
+	}
+
+	rollup := computeStatusRollup(cust, reps, searches, rejectedByRollup)
+	if rollup.customerStatus != "" {
+		logger.Logf("customer=%s status rolled up from %s to %s: %s", customerID, cust.Status, rollup.customerStatus, rollup.customerComment)
+		if err := repo.updateCustomerStatus(ctx, customerID, rollup.customerStatus, rollup.customerComment, representativeRollupActor); err != nil {
+			return "", fmt.Errorf("rolling up customer=%s status: %v", customerID, err)
+		}
+	}
+	for representativeID, change := range rollup.representatives {
+		logger.Logf("customer=%s representative=%s is now %s: %s", customerID, representativeID, change.status, change.comment)
+		if err := repo.updateRepresentativeStatus(ctx, representativeID, customerID, change.status, change.comment, representativeRollupActor); err != nil {
+			return "", fmt.Errorf("rolling up representative=%s status: %v", representativeID, err)
+		}
+	}
+	return rollup.customerStatus, nil
+}
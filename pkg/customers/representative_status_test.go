@@ -0,0 +1,136 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	watchmanClient "github.com/moov-io/watchman/client"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/watchman"
+)
+
+func TestComputeStatusRollup(t *testing.T) {
+	blocked := &client.OfacSearch{EntityID: "1241421", SdnName: "Jane Doe", Match: 1.0, Blocked: true}
+	clear := &client.OfacSearch{EntityID: "1241421", SdnName: "Jane Doe", Match: 0.5}
+
+	reps := func(statuses ...client.RepresentativeStatus) []client.Representative {
+		var out []client.Representative
+		for i, status := range statuses {
+			out = append(out, client.Representative{RepresentativeID: fmt.Sprintf("rep%d", i), Status: status})
+		}
+		return out
+	}
+	cust := func(status client.CustomerStatus) *client.Customer {
+		return &client.Customer{CustomerID: "cust", Status: status}
+	}
+
+	// a blocked representative rejects the Customer and blocks the other representatives
+	rollup := computeStatusRollup(cust(client.CUSTOMERSTATUS_VERIFIED), reps("", client.REPRESENTATIVESTATUS_ACTIVE), map[string]*client.OfacSearch{"rep0": blocked, "rep1": clear}, false)
+	require.Equal(t, client.CUSTOMERSTATUS_REJECTED, rollup.customerStatus)
+	require.Contains(t, rollup.customerComment, "representative=rep0")
+	require.Equal(t, client.REPRESENTATIVESTATUS_BLOCKED, rollup.representatives["rep0"].status)
+	require.Contains(t, rollup.representatives["rep0"].comment, "matched OFAC")
+	require.Equal(t, client.REPRESENTATIVESTATUS_BLOCKED, rollup.representatives["rep1"].status)
+	require.Equal(t, "customer is rejected", rollup.representatives["rep1"].comment)
+
+	// nothing changes when everything is already rolled up
+	rollup = computeStatusRollup(cust(client.CUSTOMERSTATUS_REJECTED), reps(client.REPRESENTATIVESTATUS_BLOCKED, client.REPRESENTATIVESTATUS_BLOCKED), map[string]*client.OfacSearch{"rep0": blocked}, true)
+	require.Empty(t, rollup.customerStatus)
+	require.Empty(t, rollup.representatives)
+
+	// Customers rejected by the rollup return to Unknown once cleared
+	rollup = computeStatusRollup(cust(client.CUSTOMERSTATUS_REJECTED), reps(client.REPRESENTATIVESTATUS_BLOCKED), map[string]*client.OfacSearch{"rep0": clear}, true)
+	require.Equal(t, client.CUSTOMERSTATUS_UNKNOWN, rollup.customerStatus)
+	require.Equal(t, client.REPRESENTATIVESTATUS_ACTIVE, rollup.representatives["rep0"].status)
+
+	// other rejections aren't undone, and still block representatives
+	rollup = computeStatusRollup(cust(client.CUSTOMERSTATUS_REJECTED), reps(""), nil, false)
+	require.Empty(t, rollup.customerStatus)
+	require.Equal(t, client.REPRESENTATIVESTATUS_BLOCKED, rollup.representatives["rep0"].status)
+
+	// Deceased Customers can't be rejected
+	rollup = computeStatusRollup(cust(client.CUSTOMERSTATUS_DECEASED), reps(""), map[string]*client.OfacSearch{"rep0": blocked}, false)
+	require.Empty(t, rollup.customerStatus)
+	require.Equal(t, client.REPRESENTATIVESTATUS_BLOCKED, rollup.representatives["rep0"].status)
+
+	// active representatives of active Customers are left alone
+	rollup = computeStatusRollup(cust(client.CUSTOMERSTATUS_VERIFIED), reps("", client.REPRESENTATIVESTATUS_ACTIVE), map[string]*client.OfacSearch{"rep0": clear}, false)
+	require.Empty(t, rollup.customerStatus)
+	require.Empty(t, rollup.representatives)
+}
+
+func TestRepresentativeStatusRollup(t *testing.T) {
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	cust, organization := setupMockCustomer(t, repo)
+
+	ofacClient := watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{EntityID: "1241421", SdnName: "Jane Doe", Match: 1.0}, nil)
+	ofac := createTestOFACSearcher(repo, ofacClient)
+
+	router := mux.NewRouter()
+	AddRepresentativeRoutes(log.NewNopLogger(), router, repo, testCustomerSSNStorage(t), ofac, nil)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("x-organization", organization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	path := fmt.Sprintf("/customers/%s/representatives", cust.CustomerID)
+
+	// a representative matching OFAC blocks the business
+	w := send("POST", path, `{"firstName": "Jane", "lastName": "Doe"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var rep client.Representative
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&rep))
+	require.Equal(t, client.REPRESENTATIVESTATUS_BLOCKED, rep.Status)
+
+	found, err := repo.GetCustomer(context.Background(), cust.CustomerID, organization)
+	require.NoError(t, err)
+	require.Equal(t, client.CUSTOMERSTATUS_REJECTED, found.Status)
+
+	history, err := repo.getStatusHistory(context.Background(), cust.CustomerID)
+	require.NoError(t, err)
+	require.Equal(t, representativeRollupActor, history[len(history)-1].Actor)
+	require.Contains(t, history[len(history)-1].Comment, rep.RepresentativeID)
+
+	// clearing the representative lets the business be verified again
+	ofac.watchmanClient = watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{EntityID: "1241421", SdnName: "Jane Doe", Match: 0.5}, nil)
+	w = send("PUT", path+"/"+rep.RepresentativeID, `{"firstName": "Janet", "lastName": "Doe"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&rep))
+	require.Equal(t, client.REPRESENTATIVESTATUS_ACTIVE, rep.Status)
+
+	found, err = repo.GetCustomer(context.Background(), cust.CustomerID, organization)
+	require.NoError(t, err)
+	require.Equal(t, client.CUSTOMERSTATUS_UNKNOWN, found.Status)
+
+	// rejecting the business blocks its representatives
+	require.NoError(t, repo.updateCustomerStatus(context.Background(), cust.CustomerID, client.CUSTOMERSTATUS_REJECTED, "fraud", "compliance"))
+	status, err := rollupRepresentativeStatus(context.Background(), log.NewNopLogger(), repo, cust.CustomerID, organization)
+	require.NoError(t, err)
+	require.Empty(t, status)
+	found2, err := repo.GetRepresentative(context.Background(), rep.RepresentativeID)
+	require.NoError(t, err)
+	require.Equal(t, client.REPRESENTATIVESTATUS_BLOCKED, found2.Status)
+
+	// each representative change was recorded
+	var count int
+	require.NoError(t, repo.db.QueryRow(`select count(*) from representative_status_updates where representative_id = ?;`, rep.RepresentativeID).Scan(&count))
+	require.Equal(t, 3, count)
+}
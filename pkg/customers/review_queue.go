@@ -0,0 +1,457 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/internal/util"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// Reasons a Customer needs manual review
+const (
+	// ReviewOFAC is a latest OFAC search which fell between the review and match thresholds
+	ReviewOFAC = "ofac_review"
+
+	// ReviewExpiredDocument is a Document which has passed its expiration
+	ReviewExpiredDocument = "expired_document"
+
+	// ReviewMissingTaxID is a pending individual without an SSN or business without an EIN
+	ReviewMissingTaxID = "missing_tax_id"
+
+	// ReviewUnverifiedAddress is a pending Customer with an address which hasn't been validated
+	ReviewUnverifiedAddress = "unverified_address"
+)
+
+// reviewPriorities order the review queue, lower values are worked first
+var reviewPriorities = map[string]int{
+	ReviewOFAC:              1,
+	ReviewExpiredDocument:   2,
+	ReviewMissingTaxID:      3,
+	ReviewUnverifiedAddress: 4,
+}
+
+// ReviewItem is a Customer needing manual attention along with every reason they're in the queue
+type ReviewItem struct {
+	CustomerID   string                `json:"customerID"`
+	FirstName    string                `json:"firstName"`
+	LastName     string                `json:"lastName"`
+	BusinessName string                `json:"businessName,omitempty"`
+	Status       client.CustomerStatus `json:"status"`
+	Reasons      []ReviewReason        `json:"reasons"`
+
+	// Priority is the priority of the most urgent reason, 1 is the highest
+	Priority int `json:"priority"`
+
+	// WaitingSince is when the oldest reason was raised
+	WaitingSince time.Time `json:"waitingSince"`
+
+	Assignee   string     `json:"assignee,omitempty"`
+	AssignedAt *time.Time `json:"assignedAt,omitempty"`
+}
+
+type ReviewReason struct {
+	Reason string    `json:"reason"`
+	Since  time.Time `json:"since"`
+}
+
+// reviewSignal is one reason a Customer needs review, as read from the database
+type reviewSignal struct {
+	item   ReviewItem
+	reason ReviewReason
+}
+
+// buildReviewQueue combines the signals of each Customer into one ReviewItem, keeping the earliest of
+// repeated reasons, and sorts them by priority, then by how long they've waited (oldest first).
+// Unknown reasons are ignored.
+func buildReviewQueue(signals []reviewSignal) []*ReviewItem {
+	byCustomer := make(map[string]*ReviewItem)
+	for i := range signals {
+		priority, ok := reviewPriorities[signals[i].reason.Reason]
+		if !ok {
+			continue
+		}
+		item, exists := byCustomer[signals[i].item.CustomerID]
+		if !exists {
+			copied := signals[i].item
+			copied.Reasons = nil
+			item = &copied
+			byCustomer[item.CustomerID] = item
+		}
+		if idx := reasonIndex(item.Reasons, signals[i].reason.Reason); idx < 0 {
+			item.Reasons = append(item.Reasons, signals[i].reason)
+		} else if signals[i].reason.Since.Before(item.Reasons[idx].Since) {
+			item.Reasons[idx].Since = signals[i].reason.Since
+		}
+		if item.Priority == 0 || priority < item.Priority {
+			item.Priority = priority
+		}
+		if item.WaitingSince.IsZero() || signals[i].reason.Since.Before(item.WaitingSince) {
+			item.WaitingSince = signals[i].reason.Since
+		}
+	}
+
+	out := make([]*ReviewItem, 0, len(byCustomer))
+	for _, item := range byCustomer {
+		sort.Slice(item.Reasons, func(i, j int) bool {
+			return reviewPriorities[item.Reasons[i].Reason] < reviewPriorities[item.Reasons[j].Reason]
+		})
+		out = append(out, item)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Priority != out[j].Priority {
+			return out[i].Priority < out[j].Priority
+		}
+		if !out[i].WaitingSince.Equal(out[j].WaitingSince) {
+			return out[i].WaitingSince.Before(out[j].WaitingSince)
+		}
+		return out[i].CustomerID < out[j].CustomerID
+	})
+	return out
+}
+
+func reasonIndex(reasons []ReviewReason, reason string) int {
+	for i := range reasons {
+		if reasons[i].Reason == reason {
+			return i
+		}
+	}
+	return -1
+}
+
+// ReviewParams filter the review queue
+type ReviewParams struct {
+	// Reason limits the queue to Customers with this reason
+	Reason string
+
+	// Assignee limits the queue to reviews claimed by them, Unassigned to reviews nobody has claimed
+	Assignee   string
+	Unassigned bool
+
+	Skip  int64
+	Count int64
+}
+
+func (p ReviewParams) matches(item *ReviewItem) bool {
+	if p.Unassigned && item.Assignee != "" {
+		return false
+	}
+	if p.Assignee != "" && item.Assignee != p.Assignee {
+		return false
+	}
+	return p.Reason == "" || reasonIndex(item.Reasons, p.Reason) >= 0
+}
+
+var errReviewClaimed = errors.New("review is assigned to someone else")
+
+// ReviewRepository finds the Customers needing manual review and tracks who is working each one
+type ReviewRepository interface {
+	// GetReviewQueue returns the organization's Customers needing review, most urgent first.
+	// Rejected and Deceased Customers are left out.
+	GetReviewQueue(ctx context.Context, organization string, now time.Time) ([]*ReviewItem, error)
+
+	// assignReview assigns the Customer's review unless someone else already has it
+	assignReview(customerID, organization, assignee string, now time.Time) error
+	releaseReview(customerID, organization string) error
+}
+
+func NewReviewRepository(logger log.Logger, db customersdb.Querier) ReviewRepository {
+	return &sqlCustomerRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// AddReviewRoutes registers the review queue. It must be added before AddCustomerRoutes so
+// /customers/reviews isn't read as a customerID.
+func AddReviewRoutes(logger log.Logger, r *mux.Router, repo CustomerRepository, reviews ReviewRepository) {
+	logger = logger.Set("package", log.String("customers"))
+
+	r.Methods("GET").Path("/customers/reviews").HandlerFunc(getReviewQueue(logger, reviews))
+	r.Methods("PUT").Path("/customers/{customerID}/review").HandlerFunc(assignReview(logger, repo, reviews))
+	r.Methods("DELETE").Path("/customers/{customerID}/review").HandlerFunc(releaseReview(logger, repo, reviews))
+}
+
+func parseReviewParams(r *http.Request) (ReviewParams, error) {
+	q := r.URL.Query()
+	params := ReviewParams{
+		Reason:     strings.ToLower(strings.TrimSpace(q.Get("reason"))),
+		Assignee:   strings.TrimSpace(q.Get("assignee")),
+		Unassigned: util.Yes(q.Get("unassigned")),
+	}
+	if _, ok := reviewPriorities[params.Reason]; params.Reason != "" && !ok {
+		return params, route.Validation(fmt.Errorf("unknown review reason %q", params.Reason))
+	}
+	if params.Unassigned && params.Assignee != "" {
+		return params, route.Validation(errors.New("assignee and unassigned can't be combined"))
+	}
+
+	skip, count, exists, err := moovhttp.GetSkipAndCount(r)
+	if exists && err != nil {
+		return params, err
+	}
+	params.Skip = int64(skip)
+	params.Count = int64(count)
+	return params, nil
+}
+
+func getReviewQueue(logger log.Logger, reviews ReviewRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+		params, err := parseReviewParams(r)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+
+		queue, err := reviews.GetReviewQueue(r.Context(), organization, time.Now())
+		if err != nil {
+			logger.LogErrorf("problem reading review queue: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		items := make([]*ReviewItem, 0)
+		for i := range queue {
+			if params.matches(queue[i]) {
+				items = append(items, queue[i])
+			}
+		}
+		total := len(items)
+		if params.Skip >= int64(len(items)) {
+			items = items[:0]
+		} else {
+			items = items[params.Skip:]
+		}
+		if int64(len(items)) > params.Count {
+			items = items[:params.Count]
+		}
+
+		w.Header().Set(totalCountHeaderKey, fmt.Sprintf("%d", total))
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(items)
+	}
+}
+
+type assignReviewRequest struct {
+	// Assignee defaults to the caller
+	Assignee string `json:"assignee"`
+}
+
+func assignReview(logger log.Logger, repo CustomerRepository, reviews ReviewRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		var req assignReviewRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				route.Problem(w, route.Validation(fmt.Errorf("reading review assignment: %v", err)))
+				return
+			}
+		}
+		assignee := strings.TrimSpace(req.Assignee)
+		if assignee == "" {
+			assignee = route.GetActor(r)
+		}
+		if assignee == "" {
+			route.Problem(w, route.Validation(errors.New("missing assignee")))
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+		if err := reviews.assignReview(customerID, organization, assignee, time.Now()); err != nil {
+			if err == errReviewClaimed {
+				route.Problem(w, route.Conflict(err))
+				return
+			}
+			logger.LogErrorf("problem assigning review: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		logger.Info().Logf("review assigned to %s", assignee)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func releaseReview(logger log.Logger, repo CustomerRepository, reviews ReviewRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		if !customerExists(w, r, repo, customerID, organization) {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+		if err := reviews.releaseReview(customerID, organization); err != nil {
+			logger.LogErrorf("problem releasing review: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		logger.Info().Log("review released")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// reviewSignalQueries each return a row for every reason a Customer needs review along with when it was
+// raised, e.g. one row for each expired Document. Arguments are the organization, the Rejected and Deceased
+// statuses and then the extra arguments of each query.
+var reviewSignalQueries = []struct {
+	reason string
+	from   string
+	args   func(now time.Time) []interface{}
+}{
+	{
+		reason: ReviewOFAC,
+		from: `, cos.created_at from customers as c
+inner join customer_ofac_searches as cos on cos.customer_id = c.customer_id
+where c.organization = ? and c.deleted_at is null and c.status not in (?, ?)
+and cos.created_at = (select max(latest.created_at) from customer_ofac_searches as latest where latest.customer_id = c.customer_id)
+and cos.blocked = ? and cos.review_required = ?`,
+		args: func(now time.Time) []interface{} { return []interface{}{false, true} },
+	},
+	{
+		reason: ReviewExpiredDocument,
+		from: `, d.expires_at from customers as c
+inner join documents as d on d.customer_id = c.customer_id
+where c.organization = ? and c.deleted_at is null and c.status not in (?, ?)
+and d.deleted_at is null and d.expires_at is not null and d.expires_at < ?`,
+		args: func(now time.Time) []interface{} { return []interface{}{now} },
+	},
+	{
+		reason: ReviewMissingTaxID,
+		from: `, c.created_at from customers as c
+where c.organization = ? and c.deleted_at is null and c.status not in (?, ?) and c.status in (?, ?)
+and ((lower(c.type) = ? and not exists (select 1 from ssn where ssn.owner_id = c.customer_id and ssn.owner_type = ?))
+or (lower(c.type) = ? and coalesce(c.ein, '') = ''))`,
+		args: func(now time.Time) []interface{} {
+			return []interface{}{
+				client.CUSTOMERSTATUS_UNKNOWN, client.CUSTOMERSTATUS_RECEIVE_ONLY,
+				client.CUSTOMERTYPE_INDIVIDUAL, client.OWNERTYPE_CUSTOMER,
+				client.CUSTOMERTYPE_BUSINESS,
+			}
+		},
+	},
+	{
+		reason: ReviewUnverifiedAddress,
+		from: `, a.created_at from customers as c
+inner join addresses as a on a.owner_id = c.customer_id
+where c.organization = ? and c.deleted_at is null and c.status not in (?, ?) and c.status in (?, ?)
+and a.owner_type = ? and a.deleted_at is null and (a.validated is null or a.validated = ?)`,
+		args: func(now time.Time) []interface{} {
+			return []interface{}{client.CUSTOMERSTATUS_UNKNOWN, client.CUSTOMERSTATUS_RECEIVE_ONLY, client.OWNERTYPE_CUSTOMER, false}
+		},
+	},
+}
+
+func (r *sqlCustomerRepository) GetReviewQueue(ctx context.Context, organization string, now time.Time) ([]*ReviewItem, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	var signals []reviewSignal
+	for _, q := range reviewSignalQueries {
+		query := `select c.customer_id, c.first_name, c.last_name, c.business_name, c.status, c.review_assignee, c.review_assigned_at` + q.from + `;`
+		args := append([]interface{}{organization, client.CUSTOMERSTATUS_REJECTED, client.CUSTOMERSTATUS_DECEASED}, q.args(now)...)
+		found, err := r.queryReviewSignals(ctx, q.reason, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("GetReviewQueue: %s: %v", q.reason, err)
+		}
+		signals = append(signals, found...)
+	}
+	return buildReviewQueue(signals), nil
+}
+
+func (r *sqlCustomerRepository) queryReviewSignals(ctx context.Context, reason, query string, args ...interface{}) ([]reviewSignal, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []reviewSignal
+	for rows.Next() {
+		var sig reviewSignal
+		var assignee *string
+		var since *time.Time
+		err := rows.Scan(&sig.item.CustomerID, &sig.item.FirstName, &sig.item.LastName, &sig.item.BusinessName, &sig.item.Status, &assignee, &sig.item.AssignedAt, &since)
+		if err != nil {
+			return nil, err
+		}
+		if assignee != nil {
+			sig.item.Assignee = *assignee
+		}
+		sig.reason = ReviewReason{Reason: reason}
+		if since != nil {
+			sig.reason.Since = *since
+		}
+		out = append(out, sig)
+	}
+	return out, rows.Err()
+}
+
+func (r *sqlCustomerRepository) assignReview(customerID, organization, assignee string, now time.Time) error {
+	return customersdb.RetryOnLock(r.db, func(tx *sql.Tx) error {
+		var current *string
+		query := `select review_assignee from customers where customer_id = ? and organization = ? and deleted_at is null;`
+		if err := tx.QueryRow(query, customerID, organization).Scan(&current); err != nil {
+			if err == sql.ErrNoRows {
+				return errCustomerNotFound
+			}
+			return fmt.Errorf("reading review assignee: %v", err)
+		}
+		if current != nil && *current != "" && *current != assignee {
+			return errReviewClaimed
+		}
+		query = `update customers set review_assignee = ?, review_assigned_at = ? where customer_id = ? and organization = ?;`
+		if _, err := tx.Exec(query, assignee, now, customerID, organization); err != nil {
+			return fmt.Errorf("assigning review: %v", err)
+		}
+		return nil
+	})
+}
+
+func (r *sqlCustomerRepository) releaseReview(customerID, organization string) error {
+	query := `update customers set review_assignee = null, review_assigned_at = null where customer_id = ? and organization = ?;`
+	if _, err := r.db.Exec(query, customerID, organization); err != nil {
+		return fmt.Errorf("releasing review: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+func TestReviewQueue__build(t *testing.T) {
+	now := time.Now()
+	signal := func(customerID, reason string, age time.Duration) reviewSignal {
+		return reviewSignal{
+			item:   ReviewItem{CustomerID: customerID},
+			reason: ReviewReason{Reason: reason, Since: now.Add(-age)},
+		}
+	}
+	queue := buildReviewQueue([]reviewSignal{
+		signal("a", ReviewUnverifiedAddress, 10*time.Hour),
+		signal("b", ReviewExpiredDocument, time.Hour),
+		signal("b", ReviewExpiredDocument, 5*time.Hour),
+		signal("c", ReviewUnverifiedAddress, 20*time.Hour),
+		signal("a", ReviewOFAC, time.Hour),
+		signal("d", ReviewExpiredDocument, 2*time.Hour),
+		signal("e", "unknown", time.Hour),
+	})
+	require.Len(t, queue, 4)
+
+	// ordered by the most urgent reason then by age
+	var ids []string
+	for i := range queue {
+		ids = append(ids, queue[i].CustomerID)
+	}
+	require.Equal(t, []string{"a", "b", "d", "c"}, ids)
+
+	require.Equal(t, 1, queue[0].Priority)
+	require.Equal(t, []ReviewReason{{ReviewOFAC, now.Add(-time.Hour)}, {ReviewUnverifiedAddress, now.Add(-10 * time.Hour)}}, queue[0].Reasons)
+	require.Equal(t, now.Add(-10*time.Hour), queue[0].WaitingSince)
+
+	// repeated reasons keep the earliest
+	require.Equal(t, []ReviewReason{{ReviewExpiredDocument, now.Add(-5 * time.Hour)}}, queue[1].Reasons)
+	require.Equal(t, 4, queue[3].Priority)
+
+	require.Empty(t, buildReviewQueue(nil))
+}
+
+func TestReviewQueue__routes(t *testing.T) {
+	scope := Setup(t)
+	organization := "organization"
+	db := scope.customerRepo.db
+
+	storage := NewSSNStorage(secrets.TestStringKeeper(t), NewCustomerSSNRepository(log.NewNopLogger(), db), "salt")
+	withSSN := func(cust client.Customer) client.Customer {
+		ssn, err := storage.encryptRaw(cust.CustomerID, client.OWNERTYPE_CUSTOMER, "123456789")
+		require.NoError(t, err)
+		require.NoError(t, storage.repo.saveSSN(ssn))
+		return cust
+	}
+	setStatus := func(cust client.Customer, status client.CustomerStatus) {
+		_, err := db.Exec(`update customers set status = ? where customer_id = ?;`, status, cust.CustomerID)
+		require.NoError(t, err)
+	}
+	expireDocument := func(cust client.Customer) {
+		_, err := db.Exec(`insert into documents (document_id, customer_id, type, content_type, uploaded_at, expires_at) values (?, ?, 'DriversLicense', 'image/png', ?, ?);`,
+			base.ID(), cust.CustomerID, time.Now().Add(-48*time.Hour), time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+	}
+
+	flagged := scope.CreateCustomer(context.Background(), "John", "Doe", organization, "john@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+	require.NoError(t, scope.customerRepo.saveCustomerOFACSearch(context.Background(), flagged.CustomerID, client.OfacSearch{
+		EntityID: "142", SdnName: "JOHN DOE", Match: 0.91, ReviewRequired: true, CreatedAt: time.Now(),
+	}))
+
+	expired := withSSN(scope.CreateCustomer(context.Background(), "Jane", "Doe", organization, "jane@example.com", client.CUSTOMERTYPE_INDIVIDUAL))
+	setStatus(expired, client.CUSTOMERSTATUS_VERIFIED)
+	expireDocument(expired)
+
+	unverified := withSSN(scope.CreateCustomer(context.Background(), "Jim", "Doe", organization, "jim@example.com", client.CUSTOMERTYPE_INDIVIDUAL))
+	require.NoError(t, scope.customerRepo.addAddress(context.Background(), unverified.CustomerID, client.OWNERTYPE_CUSTOMER, address{Type: "primary", Address1: "123 1st St", City: "Denver", State: "CO", PostalCode: "80201", Country: "US"}))
+
+	missingSSN := scope.CreateCustomer(context.Background(), "Jack", "Doe", organization, "jack@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+
+	// verified customers without issues, rejected customers and other organizations are left out
+	withSSN(scope.CreateCustomer(context.Background(), "Jill", "Doe", organization, "jill@example.com", client.CUSTOMERTYPE_INDIVIDUAL))
+	rejected := withSSN(scope.CreateCustomer(context.Background(), "Joe", "Doe", organization, "joe@example.com", client.CUSTOMERTYPE_INDIVIDUAL))
+	setStatus(rejected, client.CUSTOMERSTATUS_REJECTED)
+	expireDocument(rejected)
+	scope.CreateCustomer(context.Background(), "Jen", "Doe", "other", "jen@example.com", client.CUSTOMERTYPE_INDIVIDUAL)
+
+	router := mux.NewRouter()
+	AddReviewRoutes(log.NewNopLogger(), router, scope.customerRepo, NewReviewRepository(log.NewNopLogger(), db))
+
+	send := func(method, path, userID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Organization", organization)
+		if userID != "" {
+			req.Header.Set("X-User-ID", userID)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	queue := func(query string) []ReviewItem {
+		w := send("GET", "/customers/reviews"+query, "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var items []ReviewItem
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&items))
+		return items
+	}
+	ids := func(items []ReviewItem) []string {
+		out := make([]string, 0)
+		for i := range items {
+			out = append(out, items[i].CustomerID)
+		}
+		return out
+	}
+
+	items := queue("")
+	require.Equal(t, []string{flagged.CustomerID, expired.CustomerID, missingSSN.CustomerID, unverified.CustomerID}, ids(items))
+	require.Equal(t, []ReviewReason{{ReviewOFAC, items[0].Reasons[0].Since}, {ReviewMissingTaxID, items[0].Reasons[1].Since}}, items[0].Reasons)
+	require.Equal(t, ReviewExpiredDocument, items[1].Reasons[0].Reason)
+	require.Equal(t, ReviewUnverifiedAddress, items[3].Reasons[0].Reason)
+
+	require.Equal(t, []string{flagged.CustomerID}, ids(queue("?reason=ofac_review")))
+	require.Equal(t, []string{expired.CustomerID}, ids(queue("?skip=1&count=1")))
+
+	// reviews can only be claimed by one agent at a time
+	w := send("PUT", "/customers/"+flagged.CustomerID+"/review", "jane", "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	w = send("PUT", "/customers/"+flagged.CustomerID+"/review", "bob", "")
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	w = send("PUT", "/customers/"+flagged.CustomerID+"/review", "jane", "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	items = queue("?assignee=jane")
+	require.Equal(t, []string{flagged.CustomerID}, ids(items))
+	require.NotNil(t, items[0].AssignedAt)
+	require.NotContains(t, ids(queue("?unassigned=yes")), flagged.CustomerID)
+
+	w = send("DELETE", "/customers/"+flagged.CustomerID+"/review", "jane", "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	w = send("PUT", "/customers/"+flagged.CustomerID+"/review", "bob", `{"assignee": "carol"}`)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	require.Equal(t, []string{flagged.CustomerID}, ids(queue("?assignee=carol")))
+
+	w = send("PUT", "/customers/"+flagged.CustomerID+"/review", "", "")
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	w = send("PUT", "/customers/missing/review", "jane", "")
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	w = send("GET", "/customers/reviews?reason=other", "", "")
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
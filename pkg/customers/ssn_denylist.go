@@ -0,0 +1,192 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/secrets/hash"
+)
+
+// ssnDenylistActor is recorded on the status change of each Customer rejected by the denylist
+const ssnDenylistActor = "ssn-denylist"
+
+const defaultDenylistReason = "SSN is on the denylist"
+
+var (
+	ssnDenylistMatches = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "ssn_denylist_matches",
+		Help: "Counter of Customer SSNs which matched the denylist",
+	}, nil)
+)
+
+// SSNDenylist is a list of SSNs Customers can't have, like an internal denied-persons list. It holds
+// the SHA256 hash of each SSN's nine digits prefixed with a salt, so the file never contains SSNs.
+//
+// Each line of the file is a hex encoded hash optionally followed by a comma and the reason it's
+// denied. Blank lines and lines starting with # are ignored.
+type SSNDenylist struct {
+	path string
+	salt string
+
+	mu       sync.RWMutex
+	entries  map[string]string // hash to reason
+	loadedAt time.Time
+}
+
+// NewSSNDenylist reads the denylist at path, which hashes SSNs with salt
+func NewSSNDenylist(path, salt string) (*SSNDenylist, error) {
+	d := &SSNDenylist{
+		path: path,
+		salt: salt,
+	}
+	if err := d.Reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Reload reads the denylist file again. The previous entries are kept when the file can't be read.
+func (d *SSNDenylist) Reload() error {
+	fd, err := os.Open(d.path)
+	if err != nil {
+		return fmt.Errorf("ssn denylist: %v", err)
+	}
+	defer fd.Close()
+
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(fd)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		hashed, reason := text, defaultDenylistReason
+		if idx := strings.Index(text, ","); idx >= 0 {
+			hashed = strings.TrimSpace(text[:idx])
+			if r := strings.TrimSpace(text[idx+1:]); r != "" {
+				reason = r
+			}
+		}
+		hashed = strings.ToLower(hashed)
+		if bs, err := hex.DecodeString(hashed); err != nil || len(bs) != 32 {
+			return fmt.Errorf("ssn denylist: line %d isn't a SHA256 hash", line)
+		}
+		entries[hashed] = reason
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("ssn denylist: reading %s: %v", d.path, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = entries
+	d.loadedAt = time.Now()
+	return nil
+}
+
+// match returns the reason raw is denied, or an empty string when it isn't on the denylist
+func (d *SSNDenylist) match(raw string) string {
+	if d == nil {
+		return ""
+	}
+	hashed, _ := hash.SHA256Hash(d.salt, normalizeSSN(raw))
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.entries[hashed]
+}
+
+type ssnDenylistStatus struct {
+	Path     string    `json:"path"`
+	Entries  int       `json:"entries"`
+	LoadedAt time.Time `json:"loadedAt"`
+}
+
+func (d *SSNDenylist) status() ssnDenylistStatus {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return ssnDenylistStatus{Path: d.path, Entries: len(d.entries), LoadedAt: d.loadedAt}
+}
+
+// rejectDeniedSSN rejects the Customer when their new SSN is on the denylist, recording its reason in
+// the status history. The SSN is never logged.
+func rejectDeniedSSN(ctx context.Context, logger log.Logger, repo CustomerRepository, customerID, organization string, ssn *SSN) error {
+	if ssn == nil || ssn.denied == "" {
+		return nil
+	}
+	ssnDenylistMatches.Add(1)
+
+	cust, err := repo.GetCustomer(ctx, customerID, organization)
+	if err != nil || cust == nil {
+		return err
+	}
+	if err := TransitionAllowed(cust.Status, client.CUSTOMERSTATUS_REJECTED); err != nil {
+		return nil // e.g. already Rejected or Deceased
+	}
+
+	logger.LogErrorf("customer=%s SSN matched the denylist - rejecting customer", customerID)
+
+	comment := "SSN matched the denylist: " + ssn.denied
+	if err := repo.updateCustomerStatus(ctx, customerID, client.CUSTOMERSTATUS_REJECTED, comment, ssnDenylistActor); err != nil {
+		return fmt.Errorf("rejecting customer=%s: %v", customerID, err)
+	}
+
+	// Block the Customer's representatives too
+	cust.Status = client.CUSTOMERSTATUS_REJECTED
+	if _, err := applyStatusRollup(ctx, logger, repo, cust); err != nil {
+		logger.LogErrorf("error blocking representatives of customer=%s: %v", customerID, err)
+	}
+	return nil
+}
+
+// AddSSNDenylistAdminRoutes lets operators see how many SSNs are denied and reload the denylist
+// after it's changed.
+func AddSSNDenylistAdminRoutes(logger log.Logger, svc *admin.Server, denylist *SSNDenylist) {
+	logger = logger.Set("package", log.String("customers"))
+
+	svc.AddHandler("/ssn-denylist", ssnDenylist(logger, denylist))
+}
+
+func ssnDenylist(logger log.Logger, denylist *SSNDenylist) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		switch r.Method {
+		case "GET":
+		case "POST":
+			if err := denylist.Reload(); err != nil {
+				logger.LogErrorf("problem reloading SSN denylist: %v", err)
+				route.Problem(w, err)
+				return
+			}
+			logger.Logf("reloaded SSN denylist with %d entries", denylist.status().Entries)
+		default:
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(denylist.status())
+	}
+}
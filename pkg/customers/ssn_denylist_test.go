@@ -0,0 +1,169 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/secrets/hash"
+)
+
+// ssnDenylistPath returns a path in a new directory which is removed after the test
+func ssnDenylistPath(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "ssn-denylist")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "denylist.txt")
+}
+
+func writeSSNDenylist(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	require.NoError(t, ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600))
+}
+
+func hashDeniedSSN(t *testing.T, salt, ssn string) string {
+	t.Helper()
+	hashed, err := hash.SHA256Hash(salt, ssn)
+	require.NoError(t, err)
+	return hashed
+}
+
+func TestSSNDenylist(t *testing.T) {
+	path := ssnDenylistPath(t)
+	writeSSNDenylist(t, path,
+		"# internal denied persons",
+		"",
+		strings.ToUpper(hashDeniedSSN(t, "salt", "123456789"))+", chargeback fraud",
+		hashDeniedSSN(t, "salt", "987654321"),
+	)
+
+	denylist, err := NewSSNDenylist(path, "salt")
+	require.NoError(t, err)
+	require.Equal(t, 2, denylist.status().Entries)
+
+	require.Equal(t, "chargeback fraud", denylist.match("123-45-6789"))
+	require.Equal(t, defaultDenylistReason, denylist.match("987654321"))
+	require.Empty(t, denylist.match("111223333"))
+
+	var empty *SSNDenylist
+	require.Empty(t, empty.match("123456789"))
+
+	// a bad file keeps the loaded entries, and errors don't include the line
+	writeSSNDenylist(t, path, hashDeniedSSN(t, "salt", "111223333"), "123456789")
+	err = denylist.Reload()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "line 2")
+	require.NotContains(t, err.Error(), "123456789")
+	require.Equal(t, "chargeback fraud", denylist.match("123456789"))
+
+	writeSSNDenylist(t, path, hashDeniedSSN(t, "salt", "111223333"))
+	require.NoError(t, denylist.Reload())
+	require.Empty(t, denylist.match("123456789"))
+	require.Equal(t, defaultDenylistReason, denylist.match("111-22-3333"))
+
+	_, err = NewSSNDenylist(ssnDenylistPath(t), "salt")
+	require.Error(t, err)
+}
+
+func TestSSNDenylist__rejectsCustomers(t *testing.T) {
+	path := ssnDenylistPath(t)
+	writeSSNDenylist(t, path, hashDeniedSSN(t, "", "123456789")+",chargeback fraud")
+	denylist, err := NewSSNDenylist(path, "")
+	require.NoError(t, err)
+
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	storage := testCustomerSSNStorage(t)
+	storage.UseDenylist(denylist)
+
+	router := mux.NewRouter()
+	AddCustomerRoutes(log.NewNopLogger(), router, repo, storage, createTestOFACSearcher(nil, nil), nil, nil)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Organization", "test")
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NotContains(t, w.Body.String(), "123456789")
+		return w
+	}
+
+	// a new Customer with a denied SSN is rejected
+	w := send("POST", "/customers", `{"firstName": "Jane", "lastName": "Doe", "type": "individual", "ssn": "123-45-6789"}`)
+	var cust client.Customer
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&cust))
+	require.Equal(t, client.CUSTOMERSTATUS_REJECTED, cust.Status)
+
+	history, err := repo.getStatusHistory(context.Background(), cust.CustomerID)
+	require.NoError(t, err)
+	latest := history[len(history)-1]
+	require.Equal(t, ssnDenylistActor, latest.Actor)
+	require.Equal(t, "SSN matched the denylist: chargeback fraud", latest.Comment)
+
+	// other Customers aren't
+	w = send("POST", "/customers", `{"firstName": "John", "lastName": "Doe", "type": "individual", "ssn": "111-22-3333"}`)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&cust))
+	require.Equal(t, client.CUSTOMERSTATUS_UNKNOWN, cust.Status)
+
+	// until they're given a denied SSN
+	send("PUT", fmt.Sprintf("/customers/%s", cust.CustomerID), `{"firstName": "John", "lastName": "Doe", "type": "individual", "ssn": "123456789"}`)
+	found, err := repo.GetCustomer(context.Background(), cust.CustomerID, "test")
+	require.NoError(t, err)
+	require.Equal(t, client.CUSTOMERSTATUS_REJECTED, found.Status)
+}
+
+func TestSSNDenylist__adminReload(t *testing.T) {
+	path := ssnDenylistPath(t)
+	writeSSNDenylist(t, path, hashDeniedSSN(t, "", "123456789"))
+	denylist, err := NewSSNDenylist(path, "")
+	require.NoError(t, err)
+
+	handler := ssnDenylist(log.NewNopLogger(), denylist)
+	call := func(method string) (*httptest.ResponseRecorder, ssnDenylistStatus) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(method, "/ssn-denylist", nil))
+
+		var status ssnDenylistStatus
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+		}
+		return w, status
+	}
+
+	w, status := call("GET")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 1, status.Entries)
+
+	writeSSNDenylist(t, path, hashDeniedSSN(t, "", "123456789"), hashDeniedSSN(t, "", "111223333"))
+	w, status = call("POST")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, 2, status.Entries)
+	require.Equal(t, defaultDenylistReason, denylist.match("111223333"))
+
+	writeSSNDenylist(t, path, "not a hash")
+	w, _ = call("POST")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = call("DELETE")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+// SSNRemasker recomputes the stored mask of every SSN from the decrypted SSN with the current
+// masking rule, e.g. after the rule changes or to fill in rows missing a mask.
+//
+// Only masks which differ are written, so running it again updates nothing.
+type SSNRemasker struct {
+	logger    log.Logger
+	db        customersdb.Querier
+	keeper    *secrets.StringKeeper
+	batchSize int
+}
+
+func NewSSNRemasker(logger log.Logger, db customersdb.Querier, keeper *secrets.StringKeeper) *SSNRemasker {
+	return &SSNRemasker{
+		logger:    logger.Set("package", log.String("customers")),
+		db:        db,
+		keeper:    keeper,
+		batchSize: 100,
+	}
+}
+
+// SSNRemaskResult counts the SSNs read by a remask and what happened to them
+type SSNRemaskResult struct {
+	Scanned int `json:"scanned"`
+	Updated int `json:"updated"`
+
+	// Failed SSNs couldn't be decrypted and kept their mask
+	Failed int `json:"failed"`
+}
+
+type storedSSN struct {
+	ownerID, ownerType string
+	encrypted, masked  string
+}
+
+// Remask reads every SSN in batches and updates any mask which doesn't match the current rule
+func (m *SSNRemasker) Remask(ctx context.Context) (SSNRemaskResult, error) {
+	var result SSNRemaskResult
+	after := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		batch, err := m.readSSNs(after)
+		if err != nil {
+			return result, err
+		}
+		for i := range batch {
+			result.Scanned++
+
+			raw, err := m.keeper.DecryptString(batch[i].encrypted)
+			if err != nil {
+				// errors never include the SSN
+				m.logger.Set("ownerID", log.String(batch[i].ownerID)).LogErrorf("problem decrypting SSN: %v", err)
+				result.Failed++
+				continue
+			}
+			masked := maskSSN(raw)
+			if masked == batch[i].masked {
+				continue
+			}
+			query := `update ssn set ssn_masked = ? where owner_id = ? and owner_type = ?;`
+			if _, err := m.db.Exec(query, masked, batch[i].ownerID, batch[i].ownerType); err != nil {
+				return result, fmt.Errorf("remask owner=%s: %v", batch[i].ownerID, err)
+			}
+			result.Updated++
+		}
+		if len(batch) < m.batchSize {
+			return result, nil
+		}
+		after = batch[len(batch)-1].ownerID
+	}
+}
+
+func (m *SSNRemasker) readSSNs(after string) ([]storedSSN, error) {
+	query := `select owner_id, owner_type, ssn, ssn_masked from ssn where owner_id > ? order by owner_id asc limit ?;`
+	rows, err := m.db.Query(query, after, m.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("readSSNs: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []storedSSN
+	for rows.Next() {
+		var s storedSSN
+		var encrypted, masked *string
+		if err := rows.Scan(&s.ownerID, &s.ownerType, &encrypted, &masked); err != nil {
+			return nil, fmt.Errorf("readSSNs: scan: %v", err)
+		}
+		if encrypted != nil {
+			s.encrypted = *encrypted
+		}
+		if masked != nil {
+			s.masked = *masked
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// AddSSNRemaskAdminRoutes lets operators recompute the mask of every stored SSN
+func AddSSNRemaskAdminRoutes(logger log.Logger, svc *admin.Server, remasker *SSNRemasker) {
+	logger = logger.Set("package", log.String("customers"))
+
+	svc.AddHandler("/ssn/remask", remaskSSNs(logger, remasker))
+}
+
+func remaskSSNs(logger log.Logger, remasker *SSNRemasker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if r.Method != "POST" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		result, err := remasker.Remask(r.Context())
+		if err != nil {
+			logger.LogErrorf("problem remasking SSNs: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		logger.Logf("remasked SSNs: scanned=%d updated=%d failed=%d", result.Scanned, result.Updated, result.Failed)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+func TestSSNRemasker(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	keeper := secrets.TestStringKeeper(t)
+	storage := NewSSNStorage(keeper, NewCustomerSSNRepository(log.NewNopLogger(), db.DB), "")
+
+	// SSNs with an outdated mask, a missing mask, the current mask and one which can't be decrypted
+	var ownerIDs []string
+	for _, masked := range []string{"1#######9", "", "#####6789"} {
+		ssn, err := storage.encryptRaw(base.ID(), client.OWNERTYPE_CUSTOMER, "123-45-6789")
+		require.NoError(t, err)
+		ssn.masked = masked
+		require.NoError(t, storage.repo.saveSSN(ssn))
+		ownerIDs = append(ownerIDs, ssn.ownerID)
+	}
+	_, err := db.DB.Exec(`update ssn set ssn_masked = null where owner_id = ?;`, ownerIDs[1])
+	require.NoError(t, err)
+	plaintext := &SSN{ownerID: base.ID(), ownerType: client.OWNERTYPE_REPRESENTATIVE, encrypted: "123456789", masked: "1#######9"}
+	require.NoError(t, storage.repo.saveSSN(plaintext))
+
+	remasker := NewSSNRemasker(log.NewNopLogger(), db.DB, keeper)
+	remasker.batchSize = 2
+
+	handler := remaskSSNs(log.NewNopLogger(), remasker)
+	remask := func() SSNRemaskResult {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("POST", "/ssn/remask", nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var result SSNRemaskResult
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		return result
+	}
+	require.Equal(t, SSNRemaskResult{Scanned: 4, Updated: 2, Failed: 1}, remask())
+
+	for _, ownerID := range ownerIDs {
+		ssn, err := storage.repo.getSSN(ownerID, client.OWNERTYPE_CUSTOMER)
+		require.NoError(t, err)
+		require.Equal(t, "#####6789", ssn.masked)
+	}
+	ssn, err := storage.repo.getSSN(plaintext.ownerID, client.OWNERTYPE_REPRESENTATIVE)
+	require.NoError(t, err)
+	require.Equal(t, "1#######9", ssn.masked)
+
+	// running again changes nothing
+	require.Equal(t, SSNRemaskResult{Scanned: 4, Updated: 0, Failed: 1}, remask())
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/ssn/remask", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/client"
+)
+
+// taxFormTypes are the Document types of tax forms, matching pkg/documents. A tax form Document is
+// on file once its certifiedAt metadata is recorded and until it's deleted or expires.
+var taxFormTypes = []string{"w9", "w8ben"}
+
+const taxFormCertifiedAtKey = "certifiedAt"
+
+// requiredTaxForms holds the tax forms, any one of which, Customers of each type need on file before
+// they're Verified. Nothing is required when it's empty.
+var requiredTaxForms map[client.CustomerType][]string
+
+// SetRequiredTaxForms reads which tax forms each type of Customer needs before they're Verified from
+// comma separated type=form pairs, where forms are separated by "|" when any of them is accepted.
+// For example: individual=w9|w8ben,business=w9
+func SetRequiredTaxForms(v string) error {
+	required := make(map[client.CustomerType][]string)
+	for _, pair := range strings.Split(v, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("tax form requirement %q must be a customer type and forms, like individual=w9", pair)
+		}
+		customerType := client.CustomerType(strings.ToLower(strings.TrimSpace(parts[0])))
+		if err := validateCustomerType(customerType); err != nil {
+			return fmt.Errorf("tax form requirement %q: %v", pair, err)
+		}
+		for _, form := range strings.Split(parts[1], "|") {
+			form = strings.ToLower(strings.TrimSpace(form))
+			if !isTaxFormType(form) {
+				return fmt.Errorf("tax form requirement %q: unknown tax form %q, expected one of %s", pair, form, strings.Join(taxFormTypes, ", "))
+			}
+			required[customerType] = append(required[customerType], form)
+		}
+	}
+	requiredTaxForms = required
+	return nil
+}
+
+func isTaxFormType(v string) bool {
+	for i := range taxFormTypes {
+		if v == taxFormTypes[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// missingTaxForms returns the tax forms the Customer can provide when their type requires one and none
+// of them is on file
+func missingTaxForms(ctx context.Context, repo CustomerRepository, cust *client.Customer) ([]string, error) {
+	required := requiredTaxForms[client.CustomerType(strings.ToLower(string(cust.Type)))]
+	if len(required) == 0 {
+		return nil, nil
+	}
+	onFile, err := repo.getTaxForms(ctx, cust.CustomerID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range required {
+		for j := range onFile {
+			if required[i] == onFile[j] {
+				return nil, nil
+			}
+		}
+	}
+	return required, nil
+}
+
+// getTaxForms returns the types of tax forms the Customer has on file at now
+func (r *sqlCustomerRepository) getTaxForms(ctx context.Context, customerID string, now time.Time) ([]string, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := fmt.Sprintf(`select distinct documents.type from documents
+inner join document_metadata on document_metadata.document_id = documents.document_id and document_metadata.meta_key = ?
+where documents.customer_id = ? and documents.type in (?%s) and documents.deleted_at is null
+and (documents.expires_at is null or documents.expires_at > ?);`, strings.Repeat(", ?", len(taxFormTypes)-1))
+
+	args := []interface{}{taxFormCertifiedAtKey, customerID}
+	for i := range taxFormTypes {
+		args = append(args, taxFormTypes[i])
+	}
+	args = append(args, now)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getTaxForms: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var documentType string
+		if err := rows.Scan(&documentType); err != nil {
+			return nil, fmt.Errorf("getTaxForms: scan: %v", err)
+		}
+		out = append(out, documentType)
+	}
+	return out, rows.Err()
+}
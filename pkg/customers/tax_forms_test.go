@@ -0,0 +1,92 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package customers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+func TestTaxForms__SetRequiredTaxForms(t *testing.T) {
+	defer func(required map[client.CustomerType][]string) { requiredTaxForms = required }(requiredTaxForms)
+
+	require.NoError(t, SetRequiredTaxForms(" Individual = W9 | w8ben, business=w9,"))
+	require.Equal(t, map[client.CustomerType][]string{
+		client.CUSTOMERTYPE_INDIVIDUAL: {"w9", "w8ben"},
+		client.CUSTOMERTYPE_BUSINESS:   {"w9"},
+	}, requiredTaxForms)
+
+	require.Error(t, SetRequiredTaxForms("individual"))
+	require.Error(t, SetRequiredTaxForms("trust=w9"))
+	require.Error(t, SetRequiredTaxForms("business=w8ben-e"))
+	require.Len(t, requiredTaxForms, 2)
+
+	require.NoError(t, SetRequiredTaxForms(""))
+	require.Empty(t, requiredTaxForms)
+}
+
+func TestTaxForms__requiredForVerification(t *testing.T) {
+	defer func(required map[client.CustomerType][]string) { requiredTaxForms = required }(requiredTaxForms)
+	require.NoError(t, SetRequiredTaxForms("individual=w9|w8ben"))
+
+	repo := createTestCustomerRepository(t)
+	defer repo.close()
+
+	cust, _, _ := (customerRequest{FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}).asCustomer(testCustomerSSNStorage(t))
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, "test"))
+	require.NoError(t, repo.saveCustomerOFACSearch(context.Background(), cust.CustomerID, client.OfacSearch{EntityID: "1", Match: 0.10}))
+	ssnRepo := &testCustomerSSNRepository{ssn: &SSN{ownerID: cust.CustomerID, ownerType: client.OWNERTYPE_CUSTOMER}}
+
+	verify := func() error {
+		_, err := changeCustomerStatus(context.Background(), repo, ssnRepo, cust.CustomerID, "test", client.UpdateCustomerStatus{Status: client.CUSTOMERSTATUS_VERIFIED}, "operator")
+		return err
+	}
+	addTaxForm := func(documentType string, expiresAt *time.Time, certifiedAt string) {
+		documentID := base.ID()
+		_, err := repo.db.Exec(`insert into documents (document_id, customer_id, type, content_type, uploaded_at, expires_at) values (?, ?, ?, ?, ?, ?);`,
+			documentID, cust.CustomerID, documentType, "application/pdf", time.Now(), expiresAt)
+		require.NoError(t, err)
+		if certifiedAt != "" {
+			_, err = repo.db.Exec(`insert into document_metadata (document_id, meta_key, meta_value, created_at, last_modified) values (?, ?, ?, ?, ?);`,
+				documentID, taxFormCertifiedAtKey, certifiedAt, time.Now(), time.Now())
+			require.NoError(t, err)
+		}
+	}
+	missing := func() []string {
+		found, err := repo.GetCustomer(context.Background(), cust.CustomerID, "test")
+		require.NoError(t, err)
+		return found.MissingTaxForms
+	}
+
+	require.Equal(t, []string{"w9", "w8ben"}, missing())
+	err := verify()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "customer has no tax form on file, expected one of w9, w8ben")
+
+	// uncertified, expired and other Documents don't count
+	expired := time.Now().Add(-time.Hour)
+	addTaxForm("w9", nil, "")
+	addTaxForm("w8ben", &expired, "2020-01-02")
+	addTaxForm("passport", nil, "2020-01-02")
+	require.Len(t, missing(), 2)
+	require.Error(t, verify())
+
+	addTaxForm("w8ben", nil, "2020-01-02")
+	require.Empty(t, missing())
+	require.NoError(t, verify())
+
+	// businesses aren't required to have a tax form
+	biz, _, _ := (customerRequest{BusinessName: "Acme", Type: client.CUSTOMERTYPE_BUSINESS}).asCustomer(testCustomerSSNStorage(t))
+	require.NoError(t, repo.CreateCustomer(context.Background(), biz, "test"))
+	found, err := repo.GetCustomer(context.Background(), biz.CustomerID, "test")
+	require.NoError(t, err)
+	require.Empty(t, found.MissingTaxForms)
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/base/log"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"gocloud.dev/secrets"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// archiveManifestName is the entry listing every Document in an archive, including those skipped
+const archiveManifestName = "manifest.json"
+
+// archiveManifest describes each Document of an archive
+type archiveManifest struct {
+	CustomerID string                 `json:"customerID"`
+	CreatedAt  time.Time              `json:"createdAt"`
+	Documents  []archiveManifestEntry `json:"documents"`
+}
+
+type archiveManifestEntry struct {
+	DocumentID  string    `json:"documentID"`
+	Type        string    `json:"type"`
+	ContentType string    `json:"contentType"`
+	UploadedAt  time.Time `json:"uploadedAt"`
+
+	// Filename is the entry holding the Document, empty when it was skipped
+	Filename string `json:"filename,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// archiveFilename names a Document's entry by its type and upload date. The DocumentID keeps names
+// unique when several Documents of a type are uploaded on the same day.
+func archiveFilename(doc *client.Document) string {
+	return fmt.Sprintf("%s_%s_%s%s", doc.Type, doc.UploadedAt.UTC().Format("2006-01-02"), doc.DocumentID, contentTypeExtension(doc.ContentType))
+}
+
+func contentTypeExtension(contentType string) string {
+	switch mediaType(contentType) {
+	case "application/pdf":
+		return ".pdf"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	}
+	return ""
+}
+
+// retrieveDocumentArchive streams a zip archive of every non-deleted Document of a Customer. Each
+// Document is decrypted and written before the next is read, so only one is held in memory.
+// Documents missing from storage are skipped and noted in the manifest.
+func retrieveDocumentArchive(logger log.Logger, repo DocumentRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+
+		docs, err := repo.getCustomerDocuments(r.Context(), customerID, organization)
+		if err != nil {
+			logger.LogErrorf("failed to list documents for archive: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		bucket, err := bucketFactory()
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		defer bucket.Close()
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-documents.zip"`, customerID))
+		w.WriteHeader(http.StatusOK)
+
+		manifest := archiveManifest{
+			CustomerID: customerID,
+			CreatedAt:  time.Now(),
+			Documents:  make([]archiveManifestEntry, 0, len(docs)),
+		}
+		zw := zip.NewWriter(w)
+		for i := range docs {
+			entry := archiveManifestEntry{
+				DocumentID:  docs[i].DocumentID,
+				Type:        docs[i].Type,
+				ContentType: docs[i].ContentType,
+				UploadedAt:  docs[i].UploadedAt,
+			}
+			if err := writeArchiveDocument(r.Context(), zw, bucket, keeper, customerID, docs[i]); err != nil {
+				if _, ok := err.(archiveSkipError); !ok {
+					// the response has started, so all we can do is stop
+					logger.Set("documentID", log.String(docs[i].DocumentID)).LogErrorf("failed writing document archive: %v", err)
+					return
+				}
+				logger.Set("documentID", log.String(docs[i].DocumentID)).Logf("skipping document in archive: %v", err)
+				entry.Error = err.Error()
+			} else {
+				entry.Filename = archiveFilename(docs[i])
+			}
+			manifest.Documents = append(manifest.Documents, entry)
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+
+		mw, err := zw.Create(archiveManifestName)
+		if err == nil {
+			enc := json.NewEncoder(mw)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(manifest)
+		}
+		if err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			logger.LogErrorf("failed finishing document archive: %v", err)
+		}
+	}
+}
+
+// archiveSkipError is a Document which couldn't be read, it's left out of the archive
+type archiveSkipError struct {
+	err error
+}
+
+func (e archiveSkipError) Error() string {
+	return e.err.Error()
+}
+
+// writeArchiveDocument adds one decrypted Document to the archive. Documents which can't be read
+// or decrypted return an archiveSkipError before anything is written.
+func writeArchiveDocument(ctx context.Context, zw *zip.Writer, bucket *blob.Bucket, keeper *secrets.Keeper, customerID string, doc *client.Document) error {
+	ctx, cancelFn := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelFn()
+
+	rdr, err := bucket.NewReader(ctx, makeDocumentKey(customerID, doc.DocumentID), nil)
+	if err != nil {
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			return archiveSkipError{err: fmt.Errorf("document not found in storage")}
+		}
+		return archiveSkipError{err: fmt.Errorf("reading document: %v", err)}
+	}
+	defer rdr.Close()
+
+	encrypted, err := ioutil.ReadAll(rdr)
+	if err != nil {
+		return archiveSkipError{err: fmt.Errorf("reading document: %v", err)}
+	}
+	data, err := keeper.Decrypt(ctx, encrypted)
+	if err != nil {
+		return archiveSkipError{err: fmt.Errorf("decrypting document: %v", err)}
+	}
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     archiveFilename(doc),
+		Method:   zip.Deflate,
+		Modified: doc.UploadedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+func TestDocuments__archive(t *testing.T) {
+	repo := &testDocumentRepository{docExists: true}
+	bucketFactory := storage.NewTestBucket(t)
+
+	router := mux.NewRouter()
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), bucketFactory, nil, nil)
+
+	// upload a document
+	w := httptest.NewRecorder()
+	req := multipartRequest(t)
+	req.Header.Set("X-organization", "test")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var doc client.Document
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&doc))
+
+	uploadedAt := time.Date(2020, time.June, 12, 10, 0, 0, 0, time.UTC)
+	missing := &client.Document{DocumentID: base.ID(), Type: "UtilityBill", ContentType: "application/pdf", UploadedAt: uploadedAt}
+	doc.UploadedAt = uploadedAt
+	repo.documents = []*client.Document{&doc, missing}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/customers/foo/documents/archive", nil)
+	req.Header.Set("X-organization", "test")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	require.Equal(t, `attachment; filename="foo-documents.zip"`, w.Header().Get("Content-Disposition"))
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+
+	// the uploaded document is decrypted
+	require.Equal(t, "driverslicense_2020-06-12_"+doc.DocumentID+".jpg", zr.File[0].Name)
+	rc, err := zr.File[0].Open()
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(rc)
+	rc.Close()
+	require.NoError(t, err)
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "colorado.jpg"))
+	require.NoError(t, err)
+	require.Equal(t, expected, got)
+
+	// the missing document is noted in the manifest
+	require.Equal(t, archiveManifestName, zr.File[1].Name)
+	rc, err = zr.File[1].Open()
+	require.NoError(t, err)
+	defer rc.Close()
+	var manifest archiveManifest
+	require.NoError(t, json.NewDecoder(rc).Decode(&manifest))
+	require.Equal(t, "foo", manifest.CustomerID)
+	require.Len(t, manifest.Documents, 2)
+	require.Equal(t, zr.File[0].Name, manifest.Documents[0].Filename)
+	require.Empty(t, manifest.Documents[0].Error)
+	require.Equal(t, missing.DocumentID, manifest.Documents[1].DocumentID)
+	require.Empty(t, manifest.Documents[1].Filename)
+	require.True(t, strings.Contains(manifest.Documents[1].Error, "not found"), manifest.Documents[1].Error)
+}
+
+func TestDocuments__archiveErrors(t *testing.T) {
+	repo := &testDocumentRepository{err: os.ErrClosed}
+
+	router := mux.NewRouter()
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.TestBucket, nil, nil)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/customers/foo/documents/archive", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req.Header.Set("X-organization", "test")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
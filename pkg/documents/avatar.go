@@ -0,0 +1,348 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"gocloud.dev/blob"
+	"gocloud.dev/secrets"
+
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// avatarSize is the width and height avatars are cropped and scaled down to
+var avatarSize = readPreviewDimension("AVATAR_SIZE", 256)
+
+var errAvatarContentType = route.Validation(errors.New("avatars must be a JPEG or PNG image"))
+
+// Avatar describes the profile image of a Customer. Each Customer has at most one avatar and
+// uploading another replaces it.
+type Avatar struct {
+	CustomerID  string    `json:"customerID"`
+	ContentType string    `json:"contentType"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	ETag        string    `json:"etag"`
+	UploadedAt  time.Time `json:"uploadedAt"`
+}
+
+func AddAvatarRoutes(logger log.Logger, r *mux.Router, repo AvatarRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc) {
+	logger = logger.Set("package", log.String("documents"))
+
+	r.Methods("GET").Path("/customers/{customerID}/avatar").HandlerFunc(getAvatar(logger, repo, keeper, bucketFactory))
+	r.Methods("PUT").Path("/customers/{customerID}/avatar").HandlerFunc(uploadAvatar(logger, repo, keeper, bucketFactory))
+}
+
+// makeAvatarKey is outside of the Customer's documents so listing Documents never includes it
+func makeAvatarKey(customerID string) string {
+	return path.Join("customers", customerID, "avatar")
+}
+
+// resizeAvatar crops an image to its center square and scales it down to size. Smaller images are
+// cropped but not enlarged. Avatars keep the format of the upload.
+func resizeAvatar(data []byte, contentType string, size int) ([]byte, image.Rectangle, error) {
+	encode := imageEncoder(contentType)
+	if encode == nil {
+		return nil, image.Rectangle{}, errAvatarContentType
+	}
+	img, err := decodeImage(data)
+	if err != nil {
+		return nil, image.Rectangle{}, route.Validation(err)
+	}
+
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	min := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+	square := image.Rectangle{Min: min, Max: min.Add(image.Pt(side, side))}
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		img = sub.SubImage(square)
+	}
+	img = scaleImage(img, size, size)
+
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		return nil, image.Rectangle{}, fmt.Errorf("encoding avatar: %v", err)
+	}
+	return buf.Bytes(), img.Bounds(), nil
+}
+
+func avatarETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func uploadAvatar(logger log.Logger, repo AvatarRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+
+		if exists, err := repo.customerExists(customerID, organization); !exists || err != nil {
+			if err != nil {
+				logger.LogErrorf("failed to check customer existence: %v", err)
+			}
+			route.NotFound(w, r)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, int64(maxFormSize))
+		file, fileHeader, err := r.FormFile("file")
+		if err != nil {
+			if strings.Contains(err.Error(), "request body too large") {
+				route.Problem(w, fmt.Errorf("request body exceeds maximum size of %s", maxFormSize))
+				return
+			}
+			route.Problem(w, fmt.Errorf("expected multipart upload with key of 'file' error=%v", err))
+			return
+		}
+		defer file.Close()
+
+		if fileHeader.Size > int64(maxDocumentSize) {
+			route.Problem(w, fmt.Errorf("file exceeds maximum size of %s", maxDocumentSize))
+			return
+		}
+
+		fileReader := bufio.NewReader(file)
+		sniff, err := fileReader.Peek(512)
+		if err != nil && err != io.EOF {
+			logger.LogErrorf("peek failed: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		contentType, err := checkContentType(http.DetectContentType(sniff), fileHeader.Header.Get("Content-Type"))
+		if err != nil || !strings.HasPrefix(contentType, "image/") {
+			route.Problem(w, errAvatarContentType)
+			return
+		}
+
+		data, err := ioutil.ReadAll(fileReader)
+		if err != nil {
+			logger.LogErrorf("read failed: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		resized, bounds, err := resizeAvatar(data, contentType, avatarSize)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+
+		bucket, err := bucketFactory()
+		if err != nil {
+			logger.LogErrorf("failed to create bucket: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		defer bucket.Close()
+
+		ctx, cancelFn := context.WithTimeout(context.TODO(), 60*time.Second)
+		defer cancelFn()
+
+		encrypted, err := keeper.Encrypt(ctx, resized)
+		if err != nil {
+			logger.LogErrorf("failed to encrypt avatar: %v", err)
+			route.Problem(w, fmt.Errorf("file upload error - %v", err))
+			return
+		}
+		// writing to the same key replaces the previous avatar
+		err = bucket.WriteAll(ctx, makeAvatarKey(customerID), encrypted, &blob.WriterOptions{
+			ContentDisposition: "inline",
+			ContentType:        contentType,
+		})
+		if err != nil {
+			logger.LogErrorf("problem uploading avatar: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		avatar := &Avatar{
+			CustomerID:  customerID,
+			ContentType: contentType,
+			Width:       bounds.Dx(),
+			Height:      bounds.Dy(),
+			ETag:        avatarETag(resized),
+			UploadedAt:  time.Now(),
+		}
+		if err := repo.saveAvatar(avatar); err != nil {
+			logger.LogErrorf("failed to save avatar: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("ETag", avatar.ETag)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(avatar)
+	}
+}
+
+func getAvatar(logger log.Logger, repo AvatarRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+
+		avatar, err := repo.getAvatar(customerID, organization)
+		if err != nil {
+			logger.LogErrorf("failed to read avatar: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		if avatar == nil {
+			route.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("ETag", avatar.ETag)
+		if r.Header.Get("If-None-Match") == avatar.ETag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		bucket, err := bucketFactory()
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		defer bucket.Close()
+
+		ctx, cancelFn := context.WithTimeout(context.TODO(), 10*time.Second)
+		defer cancelFn()
+
+		encrypted, err := bucket.ReadAll(ctx, makeAvatarKey(customerID))
+		if err != nil {
+			logger.LogErrorf("failed reading avatar from storage bucket: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		data, err := keeper.Decrypt(ctx, encrypted)
+		if err != nil {
+			logger.LogErrorf("failed to decrypt avatar: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", avatar.ContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}
+}
+
+type AvatarRepository interface {
+	customerExists(customerID, organization string) (bool, error)
+	getAvatar(customerID, organization string) (*Avatar, error)
+	saveAvatar(avatar *Avatar) error
+}
+
+func NewAvatarRepo(logger log.Logger, db *sql.DB) AvatarRepository {
+	return &sqlAvatarRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+type sqlAvatarRepository struct {
+	db     *sql.DB
+	logger log.Logger
+}
+
+func (r *sqlAvatarRepository) customerExists(customerID, organization string) (bool, error) {
+	return customerExists(r.db, customerID, organization)
+}
+
+// preparer is satisfied by both *sql.DB and *sql.Tx
+type preparer interface {
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+func customerExists(db preparer, customerID, organization string) (bool, error) {
+	query := `select customer_id from customers where customer_id = ? and organization = ? and deleted_at is null limit 1;`
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("customerExists: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	var id string
+	if err := stmt.QueryRow(customerID, organization).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("customerExists: scan: %v", err)
+	}
+	return id == customerID, nil
+}
+
+func (r *sqlAvatarRepository) getAvatar(customerID, organization string) (*Avatar, error) {
+	query := `select a.customer_id, a.content_type, a.width, a.height, a.etag, a.uploaded_at from customer_avatars as a
+inner join customers on customers.customer_id = a.customer_id
+where a.customer_id = ? and customers.organization = ? and customers.deleted_at is null limit 1;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("getAvatar: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	var avatar Avatar
+	err = stmt.QueryRow(customerID, organization).Scan(&avatar.CustomerID, &avatar.ContentType, &avatar.Width, &avatar.Height, &avatar.ETag, &avatar.UploadedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getAvatar: scan: %v", err)
+	}
+	return &avatar, nil
+}
+
+func (r *sqlAvatarRepository) saveAvatar(avatar *Avatar) error {
+	query := `replace into customer_avatars (customer_id, content_type, width, height, etag, uploaded_at) values (?, ?, ?, ?, ?, ?);`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("saveAvatar: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(avatar.CustomerID, avatar.ContentType, avatar.Width, avatar.Height, avatar.ETag, avatar.UploadedAt)
+	if err != nil {
+		return fmt.Errorf("saveAvatar: exec: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+func avatarRequest(t *testing.T, customerID string, data []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mp := multipart.NewWriter(&body)
+	part, err := mp.CreateFormFile("file", "avatar")
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, mp.Close())
+
+	req := httptest.NewRequest("PUT", "/customers/"+customerID+"/avatar", &body)
+	req.Header.Set("Content-Type", mp.FormDataContentType())
+	req.Header.Set("X-Organization", "test")
+	return req
+}
+
+func TestDocuments__resizeAvatar(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 600, 300))))
+
+	out, bounds, err := resizeAvatar(buf.Bytes(), "image/png", 128)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 128, 128), image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+	require.NoError(t, err)
+	require.Equal(t, "png", format)
+	require.Equal(t, 128, cfg.Width)
+	require.Equal(t, 128, cfg.Height)
+
+	// small images are cropped but not enlarged
+	_, bounds, err = resizeAvatar(buf.Bytes(), "image/png", 1000)
+	require.NoError(t, err)
+	require.Equal(t, 300, bounds.Dx())
+	require.Equal(t, 300, bounds.Dy())
+
+	_, _, err = resizeAvatar([]byte("%PDF-1.4"), "application/pdf", 128)
+	require.Equal(t, errAvatarContentType, err)
+}
+
+func TestDocuments__avatar(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	customerRepo := customers.NewCustomerRepo(logger, db.DB)
+	require.NoError(t, customerRepo.CreateCustomer(context.Background(), &client.Customer{CustomerID: "foo", FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}, "test"))
+
+	bucketFactory := storage.NewTestBucket(t)
+	router := mux.NewRouter()
+	AddAvatarRoutes(logger, router, NewAvatarRepo(logger, db.DB), secrets.TestKeeper(t), bucketFactory)
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/customers/foo/avatar", nil)
+		req.Header.Set("X-Organization", "test")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	jpg, err := ioutil.ReadFile(filepath.Join("testdata", "colorado.jpg"))
+	require.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, avatarRequest(t, "foo", jpg))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var avatar Avatar
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&avatar))
+	require.Equal(t, "image/jpeg", avatar.ContentType)
+	require.Equal(t, avatarSize, avatar.Width)
+	require.Equal(t, avatarSize, avatar.Height)
+	require.Equal(t, avatar.ETag, w.Header().Get("ETag"))
+
+	w = get("")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	require.Equal(t, avatar.ETag, w.Header().Get("ETag"))
+	cfg, _, err := image.DecodeConfig(w.Body)
+	require.NoError(t, err)
+	require.Equal(t, avatarSize, cfg.Width)
+
+	w = get(avatar.ETag)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Zero(t, w.Body.Len())
+
+	// uploading again replaces the avatar
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 64))))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, avatarRequest(t, "foo", buf.Bytes()))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = get(avatar.ETag)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	require.NotEqual(t, avatar.ETag, w.Header().Get("ETag"))
+
+	bucket, err := bucketFactory()
+	require.NoError(t, err)
+	defer bucket.Close()
+	iter := bucket.List(nil)
+	obj, err := iter.Next(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, makeAvatarKey("foo"), obj.Key)
+	_, err = iter.Next(context.Background())
+	require.Error(t, err) // only one avatar is stored
+
+	// only images are accepted
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, avatarRequest(t, "foo", []byte("%PDF-1.4\n")))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// other organizations can't see or upload the avatar
+	req := httptest.NewRequest("GET", "/customers/foo/avatar", nil)
+	req.Header.Set("X-Organization", "other")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, avatarRequest(t, "bar", buf.Bytes()))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// purging a customer's blobs removes the avatar
+	_, err = DeleteCustomerBlobs(context.Background(), bucketFactory, "foo")
+	require.NoError(t, err)
+	exists, err := bucket.Exists(context.Background(), makeAvatarKey("foo"))
+	require.NoError(t, err)
+	require.False(t, exists)
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+const (
+	maxIPAddressLength = 45  // size of the ip_address column
+	maxUserAgentLength = 512 // size of the user_agent column
+)
+
+// acceptanceEvidence is captured from the request a Customer accepts a Disclaimer with
+type acceptanceEvidence struct {
+	IPAddress string
+	UserAgent string
+}
+
+// readAcceptanceEvidence reads the client's address, preferring the first X-Forwarded-For entry set by
+// proxies in front of Customers, and their User-Agent
+func readAcceptanceEvidence(r *http.Request) acceptanceEvidence {
+	ip := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-For"), ",")[0])
+	if ip == "" {
+		ip = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+	if net.ParseIP(ip) == nil {
+		ip = ""
+	}
+	return acceptanceEvidence{
+		IPAddress: truncate(ip, maxIPAddressLength),
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+	}
+}
+
+func truncate(v string, n int) string {
+	if len(v) > n {
+		return v[:n]
+	}
+	return v
+}
+
+// DisclaimerAcceptance is the record of a Customer accepting a version of a Disclaimer
+type DisclaimerAcceptance struct {
+	DisclaimerID string `json:"disclaimerID"`
+	CustomerID   string `json:"customerID"`
+	Version      int32  `json:"version"`
+
+	// Hash is the hex encoded SHA-256 hash of the text of the version accepted
+	Hash string `json:"hash"`
+
+	AcceptedAt time.Time `json:"acceptedAt"`
+
+	// IPAddress and UserAgent are of the request the Disclaimer was accepted with, when they were captured
+	IPAddress string `json:"ipAddress,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+}
+
+// getDisclaimerAcceptance serves the proof of a Customer accepting a Disclaimer. The latest version they
+// accepted is returned unless a version is asked for.
+func getDisclaimerAcceptance(logger log.Logger, repo DisclaimerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, disclaimerID := route.GetCustomerID(w, r), getDisclaimerID(w, r)
+		if customerID == "" || disclaimerID == "" {
+			return
+		}
+
+		var version int32
+		if v := r.URL.Query().Get("version"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || n < 1 {
+				route.Problem(w, route.Validation(fmt.Errorf("invalid version %q", v)))
+				return
+			}
+			version = int32(n)
+		}
+
+		acceptance, err := repo.getDisclaimerAcceptance(customerID, disclaimerID, version)
+		if err != nil {
+			logger.Set("customerID", log.String(customerID)).LogErrorf("problem reading disclaimer=%s acceptance: %v", disclaimerID, err)
+			route.Problem(w, err)
+			return
+		}
+		if acceptance == nil {
+			route.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(acceptance)
+	}
+}
+
+// getDisclaimerAcceptance returns the Customer's acceptance of a version of the Disclaimer, or the latest
+// version they accepted when version is zero. Nil is returned when they haven't accepted it.
+func (r *sqlDisclaimerRepository) getDisclaimerAcceptance(customerID, disclaimerID string, version int32) (*DisclaimerAcceptance, error) {
+	query := `select da.version, da.accepted_at, da.ip_address, da.user_agent, dv.text from disclaimer_acceptances as da
+inner join disclaimer_versions as dv on dv.disclaimer_id = da.disclaimer_id and dv.version = da.version
+where da.customer_id = ? and da.disclaimer_id = ? and (? = 0 or da.version = ?) and da.accepted_at is not null
+order by da.version desc limit 1;`
+
+	out := &DisclaimerAcceptance{DisclaimerID: disclaimerID, CustomerID: customerID}
+	var ipAddress, userAgent, text sql.NullString
+	err := r.db.QueryRow(query, customerID, disclaimerID, version, version).Scan(&out.Version, &out.AcceptedAt, &ipAddress, &userAgent, &text)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getDisclaimerAcceptance: %v", err)
+	}
+	out.Hash = hashDisclaimer(text.String)
+	out.IPAddress, out.UserAgent = ipAddress.String, userAgent.String
+	return out, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisclaimers__readAcceptanceEvidence(t *testing.T) {
+	req := httptest.NewRequest("POST", "/customers/foo/disclaimers/bar", nil)
+	req.RemoteAddr = "10.1.2.3:51234"
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	require.Equal(t, acceptanceEvidence{IPAddress: "10.1.2.3", UserAgent: "Mozilla/5.0"}, readAcceptanceEvidence(req))
+
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	require.Equal(t, "203.0.113.7", readAcceptanceEvidence(req).IPAddress)
+
+	req.Header.Set("X-Forwarded-For", "not an address")
+	req.Header.Set("User-Agent", strings.Repeat("a", 600))
+	evidence := readAcceptanceEvidence(req)
+	require.Empty(t, evidence.IPAddress)
+	require.Len(t, evidence.UserAgent, maxUserAgentLength)
+}
+
+func TestDisclaimers__getDisclaimerAcceptance(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	repo := &sqlDisclaimerRepository{db.DB, log.NewNopLogger()}
+	customerID := base.ID()
+	disc, err := repo.insertDisclaimer("terms and conditions", "")
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	AddDisclaimerRoutes(log.NewNopLogger(), router, repo)
+
+	get := func(query string) (*httptest.ResponseRecorder, *DisclaimerAcceptance) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/customers/%s/disclaimers/%s/acceptance%s", customerID, disc.DisclaimerID, query), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			return w, nil
+		}
+		var acceptance DisclaimerAcceptance
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&acceptance))
+		return w, &acceptance
+	}
+
+	w, _ := get("")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// accept the first version through the route so its request is captured
+	req := httptest.NewRequest("POST", fmt.Sprintf("/customers/%s/disclaimers/%s", customerID, disc.DisclaimerID), nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w, acceptance := get("")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, int32(1), acceptance.Version)
+	require.Equal(t, hashDisclaimer("terms and conditions"), acceptance.Hash)
+	require.Equal(t, "203.0.113.7", acceptance.IPAddress)
+	require.Equal(t, "Mozilla/5.0", acceptance.UserAgent)
+	require.False(t, acceptance.AcceptedAt.IsZero())
+
+	// the latest version accepted is returned unless another is asked for
+	_, err = repo.updateDisclaimer(disc.DisclaimerID, "new terms and conditions")
+	require.NoError(t, err)
+	require.NoError(t, repo.acceptDisclaimer(customerID, disc.DisclaimerID, 2, acceptanceEvidence{}))
+
+	w, acceptance = get("")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, int32(2), acceptance.Version)
+	require.Equal(t, hashDisclaimer("new terms and conditions"), acceptance.Hash)
+	require.Empty(t, acceptance.IPAddress)
+
+	w, acceptance = get("?version=1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "203.0.113.7", acceptance.IPAddress)
+
+	w, _ = get("?version=3")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w, _ = get("?version=zero")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
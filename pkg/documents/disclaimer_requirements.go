@@ -0,0 +1,81 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/moov-io/base/log"
+	"github.com/moov-io/customers/pkg/route"
+
+	"github.com/gorilla/mux"
+)
+
+// ReadRequiredDisclaimers parses a comma separated list of Disclaimer IDs
+func ReadRequiredDisclaimers(v string) []string {
+	var out []string
+	for _, id := range strings.Split(v, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// UnacceptedDisclaimers returns which of the required Disclaimers the Customer has not accepted.
+// Deleted Disclaimers are not required.
+func UnacceptedDisclaimers(repo DisclaimerRepository, customerID string, required []string) ([]string, error) {
+	if repo == nil || len(required) == 0 {
+		return nil, nil
+	}
+	return repo.getUnacceptedDisclaimers(customerID, required)
+}
+
+// RequireDisclaimers returns middleware which rejects requests for a Customer until they've accepted
+// every required Disclaimer. Only routes whose path template starts with one of pathPrefixes are checked
+// and reads (GET and HEAD requests) are always allowed.
+func RequireDisclaimers(logger log.Logger, repo DisclaimerRepository, required []string, pathPrefixes ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			customerID := mux.Vars(r)["customerID"]
+			if len(required) == 0 || customerID == "" || r.Method == "GET" || r.Method == "HEAD" || !matchesRoute(r, pathPrefixes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			unaccepted, err := UnacceptedDisclaimers(repo, customerID, required)
+			if err != nil {
+				logger.LogErrorf("problem checking disclaimers for customer=%s: %v", customerID, err)
+				route.Problem(w, err)
+				return
+			}
+			if len(unaccepted) > 0 {
+				route.Problem(w, fmt.Errorf("customer has not accepted disclaimers: %s", strings.Join(unaccepted, ", ")))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func matchesRoute(r *http.Request, pathPrefixes []string) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return false
+	}
+	for _, prefix := range pathPrefixes {
+		if strings.HasPrefix(tmpl, prefix) {
+			return true
+		}
+	}
+	return false
+}
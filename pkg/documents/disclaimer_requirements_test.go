@@ -0,0 +1,61 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/moov-io/customers/pkg/client"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisclaimers__ReadRequiredDisclaimers(t *testing.T) {
+	require.Empty(t, ReadRequiredDisclaimers(""))
+	require.Equal(t, []string{"a", "b"}, ReadRequiredDisclaimers("a, b,"))
+}
+
+func TestDisclaimers__RequireDisclaimers(t *testing.T) {
+	repo := &testDisclaimerRepository{
+		disclaimers: []*client.Disclaimer{
+			{DisclaimerID: "terms"},
+			{DisclaimerID: "privacy", AcceptedAt: time.Now()},
+		},
+	}
+
+	router := mux.NewRouter()
+	router.Use(RequireDisclaimers(log.NewNopLogger(), repo, []string{"terms", "privacy"}, "/customers/{customerID}/accounts"))
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	router.Methods("GET", "POST").Path("/customers/{customerID}/accounts").HandlerFunc(handler)
+	router.Methods("PUT").Path("/customers/{customerID}").HandlerFunc(handler)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := serve("POST", "/customers/foo/accounts")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "customer has not accepted disclaimers: terms")
+
+	// reads and other routes aren't checked
+	require.Equal(t, http.StatusOK, serve("GET", "/customers/foo/accounts").Code)
+	require.Equal(t, http.StatusOK, serve("PUT", "/customers/foo").Code)
+
+	repo.disclaimers[0].AcceptedAt = time.Now()
+	require.Equal(t, http.StatusOK, serve("POST", "/customers/foo/accounts").Code)
+
+	repo.err = errors.New("bad error")
+	require.Equal(t, http.StatusBadRequest, serve("POST", "/customers/foo/accounts").Code)
+}
@@ -5,17 +5,23 @@
 package documents
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/moov-io/base"
 	"github.com/moov-io/base/admin"
-	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/database"
 
+	customersdb "github.com/moov-io/customers/internal/database"
 	"github.com/moov-io/customers/pkg/client"
 	"github.com/moov-io/customers/pkg/route"
 
@@ -30,16 +36,18 @@ var (
 func AddDisclaimerRoutes(logger log.Logger, r *mux.Router, repo DisclaimerRepository) {
 	r.Methods("GET").Path("/customers/{customerID}/disclaimers").HandlerFunc(getCustomerDisclaimers(logger, repo))
 	r.Methods("POST").Path("/customers/{customerID}/disclaimers/{disclaimerID}").HandlerFunc(acceptDisclaimer(logger, repo))
+	r.Methods("GET").Path("/customers/{customerID}/disclaimers/{disclaimerID}/acceptance").HandlerFunc(getDisclaimerAcceptance(logger, repo))
 }
 
 func AddDisclaimerAdminRoutes(logger log.Logger, svc *admin.Server, disclaimerRepo DisclaimerRepository, docRepo DocumentRepository) {
 	svc.AddHandler("/customers/{customerID}/disclaimers", createDisclaimer(logger, disclaimerRepo, docRepo))
+	svc.AddHandler("/disclaimers/{disclaimerID}", updateDisclaimer(logger, disclaimerRepo))
 }
 
 func getDisclaimerID(w http.ResponseWriter, r *http.Request) string {
 	v, ok := mux.Vars(r)["disclaimerID"]
 	if !ok || v == "" {
-		moovhttp.Problem(w, errNoDisclaimerID)
+		route.Problem(w, errNoDisclaimerID)
 		return ""
 	}
 	return v
@@ -57,7 +65,7 @@ func getCustomerDisclaimers(logger log.Logger, repo DisclaimerRepository) http.H
 
 		disclaimers, err := repo.getCustomerDisclaimers(customerID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -76,14 +84,25 @@ func acceptDisclaimer(logger log.Logger, repo DisclaimerRepository) http.Handler
 			return
 		}
 
-		if err := repo.acceptDisclaimer(customerID, disclaimerID); err != nil {
-			moovhttp.Problem(w, err)
+		// Customers can accept the version they were shown, which must still be current
+		var version int32
+		if v := r.URL.Query().Get("version"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || n < 1 {
+				route.Problem(w, route.Validation(fmt.Errorf("invalid version %q", v)))
+				return
+			}
+			version = int32(n)
+		}
+
+		if err := repo.acceptDisclaimer(customerID, disclaimerID, version, readAcceptanceEvidence(r)); err != nil {
+			route.Problem(w, err)
 			return
 		}
 
 		disclaimer, err := repo.getCustomerDisclaimer(customerID, disclaimerID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -103,7 +122,7 @@ func createDisclaimer(logger log.Logger, disclaimerRepo DisclaimerRepository, do
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
 		if r.Method != "POST" {
-			moovhttp.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
 			return
 		}
 
@@ -114,23 +133,23 @@ func createDisclaimer(logger log.Logger, disclaimerRepo DisclaimerRepository, do
 
 		var req createDisclaimerRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
-		if err := documentExistsForCustomer(customerID, organization, req, docRepo); err != nil {
-			moovhttp.Problem(w, err)
+		if err := documentExistsForCustomer(r.Context(), customerID, organization, req, docRepo); err != nil {
+			route.Problem(w, err)
 			return
 		}
 
 		if req.Text == "" {
-			moovhttp.Problem(w, errors.New("empty disclaimer text"))
+			route.Problem(w, errors.New("empty disclaimer text"))
 			return
 		}
 
 		disclaimer, err := disclaimerRepo.insertDisclaimer(req.Text, req.DocumentID)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
@@ -139,9 +158,57 @@ func createDisclaimer(logger log.Logger, disclaimerRepo DisclaimerRepository, do
 	}
 }
 
-func documentExistsForCustomer(customerID string, organization string, req createDisclaimerRequest, docRepo DocumentRepository) error {
+type updateDisclaimerRequest struct {
+	Text string `json:"text"`
+}
+
+// updateDisclaimer replaces the text of a Disclaimer. Changing the text creates a new version which
+// Customers must accept again, the same text leaves the Disclaimer alone.
+func updateDisclaimer(logger log.Logger, repo DisclaimerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		if r.Method != "PUT" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		disclaimerID := getDisclaimerID(w, r)
+		if disclaimerID == "" {
+			return
+		}
+
+		var req updateDisclaimerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if req.Text == "" {
+			route.Problem(w, errors.New("empty disclaimer text"))
+			return
+		}
+
+		disclaimer, err := repo.updateDisclaimer(disclaimerID, req.Text)
+		if err != nil {
+			logger.LogErrorf("problem updating disclaimer=%s: %v", disclaimerID, err)
+			route.Problem(w, err)
+			return
+		}
+		if disclaimer == nil {
+			route.NotFound(w, r)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(disclaimer)
+	}
+}
+
+func documentExistsForCustomer(ctx context.Context, customerID string, organization string, req createDisclaimerRequest, docRepo DocumentRepository) error {
 	if req.DocumentID != "" {
-		docs, err := docRepo.getCustomerDocuments(customerID, organization)
+		docs, err := docRepo.getCustomerDocuments(ctx, customerID, organization)
 		if err != nil {
 			return err
 		}
@@ -150,7 +217,7 @@ func documentExistsForCustomer(customerID string, organization string, req creat
 				return nil
 			}
 		}
-		return errors.New("document not found")
+		return route.NotFoundError(errors.New("document not found"))
 	}
 	return nil
 }
@@ -158,8 +225,19 @@ func documentExistsForCustomer(customerID string, organization string, req creat
 type DisclaimerRepository interface {
 	getCustomerDisclaimer(customerID, disclaimerID string) (*client.Disclaimer, error)
 	getCustomerDisclaimers(customerID string) ([]*client.Disclaimer, error)
-	acceptDisclaimer(customerID, disclaimerID string) error
+	getUnacceptedDisclaimers(customerID string, disclaimerIDs []string) ([]string, error)
+	// acceptDisclaimer records the Customer accepting the current version of a Disclaimer. A non-zero
+	// version must match the current version.
+	acceptDisclaimer(customerID, disclaimerID string, version int32, evidence acceptanceEvidence) error
+	getDisclaimerAcceptance(customerID, disclaimerID string, version int32) (*DisclaimerAcceptance, error)
 	insertDisclaimer(text, documentID string) (*client.Disclaimer, error)
+	updateDisclaimer(disclaimerID, text string) (*client.Disclaimer, error)
+}
+
+// hashDisclaimer returns the hex encoded SHA-256 hash of a Disclaimer's text
+func hashDisclaimer(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
 }
 
 type sqlDisclaimerRepository struct {
@@ -179,8 +257,8 @@ func (r *sqlDisclaimerRepository) close() error {
 }
 
 func (r *sqlDisclaimerRepository) getCustomerDisclaimer(customerID, disclaimerID string) (*client.Disclaimer, error) {
-	query := `select d.disclaimer_id, d.text, d.document_id, da.accepted_at from disclaimers as d
-left outer join disclaimer_acceptances as da on d.disclaimer_id = da.disclaimer_id
+	query := `select d.disclaimer_id, d.text, d.document_id, d.version, da.accepted_at from disclaimers as d
+left outer join disclaimer_acceptances as da on d.disclaimer_id = da.disclaimer_id and da.version = d.version and da.customer_id = ?
 where d.deleted_at is null and d.disclaimer_id = ? limit 1;`
 	stmt, err := r.db.Prepare(query)
 	if err != nil {
@@ -191,7 +269,7 @@ where d.deleted_at is null and d.disclaimer_id = ? limit 1;`
 	var acceptedAt *time.Time
 	var d client.Disclaimer
 
-	if err := stmt.QueryRow(disclaimerID).Scan(&d.DisclaimerID, &d.Text, &d.DocumentID, &acceptedAt); err != nil {
+	if err := stmt.QueryRow(customerID, disclaimerID).Scan(&d.DisclaimerID, &d.Text, &d.DocumentID, &d.Version, &acceptedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -200,6 +278,7 @@ where d.deleted_at is null and d.disclaimer_id = ? limit 1;`
 	if acceptedAt != nil && !acceptedAt.IsZero() {
 		d.AcceptedAt = *acceptedAt
 	}
+	d.Hash = hashDisclaimer(d.Text)
 
 	return &d, nil
 }
@@ -230,57 +309,127 @@ func (r *sqlDisclaimerRepository) getCustomerDisclaimers(customerID string) ([]*
 	return out, rows.Err()
 }
 
-func (r *sqlDisclaimerRepository) acceptDisclaimer(customerID, disclaimerID string) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
+// getUnacceptedDisclaimers returns the IDs from disclaimerIDs the Customer hasn't accepted the current
+// version of. Deleted disclaimers are never returned.
+func (r *sqlDisclaimerRepository) getUnacceptedDisclaimers(customerID string, disclaimerIDs []string) ([]string, error) {
+	if len(disclaimerIDs) == 0 {
+		return nil, nil
 	}
 
-	query := `select disclaimer_id from disclaimers where disclaimer_id = ? and deleted_at is null limit 1;`
+	query := fmt.Sprintf(`select d.disclaimer_id from disclaimers as d
+left outer join disclaimer_acceptances as da on d.disclaimer_id = da.disclaimer_id and da.version = d.version and da.customer_id = ?
+where d.deleted_at is null and da.accepted_at is null and d.disclaimer_id in (?%s) order by d.created_at asc;`, strings.Repeat(",?", len(disclaimerIDs)-1))
 	stmt, err := r.db.Prepare(query)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return nil, fmt.Errorf("getUnacceptedDisclaimers: prepare: %v", err)
 	}
+	defer stmt.Close()
 
-	row := stmt.QueryRow(disclaimerID)
-	var discID string
-	if err := row.Scan(&discID); discID != disclaimerID || err != nil {
-		stmt.Close()
-		return fmt.Errorf("acceptDisclaimer: missing disclaimer: %v rollback=%v", err, tx.Rollback())
+	args := []interface{}{customerID}
+	for i := range disclaimerIDs {
+		args = append(args, disclaimerIDs[i])
 	}
-	stmt.Close()
-
-	// write the acceptance row now
-	query = `insert into disclaimer_acceptances (disclaimer_id, customer_id, accepted_at) values (?, ?, ?);`
-	stmt, err = tx.Prepare(query)
+	rows, err := stmt.Query(args...)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return nil, fmt.Errorf("getUnacceptedDisclaimers: query: %v", err)
 	}
-	defer stmt.Close()
+	defer rows.Close()
 
-	_, err = stmt.Exec(disclaimerID, customerID, time.Now())
-	if err != nil {
-		tx.Rollback()
-		return err
+	var out []string
+	for rows.Next() {
+		var disclaimerID string
+		if err := rows.Scan(&disclaimerID); err != nil {
+			return nil, fmt.Errorf("getUnacceptedDisclaimers: scan: %v", err)
+		}
+		out = append(out, disclaimerID)
 	}
-	return tx.Commit()
+	return out, rows.Err()
 }
 
-func (r *sqlDisclaimerRepository) insertDisclaimer(text, documentID string) (*client.Disclaimer, error) {
-	query := `insert into disclaimers (disclaimer_id, text, document_id, created_at) values (?, ?, ?, ?);`
-	stmt, err := r.db.Prepare(query)
-	if err != nil {
-		return nil, err
+// acceptDisclaimer records the Customer accepting the current version of a Disclaimer along with evidence
+// of the request. Accepting a version again keeps the original accepted_at and evidence.
+func (r *sqlDisclaimerRepository) acceptDisclaimer(customerID, disclaimerID string, version int32, evidence acceptanceEvidence) error {
+	err := customersdb.WithTransaction(r.db, func(tx *sql.Tx) error {
+		query := `select version from disclaimers where disclaimer_id = ? and deleted_at is null limit 1;`
+		var current int32
+		if err := tx.QueryRow(query, disclaimerID).Scan(&current); err != nil {
+			return fmt.Errorf("acceptDisclaimer: missing disclaimer: %v", err)
+		}
+		if version != 0 && version != current {
+			return route.Conflict(fmt.Errorf("version %d of disclaimer %s is not current, version %d is", version, disclaimerID, current))
+		}
+
+		// write the acceptance row now
+		query = `insert into disclaimer_acceptances (disclaimer_id, version, customer_id, accepted_at, ip_address, user_agent) values (?, ?, ?, ?, ?, ?);`
+		_, err := tx.Exec(query, disclaimerID, current, customerID, time.Now(), evidence.IPAddress, evidence.UserAgent)
+		return err
+	})
+	if err != nil && database.UniqueViolation(err) {
+		return nil // already accepted
 	}
-	defer stmt.Close()
+	return err
+}
 
+func (r *sqlDisclaimerRepository) insertDisclaimer(text, documentID string) (*client.Disclaimer, error) {
 	disc := &client.Disclaimer{
 		DisclaimerID: base.ID(),
 		Text:         text,
 		DocumentID:   documentID,
+		Version:      1,
+		Hash:         hashDisclaimer(text),
 	}
-	_, err = stmt.Exec(disc.DisclaimerID, disc.Text, disc.DocumentID, time.Now())
+	err := customersdb.WithTransaction(r.db, func(tx *sql.Tx) error {
+		now := time.Now()
+		query := `insert into disclaimers (disclaimer_id, text, document_id, version, created_at, last_modified) values (?, ?, ?, ?, ?, ?);`
+		if _, err := tx.Exec(query, disc.DisclaimerID, disc.Text, disc.DocumentID, disc.Version, now, now); err != nil {
+			return err
+		}
+		return insertDisclaimerVersion(tx, disc.DisclaimerID, disc.Version, disc.Text, now)
+	})
 	return disc, err
 }
+
+// updateDisclaimer saves text as a new version of the Disclaimer, unless it's the current text.
+// Nil is returned for missing or deleted Disclaimers.
+func (r *sqlDisclaimerRepository) updateDisclaimer(disclaimerID, text string) (*client.Disclaimer, error) {
+	var found bool
+	err := customersdb.WithTransaction(r.db, func(tx *sql.Tx) error {
+		query := `select text, version from disclaimers where disclaimer_id = ? and deleted_at is null limit 1;`
+		var current sql.NullString
+		var version int32
+		if err := tx.QueryRow(query, disclaimerID).Scan(&current, &version); err != nil {
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			return fmt.Errorf("updateDisclaimer: reading: %v", err)
+		}
+		found = true
+		if hashDisclaimer(current.String) == hashDisclaimer(text) {
+			return nil
+		}
+
+		now := time.Now()
+		query = `update disclaimers set text = ?, version = ?, last_modified = ? where disclaimer_id = ? and version = ?;`
+		res, err := tx.Exec(query, text, version+1, now, disclaimerID, version)
+		if err != nil {
+			return fmt.Errorf("updateDisclaimer: updating: %v", err)
+		}
+		if n, _ := res.RowsAffected(); n != 1 {
+			return route.Conflict(fmt.Errorf("disclaimer %s was changed concurrently", disclaimerID))
+		}
+		return insertDisclaimerVersion(tx, disclaimerID, version+1, text, now)
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return r.getCustomerDisclaimer("", disclaimerID)
+}
+
+// insertDisclaimerVersion keeps the text of each version so what a Customer accepted can be shown later
+func insertDisclaimerVersion(tx *sql.Tx, disclaimerID string, version int32, text string, createdAt time.Time) error {
+	query := `insert into disclaimer_versions (disclaimer_id, version, text, created_at) values (?, ?, ?, ?);`
+	if _, err := tx.Exec(query, disclaimerID, version, text, createdAt); err != nil {
+		return fmt.Errorf("saving disclaimer=%s version=%d: %v", disclaimerID, version, err)
+	}
+	return nil
+}
@@ -14,14 +14,17 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/moov-io/base"
 	"github.com/moov-io/base/admin"
 	"github.com/moov-io/base/database"
 	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
 
 	"github.com/gorilla/mux"
 	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
 )
 
 type testDisclaimerRepository struct {
@@ -46,10 +49,50 @@ func (r *testDisclaimerRepository) getCustomerDisclaimers(customerID string) ([]
 	return r.disclaimers, nil
 }
 
-func (r *testDisclaimerRepository) acceptDisclaimer(customerID, disclaimerID string) error {
+func (r *testDisclaimerRepository) getUnacceptedDisclaimers(customerID string, disclaimerIDs []string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var out []string
+	for _, id := range disclaimerIDs {
+		for i := range r.disclaimers {
+			if r.disclaimers[i].DisclaimerID == id && r.disclaimers[i].AcceptedAt.IsZero() {
+				out = append(out, id)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *testDisclaimerRepository) acceptDisclaimer(customerID, disclaimerID string, version int32, evidence acceptanceEvidence) error {
 	return r.err
 }
 
+func (r *testDisclaimerRepository) getDisclaimerAcceptance(customerID, disclaimerID string, version int32) (*DisclaimerAcceptance, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for i := range r.disclaimers {
+		if d := r.disclaimers[i]; d.DisclaimerID == disclaimerID && !d.AcceptedAt.IsZero() {
+			return &DisclaimerAcceptance{DisclaimerID: disclaimerID, CustomerID: customerID, Version: d.Version, Hash: d.Hash, AcceptedAt: d.AcceptedAt}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *testDisclaimerRepository) updateDisclaimer(disclaimerID, text string) (*client.Disclaimer, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for i := range r.disclaimers {
+		if r.disclaimers[i].DisclaimerID == disclaimerID {
+			r.disclaimers[i].Text = text
+			return r.disclaimers[i], nil
+		}
+	}
+	return nil, nil
+}
+
 func (r *testDisclaimerRepository) insertDisclaimer(text, documentID string) (*client.Disclaimer, error) {
 	if r.err != nil {
 		return nil, r.err
@@ -168,6 +211,12 @@ func TestDisclaimers__acceptDisclaimer(t *testing.T) {
 		t.Errorf("bogus HTTP status: %d", w.Code)
 	}
 
+	// versions must be positive numbers
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", fmt.Sprintf("/customers/adam/disclaimers/%s?version=zero", disclaimerID), nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
 	// set an error and verify
 	repo.err = errors.New("bad error")
 
@@ -209,14 +258,80 @@ func TestDisclaimersRepository(t *testing.T) {
 		}
 
 		// Accept the disclaimer
-		if err := repo.acceptDisclaimer(customerID, disc.DisclaimerID); err != nil {
+		if err := repo.acceptDisclaimer(customerID, disc.DisclaimerID, 0, acceptanceEvidence{}); err != nil {
 			t.Fatal(err)
 		}
 
 		// Verify a different disclaimer ID is rejected
-		if err := repo.acceptDisclaimer(customerID, base.ID()); err == nil {
+		if err := repo.acceptDisclaimer(customerID, base.ID(), 0, acceptanceEvidence{}); err == nil {
 			t.Error("expected error")
 		}
+
+		// Accepting again keeps the first acceptance
+		first, err := repo.getCustomerDisclaimer(customerID, disc.DisclaimerID)
+		require.NoError(t, err)
+		require.False(t, first.AcceptedAt.IsZero())
+		require.NoError(t, repo.acceptDisclaimer(customerID, disc.DisclaimerID, 0, acceptanceEvidence{}))
+		again, err := repo.getCustomerDisclaimer(customerID, disc.DisclaimerID)
+		require.NoError(t, err)
+		require.True(t, first.AcceptedAt.Equal(again.AcceptedAt))
+
+		// Other customers haven't accepted it
+		other, err := repo.getCustomerDisclaimer(base.ID(), disc.DisclaimerID)
+		require.NoError(t, err)
+		require.True(t, other.AcceptedAt.IsZero())
+
+		// Only unaccepted and non-deleted disclaimers are returned
+		pending, err := repo.insertDisclaimer("privacy policy", documentID)
+		require.NoError(t, err)
+		deleted, err := repo.insertDisclaimer("old terms", documentID)
+		require.NoError(t, err)
+		_, err = repo.db.Exec(`update disclaimers set deleted_at = ? where disclaimer_id = ?;`, time.Now(), deleted.DisclaimerID)
+		require.NoError(t, err)
+
+		unaccepted, err := repo.getUnacceptedDisclaimers(customerID, []string{disc.DisclaimerID, pending.DisclaimerID, deleted.DisclaimerID})
+		require.NoError(t, err)
+		require.Equal(t, []string{pending.DisclaimerID}, unaccepted)
+
+		// Deleted disclaimers can't be accepted
+		require.Error(t, repo.acceptDisclaimer(customerID, deleted.DisclaimerID, 0, acceptanceEvidence{}))
+
+		// Changing the text creates a new version which must be accepted again
+		same, err := repo.updateDisclaimer(disc.DisclaimerID, "terms and conditions")
+		require.NoError(t, err)
+		require.Equal(t, int32(1), same.Version)
+		require.Equal(t, disc.Hash, same.Hash)
+
+		updated, err := repo.updateDisclaimer(disc.DisclaimerID, "new terms and conditions")
+		require.NoError(t, err)
+		require.Equal(t, int32(2), updated.Version)
+		require.NotEqual(t, disc.Hash, updated.Hash)
+
+		unaccepted, err = repo.getUnacceptedDisclaimers(customerID, []string{disc.DisclaimerID})
+		require.NoError(t, err)
+		require.Equal(t, []string{disc.DisclaimerID}, unaccepted)
+
+		// accepting an old version is refused
+		err = repo.acceptDisclaimer(customerID, disc.DisclaimerID, 1, acceptanceEvidence{})
+		require.Error(t, err)
+		require.Equal(t, http.StatusConflict, route.Classify(err).Status)
+
+		require.NoError(t, repo.acceptDisclaimer(customerID, disc.DisclaimerID, 2, acceptanceEvidence{}))
+		unaccepted, err = repo.getUnacceptedDisclaimers(customerID, []string{disc.DisclaimerID})
+		require.NoError(t, err)
+		require.Empty(t, unaccepted)
+
+		// each acceptance records its version, and the text of each version is kept
+		var count int
+		require.NoError(t, repo.db.QueryRow(`select count(*) from disclaimer_acceptances where disclaimer_id = ? and customer_id = ?;`, disc.DisclaimerID, customerID).Scan(&count))
+		require.Equal(t, 2, count)
+		var text string
+		require.NoError(t, repo.db.QueryRow(`select text from disclaimer_versions where disclaimer_id = ? and version = 1;`, disc.DisclaimerID).Scan(&text))
+		require.Equal(t, "terms and conditions", text)
+
+		missing, err := repo.updateDisclaimer(base.ID(), "text")
+		require.NoError(t, err)
+		require.Nil(t, missing)
 	}
 
 	// SQLite tests
@@ -276,6 +391,45 @@ func TestDisclaimersAdmin__create(t *testing.T) {
 	}
 }
 
+func TestDisclaimersAdmin__update(t *testing.T) {
+	disclaimerID := base.ID()
+	repo := &testDisclaimerRepository{
+		disclaimers: []*client.Disclaimer{{DisclaimerID: disclaimerID, Text: "terms and conditions"}},
+	}
+
+	svc := admin.NewServer(":0")
+	defer svc.Shutdown()
+	AddDisclaimerAdminRoutes(log.NewNopLogger(), svc, repo, &testDocumentRepository{})
+	go svc.Listen()
+
+	update := func(method, disclaimerID, body string) *http.Response {
+		req, err := http.NewRequest(method, fmt.Sprintf("http://%s/disclaimers/%s", svc.BindAddr(), disclaimerID), strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := update("PUT", disclaimerID, `{"text": "new terms"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var disclaimer client.Disclaimer
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&disclaimer))
+	require.Equal(t, "new terms", disclaimer.Text)
+
+	resp = update("PUT", base.ID(), `{"text": "new terms"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = update("PUT", disclaimerID, `{"text": ""}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = update("POST", disclaimerID, `{"text": "new terms"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
 func TestDisclaimersAdmin__createErr(t *testing.T) {
 	disclaimerRepo := &testDisclaimerRepository{}
 	docRepo := &testDocumentRepository{}
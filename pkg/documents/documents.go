@@ -7,26 +7,33 @@ package documents
 import (
 	"bufio"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/moov-io/base"
+	"github.com/moov-io/base/admin"
 	moovhttp "github.com/moov-io/base/http"
 
 	"github.com/moov-io/customers/pkg/client"
 	"github.com/moov-io/customers/pkg/documents/storage"
 	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/tracing"
+	"github.com/moov-io/customers/pkg/webhooks"
 
 	"github.com/gorilla/mux"
 	"github.com/moov-io/base/log"
 	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
 	"gocloud.dev/secrets"
 )
 
@@ -40,44 +47,101 @@ func (l sizeLimit) String() string {
 	return fmt.Sprintf("%dMB", l>>20)
 }
 
-const (
-	maxDocumentSize sizeLimit = 20 << 20                    // 20MB
-	maxFormSize     sizeLimit = maxDocumentSize + (5 << 20) // restricts request body size to allow for the document plus a small buffer
+var (
+	maxDocumentSize sizeLimit = func() sizeLimit {
+		if v := os.Getenv("DOCUMENTS_MAX_SIZE_MB"); v != "" {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err == nil && n > 0 {
+				return sizeLimit(n << 20)
+			}
+		}
+		return 20 << 20 // default, 20MB
+	}()
+	maxFormSize sizeLimit = maxDocumentSize + (5 << 20) // restricts request body size to allow for the document plus a small buffer
 )
 
-func AddDocumentRoutes(logger log.Logger, r *mux.Router, repo DocumentRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc) {
+func AddDocumentRoutes(logger log.Logger, r *mux.Router, repo DocumentRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc, scanner *Scanner, notifier webhooks.Notifier) {
 	logger = logger.Set("package", log.String("documents"))
 
+	r.Methods("GET").Path("/customers/documents/expiring").HandlerFunc(listExpiringDocuments(logger, repo))
 	r.Methods("GET").Path("/customers/{customerID}/documents").HandlerFunc(getCustomerDocuments(logger, repo))
-	r.Methods("POST").Path("/customers/{customerID}/documents").HandlerFunc(uploadCustomerDocument(logger, repo, keeper, bucketFactory))
+	r.Methods("POST").Path("/customers/{customerID}/documents").HandlerFunc(uploadCustomerDocument(logger, repo, keeper, bucketFactory, scanner, notifier))
+	r.Methods("GET").Path("/customers/{customerID}/documents/archive").HandlerFunc(retrieveDocumentArchive(logger, repo, keeper, bucketFactory))
 	r.Methods("GET").Path("/customers/{customerID}/documents/{documentID}").HandlerFunc(retrieveRawDocument(logger, repo, keeper, bucketFactory))
+	r.Methods("GET").Path("/customers/{customerID}/documents/{documentID}/preview").HandlerFunc(retrieveDocumentPreview(logger, repo, keeper, bucketFactory))
 	r.Methods("DELETE").Path("/customers/{customerID}/documents/{documentID}").HandlerFunc(deleteCustomerDocument(logger, repo))
+	r.Methods("POST").Path("/customers/{customerID}/documents/{documentID}/restore").HandlerFunc(restoreCustomerDocument(logger, repo))
+	r.Methods("GET").Path("/customers/{customerID}/documents/{documentID}/metadata").HandlerFunc(getDocumentMetadata(logger, repo))
+	r.Methods("PUT").Path("/customers/{customerID}/documents/{documentID}/metadata").HandlerFunc(replaceDocumentMetadata(logger, repo))
+}
+
+// AddDocumentAdminRoutes registers the admin routes for reading Documents, including deleted ones
+func AddDocumentAdminRoutes(logger log.Logger, svc *admin.Server, repo DocumentRepository) {
+	logger = logger.Set("package", log.String("documents"))
+
+	svc.AddHandler("/customers/{customerID}/documents", listCustomerDocuments(logger, repo, true))
 }
 
 func getDocumentID(w http.ResponseWriter, r *http.Request) string {
 	v, ok := mux.Vars(r)["documentID"]
 	if !ok || v == "" {
-		moovhttp.Problem(w, errNoDocumentID)
+		route.Problem(w, errNoDocumentID)
 		return ""
 	}
 	return v
 }
 
+// nextCursorHeaderKey is set on Document listings when there are more Documents to read. Its value
+// is passed back as the cursor query parameter to read the next page.
+const nextCursorHeaderKey = "X-Next-Cursor"
+
 func getCustomerDocuments(logger log.Logger, repo DocumentRepository) http.HandlerFunc {
+	return listCustomerDocuments(logger, repo, false)
+}
+
+// listCustomerDocuments serves a page of the Customer's Documents. Deleted Documents are only
+// included on the admin route when includeDeleted=true is set.
+func listCustomerDocuments(logger log.Logger, repo DocumentRepository, admin bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if r.Method != "GET" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
 
 		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
 		if customerID == "" || organization == "" {
 			return
 		}
 
-		docs, err := repo.getCustomerDocuments(customerID, organization)
+		filters, err := readDocumentFilters(r, admin)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+
+		// read one extra Document to know if there's another page
+		count := filters.Count
+		filters.Count++
+		docs, err := repo.listCustomerDocuments(r.Context(), customerID, organization, filters)
 		if err != nil {
 			logger.Set("customerID", log.String(customerID)).LogErrorf("failed to get customer document: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
+			return
+		}
+		if len(docs) > count {
+			docs = docs[:count]
+			w.Header().Set(nextCursorHeaderKey, encodeCursor(docs[count-1].DocumentID))
+		}
+		quota, err := repo.getDocumentQuota(r.Context(), customerID)
+		if err != nil {
+			logger.Set("customerID", log.String(customerID)).LogErrorf("failed to get customer document quota: %v", err)
+			route.Problem(w, err)
 			return
 		}
+		quota.setHeaders(w)
 
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
@@ -85,6 +149,45 @@ func getCustomerDocuments(logger log.Logger, repo DocumentRepository) http.Handl
 	}
 }
 
+func readDocumentFilters(r *http.Request, admin bool) (documentFilters, error) {
+	var filters documentFilters
+
+	_, count, _, err := moovhttp.GetSkipAndCount(r)
+	if err != nil {
+		return filters, err
+	}
+	filters.Count = count
+
+	q := r.URL.Query()
+	if v := q.Get("type"); v != "" {
+		if filters.Type, err = readDocumentType(v); err != nil {
+			return filters, err
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		if filters.After, err = decodeCursor(v); err != nil {
+			return filters, err
+		}
+	}
+	if admin {
+		filters.IncludeDeleted = strings.EqualFold(q.Get("includeDeleted"), "true")
+	}
+	return filters, nil
+}
+
+// encodeCursor hides the documentID a page ends on so clients treat cursors as opaque
+func encodeCursor(documentID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(documentID))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(bs) == 0 {
+		return "", fmt.Errorf("invalid cursor: %s", cursor)
+	}
+	return string(bs), nil
+}
+
 func readDocumentType(v string) (string, error) {
 	orig := v
 	v = strings.ToLower(strings.TrimSpace(v))
@@ -93,13 +196,111 @@ func readDocumentType(v string) (string, error) {
 		return v, nil
 	case "utilitybill", "bankstatement":
 		return v, nil
+	case documentTypeW9, documentTypeW8BEN:
+		return v, nil
 	}
 	return "", fmt.Errorf("unknown Document type: %s", orig)
 }
 
-func uploadCustomerDocument(logger log.Logger, repo DocumentRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc) http.HandlerFunc {
+// readExpiresAt parses the optional expiration of an uploaded Document as either a date or an RFC 3339 timestamp
+func readExpiresAt(v string) (*time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid expiresAt: %s", v)
+}
+
+const (
+	defaultExpiringDays = 30
+	maxExpiringDays     = 365
+)
+
+// listExpiringDocuments serves the Customers with Documents which expire within the days query
+// parameter, including Documents which have already expired, so they can be asked for new ones.
+func listExpiringDocuments(logger log.Logger, repo DocumentRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		days := defaultExpiringDays
+		if v := r.URL.Query().Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > maxExpiringDays {
+				route.Problem(w, route.Validation(fmt.Errorf("days must be between 0 and %d", maxExpiringDays)))
+				return
+			}
+			days = n
+		}
+
+		expiring, err := repo.expiringDocuments(r.Context(), organization, time.Now().Add(time.Duration(days)*24*time.Hour))
+		if err != nil {
+			logger.LogErrorf("failed to list expiring documents: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(expiring)
+	}
+}
+
+// allowedContentTypes are the file formats accepted as Documents
+var allowedContentTypes = []string{
+	"application/pdf",
+	"image/jpeg",
+	"image/png",
+}
+
+// checkContentType returns the Document's content type after verifying the type sniffed from the
+// uploaded bytes is allowed and matches the content type declared on the multipart file, if any.
+func checkContentType(sniffed, declared string) (string, error) {
+	sniffed = mediaType(sniffed)
+	allowed := false
+	for i := range allowedContentTypes {
+		if sniffed == allowedContentTypes[i] {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("unsupported Document content type %s, expected one of %s", sniffed, strings.Join(allowedContentTypes, ", "))
+	}
+
+	// multipart writers often default to application/octet-stream when the file type isn't known
+	declared = mediaType(declared)
+	if declared != "" && declared != "application/octet-stream" && declared != sniffed {
+		return "", fmt.Errorf("declared content type %s does not match the uploaded %s file", declared, sniffed)
+	}
+	return sniffed, nil
+}
+
+func mediaType(v string) string {
+	if idx := strings.Index(v, ";"); idx >= 0 {
+		v = v[:idx]
+	}
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "image/jpg" {
+		return "image/jpeg"
+	}
+	return v
+}
+
+func uploadCustomerDocument(logger log.Logger, repo DocumentRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc, scanner *Scanner, notifier webhooks.Notifier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 
 		customerID := route.GetCustomerID(w, r)
 		if customerID == "" {
@@ -110,7 +311,12 @@ func uploadCustomerDocument(logger log.Logger, repo DocumentRepository, keeper *
 
 		documentType, err := readDocumentType(r.URL.Query().Get("type"))
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
+			return
+		}
+		expiresAt, err := readExpiresAt(r.URL.Query().Get("expiresAt"))
+		if err != nil {
+			route.Problem(w, err)
 			return
 		}
 
@@ -120,18 +326,18 @@ func uploadCustomerDocument(logger log.Logger, repo DocumentRepository, keeper *
 		if err != nil {
 			if strings.Contains(err.Error(), "request body too large") {
 				logger.LogErrorf("max form size exceeded: %v", err)
-				moovhttp.Problem(w, fmt.Errorf("request body exceeds maximum size of %s", maxFormSize))
+				route.Problem(w, fmt.Errorf("request body exceeds maximum size of %s", maxFormSize))
 				return
 			}
 			logger.LogErrorf("error reading form file: %v", err)
-			moovhttp.Problem(w, fmt.Errorf("expected multipart upload with key of 'file' error=%v", err))
+			route.Problem(w, fmt.Errorf("expected multipart upload with key of 'file' error=%v", err))
 			return
 		}
 		defer file.Close()
 
 		if fileHeader.Size > int64(maxDocumentSize) {
-			logger.LogErrorf("failed to peek: %v", err)
-			moovhttp.Problem(w, fmt.Errorf("file exceeds maximum size of %s", maxDocumentSize))
+			logger.LogErrorf("file size of %d bytes exceeds %s", fileHeader.Size, maxDocumentSize)
+			route.Problem(w, fmt.Errorf("file exceeds maximum size of %s", maxDocumentSize))
 			return
 		}
 
@@ -139,72 +345,102 @@ func uploadCustomerDocument(logger log.Logger, repo DocumentRepository, keeper *
 		sniff, err := fileReader.Peek(512)
 		if err != nil && err != io.EOF {
 			logger.LogErrorf("peek failed: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
+			return
+		}
+		contentType, err := checkContentType(http.DetectContentType(sniff), fileHeader.Header.Get("Content-Type"))
+		if err != nil {
+			logger.LogErrorf("rejecting upload: %v", err)
+			route.Problem(w, err)
 			return
 		}
-		contentType := http.DetectContentType(sniff)
 
 		// Grab our cloud bucket before writing into our database
 		bucket, err := bucketFactory()
 		if err != nil {
 			logger.LogErrorf("failed to create bucket: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		defer bucket.Close()
 
+		fBytes, err := ioutil.ReadAll(fileReader)
+		if err != nil {
+			logger.LogErrorf("read failed: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
 		doc := &client.Document{
 			DocumentID:  base.ID(),
 			Type:        documentType,
 			ContentType: contentType,
 			UploadedAt:  time.Now(),
+			ExpiresAt:   expiresAt,
+			Expired:     expired(expiresAt),
 		}
-		if err := repo.writeCustomerDocument(customerID, doc); err != nil {
-			logger.LogErrorf("failed to write customer document: %v", err)
-			moovhttp.Problem(w, err)
+		organization := r.Header.Get("X-Organization")
+		if err := storeDocument(r.Context(), logger, repo, scanner, keeper, bucket, customerID, organization, doc, fBytes); err != nil {
+			route.Problem(w, err)
 			return
 		}
-		logger.Logf("uploading document=%s (content-type: %s)", doc.DocumentID, contentType)
+		webhooks.NotifyDocument(notifier, webhooks.DocumentUploaded, customerID, organization, doc.DocumentID, doc.Type)
 
-		// Write our document from the request body
-		ctx, cancelFn := context.WithTimeout(context.TODO(), 60*time.Second)
-		defer cancelFn()
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(doc)
+	}
+}
 
-		fBytes, err := ioutil.ReadAll(fileReader)
-		if err != nil {
-			logger.LogErrorf("read failed: %v", err)
-			moovhttp.Problem(w, err)
-			return
-		}
-		encryptedDoc, err := keeper.Encrypt(ctx, fBytes)
-		if err != nil {
-			logger.LogErrorf("failed to encrypt document: %v", err)
-			moovhttp.Problem(w, fmt.Errorf("file upload error - %v", err))
-			return
-		}
+// storeDocument scans data, then records doc for the Customer and encrypts and writes data, along with
+// its preview, into the bucket. Nothing is stored for Customers at their Document limit or Documents
+// which fail the scan.
+func storeDocument(ctx context.Context, logger log.Logger, repo DocumentRepository, scanner *Scanner, keeper *secrets.Keeper, bucket *blob.Bucket, customerID, organization string, doc *client.Document, data []byte) error {
+	if err := checkDocumentQuota(ctx, repo, customerID); err != nil {
+		logger.LogErrorf("rejecting upload: %v", err)
+		return err
+	}
+	if err := scanner.check(ctx, customerID, organization, doc, data); err != nil {
+		return err
+	}
 
-		documentKey := makeDocumentKey(customerID, doc.DocumentID)
-		logger.Logf("writing %s", documentKey)
+	_, span := tracing.StartSpan(ctx, "UploadDocument", customerID)
+	err := repo.writeCustomerDocument(ctx, customerID, doc)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		logger.LogErrorf("failed to write customer document: %v", err)
+		return err
+	}
+	logger.Logf("uploading document=%s (content-type: %s)", doc.DocumentID, doc.ContentType)
 
-		err = bucket.WriteAll(ctx, documentKey, encryptedDoc, &blob.WriterOptions{
-			ContentDisposition: "inline",
-			ContentType:        contentType,
-		})
-		if err != nil {
-			logger.LogErrorf("problem uploading document: %v", err)
-			moovhttp.Problem(w, err)
-			return
-		}
+	ctx, cancelFn := context.WithTimeout(context.TODO(), 60*time.Second)
+	defer cancelFn()
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(doc)
+	encryptedDoc, err := keeper.Encrypt(ctx, data)
+	if err != nil {
+		logger.LogErrorf("failed to encrypt document: %v", err)
+		return fmt.Errorf("file upload error - %v", err)
 	}
+
+	documentKey := makeDocumentKey(customerID, doc.DocumentID)
+	logger.Logf("writing %s", documentKey)
+
+	err = bucket.WriteAll(ctx, documentKey, encryptedDoc, &blob.WriterOptions{
+		ContentDisposition: "inline",
+		ContentType:        doc.ContentType,
+	})
+	if err != nil {
+		logger.LogErrorf("problem uploading document: %v", err)
+		return err
+	}
+	writePreview(ctx, logger, keeper, bucket, customerID, doc.DocumentID, doc.ContentType, data)
+	return nil
 }
 
 func retrieveRawDocument(logger log.Logger, repo DocumentRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 
 		customerID, documentID := route.GetCustomerID(w, r), getDocumentID(w, r)
 		if customerID == "" || documentID == "" {
@@ -218,17 +454,17 @@ func retrieveRawDocument(logger log.Logger, repo DocumentRepository, keeper *sec
 		logger = logger.Set("customerID", log.String(customerID)).Set("documentID", log.String(documentID))
 
 		// reject the request if the document is deleted
-		if exists, err := repo.exists(customerID, documentID, organization); !exists || err != nil {
+		if exists, err := repo.exists(r.Context(), customerID, documentID, organization); !exists || err != nil {
 			if err != nil {
 				logger.LogErrorf("failed to check document existence: %v", err)
 			}
-			http.NotFound(w, r)
+			route.NotFound(w, r)
 			return
 		}
 
 		bucket, err := bucketFactory()
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		defer bucket.Close()
@@ -239,7 +475,7 @@ func retrieveRawDocument(logger log.Logger, repo DocumentRepository, keeper *sec
 		documentKey := makeDocumentKey(customerID, documentID)
 		rdr, err := bucket.NewReader(ctx, documentKey, nil)
 		if err != nil {
-			moovhttp.Problem(w, fmt.Errorf("read documentID=%s: %v", documentKey, err))
+			route.Problem(w, fmt.Errorf("read documentID=%s: %v", documentKey, err))
 			return
 		}
 		defer rdr.Close()
@@ -247,21 +483,21 @@ func retrieveRawDocument(logger log.Logger, repo DocumentRepository, keeper *sec
 		encryptedDoc, err := ioutil.ReadAll(rdr)
 		if err != nil {
 			logger.LogErrorf("failed reading document from storage bucket: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		doc, err := keeper.Decrypt(ctx, encryptedDoc)
 		if err != nil {
 			logger.LogErrorf("failed to decrypt document: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		w.Header().Set("Content-Type", rdr.ContentType())
 		w.WriteHeader(http.StatusOK)
 		if n, err := w.Write(doc); n == 0 || err != nil {
-			moovhttp.Problem(w, fmt.Errorf("failed writing documentID=%s (bytes read: %d): %v", documentKey, n, err))
+			route.Problem(w, fmt.Errorf("failed writing documentID=%s (bytes read: %d): %v", documentKey, n, err))
 			return
 		}
 	}
@@ -270,6 +506,7 @@ func retrieveRawDocument(logger log.Logger, repo DocumentRepository, keeper *sec
 func deleteCustomerDocument(logger log.Logger, repo DocumentRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 
 		customerID, documentID := route.GetCustomerID(w, r), getDocumentID(w, r)
 		if customerID == "" || documentID == "" {
@@ -278,10 +515,10 @@ func deleteCustomerDocument(logger log.Logger, repo DocumentRepository) http.Han
 
 		logger = logger.Set("customerID", log.String(customerID)).Set("documentID", log.String(documentID))
 
-		err := repo.deleteCustomerDocument(customerID, documentID)
+		err := repo.deleteCustomerDocument(r.Context(), customerID, documentID)
 		if err != nil {
 			logger.LogErrorf("failed to delete document: %v", err)
-			moovhttp.Problem(w, fmt.Errorf("failed to %v", err))
+			route.Problem(w, fmt.Errorf("failed to %v", err))
 			return
 		}
 
@@ -291,6 +528,121 @@ func deleteCustomerDocument(logger log.Logger, repo DocumentRepository) http.Han
 	}
 }
 
+// restoreCustomerDocument undoes deleting a Document, which is possible until the RetentionSweeper
+// purges its blob.
+func restoreCustomerDocument(logger log.Logger, repo DocumentRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, documentID := route.GetCustomerID(w, r), getDocumentID(w, r)
+		if customerID == "" || documentID == "" {
+			return
+		}
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID)).Set("documentID", log.String(documentID))
+
+		restored, err := repo.restoreCustomerDocument(r.Context(), customerID, documentID, organization)
+		if err != nil {
+			logger.LogErrorf("failed to restore document: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		if !restored {
+			route.NotFound(w, r)
+			return
+		}
+
+		logger.Log("restored document")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
 func makeDocumentKey(customerID, documentID string) string {
 	return path.Join("customers", customerID, "documents", documentID)
 }
+
+// DeleteCustomerBlobs removes every stored document of a Customer from the storage bucket, including
+// soft-deleted documents, previews, partial uploads and their avatar, and returns how many documents were removed.
+func DeleteCustomerBlobs(ctx context.Context, bucketFactory storage.BucketFunc, customerID string) (int, error) {
+	bucket, err := bucketFactory()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create bucket: %v", err)
+	}
+	defer bucket.Close()
+
+	deleted := 0
+	iter := bucket.List(&blob.ListOptions{
+		Prefix: path.Join("customers", customerID, "documents") + "/",
+	})
+	for {
+		obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("listing documents for customer=%s: %v", customerID, err)
+		}
+		if err := bucket.Delete(ctx, obj.Key); err != nil {
+			return deleted, fmt.Errorf("deleting document %s: %v", obj.Key, err)
+		}
+		if !strings.HasSuffix(obj.Key, previewSuffix) {
+			deleted++
+		}
+	}
+	uploads := bucket.List(&blob.ListOptions{
+		Prefix: path.Join("customers", customerID, "uploads") + "/",
+	})
+	for {
+		obj, err := uploads.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("listing uploads for customer=%s: %v", customerID, err)
+		}
+		if err := bucket.Delete(ctx, obj.Key); err != nil {
+			return deleted, fmt.Errorf("deleting upload chunk %s: %v", obj.Key, err)
+		}
+	}
+	if err := bucket.Delete(ctx, makeAvatarKey(customerID)); err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+		return deleted, fmt.Errorf("deleting avatar for customer=%s: %v", customerID, err)
+	}
+	return deleted, nil
+}
+
+// CopyCustomerBlobs copies every stored document of a Customer, including previews, under another
+// Customer and returns how many documents were copied. The originals are left in place.
+func CopyCustomerBlobs(ctx context.Context, bucketFactory storage.BucketFunc, fromCustomerID, toCustomerID string) (int, error) {
+	bucket, err := bucketFactory()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create bucket: %v", err)
+	}
+	defer bucket.Close()
+
+	prefix := path.Join("customers", fromCustomerID, "documents") + "/"
+	copied := 0
+	iter := bucket.List(&blob.ListOptions{Prefix: prefix})
+	for {
+		obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return copied, fmt.Errorf("listing documents for customer=%s: %v", fromCustomerID, err)
+		}
+		dst := path.Join("customers", toCustomerID, "documents", strings.TrimPrefix(obj.Key, prefix))
+		if err := bucket.Copy(ctx, dst, obj.Key, nil); err != nil {
+			return copied, fmt.Errorf("copying document %s: %v", obj.Key, err)
+		}
+		if !strings.HasSuffix(obj.Key, previewSuffix) {
+			copied++
+		}
+	}
+	return copied, nil
+}
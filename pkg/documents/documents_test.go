@@ -6,6 +6,7 @@ package documents
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,9 +15,11 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -38,32 +41,147 @@ type testDocumentRepository struct {
 	err       error
 	docExists bool
 	written   *client.Document
+	quota     *documentQuota
 }
 
-func (r *testDocumentRepository) exists(customerID string, documentID string, organization string) (bool, error) {
+func (r *testDocumentRepository) exists(ctx context.Context, customerID string, documentID string, organization string) (bool, error) {
 	if r.docExists {
 		return true, nil
 	}
 	return false, r.err
 }
 
-func (r *testDocumentRepository) getCustomerDocuments(customerID string, organization string) ([]*client.Document, error) {
+func (r *testDocumentRepository) getDocumentType(ctx context.Context, customerID string, documentID string, organization string) (string, error) {
+	if r.docExists {
+		return "driverslicense", nil
+	}
+	return "", r.err
+}
+
+func (r *testDocumentRepository) getCustomerDocuments(ctx context.Context, customerID string, organization string) ([]*client.Document, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.documents, nil
+}
+
+func (r *testDocumentRepository) listCustomerDocuments(ctx context.Context, customerID string, organization string, filters documentFilters) ([]*client.Document, error) {
 	if r.err != nil {
 		return nil, r.err
 	}
+	if len(r.documents) > filters.Count {
+		return r.documents[:filters.Count], nil
+	}
 	return r.documents, nil
 }
 
-func (r *testDocumentRepository) writeCustomerDocument(customerID string, doc *client.Document) error {
+func (r *testDocumentRepository) getDocumentQuota(ctx context.Context, customerID string) (*documentQuota, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.quota != nil {
+		return r.quota, nil
+	}
+	return &documentQuota{Count: len(r.documents), Limit: maxDocumentsPerCustomer}, nil
+}
+
+func (r *testDocumentRepository) writeCustomerDocument(ctx context.Context, customerID string, doc *client.Document) error {
 	r.written = doc
 	return r.err
 }
 
-func (r *testDocumentRepository) deleteCustomerDocument(customerID string, documentID string) error {
+func (r *testDocumentRepository) deleteCustomerDocument(ctx context.Context, customerID string, documentID string) error {
 	r.written = nil
 	return r.err
 }
 
+func (r *testDocumentRepository) restoreCustomerDocument(ctx context.Context, customerID string, documentID string, organization string) (bool, error) {
+	return r.docExists, r.err
+}
+
+func (r *testDocumentRepository) expiredDocuments(ctx context.Context, deletedBefore time.Time, limit int) ([]expiredDocument, error) {
+	return nil, r.err
+}
+
+func (r *testDocumentRepository) markDocumentPurged(ctx context.Context, documentID string, purgedAt time.Time) error {
+	return r.err
+}
+
+func (r *testDocumentRepository) expiringDocuments(ctx context.Context, organization string, expiresBefore time.Time) ([]*client.ExpiringDocuments, error) {
+	return nil, r.err
+}
+
+func (r *testDocumentRepository) unnotifiedExpiringDocuments(ctx context.Context, expiresBefore time.Time, limit int) ([]expiringDocument, error) {
+	return nil, r.err
+}
+
+func (r *testDocumentRepository) markExpiryNotified(ctx context.Context, documentID string, notifiedAt time.Time) error {
+	return r.err
+}
+
+func (r *testDocumentRepository) getDocumentMetadata(ctx context.Context, documentID string) (*client.DocumentMetadata, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &client.DocumentMetadata{Metadata: make(map[string]string)}, nil
+}
+
+func (r *testDocumentRepository) replaceDocumentMetadata(ctx context.Context, documentID string, metadata map[string]string) error {
+	return r.err
+}
+
+func TestDocuments__listCustomerDocumentsCursor(t *testing.T) {
+	repo := &testDocumentRepository{}
+	for i := 0; i < 3; i++ {
+		repo.documents = append(repo.documents, &client.Document{DocumentID: fmt.Sprintf("doc%d", i), Type: "passport"})
+	}
+	router := mux.NewRouter()
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.TestBucket, nil, nil)
+
+	list := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/customers/foo/documents"+query, nil)
+		req.Header.Set("x-organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := list("?count=2")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var docs []client.Document
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&docs))
+	require.Len(t, docs, 2)
+
+	cursor := w.Header().Get(nextCursorHeaderKey)
+	after, err := decodeCursor(cursor)
+	require.NoError(t, err)
+	require.Equal(t, "doc1", after)
+
+	// the last page has no cursor
+	w = list("?count=5")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get(nextCursorHeaderKey))
+
+	w = list("?cursor=%25%25")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = list("?type=other")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDocuments__readDocumentFilters(t *testing.T) {
+	req := httptest.NewRequest("GET", "/customers/foo/documents?type=Passport&count=5&includeDeleted=true&cursor="+encodeCursor("doc1"), nil)
+
+	filters, err := readDocumentFilters(req, false)
+	require.NoError(t, err)
+	require.Equal(t, documentFilters{Type: "passport", After: "doc1", Count: 5}, filters)
+
+	// only admins can read deleted Documents
+	filters, err = readDocumentFilters(req, true)
+	require.NoError(t, err)
+	require.True(t, filters.IncludeDeleted)
+}
+
 func TestDocuments__getDocumentID(t *testing.T) {
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/ping", nil)
@@ -84,7 +202,7 @@ func TestDocuments__getCustomerDocuments(t *testing.T) {
 	req.Header.Set("x-organization", "test")
 
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.TestBucket)
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.TestBucket, nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -139,7 +257,7 @@ func TestDocumentsUploadAndRetrieval(t *testing.T) {
 	req.Header.Set("X-organization", "test")
 
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.NewTestBucket(t))
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.NewTestBucket(t), nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -157,6 +275,9 @@ func TestDocumentsUploadAndRetrieval(t *testing.T) {
 	if doc.ContentType != "image/jpeg" {
 		t.Errorf("unknown content type: %s", doc.ContentType)
 	}
+	if doc.ExpiresAt != nil || repo.written.ExpiresAt != nil {
+		t.Errorf("unexpected expiresAt: %v", doc.ExpiresAt)
+	}
 
 	// Test the HTTP retrieval route
 	w = httptest.NewRecorder()
@@ -176,7 +297,7 @@ func TestDocumentsUpload_fileTooLarge(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, secrets.TestKeeper(t), storage.TestBucket)
+	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, secrets.TestKeeper(t), storage.TestBucket, nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -196,7 +317,7 @@ func TestDocumentsUpload_fileSmallerThanPeek(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, secrets.TestKeeper(t), storage.TestBucket)
+	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, secrets.TestKeeper(t), storage.TestBucket, nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -210,7 +331,7 @@ func TestDocumentsUpload_formTooLarge(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, secrets.TestKeeper(t), storage.TestBucket)
+	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, secrets.TestKeeper(t), storage.TestBucket, nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -229,7 +350,7 @@ func TestDocumentsUpload_missingFileKey(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, secrets.TestKeeper(t), storage.TestBucket)
+	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, secrets.TestKeeper(t), storage.TestBucket, nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -249,7 +370,7 @@ func TestDocumentsUpload_repoErr(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.TestBucket)
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.TestBucket, nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -270,7 +391,7 @@ func TestDocumentsUpload_keeperErr(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, keeper, storage.TestBucket)
+	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, keeper, storage.TestBucket, nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -293,7 +414,7 @@ func TestDocumentsUpload_BucketErr(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, keeper, bucketFunc)
+	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, keeper, bucketFunc, nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -341,7 +462,10 @@ func multipartFileOfSize(t *testing.T, fileKey string, size int64) *http.Request
 	if err != nil {
 		t.Fatal(err)
 	}
-	if _, err := part.Write(make([]byte, size)); err != nil {
+	// start the file like a PDF so the upload sniffs as an allowed content type
+	data := make([]byte, size)
+	copy(data, "%PDF-1.4\n")
+	if _, err := part.Write(data); err != nil {
 		t.Fatal(err)
 	}
 	if err := mp.Close(); err != nil {
@@ -363,7 +487,7 @@ func TestDocuments__retrieveError(t *testing.T) {
 	}
 
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.TestBucket)
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.TestBucket, nil, nil)
 
 	customerID, documentID := base.ID(), base.ID()
 
@@ -388,7 +512,7 @@ func TestDocumentsRetrieve_bucketErr(t *testing.T) {
 	req.Header.Set("x-request-id", "test")
 	req.Header.Set("X-organization", "test")
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, repo, keeper, bucketFunc)
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, keeper, bucketFunc, nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -405,7 +529,7 @@ func TestDocuments__delete(t *testing.T) {
 	repo := &sqlDocumentRepository{db.DB, log.NewNopLogger()}
 
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.TestBucket)
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.TestBucket, nil, nil)
 
 	customerID := base.ID()
 	// create document
@@ -415,7 +539,7 @@ func TestDocuments__delete(t *testing.T) {
 		ContentType: "image/png",
 		UploadedAt:  time.Now(),
 	}
-	err := repo.writeCustomerDocument(customerID, doc)
+	err := repo.writeCustomerDocument(context.Background(), customerID, doc)
 	require.NoError(t, err)
 
 	w := httptest.NewRecorder()
@@ -444,7 +568,7 @@ func TestDocuments__uploadCustomerDocument(t *testing.T) {
 	req.URL = u // replace query params with invalid values
 
 	router := mux.NewRouter()
-	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.TestBucket)
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.TestBucket, nil, nil)
 	router.ServeHTTP(w, req)
 	w.Flush()
 
@@ -453,6 +577,106 @@ func TestDocuments__uploadCustomerDocument(t *testing.T) {
 	}
 }
 
+func TestDocuments__checkContentType(t *testing.T) {
+	contentType, err := checkContentType("image/jpeg", "")
+	require.NoError(t, err)
+	require.Equal(t, "image/jpeg", contentType)
+
+	contentType, err = checkContentType("application/pdf", "application/octet-stream")
+	require.NoError(t, err)
+	require.Equal(t, "application/pdf", contentType)
+
+	contentType, err = checkContentType("image/jpeg", "image/jpg")
+	require.NoError(t, err)
+	require.Equal(t, "image/jpeg", contentType)
+
+	_, err = checkContentType("text/plain; charset=utf-8", "text/plain")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported Document content type text/plain")
+
+	_, err = checkContentType("image/png", "application/pdf")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "does not match")
+}
+
+func TestDocumentsUpload_contentType(t *testing.T) {
+	upload := func(contents []byte, declared string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mp := multipart.NewWriter(&body)
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="file"; filename="upload"`)
+		header.Set("Content-Type", declared)
+		part, err := mp.CreatePart(header)
+		require.NoError(t, err)
+		part.Write(contents)
+		require.NoError(t, mp.Close())
+
+		req := httptest.NewRequest("POST", "/customers/foo/documents?type=passport", &body)
+		req.Header.Set("Content-Type", mp.FormDataContentType())
+		req.Header.Set("X-organization", "test")
+
+		w := httptest.NewRecorder()
+		router := mux.NewRouter()
+		AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, secrets.TestKeeper(t), storage.TestBucket, nil, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A" + strings.Repeat("\x00", 32))
+	w := upload(png, "image/png")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// mislabeled file
+	w = upload(png, "application/pdf")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// executables aren't allowed
+	w = upload([]byte("MZ\x90\x00\x03\x00\x00\x00"), "image/png")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "unsupported Document content type")
+}
+
+func TestDocumentsUpload_expiresAt(t *testing.T) {
+	repo := &testDocumentRepository{}
+	router := mux.NewRouter()
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.NewTestBucket(t), nil, nil)
+
+	upload := func(expiresAt string) *httptest.ResponseRecorder {
+		req := multipartRequest(t)
+		req.URL.RawQuery += "&expiresAt=" + url.QueryEscape(expiresAt)
+		req.Header.Set("X-Organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := upload("2000-01-01")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC), *repo.written.ExpiresAt)
+
+	var doc client.Document
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&doc))
+	require.True(t, doc.Expired)
+
+	require.Equal(t, http.StatusBadRequest, upload("tomorrow").Code)
+}
+
+func TestDocumentsUpload_notifies(t *testing.T) {
+	notifier := &testDocumentNotifier{}
+	router := mux.NewRouter()
+	AddDocumentRoutes(log.NewNopLogger(), router, &testDocumentRepository{}, secrets.TestKeeper(t), storage.NewTestBucket(t), nil, notifier)
+
+	req := multipartRequest(t)
+	req.Header.Set("X-Organization", "test")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var doc client.Document
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&doc))
+	require.Equal(t, []string{doc.DocumentID}, notifier.documentIDs)
+}
+
 func TestDocuments__makeDocumentKey(t *testing.T) {
 	key := makeDocumentKey("a", "b")
 
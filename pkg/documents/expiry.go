@@ -0,0 +1,115 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+var (
+	documentExpiryAlerts = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "document_expiry_alerts",
+		Help: "Counter of alerts sent for Documents about to expire by their type",
+	}, []string{"type"})
+)
+
+// ExpiryConfig holds the settings for alerting on Documents about to expire
+type ExpiryConfig struct {
+	// Window is how long before a Document expires an alert is sent.
+	// No alerts are sent when Window is zero.
+	Window time.Duration
+
+	// Interval is how often expiring Documents are checked
+	Interval time.Duration
+
+	// BatchSize is how many Documents are read from the database at once
+	BatchSize int
+}
+
+// ExpiryAlerter sends a webhooks.DocumentExpiring event for each Document which expires within the
+// configured window, so the Customer can be asked to upload a new one. Each Document is alerted on once.
+type ExpiryAlerter struct {
+	logger   log.Logger
+	repo     DocumentRepository
+	notifier webhooks.Notifier
+	cfg      ExpiryConfig
+}
+
+func NewExpiryAlerter(logger log.Logger, repo DocumentRepository, notifier webhooks.Notifier, cfg ExpiryConfig) *ExpiryAlerter {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &ExpiryAlerter{
+		logger:   logger.Set("package", log.String("documents")),
+		repo:     repo,
+		notifier: notifier,
+		cfg:      cfg,
+	}
+}
+
+// Start alerts on expiring Documents every interval until ctx is cancelled. It returns right away
+// when no window or notifier is set.
+func (a *ExpiryAlerter) Start(ctx context.Context) {
+	if a.cfg.Window <= 0 || a.notifier == nil {
+		return
+	}
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := a.Alert(ctx, time.Now()); err != nil {
+			a.logger.LogErrorf("problem alerting on expiring documents: %v", err)
+		} else if n > 0 {
+			a.logger.Logf("alerted on %d expiring documents", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Alert notifies for every Document expiring before now plus the window which hasn't been alerted
+// on yet and returns how many were sent
+func (a *ExpiryAlerter) Alert(ctx context.Context, now time.Time) (int, error) {
+	if a.cfg.Window <= 0 || a.notifier == nil {
+		return 0, nil
+	}
+	alerted := 0
+	for {
+		docs, err := a.repo.unnotifiedExpiringDocuments(ctx, now.Add(a.cfg.Window), a.cfg.BatchSize)
+		if err != nil {
+			return alerted, err
+		}
+		for i := range docs {
+			// mark the Document first so a failure doesn't send the alert again every interval
+			if err := a.repo.markExpiryNotified(ctx, docs[i].DocumentID, time.Now()); err != nil {
+				return alerted, err
+			}
+			a.notifier.Notify(webhooks.DocumentExpiring, docs[i].CustomerID, docs[i].Organization, "")
+			a.logger.With(log.Fields{
+				"customerID": log.String(docs[i].CustomerID),
+				"documentID": log.String(docs[i].DocumentID),
+				"expiresAt":  log.Time(docs[i].ExpiresAt),
+			}).Log("alerted on expiring document")
+			documentExpiryAlerts.With("type", docs[i].Type).Add(1)
+			alerted++
+		}
+		if len(docs) < a.cfg.BatchSize {
+			return alerted, nil
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/secrets"
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+type testExpiryNotifier struct {
+	mu          sync.Mutex
+	customerIDs []string
+}
+
+func (n *testExpiryNotifier) Notify(eventType webhooks.EventType, customerID, organization, status string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if eventType == webhooks.DocumentExpiring && organization == "test" {
+		n.customerIDs = append(n.customerIDs, customerID)
+	}
+}
+
+func TestDocuments__readExpiresAt(t *testing.T) {
+	at, err := readExpiresAt("")
+	require.NoError(t, err)
+	require.Nil(t, at)
+
+	at, err = readExpiresAt("2030-01-31")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2030, time.January, 31, 0, 0, 0, 0, time.UTC), *at)
+
+	at, err = readExpiresAt("2030-01-31T12:00:00Z")
+	require.NoError(t, err)
+	require.Equal(t, 12, at.Hour())
+
+	_, err = readExpiresAt("01/31/2030")
+	require.Error(t, err)
+}
+
+func TestDocuments__expiring(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	cust := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customers.NewCustomerRepo(logger, db.DB).CreateCustomer(context.Background(), cust, "test"))
+
+	repo := NewDocumentRepo(logger, db.DB)
+	write := func(expiresAt *time.Time) string {
+		doc := &client.Document{DocumentID: base.ID(), Type: "driverslicense", ContentType: "image/png", UploadedAt: time.Now(), ExpiresAt: expiresAt}
+		require.NoError(t, repo.writeCustomerDocument(context.Background(), cust.CustomerID, doc))
+		return doc.DocumentID
+	}
+	now := time.Now()
+	lastWeek, nextWeek, nextYear := now.Add(-7*24*time.Hour), now.Add(7*24*time.Hour), now.Add(365*24*time.Hour)
+	expiredID, soonID := write(&lastWeek), write(&nextWeek)
+	write(&nextYear)
+	write(nil)
+
+	// expired documents are flagged in the customer's list
+	docs, err := repo.listCustomerDocuments(context.Background(), cust.CustomerID, "test", documentFilters{Count: 10})
+	require.NoError(t, err)
+	require.Len(t, docs, 4)
+	require.True(t, docs[0].Expired)
+	for _, doc := range docs[1:] {
+		require.False(t, doc.Expired)
+	}
+
+	router := mux.NewRouter()
+	AddDocumentRoutes(logger, router, repo, secrets.TestKeeper(t), storage.NewTestBucket(t), nil, nil)
+	list := func(organization, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/customers/documents/expiring"+query, nil)
+		req.Header.Set("X-Organization", organization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := list("test", "?days=30")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var expiring []client.ExpiringDocuments
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&expiring))
+	require.Len(t, expiring, 1)
+	require.Equal(t, cust.CustomerID, expiring[0].CustomerID)
+	require.Len(t, expiring[0].Documents, 2)
+	require.Equal(t, expiredID, expiring[0].Documents[0].DocumentID)
+	require.True(t, expiring[0].Documents[0].Expired)
+	require.Equal(t, soonID, expiring[0].Documents[1].DocumentID)
+
+	w = list("other", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "[]\n", w.Body.String())
+
+	require.Equal(t, http.StatusBadRequest, list("test", "?days=-1").Code)
+	require.Equal(t, http.StatusBadRequest, list("test", "?days=1000").Code)
+
+	// alerts are sent once per document within the window
+	notifier := &testExpiryNotifier{}
+	alerter := NewExpiryAlerter(logger, repo, notifier, ExpiryConfig{Window: 30 * 24 * time.Hour, BatchSize: 1})
+	n, err := alerter.Alert(context.Background(), now)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []string{cust.CustomerID, cust.CustomerID}, notifier.customerIDs)
+
+	n, err = alerter.Alert(context.Background(), now)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// nothing is sent without a window
+	n, err = NewExpiryAlerter(logger, repo, notifier, ExpiryConfig{}).Alert(context.Background(), now.Add(365*24*time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
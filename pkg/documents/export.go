@@ -0,0 +1,52 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+// ExportRecords are the Document metadata and Disclaimer acceptances of a Customer included in a data export.
+// Document contents are not included.
+type ExportRecords struct {
+	Documents   []*client.Document
+	Disclaimers []*client.Disclaimer
+}
+
+// Exporter reads a Customer's Documents and accepted Disclaimers for data portability requests.
+type Exporter struct {
+	docs        DocumentRepository
+	disclaimers DisclaimerRepository
+}
+
+func NewExporter(docs DocumentRepository, disclaimers DisclaimerRepository) *Exporter {
+	return &Exporter{
+		docs:        docs,
+		disclaimers: disclaimers,
+	}
+}
+
+// Export returns the Customer's Document metadata and the Disclaimers they've accepted
+func (e *Exporter) Export(ctx context.Context, customerID, organization string) (*ExportRecords, error) {
+	docs, err := e.docs.getCustomerDocuments(ctx, customerID, organization)
+	if err != nil {
+		return nil, fmt.Errorf("export: documents: %v", err)
+	}
+	records := &ExportRecords{Documents: docs}
+
+	disclaimers, err := e.disclaimers.getCustomerDisclaimers(customerID)
+	if err != nil {
+		return nil, fmt.Errorf("export: disclaimers: %v", err)
+	}
+	for i := range disclaimers {
+		if disclaimers[i] != nil && !disclaimers[i].AcceptedAt.IsZero() {
+			records.Disclaimers = append(records.Disclaimers, disclaimers[i])
+		}
+	}
+	return records, nil
+}
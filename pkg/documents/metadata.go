@@ -0,0 +1,244 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// Document metadata has the same limits as Customer metadata
+const (
+	maxMetadataKeys        = 100
+	maxMetadataKeyLength   = 40  // size of the meta_key column
+	maxMetadataValueLength = 512 // size of the meta_value column
+)
+
+type replaceMetadataRequest struct {
+	Metadata map[string]string `json:"metadata"`
+}
+
+func validateMetadata(meta map[string]string) error {
+	if len(meta) > maxMetadataKeys {
+		return fmt.Errorf("metadata is limited to %d entries", maxMetadataKeys)
+	}
+	for k, v := range meta {
+		if k == "" || utf8.RuneCountInString(k) > maxMetadataKeyLength {
+			return fmt.Errorf("metadata key %q must be between 1 and %d characters", k, maxMetadataKeyLength)
+		}
+		if utf8.RuneCountInString(v) > maxMetadataValueLength {
+			return fmt.Errorf("metadata key %s value is too long", k)
+		}
+	}
+	return nil
+}
+
+func getDocumentMetadata(logger log.Logger, repo DocumentRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, documentID := route.GetCustomerID(w, r), getDocumentID(w, r)
+		if customerID == "" || documentID == "" {
+			return
+		}
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+		if !documentExists(logger, w, r, repo, customerID, documentID, organization) {
+			return
+		}
+
+		meta, err := repo.getDocumentMetadata(r.Context(), documentID)
+		if err != nil {
+			logger.Set("documentID", log.String(documentID)).LogErrorf("problem reading document metadata: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(meta)
+	}
+}
+
+func replaceDocumentMetadata(logger log.Logger, repo DocumentRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, documentID := route.GetCustomerID(w, r), getDocumentID(w, r)
+		if customerID == "" || documentID == "" {
+			return
+		}
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		var req replaceMetadataRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if err := validateMetadata(req.Metadata); err != nil {
+			route.Problem(w, route.Validation(err))
+			return
+		}
+		documentType, err := repo.getDocumentType(r.Context(), customerID, documentID, organization)
+		if err != nil {
+			logger.Set("documentID", log.String(documentID)).LogErrorf("problem finding document: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		if documentType == "" {
+			route.NotFound(w, r)
+			return
+		}
+		if err := validateTaxFormMetadata(documentType, req.Metadata, time.Now()); err != nil {
+			route.Problem(w, route.Validation(err))
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID)).Set("documentID", log.String(documentID))
+		if err := repo.replaceDocumentMetadata(r.Context(), documentID, req.Metadata); err != nil {
+			logger.LogErrorf("problem saving document metadata: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		meta, err := repo.getDocumentMetadata(r.Context(), documentID)
+		if err != nil {
+			logger.LogErrorf("problem reading document metadata: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(meta)
+	}
+}
+
+// documentExists writes a 404 and returns false when the Document is deleted or doesn't belong
+// to the Customer in organization
+func documentExists(logger log.Logger, w http.ResponseWriter, r *http.Request, repo DocumentRepository, customerID, documentID, organization string) bool {
+	exists, err := repo.exists(r.Context(), customerID, documentID, organization)
+	if err != nil {
+		logger.Set("documentID", log.String(documentID)).LogErrorf("problem finding document: %v", err)
+		route.Problem(w, err)
+		return false
+	}
+	if !exists {
+		route.NotFound(w, r)
+		return false
+	}
+	return true
+}
+
+// getDocumentMetadata returns the metadata of a Document, which is empty if none has been set
+func (r *sqlDocumentRepository) getDocumentMetadata(ctx context.Context, documentID string) (*client.DocumentMetadata, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select meta_key, meta_value, created_at, last_modified from document_metadata where document_id = ?;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("getDocumentMetadata: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("getDocumentMetadata: query: %v", err)
+	}
+	defer rows.Close()
+
+	meta := &client.DocumentMetadata{Metadata: make(map[string]string)}
+	for rows.Next() {
+		var key, value string
+		var createdAt, lastModified time.Time
+		if err := rows.Scan(&key, &value, &createdAt, &lastModified); err != nil {
+			return nil, fmt.Errorf("getDocumentMetadata: scan: %v", err)
+		}
+		meta.Metadata[key] = value
+		if meta.CreatedAt == nil || createdAt.Before(*meta.CreatedAt) {
+			meta.CreatedAt = &createdAt
+		}
+		if meta.LastModified == nil || lastModified.After(*meta.LastModified) {
+			meta.LastModified = &lastModified
+		}
+	}
+	return meta, rows.Err()
+}
+
+// replaceDocumentMetadata overwrites the metadata of a Document. Keys whose value is unchanged
+// keep their timestamps.
+func (r *sqlDocumentRepository) replaceDocumentMetadata(ctx context.Context, documentID string, metadata map[string]string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	return customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		type metaTimes struct {
+			value                   string
+			createdAt, lastModified time.Time
+		}
+		existing := make(map[string]metaTimes)
+		rows, err := tx.QueryContext(ctx, `select meta_key, meta_value, created_at, last_modified from document_metadata where document_id = ?;`, documentID)
+		if err != nil {
+			return fmt.Errorf("replaceDocumentMetadata: select: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var key string
+			var prev metaTimes
+			if err := rows.Scan(&key, &prev.value, &prev.createdAt, &prev.lastModified); err != nil {
+				return fmt.Errorf("replaceDocumentMetadata: scan: %v", err)
+			}
+			existing[key] = prev
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("replaceDocumentMetadata: select: %v", err)
+		}
+		rows.Close()
+
+		if _, err := tx.ExecContext(ctx, `delete from document_metadata where document_id = ?;`, documentID); err != nil {
+			return fmt.Errorf("replaceDocumentMetadata: delete: %v", err)
+		}
+
+		query := `insert into document_metadata (document_id, meta_key, meta_value, created_at, last_modified) values (?, ?, ?, ?, ?);`
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("replaceDocumentMetadata: insert prepare: %v", err)
+		}
+		defer stmt.Close()
+
+		now := time.Now()
+		for k, v := range metadata {
+			createdAt, lastModified := now, now
+			if prev, ok := existing[k]; ok {
+				createdAt = prev.createdAt
+				if prev.value == v {
+					lastModified = prev.lastModified
+				}
+			}
+			if _, err := stmt.ExecContext(ctx, documentID, k, v, createdAt, lastModified); err != nil {
+				return fmt.Errorf("replaceDocumentMetadata: insert %s: %v", k, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+)
+
+func TestDocumentMetadata(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	repo := NewDocumentRepo(logger, db.DB)
+	customerRepo := customers.NewCustomerRepo(logger, db.DB)
+
+	cust := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customerRepo.CreateCustomer(context.Background(), cust, "test"))
+	doc := &client.Document{DocumentID: base.ID(), Type: "DriversLicense", ContentType: "image/png"}
+	require.NoError(t, repo.writeCustomerDocument(context.Background(), cust.CustomerID, doc))
+
+	router := mux.NewRouter()
+	AddDocumentRoutes(logger, router, repo, nil, nil, nil, nil)
+
+	call := func(method, organization, documentID, body string) (*httptest.ResponseRecorder, client.DocumentMetadata) {
+		path := fmt.Sprintf("/customers/%s/documents/%s/metadata", cust.CustomerID, documentID)
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Organization", organization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var meta client.DocumentMetadata
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&meta))
+		}
+		return w, meta
+	}
+
+	w, meta := call("GET", "test", doc.DocumentID, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Empty(t, meta.Metadata)
+	require.Nil(t, meta.CreatedAt)
+
+	w, meta = call("PUT", "test", doc.DocumentID, `{"metadata": {"documentNumber": "D1234567", "issuingAuthority": "CO DMV"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, map[string]string{"documentNumber": "D1234567", "issuingAuthority": "CO DMV"}, meta.Metadata)
+	require.NotNil(t, meta.CreatedAt)
+	created := *meta.CreatedAt
+
+	// unchanged keys keep their timestamps
+	time.Sleep(10 * time.Millisecond)
+	w, meta = call("PUT", "test", doc.DocumentID, `{"metadata": {"documentNumber": "D1234567", "issueDate": "2019-04-01"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, meta.Metadata, 2)
+	require.True(t, created.Equal(*meta.CreatedAt))
+	require.True(t, meta.LastModified.After(created))
+
+	w, _ = call("PUT", "test", doc.DocumentID, fmt.Sprintf(`{"metadata": {%q: "value"}}`, strings.Repeat("a", maxMetadataKeyLength+1)))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// other organizations and missing or deleted Documents are not found
+	w, _ = call("GET", "other", doc.DocumentID, "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w, _ = call("PUT", "test", base.ID(), `{"metadata": {"a": "b"}}`)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	require.NoError(t, repo.deleteCustomerDocument(context.Background(), cust.CustomerID, doc.DocumentID))
+	w, _ = call("PUT", "test", doc.DocumentID, `{"metadata": {"a": "b"}}`)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// purging the Document's file removes its metadata
+	require.NoError(t, repo.markDocumentPurged(context.Background(), doc.DocumentID, time.Now()))
+	found, err := repo.getDocumentMetadata(context.Background(), doc.DocumentID)
+	require.NoError(t, err)
+	require.Empty(t, found.Metadata)
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/moov-io/base/log"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"gocloud.dev/secrets"
+
+	"github.com/moov-io/customers/internal/util"
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// previewSuffix is appended to a Document's key to store its preview next to the original
+const previewSuffix = "-preview"
+
+// maxPreviewSourcePixels limits the images decoded for previews so a small file can't expand
+// into an enormous image in memory.
+const maxPreviewSourcePixels = 50 * 1000 * 1000
+
+var (
+	previewsEnabled = util.Yes(os.Getenv("DOCUMENTS_PREVIEW_ENABLED"))
+
+	previewMaxWidth  = readPreviewDimension("DOCUMENTS_PREVIEW_MAX_WIDTH", 320)
+	previewMaxHeight = readPreviewDimension("DOCUMENTS_PREVIEW_MAX_HEIGHT", 320)
+)
+
+func readPreviewDimension(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		n, err := strconv.Atoi(v)
+		if err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
+
+func makePreviewKey(customerID, documentID string) string {
+	return makeDocumentKey(customerID, documentID) + previewSuffix
+}
+
+// generatePreview scales an uploaded image down to fit within maxWidth and maxHeight, keeping its
+// aspect ratio. Previews keep the format of the original. Images which already fit are re-encoded
+// at their original size.
+func generatePreview(data []byte, contentType string, maxWidth, maxHeight int) ([]byte, error) {
+	encode := imageEncoder(contentType)
+	if encode == nil {
+		return nil, fmt.Errorf("previews aren't supported for %s", contentType)
+	}
+	img, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, scaleImage(img, maxWidth, maxHeight)); err != nil {
+		return nil, fmt.Errorf("encoding preview: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// imageEncoder returns the encoder for contentType, or nil if it isn't a supported image format
+func imageEncoder(contentType string) func(*bytes.Buffer, image.Image) error {
+	switch contentType {
+	case "image/jpeg":
+		return func(buf *bytes.Buffer, img image.Image) error {
+			return jpeg.Encode(buf, img, &jpeg.Options{Quality: 80})
+		}
+	case "image/png":
+		return func(buf *bytes.Buffer, img image.Image) error {
+			return png.Encode(buf, img)
+		}
+	}
+	return nil
+}
+
+// decodeImage decodes data after checking its dimensions are within maxPreviewSourcePixels
+func decodeImage(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("reading image: %v", err)
+	}
+	if cfg.Width*cfg.Height > maxPreviewSourcePixels {
+		return nil, fmt.Errorf("image of %dx%d is too large to resize", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %v", err)
+	}
+	return img, nil
+}
+
+// scaleImage shrinks img to fit within maxWidth and maxHeight by averaging the source pixels
+// covered by each preview pixel.
+func scaleImage(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= maxWidth && srcH <= maxHeight {
+		return img
+	}
+
+	scale := float64(maxWidth) / float64(srcW)
+	if s := float64(maxHeight) / float64(srcH); s < scale {
+		scale = s
+	}
+	dstW, dstH := int(float64(srcW)*scale), int(float64(srcH)*scale)
+	if dstW < 1 {
+		dstW = 1
+	}
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0, y1 := bounds.Min.Y+y*srcH/dstH, bounds.Min.Y+(y+1)*srcH/dstH
+		for x := 0; x < dstW; x++ {
+			x0, x1 := bounds.Min.X+x*srcW/dstW, bounds.Min.X+(x+1)*srcW/dstW
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// writePreview stores an encrypted preview of an uploaded image. Failures are logged and don't fail
+// the upload since the original Document was stored.
+func writePreview(ctx context.Context, logger log.Logger, keeper *secrets.Keeper, bucket *blob.Bucket, customerID, documentID, contentType string, data []byte) {
+	if !previewsEnabled || (contentType != "image/jpeg" && contentType != "image/png") {
+		return
+	}
+	preview, err := generatePreview(data, contentType, previewMaxWidth, previewMaxHeight)
+	if err != nil {
+		logger.LogErrorf("skipping document preview: %v", err)
+		return
+	}
+	encrypted, err := keeper.Encrypt(ctx, preview)
+	if err != nil {
+		logger.LogErrorf("failed to encrypt document preview: %v", err)
+		return
+	}
+	err = bucket.WriteAll(ctx, makePreviewKey(customerID, documentID), encrypted, &blob.WriterOptions{
+		ContentDisposition: "inline",
+		ContentType:        contentType,
+	})
+	if err != nil {
+		logger.LogErrorf("problem uploading document preview: %v", err)
+	}
+}
+
+func retrieveDocumentPreview(logger log.Logger, repo DocumentRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, documentID := route.GetCustomerID(w, r), getDocumentID(w, r)
+		if customerID == "" || documentID == "" {
+			return
+		}
+		organization := route.GetOrganization(w, r)
+		if organization == "" {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID)).Set("documentID", log.String(documentID))
+
+		// reject the request if the document is deleted
+		if exists, err := repo.exists(r.Context(), customerID, documentID, organization); !exists || err != nil {
+			if err != nil {
+				logger.LogErrorf("failed to check document existence: %v", err)
+			}
+			route.NotFound(w, r)
+			return
+		}
+
+		bucket, err := bucketFactory()
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		defer bucket.Close()
+
+		ctx, cancelFn := context.WithTimeout(context.TODO(), 10*time.Second)
+		defer cancelFn()
+
+		// PDFs and documents uploaded while previews were disabled have no preview
+		rdr, err := bucket.NewReader(ctx, makePreviewKey(customerID, documentID), nil)
+		if err != nil {
+			if gcerrors.Code(err) == gcerrors.NotFound {
+				route.NotFound(w, r)
+				return
+			}
+			route.Problem(w, fmt.Errorf("read preview of documentID=%s: %v", documentID, err))
+			return
+		}
+		defer rdr.Close()
+
+		encrypted, err := ioutil.ReadAll(rdr)
+		if err != nil {
+			logger.LogErrorf("failed reading document preview from storage bucket: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		preview, err := keeper.Decrypt(ctx, encrypted)
+		if err != nil {
+			logger.LogErrorf("failed to decrypt document preview: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", rdr.ContentType())
+		w.WriteHeader(http.StatusOK)
+		w.Write(preview)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+func TestDocuments__scaleImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for x := 0; x < 200; x++ {
+		for y := 0; y < 200; y++ {
+			src.Set(x, y, color.White)
+		}
+	}
+
+	out := scaleImage(src, 100, 100)
+	require.Equal(t, image.Rect(0, 0, 100, 50), out.Bounds())
+
+	// left half was white, right half transparent
+	r, _, _, a := out.At(10, 10).RGBA()
+	require.Equal(t, uint32(0xffff), r)
+	require.Equal(t, uint32(0xffff), a)
+	_, _, _, a = out.At(90, 10).RGBA()
+	require.Equal(t, uint32(0), a)
+
+	// images which fit aren't scaled
+	require.Equal(t, src, scaleImage(src, 400, 400))
+}
+
+func TestDocuments__generatePreview(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "colorado.jpg"))
+	require.NoError(t, err)
+
+	preview, err := generatePreview(data, "image/jpeg", 64, 64)
+	require.NoError(t, err)
+	require.Less(t, len(preview), len(data))
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(preview))
+	require.NoError(t, err)
+	require.Equal(t, "jpeg", format)
+	require.True(t, cfg.Width <= 64 && cfg.Height <= 64, "%dx%d", cfg.Width, cfg.Height)
+
+	_, err = generatePreview([]byte("%PDF-1.4"), "application/pdf", 64, 64)
+	require.Error(t, err)
+
+	_, err = generatePreview([]byte("not an image"), "image/png", 64, 64)
+	require.Error(t, err)
+}
+
+func TestDocuments__preview(t *testing.T) {
+	previewsEnabled = true
+	defer func() { previewsEnabled = false }()
+
+	repo := &testDocumentRepository{docExists: true}
+	router := mux.NewRouter()
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.NewTestBucket(t), nil, nil)
+
+	upload := func(req *http.Request) client.Document {
+		req.Header.Set("X-organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var doc client.Document
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&doc))
+		return doc
+	}
+	preview := func(documentID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/customers/foo/documents/%s/preview", documentID), nil)
+		req.Header.Set("X-organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	doc := upload(multipartRequest(t))
+	w := preview(doc.DocumentID)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+
+	cfg, _, err := image.DecodeConfig(w.Body)
+	require.NoError(t, err)
+	require.True(t, cfg.Width <= previewMaxWidth && cfg.Height <= previewMaxHeight)
+
+	// PDFs have no preview
+	doc = upload(multipartFileOfSize(t, "file", 256))
+	w = preview(doc.DocumentID)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// deleted documents aren't found
+	repo.docExists = false
+	w = preview(doc.DocumentID)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDocuments__previewPNG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1000, 10))))
+
+	preview, err := generatePreview(buf.Bytes(), "image/png", 100, 100)
+	require.NoError(t, err)
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(preview))
+	require.NoError(t, err)
+	require.Equal(t, "png", format)
+	require.Equal(t, 100, cfg.Width)
+	require.Equal(t, 1, cfg.Height)
+}
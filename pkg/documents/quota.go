@@ -0,0 +1,87 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+const (
+	// documentLimitMetadataKey is the Customer metadata key overriding maxDocumentsPerCustomer for one Customer
+	documentLimitMetadataKey = "documentLimit"
+
+	documentCountHeaderKey = "X-Document-Count"
+	documentLimitHeaderKey = "X-Document-Limit"
+)
+
+// maxDocumentsPerCustomer is how many Documents, not counting deleted ones, a Customer can have
+var maxDocumentsPerCustomer = func() int {
+	if v := os.Getenv("DOCUMENTS_MAX_PER_CUSTOMER"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err == nil && n > 0 {
+			return n
+		}
+	}
+	return 100
+}()
+
+// documentQuota is how many Documents a Customer has and is allowed
+type documentQuota struct {
+	Count int
+	Limit int
+}
+
+func (q documentQuota) setHeaders(w http.ResponseWriter) {
+	w.Header().Set(documentCountHeaderKey, strconv.Itoa(q.Count))
+	w.Header().Set(documentLimitHeaderKey, strconv.Itoa(q.Limit))
+}
+
+// readDocumentLimit returns the Customer's override of maxDocumentsPerCustomer when it's a positive number
+func readDocumentLimit(override string) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(override)); err == nil && n > 0 {
+		return n
+	}
+	return maxDocumentsPerCustomer
+}
+
+// checkDocumentQuota rejects another Document for a Customer who has reached their limit
+func checkDocumentQuota(ctx context.Context, repo DocumentRepository, customerID string) error {
+	quota, err := repo.getDocumentQuota(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	if quota.Count >= quota.Limit {
+		return route.Conflict(fmt.Errorf("customer has %d documents which is their limit, delete one before uploading another", quota.Limit))
+	}
+	return nil
+}
+
+func (r *sqlDocumentRepository) getDocumentQuota(ctx context.Context, customerID string) (*documentQuota, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	quota := &documentQuota{}
+	query := `select count(*) from documents where customer_id = ? and deleted_at is null;`
+	if err := r.db.QueryRowContext(ctx, query, customerID).Scan(&quota.Count); err != nil {
+		return nil, fmt.Errorf("getDocumentQuota: count: %v", err)
+	}
+
+	var override string
+	query = `select meta_value from customer_metadata where customer_id = ? and meta_key = ? limit 1;`
+	if err := r.db.QueryRowContext(ctx, query, customerID, documentLimitMetadataKey).Scan(&override); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("getDocumentQuota: metadata: %v", err)
+	}
+	quota.Limit = readDocumentLimit(override)
+	return quota, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+func TestDocuments__readDocumentLimit(t *testing.T) {
+	require.Equal(t, maxDocumentsPerCustomer, readDocumentLimit(""))
+	require.Equal(t, maxDocumentsPerCustomer, readDocumentLimit("none"))
+	require.Equal(t, maxDocumentsPerCustomer, readDocumentLimit("-1"))
+	require.Equal(t, 250, readDocumentLimit(" 250 "))
+}
+
+func TestDocuments__uploadOverQuota(t *testing.T) {
+	repo := &testDocumentRepository{quota: &documentQuota{Count: 5, Limit: 5}}
+
+	router := mux.NewRouter()
+	AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.NewTestBucket(t), nil, nil)
+
+	w := httptest.NewRecorder()
+	req := multipartRequest(t)
+	req.Header.Set("X-organization", "test")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), "customer has 5 documents which is their limit")
+	require.Nil(t, repo.written)
+
+	repo.quota.Count = 4
+	w = httptest.NewRecorder()
+	req = multipartRequest(t)
+	req.Header.Set("X-organization", "test")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NotNil(t, repo.written)
+}
+
+func TestDocumentRepository__getDocumentQuota(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	repo := NewDocumentRepo(logger, db.DB)
+	cust := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customers.NewCustomerRepo(logger, db.DB).CreateCustomer(context.Background(), cust, "test"))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, repo.writeCustomerDocument(context.Background(), cust.CustomerID, &client.Document{DocumentID: base.ID(), Type: "passport", ContentType: "image/png"}))
+	}
+	deleted := &client.Document{DocumentID: base.ID(), Type: "passport", ContentType: "image/png"}
+	require.NoError(t, repo.writeCustomerDocument(context.Background(), cust.CustomerID, deleted))
+	require.NoError(t, repo.deleteCustomerDocument(context.Background(), cust.CustomerID, deleted.DocumentID))
+
+	quota, err := repo.getDocumentQuota(context.Background(), cust.CustomerID)
+	require.NoError(t, err)
+	require.Equal(t, 3, quota.Count)
+	require.Equal(t, maxDocumentsPerCustomer, quota.Limit)
+
+	// Customers can be allowed more (or fewer) Documents with metadata
+	_, err = db.DB.Exec(`insert into customer_metadata (customer_id, meta_key, meta_value) values (?, ?, ?);`, cust.CustomerID, documentLimitMetadataKey, "3")
+	require.NoError(t, err)
+	quota, err = repo.getDocumentQuota(context.Background(), cust.CustomerID)
+	require.NoError(t, err)
+	require.Equal(t, 3, quota.Limit)
+	require.Error(t, checkDocumentQuota(context.Background(), repo, cust.CustomerID))
+
+	router := mux.NewRouter()
+	AddDocumentRoutes(logger, router, repo, nil, nil, nil, nil)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", fmt.Sprintf("/customers/%s/documents", cust.CustomerID), nil)
+	req.Header.Set("X-organization", "test")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "3", w.Header().Get(documentCountHeaderKey))
+	require.Equal(t, "3", w.Header().Get(documentLimitHeaderKey))
+}
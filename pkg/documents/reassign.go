@@ -0,0 +1,232 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/base/admin"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+	"gocloud.dev/gcerrors"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/audit"
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+var (
+	errReassignCustomerNotFound = route.NewError(http.StatusNotFound, route.CodeNotFound, "customer not found")
+	errReassignDocumentNotFound = route.NewError(http.StatusNotFound, route.CodeNotFound, "document not found")
+	errReassignSameCustomer     = route.Validation(errors.New("the document already belongs to this customer"))
+)
+
+// Reassignment is the record of a Document moved to another Customer
+type Reassignment struct {
+	DocumentID     string    `json:"documentID"`
+	FromCustomerID string    `json:"fromCustomerID"`
+	ToCustomerID   string    `json:"toCustomerID"`
+	Organization   string    `json:"organization"`
+	ReassignedBy   string    `json:"reassignedBy"`
+	RequestID      string    `json:"requestID,omitempty"`
+	ReassignedAt   time.Time `json:"reassignedAt"`
+}
+
+// Reassigner moves Documents uploaded under the wrong Customer
+type Reassigner struct {
+	logger        log.Logger
+	db            *sql.DB
+	bucketFactory storage.BucketFunc
+}
+
+func NewReassigner(logger log.Logger, db *sql.DB, bucketFactory storage.BucketFunc) *Reassigner {
+	return &Reassigner{
+		logger:        logger.Set("package", log.String("documents")),
+		db:            db,
+		bucketFactory: bucketFactory,
+	}
+}
+
+// Reassign moves a Document, along with its metadata and scans, from one Customer to another in the same
+// organization. Neither Customer can be deleted. The stored file and its preview are copied within the
+// bucket before the transaction commits and the originals are removed after, so nothing is uploaded again.
+func (ra *Reassigner) Reassign(ctx context.Context, documentID, fromCustomerID, toCustomerID, organization, actor, requestID string) (*Reassignment, error) {
+	if fromCustomerID == toCustomerID {
+		return nil, errReassignSameCustomer
+	}
+
+	result := &Reassignment{
+		DocumentID:     documentID,
+		FromCustomerID: fromCustomerID,
+		ToCustomerID:   toCustomerID,
+		Organization:   organization,
+		ReassignedBy:   actor,
+		RequestID:      requestID,
+		ReassignedAt:   time.Now(),
+	}
+	err := customersdb.RetryOnLock(ra.db, func(tx *sql.Tx) error {
+		for _, customerID := range []string{fromCustomerID, toCustomerID} {
+			exists, err := customerExists(tx, customerID, organization)
+			if err != nil {
+				return fmt.Errorf("reassign: %v", err)
+			}
+			if !exists {
+				return errReassignCustomerNotFound
+			}
+		}
+
+		res, err := tx.Exec(`update documents set customer_id = ? where document_id = ? and customer_id = ? and deleted_at is null;`, toCustomerID, documentID, fromCustomerID)
+		if err != nil {
+			return fmt.Errorf("reassign: update document: %v", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errReassignDocumentNotFound
+		}
+		if _, err := tx.Exec(`update document_scans set customer_id = ? where document_id = ? and customer_id = ?;`, toCustomerID, documentID, fromCustomerID); err != nil {
+			return fmt.Errorf("reassign: update scans: %v", err)
+		}
+
+		if err := ra.copyBlobs(ctx, documentID, fromCustomerID, toCustomerID); err != nil {
+			return fmt.Errorf("reassign: %v", err)
+		}
+		return recordReassignment(tx, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ra.deleteBlobs(ctx, fromCustomerID, documentID); err != nil {
+		ra.logger.Set("customerID", log.String(fromCustomerID)).Set("documentID", log.String(documentID)).LogErrorf("problem removing reassigned document: %v", err)
+	}
+	return result, nil
+}
+
+// copyBlobs copies the Document's file, and preview when there is one, under the other Customer
+func (ra *Reassigner) copyBlobs(ctx context.Context, documentID, fromCustomerID, toCustomerID string) error {
+	bucket, err := ra.bucketFactory()
+	if err != nil {
+		return fmt.Errorf("failed to create bucket: %v", err)
+	}
+	defer bucket.Close()
+
+	src, dst := makeDocumentKey(fromCustomerID, documentID), makeDocumentKey(toCustomerID, documentID)
+	if err := bucket.Copy(ctx, dst, src, nil); err != nil {
+		return fmt.Errorf("copying document %s: %v", src, err)
+	}
+	if err := bucket.Copy(ctx, dst+previewSuffix, src+previewSuffix, nil); err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+		return fmt.Errorf("copying preview %s: %v", src, err)
+	}
+	return nil
+}
+
+func (ra *Reassigner) deleteBlobs(ctx context.Context, customerID, documentID string) error {
+	bucket, err := ra.bucketFactory()
+	if err != nil {
+		return fmt.Errorf("failed to create bucket: %v", err)
+	}
+	defer bucket.Close()
+
+	key := makeDocumentKey(customerID, documentID)
+	for _, k := range []string{key, key + previewSuffix} {
+		if err := bucket.Delete(ctx, k); err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+			return fmt.Errorf("deleting %s: %v", k, err)
+		}
+	}
+	return nil
+}
+
+// recordReassignment adds an entry to the audit log of both Customers
+func recordReassignment(tx *sql.Tx, result *Reassignment) error {
+	action := "POST /customers/{customerID}/documents/{documentID}/reassign"
+	err := audit.Record(tx, &audit.Entry{
+		Actor:        result.ReassignedBy,
+		Action:       action,
+		CustomerID:   result.FromCustomerID,
+		Organization: result.Organization,
+		RequestID:    result.RequestID,
+		Diff:         audit.Diff{"documentReassignedTo": audit.Change{From: result.DocumentID, To: result.ToCustomerID}},
+		CreatedAt:    result.ReassignedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return audit.Record(tx, &audit.Entry{
+		Actor:        result.ReassignedBy,
+		Action:       action,
+		CustomerID:   result.ToCustomerID,
+		Organization: result.Organization,
+		RequestID:    result.RequestID,
+		Diff:         audit.Diff{"documentReassignedFrom": audit.Change{From: result.FromCustomerID, To: result.DocumentID}},
+		CreatedAt:    result.ReassignedAt,
+	})
+}
+
+// AddReassignAdminRoutes registers the endpoint moving a Document to another Customer on the admin server.
+// Reassignments are attributed to an operator with the X-User-ID header.
+func AddReassignAdminRoutes(logger log.Logger, svc *admin.Server, reassigner *Reassigner) {
+	logger = logger.Set("package", log.String("documents"))
+
+	svc.AddHandler("/customers/{customerID}/documents/{documentID}/reassign", reassignDocument(logger, reassigner))
+}
+
+type reassignRequest struct {
+	CustomerID string `json:"customerID"`
+}
+
+func reassignDocument(logger log.Logger, reassigner *Reassigner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if r.Method != "POST" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		documentID := getDocumentID(w, r)
+		if documentID == "" {
+			return
+		}
+		actor := moovhttp.GetUserID(r)
+		if actor == "" {
+			route.Problem(w, route.Forbidden(errors.New("reassigning documents requires the X-User-ID header")))
+			return
+		}
+
+		var req reassignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if req.CustomerID == "" {
+			route.Problem(w, route.Validation(errors.New("missing customerID")))
+			return
+		}
+		logger = logger.Set("customerID", log.String(customerID)).Set("documentID", log.String(documentID)).Set("actor", log.String(actor))
+
+		result, err := reassigner.Reassign(r.Context(), documentID, customerID, req.CustomerID, organization, actor, moovhttp.GetRequestID(r))
+		if err != nil {
+			logger.LogErrorf("problem reassigning document: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		logger.Logf("reassigned document to customer=%s", result.ToCustomerID)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/documents/storage"
+)
+
+func TestReassigner(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	customerRepo := customers.NewCustomerRepo(logger, db.DB)
+	from := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customerRepo.CreateCustomer(context.Background(), from, "test"))
+	to := &client.Customer{CustomerID: base.ID(), FirstName: "John", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customerRepo.CreateCustomer(context.Background(), to, "test"))
+	deleted := &client.Customer{CustomerID: base.ID(), FirstName: "Jim", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customerRepo.CreateCustomer(context.Background(), deleted, "test"))
+	_, err := db.DB.Exec(`update customers set deleted_at = ? where customer_id = ?;`, time.Now(), deleted.CustomerID)
+	require.NoError(t, err)
+
+	repo := NewDocumentRepo(logger, db.DB)
+	doc := &client.Document{DocumentID: base.ID(), Type: "DriversLicense", ContentType: "image/jpeg"}
+	require.NoError(t, repo.writeCustomerDocument(context.Background(), from.CustomerID, doc))
+
+	bucketFactory := storage.NewTestBucket(t)
+	bucket, err := bucketFactory()
+	require.NoError(t, err)
+	defer bucket.Close()
+	ctx := context.Background()
+	require.NoError(t, bucket.WriteAll(ctx, makeDocumentKey(from.CustomerID, doc.DocumentID), []byte("contents"), nil))
+
+	svc := admin.NewServer(":0")
+	defer svc.Shutdown()
+	AddReassignAdminRoutes(logger, svc, NewReassigner(logger, db.DB, bucketFactory))
+	go svc.Listen()
+
+	do := func(method, customerID, userID, body string) *http.Response {
+		req, err := http.NewRequest(method, fmt.Sprintf("http://%s/customers/%s/documents/%s/reassign", svc.BindAddr(), customerID, doc.DocumentID), strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("x-organization", "test")
+		if userID != "" {
+			req.Header.Set("x-user-id", userID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	body := func(customerID string) string {
+		return fmt.Sprintf(`{"customerID": %q}`, customerID)
+	}
+
+	resp := do("POST", from.CustomerID, "", body(to.CustomerID))
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = do("POST", from.CustomerID, "operator", `{}`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = do("POST", from.CustomerID, "operator", body(from.CustomerID))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = do("POST", from.CustomerID, "operator", body(deleted.CustomerID))
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = do("POST", from.CustomerID, "operator", body(base.ID()))
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = do("POST", to.CustomerID, "operator", body(from.CustomerID))
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = do("POST", from.CustomerID, "operator", body(to.CustomerID))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result Reassignment
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(t, from.CustomerID, result.FromCustomerID)
+	require.Equal(t, to.CustomerID, result.ToCustomerID)
+	require.Equal(t, "operator", result.ReassignedBy)
+
+	// the document is listed under the other customer
+	docs, err := repo.getCustomerDocuments(ctx, to.CustomerID, "test")
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	require.Equal(t, doc.DocumentID, docs[0].DocumentID)
+	docs, err = repo.getCustomerDocuments(ctx, from.CustomerID, "test")
+	require.NoError(t, err)
+	require.Empty(t, docs)
+
+	// the stored file was moved rather than uploaded again
+	contents, err := bucket.ReadAll(ctx, makeDocumentKey(to.CustomerID, doc.DocumentID))
+	require.NoError(t, err)
+	require.Equal(t, "contents", string(contents))
+	exists, err := bucket.Exists(ctx, makeDocumentKey(from.CustomerID, doc.DocumentID))
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	for _, customerID := range []string{from.CustomerID, to.CustomerID} {
+		var action, actor string
+		require.NoError(t, db.DB.QueryRow(`select action, actor from audit_log where customer_id = ?;`, customerID).Scan(&action, &actor))
+		require.Equal(t, "POST /customers/{customerID}/documents/{documentID}/reassign", action)
+		require.Equal(t, "operator", actor)
+	}
+
+	resp = do("GET", to.CustomerID, "operator", "")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -5,20 +5,45 @@
 package documents
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
+	"github.com/go-kit/kit/metrics/prometheus"
 	"github.com/moov-io/base/log"
+	customersdb "github.com/moov-io/customers/internal/database"
 	"github.com/moov-io/customers/pkg/client"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	documentsUploaded = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "documents_uploaded",
+		Help: "Counter of Documents uploaded by their type",
+	}, []string{"type"})
 )
 
 type DocumentRepository interface {
-	exists(customerID string, documentID string, organization string) (bool, error)
-	getCustomerDocuments(customerID string, organization string) ([]*client.Document, error)
+	exists(ctx context.Context, customerID string, documentID string, organization string) (bool, error)
+	getDocumentType(ctx context.Context, customerID string, documentID string, organization string) (string, error)
+	getCustomerDocuments(ctx context.Context, customerID string, organization string) ([]*client.Document, error)
+	listCustomerDocuments(ctx context.Context, customerID string, organization string, filters documentFilters) ([]*client.Document, error)
+	getDocumentQuota(ctx context.Context, customerID string) (*documentQuota, error)
+
+	writeCustomerDocument(ctx context.Context, customerID string, doc *client.Document) error
+	deleteCustomerDocument(ctx context.Context, customerID string, documentID string) error
+	restoreCustomerDocument(ctx context.Context, customerID string, documentID string, organization string) (bool, error)
+
+	expiredDocuments(ctx context.Context, deletedBefore time.Time, limit int) ([]expiredDocument, error)
+	markDocumentPurged(ctx context.Context, documentID string, purgedAt time.Time) error
 
-	writeCustomerDocument(customerID string, doc *client.Document) error
-	deleteCustomerDocument(customerID string, documentID string) error
+	expiringDocuments(ctx context.Context, organization string, expiresBefore time.Time) ([]*client.ExpiringDocuments, error)
+	unnotifiedExpiringDocuments(ctx context.Context, expiresBefore time.Time, limit int) ([]expiringDocument, error)
+	markExpiryNotified(ctx context.Context, documentID string, notifiedAt time.Time) error
+
+	getDocumentMetadata(ctx context.Context, documentID string) (*client.DocumentMetadata, error)
+	replaceDocumentMetadata(ctx context.Context, documentID string, metadata map[string]string) error
 }
 
 type sqlDocumentRepository struct {
@@ -33,35 +58,61 @@ func NewDocumentRepo(logger log.Logger, db *sql.DB) DocumentRepository {
 	}
 }
 
-func (r *sqlDocumentRepository) exists(customerID string, documentID string, organization string) (bool, error) {
+func (r *sqlDocumentRepository) exists(ctx context.Context, customerID string, documentID string, organization string) (bool, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	query := `select documents.document_id from documents
-inner join customers on customers.organization = ?
+inner join customers on customers.customer_id = documents.customer_id and customers.organization = ?
 where documents.customer_id = ? and documents.document_id = ? and documents.deleted_at is null
 limit 1;`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return false, fmt.Errorf("prepare exists: %v", err)
 	}
 	defer stmt.Close()
 
 	var docID string
-	if err := stmt.QueryRow(organization, customerID, documentID).Scan(&docID); err != nil {
+	if err := stmt.QueryRowContext(ctx, organization, customerID, documentID).Scan(&docID); err != nil {
 		return false, err
 	}
 	return documentID == docID, nil
 }
 
-func (r *sqlDocumentRepository) getCustomerDocuments(customerID string, organization string) ([]*client.Document, error) {
-	query := `select document_id, documents.type, content_type, uploaded_at from documents
+// getDocumentType returns the type of a Document, or an empty string when it's deleted or doesn't
+// belong to the Customer in organization
+func (r *sqlDocumentRepository) getDocumentType(ctx context.Context, customerID string, documentID string, organization string) (string, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select documents.type from documents
+inner join customers on customers.customer_id = documents.customer_id and customers.organization = ?
+where documents.customer_id = ? and documents.document_id = ? and documents.deleted_at is null
+limit 1;`
+	var documentType string
+	if err := r.db.QueryRowContext(ctx, query, organization, customerID, documentID).Scan(&documentType); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("getDocumentType: %v", err)
+	}
+	return documentType, nil
+}
+
+func (r *sqlDocumentRepository) getCustomerDocuments(ctx context.Context, customerID string, organization string) ([]*client.Document, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select document_id, documents.type, content_type, uploaded_at, documents.expires_at from documents
 inner join customers on customers.customer_id = documents.customer_id
 where customers.organization = ? and documents.customer_id = ? and documents.deleted_at is null;`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("prepare listing documents: %v", err)
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query(organization, customerID)
+	rows, err := stmt.QueryContext(ctx, organization, customerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed querying customer documents: %v", err)
 	}
@@ -70,9 +121,10 @@ where customers.organization = ? and documents.customer_id = ? and documents.del
 	docs := make([]*client.Document, 0)
 	for rows.Next() {
 		var doc client.Document
-		if err := rows.Scan(&doc.DocumentID, &doc.Type, &doc.ContentType, &doc.UploadedAt); err != nil {
+		if err := rows.Scan(&doc.DocumentID, &doc.Type, &doc.ContentType, &doc.UploadedAt, &doc.ExpiresAt); err != nil {
 			return nil, fmt.Errorf("scan customer documents: %v", err)
 		}
+		doc.Expired = expired(doc.ExpiresAt)
 		docs = append(docs, &doc)
 	}
 
@@ -83,28 +135,286 @@ where customers.organization = ? and documents.customer_id = ? and documents.del
 	return docs, nil
 }
 
-func (r *sqlDocumentRepository) writeCustomerDocument(customerID string, doc *client.Document) error {
-	query := `insert into documents (document_id, customer_id, type, content_type, uploaded_at) values (?, ?, ?, ?, ?);`
-	stmt, err := r.db.Prepare(query)
+// documentFilters narrow and page through a Customer's Documents
+type documentFilters struct {
+	Type string
+
+	// After is the documentID of the last Document on the previous page
+	After string
+	Count int
+
+	IncludeDeleted bool
+}
+
+// listCustomerDocuments returns up to filters.Count of a Customer's Documents ordered by when they
+// were uploaded, starting after the Document named by filters.After.
+func (r *sqlDocumentRepository) listCustomerDocuments(ctx context.Context, customerID string, organization string, filters documentFilters) ([]*client.Document, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select documents.document_id, documents.type, documents.content_type, documents.uploaded_at, documents.deleted_at, documents.expires_at from documents
+inner join customers on customers.customer_id = documents.customer_id
+where customers.organization = ? and documents.customer_id = ?`
+	args := []interface{}{organization, customerID}
+	if !filters.IncludeDeleted {
+		query += " and documents.deleted_at is null"
+	}
+	if filters.Type != "" {
+		query += " and documents.type = ?"
+		args = append(args, filters.Type)
+	}
+	if filters.After != "" {
+		after := "(select uploaded_at from documents where customer_id = ? and document_id = ?)"
+		query += fmt.Sprintf(" and (documents.uploaded_at > %s or (documents.uploaded_at = %s and documents.document_id > ?))", after, after)
+		args = append(args, customerID, filters.After, customerID, filters.After, filters.After)
+	}
+	query += " order by documents.uploaded_at asc, documents.document_id asc limit ?;"
+	args = append(args, filters.Count)
+
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("listCustomerDocuments: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listCustomerDocuments: query: %v", err)
+	}
+	defer rows.Close()
+
+	docs := make([]*client.Document, 0)
+	for rows.Next() {
+		var doc client.Document
+		if err := rows.Scan(&doc.DocumentID, &doc.Type, &doc.ContentType, &doc.UploadedAt, &doc.DeletedAt, &doc.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("listCustomerDocuments: scan: %v", err)
+		}
+		doc.Expired = expired(doc.ExpiresAt)
+		docs = append(docs, &doc)
+	}
+	return docs, rows.Err()
+}
+
+func (r *sqlDocumentRepository) writeCustomerDocument(ctx context.Context, customerID string, doc *client.Document) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `insert into documents (document_id, customer_id, type, content_type, uploaded_at, expires_at) values (?, ?, ?, ?, ?, ?);`
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("prepare write: %v", err)
 	}
 	defer stmt.Close()
 
-	if _, err := stmt.Exec(doc.DocumentID, customerID, doc.Type, doc.ContentType, doc.UploadedAt); err != nil {
+	if _, err := stmt.ExecContext(ctx, doc.DocumentID, customerID, doc.Type, doc.ContentType, doc.UploadedAt, doc.ExpiresAt); err != nil {
 		return fmt.Errorf("write customer document: %v", err)
 	}
+	documentsUploaded.With("type", doc.Type).Add(1)
 	return nil
 }
 
-func (r *sqlDocumentRepository) deleteCustomerDocument(customerID string, documentID string) error {
+func (r *sqlDocumentRepository) deleteCustomerDocument(ctx context.Context, customerID string, documentID string) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
 	query := `update documents set deleted_at = ? where customer_id = ? and document_id = ? and deleted_at is null;`
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("prepare delete: %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(time.Now(), customerID, documentID)
+	_, err = stmt.ExecContext(ctx, time.Now(), customerID, documentID)
 	return err
 }
+
+// restoreCustomerDocument clears deleted_at of a Document whose blob hasn't been purged yet.
+// It returns false when no such Document was found.
+func (r *sqlDocumentRepository) restoreCustomerDocument(ctx context.Context, customerID string, documentID string, organization string) (bool, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `update documents set deleted_at = null
+where customer_id = ? and document_id = ? and deleted_at is not null and purged_at is null
+and customer_id in (select customer_id from customers where organization = ? and deleted_at is null);`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return false, fmt.Errorf("restoreCustomerDocument: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, customerID, documentID, organization)
+	if err != nil {
+		return false, fmt.Errorf("restoreCustomerDocument: exec: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("restoreCustomerDocument: rows affected: %v", err)
+	}
+	return n > 0, nil
+}
+
+// expiredDocument is a soft-deleted Document whose blob is due to be purged
+type expiredDocument struct {
+	CustomerID string
+	DocumentID string
+	Type       string
+	DeletedAt  time.Time
+}
+
+// expiredDocuments returns up to limit Documents deleted before deletedBefore which haven't been purged
+func (r *sqlDocumentRepository) expiredDocuments(ctx context.Context, deletedBefore time.Time, limit int) ([]expiredDocument, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select customer_id, document_id, type, deleted_at from documents
+where deleted_at is not null and deleted_at < ? and purged_at is null
+order by deleted_at asc, document_id asc limit ?;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("expiredDocuments: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, deletedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("expiredDocuments: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []expiredDocument
+	for rows.Next() {
+		var doc expiredDocument
+		if err := rows.Scan(&doc.CustomerID, &doc.DocumentID, &doc.Type, &doc.DeletedAt); err != nil {
+			return nil, fmt.Errorf("expiredDocuments: scan: %v", err)
+		}
+		out = append(out, doc)
+	}
+	return out, rows.Err()
+}
+
+// markDocumentPurged records the Document's file was deleted and removes the metadata read off it
+func (r *sqlDocumentRepository) markDocumentPurged(ctx context.Context, documentID string, purgedAt time.Time) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	return customersdb.RetryOnLockContext(ctx, r.db, func(tx *sql.Tx) error {
+		query := `update documents set purged_at = ? where document_id = ? and purged_at is null;`
+		if _, err := tx.ExecContext(ctx, query, purgedAt, documentID); err != nil {
+			return fmt.Errorf("markDocumentPurged: exec: %v", err)
+		}
+		if _, err := tx.ExecContext(ctx, `delete from document_metadata where document_id = ?;`, documentID); err != nil {
+			return fmt.Errorf("markDocumentPurged: delete metadata: %v", err)
+		}
+		return nil
+	})
+}
+
+// expired returns true if expiresAt is set and has passed
+func expired(expiresAt *time.Time) bool {
+	return expiresAt != nil && expiresAt.Before(time.Now())
+}
+
+// expiringDocuments returns the Documents of active Customers in organization which expire before
+// expiresBefore, grouped by Customer, soonest expiration first.
+func (r *sqlDocumentRepository) expiringDocuments(ctx context.Context, organization string, expiresBefore time.Time) ([]*client.ExpiringDocuments, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select documents.customer_id, documents.document_id, documents.type, documents.content_type, documents.uploaded_at, documents.expires_at from documents
+inner join customers on customers.customer_id = documents.customer_id
+where customers.organization = ? and customers.deleted_at is null and documents.deleted_at is null
+and documents.expires_at is not null and documents.expires_at < ?
+order by documents.expires_at asc, documents.document_id asc;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("expiringDocuments: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, organization, expiresBefore)
+	if err != nil {
+		return nil, fmt.Errorf("expiringDocuments: query: %v", err)
+	}
+	defer rows.Close()
+
+	out := make([]*client.ExpiringDocuments, 0)
+	byCustomer := make(map[string]*client.ExpiringDocuments)
+	for rows.Next() {
+		var customerID string
+		var doc client.Document
+		if err := rows.Scan(&customerID, &doc.DocumentID, &doc.Type, &doc.ContentType, &doc.UploadedAt, &doc.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("expiringDocuments: scan: %v", err)
+		}
+		doc.Expired = expired(doc.ExpiresAt)
+
+		cust, ok := byCustomer[customerID]
+		if !ok {
+			cust = &client.ExpiringDocuments{CustomerID: customerID}
+			byCustomer[customerID] = cust
+			out = append(out, cust)
+		}
+		cust.Documents = append(cust.Documents, doc)
+	}
+	return out, rows.Err()
+}
+
+// expiringDocument is a Document which expires soon that no alert has been sent for
+type expiringDocument struct {
+	CustomerID   string
+	Organization string
+	DocumentID   string
+	Type         string
+	ExpiresAt    time.Time
+}
+
+// unnotifiedExpiringDocuments returns up to limit Documents of active Customers which expire before
+// expiresBefore and haven't been alerted on
+func (r *sqlDocumentRepository) unnotifiedExpiringDocuments(ctx context.Context, expiresBefore time.Time, limit int) ([]expiringDocument, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select documents.customer_id, customers.organization, documents.document_id, documents.type, documents.expires_at from documents
+inner join customers on customers.customer_id = documents.customer_id
+where customers.deleted_at is null and documents.deleted_at is null
+and documents.expires_at is not null and documents.expires_at < ? and documents.expiry_notified_at is null
+order by documents.expires_at asc, documents.document_id asc limit ?;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("unnotifiedExpiringDocuments: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, expiresBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("unnotifiedExpiringDocuments: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []expiringDocument
+	for rows.Next() {
+		var doc expiringDocument
+		if err := rows.Scan(&doc.CustomerID, &doc.Organization, &doc.DocumentID, &doc.Type, &doc.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("unnotifiedExpiringDocuments: scan: %v", err)
+		}
+		out = append(out, doc)
+	}
+	return out, rows.Err()
+}
+
+func (r *sqlDocumentRepository) markExpiryNotified(ctx context.Context, documentID string, notifiedAt time.Time) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `update documents set expiry_notified_at = ? where document_id = ? and expiry_notified_at is null;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("markExpiryNotified: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, notifiedAt, documentID); err != nil {
+		return fmt.Errorf("markExpiryNotified: exec: %v", err)
+	}
+	return nil
+}
@@ -5,12 +5,14 @@
 package documents
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/moov-io/base"
@@ -48,15 +50,15 @@ func TestDocumentRepository(t *testing.T) {
 			organization := "test"
 
 			// check empty docs
-			docs, err := documentRepo.getCustomerDocuments(base.ID(), organization)
+			docs, err := documentRepo.getCustomerDocuments(context.Background(), base.ID(), organization)
 			require.NoError(t, err)
 			require.Empty(t, docs)
 
 			// create test customer with organization
 			router := mux.NewRouter()
-			ssnStorage := customers.NewSSNStorage(secrets.TestStringKeeper(t), customers.NewCustomerSSNRepository(logger, tc.db))
+			ssnStorage := customers.NewSSNStorage(secrets.TestStringKeeper(t), customers.NewCustomerSSNRepository(logger, tc.db), "")
 			ofacSearcher := customers.NewOFACSearcher(customerRepo, &watchman.TestWatchmanClient{})
-			customers.AddCustomerRoutes(log.NewNopLogger(), router, customerRepo, ssnStorage, ofacSearcher)
+			customers.AddCustomerRoutes(log.NewNopLogger(), router, customerRepo, ssnStorage, ofacSearcher, nil, nil)
 			body := `{"firstName": "jane", "lastName": "doe", "email": "jane@example.com", "birthDate": "1991-04-01", "ssn": "123456789", "type": "individual"}`
 			req := httptest.NewRequest("POST", "/customers", strings.NewReader(body))
 
@@ -76,10 +78,10 @@ func TestDocumentRepository(t *testing.T) {
 				Type:        "DriversLicense",
 				ContentType: "image/png",
 			}
-			if err := documentRepo.writeCustomerDocument(cust.CustomerID, doc); err != nil {
+			if err := documentRepo.writeCustomerDocument(context.Background(), cust.CustomerID, doc); err != nil {
 				t.Fatal(err)
 			}
-			docs, err = documentRepo.getCustomerDocuments(cust.CustomerID, organization)
+			docs, err = documentRepo.getCustomerDocuments(context.Background(), cust.CustomerID, organization)
 			require.NoError(t, err)
 			require.Len(t, docs, 1)
 
@@ -87,7 +89,7 @@ func TestDocumentRepository(t *testing.T) {
 			require.Equal(t, "image/png", docs[0].ContentType)
 
 			// make sure we read the document
-			exists, err := documentRepo.exists(cust.CustomerID, doc.DocumentID, organization)
+			exists, err := documentRepo.exists(context.Background(), cust.CustomerID, doc.DocumentID, organization)
 			require.Equal(t, true, exists)
 			require.NoError(t, err)
 		})
@@ -111,7 +113,7 @@ func TestDocumentsRepository__Delete(t *testing.T) {
 			Type:        "DriversLicense",
 			ContentType: "image/png",
 		}
-		err := repo.writeCustomerDocument(customerID, doc)
+		err := repo.writeCustomerDocument(context.Background(), customerID, doc)
 		require.NoError(t, err)
 		docs[i] = &document{
 			Document: doc,
@@ -124,7 +126,7 @@ func TestDocumentsRepository__Delete(t *testing.T) {
 		require.Less(t, idx, len(docs))
 		docs[idx].deleted = true
 		require.NoError(t,
-			repo.deleteCustomerDocument(customerID, docs[idx].DocumentID),
+			repo.deleteCustomerDocument(context.Background(), customerID, docs[idx].DocumentID),
 		)
 	}
 
@@ -151,7 +153,61 @@ func TestDocumentsRepository__Delete(t *testing.T) {
 	}
 
 	// make sure we find the document as deleted
-	exists, err := repo.exists(customerID, docs[0].DocumentID, "")
+	exists, err := repo.exists(context.Background(), customerID, docs[0].DocumentID, "")
 	require.Equal(t, false, exists)
 	require.Equal(t, err, sql.ErrNoRows)
 }
+
+func TestDocumentsRepository__listCustomerDocuments(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	logger := log.NewNopLogger()
+	repo := &sqlDocumentRepository{db.DB, logger}
+
+	cust := &client.Customer{
+		CustomerID: base.ID(),
+		FirstName:  "Jane",
+		LastName:   "Doe",
+		Type:       client.CUSTOMERTYPE_INDIVIDUAL,
+	}
+	require.NoError(t, customers.NewCustomerRepo(logger, db.DB).CreateCustomer(context.Background(), cust, "test"))
+
+	// two Documents share an upload time to check the documentID breaks ties
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var ids []string
+	for i, uploadedAt := range []time.Time{start, start.Add(time.Minute), start.Add(time.Minute), start.Add(2 * time.Minute)} {
+		doc := &client.Document{DocumentID: base.ID(), Type: "passport", ContentType: "image/png", UploadedAt: uploadedAt}
+		if i == 3 {
+			doc.Type = "utilitybill"
+		}
+		require.NoError(t, repo.writeCustomerDocument(context.Background(), cust.CustomerID, doc))
+		ids = append(ids, doc.DocumentID)
+	}
+	if ids[1] > ids[2] {
+		ids[1], ids[2] = ids[2], ids[1]
+	}
+	require.NoError(t, repo.deleteCustomerDocument(context.Background(), cust.CustomerID, ids[0]))
+
+	read := func(filters documentFilters) []string {
+		docs, err := repo.listCustomerDocuments(context.Background(), cust.CustomerID, "test", filters)
+		require.NoError(t, err)
+
+		var out []string
+		for i := range docs {
+			out = append(out, docs[i].DocumentID)
+		}
+		return out
+	}
+
+	require.Equal(t, ids[1:3], read(documentFilters{Count: 2}))
+	require.Equal(t, ids[3:], read(documentFilters{Count: 2, After: ids[2]}))
+	require.Equal(t, ids[2:], read(documentFilters{Count: 2, After: ids[1]}))
+	require.Equal(t, ids[3:], read(documentFilters{Count: 10, Type: "utilitybill"}))
+	require.Equal(t, ids, read(documentFilters{Count: 10, IncludeDeleted: true}))
+
+	// other organizations can't list the Documents
+	docs, err := repo.listCustomerDocuments(context.Background(), cust.CustomerID, "other", documentFilters{Count: 10})
+	require.NoError(t, err)
+	require.Empty(t, docs)
+}
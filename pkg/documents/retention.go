@@ -0,0 +1,128 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"gocloud.dev/gcerrors"
+
+	"github.com/moov-io/customers/pkg/documents/storage"
+)
+
+var (
+	documentsPurged = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "documents_purged",
+		Help: "Counter of deleted Documents purged after the retention period by their type",
+	}, []string{"type"})
+)
+
+// RetentionConfig holds the settings for purging deleted Documents
+type RetentionConfig struct {
+	// Period is how long a deleted Document can be restored before its blob is purged.
+	// Deleted Documents are kept forever when Period is zero.
+	Period time.Duration
+
+	// Interval is how often deleted Documents are checked
+	Interval time.Duration
+
+	// BatchSize is how many Documents are read from the database at once
+	BatchSize int
+}
+
+// RetentionSweeper removes the blobs (and previews) of Documents deleted longer than the retention
+// period ago and marks them as purged. Once purged a Document can't be restored.
+//
+// Blobs are deleted before the Document is marked, and blobs which are already gone are skipped,
+// so a sweep interrupted part way is finished by the next one.
+type RetentionSweeper struct {
+	logger        log.Logger
+	repo          DocumentRepository
+	bucketFactory storage.BucketFunc
+	cfg           RetentionConfig
+}
+
+func NewRetentionSweeper(logger log.Logger, repo DocumentRepository, bucketFactory storage.BucketFunc, cfg RetentionConfig) *RetentionSweeper {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &RetentionSweeper{
+		logger:        logger.Set("package", log.String("documents")),
+		repo:          repo,
+		bucketFactory: bucketFactory,
+		cfg:           cfg,
+	}
+}
+
+// Start purges expired Documents every interval until ctx is cancelled. It returns right away
+// when no retention period is set.
+func (s *RetentionSweeper) Start(ctx context.Context) {
+	if s.cfg.Period <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.Sweep(ctx, time.Now()); err != nil {
+			s.logger.LogErrorf("problem purging deleted documents: %v", err)
+		} else if n > 0 {
+			s.logger.Logf("purged %d deleted documents", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep purges every Document deleted before now minus the retention period and returns how many were purged
+func (s *RetentionSweeper) Sweep(ctx context.Context, now time.Time) (int, error) {
+	if s.cfg.Period <= 0 {
+		return 0, nil
+	}
+	bucket, err := s.bucketFactory()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create bucket: %v", err)
+	}
+	defer bucket.Close()
+
+	purged := 0
+	for {
+		docs, err := s.repo.expiredDocuments(ctx, now.Add(-s.cfg.Period), s.cfg.BatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for i := range docs {
+			for _, key := range []string{makeDocumentKey(docs[i].CustomerID, docs[i].DocumentID), makePreviewKey(docs[i].CustomerID, docs[i].DocumentID)} {
+				if err := bucket.Delete(ctx, key); err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+					return purged, fmt.Errorf("deleting document %s: %v", key, err)
+				}
+			}
+			if err := s.repo.markDocumentPurged(ctx, docs[i].DocumentID, time.Now()); err != nil {
+				return purged, err
+			}
+			s.logger.With(log.Fields{
+				"customerID": log.String(docs[i].CustomerID),
+				"documentID": log.String(docs[i].DocumentID),
+				"deletedAt":  log.Time(docs[i].DeletedAt),
+			}).Log("purged deleted document")
+			documentsPurged.With("type", docs[i].Type).Add(1)
+			purged++
+		}
+		if len(docs) < s.cfg.BatchSize {
+			return purged, nil
+		}
+	}
+}
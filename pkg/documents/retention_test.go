@@ -0,0 +1,141 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+func TestDocuments__restore(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	cust := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customers.NewCustomerRepo(logger, db.DB).CreateCustomer(context.Background(), cust, "test"))
+
+	repo := NewDocumentRepo(logger, db.DB)
+	doc := &client.Document{DocumentID: base.ID(), Type: "passport", ContentType: "image/png", UploadedAt: time.Now()}
+	require.NoError(t, repo.writeCustomerDocument(context.Background(), cust.CustomerID, doc))
+
+	router := mux.NewRouter()
+	AddDocumentRoutes(logger, router, repo, secrets.TestKeeper(t), storage.NewTestBucket(t), nil, nil)
+
+	restore := func(organization string) int {
+		req := httptest.NewRequest("POST", fmt.Sprintf("/customers/%s/documents/%s/restore", cust.CustomerID, doc.DocumentID), nil)
+		req.Header.Set("X-Organization", organization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// only deleted documents are restored
+	require.Equal(t, http.StatusNotFound, restore("test"))
+
+	require.NoError(t, repo.deleteCustomerDocument(context.Background(), cust.CustomerID, doc.DocumentID))
+	exists, _ := repo.exists(context.Background(), cust.CustomerID, doc.DocumentID, "test")
+	require.False(t, exists)
+
+	require.Equal(t, http.StatusNotFound, restore("other"))
+	require.Equal(t, http.StatusNoContent, restore("test"))
+	exists, err := repo.exists(context.Background(), cust.CustomerID, doc.DocumentID, "test")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	// purged documents can't be restored
+	require.NoError(t, repo.deleteCustomerDocument(context.Background(), cust.CustomerID, doc.DocumentID))
+	require.NoError(t, repo.markDocumentPurged(context.Background(), doc.DocumentID, time.Now()))
+	require.Equal(t, http.StatusNotFound, restore("test"))
+}
+
+func TestRetentionSweeper(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	repo := NewDocumentRepo(logger, db.DB)
+	bucketFactory := storage.NewTestBucket(t)
+	bucket, err := bucketFactory()
+	require.NoError(t, err)
+	defer bucket.Close()
+
+	customerID := base.ID()
+	write := func(deletedAt *time.Time, preview bool) string {
+		doc := &client.Document{DocumentID: base.ID(), Type: "passport", ContentType: "image/png", UploadedAt: time.Now()}
+		require.NoError(t, repo.writeCustomerDocument(ctx, customerID, doc))
+		require.NoError(t, bucket.WriteAll(ctx, makeDocumentKey(customerID, doc.DocumentID), []byte("doc"), nil))
+		if preview {
+			require.NoError(t, bucket.WriteAll(ctx, makePreviewKey(customerID, doc.DocumentID), []byte("preview"), nil))
+		}
+		if deletedAt != nil {
+			_, err := db.DB.Exec(`update documents set deleted_at = ? where document_id = ?;`, *deletedAt, doc.DocumentID)
+			require.NoError(t, err)
+		}
+		return doc.DocumentID
+	}
+	stored := func(documentID string) bool {
+		exists, err := bucket.Exists(ctx, makeDocumentKey(customerID, documentID))
+		require.NoError(t, err)
+		return exists
+	}
+
+	now := time.Now()
+	old, recent := now.Add(-48*time.Hour), now.Add(-time.Hour)
+	expired := []string{write(&old, true), write(&old, false), write(&old, false)}
+	kept := write(&recent, false)
+	active := write(nil, false)
+
+	// nothing is purged without a retention period
+	sweeper := NewRetentionSweeper(logger, repo, bucketFactory, RetentionConfig{BatchSize: 2})
+	n, err := sweeper.Sweep(ctx, now)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	sweeper = NewRetentionSweeper(logger, repo, bucketFactory, RetentionConfig{Period: 24 * time.Hour, BatchSize: 2})
+	n, err = sweeper.Sweep(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	for _, documentID := range expired {
+		require.False(t, stored(documentID))
+	}
+	exists, err := bucket.Exists(ctx, makePreviewKey(customerID, expired[0]))
+	require.NoError(t, err)
+	require.False(t, exists)
+	require.True(t, stored(kept))
+	require.True(t, stored(active))
+
+	// sweeping again finds nothing
+	n, err = sweeper.Sweep(ctx, now)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// a document whose blob was already removed is still marked as purged
+	require.NoError(t, bucket.Delete(ctx, makeDocumentKey(customerID, kept)))
+	n, err = sweeper.Sweep(ctx, now.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	docs, err := repo.expiredDocuments(ctx, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Empty(t, docs)
+	require.True(t, stored(active))
+}
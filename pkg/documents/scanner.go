@@ -0,0 +1,332 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/admin"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+var (
+	documentScans = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "document_scans",
+		Help: "Counter of uploaded Documents scanned for viruses by their result",
+	}, []string{"result"})
+
+	errDocumentInfected = errors.New("document failed virus scan")
+)
+
+// Scan results recorded for each uploaded Document
+const (
+	ScanClean    = "clean"
+	ScanInfected = "infected"
+	ScanError    = "error"
+)
+
+// ScanResult is what a DocumentScanner found in a Document
+type ScanResult struct {
+	Infected bool
+
+	// Signature names the virus or malware which was found
+	Signature string
+}
+
+// DocumentScanner checks the contents of an uploaded Document for viruses and malware
+type DocumentScanner interface {
+	Scan(ctx context.Context, data []byte) (*ScanResult, error)
+}
+
+// DocumentScan is the recorded result of scanning one uploaded Document. Infected Documents are
+// never stored, so their DocumentID only identifies the scan.
+type DocumentScan struct {
+	ScanID       string    `json:"scanID"`
+	CustomerID   string    `json:"customerID"`
+	Organization string    `json:"organization"`
+	DocumentID   string    `json:"documentID"`
+	Type         string    `json:"type"`
+	ContentType  string    `json:"contentType"`
+	Size         int64     `json:"size"`
+	Result       string    `json:"result"`
+	Signature    string    `json:"signature,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	ScannedAt    time.Time `json:"scannedAt"`
+}
+
+// ScanConfig holds the settings for scanning uploaded Documents
+type ScanConfig struct {
+	// FailOpen stores Documents which couldn't be scanned, otherwise they're rejected
+	FailOpen bool
+}
+
+// Scanner scans each uploaded Document before it's stored and records the result.
+// A nil Scanner stores every Document without scanning it.
+type Scanner struct {
+	logger  log.Logger
+	scanner DocumentScanner
+	repo    ScanRepository
+	cfg     ScanConfig
+}
+
+func NewScanner(logger log.Logger, scanner DocumentScanner, repo ScanRepository, cfg ScanConfig) *Scanner {
+	return &Scanner{
+		logger:  logger.Set("package", log.String("documents")),
+		scanner: scanner,
+		repo:    repo,
+		cfg:     cfg,
+	}
+}
+
+// check returns an error when the Document is infected, or couldn't be scanned and the Scanner fails closed
+func (s *Scanner) check(ctx context.Context, customerID, organization string, doc *client.Document, data []byte) error {
+	if s == nil {
+		return nil
+	}
+	scan := &DocumentScan{
+		ScanID:       base.ID(),
+		CustomerID:   customerID,
+		Organization: organization,
+		DocumentID:   doc.DocumentID,
+		Type:         doc.Type,
+		ContentType:  doc.ContentType,
+		Size:         int64(len(data)),
+		Result:       ScanClean,
+	}
+	result, err := s.scanner.Scan(ctx, data)
+	switch {
+	case err != nil:
+		scan.Result, scan.Error = ScanError, err.Error()
+	case result != nil && result.Infected:
+		scan.Result, scan.Signature = ScanInfected, result.Signature
+	}
+	scan.ScannedAt = time.Now()
+	documentScans.With("result", scan.Result).Add(1)
+
+	logger := s.logger.With(log.Fields{
+		"customerID": log.String(customerID),
+		"documentID": log.String(doc.DocumentID),
+	})
+	if err := s.repo.recordScan(scan); err != nil {
+		logger.LogErrorf("problem recording document scan: %v", err)
+	}
+
+	switch scan.Result {
+	case ScanInfected:
+		logger.Logf("rejecting document infected with %s", scan.Signature)
+		return route.Validation(errDocumentInfected)
+	case ScanError:
+		if s.cfg.FailOpen {
+			logger.LogErrorf("storing unscanned document: %v", err)
+			return nil
+		}
+		logger.LogErrorf("rejecting unscanned document: %v", err)
+		return route.NewError(http.StatusServiceUnavailable, route.CodeUnavailable, "unable to scan document")
+	}
+	return nil
+}
+
+// ClamdScanner streams Documents to a clamd daemon with its INSTREAM command
+type ClamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// clamdChunkSize is how much of a Document is sent to clamd at once
+const clamdChunkSize = 64 << 10
+
+// NewClamdScanner returns a DocumentScanner for the clamd listening at address, which is either
+// "tcp://host:port" (or just "host:port") or "unix:///path/to/clamd.sock".
+func NewClamdScanner(address string, timeout time.Duration) (*ClamdScanner, error) {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	c := &ClamdScanner{network: "tcp", address: address, timeout: timeout}
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		c.network, c.address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		c.address = strings.TrimPrefix(address, "tcp://")
+	}
+	if c.address == "" {
+		return nil, errors.New("missing clamd address")
+	}
+	return c, nil
+}
+
+func (c *ClamdScanner) Scan(ctx context.Context, data []byte) (*ScanResult, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, c.timeout)
+	defer cancelFn()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("clamd: %v", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	for len(data) > 0 {
+		n := len(data)
+		if n > clamdChunkSize {
+			n = clamdChunkSize
+		}
+		binary.Write(w, binary.BigEndian, uint32(n))
+		w.Write(data[:n])
+		data = data[n:]
+	}
+	binary.Write(w, binary.BigEndian, uint32(0))
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("clamd: %v", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return nil, fmt.Errorf("clamd: reading reply: %v", err)
+	}
+	return parseClamdReply(reply)
+}
+
+// parseClamdReply reads replies like "stream: OK" or "stream: Eicar-Signature FOUND"
+func parseClamdReply(reply string) (*ScanResult, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case reply == "OK":
+		return &ScanResult{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &ScanResult{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	}
+	return nil, fmt.Errorf("clamd: %s", reply)
+}
+
+type ScanRepository interface {
+	recordScan(scan *DocumentScan) error
+	listScans(customerID string, limit int) ([]*DocumentScan, error)
+}
+
+func NewScanRepo(logger log.Logger, db *sql.DB) ScanRepository {
+	return &sqlScanRepository{logger: logger, db: db}
+}
+
+type sqlScanRepository struct {
+	logger log.Logger
+	db     *sql.DB
+}
+
+func (r *sqlScanRepository) recordScan(scan *DocumentScan) error {
+	query := `insert into document_scans (scan_id, customer_id, organization, document_id, type, content_type, size, result, signature, error, scanned_at)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("recordScan: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	errMsg := scan.Error
+	if len(errMsg) > 512 {
+		errMsg = errMsg[:512]
+	}
+	_, err = stmt.Exec(scan.ScanID, scan.CustomerID, scan.Organization, scan.DocumentID, scan.Type, scan.ContentType, scan.Size,
+		scan.Result, scan.Signature, errMsg, scan.ScannedAt)
+	if err != nil {
+		return fmt.Errorf("recordScan: exec: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlScanRepository) listScans(customerID string, limit int) ([]*DocumentScan, error) {
+	query := `select scan_id, customer_id, organization, document_id, type, content_type, size, result, signature, error, scanned_at
+from document_scans where customer_id = ? order by scanned_at desc limit ?;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("listScans: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(customerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listScans: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []*DocumentScan
+	for rows.Next() {
+		var s DocumentScan
+		err := rows.Scan(&s.ScanID, &s.CustomerID, &s.Organization, &s.DocumentID, &s.Type, &s.ContentType, &s.Size,
+			&s.Result, &s.Signature, &s.Error, &s.ScannedAt)
+		if err != nil {
+			return nil, fmt.Errorf("listScans: scan: %v", err)
+		}
+		out = append(out, &s)
+	}
+	return out, rows.Err()
+}
+
+// AddScanAdminRoutes registers the admin route for auditing a Customer's Document scans
+func AddScanAdminRoutes(logger log.Logger, svc *admin.Server, repo ScanRepository) {
+	logger = logger.Set("package", log.String("documents"))
+
+	svc.AddHandler("/customers/{customerID}/document-scans", listDocumentScans(logger, repo))
+}
+
+func listDocumentScans(logger log.Logger, repo ScanRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if r.Method != "GET" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		customerID := route.GetCustomerID(w, r)
+		if customerID == "" {
+			return
+		}
+
+		_, count, _, err := moovhttp.GetSkipAndCount(r)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+
+		scans, err := repo.listScans(customerID, count)
+		if err != nil {
+			logger.Set("customerID", log.String(customerID)).LogErrorf("problem reading document scans: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		if scans == nil {
+			scans = []*DocumentScan{}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(scans)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+type testDocumentScanner struct {
+	result *ScanResult
+	err    error
+}
+
+func (s *testDocumentScanner) Scan(ctx context.Context, data []byte) (*ScanResult, error) {
+	return s.result, s.err
+}
+
+// fakeClamd answers INSTREAM commands like clamd, finding "EICAR" in any stream which contains it
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var data bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+						return
+					}
+				}
+				if bytes.Contains(data.Bytes(), []byte("EICAR")) {
+					conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}(conn)
+		}
+	}()
+	return "tcp://" + ln.Addr().String()
+}
+
+func TestClamdScanner(t *testing.T) {
+	scanner, err := NewClamdScanner(fakeClamd(t), time.Second)
+	require.NoError(t, err)
+
+	result, err := scanner.Scan(context.Background(), bytes.Repeat([]byte("a"), 3*clamdChunkSize/2))
+	require.NoError(t, err)
+	require.False(t, result.Infected)
+
+	result, err = scanner.Scan(context.Background(), []byte("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*"))
+	require.NoError(t, err)
+	require.True(t, result.Infected)
+	require.Equal(t, "Eicar-Signature", result.Signature)
+
+	scanner, err = NewClamdScanner("unix:///tmp/customers-missing-clamd.sock", time.Second)
+	require.NoError(t, err)
+	_, err = scanner.Scan(context.Background(), []byte("data"))
+	require.Error(t, err)
+
+	_, err = NewClamdScanner("tcp://", time.Second)
+	require.Error(t, err)
+}
+
+func TestClamdScanner__parseReply(t *testing.T) {
+	result, err := parseClamdReply("stream: OK\x00")
+	require.NoError(t, err)
+	require.False(t, result.Infected)
+
+	result, err = parseClamdReply("stream: Win.Test.EICAR_HDB-1 FOUND")
+	require.NoError(t, err)
+	require.Equal(t, "Win.Test.EICAR_HDB-1", result.Signature)
+
+	_, err = parseClamdReply("INSTREAM size limit exceeded. ERROR")
+	require.Error(t, err)
+}
+
+func TestDocumentsUpload_scanned(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+	scans := NewScanRepo(log.NewNopLogger(), db.DB)
+
+	upload := func(scanner DocumentScanner, cfg ScanConfig) (*httptest.ResponseRecorder, *testDocumentRepository) {
+		repo := &testDocumentRepository{}
+		router := mux.NewRouter()
+		AddDocumentRoutes(log.NewNopLogger(), router, repo, secrets.TestKeeper(t), storage.NewTestBucket(t), NewScanner(log.NewNopLogger(), scanner, scans, cfg), nil)
+
+		req := multipartRequest(t)
+		req.Header.Set("X-Organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w, repo
+	}
+
+	w, repo := upload(&testDocumentScanner{result: &ScanResult{}}, ScanConfig{})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NotNil(t, repo.written)
+
+	// infected documents are never stored
+	w, repo = upload(&testDocumentScanner{result: &ScanResult{Infected: true, Signature: "Eicar-Signature"}}, ScanConfig{FailOpen: true})
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), errDocumentInfected.Error())
+	require.Nil(t, repo.written)
+
+	// unscanned documents are rejected unless the scanner fails open
+	w, repo = upload(&testDocumentScanner{err: errors.New("connection refused")}, ScanConfig{})
+	require.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+	require.Nil(t, repo.written)
+
+	w, repo = upload(&testDocumentScanner{err: errors.New("connection refused")}, ScanConfig{FailOpen: true})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NotNil(t, repo.written)
+
+	// every scan is recorded
+	w = httptest.NewRecorder()
+	listDocumentScans(log.NewNopLogger(), scans)(w, mux.SetURLVars(httptest.NewRequest("GET", "/customers/foo/document-scans", nil), map[string]string{"customerID": "foo"}))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var found []*DocumentScan
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&found))
+	require.Len(t, found, 4)
+	results := make(map[string]int)
+	for i := range found {
+		require.Equal(t, "test", found[i].Organization)
+		require.Equal(t, "image/jpeg", found[i].ContentType)
+		results[found[i].Result]++
+	}
+	require.Equal(t, map[string]int{ScanClean: 1, ScanInfected: 1, ScanError: 2}, results)
+}
@@ -13,7 +13,6 @@ import (
 	"os"
 	"time"
 
-	moovhttp "github.com/moov-io/base/http"
 	"github.com/moov-io/customers/pkg/route"
 
 	"github.com/gorilla/mux"
@@ -38,19 +37,20 @@ func AddFileblobRoutes(logger log.Logger, r *mux.Router, signer *fileblob.URLSig
 func proxyLocalFile(logger log.Logger, signer *fileblob.URLSignerHMAC, bucketFactory BucketFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 
 		ctx, cancelFn := context.WithTimeout(context.TODO(), 30*time.Second)
 		defer cancelFn()
 
 		key, err := signer.KeyFromURL(ctx, r.URL)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		bucket, err := bucketFactory()
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		defer bucket.Close()
@@ -58,7 +58,7 @@ func proxyLocalFile(logger log.Logger, signer *fileblob.URLSignerHMAC, bucketFac
 		// Grab the blob.Reader for proxying to endpoint
 		rdr, err := bucket.NewReader(ctx, key, nil)
 		if err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 		defer rdr.Close()
@@ -71,7 +71,7 @@ func proxyLocalFile(logger log.Logger, signer *fileblob.URLSignerHMAC, bucketFac
 		w.WriteHeader(http.StatusOK)
 
 		if n, err := io.Copy(w, rdr); err != nil || n == 0 {
-			moovhttp.Problem(w, fmt.Errorf("proxyLocalFile: n=%d error=%v", n, err))
+			route.Problem(w, fmt.Errorf("proxyLocalFile: n=%d error=%v", n, err))
 			return
 		}
 	}
@@ -0,0 +1,59 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moov-io/customers/pkg/model"
+)
+
+// Tax forms are Documents whose TIN type and certification date are recorded in their metadata
+const (
+	documentTypeW9    = "w9"
+	documentTypeW8BEN = "w8ben"
+
+	taxFormTINTypeKey     = "tinType"
+	taxFormCertifiedAtKey = "certifiedAt"
+)
+
+// taxFormTINTypes are the taxpayer identification numbers each tax form can certify. A W-9 is given
+// by US persons and a W-8BEN by foreign individuals, who may have a foreign TIN (ftin) instead.
+var taxFormTINTypes = map[string][]string{
+	documentTypeW9:    {"ssn", "ein", "itin"},
+	documentTypeW8BEN: {"ssn", "itin", "ftin"},
+}
+
+// validateTaxFormMetadata checks the TIN type and certification date of a tax form, when they're set,
+// and lowercases the TIN type. Other Documents and metadata keys aren't checked.
+func validateTaxFormMetadata(documentType string, meta map[string]string, now time.Time) error {
+	allowed, exists := taxFormTINTypes[documentType]
+	if !exists {
+		return nil
+	}
+	if v, exists := meta[taxFormTINTypeKey]; exists {
+		v = strings.ToLower(strings.TrimSpace(v))
+		found := false
+		for i := range allowed {
+			found = found || v == allowed[i]
+		}
+		if !found {
+			return fmt.Errorf("%s %q must be one of %s", taxFormTINTypeKey, meta[taxFormTINTypeKey], strings.Join(allowed, ", "))
+		}
+		meta[taxFormTINTypeKey] = v
+	}
+	if v, exists := meta[taxFormCertifiedAtKey]; exists {
+		certified, err := time.Parse(model.YYYYMMDD_Format, v)
+		if err != nil {
+			return fmt.Errorf("%s %q is not a YYYY-MM-DD date", taxFormCertifiedAtKey, v)
+		}
+		if certified.After(now.UTC().Truncate(24 * time.Hour)) {
+			return fmt.Errorf("%s %s is in the future", taxFormCertifiedAtKey, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+)
+
+func TestTaxForms__validateTaxFormMetadata(t *testing.T) {
+	now := time.Date(2020, time.June, 1, 14, 0, 0, 0, time.UTC)
+
+	meta := map[string]string{"tinType": " SSN ", "certifiedAt": "2020-06-01", "other": "value"}
+	require.NoError(t, validateTaxFormMetadata("w9", meta, now))
+	require.Equal(t, "ssn", meta["tinType"])
+	require.NoError(t, validateTaxFormMetadata("w8ben", map[string]string{"tinType": "ftin"}, now))
+
+	require.Error(t, validateTaxFormMetadata("w9", map[string]string{"tinType": "ftin"}, now))
+	require.Error(t, validateTaxFormMetadata("w8ben", map[string]string{"tinType": "ein"}, now))
+	require.Error(t, validateTaxFormMetadata("w9", map[string]string{"certifiedAt": "06/01/2020"}, now))
+	require.Error(t, validateTaxFormMetadata("w9", map[string]string{"certifiedAt": "2020-06-02"}, now))
+
+	// other Documents can use the same keys
+	require.NoError(t, validateTaxFormMetadata("passport", map[string]string{"tinType": "other"}, now))
+}
+
+func TestTaxForms__replaceMetadata(t *testing.T) {
+	require.Equal(t, "w8ben", mustReadDocumentType(t, "W8BEN"))
+
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	repo := NewDocumentRepo(logger, db.DB)
+	cust := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customers.NewCustomerRepo(logger, db.DB).CreateCustomer(context.Background(), cust, "test"))
+	doc := &client.Document{DocumentID: base.ID(), Type: mustReadDocumentType(t, "w9"), ContentType: "application/pdf"}
+	require.NoError(t, repo.writeCustomerDocument(context.Background(), cust.CustomerID, doc))
+
+	router := mux.NewRouter()
+	AddDocumentRoutes(logger, router, repo, nil, nil, nil, nil)
+
+	replace := func(body string) (*httptest.ResponseRecorder, client.DocumentMetadata) {
+		req := httptest.NewRequest("PUT", fmt.Sprintf("/customers/%s/documents/%s/metadata", cust.CustomerID, doc.DocumentID), strings.NewReader(body))
+		req.Header.Set("X-Organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var meta client.DocumentMetadata
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&meta))
+		}
+		return w, meta
+	}
+
+	w, meta := replace(`{"metadata": {"tinType": "EIN", "certifiedAt": "2020-01-02"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, map[string]string{"tinType": "ein", "certifiedAt": "2020-01-02"}, meta.Metadata)
+
+	w, _ = replace(`{"metadata": {"tinType": "ftin"}}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), "must be one of ssn, ein, itin")
+}
+
+func mustReadDocumentType(t *testing.T, v string) string {
+	t.Helper()
+	documentType, err := readDocumentType(v)
+	require.NoError(t, err)
+	return documentType
+}
@@ -0,0 +1,667 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"gocloud.dev/secrets"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+// uploadOffsetHeader is the number of bytes an upload has received (in responses) and where a chunk starts (in requests)
+const uploadOffsetHeader = "Upload-Offset"
+
+var (
+	uploadsAbandoned = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "document_uploads_abandoned",
+		Help: "Counter of partial Document uploads removed after they were abandoned",
+	}, nil)
+
+	errUploadOffsetMoved = route.Conflict(errors.New("another chunk was written at this offset, read the upload to resume"))
+)
+
+// DocumentUpload is a Document uploaded in chunks, which lets large files be sent over unreliable
+// connections. After a failed chunk clients read the upload and resume from its Offset. Completing
+// the upload assembles its chunks into a Document.
+type DocumentUpload struct {
+	UploadID   string     `json:"uploadID"`
+	CustomerID string     `json:"customerID"`
+	Type       string     `json:"type"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	Offset     int64      `json:"offset"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+type uploadChunk struct {
+	Offset    int64
+	Size      int64
+	BlobKey   string
+	CreatedAt time.Time
+}
+
+func AddUploadRoutes(logger log.Logger, r *mux.Router, docs DocumentRepository, uploads UploadRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc, scanner *Scanner, notifier webhooks.Notifier) {
+	logger = logger.Set("package", log.String("documents"))
+
+	r.Methods("POST").Path("/customers/{customerID}/documents/uploads").HandlerFunc(createUpload(logger, uploads))
+	r.Methods("GET").Path("/customers/{customerID}/documents/uploads/{uploadID}").HandlerFunc(getUpload(logger, uploads))
+	r.Methods("PATCH").Path("/customers/{customerID}/documents/uploads/{uploadID}").HandlerFunc(appendUpload(logger, uploads, keeper, bucketFactory))
+	r.Methods("POST").Path("/customers/{customerID}/documents/uploads/{uploadID}/complete").HandlerFunc(completeUpload(logger, docs, uploads, keeper, bucketFactory, scanner, notifier))
+	r.Methods("DELETE").Path("/customers/{customerID}/documents/uploads/{uploadID}").HandlerFunc(abortUpload(logger, uploads, bucketFactory))
+}
+
+// makeUploadChunkKey includes a random suffix so a chunk which loses a race for its offset never
+// overwrites the chunk which won
+func makeUploadChunkKey(customerID, uploadID string, offset int64) string {
+	return path.Join(makeUploadPrefix(customerID, uploadID), fmt.Sprintf("%012d-%s", offset, base.ID()))
+}
+
+func makeUploadPrefix(customerID, uploadID string) string {
+	return path.Join("customers", customerID, "uploads", uploadID) + "/"
+}
+
+func writeUpload(w http.ResponseWriter, upload *DocumentUpload) {
+	w.Header().Set(uploadOffsetHeader, fmt.Sprintf("%d", upload.Offset))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(upload)
+}
+
+// readUpload returns the upload named in the request, or nil after responding when it's missing
+func readUpload(w http.ResponseWriter, r *http.Request, logger log.Logger, repo UploadRepository) *DocumentUpload {
+	customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+	if customerID == "" || organization == "" {
+		return nil
+	}
+	uploadID := mux.Vars(r)["uploadID"]
+
+	upload, err := repo.getUpload(customerID, uploadID, organization)
+	if err != nil {
+		logger.Set("uploadID", log.String(uploadID)).LogErrorf("failed to read upload: %v", err)
+		route.Problem(w, err)
+		return nil
+	}
+	if upload == nil {
+		route.NotFound(w, r)
+		return nil
+	}
+	return upload
+}
+
+func createUpload(logger log.Logger, repo UploadRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+
+		documentType, err := readDocumentType(r.URL.Query().Get("type"))
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+		expiresAt, err := readExpiresAt(r.URL.Query().Get("expiresAt"))
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+
+		if exists, err := repo.customerExists(customerID, organization); !exists || err != nil {
+			if err != nil {
+				logger.LogErrorf("failed to check customer existence: %v", err)
+			}
+			route.NotFound(w, r)
+			return
+		}
+
+		now := time.Now()
+		upload := &DocumentUpload{
+			UploadID:   base.ID(),
+			CustomerID: customerID,
+			Type:       documentType,
+			ExpiresAt:  expiresAt,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if err := repo.createUpload(upload, organization); err != nil {
+			logger.LogErrorf("failed to create upload: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		logger.Logf("started document upload=%s", upload.UploadID)
+
+		writeUpload(w, upload)
+	}
+}
+
+func getUpload(logger log.Logger, repo UploadRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if upload := readUpload(w, r, logger, repo); upload != nil {
+			writeUpload(w, upload)
+		}
+	}
+}
+
+// appendUpload writes the request body as the chunk starting at the Upload-Offset header, which must
+// match the bytes received so far. Chunks are encrypted like Documents.
+func appendUpload(logger log.Logger, repo UploadRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		upload := readUpload(w, r, logger, repo)
+		if upload == nil {
+			return
+		}
+		logger = logger.With(log.Fields{
+			"customerID": log.String(upload.CustomerID),
+			"uploadID":   log.String(upload.UploadID),
+		})
+
+		offset, err := strconv.ParseInt(r.Header.Get(uploadOffsetHeader), 10, 64)
+		if err != nil || offset < 0 {
+			route.Problem(w, route.Validation(fmt.Errorf("missing or invalid %s header", uploadOffsetHeader)))
+			return
+		}
+		if offset != upload.Offset {
+			w.Header().Set(uploadOffsetHeader, fmt.Sprintf("%d", upload.Offset))
+			route.Problem(w, route.Conflict(fmt.Errorf("upload has received %d bytes, not %d", upload.Offset, offset)))
+			return
+		}
+
+		remaining := int64(maxDocumentSize) - upload.Offset
+		chunk, err := ioutil.ReadAll(io.LimitReader(r.Body, remaining+1))
+		if err != nil {
+			logger.LogErrorf("read failed: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		if len(chunk) == 0 {
+			route.Problem(w, route.Validation(errors.New("chunk is empty")))
+			return
+		}
+		if int64(len(chunk)) > remaining {
+			route.Problem(w, route.Validation(fmt.Errorf("upload exceeds maximum size of %s", maxDocumentSize)))
+			return
+		}
+
+		bucket, err := bucketFactory()
+		if err != nil {
+			logger.LogErrorf("failed to create bucket: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		defer bucket.Close()
+
+		ctx, cancelFn := context.WithTimeout(context.TODO(), 60*time.Second)
+		defer cancelFn()
+
+		encrypted, err := keeper.Encrypt(ctx, chunk)
+		if err != nil {
+			logger.LogErrorf("failed to encrypt chunk: %v", err)
+			route.Problem(w, fmt.Errorf("file upload error - %v", err))
+			return
+		}
+		key := makeUploadChunkKey(upload.CustomerID, upload.UploadID, offset)
+		if err := bucket.WriteAll(ctx, key, encrypted, nil); err != nil {
+			logger.LogErrorf("problem writing chunk: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		now := time.Now()
+		err = repo.appendChunk(upload.UploadID, uploadChunk{
+			Offset:    offset,
+			Size:      int64(len(chunk)),
+			BlobKey:   key,
+			CreatedAt: now,
+		})
+		if err != nil {
+			if err := bucket.Delete(ctx, key); err != nil {
+				logger.LogErrorf("problem removing unused chunk: %v", err)
+			}
+			route.Problem(w, err)
+			return
+		}
+
+		upload.Offset += int64(len(chunk))
+		upload.UpdatedAt = now
+		writeUpload(w, upload)
+	}
+}
+
+// completeUpload assembles the upload's chunks into a Document, checking its content type and size
+// like a regular upload, and removes the upload.
+func completeUpload(logger log.Logger, docs DocumentRepository, repo UploadRepository, keeper *secrets.Keeper, bucketFactory storage.BucketFunc, scanner *Scanner, notifier webhooks.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		upload := readUpload(w, r, logger, repo)
+		if upload == nil {
+			return
+		}
+		logger = logger.With(log.Fields{
+			"customerID": log.String(upload.CustomerID),
+			"uploadID":   log.String(upload.UploadID),
+		})
+
+		chunks, err := repo.getChunks(upload.UploadID)
+		if err != nil {
+			logger.LogErrorf("failed to read chunks: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		if len(chunks) == 0 {
+			route.Problem(w, route.Validation(errors.New("upload is empty")))
+			return
+		}
+
+		bucket, err := bucketFactory()
+		if err != nil {
+			logger.LogErrorf("failed to create bucket: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		defer bucket.Close()
+
+		ctx, cancelFn := context.WithTimeout(context.TODO(), 60*time.Second)
+		defer cancelFn()
+
+		var buf bytes.Buffer
+		for i := range chunks {
+			if chunks[i].Offset != int64(buf.Len()) {
+				logger.LogErrorf("chunk at offset %d follows %d bytes", chunks[i].Offset, buf.Len())
+				route.Problem(w, fmt.Errorf("upload is missing bytes at offset %d", buf.Len()))
+				return
+			}
+			data, err := readChunk(ctx, keeper, bucket, chunks[i])
+			if err != nil {
+				logger.LogErrorf("problem reading chunk: %v", err)
+				route.Problem(w, err)
+				return
+			}
+			buf.Write(data)
+		}
+		contents := buf.Bytes()
+
+		contentType, err := checkContentType(http.DetectContentType(contents), "")
+		if err != nil {
+			logger.LogErrorf("rejecting upload: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		doc := &client.Document{
+			DocumentID:  base.ID(),
+			Type:        upload.Type,
+			ContentType: contentType,
+			UploadedAt:  time.Now(),
+			ExpiresAt:   upload.ExpiresAt,
+			Expired:     expired(upload.ExpiresAt),
+		}
+		organization := r.Header.Get("X-Organization")
+		if err := storeDocument(r.Context(), logger, docs, scanner, keeper, bucket, upload.CustomerID, organization, doc, contents); err != nil {
+			route.Problem(w, err)
+			return
+		}
+		webhooks.NotifyDocument(notifier, webhooks.DocumentUploaded, upload.CustomerID, organization, doc.DocumentID, doc.Type)
+
+		// Remove the upload so it can't be completed twice, its chunks are cleaned up afterwards
+		if err := repo.deleteUpload(upload.UploadID); err != nil {
+			logger.LogErrorf("problem removing completed upload: %v", err)
+		} else if err := deleteUploadBlobs(ctx, bucket, upload); err != nil {
+			logger.LogErrorf("problem removing chunks: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(doc)
+	}
+}
+
+func readChunk(ctx context.Context, keeper *secrets.Keeper, bucket *blob.Bucket, chunk uploadChunk) ([]byte, error) {
+	encrypted, err := bucket.ReadAll(ctx, chunk.BlobKey)
+	if err != nil {
+		return nil, fmt.Errorf("reading chunk at offset %d: %v", chunk.Offset, err)
+	}
+	data, err := keeper.Decrypt(ctx, encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting chunk at offset %d: %v", chunk.Offset, err)
+	}
+	if int64(len(data)) != chunk.Size {
+		return nil, fmt.Errorf("chunk at offset %d has %d bytes, expected %d", chunk.Offset, len(data), chunk.Size)
+	}
+	return data, nil
+}
+
+func abortUpload(logger log.Logger, repo UploadRepository, bucketFactory storage.BucketFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		upload := readUpload(w, r, logger, repo)
+		if upload == nil {
+			return
+		}
+		logger = logger.With(log.Fields{
+			"customerID": log.String(upload.CustomerID),
+			"uploadID":   log.String(upload.UploadID),
+		})
+
+		if err := repo.deleteUpload(upload.UploadID); err != nil {
+			logger.LogErrorf("problem removing upload: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		bucket, err := bucketFactory()
+		if err != nil {
+			logger.LogErrorf("failed to create bucket: %v", err)
+		} else {
+			defer bucket.Close()
+
+			ctx, cancelFn := context.WithTimeout(context.TODO(), 60*time.Second)
+			defer cancelFn()
+
+			if err := deleteUploadBlobs(ctx, bucket, upload); err != nil {
+				logger.LogErrorf("problem removing chunks: %v", err)
+			}
+		}
+		logger.Log("aborted document upload")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func deleteUploadBlobs(ctx context.Context, bucket *blob.Bucket, upload *DocumentUpload) error {
+	iter := bucket.List(&blob.ListOptions{
+		Prefix: makeUploadPrefix(upload.CustomerID, upload.UploadID),
+	})
+	for {
+		obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("listing chunks of upload=%s: %v", upload.UploadID, err)
+		}
+		if err := bucket.Delete(ctx, obj.Key); err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+			return fmt.Errorf("deleting chunk %s: %v", obj.Key, err)
+		}
+	}
+}
+
+// UploadConfig holds the settings for removing abandoned uploads
+type UploadConfig struct {
+	// Expiration is how long an upload can go without receiving a chunk before it's removed
+	Expiration time.Duration
+
+	// Interval is how often abandoned uploads are checked
+	Interval time.Duration
+
+	// BatchSize is how many uploads are read from the database at once
+	BatchSize int
+}
+
+// UploadSweeper removes uploads which haven't received a chunk within the expiration, along with
+// their chunks. Chunks are deleted before the upload so an interrupted sweep is finished by the next one.
+type UploadSweeper struct {
+	logger        log.Logger
+	repo          UploadRepository
+	bucketFactory storage.BucketFunc
+	cfg           UploadConfig
+}
+
+func NewUploadSweeper(logger log.Logger, repo UploadRepository, bucketFactory storage.BucketFunc, cfg UploadConfig) *UploadSweeper {
+	if cfg.Expiration <= 0 {
+		cfg.Expiration = 24 * time.Hour
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &UploadSweeper{
+		logger:        logger.Set("package", log.String("documents")),
+		repo:          repo,
+		bucketFactory: bucketFactory,
+		cfg:           cfg,
+	}
+}
+
+// Start removes abandoned uploads every interval until ctx is cancelled
+func (s *UploadSweeper) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.Sweep(ctx, time.Now()); err != nil {
+			s.logger.LogErrorf("problem removing abandoned uploads: %v", err)
+		} else if n > 0 {
+			s.logger.Logf("removed %d abandoned uploads", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep removes every upload last changed before now minus the expiration and returns how many were removed
+func (s *UploadSweeper) Sweep(ctx context.Context, now time.Time) (int, error) {
+	bucket, err := s.bucketFactory()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create bucket: %v", err)
+	}
+	defer bucket.Close()
+
+	removed := 0
+	for {
+		uploads, err := s.repo.abandonedUploads(now.Add(-s.cfg.Expiration), s.cfg.BatchSize)
+		if err != nil {
+			return removed, err
+		}
+		for i := range uploads {
+			if err := deleteUploadBlobs(ctx, bucket, uploads[i]); err != nil {
+				return removed, err
+			}
+			if err := s.repo.deleteUpload(uploads[i].UploadID); err != nil {
+				return removed, err
+			}
+			s.logger.With(log.Fields{
+				"customerID": log.String(uploads[i].CustomerID),
+				"uploadID":   log.String(uploads[i].UploadID),
+				"updatedAt":  log.Time(uploads[i].UpdatedAt),
+			}).Log("removed abandoned upload")
+			uploadsAbandoned.Add(1)
+			removed++
+		}
+		if len(uploads) < s.cfg.BatchSize {
+			return removed, nil
+		}
+	}
+}
+
+type UploadRepository interface {
+	customerExists(customerID, organization string) (bool, error)
+
+	createUpload(upload *DocumentUpload, organization string) error
+	getUpload(customerID, uploadID, organization string) (*DocumentUpload, error)
+	deleteUpload(uploadID string) error
+	abandonedUploads(updatedBefore time.Time, limit int) ([]*DocumentUpload, error)
+
+	appendChunk(uploadID string, chunk uploadChunk) error
+	getChunks(uploadID string) ([]uploadChunk, error)
+}
+
+func NewUploadRepo(logger log.Logger, db *sql.DB) UploadRepository {
+	return &sqlUploadRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+type sqlUploadRepository struct {
+	db     *sql.DB
+	logger log.Logger
+}
+
+func (r *sqlUploadRepository) customerExists(customerID, organization string) (bool, error) {
+	return customerExists(r.db, customerID, organization)
+}
+
+func (r *sqlUploadRepository) createUpload(upload *DocumentUpload, organization string) error {
+	query := `insert into document_uploads (upload_id, customer_id, organization, type, expires_at, size, created_at, updated_at) values (?, ?, ?, ?, ?, ?, ?, ?);`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("createUpload: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(upload.UploadID, upload.CustomerID, organization, upload.Type, upload.ExpiresAt, upload.Offset, upload.CreatedAt, upload.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("createUpload: exec: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlUploadRepository) getUpload(customerID, uploadID, organization string) (*DocumentUpload, error) {
+	query := `select upload_id, customer_id, type, expires_at, size, created_at, updated_at from document_uploads
+where customer_id = ? and upload_id = ? and organization = ? limit 1;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("getUpload: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	var upload DocumentUpload
+	err = stmt.QueryRow(customerID, uploadID, organization).Scan(&upload.UploadID, &upload.CustomerID, &upload.Type, &upload.ExpiresAt, &upload.Offset, &upload.CreatedAt, &upload.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getUpload: scan: %v", err)
+	}
+	return &upload, nil
+}
+
+func (r *sqlUploadRepository) deleteUpload(uploadID string) error {
+	return customersdb.RetryOnLock(r.db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`delete from document_upload_chunks where upload_id = ?;`, uploadID); err != nil {
+			return fmt.Errorf("deleteUpload: chunks: %v", err)
+		}
+		if _, err := tx.Exec(`delete from document_uploads where upload_id = ?;`, uploadID); err != nil {
+			return fmt.Errorf("deleteUpload: %v", err)
+		}
+		return nil
+	})
+}
+
+func (r *sqlUploadRepository) abandonedUploads(updatedBefore time.Time, limit int) ([]*DocumentUpload, error) {
+	query := `select upload_id, customer_id, type, expires_at, size, created_at, updated_at from document_uploads
+where updated_at < ? order by updated_at asc limit ?;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("abandonedUploads: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(updatedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("abandonedUploads: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []*DocumentUpload
+	for rows.Next() {
+		var upload DocumentUpload
+		if err := rows.Scan(&upload.UploadID, &upload.CustomerID, &upload.Type, &upload.ExpiresAt, &upload.Offset, &upload.CreatedAt, &upload.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("abandonedUploads: scan: %v", err)
+		}
+		out = append(out, &upload)
+	}
+	return out, rows.Err()
+}
+
+// appendChunk records chunk and moves the upload's offset past it, as long as no other chunk was
+// written at the same offset first
+func (r *sqlUploadRepository) appendChunk(uploadID string, chunk uploadChunk) error {
+	return customersdb.RetryOnLock(r.db, func(tx *sql.Tx) error {
+		query := `update document_uploads set size = size + ?, updated_at = ? where upload_id = ? and size = ?;`
+		res, err := tx.Exec(query, chunk.Size, chunk.CreatedAt, uploadID, chunk.Offset)
+		if err != nil {
+			return fmt.Errorf("appendChunk: update: %v", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errUploadOffsetMoved
+		}
+
+		query = `insert into document_upload_chunks (upload_id, byte_offset, size, blob_key, created_at) values (?, ?, ?, ?, ?);`
+		if _, err := tx.Exec(query, uploadID, chunk.Offset, chunk.Size, chunk.BlobKey, chunk.CreatedAt); err != nil {
+			return fmt.Errorf("appendChunk: insert: %v", err)
+		}
+		return nil
+	})
+}
+
+func (r *sqlUploadRepository) getChunks(uploadID string) ([]uploadChunk, error) {
+	query := `select byte_offset, size, blob_key, created_at from document_upload_chunks where upload_id = ? order by byte_offset asc;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("getChunks: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("getChunks: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []uploadChunk
+	for rows.Next() {
+		var chunk uploadChunk
+		if err := rows.Scan(&chunk.Offset, &chunk.Size, &chunk.BlobKey, &chunk.CreatedAt); err != nil {
+			return nil, fmt.Errorf("getChunks: scan: %v", err)
+		}
+		out = append(out, chunk)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,232 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package documents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/secrets"
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+type testDocumentNotifier struct {
+	documentIDs []string
+}
+
+func (n *testDocumentNotifier) Notify(eventType webhooks.EventType, customerID, organization, status string) {
+}
+
+func (n *testDocumentNotifier) NotifyDocument(eventType webhooks.EventType, customerID, organization, documentID, documentType string) {
+	if eventType == webhooks.DocumentUploaded && organization == "test" && documentType != "" {
+		n.documentIDs = append(n.documentIDs, documentID)
+	}
+}
+
+type uploadTest struct {
+	t             *testing.T
+	router        *mux.Router
+	repo          UploadRepository
+	bucketFactory storage.BucketFunc
+	notifier      *testDocumentNotifier
+	customerID    string
+}
+
+func setupUploadTest(t *testing.T) *uploadTest {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	cust := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customers.NewCustomerRepo(logger, db.DB).CreateCustomer(context.Background(), cust, "test"))
+
+	test := &uploadTest{
+		t:             t,
+		router:        mux.NewRouter(),
+		repo:          NewUploadRepo(logger, db.DB),
+		bucketFactory: storage.NewTestBucket(t),
+		notifier:      &testDocumentNotifier{},
+		customerID:    cust.CustomerID,
+	}
+	keeper := secrets.TestKeeper(t)
+	docs := NewDocumentRepo(logger, db.DB)
+	AddUploadRoutes(logger, test.router, docs, test.repo, keeper, test.bucketFactory, nil, test.notifier)
+	AddDocumentRoutes(logger, test.router, docs, keeper, test.bucketFactory, nil, test.notifier)
+	return test
+}
+
+func (test *uploadTest) send(method, path string, offset int64, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, fmt.Sprintf("/customers/%s%s", test.customerID, path), bytes.NewReader(body))
+	req.Header.Set("X-Organization", "test")
+	if offset >= 0 {
+		req.Header.Set(uploadOffsetHeader, fmt.Sprintf("%d", offset))
+	}
+	w := httptest.NewRecorder()
+	test.router.ServeHTTP(w, req)
+	return w
+}
+
+func (test *uploadTest) start() *DocumentUpload {
+	w := test.send("POST", "/documents/uploads?type=DriversLicense&expiresAt=2030-01-01", -1, nil)
+	require.Equal(test.t, http.StatusOK, w.Code, w.Body.String())
+
+	var upload DocumentUpload
+	require.NoError(test.t, json.NewDecoder(w.Body).Decode(&upload))
+	return &upload
+}
+
+func (test *uploadTest) chunkKeys(uploadID string) []string {
+	bucket, err := test.bucketFactory()
+	require.NoError(test.t, err)
+	defer bucket.Close()
+
+	var keys []string
+	iter := bucket.List(&blob.ListOptions{Prefix: makeUploadPrefix(test.customerID, uploadID)})
+	for {
+		obj, err := iter.Next(context.Background())
+		if err != nil {
+			return keys
+		}
+		keys = append(keys, obj.Key)
+	}
+}
+
+func TestDocumentUploads(t *testing.T) {
+	test := setupUploadTest(t)
+
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "colorado.jpg"))
+	require.NoError(t, err)
+
+	upload := test.start()
+	require.Equal(t, "driverslicense", upload.Type)
+	require.Zero(t, upload.Offset)
+	path := "/documents/uploads/" + upload.UploadID
+
+	// send the first two thirds, then resume after a chunk is sent twice
+	third := int64(len(contents) / 3)
+	w := test.send("PATCH", path, 0, contents[:third])
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = test.send("PATCH", path, third, contents[third:2*third])
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, fmt.Sprintf("%d", 2*third), w.Header().Get(uploadOffsetHeader))
+
+	w = test.send("PATCH", path, third, contents[third:2*third])
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	require.Equal(t, fmt.Sprintf("%d", 2*third), w.Header().Get(uploadOffsetHeader))
+
+	w = test.send("GET", path, -1, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var found DocumentUpload
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&found))
+	require.Equal(t, 2*third, found.Offset)
+
+	w = test.send("PATCH", path, found.Offset, contents[found.Offset:])
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, test.chunkKeys(upload.UploadID), 3)
+	require.Empty(t, test.notifier.documentIDs)
+
+	// completing assembles the chunks into a Document
+	w = test.send("POST", path+"/complete", -1, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var doc client.Document
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&doc))
+	require.Equal(t, "image/jpeg", doc.ContentType)
+	require.Equal(t, "driverslicense", doc.Type)
+	require.NotNil(t, doc.ExpiresAt)
+	require.Equal(t, []string{doc.DocumentID}, test.notifier.documentIDs)
+
+	w = test.send("GET", "/documents/"+doc.DocumentID, -1, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, contents, w.Body.Bytes())
+
+	// the upload and its chunks are removed
+	w = test.send("POST", path+"/complete", -1, nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Empty(t, test.chunkKeys(upload.UploadID))
+}
+
+func TestDocumentUploads__errors(t *testing.T) {
+	test := setupUploadTest(t)
+
+	w := test.send("POST", "/documents/uploads?type=selfie", -1, nil)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	req := httptest.NewRequest("POST", fmt.Sprintf("/customers/%s/documents/uploads?type=passport", test.customerID), nil)
+	req.Header.Set("X-Organization", "other")
+	w = httptest.NewRecorder()
+	test.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	upload := test.start()
+	path := "/documents/uploads/" + upload.UploadID
+
+	w = test.send("PATCH", path, -1, []byte("data"))
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	w = test.send("PATCH", path, 0, nil)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	w = test.send("POST", path+"/complete", -1, nil)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	// uploads can't grow past the maximum Document size
+	defer func(limit sizeLimit) { maxDocumentSize = limit }(maxDocumentSize)
+	maxDocumentSize = 8
+	w = test.send("PATCH", path, 0, []byte("12345"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = test.send("PATCH", path, 5, []byte("6789"))
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	// plain text isn't an allowed Document
+	w = test.send("POST", path+"/complete", -1, nil)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	require.Empty(t, test.notifier.documentIDs)
+
+	// aborting removes the upload and its chunks
+	w = test.send("DELETE", path, -1, nil)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	w = test.send("GET", path, -1, nil)
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	require.Empty(t, test.chunkKeys(upload.UploadID))
+}
+
+func TestDocumentUploads__sweeper(t *testing.T) {
+	test := setupUploadTest(t)
+
+	upload := test.start()
+	w := test.send("PATCH", "/documents/uploads/"+upload.UploadID, 0, []byte("partial"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	sweeper := NewUploadSweeper(log.NewNopLogger(), test.repo, test.bucketFactory, UploadConfig{Expiration: time.Hour, BatchSize: 1})
+
+	removed, err := sweeper.Sweep(context.Background(), time.Now())
+	require.NoError(t, err)
+	require.Zero(t, removed)
+
+	removed, err = sweeper.Sweep(context.Background(), time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+
+	found, err := test.repo.getUpload(test.customerID, upload.UploadID, "test")
+	require.NoError(t, err)
+	require.Nil(t, found)
+	require.Empty(t, test.chunkKeys(upload.UploadID))
+}
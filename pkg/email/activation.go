@@ -0,0 +1,296 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+var (
+	errInvalidActivationCode = errors.New("invalid or expired activation code")
+	errEmailNotFound         = route.NewError(http.StatusNotFound, route.CodeNotFound, "email not found")
+	errTooManyResends        = route.NewError(http.StatusTooManyRequests, route.CodeTooManyRequests, "too many activation codes resent, try again later")
+)
+
+// resendWindow is the period ActivatorConfig.MaxResends applies to
+const resendWindow = time.Hour
+
+// activationCode is a one-time code emailed to a Customer to confirm they own the address.
+// Only a hash of the code is stored.
+type activationCode struct {
+	CodeID       string
+	CustomerID   string
+	Organization string
+	EmailID      string
+	Email        string
+	CodeHash     string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+
+	// Resent is true for codes sent on request rather than when the Customer was created
+	Resent bool
+}
+
+// ActivatorConfig limits how long activation codes are valid and how often they're resent
+type ActivatorConfig struct {
+	// TTL is how long a code can be submitted, it defaults to 24 hours.
+	TTL time.Duration
+
+	// MaxResends is how many codes can be resent to an email address each hour before requests
+	// are refused. It defaults to 3.
+	MaxResends int
+
+	// Templates render the activation email, DefaultTemplates are used when it's nil.
+	Templates Templates
+
+	// LinkURL is where the ActivationLink template variable points, with the customerID, emailID and
+	// code added to its query. ActivationLink is empty when it isn't set.
+	LinkURL string
+
+	// Secret keys the HMAC of each stored code
+	Secret string
+}
+
+// Activator issues activation codes for a Customer's email addresses and queues the email containing
+// them. It implements webhooks.Notifier so codes are issued for the primary address when a Customer
+// is created.
+type Activator struct {
+	logger       log.Logger
+	repo         Repository
+	customerRepo customers.CustomerRepository
+	emails       customers.CustomerEmailRepository
+	cfg          ActivatorConfig
+}
+
+func NewActivator(logger log.Logger, repo Repository, customerRepo customers.CustomerRepository, emails customers.CustomerEmailRepository, cfg ActivatorConfig) *Activator {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.MaxResends <= 0 {
+		cfg.MaxResends = 3
+	}
+	if cfg.Templates == nil {
+		cfg.Templates = DefaultTemplates()
+	}
+	return &Activator{
+		logger:       logger.Set("package", log.String("email")),
+		repo:         repo,
+		customerRepo: customerRepo,
+		emails:       emails,
+		cfg:          cfg,
+	}
+}
+
+func (a *Activator) Notify(eventType webhooks.EventType, customerID, organization, status string) {
+	if eventType != webhooks.CustomerCreated {
+		return
+	}
+	if err := a.IssueCode(context.Background(), customerID, organization); err != nil {
+		a.logger.Set("customerID", log.String(customerID)).LogErrorf("problem issuing activation code: %v", err)
+	}
+}
+
+// IssueCode generates an activation code for the Customer's primary email address and queues the
+// activation email. Customers without an email address are skipped.
+func (a *Activator) IssueCode(ctx context.Context, customerID, organization string) error {
+	cust, err := a.customerRepo.GetCustomer(ctx, customerID, organization)
+	if err != nil {
+		return err
+	}
+	if cust == nil {
+		return nil
+	}
+	emails, err := a.emails.GetEmails(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	if len(emails) == 0 || !emails[0].Primary {
+		return nil
+	}
+	return a.issue(ctx, cust, organization, emails[0], false, "")
+}
+
+// Resend invalidates the unused codes sent to one of the Customer's email addresses, or their primary
+// address when emailID is empty, and queues an email with a new code. Once MaxResends codes have been
+// resent to the address within an hour further requests are refused. requestID is forwarded with the email.
+func (a *Activator) Resend(ctx context.Context, customerID, organization, emailID, requestID string) error {
+	cust, err := a.customerRepo.GetCustomer(ctx, customerID, organization)
+	if err != nil {
+		return err
+	}
+	if cust == nil {
+		return errEmailNotFound
+	}
+	email, err := a.resendTo(ctx, customerID, emailID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	resent, err := a.repo.countResends(ctx, customerID, email.EmailID, now.Add(-resendWindow))
+	if err != nil {
+		return err
+	}
+	if resent >= a.cfg.MaxResends {
+		return errTooManyResends
+	}
+	if err := a.repo.expireActivationCodes(ctx, customerID, email.EmailID, email.Email, now); err != nil {
+		return err
+	}
+	return a.issue(ctx, cust, organization, email, true, requestID)
+}
+
+func (a *Activator) resendTo(ctx context.Context, customerID, emailID string) (*customers.CustomerEmail, error) {
+	if emailID != "" {
+		email, err := a.emails.GetEmail(ctx, customerID, emailID)
+		if err != nil {
+			return nil, err
+		}
+		if email == nil {
+			return nil, errEmailNotFound
+		}
+		return email, nil
+	}
+	emails, err := a.emails.GetEmails(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(emails) == 0 || !emails[0].Primary {
+		return nil, errEmailNotFound
+	}
+	return emails[0], nil
+}
+
+func (a *Activator) issue(ctx context.Context, cust *client.Customer, organization string, email *customers.CustomerEmail, resent bool, requestID string) error {
+	customerID := cust.CustomerID
+	code, err := generateCode()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	ac := &activationCode{
+		CodeID:       base.ID(),
+		CustomerID:   customerID,
+		Organization: organization,
+		EmailID:      email.EmailID,
+		Email:        email.Email,
+		CodeHash:     a.hashCode(customerID, code),
+		ExpiresAt:    now.Add(a.cfg.TTL),
+		CreatedAt:    now,
+		Resent:       resent,
+	}
+	if err := a.repo.saveActivationCode(ctx, ac); err != nil {
+		return err
+	}
+
+	msg, err := a.cfg.Templates.RenderLocale(TypeActivation, cust.Locale, TemplateData{
+		FirstName:      cust.FirstName,
+		LastName:       cust.LastName,
+		Email:          email.Email,
+		Code:           code,
+		ActivationLink: activationLink(a.cfg.LinkURL, customerID, email.EmailID, code),
+		ExpiresAt:      ac.ExpiresAt.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("rendering activation email: %v", err)
+	}
+	return a.repo.enqueue(ctx, &OutboundEmail{
+		CustomerID: customerID,
+		Type:       TypeActivation,
+		Recipient:  email.Email,
+		Subject:    msg.Subject,
+		Body:       msg.Text,
+		HTMLBody:   msg.HTML,
+		RequestID:  requestID,
+	})
+}
+
+// Activate marks the code as used if it matches an unexpired code issued to the Customer for emailID,
+// or for any of their addresses when emailID is empty, and marks the address as verified. Codes for
+// an address which has since changed can't be used.
+func (a *Activator) Activate(ctx context.Context, customerID, organization, emailID, code string) (*activationCode, error) {
+	now := time.Now()
+	codes, err := a.repo.getActivationCodes(ctx, customerID, organization, now)
+	if err != nil {
+		return nil, err
+	}
+	hash := a.hashCode(customerID, code)
+	for i := range codes {
+		if emailID != "" && codes[i].EmailID != emailID {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(codes[i].CodeHash), []byte(hash)) == 1 {
+			// codes issued before Customers had multiple emails aren't tied to one
+			if codes[i].EmailID != "" {
+				verified, err := a.emails.MarkEmailVerified(ctx, customerID, codes[i].EmailID, codes[i].Email, now)
+				if err != nil {
+					return nil, err
+				}
+				if !verified {
+					return nil, errInvalidActivationCode
+				}
+			}
+			if err := a.repo.markActivated(ctx, codes[i].CodeID, now); err != nil {
+				return nil, err
+			}
+			return codes[i], nil
+		}
+	}
+	return nil, errInvalidActivationCode
+}
+
+// activationLink adds the code to linkURL's query, or returns an empty string when there's no linkURL
+func activationLink(linkURL, customerID, emailID, code string) string {
+	if linkURL == "" {
+		return ""
+	}
+	u, err := url.Parse(linkURL)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	q.Set("customerID", customerID)
+	if emailID != "" {
+		q.Set("emailID", emailID)
+	}
+	q.Set("code", code)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// generateCode returns a random six digit code
+func generateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("generating activation code: %v", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashCode keys the hash with a server secret since six digit codes are quick to brute force
+func (a *Activator) hashCode(customerID, code string) string {
+	mac := hmac.New(sha256.New, []byte(a.cfg.Secret))
+	mac.Write([]byte(customerID + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,514 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+type mockSender struct {
+	err        error
+	sent       []string
+	html       []string
+	requestIDs []string
+}
+
+func (s *mockSender) Send(to, subject, body, htmlBody, requestID string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, body)
+	s.html = append(s.html, htmlBody)
+	s.requestIDs = append(s.requestIDs, requestID)
+	return nil
+}
+
+func TestEmail__NewSender(t *testing.T) {
+	sender, err := NewSender(SenderConfig{})
+	require.NoError(t, err)
+	require.Nil(t, sender)
+
+	_, err = NewSender(SenderConfig{Provider: "smtp"})
+	require.Error(t, err)
+
+	_, err = NewSender(SenderConfig{Provider: "smtp", From: "noreply@moov.io"})
+	require.Error(t, err)
+
+	sender, err = NewSender(SenderConfig{Provider: "SMTP", From: "noreply@moov.io", SMTP: SMTPConfig{Host: "localhost"}})
+	require.NoError(t, err)
+	require.Equal(t, "587", sender.(*smtpSender).cfg.Port)
+
+	sender, err = NewSender(SenderConfig{Provider: "ses", From: "noreply@moov.io", SES: SESConfig{Region: "us-east-1"}})
+	require.NoError(t, err)
+	require.IsType(t, &sesSender{}, sender)
+
+	_, err = NewSender(SenderConfig{Provider: "other", From: "noreply@moov.io"})
+	require.Error(t, err)
+}
+
+func TestEmail__formatMessage(t *testing.T) {
+	msg := string(formatMessage("noreply@moov.io", "jane@example.com", "Hello\r\nBcc: other@example.com", "line one\nline two", "", ""))
+	require.Contains(t, msg, "Subject: HelloBcc: other@example.com\r\n")
+	require.NotContains(t, msg, "X-Request-ID")
+	require.True(t, strings.HasSuffix(msg, "\r\n\r\nline one\r\nline two"))
+
+	msg = string(formatMessage("noreply@moov.io", "jane@example.com", "Hello", "body", "", "rs4f9915"))
+	require.Contains(t, msg, "X-Request-ID: rs4f9915\r\n")
+	require.Contains(t, msg, "Content-Type: text/plain; charset=utf-8\r\n")
+
+	msg = string(formatMessage("noreply@moov.io", "jane@example.com", "Hello", "line one\nline two", "<p>body</p>", ""))
+	require.Contains(t, msg, "Content-Type: multipart/alternative; boundary=")
+	text, html := strings.Index(msg, "Content-Type: text/plain"), strings.Index(msg, "Content-Type: text/html")
+	require.True(t, text > 0 && html > text)
+	require.Contains(t, msg, "line one\r\nline two")
+	require.Contains(t, msg, "<p>body</p>")
+}
+
+func TestEmail__LoadTemplates(t *testing.T) {
+	templates, err := LoadTemplates("", nil)
+	require.NoError(t, err)
+	msg, err := templates.Render(TypeActivation, TemplateData{FirstName: "Jane", Code: "123456", ExpiresAt: time.Now()})
+	require.NoError(t, err)
+	require.Equal(t, "Confirm your email address", msg.Subject)
+	require.Contains(t, msg.Text, "Hi Jane,")
+	require.NotContains(t, msg.Text, "opening")
+	require.Empty(t, msg.HTML)
+
+	dir, err := ioutil.TempDir("", "email-templates")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	write := func(name, body string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0600))
+	}
+	write("activation.subject", "Welcome to Acme, {{.FirstName}}\n")
+	write("activation.html", `<p>Hi {{.FirstName}}, <a href="{{.ActivationLink}}">confirm</a></p>`)
+	write("notes.md", "ignored")
+
+	env := map[string]string{"EMAIL_TEMPLATE_ACTIVATION_TEXT": "{{.Code}} {{.LastName}}"}
+	templates, err = LoadTemplates(dir, func(key string) string { return env[key] })
+	require.NoError(t, err)
+	msg, err = templates.Render(TypeActivation, TemplateData{
+		FirstName:      "<Jane>",
+		LastName:       "Doe",
+		Code:           "123456",
+		ActivationLink: "https://example.com/confirm?code=123456",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Welcome to Acme, <Jane>", msg.Subject)
+	require.Equal(t, "123456 Doe", msg.Text)
+	require.Equal(t, `<p>Hi &lt;Jane&gt;, <a href="https://example.com/confirm?code=123456">confirm</a></p>`, msg.HTML)
+
+	_, err = templates.Render("newsletter", TemplateData{})
+	require.Error(t, err)
+
+	// new types need a subject and text body, and every template must render
+	write("newsletter.txt", "Hi {{.FirstName}}")
+	_, err = LoadTemplates(dir, nil)
+	require.Error(t, err)
+	write("newsletter.subject", "News")
+	templates, err = LoadTemplates(dir, nil)
+	require.NoError(t, err)
+	require.Contains(t, templates, "newsletter")
+
+	// translations are picked by locale and fall back to the language, then the default
+	write("activation.fr.subject", "Bienvenue {{.FirstName}}")
+	write("activation.fr.txt", "Bonjour {{.FirstName}}")
+	write("activation.es-MX.subject", "Hola")
+	write("activation.es-MX.txt", "Hola {{.FirstName}}")
+	env["EMAIL_TEMPLATE_ACTIVATION_ES_MX_SUBJECT"] = "Bienvenido {{.FirstName}}"
+	templates, err = LoadTemplates(dir, func(key string) string { return env[key] })
+	require.NoError(t, err)
+	for locale, subject := range map[string]string{
+		"fr-CA": "Bienvenue Jane",
+		"fr":    "Bienvenue Jane",
+		"es-MX": "Bienvenido Jane",
+		"es_mx": "Bienvenido Jane",
+		"es-ES": "Welcome to Acme, Jane",
+		"":      "Welcome to Acme, Jane",
+	} {
+		msg, err = templates.RenderLocale(TypeActivation, locale, TemplateData{FirstName: "Jane"})
+		require.NoError(t, err, locale)
+		require.Equal(t, subject, msg.Subject, locale)
+	}
+	msg, err = templates.RenderLocale(TypeActivation, "fr", TemplateData{FirstName: "Jane"})
+	require.NoError(t, err)
+	require.Equal(t, "Bonjour Jane", msg.Text)
+	require.Empty(t, msg.HTML)
+
+	write("activation.de.txt", "Hallo {{.FirstName}}")
+	_, err = LoadTemplates(dir, nil)
+	require.Error(t, err)
+	os.Remove(filepath.Join(dir, "activation.de.txt"))
+
+	write("newsletter.txt", "Hi {{.Nickname}}")
+	_, err = LoadTemplates(dir, nil)
+	require.Error(t, err)
+	_, err = LoadTemplates(filepath.Join(dir, "missing"), nil)
+	require.Error(t, err)
+}
+
+func TestEmail__Worker(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+	repo := NewRepository(log.NewNopLogger(), db.DB)
+
+	require.NoError(t, repo.enqueue(context.Background(), &OutboundEmail{CustomerID: "foo", Type: TypeActivation, Recipient: "jane@example.com", Subject: "hi", Body: "body"}))
+
+	sender := &mockSender{err: errors.New("connection refused")}
+	worker := NewWorker(log.NewNopLogger(), repo, nil, sender, WorkerConfig{MaxAttempts: 2, Backoff: time.Minute})
+
+	// first failure is retried after the backoff
+	now := time.Now()
+	require.NoError(t, worker.sendPending(context.Background(), now))
+	emails, err := repo.pending(context.Background(), now, 10)
+	require.NoError(t, err)
+	require.Empty(t, emails)
+
+	emails, err = repo.pending(context.Background(), now.Add(2*time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	require.Equal(t, 1, emails[0].Attempts)
+	require.Equal(t, "connection refused", emails[0].LastError)
+
+	// second failure is dead lettered
+	require.NoError(t, worker.sendPending(context.Background(), now.Add(2*time.Minute)))
+	emails, err = repo.pending(context.Background(), now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Empty(t, emails)
+
+	emails, err = repo.getEmails(context.Background(), "foo", 10)
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	require.Equal(t, 2, emails[0].Attempts)
+	require.NotNil(t, emails[0].DeadLetteredAt)
+	require.Nil(t, emails[0].SentAt)
+
+	// a successful send is only attempted once
+	require.NoError(t, repo.enqueue(context.Background(), &OutboundEmail{CustomerID: "bar", Type: TypeActivation, Recipient: "john@example.com", Subject: "hi", Body: "body", RequestID: "rs4f9915"}))
+	sender.err = nil
+	require.NoError(t, worker.sendPending(context.Background(), time.Now()))
+	require.NoError(t, worker.sendPending(context.Background(), time.Now()))
+	require.Len(t, sender.sent, 1)
+	require.Equal(t, []string{"rs4f9915"}, sender.requestIDs)
+
+	emails, err = repo.getEmails(context.Background(), "bar", 10)
+	require.NoError(t, err)
+	require.NotNil(t, emails[0].SentAt)
+	require.Equal(t, 1, emails[0].Attempts)
+}
+
+func TestEmail__WorkerContactPreferences(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	repo := NewRepository(logger, db.DB)
+	prefs := &mockPreferences{prefs: map[string]*customers.ContactPreferences{
+		"bar": {EmailOptIn: true},
+	}}
+	sender := &mockSender{}
+	worker := NewWorker(logger, repo, prefs, sender, WorkerConfig{})
+
+	// foo hasn't opted in to email so only activation codes are sent
+	require.NoError(t, repo.enqueue(context.Background(), &OutboundEmail{CustomerID: "foo", Type: TypeActivation, Recipient: "jane@example.com", Subject: "hi", Body: "body"}))
+	require.NoError(t, repo.enqueue(context.Background(), &OutboundEmail{CustomerID: "foo", Type: "newsletter", Recipient: "jane@example.com", Subject: "news", Body: "body"}))
+	require.NoError(t, repo.enqueue(context.Background(), &OutboundEmail{CustomerID: "bar", Type: "newsletter", Recipient: "john@example.com", Subject: "news", Body: "body"}))
+
+	require.NoError(t, worker.sendPending(context.Background(), time.Now()))
+	require.Len(t, sender.sent, 2)
+
+	emails, err := repo.getEmails(context.Background(), "foo", 10)
+	require.NoError(t, err)
+	for i := range emails {
+		if emails[i].Type == TypeActivation {
+			require.NotNil(t, emails[i].SentAt)
+		} else {
+			require.Nil(t, emails[i].SentAt)
+			require.NotNil(t, emails[i].SkippedAt)
+		}
+	}
+
+	// skipped emails aren't retried
+	emails, err = repo.pending(context.Background(), time.Now().Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Empty(t, emails)
+
+	// emails are left queued when preferences can't be read
+	prefs.err = errors.New("bad error")
+	require.NoError(t, repo.enqueue(context.Background(), &OutboundEmail{CustomerID: "bar", Type: "newsletter", Recipient: "john@example.com", Subject: "news", Body: "body"}))
+	require.NoError(t, worker.sendPending(context.Background(), time.Now()))
+	emails, err = repo.pending(context.Background(), time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+}
+
+func TestEmail__ActivationTemplates(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	repo := NewRepository(logger, db.DB)
+	customerRepo := customers.NewCustomerRepo(logger, db.DB)
+	cust := &client.Customer{CustomerID: "foo", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customerRepo.CreateCustomer(context.Background(), cust, "test"))
+
+	env := map[string]string{
+		"EMAIL_TEMPLATE_ACTIVATION_SUBJECT": "Welcome {{.FirstName}} {{.LastName}}",
+		"EMAIL_TEMPLATE_ACTIVATION_HTML":    `<a href="{{.ActivationLink}}">Confirm {{.Email}}</a>`,
+	}
+	templates, err := LoadTemplates("", func(key string) string { return env[key] })
+	require.NoError(t, err)
+
+	emailRepo := customers.NewCustomerEmailRepository(logger, db.DB, nil)
+	activator := NewActivator(logger, repo, customerRepo, emailRepo, ActivatorConfig{
+		Templates: templates,
+		LinkURL:   "https://example.com/confirm?lang=en",
+		Secret:    "salt",
+	})
+	require.NoError(t, activator.IssueCode(context.Background(), "foo", "test"))
+
+	emails, err := repo.getEmails(context.Background(), "foo", 10)
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	require.Equal(t, "Welcome Jane Doe", emails[0].Subject)
+
+	code := regexp.MustCompile(`\d{6}`).FindString(emails[0].Body)
+	require.Contains(t, emails[0].Body, "Or confirm it by opening https://example.com/confirm?code="+code)
+	require.Contains(t, emails[0].HTMLBody, `<a href="https://example.com/confirm?code=`+code+`&amp;customerID=foo&amp;emailID=`)
+	require.Contains(t, emails[0].HTMLBody, "lang=en")
+	require.Contains(t, emails[0].HTMLBody, "Confirm jane@example.com</a>")
+
+	// the HTML body is delivered alongside the text
+	sender := &mockSender{}
+	worker := NewWorker(logger, repo, nil, sender, WorkerConfig{})
+	require.NoError(t, worker.sendPending(context.Background(), time.Now()))
+	require.Len(t, sender.html, 1)
+	require.Equal(t, emails[0].HTMLBody, sender.html[0])
+}
+
+type mockPreferences struct {
+	customers.ContactPreferencesRepository
+
+	prefs map[string]*customers.ContactPreferences
+	err   error
+}
+
+func (p *mockPreferences) GetContactPreferences(customerID string) (*customers.ContactPreferences, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if prefs, ok := p.prefs[customerID]; ok {
+		return prefs, nil
+	}
+	return &customers.ContactPreferences{}, nil
+}
+
+func TestEmail__Activation(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	repo := NewRepository(logger, db.DB)
+	customerRepo := customers.NewCustomerRepo(logger, db.DB)
+
+	cust := &client.Customer{CustomerID: "foo", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customerRepo.CreateCustomer(context.Background(), cust, "test"))
+
+	emailRepo := customers.NewCustomerEmailRepository(logger, db.DB, nil)
+	activator := NewActivator(logger, repo, customerRepo, emailRepo, ActivatorConfig{TTL: time.Hour, Secret: "salt"})
+	notifier := webhooks.MultiNotifier(nil, activator)
+	notifier.Notify(webhooks.CustomerStatusUpdated, "foo", "test", "")
+	notifier.Notify(webhooks.CustomerCreated, "foo", "test", "")
+
+	emails, err := repo.getEmails(context.Background(), "foo", 10)
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	require.Equal(t, "jane@example.com", emails[0].Recipient)
+	require.Contains(t, emails[0].Body, "Hi Jane,")
+
+	code := regexp.MustCompile(`\d{6}`).FindString(emails[0].Body)
+	require.NotEmpty(t, code)
+
+	// stored codes are keyed with the secret rather than only hashed
+	codes, err := repo.getActivationCodes(context.Background(), "foo", "test", time.Now())
+	require.NoError(t, err)
+	require.Len(t, codes, 1)
+	unkeyed := sha256.Sum256([]byte("foo:" + code))
+	require.NotEqual(t, hex.EncodeToString(unkeyed[:]), codes[0].CodeHash)
+	other := NewActivator(logger, repo, customerRepo, emailRepo, ActivatorConfig{TTL: time.Hour, Secret: "other"})
+	_, err = other.Activate(context.Background(), "foo", "test", "", code)
+	require.Equal(t, errInvalidActivationCode, err)
+
+	router := mux.NewRouter()
+	AddRoutes(logger, router, activator)
+
+	activate := func(code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/customers/foo/email/activate", strings.NewReader(fmt.Sprintf(`{"code": %q}`, code)))
+		req.Header.Set("x-organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := activate("not-it")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = activate(code)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), `"email":"jane@example.com"`)
+
+	// codes can only be used once
+	w = activate(code)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	addresses, err := emailRepo.GetEmails(context.Background(), "foo")
+	require.NoError(t, err)
+	require.Len(t, addresses, 1)
+	require.True(t, addresses[0].Verified)
+}
+
+func TestEmail__ActivationByEmail(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	repo := NewRepository(logger, db.DB)
+	customerRepo := customers.NewCustomerRepo(logger, db.DB)
+	emailRepo := customers.NewCustomerEmailRepository(logger, db.DB, nil)
+
+	cust := &client.Customer{CustomerID: "foo", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customerRepo.CreateCustomer(context.Background(), cust, "test"))
+
+	router := mux.NewRouter()
+	AddRoutes(logger, router, NewActivator(logger, repo, customerRepo, emailRepo, ActivatorConfig{TTL: time.Hour, Secret: "salt"}))
+	customerRouter := mux.NewRouter()
+	customers.AddCustomerEmailRoutes(logger, customerRouter, customerRepo, emailRepo)
+
+	req := httptest.NewRequest("POST", "/customers/foo/emails", strings.NewReader(`{"email": "jane@work.example.com", "type": "work"}`))
+	req.Header.Set("x-organization", "test")
+	w := httptest.NewRecorder()
+	customerRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var work customers.CustomerEmail
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&work))
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/customers/foo/emails/"+path, strings.NewReader(body))
+		req.Header.Set("x-organization", "test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusNotFound, post("missing/activation", "").Code)
+	require.Equal(t, http.StatusAccepted, post(work.EmailID+"/activation", "").Code)
+
+	emails, err := repo.getEmails(context.Background(), "foo", 10)
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	require.Equal(t, "jane@work.example.com", emails[0].Recipient)
+	code := regexp.MustCompile(`\d{6}`).FindString(emails[0].Body)
+
+	// the code only activates the email it was sent to
+	addresses, err := emailRepo.GetEmails(context.Background(), "foo")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, post(addresses[0].EmailID+"/activate", fmt.Sprintf(`{"code": %q}`, code)).Code)
+
+	w = post(work.EmailID+"/activate", fmt.Sprintf(`{"code": %q}`, code))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), fmt.Sprintf(`"emailID":%q`, work.EmailID))
+
+	verified, err := emailRepo.GetEmail(context.Background(), "foo", work.EmailID)
+	require.NoError(t, err)
+	require.True(t, verified.Verified)
+	require.NotNil(t, verified.VerifiedAt)
+}
+
+func TestEmail__ResendActivation(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	repo := NewRepository(logger, db.DB)
+	customerRepo := customers.NewCustomerRepo(logger, db.DB)
+	emailRepo := customers.NewCustomerEmailRepository(logger, db.DB, nil)
+
+	cust := &client.Customer{CustomerID: "foo", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customerRepo.CreateCustomer(context.Background(), cust, "test"))
+
+	activator := NewActivator(logger, repo, customerRepo, emailRepo, ActivatorConfig{TTL: time.Hour, MaxResends: 2, Secret: "salt"})
+	require.NoError(t, activator.IssueCode(context.Background(), "foo", "test"))
+
+	router := mux.NewRouter()
+	AddRoutes(logger, router, activator)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/customers/foo/email/"+path, strings.NewReader(body))
+		req.Header.Set("x-organization", "test")
+		req.Header.Set("x-request-id", "rs4f9915")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	latestCode := func() string {
+		emails, err := repo.getEmails(context.Background(), "foo", 1)
+		require.NoError(t, err)
+		require.Len(t, emails, 1)
+		return regexp.MustCompile(`\d{6}`).FindString(emails[0].Body)
+	}
+
+	first := latestCode()
+	require.Equal(t, http.StatusAccepted, post("activation", "").Code)
+	second := latestCode()
+
+	// resent emails carry the request which asked for them
+	emails, err := repo.getEmails(context.Background(), "foo", 1)
+	require.NoError(t, err)
+	require.Equal(t, "rs4f9915", emails[0].RequestID)
+
+	// the first code no longer works
+	require.Equal(t, http.StatusBadRequest, post("activate", fmt.Sprintf(`{"code": %q}`, first)).Code)
+
+	require.Equal(t, http.StatusAccepted, post("activation", "").Code)
+	third := latestCode()
+	require.Equal(t, http.StatusBadRequest, post("activate", fmt.Sprintf(`{"code": %q}`, second)).Code)
+
+	// the initial code doesn't count as a resend
+	w := post("activation", "")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Contains(t, w.Body.String(), "too_many_requests")
+	require.Equal(t, third, latestCode())
+
+	w = post("activate", fmt.Sprintf(`{"code": %q}`, third))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Customers without an email can't be sent a code
+	other := &client.Customer{CustomerID: "bar", FirstName: "John", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customerRepo.CreateCustomer(context.Background(), other, "test"))
+	require.Equal(t, errEmailNotFound, activator.Resend(context.Background(), "bar", "test", "", ""))
+}
@@ -0,0 +1,311 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+)
+
+// Email types queued in outbound_emails
+const (
+	TypeActivation = "activation"
+)
+
+// OutboundEmail is a message waiting to be, or which has been, delivered
+type OutboundEmail struct {
+	EmailID    string     `json:"emailID"`
+	CustomerID string     `json:"customerID,omitempty"`
+	Type       string     `json:"type"`
+	Recipient  string     `json:"recipient"`
+	Subject    string     `json:"subject"`
+	Body       string     `json:"-"`
+	HTMLBody   string     `json:"-"`
+	Attempts   int        `json:"attempts"`
+	LastError  string     `json:"lastError,omitempty"`
+	SentAt     *time.Time `json:"sentAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+
+	// DeadLetteredAt is set once the email has failed every attempt and won't be retried
+	DeadLetteredAt *time.Time `json:"deadLetteredAt,omitempty"`
+
+	// SkippedAt is set when the email wasn't sent because the customer hasn't opted in to it
+	SkippedAt *time.Time `json:"skippedAt,omitempty"`
+
+	// RequestID is the request which queued the email, it's included in the message so the delivery can be traced
+	RequestID string `json:"requestID,omitempty"`
+}
+
+type Repository interface {
+	enqueue(ctx context.Context, email *OutboundEmail) error
+	pending(ctx context.Context, now time.Time, limit int) ([]*OutboundEmail, error)
+	markSent(ctx context.Context, emailID string, sentAt time.Time) error
+	markFailed(ctx context.Context, emailID string, attempts int, lastError string, nextAttemptAt time.Time, deadLettered bool) error
+	markSkipped(ctx context.Context, emailID string, skippedAt time.Time) error
+	getEmails(ctx context.Context, customerID string, limit int) ([]*OutboundEmail, error)
+
+	saveActivationCode(ctx context.Context, code *activationCode) error
+	getActivationCodes(ctx context.Context, customerID, organization string, now time.Time) ([]*activationCode, error)
+	markActivated(ctx context.Context, codeID string, activatedAt time.Time) error
+	countResends(ctx context.Context, customerID, emailID string, since time.Time) (int, error)
+	expireActivationCodes(ctx context.Context, customerID, emailID, email string, now time.Time) error
+}
+
+func NewRepository(logger log.Logger, db *sql.DB) Repository {
+	return &sqlRepository{db: db, logger: logger}
+}
+
+type sqlRepository struct {
+	db     *sql.DB
+	logger log.Logger
+}
+
+func (r *sqlRepository) enqueue(ctx context.Context, email *OutboundEmail) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	if email.EmailID == "" {
+		email.EmailID = base.ID()
+	}
+	if email.CreatedAt.IsZero() {
+		email.CreatedAt = time.Now()
+	}
+	query := `insert into outbound_emails (email_id, customer_id, email_type, recipient, subject, body, html_body, request_id, next_attempt_at, created_at) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("enqueue: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, email.EmailID, email.CustomerID, email.Type, email.Recipient, email.Subject, email.Body, email.HTMLBody, email.RequestID, email.CreatedAt, email.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("enqueue: exec: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) pending(ctx context.Context, now time.Time, limit int) ([]*OutboundEmail, error) {
+	query := `select email_id, customer_id, email_type, recipient, subject, body, html_body, attempts, last_error, sent_at, dead_lettered_at, skipped_at, request_id, created_at from outbound_emails
+where sent_at is null and dead_lettered_at is null and skipped_at is null and next_attempt_at <= ? order by created_at asc limit ?;`
+	return r.queryEmails(ctx, query, now, limit)
+}
+
+func (r *sqlRepository) getEmails(ctx context.Context, customerID string, limit int) ([]*OutboundEmail, error) {
+	query := `select email_id, customer_id, email_type, recipient, subject, body, html_body, attempts, last_error, sent_at, dead_lettered_at, skipped_at, request_id, created_at from outbound_emails
+where customer_id = ? order by created_at desc limit ?;`
+	return r.queryEmails(ctx, query, customerID, limit)
+}
+
+func (r *sqlRepository) queryEmails(ctx context.Context, query string, args ...interface{}) ([]*OutboundEmail, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("queryEmails: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("queryEmails: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []*OutboundEmail
+	for rows.Next() {
+		var e OutboundEmail
+		var customerID, htmlBody, lastError, requestID *string
+		err := rows.Scan(&e.EmailID, &customerID, &e.Type, &e.Recipient, &e.Subject, &e.Body, &htmlBody, &e.Attempts, &lastError, &e.SentAt, &e.DeadLetteredAt, &e.SkippedAt, &requestID, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("queryEmails: scan: %v", err)
+		}
+		if customerID != nil {
+			e.CustomerID = *customerID
+		}
+		if htmlBody != nil {
+			e.HTMLBody = *htmlBody
+		}
+		if lastError != nil {
+			e.LastError = *lastError
+		}
+		if requestID != nil {
+			e.RequestID = *requestID
+		}
+		out = append(out, &e)
+	}
+	return out, rows.Err()
+}
+
+func (r *sqlRepository) markSent(ctx context.Context, emailID string, sentAt time.Time) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `update outbound_emails set sent_at = ?, attempts = attempts + 1, last_error = null where email_id = ?;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("markSent: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, sentAt, emailID); err != nil {
+		return fmt.Errorf("markSent: exec: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) markFailed(ctx context.Context, emailID string, attempts int, lastError string, nextAttemptAt time.Time, deadLettered bool) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	if len(lastError) > 512 {
+		lastError = lastError[:512]
+	}
+	var deadLetteredAt *time.Time
+	if deadLettered {
+		now := time.Now()
+		deadLetteredAt = &now
+	}
+	query := `update outbound_emails set attempts = ?, last_error = ?, next_attempt_at = ?, dead_lettered_at = ? where email_id = ?;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("markFailed: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, attempts, lastError, nextAttemptAt, deadLetteredAt, emailID); err != nil {
+		return fmt.Errorf("markFailed: exec: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) markSkipped(ctx context.Context, emailID string, skippedAt time.Time) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `update outbound_emails set skipped_at = ? where email_id = ?;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("markSkipped: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, skippedAt, emailID); err != nil {
+		return fmt.Errorf("markSkipped: exec: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) saveActivationCode(ctx context.Context, code *activationCode) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `insert into email_activation_codes (code_id, customer_id, organization, email_id, email, code_hash, expires_at, created_at, resent) values (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("saveActivationCode: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, code.CodeID, code.CustomerID, code.Organization, code.EmailID, code.Email, code.CodeHash, code.ExpiresAt, code.CreatedAt, code.Resent)
+	if err != nil {
+		return fmt.Errorf("saveActivationCode: exec: %v", err)
+	}
+	return nil
+}
+
+// getActivationCodes returns the unused and unexpired codes issued to a Customer
+func (r *sqlRepository) getActivationCodes(ctx context.Context, customerID, organization string, now time.Time) ([]*activationCode, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select code_id, customer_id, organization, email_id, email, code_hash, expires_at, created_at from email_activation_codes
+where customer_id = ? and organization = ? and activated_at is null and expires_at > ? order by created_at desc;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("getActivationCodes: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, customerID, organization, now)
+	if err != nil {
+		return nil, fmt.Errorf("getActivationCodes: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []*activationCode
+	for rows.Next() {
+		var c activationCode
+		var emailID sql.NullString
+		if err := rows.Scan(&c.CodeID, &c.CustomerID, &c.Organization, &emailID, &c.Email, &c.CodeHash, &c.ExpiresAt, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("getActivationCodes: scan: %v", err)
+		}
+		c.EmailID = emailID.String
+		out = append(out, &c)
+	}
+	return out, rows.Err()
+}
+
+func (r *sqlRepository) markActivated(ctx context.Context, codeID string, activatedAt time.Time) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `update email_activation_codes set activated_at = ? where code_id = ? and activated_at is null;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("markActivated: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, activatedAt, codeID); err != nil {
+		return fmt.Errorf("markActivated: exec: %v", err)
+	}
+	return nil
+}
+
+// countResends returns how many codes were resent to the Customer's email since the given time
+func (r *sqlRepository) countResends(ctx context.Context, customerID, emailID string, since time.Time) (int, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select count(*) from email_activation_codes where customer_id = ? and email_id = ? and resent = ? and created_at > ?;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("countResends: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	var n int
+	if err := stmt.QueryRowContext(ctx, customerID, emailID, true, since).Scan(&n); err != nil {
+		return 0, fmt.Errorf("countResends: scan: %v", err)
+	}
+	return n, nil
+}
+
+// expireActivationCodes stops the unused codes sent to an email from being activated. Codes issued
+// before Customers had multiple emails are matched on their address.
+func (r *sqlRepository) expireActivationCodes(ctx context.Context, customerID, emailID, email string, now time.Time) error {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `update email_activation_codes set expires_at = ? where customer_id = ? and (email_id = ? or (email_id is null and email = ?)) and activated_at is null and expires_at > ?;`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("expireActivationCodes: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, now, customerID, emailID, email, now); err != nil {
+		return fmt.Errorf("expireActivationCodes: exec: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/admin"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// AddRoutes registers the endpoints Customers submit their activation code to and which send a
+// new code to one of their email addresses
+func AddRoutes(logger log.Logger, r *mux.Router, activator *Activator) {
+	logger = logger.Set("package", log.String("email"))
+
+	r.Methods("POST").Path("/customers/{customerID}/email/activate").HandlerFunc(activateEmail(logger, activator))
+	r.Methods("POST").Path("/customers/{customerID}/email/activation").HandlerFunc(sendActivationCode(logger, activator))
+	r.Methods("POST").Path("/customers/{customerID}/emails/{emailID}/activate").HandlerFunc(activateEmail(logger, activator))
+	r.Methods("POST").Path("/customers/{customerID}/emails/{emailID}/activation").HandlerFunc(sendActivationCode(logger, activator))
+}
+
+type activateRequest struct {
+	Code string `json:"code"`
+}
+
+type activateResponse struct {
+	CustomerID  string    `json:"customerID"`
+	EmailID     string    `json:"emailID,omitempty"`
+	Email       string    `json:"email"`
+	ActivatedAt time.Time `json:"activatedAt"`
+}
+
+func activateEmail(logger log.Logger, activator *Activator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		var req activateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if req.Code == "" {
+			route.Problem(w, errInvalidActivationCode)
+			return
+		}
+
+		// the legacy route accepts a code for any of the Customer's emails
+		code, err := activator.Activate(r.Context(), customerID, organization, mux.Vars(r)["emailID"], req.Code)
+		if err != nil {
+			if err != errInvalidActivationCode {
+				logger.Set("customerID", log.String(customerID)).LogErrorf("problem activating email: %v", err)
+			}
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(activateResponse{
+			CustomerID:  code.CustomerID,
+			EmailID:     code.EmailID,
+			Email:       code.Email,
+			ActivatedAt: time.Now(),
+		})
+	}
+}
+
+// sendActivationCode emails a new activation code to one of the Customer's addresses, or their
+// primary address on the legacy route, replacing any unused codes
+func sendActivationCode(logger log.Logger, activator *Activator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		emailID := mux.Vars(r)["emailID"]
+		if err := activator.Resend(r.Context(), customerID, organization, emailID, route.GetRequestID(r)); err != nil {
+			if err != errEmailNotFound && err != errTooManyResends {
+				logger.Set("customerID", log.String(customerID)).LogErrorf("problem issuing activation code: %v", err)
+			}
+			route.Problem(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// AddAdminRoutes registers an endpoint to debug email deliveries
+func AddAdminRoutes(logger log.Logger, svc *admin.Server, repo Repository) {
+	logger = logger.Set("package", log.String("email"))
+
+	svc.AddHandler("/customers/{customerID}/emails", getEmails(logger, repo))
+}
+
+func getEmails(logger log.Logger, repo Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if r.Method != "GET" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		customerID := route.GetCustomerID(w, r)
+		if customerID == "" {
+			return
+		}
+
+		_, count, _, err := moovhttp.GetSkipAndCount(r)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+
+		emails, err := repo.getEmails(r.Context(), customerID, count)
+		if err != nil {
+			logger.Set("customerID", log.String(customerID)).LogErrorf("problem reading outbound emails: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(emails)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package email delivers the messages queued in outbound_emails through SMTP or AWS SES and issues
+// the activation codes customers use to confirm their email address.
+package email
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+)
+
+// EmailSender delivers a single message. The plain text body is always sent, htmlBody is sent as an
+// alternative when it's not empty. requestID is the request which queued it, if any, and is included
+// in the message so its delivery can be traced.
+type EmailSender interface {
+	Send(to, subject, body, htmlBody, requestID string) error
+}
+
+// SenderConfig holds the settings for the configured EmailSender
+type SenderConfig struct {
+	// Provider is either "smtp" or "ses", an empty value disables sending emails.
+	Provider string
+	From     string
+
+	SMTP SMTPConfig
+	SES  SESConfig
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+type SESConfig struct {
+	Region string
+}
+
+// NewSender returns the EmailSender for cfg or nil if no provider is configured.
+func NewSender(cfg SenderConfig) (EmailSender, error) {
+	provider := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	if provider == "" {
+		return nil, nil
+	}
+	if cfg.From == "" {
+		return nil, errors.New("email: missing from address")
+	}
+	switch provider {
+	case "smtp":
+		if cfg.SMTP.Host == "" {
+			return nil, errors.New("email: missing SMTP host")
+		}
+		if cfg.SMTP.Port == "" {
+			cfg.SMTP.Port = "587"
+		}
+		return &smtpSender{from: cfg.From, cfg: cfg.SMTP}, nil
+
+	case "ses":
+		sess, err := session.NewSession(&aws.Config{
+			Region: aws.String(cfg.SES.Region),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("email: SES session: %v", err)
+		}
+		return &sesSender{from: cfg.From, client: ses.New(sess)}, nil
+	}
+	return nil, fmt.Errorf("email: unknown provider %q", cfg.Provider)
+}
+
+type smtpSender struct {
+	from string
+	cfg  SMTPConfig
+}
+
+func (s *smtpSender) Send(to, subject, body, htmlBody, requestID string) error {
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+	addr := net.JoinHostPort(s.cfg.Host, s.cfg.Port)
+	return smtp.SendMail(addr, auth, s.from, []string{to}, formatMessage(s.from, to, subject, body, htmlBody, requestID))
+}
+
+// formatMessage returns the RFC 5322 message sent over SMTP. Messages with an HTML body are sent as
+// multipart/alternative with the plain text first.
+func formatMessage(from, to, subject, body, htmlBody, requestID string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&buf, "To: %s\r\n", headerValue(to))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", headerValue(subject))
+	if requestID != "" {
+		fmt.Fprintf(&buf, "X-Request-ID: %s\r\n", headerValue(requestID))
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	if htmlBody == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("\r\n")
+		buf.WriteString(crlf(body))
+		return buf.Bytes()
+	}
+
+	var parts bytes.Buffer
+	mw := multipart.NewWriter(&parts)
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", body},
+		{"text/html; charset=utf-8", htmlBody},
+	} {
+		w, _ := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		w.Write([]byte(crlf(part.body)))
+	}
+	mw.Close()
+
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n", mw.Boundary())
+	buf.WriteString("\r\n")
+	buf.Write(parts.Bytes())
+	return buf.Bytes()
+}
+
+// crlf converts the line endings of a body for SMTP
+func crlf(body string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// headerValue removes line breaks so a value can't inject extra headers
+func headerValue(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
+
+type sesSender struct {
+	from   string
+	client *ses.SES
+}
+
+func (s *sesSender) Send(to, subject, body, htmlBody, requestID string) error {
+	input := &ses.SendEmailInput{
+		Source: aws.String(s.from),
+		Destination: &ses.Destination{
+			ToAddresses: []*string{aws.String(to)},
+		},
+		Message: &ses.Message{
+			Subject: &ses.Content{
+				Charset: aws.String("UTF-8"),
+				Data:    aws.String(subject),
+			},
+			Body: &ses.Body{
+				Text: &ses.Content{
+					Charset: aws.String("UTF-8"),
+					Data:    aws.String(body),
+				},
+			},
+		},
+	}
+	if htmlBody != "" {
+		input.Message.Body.Html = &ses.Content{
+			Charset: aws.String("UTF-8"),
+			Data:    aws.String(htmlBody),
+		}
+	}
+	if requestID != "" {
+		// SES doesn't accept custom headers on SendEmail so the request is tagged instead
+		input.Tags = []*ses.MessageTag{{Name: aws.String("request-id"), Value: aws.String(requestID)}}
+	}
+	_, err := s.client.SendEmail(input)
+	if err != nil {
+		return fmt.Errorf("ses: %v", err)
+	}
+	return nil
+}
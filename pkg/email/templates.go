@@ -0,0 +1,236 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData holds the variables templates are rendered with. Fields which don't apply to an email
+// type are left empty.
+type TemplateData struct {
+	FirstName string
+	LastName  string
+	Email     string
+
+	// Code and ActivationLink are the activation code and, when configured, a link which submits it
+	Code           string
+	ActivationLink string
+	ExpiresAt      time.Time
+}
+
+// Template is the content of one email type. Text is required, an HTML body is sent alongside it
+// when set. Variables in the HTML body are escaped.
+type Template struct {
+	Subject *template.Template
+	Text    *template.Template
+	HTML    *htmltemplate.Template
+}
+
+// Rendered is a Template executed with TemplateData
+type Rendered struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Templates are the Template of each email type. Translations are keyed by the email type and
+// a lowercase BCP 47 tag, e.g. activation.fr-ca, see RenderLocale.
+type Templates map[string]*Template
+
+// Template file extensions, e.g. activation.subject, activation.txt and activation.html
+const (
+	subjectExt = ".subject"
+	textExt    = ".txt"
+	htmlExt    = ".html"
+)
+
+const defaultActivationText = `Hi {{.FirstName}},
+
+Enter the code below to confirm your email address.
+
+{{.Code}}
+{{if .ActivationLink}}
+Or confirm it by opening {{.ActivationLink}}
+{{end}}
+This code expires at {{.ExpiresAt.Format "2006-01-02 15:04 MST"}}.
+`
+
+// DefaultTemplates returns the built in Templates used when none are configured
+func DefaultTemplates() Templates {
+	return Templates{
+		TypeActivation: &Template{
+			Subject: template.Must(template.New(TypeActivation + subjectExt).Parse("Confirm your email address")),
+			Text:    template.Must(template.New(TypeActivation + textExt).Parse(defaultActivationText)),
+		},
+	}
+}
+
+// LoadTemplates returns the default Templates overridden by the files in dir and then by environment
+// variables read with getenv. Files are named after the email type and part, e.g. activation.subject,
+// activation.txt and activation.html, and variables are EMAIL_TEMPLATE_<TYPE>_SUBJECT, _TEXT and _HTML.
+// An empty dir only reads the environment.
+//
+// Translations are files named after the email type and a BCP 47 tag, e.g. activation.fr-CA.txt or
+// activation.es.subject, and are overridden by variables like EMAIL_TEMPLATE_ACTIVATION_FR_CA_TEXT.
+// They don't inherit any part of the default template, so each needs its own subject and text body.
+//
+// Each template is rendered once so mistakes like unknown variables fail on startup.
+func LoadTemplates(dir string, getenv func(string) string) (Templates, error) {
+	parts := make(map[string]map[string]string)
+	set := func(emailType, ext, body string) {
+		if parts[emailType] == nil {
+			parts[emailType] = make(map[string]string)
+		}
+		parts[emailType][ext] = body
+	}
+
+	if dir != "" {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("email: reading templates: %v", err)
+		}
+		for i := range files {
+			ext := filepath.Ext(files[i].Name())
+			if files[i].IsDir() || (ext != subjectExt && ext != textExt && ext != htmlExt) {
+				continue
+			}
+			bs, err := ioutil.ReadFile(filepath.Join(dir, files[i].Name()))
+			if err != nil {
+				return nil, fmt.Errorf("email: reading template: %v", err)
+			}
+			set(templateKey(strings.TrimSuffix(files[i].Name(), ext)), ext, string(bs))
+		}
+	}
+
+	templates := DefaultTemplates()
+	var types []string
+	for emailType := range templates {
+		types = append(types, emailType)
+	}
+	for emailType := range parts {
+		if _, exists := templates[emailType]; !exists {
+			types = append(types, emailType)
+		}
+	}
+	sort.Strings(types)
+
+	for _, emailType := range types {
+		if getenv != nil {
+			prefix := "EMAIL_TEMPLATE_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(emailType))
+			for ext, suffix := range map[string]string{subjectExt: "_SUBJECT", textExt: "_TEXT", htmlExt: "_HTML"} {
+				if v := getenv(prefix + suffix); v != "" {
+					set(emailType, ext, v)
+				}
+			}
+		}
+		tmpl, err := parseTemplate(emailType, templates[emailType], parts[emailType])
+		if err != nil {
+			return nil, err
+		}
+		templates[emailType] = tmpl
+	}
+	return templates, nil
+}
+
+func parseTemplate(emailType string, base *Template, parts map[string]string) (*Template, error) {
+	tmpl := &Template{}
+	if base != nil {
+		*tmpl = *base
+	}
+	var err error
+	if v, ok := parts[subjectExt]; ok {
+		if tmpl.Subject, err = template.New(emailType + subjectExt).Parse(strings.TrimSpace(v)); err != nil {
+			return nil, fmt.Errorf("email: %s subject template: %v", emailType, err)
+		}
+	}
+	if v, ok := parts[textExt]; ok {
+		if tmpl.Text, err = template.New(emailType + textExt).Parse(v); err != nil {
+			return nil, fmt.Errorf("email: %s text template: %v", emailType, err)
+		}
+	}
+	if v, ok := parts[htmlExt]; ok {
+		if tmpl.HTML, err = htmltemplate.New(emailType + htmlExt).Parse(v); err != nil {
+			return nil, fmt.Errorf("email: %s HTML template: %v", emailType, err)
+		}
+	}
+	if tmpl.Subject == nil || tmpl.Text == nil {
+		return nil, fmt.Errorf("email: %s template needs a subject and a text body", emailType)
+	}
+	if _, err := tmpl.Render(TemplateData{ExpiresAt: time.Now()}); err != nil {
+		return nil, fmt.Errorf("email: %s template: %v", emailType, err)
+	}
+	return tmpl, nil
+}
+
+// templateKey lowercases the locale of a translation's name, e.g. activation.fr-CA becomes activation.fr-ca
+func templateKey(name string) string {
+	if idx := strings.Index(name, "."); idx > 0 {
+		return name[:idx] + "." + strings.ToLower(name[idx+1:])
+	}
+	return name
+}
+
+// Render executes the default Template of emailType with data
+func (t Templates) Render(emailType string, data TemplateData) (*Rendered, error) {
+	return t.RenderLocale(emailType, "", data)
+}
+
+// RenderLocale executes the translation of emailType for locale with data. Translations for the locale's
+// language are used when there isn't one for the whole tag, e.g. activation.fr for fr-CA, and the
+// default Template is used when there's neither.
+func (t Templates) RenderLocale(emailType, locale string, data TemplateData) (*Rendered, error) {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	for locale != "" {
+		if tmpl, ok := t[emailType+"."+locale]; ok {
+			return tmpl.Render(data)
+		}
+		idx := strings.LastIndex(locale, "-")
+		if idx < 0 {
+			break
+		}
+		locale = locale[:idx]
+	}
+	tmpl, ok := t[emailType]
+	if !ok {
+		return nil, fmt.Errorf("email: no template for %s emails", emailType)
+	}
+	return tmpl.Render(data)
+}
+
+// Render executes each part of the Template with data
+func (t *Template) Render(data TemplateData) (*Rendered, error) {
+	if t == nil || t.Subject == nil || t.Text == nil {
+		return nil, errors.New("email: incomplete template")
+	}
+	var subject, text bytes.Buffer
+	if err := t.Subject.Execute(&subject, data); err != nil {
+		return nil, fmt.Errorf("rendering subject: %v", err)
+	}
+	if err := t.Text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("rendering text body: %v", err)
+	}
+	out := &Rendered{
+		Subject: headerValue(subject.String()),
+		Text:    text.String(),
+	}
+	if t.HTML != nil {
+		var html bytes.Buffer
+		if err := t.HTML.Execute(&html, data); err != nil {
+			return nil, fmt.Errorf("rendering HTML body: %v", err)
+		}
+		out.HTML = html.String()
+	}
+	return out, nil
+}
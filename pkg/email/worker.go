@@ -0,0 +1,168 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/moov-io/base/log"
+	"github.com/moov-io/customers/pkg/customers"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	emailsSent = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "emails_sent",
+		Help: "Counter of email delivery attempts by their type and result",
+	}, []string{"type", "result"})
+)
+
+// WorkerConfig holds the settings for delivering queued emails
+type WorkerConfig struct {
+	// Interval is how often outbound_emails is checked for unsent emails
+	Interval time.Duration
+
+	// BatchSize is the most emails sent on each interval
+	BatchSize int
+
+	// MaxAttempts is how many times an email is tried before it's dead lettered
+	MaxAttempts int
+
+	// Backoff is the delay after the first failure, which doubles after each following failure
+	Backoff time.Duration
+}
+
+// Worker sends the emails queued in outbound_emails. An email which fails MaxAttempts times is
+// dead lettered and left in the table for operators to inspect. Emails to customers who haven't
+// opted in to their category are skipped.
+type Worker struct {
+	logger log.Logger
+	repo   Repository
+	prefs  customers.ContactPreferencesRepository
+	sender EmailSender
+	cfg    WorkerConfig
+}
+
+func NewWorker(logger log.Logger, repo Repository, prefs customers.ContactPreferencesRepository, sender EmailSender, cfg WorkerConfig) *Worker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Minute
+	}
+	return &Worker{
+		logger: logger.Set("package", log.String("email")),
+		repo:   repo,
+		prefs:  prefs,
+		sender: sender,
+		cfg:    cfg,
+	}
+}
+
+// Start sends queued emails every interval until ctx is cancelled
+func (w *Worker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.sendPending(ctx, time.Now()); err != nil {
+			w.logger.LogErrorf("problem sending queued emails: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendPending tries each email due at now, recording whether it was sent. Once ctx is done the
+// remaining emails are left for the next run. Results are recorded without ctx so an email which
+// was sent isn't sent again.
+func (w *Worker) sendPending(ctx context.Context, now time.Time) error {
+	emails, err := w.repo.pending(ctx, now, w.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range emails {
+		if ctx.Err() != nil {
+			return nil
+		}
+		logger := w.logger.Set("emailID", log.String(emails[i].EmailID))
+		if emails[i].RequestID != "" {
+			logger = logger.Set("requestID", log.String(emails[i].RequestID))
+		}
+
+		if allowed, err := w.optedIn(emails[i]); err != nil {
+			logger.LogErrorf("problem reading contact preferences: %v", err)
+			continue
+		} else if !allowed {
+			logger.Info().Logf("skipping %s email, customer hasn't opted in", emails[i].Type)
+			emailsSent.With("type", emails[i].Type, "result", "skipped").Add(1)
+			if err := w.repo.markSkipped(context.Background(), emails[i].EmailID, time.Now()); err != nil {
+				logger.LogErrorf("problem marking email as skipped: %v", err)
+			}
+			continue
+		}
+
+		sendErr := w.sender.Send(emails[i].Recipient, emails[i].Subject, emails[i].Body, emails[i].HTMLBody, emails[i].RequestID)
+		if sendErr == nil {
+			emailsSent.With("type", emails[i].Type, "result", "sent").Add(1)
+			if err := w.repo.markSent(context.Background(), emails[i].EmailID, time.Now()); err != nil {
+				logger.LogErrorf("problem marking email as sent: %v", err)
+			}
+			continue
+		}
+
+		attempts := emails[i].Attempts + 1
+		deadLettered := attempts >= w.cfg.MaxAttempts
+		if deadLettered {
+			emailsSent.With("type", emails[i].Type, "result", "dead_lettered").Add(1)
+			logger.LogErrorf("dead lettering email after %d attempts: %v", attempts, sendErr)
+		} else {
+			emailsSent.With("type", emails[i].Type, "result", "failed").Add(1)
+			logger.LogErrorf("email attempt %d of %d failed: %v", attempts, w.cfg.MaxAttempts, sendErr)
+		}
+		next := now.Add(w.cfg.Backoff << uint(attempts-1))
+		if err := w.repo.markFailed(context.Background(), emails[i].EmailID, attempts, sendErr.Error(), next, deadLettered); err != nil {
+			logger.LogErrorf("problem recording failed email: %v", err)
+		}
+	}
+	return nil
+}
+
+// requiredConsent returns the contact preference a customer must opt in to before emails of
+// emailType are sent. Activation codes confirm the customer's own signup so they're transactional
+// and sent without consent, every other email requires the customer to opt in.
+func requiredConsent(emailType string) customers.ContactCategory {
+	switch emailType {
+	case TypeActivation:
+		return ""
+	}
+	return customers.ContactEmail
+}
+
+func (w *Worker) optedIn(email *OutboundEmail) (bool, error) {
+	category := requiredConsent(email.Type)
+	if category == "" {
+		return true, nil
+	}
+	if email.CustomerID == "" || w.prefs == nil {
+		return false, nil
+	}
+	prefs, err := w.prefs.GetContactPreferences(email.CustomerID)
+	if err != nil {
+		return false, err
+	}
+	return prefs.OptedIn(category), nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package export builds a portable bundle of every record held about a Customer
+// for data portability (GDPR / CCPA) requests.
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/internal/util"
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/documents"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// Bundle is the portable export of a Customer. Document contents are not included, only their metadata.
+type Bundle struct {
+	ExportedAt    time.Time                  `json:"exportedAt"`
+	Customer      *client.Customer           `json:"customer"`
+	SSN           string                     `json:"ssn,omitempty"`
+	Emails        []*customers.CustomerEmail `json:"emails"`
+	StatusHistory []customers.StatusUpdate   `json:"statusHistory"`
+	OFACSearches  []*client.OfacSearch       `json:"ofacSearches"`
+	Documents     []*client.Document         `json:"documents"`
+	Disclaimers   []*client.Disclaimer       `json:"disclaimers"`
+}
+
+// Service gathers a Customer's records from each package which stores them.
+type Service struct {
+	customers *customers.Exporter
+	documents *documents.Exporter
+}
+
+func NewService(customers *customers.Exporter, documents *documents.Exporter) *Service {
+	return &Service{
+		customers: customers,
+		documents: documents,
+	}
+}
+
+// Bundle returns the export of a Customer or nil if they don't exist. The SSN is masked
+// unless includeSSN is set.
+func (svc *Service) Bundle(ctx context.Context, customerID, organization string, includeSSN bool) (*Bundle, error) {
+	cust, err := svc.customers.Export(ctx, customerID, organization, includeSSN)
+	if err != nil || cust == nil {
+		return nil, err
+	}
+	docs, err := svc.documents.Export(ctx, customerID, organization)
+	if err != nil {
+		return nil, err
+	}
+	return &Bundle{
+		ExportedAt:    time.Now(),
+		Customer:      cust.Customer,
+		SSN:           cust.SSN,
+		Emails:        nonNilEmails(cust.Emails),
+		StatusHistory: nonNilStatusHistory(cust.StatusHistory),
+		OFACSearches:  nonNilOFACSearches(cust.OFACSearches),
+		Documents:     nonNilDocuments(docs.Documents),
+		Disclaimers:   nonNilDisclaimers(docs.Disclaimers),
+	}, nil
+}
+
+// AddRoutes registers the export endpoint on the public router. SSNs are always masked.
+func AddRoutes(logger log.Logger, r *mux.Router, svc *Service) {
+	logger = logger.Set("package", log.String("export"))
+
+	r.Methods("GET").Path("/customers/{customerID}/export").HandlerFunc(exportCustomer(logger, svc, false))
+}
+
+// AddAdminRoutes registers the export endpoint on the admin server, which can include the full SSN
+// with ?includeSSN=true
+func AddAdminRoutes(logger log.Logger, server *admin.Server, svc *Service) {
+	logger = logger.Set("package", log.String("export"))
+
+	server.AddHandler("/customers/{customerID}/export", exportCustomer(logger, svc, true))
+}
+
+func exportCustomer(logger log.Logger, svc *Service, allowSSN bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if r.Method != "GET" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		includeSSN := allowSSN && util.Yes(r.URL.Query().Get("includeSSN"))
+
+		bundle, err := svc.Bundle(r.Context(), customerID, organization, includeSSN)
+		if err != nil {
+			logger.LogErrorf("problem exporting customer=%s: %v", customerID, err)
+			route.Problem(w, err)
+			return
+		}
+		if bundle == nil {
+			route.NotFound(w, r)
+			return
+		}
+		logger.Logf("exported customer=%s includeSSN=%v", customerID, includeSSN)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="customer-%s.json"`, customerID))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(bundle)
+	}
+}
+
+func nonNilEmails(in []*customers.CustomerEmail) []*customers.CustomerEmail {
+	if in == nil {
+		return []*customers.CustomerEmail{}
+	}
+	return in
+}
+
+func nonNilStatusHistory(in []customers.StatusUpdate) []customers.StatusUpdate {
+	if in == nil {
+		return []customers.StatusUpdate{}
+	}
+	return in
+}
+
+func nonNilOFACSearches(in []*client.OfacSearch) []*client.OfacSearch {
+	if in == nil {
+		return []*client.OfacSearch{}
+	}
+	return in
+}
+
+func nonNilDocuments(in []*client.Document) []*client.Document {
+	if in == nil {
+		return []*client.Document{}
+	}
+	return in
+}
+
+func nonNilDisclaimers(in []*client.Disclaimer) []*client.Disclaimer {
+	if in == nil {
+		return []*client.Disclaimer{}
+	}
+	return in
+}
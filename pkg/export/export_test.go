@@ -0,0 +1,148 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/documents"
+	"github.com/moov-io/customers/pkg/secrets"
+	"github.com/moov-io/customers/pkg/watchman"
+
+	watchmanClient "github.com/moov-io/watchman/client"
+)
+
+func setupRouter(t *testing.T) (*mux.Router, *Service, customers.CustomerEmailRepository) {
+	t.Helper()
+
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t).DB
+
+	customerRepo := customers.NewCustomerRepo(logger, db)
+	ssnStorage := customers.NewSSNStorage(secrets.TestStringKeeper(t), customers.NewCustomerSSNRepository(logger, db), "")
+	ofac := customers.NewOFACSearcher(customerRepo, watchman.NewTestWatchmanClient(&watchmanClient.OfacSdn{
+		EntityID: "1241421",
+		SdnName:  "Jane Doe",
+		Match:    0.5,
+	}, nil))
+
+	emailRepo := customers.NewCustomerEmailRepository(logger, db, nil)
+
+	router := mux.NewRouter()
+	customers.AddCustomerRoutes(logger, router, customerRepo, ssnStorage, ofac, nil, nil)
+	customers.AddCustomerEmailRoutes(logger, router, customerRepo, emailRepo)
+
+	svc := NewService(
+		customers.NewExporter(customerRepo, emailRepo, ssnStorage),
+		documents.NewExporter(documents.NewDocumentRepo(logger, db), documents.NewDisclaimerRepo(logger, db)),
+	)
+	AddRoutes(logger, router, svc)
+	return router, svc, emailRepo
+}
+
+func do(t *testing.T, router *mux.Router, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("x-organization", "test")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func createCustomer(t *testing.T, router *mux.Router) string {
+	t.Helper()
+
+	w := do(t, router, "POST", "/customers", `{"firstName": "Jane", "lastName": "Doe", "type": "individual", "email": "jane@example.com", "SSN": "587654321"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var cust client.Customer
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&cust))
+
+	w = do(t, router, "PUT", fmt.Sprintf("/customers/%s/status", cust.CustomerID), `{"status": "receiveonly", "comment": "initial review"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	return cust.CustomerID
+}
+
+func TestExport__customer(t *testing.T) {
+	router, _, emailRepo := setupRouter(t)
+	customerID := createCustomer(t, router)
+
+	w := do(t, router, "POST", fmt.Sprintf("/customers/%s/emails", customerID), `{"email": "jane@work.example.com", "type": "work"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var work customers.CustomerEmail
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&work))
+	verified, err := emailRepo.MarkEmailVerified(context.Background(), customerID, work.EmailID, work.Email, time.Now())
+	require.NoError(t, err)
+	require.True(t, verified)
+
+	w = do(t, router, "GET", fmt.Sprintf("/customers/%s/export?includeSSN=true", customerID), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Contains(t, w.Header().Get("Content-Disposition"), customerID)
+
+	var bundle Bundle
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&bundle))
+	require.Equal(t, customerID, bundle.Customer.CustomerID)
+	require.Equal(t, "#####4321", bundle.SSN) // public route never includes the full SSN
+	require.Len(t, bundle.Emails, 2)
+	require.Equal(t, "jane@example.com", bundle.Emails[0].Email)
+	require.True(t, bundle.Emails[0].Primary)
+	require.False(t, bundle.Emails[0].Verified)
+	require.Equal(t, "jane@work.example.com", bundle.Emails[1].Email)
+	require.True(t, bundle.Emails[1].Verified)
+	require.NotNil(t, bundle.Emails[1].VerifiedAt)
+	require.Len(t, bundle.OFACSearches, 1)
+	require.Equal(t, "1241421", bundle.OFACSearches[0].EntityID)
+	require.Len(t, bundle.StatusHistory, 1)
+	require.Equal(t, client.CUSTOMERSTATUS_RECEIVE_ONLY, bundle.StatusHistory[0].Status)
+	require.Equal(t, "initial review", bundle.StatusHistory[0].Comment)
+	require.Empty(t, bundle.Documents)
+	require.Empty(t, bundle.Disclaimers)
+
+	w = do(t, router, "GET", "/customers/missing/export", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestExport__admin(t *testing.T) {
+	router, exportSvc, _ := setupRouter(t)
+	customerID := createCustomer(t, router)
+
+	svc := admin.NewServer(":0")
+	defer svc.Shutdown()
+	AddAdminRoutes(log.NewNopLogger(), svc, exportSvc)
+	go svc.Listen()
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/customers/%s/export?includeSSN=true", svc.BindAddr(), customerID), nil)
+	require.NoError(t, err)
+	req.Header.Set("x-organization", "test")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	var bundle Bundle
+	require.NoError(t, json.Unmarshal(body, &bundle))
+	require.Equal(t, "587654321", bundle.SSN)
+}
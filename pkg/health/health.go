@@ -0,0 +1,108 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package health serves the liveness and readiness probes used by orchestrators like Kubernetes.
+// GET /live only reports the process is running while GET /ready checks each dependency is reachable.
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+)
+
+var errTimeout = errors.New("timeout exceeded")
+
+// Check is a dependency which must be reachable for the service to be ready
+type Check struct {
+	Name  string
+	Check func() error
+}
+
+// Status is the body of a probe response
+type Status struct {
+	Status string `json:"status"`
+
+	// Failures holds the error of each dependency which couldn't be reached
+	Failures map[string]string `json:"failures,omitempty"`
+}
+
+// AddRoutes registers GET /live and GET /ready. Each check is run concurrently on every readiness
+// request and fails if it's still running after timeout.
+func AddRoutes(logger log.Logger, r *mux.Router, timeout time.Duration, checks ...Check) {
+	logger = logger.Set("package", log.String("health"))
+
+	r.Methods("GET").Path("/live").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(w, http.StatusOK, Status{Status: "alive"})
+	})
+	r.Methods("GET").Path("/ready").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failures := runChecks(checks, timeout)
+		if len(failures) > 0 {
+			for _, name := range sortedKeys(failures) {
+				logger.Set("check", log.String(name)).LogErrorf("readiness check failed: %s", failures[name])
+			}
+			respond(w, http.StatusServiceUnavailable, Status{Status: "unavailable", Failures: failures})
+			return
+		}
+		respond(w, http.StatusOK, Status{Status: "ready"})
+	})
+}
+
+func respond(w http.ResponseWriter, status int, body Status) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// runChecks returns the error of each failed check keyed by its name
+func runChecks(checks []Check, timeout time.Duration) map[string]string {
+	var mu sync.Mutex
+	failures := make(map[string]string)
+
+	var wg sync.WaitGroup
+	wg.Add(len(checks))
+	for i := range checks {
+		go func(check Check) {
+			defer wg.Done()
+			if err := try(check.Check, timeout); err != nil {
+				mu.Lock()
+				failures[check.Name] = err.Error()
+				mu.Unlock()
+			}
+		}(checks[i])
+	}
+	wg.Wait()
+
+	return failures
+}
+
+// try calls f but returns errTimeout if it hasn't returned after timeout
+func try(f func() error, timeout time.Duration) error {
+	answer := make(chan error, 1)
+	go func() {
+		answer <- f()
+	}()
+	select {
+	case err := <-answer:
+		return err
+	case <-time.After(timeout):
+		return errTimeout
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
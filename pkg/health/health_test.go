@@ -0,0 +1,70 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth__Routes(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	watchmanErr := errors.New("connection refused")
+	router := mux.NewRouter()
+	AddRoutes(log.NewNopLogger(), router, 50*time.Millisecond,
+		Check{Name: "database", Check: db.DB.Ping},
+		Check{Name: "watchman", Check: func() error { return watchmanErr }},
+		Check{Name: "slow", Check: func() error {
+			time.Sleep(time.Second)
+			return nil
+		}},
+	)
+
+	get := func(path string) (int, Status) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		var status Status
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+		return w.Code, status
+	}
+
+	code, status := get("/live")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "alive", status.Status)
+
+	code, status = get("/ready")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, map[string]string{
+		"watchman": "connection refused",
+		"slow":     "timeout exceeded",
+	}, status.Failures)
+
+	// once the database is closed it's reported as well
+	db.Close()
+	_, status = get("/ready")
+	require.Contains(t, status.Failures, "database")
+}
+
+func TestHealth__Ready(t *testing.T) {
+	router := mux.NewRouter()
+	AddRoutes(log.NewNopLogger(), router, time.Second, Check{Name: "database", Check: func() error { return nil }})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status": "ready"}`, w.Body.String())
+}
@@ -0,0 +1,555 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package merge combines two Customer records which turned out to be the same person. The source
+// Customer's records are moved to the target, which survives, and the source is deleted with a
+// pointer to the target.
+//
+// Conflicts are settled the same way each time: the target's fields win unless they're blank, and
+// records the target already has (the same phone number, address line, metadata key, disclaimer,
+// account or SSN) stay with the deleted source instead of being duplicated. Relationships are the
+// exception, duplicates and those between the two Customers are dropped.
+package merge
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/audit"
+	"github.com/moov-io/customers/pkg/documents"
+	"github.com/moov-io/customers/pkg/documents/storage"
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+var (
+	errCustomerNotFound = route.NewError(http.StatusNotFound, route.CodeNotFound, "customer not found")
+	errSameCustomer     = route.Validation(errors.New("a customer can't be merged into itself"))
+)
+
+// Result is the record of a merge
+type Result struct {
+	SourceCustomerID string `json:"sourceCustomerID"`
+	TargetCustomerID string `json:"targetCustomerID"`
+	Organization     string `json:"organization"`
+	MergedBy         string `json:"mergedBy,omitempty"`
+	RequestID        string `json:"requestID,omitempty"`
+
+	// Fields lists the target's blank fields which were filled from the source
+	Fields []string `json:"fields,omitempty"`
+
+	// Moved counts the rows moved to the target and Skipped the rows left with the source because
+	// the target already had them, or dropped for relationships. Both are keyed by table.
+	Moved   map[string]int `json:"moved"`
+	Skipped map[string]int `json:"skipped,omitempty"`
+
+	DocumentsCopied int       `json:"documentsCopied"`
+	MergedAt        time.Time `json:"mergedAt"`
+}
+
+// Merger moves a Customer's records under another Customer
+type Merger struct {
+	logger        log.Logger
+	db            *sql.DB
+	bucketFactory storage.BucketFunc
+
+	// fields decrypts phone numbers so duplicates can be found, it's nil when they aren't encrypted
+	fields *secrets.FieldEncryptor
+}
+
+func NewMerger(logger log.Logger, db *sql.DB, bucketFactory storage.BucketFunc, fields *secrets.FieldEncryptor) *Merger {
+	return &Merger{
+		logger:        logger.Set("package", log.String("merge")),
+		db:            db,
+		bucketFactory: bucketFactory,
+		fields:        fields,
+	}
+}
+
+// Merge moves the source Customer's records to the target in one transaction and deletes the source.
+// Documents are copied in the storage bucket before the transaction commits and the source's copies
+// are removed after.
+func (m *Merger) Merge(ctx context.Context, sourceID, targetID, organization, actor, requestID string) (*Result, error) {
+	if sourceID == targetID {
+		return nil, errSameCustomer
+	}
+
+	var result *Result
+	queryCtx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+	err := customersdb.RetryOnLockContext(queryCtx, m.db, func(tx *sql.Tx) error {
+		source, err := readCustomer(queryCtx, tx, sourceID, organization)
+		if err != nil {
+			return err
+		}
+		target, err := readCustomer(queryCtx, tx, targetID, organization)
+		if err != nil {
+			return err
+		}
+
+		result = &Result{
+			SourceCustomerID: sourceID,
+			TargetCustomerID: targetID,
+			Organization:     organization,
+			MergedBy:         actor,
+			RequestID:        requestID,
+			Moved:            make(map[string]int),
+			Skipped:          make(map[string]int),
+			MergedAt:         time.Now(),
+		}
+
+		diff, err := mergeFields(queryCtx, tx, source, target, result.MergedAt)
+		if err != nil {
+			return err
+		}
+		for i := range fields {
+			if _, filled := diff[fields[i].name]; filled && (i == 0 || fields[i-1].name != fields[i].name) {
+				result.Fields = append(result.Fields, fields[i].name)
+			}
+		}
+		if err := moveRecords(queryCtx, tx, m.fields, sourceID, targetID, result); err != nil {
+			return err
+		}
+
+		query := `update customers set deleted_at = ?, merged_into = ?, version = version + 1 where customer_id = ?;`
+		if _, err := tx.ExecContext(queryCtx, query, result.MergedAt, targetID, sourceID); err != nil {
+			return fmt.Errorf("merge: deleting source: %v", err)
+		}
+
+		result.DocumentsCopied, err = documents.CopyCustomerBlobs(ctx, m.bucketFactory, sourceID, targetID)
+		if err != nil {
+			return fmt.Errorf("merge: %v", err)
+		}
+		return recordMerge(tx, result, diff)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := documents.DeleteCustomerBlobs(ctx, m.bucketFactory, sourceID); err != nil {
+		m.logger.Set("customerID", log.String(sourceID)).LogErrorf("problem removing merged documents: %v", err)
+	}
+	return result, nil
+}
+
+// MergedInto returns the Customer a deleted Customer was merged into, or an empty string if they weren't merged
+func (m *Merger) MergedInto(ctx context.Context, customerID, organization string) (string, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	var target *string
+	query := `select merged_into from customers where customer_id = ? and organization = ? limit 1;`
+	if err := m.db.QueryRowContext(ctx, query, customerID, organization).Scan(&target); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("MergedInto: %v", err)
+	}
+	if target == nil {
+		return "", nil
+	}
+	return *target, nil
+}
+
+// fields are the customers columns filled on the target when they're blank, along with the name
+// they're recorded under in the audit log. Columns sharing a name are blank only when each of them
+// is, such as an email stored in either email or encrypted_email.
+var fields = []struct {
+	column, name string
+}{
+	{"first_name", "firstName"},
+	{"middle_name", "middleName"},
+	{"last_name", "lastName"},
+	{"nick_name", "nickName"},
+	{"suffix", "suffix"},
+	{"email", "email"},
+	{"encrypted_email", "email"},
+	{"birth_date", "birthDate"},
+	{"business_name", "businessName"},
+	{"doing_business_as", "doingBusinessAs"},
+	{"business_type", "businessType"},
+	{"ein", "EIN"},
+	{"duns", "DUNS"},
+	{"sic_code", "SICCode"},
+	{"naics_code", "NAICSCode"},
+	{"website", "website"},
+	{"date_business_established", "dateBusinessEstablished"},
+}
+
+// customer holds the columns of a customers row which are merged
+type customer struct {
+	customerID string
+	values     []interface{}
+}
+
+func readCustomer(ctx context.Context, tx *sql.Tx, customerID, organization string) (*customer, error) {
+	columns := make([]string, len(fields))
+	for i := range fields {
+		columns[i] = fields[i].column
+	}
+	query := fmt.Sprintf(`select %s from customers where customer_id = ? and organization = ? and deleted_at is null limit 1;`, strings.Join(columns, ", "))
+
+	cust := &customer{customerID: customerID, values: make([]interface{}, len(fields))}
+	dest := make([]interface{}, len(fields))
+	for i := range dest {
+		dest[i] = &cust.values[i]
+	}
+	if err := tx.QueryRowContext(ctx, query, customerID, organization).Scan(dest...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errCustomerNotFound
+		}
+		return nil, fmt.Errorf("merge: reading customer=%s: %v", customerID, err)
+	}
+	return cust, nil
+}
+
+// blank returns true when each of the customer's columns recorded under name is blank
+func (c *customer) blank(name string) bool {
+	for i := range fields {
+		if fields[i].name == name && !blank(c.values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func blank(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []byte:
+		return strings.TrimSpace(string(v)) == ""
+	case time.Time:
+		return v.IsZero()
+	}
+	return false
+}
+
+// mergeFields fills each of the target's blank fields from the source
+func mergeFields(ctx context.Context, tx *sql.Tx, source, target *customer, now time.Time) (audit.Diff, error) {
+	diff := make(audit.Diff)
+
+	var sets []string
+	var args []interface{}
+	for i := range fields {
+		if target.blank(fields[i].name) && !blank(source.values[i]) {
+			sets = append(sets, fields[i].column+" = ?")
+			args = append(args, source.values[i])
+			diff[fields[i].name] = audit.Change{From: auditValue(target.values[i]), To: auditValue(source.values[i])}
+		}
+	}
+	if len(sets) == 0 {
+		return diff, nil
+	}
+
+	query := fmt.Sprintf(`update customers set %s, last_modified = ?, version = version + 1 where customer_id = ?;`, strings.Join(sets, ", "))
+	args = append(args, now, target.customerID)
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return nil, fmt.Errorf("merge: updating target: %v", err)
+	}
+	return diff, nil
+}
+
+func auditValue(v interface{}) interface{} {
+	if bs, ok := v.([]byte); ok {
+		return string(bs)
+	}
+	return v
+}
+
+// moveRecords reassigns the source's child records to the target. Rows which would duplicate one the
+// target already has are left with the source.
+func moveRecords(ctx context.Context, tx *sql.Tx, fields *secrets.FieldEncryptor, sourceID, targetID string, result *Result) error {
+	// Only one address can be primary, so the source's primary address is demoted if the target has one
+	var primary int
+	err := tx.QueryRowContext(ctx, `select count(*) from addresses where owner_id = ? and owner_type = 'customer' and type = 'primary' and deleted_at is null;`, targetID).Scan(&primary)
+	if err != nil {
+		return fmt.Errorf("merge: reading primary address: %v", err)
+	}
+	if primary > 0 {
+		query := `update addresses set type = 'secondary', last_modified = ? where owner_id = ? and owner_type = 'customer' and type = 'primary';`
+		if _, err := tx.ExecContext(ctx, query, time.Now(), sourceID); err != nil {
+			return fmt.Errorf("merge: demoting primary address: %v", err)
+		}
+	}
+
+	// The same goes for the primary email
+	err = tx.QueryRowContext(ctx, `select count(*) from customers_emails where customer_id = ? and is_primary = ? and deleted_at is null;`, targetID, true).Scan(&primary)
+	if err != nil {
+		return fmt.Errorf("merge: reading primary email: %v", err)
+	}
+	if primary > 0 {
+		query := `update customers_emails set is_primary = ?, last_modified = ? where customer_id = ? and is_primary = ?;`
+		if _, err := tx.ExecContext(ctx, query, false, time.Now(), sourceID, true); err != nil {
+			return fmt.Errorf("merge: demoting primary email: %v", err)
+		}
+	}
+
+	moves := []struct {
+		table, column, extra string
+
+		// key identifies each of the source's rows returned by conflicts, a query for the rows the
+		// target already has. Tables without conflicts leave both empty.
+		key, conflicts string
+
+		// findConflicts replaces conflicts for rows which can't be compared in SQL
+		findConflicts func(tx *sql.Tx, targetID, sourceID string) ([]string, error)
+	}{
+		{
+			table: "phones", column: "owner_id", extra: "owner_type = 'customer'", key: "coalesce(encrypted_number, number)",
+			findConflicts: func(tx *sql.Tx, targetID, sourceID string) ([]string, error) {
+				return phoneConflicts(ctx, tx, fields, targetID, sourceID)
+			},
+		},
+		{
+			table: "addresses", column: "owner_id", extra: "owner_type = 'customer'", key: "address1",
+			conflicts: `select s.address1 from addresses s join addresses t on t.owner_id = ? and t.owner_type = 'customer' and t.address1 = s.address1
+where s.owner_id = ? and s.owner_type = 'customer';`,
+		},
+		{
+			table: "ssn", column: "owner_id", extra: "owner_type = 'customer'", key: "owner_id",
+			conflicts: `select s.owner_id from ssn s join ssn t on t.owner_id = ? and t.owner_type = 'customer'
+where s.owner_id = ? and s.owner_type = 'customer';`,
+		},
+		{
+			table: "customer_metadata", column: "customer_id", key: "meta_key",
+			conflicts: `select s.meta_key from customer_metadata s join customer_metadata t on t.customer_id = ? and t.meta_key = s.meta_key
+where s.customer_id = ?;`,
+		},
+		{
+			table: "customer_tags", column: "customer_id", key: "tag_id",
+			conflicts: `select s.tag_id from customer_tags s join customer_tags t on t.customer_id = ? and t.tag_id = s.tag_id
+where s.customer_id = ?;`,
+		},
+		{
+			table: "disclaimer_acceptances", column: "customer_id", key: "disclaimer_id",
+			// acceptances of other versions of a Disclaimer the target already accepted stay with the source
+			conflicts: `select s.disclaimer_id from disclaimer_acceptances s join disclaimer_acceptances t on t.customer_id = ? and t.disclaimer_id = s.disclaimer_id and t.version = s.version
+where s.customer_id = ?;`,
+		},
+		{
+			table: "accounts", column: "customer_id", key: "account_id",
+			conflicts: `select s.account_id from accounts s join accounts t on t.customer_id = ? and t.sha256_account_number = s.sha256_account_number and t.routing_number = s.routing_number
+where s.customer_id = ?;`,
+		},
+		{
+			table: "customer_contact_preferences", column: "customer_id", key: "customer_id",
+			conflicts: `select s.customer_id from customer_contact_preferences s join customer_contact_preferences t on t.customer_id = ?
+where s.customer_id = ?;`,
+		},
+		{
+			table: "customers_emails", column: "customer_id", key: "coalesce(encrypted_email, email)",
+			conflicts: `select coalesce(s.encrypted_email, s.email) from customers_emails s join customers_emails t on t.customer_id = ? and coalesce(t.encrypted_email, t.email) = coalesce(s.encrypted_email, s.email)
+where s.customer_id = ?;`,
+		},
+		{table: "customer_ofac_searches", column: "customer_id"},
+		{table: "documents", column: "customer_id"},
+		{table: "customer_notes", column: "customer_id"},
+		{table: "representatives", column: "customer_id"},
+		{table: "representative_status_updates", column: "customer_id"},
+	}
+	for _, mv := range moves {
+		where := mv.column + " = ?"
+		if mv.extra != "" {
+			where += " and " + mv.extra
+		}
+
+		var conflicts []interface{}
+		if mv.conflicts != "" || mv.findConflicts != nil {
+			var ids []string
+			var err error
+			if mv.findConflicts != nil {
+				ids, err = mv.findConflicts(tx, targetID, sourceID)
+			} else {
+				ids, err = selectStrings(ctx, tx, mv.conflicts, targetID, sourceID)
+			}
+			if err != nil {
+				return fmt.Errorf("merge: reading %s: %v", mv.table, err)
+			}
+			for i := range ids {
+				conflicts = append(conflicts, ids[i])
+			}
+		}
+
+		query := fmt.Sprintf(`update %s set %s = ? where %s`, mv.table, mv.column, where)
+		args := []interface{}{targetID, sourceID}
+		if len(conflicts) > 0 {
+			query += fmt.Sprintf(` and %s not in (?%s)`, mv.key, strings.Repeat(", ?", len(conflicts)-1))
+			args = append(args, conflicts...)
+		}
+		res, err := tx.ExecContext(ctx, query+";", args...)
+		if err != nil {
+			return fmt.Errorf("merge: moving %s: %v", mv.table, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			result.Moved[mv.table] = int(n)
+		}
+		if len(conflicts) > 0 {
+			result.Skipped[mv.table] = len(conflicts)
+		}
+	}
+	return moveRelationships(ctx, tx, sourceID, targetID, result)
+}
+
+// moveRelationships points the source's relationships, from either side, at the target. Relationships
+// between the source and target would relate the target to itself, and others the target already has
+// would be duplicated, so both are dropped rather than left with the source. They're counted as skipped.
+func moveRelationships(ctx context.Context, tx *sql.Tx, sourceID, targetID string, result *Result) error {
+	const table = "customer_relationships"
+
+	var dropped []interface{}
+	seen := make(map[string]bool)
+	for _, q := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{
+			query: `select relationship_id from customer_relationships where (customer_id = ? and related_customer_id = ?) or (customer_id = ? and related_customer_id = ?);`,
+			args:  []interface{}{sourceID, targetID, targetID, sourceID},
+		},
+		{
+			query: `select s.relationship_id from customer_relationships s join customer_relationships t on t.customer_id = ? and t.related_customer_id = s.related_customer_id and t.relationship_type = s.relationship_type
+where s.customer_id = ?;`,
+			args: []interface{}{targetID, sourceID},
+		},
+		{
+			query: `select s.relationship_id from customer_relationships s join customer_relationships t on t.related_customer_id = ? and t.customer_id = s.customer_id and t.relationship_type = s.relationship_type
+where s.related_customer_id = ?;`,
+			args: []interface{}{targetID, sourceID},
+		},
+	} {
+		ids, err := selectStrings(ctx, tx, q.query, q.args...)
+		if err != nil {
+			return fmt.Errorf("merge: reading %s: %v", table, err)
+		}
+		for i := range ids {
+			if !seen[ids[i]] {
+				seen[ids[i]] = true
+				dropped = append(dropped, ids[i])
+			}
+		}
+	}
+	if len(dropped) > 0 {
+		query := fmt.Sprintf(`delete from customer_relationships where relationship_id in (?%s);`, strings.Repeat(", ?", len(dropped)-1))
+		if _, err := tx.ExecContext(ctx, query, dropped...); err != nil {
+			return fmt.Errorf("merge: dropping %s: %v", table, err)
+		}
+		result.Skipped[table] = len(dropped)
+	}
+
+	var moved int64
+	for _, column := range []string{"customer_id", "related_customer_id"} {
+		query := fmt.Sprintf(`update customer_relationships set %s = ? where %s = ?;`, column, column)
+		res, err := tx.ExecContext(ctx, query, targetID, sourceID)
+		if err != nil {
+			return fmt.Errorf("merge: moving %s: %v", table, err)
+		}
+		n, _ := res.RowsAffected()
+		moved += n
+	}
+	if moved > 0 {
+		result.Moved[table] = int(moved)
+	}
+	return nil
+}
+
+// phoneConflicts returns the stored number of each of the source's phones the target already has.
+// Encrypted numbers are randomized, so both Customers' phones are decrypted and compared.
+func phoneConflicts(ctx context.Context, tx *sql.Tx, fields *secrets.FieldEncryptor, targetID, sourceID string) ([]string, error) {
+	read := func(ownerID string) (map[string]string, error) {
+		rows, err := tx.QueryContext(ctx, `select number, encrypted_number from phones where owner_id = ? and owner_type = 'customer';`, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		out := make(map[string]string) // plaintext to stored number
+		for rows.Next() {
+			var number, encrypted sql.NullString
+			if err := rows.Scan(&number, &encrypted); err != nil {
+				return nil, err
+			}
+			stored := number.String
+			if encrypted.String != "" {
+				stored = encrypted.String
+			}
+			plain, err := fields.Decrypt(stored)
+			if err != nil {
+				return nil, err
+			}
+			out[plain] = stored
+		}
+		return out, rows.Err()
+	}
+
+	target, err := read(targetID)
+	if err != nil {
+		return nil, err
+	}
+	source, err := read(sourceID)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for plain, stored := range source {
+		if _, exists := target[plain]; exists {
+			out = append(out, stored)
+		}
+	}
+	return out, nil
+}
+
+func selectStrings(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var v sql.NullString
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, v.String)
+	}
+	return out, rows.Err()
+}
+
+// recordMerge adds an entry to the audit log of both Customers
+func recordMerge(tx *sql.Tx, result *Result, diff audit.Diff) error {
+	diff["mergedFrom"] = audit.Change{To: result.SourceCustomerID}
+	err := audit.Record(tx, &audit.Entry{
+		Actor:        result.MergedBy,
+		Action:       "POST /customers/{customerID}/merge",
+		CustomerID:   result.TargetCustomerID,
+		Organization: result.Organization,
+		RequestID:    result.RequestID,
+		Diff:         diff,
+		CreatedAt:    result.MergedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return audit.Record(tx, &audit.Entry{
+		Actor:        result.MergedBy,
+		Action:       "POST /customers/{customerID}/merge",
+		CustomerID:   result.SourceCustomerID,
+		Organization: result.Organization,
+		RequestID:    result.RequestID,
+		Diff:         audit.Diff{"mergedInto": audit.Change{To: result.TargetCustomerID}},
+		CreatedAt:    result.MergedAt,
+	})
+}
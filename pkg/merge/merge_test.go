@@ -0,0 +1,292 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package merge
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/fileblob"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+func testBucket(t *testing.T) func() (*blob.Bucket, error) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "merge")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return func() (*blob.Bucket, error) {
+		return fileblob.OpenBucket(dir, nil)
+	}
+}
+
+func countRows(t *testing.T, db *sql.DB, table, column, id string) int {
+	t.Helper()
+
+	var n int
+	require.NoError(t, db.QueryRow(fmt.Sprintf("select count(*) from %s where %s = ?;", table, column), id).Scan(&n))
+	return n
+}
+
+func TestMerger(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t).DB
+	bucketFactory := testBucket(t)
+	repo := customers.NewCustomerRepo(log.NewNopLogger(), db)
+
+	target := &client.Customer{
+		CustomerID: base.ID(),
+		FirstName:  "Jane",
+		LastName:   "Doe",
+		Phones: []client.Phone{
+			{Number: "+15555551234", Type: client.PHONETYPE_MOBILE},
+		},
+		Addresses: []client.Address{
+			{AddressID: base.ID(), Type: client.ADDRESSTYPE_PRIMARY, Address1: "123 1st St", City: "Anytown", State: "CA", PostalCode: "90210", Country: "US"},
+		},
+	}
+	require.NoError(t, repo.CreateCustomer(context.Background(), target, "test"))
+
+	source := &client.Customer{
+		CustomerID: base.ID(),
+		FirstName:  "Janet",
+		MiddleName: "Q",
+		LastName:   "Doe",
+		Email:      "jane@example.com",
+		Phones: []client.Phone{
+			{Number: "+15555551234", Type: client.PHONETYPE_MOBILE},
+			{Number: "+15555550000", Type: client.PHONETYPE_HOME},
+		},
+		Addresses: []client.Address{
+			{AddressID: base.ID(), Type: client.ADDRESSTYPE_PRIMARY, Address1: "9 Elm St", City: "Othertown", State: "NY", PostalCode: "10001", Country: "US"},
+		},
+	}
+	require.NoError(t, repo.CreateCustomer(context.Background(), source, "test"))
+
+	for _, meta := range [][3]string{{target.CustomerID, "source", "web"}, {source.CustomerID, "source", "branch"}, {source.CustomerID, "crm", "1234"}} {
+		_, err := db.Exec(`insert into customer_metadata (customer_id, meta_key, meta_value) values (?, ?, ?);`, meta[0], meta[1], meta[2])
+		require.NoError(t, err)
+	}
+
+	documentID := base.ID()
+	_, err := db.Exec(`insert into documents (document_id, customer_id, type, content_type, uploaded_at) values (?, ?, 'DriversLicense', 'image/png', ?);`, documentID, source.CustomerID, time.Now())
+	require.NoError(t, err)
+	_, err = db.Exec(`insert into customer_ofac_searches (customer_id, entity_id, sdn_name, sdn_type, percentage_match, blocked, created_at) values (?, '123', 'Jane Doe', 1, 0.5, false, ?);`, source.CustomerID, time.Now())
+	require.NoError(t, err)
+
+	bucket, err := bucketFactory()
+	require.NoError(t, err)
+	require.NoError(t, bucket.WriteAll(context.Background(), fmt.Sprintf("customers/%s/documents/%s", source.CustomerID, documentID), []byte("doc"), nil))
+	bucket.Close()
+
+	merger := NewMerger(log.NewNopLogger(), db, bucketFactory, nil)
+
+	_, err = merger.Merge(context.Background(), target.CustomerID, target.CustomerID, "test", "operator", "")
+	require.Equal(t, errSameCustomer, err)
+	_, err = merger.Merge(context.Background(), source.CustomerID, target.CustomerID, "other", "operator", "")
+	require.Equal(t, errCustomerNotFound, err)
+
+	result, err := merger.Merge(context.Background(), source.CustomerID, target.CustomerID, "test", "operator", "req-1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"middleName", "email"}, result.Fields)
+	require.Equal(t, map[string]int{
+		"phones":                 1,
+		"addresses":              1,
+		"customer_metadata":      1,
+		"customer_ofac_searches": 1,
+		"customers_emails":       1,
+		"documents":              1,
+	}, result.Moved)
+	require.Equal(t, map[string]int{"phones": 1, "customer_metadata": 1}, result.Skipped)
+	require.Equal(t, 1, result.DocumentsCopied)
+
+	// the target's fields win unless they were blank
+	merged, err := repo.GetCustomer(context.Background(), target.CustomerID, "test")
+	require.NoError(t, err)
+	require.Equal(t, "Jane", merged.FirstName)
+	require.Equal(t, "Q", merged.MiddleName)
+	require.Equal(t, "jane@example.com", merged.Email)
+	require.Len(t, merged.Phones, 2)
+	require.Len(t, merged.Addresses, 2)
+	require.Equal(t, map[string]string{"source": "web", "crm": "1234"}, merged.Metadata)
+	for _, addr := range merged.Addresses {
+		if addr.Address1 == "9 Elm St" {
+			require.Equal(t, client.ADDRESSTYPE_SECONDARY, addr.Type)
+		}
+	}
+	require.Equal(t, 1, countRows(t, db, "documents", "customer_id", target.CustomerID))
+	require.Equal(t, 1, countRows(t, db, "customer_ofac_searches", "customer_id", target.CustomerID))
+
+	// the source is deleted and points to the target
+	cust, err := repo.GetCustomer(context.Background(), source.CustomerID, "test")
+	require.Nil(t, cust)
+	into, err := merger.MergedInto(context.Background(), source.CustomerID, "test")
+	require.NoError(t, err)
+	require.Equal(t, target.CustomerID, into)
+
+	into, err = merger.MergedInto(context.Background(), target.CustomerID, "test")
+	require.NoError(t, err)
+	require.Empty(t, into)
+
+	// both customers have an audit entry
+	require.Equal(t, 1, countRows(t, db, "audit_log", "customer_id", target.CustomerID))
+	require.Equal(t, 1, countRows(t, db, "audit_log", "customer_id", source.CustomerID))
+
+	// documents moved in the bucket
+	bucket, err = bucketFactory()
+	require.NoError(t, err)
+	defer bucket.Close()
+	exists, err := bucket.Exists(context.Background(), fmt.Sprintf("customers/%s/documents/%s", target.CustomerID, documentID))
+	require.NoError(t, err)
+	require.True(t, exists)
+	exists, err = bucket.Exists(context.Background(), fmt.Sprintf("customers/%s/documents/%s", source.CustomerID, documentID))
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// a deleted source can't be merged again
+	_, err = merger.Merge(context.Background(), source.CustomerID, target.CustomerID, "test", "operator", "")
+	require.Equal(t, errCustomerNotFound, err)
+}
+
+func TestMerger__relationships(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t).DB
+	repo := customers.NewCustomerRepo(log.NewNopLogger(), db)
+
+	ids := make(map[string]string)
+	for _, name := range []string{"source", "target", "x", "y"} {
+		cust := &client.Customer{CustomerID: base.ID(), FirstName: name, LastName: "Doe"}
+		require.NoError(t, repo.CreateCustomer(context.Background(), cust, "test"))
+		ids[name] = cust.CustomerID
+	}
+	relate := func(from, to, kind string) {
+		_, err := db.Exec(`insert into customer_relationships (relationship_id, organization, customer_id, related_customer_id, relationship_type, actor, created_at) values (?, 'test', ?, ?, ?, 'operator', ?);`,
+			base.ID(), ids[from], ids[to], kind, time.Now())
+		require.NoError(t, err)
+	}
+	relate("source", "target", "spouse") // would relate the target to itself
+	relate("source", "x", "parent")      // the target already has it
+	relate("target", "x", "parent")
+	relate("source", "y", "parent")
+	relate("x", "source", "household")
+	relate("y", "source", "household") // the target already has it
+	relate("y", "target", "household")
+
+	merger := NewMerger(log.NewNopLogger(), db, testBucket(t), nil)
+	result, err := merger.Merge(context.Background(), ids["source"], ids["target"], "test", "operator", "")
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Moved["customer_relationships"])
+	require.Equal(t, 3, result.Skipped["customer_relationships"])
+
+	require.Zero(t, countRows(t, db, "customer_relationships", "customer_id", ids["source"]))
+	require.Zero(t, countRows(t, db, "customer_relationships", "related_customer_id", ids["source"]))
+	require.Equal(t, 2, countRows(t, db, "customer_relationships", "customer_id", ids["target"]))
+	require.Equal(t, 2, countRows(t, db, "customer_relationships", "related_customer_id", ids["target"]))
+
+	var loops int
+	require.NoError(t, db.QueryRow(`select count(*) from customer_relationships where customer_id = related_customer_id;`).Scan(&loops))
+	require.Zero(t, loops)
+}
+
+func TestMerger__encryptedFields(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t).DB
+	fields := secrets.TestFieldEncryptor(t)
+	repo := customers.NewCustomerRepoWithEncryption(log.NewNopLogger(), db, fields)
+
+	target := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Email: "jane@example.com",
+		Phones: []client.Phone{{Number: "+15555551234", Type: client.PHONETYPE_MOBILE}}}
+	require.NoError(t, repo.CreateCustomer(context.Background(), target, "test"))
+	source := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Email: "janet@example.com",
+		Phones: []client.Phone{
+			{Number: "+15555551234", Type: client.PHONETYPE_MOBILE},
+			{Number: "+15555550000", Type: client.PHONETYPE_HOME},
+		}}
+	require.NoError(t, repo.CreateCustomer(context.Background(), source, "test"))
+
+	result, err := NewMerger(log.NewNopLogger(), db, testBucket(t), fields).Merge(context.Background(), source.CustomerID, target.CustomerID, "test", "operator", "")
+	require.NoError(t, err)
+	require.Empty(t, result.Fields) // the target's encrypted email isn't blank
+	require.Equal(t, 1, result.Moved["phones"])
+	require.Equal(t, 1, result.Skipped["phones"])
+
+	merged, err := repo.GetCustomer(context.Background(), target.CustomerID, "test")
+	require.NoError(t, err)
+	require.Equal(t, "jane@example.com", merged.Email)
+	require.Len(t, merged.Phones, 2)
+}
+
+func TestMerger__routes(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t).DB
+	repo := customers.NewCustomerRepo(log.NewNopLogger(), db)
+
+	target := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe"}
+	require.NoError(t, repo.CreateCustomer(context.Background(), target, "test"))
+	source := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Email: "jane@example.com"}
+	require.NoError(t, repo.CreateCustomer(context.Background(), source, "test"))
+
+	svc := admin.NewServer(":0")
+	defer svc.Shutdown()
+	AddAdminRoutes(log.NewNopLogger(), svc, NewMerger(log.NewNopLogger(), db, testBucket(t), nil), nil)
+	go svc.Listen()
+
+	do := func(method, customerID, userID, body string) *http.Response {
+		req, err := http.NewRequest(method, fmt.Sprintf("http://%s/customers/%s/merge", svc.BindAddr(), customerID), bytes.NewReader([]byte(body)))
+		require.NoError(t, err)
+		req.Header.Set("x-organization", "test")
+		if userID != "" {
+			req.Header.Set("x-user-id", userID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	body := fmt.Sprintf(`{"sourceCustomerID": %q}`, source.CustomerID)
+
+	resp := do("POST", target.CustomerID, "", body)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = do("POST", target.CustomerID, "operator", `{}`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = do("GET", source.CustomerID, "", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = do("POST", target.CustomerID, "operator", body)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result Result
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(t, source.CustomerID, result.SourceCustomerID)
+	require.Equal(t, "operator", result.MergedBy)
+	require.Equal(t, []string{"email"}, result.Fields)
+
+	resp = do("GET", source.CustomerID, "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var into mergedInto
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&into))
+	require.Equal(t, target.CustomerID, into.TargetCustomerID)
+
+	resp = do("POST", target.CustomerID, "operator", body)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package merge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/moov-io/base/admin"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/route"
+	"github.com/moov-io/customers/pkg/webhooks"
+)
+
+// AddAdminRoutes registers the merge endpoint on the admin server. Merges are attributed to an operator
+// with the X-User-ID header and the source Customer's deletion is sent to notifier.
+func AddAdminRoutes(logger log.Logger, svc *admin.Server, merger *Merger, notifier webhooks.Notifier) {
+	logger = logger.Set("package", log.String("merge"))
+
+	svc.AddHandler("/customers/{customerID}/merge", mergeCustomer(logger, merger, notifier))
+}
+
+type mergeRequest struct {
+	SourceCustomerID string `json:"sourceCustomerID"`
+}
+
+type mergedInto struct {
+	CustomerID       string `json:"customerID"`
+	TargetCustomerID string `json:"targetCustomerID"`
+}
+
+func mergeCustomer(logger log.Logger, merger *Merger, notifier webhooks.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			// customerID is a source, find who it was merged into
+			target, err := merger.MergedInto(r.Context(), customerID, organization)
+			if err != nil {
+				route.Problem(w, err)
+				return
+			}
+			if target == "" {
+				route.NotFound(w, r)
+				return
+			}
+			respond(w, mergedInto{CustomerID: customerID, TargetCustomerID: target})
+
+		case "POST":
+			actor := moovhttp.GetUserID(r)
+			if actor == "" {
+				route.Problem(w, route.Forbidden(errors.New("merging customers requires the X-User-ID header")))
+				return
+			}
+
+			var req mergeRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				route.Problem(w, err)
+				return
+			}
+			if req.SourceCustomerID == "" {
+				route.Problem(w, route.Validation(errors.New("missing sourceCustomerID")))
+				return
+			}
+			logger = logger.Set("customerID", log.String(customerID)).Set("sourceCustomerID", log.String(req.SourceCustomerID)).Set("actor", log.String(actor))
+
+			result, err := merger.Merge(r.Context(), req.SourceCustomerID, customerID, organization, actor, moovhttp.GetRequestID(r))
+			if err != nil {
+				logger.LogErrorf("problem merging customers: %v", err)
+				route.Problem(w, err)
+				return
+			}
+			logger.Logf("merged customer, filled %d fields and copied %d documents", len(result.Fields), result.DocumentsCopied)
+			if notifier != nil {
+				notifier.Notify(webhooks.CustomerDeleted, result.SourceCustomerID, organization, "")
+			}
+
+			respond(w, result)
+
+		default:
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+		}
+	}
+}
+
+func respond(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package notes stores internal notes support agents leave on a Customer. Notes are only served
+// from the admin server and are never included in a Customer's export.
+package notes
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+)
+
+// Note is an internal comment on a Customer
+type Note struct {
+	NoteID     string    `json:"noteID"`
+	CustomerID string    `json:"customerID"`
+	Author     string    `json:"author"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type listParams struct {
+	Skip  int
+	Count int
+}
+
+type Repository interface {
+	customerExists(customerID, organization string) (bool, error)
+
+	addNote(organization string, note *Note) error
+
+	// listNotes returns a page of the Customer's notes newest first along with how many they have
+	listNotes(customerID, organization string, params listParams) ([]*Note, int, error)
+
+	// deleteNote soft deletes a note, returning false when it's missing or already deleted
+	deleteNote(customerID, organization, noteID string) (bool, error)
+}
+
+func NewRepository(logger log.Logger, db *sql.DB) Repository {
+	return &sqlRepository{db: db, logger: logger}
+}
+
+type sqlRepository struct {
+	db     *sql.DB
+	logger log.Logger
+}
+
+func (r *sqlRepository) customerExists(customerID, organization string) (bool, error) {
+	var id string
+	query := `select customer_id from customers where customer_id = ? and organization = ? and deleted_at is null limit 1;`
+	if err := r.db.QueryRow(query, customerID, organization).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("notes: customerExists: %v", err)
+	}
+	return true, nil
+}
+
+func (r *sqlRepository) addNote(organization string, note *Note) error {
+	if note.NoteID == "" {
+		note.NoteID = base.ID()
+	}
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = time.Now()
+	}
+	query := `insert into customer_notes (note_id, customer_id, organization, author, body, created_at) values (?, ?, ?, ?, ?, ?);`
+	if _, err := r.db.Exec(query, note.NoteID, note.CustomerID, organization, note.Author, note.Body, note.CreatedAt); err != nil {
+		return fmt.Errorf("notes: addNote: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) listNotes(customerID, organization string, params listParams) ([]*Note, int, error) {
+	where := ` where customer_id = ? and organization = ? and deleted_at is null`
+
+	var total int
+	if err := r.db.QueryRow(`select count(*) from customer_notes`+where+`;`, customerID, organization).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("notes: listNotes: count: %v", err)
+	}
+
+	query := `select note_id, customer_id, author, body, created_at from customer_notes` + where + ` order by created_at desc, note_id desc limit ? offset ?;`
+	rows, err := r.db.Query(query, customerID, organization, params.Count, params.Skip)
+	if err != nil {
+		return nil, 0, fmt.Errorf("notes: listNotes: query: %v", err)
+	}
+	defer rows.Close()
+
+	out := make([]*Note, 0)
+	for rows.Next() {
+		var note Note
+		if err := rows.Scan(&note.NoteID, &note.CustomerID, &note.Author, &note.Body, &note.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("notes: listNotes: scan: %v", err)
+		}
+		out = append(out, &note)
+	}
+	return out, total, rows.Err()
+}
+
+func (r *sqlRepository) deleteNote(customerID, organization, noteID string) (bool, error) {
+	query := `update customer_notes set deleted_at = ? where note_id = ? and customer_id = ? and organization = ? and deleted_at is null;`
+	res, err := r.db.Exec(query, time.Now(), noteID, customerID, organization)
+	if err != nil {
+		return false, fmt.Errorf("notes: deleteNote: %v", err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+)
+
+func TestNotes(t *testing.T) {
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	cust := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customers.NewCustomerRepo(logger, db.DB).CreateCustomer(context.Background(), cust, "test"))
+
+	svc := admin.NewServer(":0")
+	defer svc.Shutdown()
+	AddAdminRoutes(logger, svc, NewRepository(logger, db.DB))
+	go svc.Listen()
+
+	do := func(method, path, organization, userID, body string) *http.Response {
+		req, err := http.NewRequest(method, fmt.Sprintf("http://%s%s", svc.BindAddr(), path), strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("x-organization", organization)
+		if userID != "" {
+			req.Header.Set("x-user-id", userID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	notesPath := fmt.Sprintf("/customers/%s/notes", cust.CustomerID)
+
+	resp := do("POST", notesPath, "test", "", `{"body": "called about their account"}`)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = do("POST", notesPath, "test", "agent", `{"body": "  "}`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = do("POST", notesPath, "test", "agent", fmt.Sprintf(`{"body": %q}`, strings.Repeat("a", maxBodyLength+1)))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = do("POST", notesPath, "other", "agent", `{"body": "called about their account"}`)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	var added []Note
+	for _, body := range []string{"first", "second", "third"} {
+		resp = do("POST", notesPath, "test", "agent", fmt.Sprintf(`{"body": %q}`, body))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var note Note
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&note))
+		require.Equal(t, "agent", note.Author)
+		require.Equal(t, cust.CustomerID, note.CustomerID)
+		added = append(added, note)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	list := func(query string) ([]Note, string) {
+		resp := do("GET", notesPath+query, "test", "", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var notes []Note
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&notes))
+		return notes, resp.Header.Get(totalCountHeaderKey)
+	}
+
+	// newest first, with pages
+	notes, total := list("")
+	require.Equal(t, "3", total)
+	require.Len(t, notes, 3)
+	require.Equal(t, []string{"third", "second", "first"}, []string{notes[0].Body, notes[1].Body, notes[2].Body})
+
+	notes, total = list("?skip=1&count=1")
+	require.Equal(t, "3", total)
+	require.Len(t, notes, 1)
+	require.Equal(t, "second", notes[0].Body)
+
+	// deleted notes are hidden
+	notePath := fmt.Sprintf("%s/%s", notesPath, added[1].NoteID)
+	resp = do("DELETE", notePath, "other", "agent", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = do("DELETE", notePath, "test", "agent", "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = do("DELETE", notePath, "test", "agent", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	notes, total = list("")
+	require.Equal(t, "2", total)
+	require.Equal(t, []string{"third", "first"}, []string{notes[0].Body, notes[1].Body})
+
+	resp = do("PUT", notesPath, "test", "agent", "")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
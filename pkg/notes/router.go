@@ -0,0 +1,175 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package notes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/admin"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+const (
+	totalCountHeaderKey = "X-Total-Count"
+
+	// maxBodyLength limits how long a note can be
+	maxBodyLength = 4096
+)
+
+// AddAdminRoutes registers the endpoints to add, list and delete a Customer's notes on the admin server.
+// Notes are attributed to the operator in the X-User-ID header.
+func AddAdminRoutes(logger log.Logger, svc *admin.Server, repo Repository) {
+	logger = logger.Set("package", log.String("notes"))
+
+	svc.AddHandler("/customers/{customerID}/notes", customerNotes(logger, repo))
+	svc.AddHandler("/customers/{customerID}/notes/{noteID}", deleteNote(logger, repo))
+}
+
+func customerNotes(logger log.Logger, repo Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		logger = logger.Set("customerID", log.String(customerID))
+
+		switch r.Method {
+		case "GET":
+			listNotes(logger, repo, w, r, customerID, organization)
+		case "POST":
+			addNote(logger, repo, w, r, customerID, organization)
+		default:
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+		}
+	}
+}
+
+type addNoteRequest struct {
+	Body string `json:"body"`
+}
+
+func addNote(logger log.Logger, repo Repository, w http.ResponseWriter, r *http.Request, customerID, organization string) {
+	author := route.GetActor(r)
+	if author == "" {
+		route.Problem(w, route.Forbidden(errors.New("adding notes requires the X-User-ID header")))
+		return
+	}
+
+	var req addNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		route.Problem(w, route.Validation(fmt.Errorf("invalid note: %v", err)))
+		return
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		route.Problem(w, route.Validation(errors.New("missing body")))
+		return
+	}
+	if utf8.RuneCountInString(req.Body) > maxBodyLength {
+		route.Problem(w, route.Validation(fmt.Errorf("body is limited to %d characters", maxBodyLength)))
+		return
+	}
+
+	if !customerExists(logger, repo, w, r, customerID, organization) {
+		return
+	}
+
+	note := &Note{CustomerID: customerID, Author: author, Body: req.Body}
+	if err := repo.addNote(organization, note); err != nil {
+		logger.LogErrorf("problem adding note: %v", err)
+		route.Problem(w, err)
+		return
+	}
+	logger.Logf("added note=%s", note.NoteID)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(note)
+}
+
+func listNotes(logger log.Logger, repo Repository, w http.ResponseWriter, r *http.Request, customerID, organization string) {
+	var params listParams
+	var err error
+	params.Skip, params.Count, _, err = moovhttp.GetSkipAndCount(r)
+	if err != nil {
+		route.Problem(w, route.Validation(err))
+		return
+	}
+
+	if !customerExists(logger, repo, w, r, customerID, organization) {
+		return
+	}
+
+	notes, total, err := repo.listNotes(customerID, organization, params)
+	if err != nil {
+		logger.LogErrorf("problem listing notes: %v", err)
+		route.Problem(w, err)
+		return
+	}
+
+	w.Header().Set(totalCountHeaderKey, fmt.Sprintf("%d", total))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(notes)
+}
+
+func deleteNote(logger log.Logger, repo Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if r.Method != "DELETE" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+		noteID := mux.Vars(r)["noteID"]
+		logger = logger.Set("customerID", log.String(customerID)).Set("noteID", log.String(noteID))
+
+		deleted, err := repo.deleteNote(customerID, organization, noteID)
+		if err != nil {
+			logger.LogErrorf("problem deleting note: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		if !deleted {
+			route.NotFound(w, r)
+			return
+		}
+		logger.Logf("deleted note")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func customerExists(logger log.Logger, repo Repository, w http.ResponseWriter, r *http.Request, customerID, organization string) bool {
+	exists, err := repo.customerExists(customerID, organization)
+	if err != nil {
+		logger.LogErrorf("problem reading customer: %v", err)
+		route.Problem(w, err)
+		return false
+	}
+	if !exists {
+		route.NotFound(w, r)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,242 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package purge permanently erases a Customer's personal information for right to be forgotten requests.
+// Unlike deleting a Customer, which only marks them as deleted, a purge removes their rows and stored documents.
+// A tombstone noting who purged the Customer and when is kept.
+package purge
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/audit"
+	"github.com/moov-io/customers/pkg/documents"
+	"github.com/moov-io/customers/pkg/documents/storage"
+)
+
+var errCustomerNotFound = errors.New("customer not found")
+
+// Tombstone is the record kept after a Customer is purged
+type Tombstone struct {
+	CustomerID       string    `json:"customerID"`
+	Organization     string    `json:"organization"`
+	PurgedBy         string    `json:"purgedBy"`
+	RequestID        string    `json:"requestID,omitempty"`
+	DocumentsDeleted int       `json:"documentsDeleted"`
+	PurgedAt         time.Time `json:"purgedAt"`
+}
+
+// Purger removes a Customer's rows across each table holding their information along with their
+// documents in the storage bucket.
+type Purger struct {
+	db            *sql.DB
+	bucketFactory storage.BucketFunc
+}
+
+func NewPurger(db *sql.DB, bucketFactory storage.BucketFunc) *Purger {
+	return &Purger{
+		db:            db,
+		bucketFactory: bucketFactory,
+	}
+}
+
+// Purge erases the Customer in one transaction. Documents are removed from the bucket before the transaction
+// commits so a failure leaves the database rows in place and the purge can be retried.
+func (p *Purger) Purge(ctx context.Context, customerID, organization, actor, requestID string) (*Tombstone, error) {
+	var tombstone *Tombstone
+	queryCtx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+	err := customersdb.RetryOnLockContext(queryCtx, p.db, func(tx *sql.Tx) error {
+		exists, err := customerExists(queryCtx, tx, customerID, organization)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errCustomerNotFound
+		}
+
+		representativeIDs, err := selectIDs(queryCtx, tx, `select representative_id from representatives where customer_id = ?;`, customerID)
+		if err != nil {
+			return fmt.Errorf("purge: representatives: %v", err)
+		}
+		accountIDs, err := selectIDs(queryCtx, tx, `select account_id from accounts where customer_id = ?;`, customerID)
+		if err != nil {
+			return fmt.Errorf("purge: accounts: %v", err)
+		}
+		documentIDs, err := selectIDs(queryCtx, tx, `select document_id from documents where customer_id = ?;`, customerID)
+		if err != nil {
+			return fmt.Errorf("purge: documents: %v", err)
+		}
+		uploadIDs, err := selectIDs(queryCtx, tx, `select upload_id from document_uploads where customer_id = ?;`, customerID)
+		if err != nil {
+			return fmt.Errorf("purge: document uploads: %v", err)
+		}
+		ownerIDs := append([]string{customerID}, representativeIDs...)
+
+		deletes := []struct {
+			table, column string
+			ids           []string
+		}{
+			{"phones", "owner_id", ownerIDs},
+			{"addresses", "owner_id", ownerIDs},
+			{"ssn", "owner_id", ownerIDs},
+			{"representative_ofac_searches", "representative_id", representativeIDs},
+			{"representative_status_updates", "representative_id", representativeIDs},
+			{"representatives", "customer_id", []string{customerID}},
+			{"validations", "account_id", accountIDs},
+			{"account_ofac_searches", "account_id", accountIDs},
+			{"accounts", "customer_id", []string{customerID}},
+			{"customer_metadata", "customer_id", []string{customerID}},
+			{"customer_tags", "customer_id", []string{customerID}},
+			{"customer_status_updates", "customer_id", []string{customerID}},
+			{"customer_notes", "customer_id", []string{customerID}},
+			{"customer_relationships", "customer_id", []string{customerID}},
+			{"customer_relationships", "related_customer_id", []string{customerID}},
+			{"customer_ofac_searches", "customer_id", []string{customerID}},
+			{"disclaimer_acceptances", "customer_id", []string{customerID}},
+			{"document_metadata", "document_id", documentIDs},
+			{"document_scans", "customer_id", []string{customerID}},
+			{"documents", "customer_id", []string{customerID}},
+			{"customer_avatars", "customer_id", []string{customerID}},
+			{"document_upload_chunks", "upload_id", uploadIDs},
+			{"document_uploads", "customer_id", []string{customerID}},
+			{"outbound_emails", "customer_id", []string{customerID}},
+			{"email_activation_codes", "customer_id", []string{customerID}},
+			{"phone_verifications", "customer_id", []string{customerID}},
+			{"customer_contact_preferences", "customer_id", []string{customerID}},
+			{"customers_emails", "customer_id", []string{customerID}},
+			{"customers", "customer_id", []string{customerID}},
+		}
+		for _, d := range deletes {
+			if err := deleteRows(queryCtx, tx, d.table, d.column, d.ids); err != nil {
+				return err
+			}
+		}
+
+		deleted, err := documents.DeleteCustomerBlobs(ctx, p.bucketFactory, customerID)
+		if err != nil {
+			return fmt.Errorf("purge: %v", err)
+		}
+
+		tombstone = &Tombstone{
+			CustomerID:       customerID,
+			Organization:     organization,
+			PurgedBy:         actor,
+			RequestID:        requestID,
+			DocumentsDeleted: deleted,
+			PurgedAt:         time.Now(),
+		}
+		if err := insertTombstone(queryCtx, tx, tombstone); err != nil {
+			return err
+		}
+		return recordPurge(queryCtx, tx, tombstone)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tombstone, nil
+}
+
+// GetTombstone returns the record of a purged Customer or nil if they haven't been purged
+func (p *Purger) GetTombstone(ctx context.Context, customerID, organization string) (*Tombstone, error) {
+	ctx, cancelFn := customersdb.QueryContext(ctx)
+	defer cancelFn()
+
+	query := `select customer_id, organization, purged_by, request_id, documents_deleted, purged_at from customer_purges
+where customer_id = ? and organization = ? limit 1;`
+	stmt, err := p.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("GetTombstone: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	var t Tombstone
+	var requestID *string
+	err = stmt.QueryRowContext(ctx, customerID, organization).Scan(&t.CustomerID, &t.Organization, &t.PurgedBy, &requestID, &t.DocumentsDeleted, &t.PurgedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("GetTombstone: scan: %v", err)
+	}
+	if requestID != nil {
+		t.RequestID = *requestID
+	}
+	return &t, nil
+}
+
+func customerExists(ctx context.Context, tx *sql.Tx, customerID, organization string) (bool, error) {
+	var id string
+	err := tx.QueryRowContext(ctx, `select customer_id from customers where customer_id = ? and organization = ? limit 1;`, customerID, organization).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("purge: customer: %v", err)
+	}
+	return true, nil
+}
+
+func selectIDs(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func deleteRows(ctx context.Context, tx *sql.Tx, table, column string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(ids))
+	for i := range ids {
+		args[i] = ids[i]
+	}
+	query := fmt.Sprintf(`delete from %s where %s in (?%s);`, table, column, strings.Repeat(", ?", len(ids)-1))
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("purge: delete from %s: %v", table, err)
+	}
+	return nil
+}
+
+// recordPurge removes the field changes held in the Customer's audit log, as they contain personal
+// information, and records the purge.
+func recordPurge(ctx context.Context, tx *sql.Tx, t *Tombstone) error {
+	if _, err := tx.ExecContext(ctx, `update audit_log set diff = null where customer_id = ?;`, t.CustomerID); err != nil {
+		return fmt.Errorf("purge: redacting audit log: %v", err)
+	}
+	return audit.Record(tx, &audit.Entry{
+		Actor:        t.PurgedBy,
+		Action:       "DELETE /customers/{customerID}/purge",
+		CustomerID:   t.CustomerID,
+		Organization: t.Organization,
+		RequestID:    t.RequestID,
+		CreatedAt:    t.PurgedAt,
+	})
+}
+
+func insertTombstone(ctx context.Context, tx *sql.Tx, t *Tombstone) error {
+	query := `insert into customer_purges (customer_id, organization, purged_by, request_id, documents_deleted, purged_at) values (?, ?, ?, ?, ?, ?);`
+	if _, err := tx.ExecContext(ctx, query, t.CustomerID, t.Organization, t.PurgedBy, t.RequestID, t.DocumentsDeleted, t.PurgedAt); err != nil {
+		return fmt.Errorf("purge: tombstone: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package purge
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/fileblob"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+)
+
+func testBucket(t *testing.T) func() (*blob.Bucket, error) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "purge")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return func() (*blob.Bucket, error) {
+		return fileblob.OpenBucket(dir, nil)
+	}
+}
+
+func countRows(t *testing.T, db *sql.DB, table, column, id string) int {
+	t.Helper()
+
+	var n int
+	require.NoError(t, db.QueryRow(fmt.Sprintf("select count(*) from %s where %s = ?;", table, column), id).Scan(&n))
+	return n
+}
+
+func TestPurger(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t).DB
+	bucketFactory := testBucket(t)
+	repo := customers.NewCustomerRepo(log.NewNopLogger(), db)
+
+	cust := &client.Customer{
+		CustomerID: base.ID(),
+		FirstName:  "Jane",
+		LastName:   "Doe",
+		Email:      "jane@example.com",
+		Phones: []client.Phone{
+			{Number: "+15555551234", Type: client.PHONETYPE_MOBILE},
+		},
+		Addresses: []client.Address{
+			{AddressID: base.ID(), Type: client.ADDRESSTYPE_PRIMARY, Address1: "123 1st St", City: "Anytown", State: "CA", PostalCode: "90210", Country: "US"},
+		},
+	}
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, "test"))
+	other := &client.Customer{CustomerID: base.ID(), FirstName: "John", LastName: "Doe"}
+	require.NoError(t, repo.CreateCustomer(context.Background(), other, "test"))
+
+	_, err := db.Exec(`insert into ssn (owner_id, owner_type, ssn, ssn_masked, created_at) values (?, 'customer', 'encrypted', '#####4321', ?);`, cust.CustomerID, time.Now())
+	require.NoError(t, err)
+	_, err = db.Exec(`insert into documents (document_id, customer_id, type, content_type, uploaded_at) values (?, ?, 'DriversLicense', 'image/png', ?);`, base.ID(), cust.CustomerID, time.Now())
+	require.NoError(t, err)
+	_, err = db.Exec(`insert into document_scans (scan_id, customer_id, organization, document_id, type, content_type, size, result, signature, scanned_at) values (?, ?, 'test', ?, 'DriversLicense', 'image/png', 3, 'infected', 'Eicar-Test-Signature', ?);`, base.ID(), cust.CustomerID, base.ID(), time.Now())
+	require.NoError(t, err)
+	_, err = db.Exec(`insert into customer_tags (customer_id, tag_id, created_at) values (?, ?, ?);`, cust.CustomerID, base.ID(), time.Now())
+	require.NoError(t, err)
+	uploadID := base.ID()
+	_, err = db.Exec(`insert into document_uploads (upload_id, customer_id, organization, type, size, created_at, updated_at) values (?, ?, 'test', 'passport', 3, ?, ?);`, uploadID, cust.CustomerID, time.Now(), time.Now())
+	require.NoError(t, err)
+	_, err = db.Exec(`insert into document_upload_chunks (upload_id, byte_offset, size, blob_key, created_at) values (?, 0, 3, 'chunk', ?);`, uploadID, time.Now())
+	require.NoError(t, err)
+
+	bucket, err := bucketFactory()
+	require.NoError(t, err)
+	require.NoError(t, bucket.WriteAll(context.Background(), fmt.Sprintf("customers/%s/documents/%s", cust.CustomerID, base.ID()), []byte("doc"), nil))
+	require.NoError(t, bucket.WriteAll(context.Background(), fmt.Sprintf("customers/%s/uploads/%s/000000000000-%s", cust.CustomerID, uploadID, base.ID()), []byte("doc"), nil))
+	otherKey := fmt.Sprintf("customers/%s/documents/%s", other.CustomerID, base.ID())
+	require.NoError(t, bucket.WriteAll(context.Background(), otherKey, []byte("doc"), nil))
+	bucket.Close()
+
+	purger := NewPurger(db, bucketFactory)
+
+	_, err = purger.Purge(context.Background(), cust.CustomerID, "other", "operator", "")
+	require.Equal(t, errCustomerNotFound, err)
+
+	tombstone, err := purger.Purge(context.Background(), cust.CustomerID, "test", "operator", "req-1")
+	require.NoError(t, err)
+	require.Equal(t, "operator", tombstone.PurgedBy)
+	require.Equal(t, 1, tombstone.DocumentsDeleted)
+
+	for _, tbl := range [][2]string{{"customers", "customer_id"}, {"phones", "owner_id"}, {"addresses", "owner_id"}, {"ssn", "owner_id"}, {"documents", "customer_id"}, {"document_scans", "customer_id"}, {"customer_tags", "customer_id"}, {"document_uploads", "customer_id"}} {
+		require.Zero(t, countRows(t, db, tbl[0], tbl[1], cust.CustomerID), tbl[0])
+	}
+	require.Zero(t, countRows(t, db, "document_upload_chunks", "upload_id", uploadID))
+	require.Equal(t, 1, countRows(t, db, "customers", "customer_id", other.CustomerID))
+	require.Equal(t, 1, countRows(t, db, "audit_log", "customer_id", cust.CustomerID))
+
+	bucket, err = bucketFactory()
+	require.NoError(t, err)
+	defer bucket.Close()
+	exists, err := bucket.Exists(context.Background(), otherKey)
+	require.NoError(t, err)
+	require.True(t, exists)
+	obj, err := bucket.List(&blob.ListOptions{Prefix: fmt.Sprintf("customers/%s/", cust.CustomerID)}).Next(context.Background())
+	require.Nil(t, obj)
+	require.Error(t, err)
+
+	got, err := purger.GetTombstone(context.Background(), cust.CustomerID, "test")
+	require.NoError(t, err)
+	require.Equal(t, "req-1", got.RequestID)
+	require.Equal(t, 1, got.DocumentsDeleted)
+
+	// a second purge finds nothing
+	_, err = purger.Purge(context.Background(), cust.CustomerID, "test", "operator", "")
+	require.Equal(t, errCustomerNotFound, err)
+}
+
+func TestPurger__routes(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t).DB
+	repo := customers.NewCustomerRepo(log.NewNopLogger(), db)
+
+	cust := &client.Customer{CustomerID: base.ID(), FirstName: "Jane", LastName: "Doe"}
+	require.NoError(t, repo.CreateCustomer(context.Background(), cust, "test"))
+
+	svc := admin.NewServer(":0")
+	defer svc.Shutdown()
+	AddAdminRoutes(log.NewNopLogger(), svc, NewPurger(db, testBucket(t)))
+	go svc.Listen()
+
+	do := func(method, userID string) *http.Response {
+		req, err := http.NewRequest(method, fmt.Sprintf("http://%s/customers/%s/purge", svc.BindAddr(), cust.CustomerID), nil)
+		require.NoError(t, err)
+		req.Header.Set("x-organization", "test")
+		if userID != "" {
+			req.Header.Set("x-user-id", userID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := do("DELETE", "")
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = do("GET", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = do("DELETE", "operator")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var tombstone Tombstone
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tombstone))
+	require.Equal(t, cust.CustomerID, tombstone.CustomerID)
+	require.Equal(t, "operator", tombstone.PurgedBy)
+
+	resp = do("GET", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = do("DELETE", "operator")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package purge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/moov-io/base/admin"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// AddAdminRoutes registers the purge endpoint. It's only offered on the admin server, which is not exposed
+// to applications, and every purge must be attributed to an operator with the X-User-ID header.
+func AddAdminRoutes(logger log.Logger, svc *admin.Server, purger *Purger) {
+	logger = logger.Set("package", log.String("purge"))
+
+	svc.AddHandler("/customers/{customerID}/purge", purgeCustomer(logger, purger))
+}
+
+func purgeCustomer(logger log.Logger, purger *Purger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			tombstone, err := purger.GetTombstone(r.Context(), customerID, organization)
+			if err != nil {
+				route.Problem(w, err)
+				return
+			}
+			if tombstone == nil {
+				route.NotFound(w, r)
+				return
+			}
+			respondWithTombstone(w, tombstone)
+
+		case "DELETE":
+			actor := moovhttp.GetUserID(r)
+			if actor == "" {
+				route.Problem(w, route.Forbidden(errors.New("purging a customer requires the X-User-ID header")))
+				return
+			}
+			logger = logger.Set("customerID", log.String(customerID)).Set("actor", log.String(actor))
+
+			tombstone, err := purger.Purge(r.Context(), customerID, organization, actor, moovhttp.GetRequestID(r))
+			if err == errCustomerNotFound {
+				route.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				logger.LogErrorf("problem purging customer: %v", err)
+				route.Problem(w, err)
+				return
+			}
+			logger.Logf("purged customer and %d documents", tombstone.DocumentsDeleted)
+
+			respondWithTombstone(w, tombstone)
+
+		default:
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+		}
+	}
+}
+
+func respondWithTombstone(w http.ResponseWriter, tombstone *Tombstone) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tombstone)
+}
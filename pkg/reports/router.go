@@ -7,7 +7,6 @@ import (
 	"strings"
 
 	"github.com/gorilla/mux"
-	moovhttp "github.com/moov-io/base/http"
 	"github.com/moov-io/base/log"
 
 	"github.com/moov-io/customers/pkg/accounts"
@@ -39,6 +38,7 @@ func getCustomerAccounts(
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
 
 		accountIDsInput := r.URL.Query().Get("accountIDs")
 		accountIDsInput = strings.TrimSpace(accountIDsInput)
@@ -50,23 +50,23 @@ func getCustomerAccounts(
 
 		limit := 25
 		if len(accountIDs) > limit {
-			moovhttp.Problem(w, fmt.Errorf("exceeded limit of %d accountIDs, found %d", limit, len(accountIDs)))
+			route.Problem(w, fmt.Errorf("exceeded limit of %d accountIDs, found %d", limit, len(accountIDs)))
 			return
 		}
 
-		allAccounts, err := accountRepo.GetCustomerAccountsByIDs(accountIDs)
+		allAccounts, err := accountRepo.GetCustomerAccountsByIDs(r.Context(), accountIDs)
 		if err != nil {
 			logger.LogErrorf("error getting customers' accounts: %v", err)
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 
 		results := make([]*client.ReportAccountResponse, 0)
 		for _, acc := range allAccounts {
-			cust, err := customerRepo.GetCustomer(acc.CustomerID, organization)
+			cust, err := customerRepo.GetCustomer(r.Context(), acc.CustomerID, organization)
 			if err != nil {
 				logger.LogErrorf("error getting customer: %v", err)
-				moovhttp.Problem(w, err)
+				route.Problem(w, err)
 				return
 			}
 			results = append(results, &client.ReportAccountResponse{
@@ -78,7 +78,7 @@ func getCustomerAccounts(
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
 		if err := json.NewEncoder(w).Encode(results); err != nil {
-			moovhttp.Problem(w, err)
+			route.Problem(w, err)
 			return
 		}
 	}
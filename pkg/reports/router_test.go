@@ -1,6 +1,7 @@
 package reports
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -47,10 +48,10 @@ func TestGetAccounts(t *testing.T) {
 			CustomerID: base.ID(),
 			FirstName:  fmt.Sprintf("%d", i),
 		}
-		err := customerRepo.CreateCustomer(cust, organization)
+		err := customerRepo.CreateCustomer(context.Background(), cust, organization)
 		require.NoError(t, err)
 
-		account, err := accountRepo.CreateCustomerAccount(
+		account, err := accountRepo.CreateCustomerAccount(context.Background(),
 			cust.CustomerID,
 			"test-user-id",
 			&accounts.CreateAccountRequest{},
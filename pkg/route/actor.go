@@ -0,0 +1,90 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	moovhttp "github.com/moov-io/base/http"
+)
+
+// Scopes granted to callers. Each scope includes the ones before it, so customers:admin can
+// also read and write.
+const (
+	ScopeRead  = "customers:read"
+	ScopeWrite = "customers:write"
+	ScopeAdmin = "customers:admin"
+)
+
+var scopeIncludes = map[string][]string{
+	ScopeRead:  {ScopeRead, ScopeWrite, ScopeAdmin},
+	ScopeWrite: {ScopeWrite, ScopeAdmin},
+	ScopeAdmin: {ScopeAdmin},
+}
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	// Subject is the user or service the credentials were issued to
+	Subject string
+
+	// Organization limits requests to one X-Organization when set
+	Organization string
+
+	Scopes []string
+}
+
+// HasScope returns true when the Identity was granted scope, or a scope which includes it
+func (id *Identity) HasScope(scope string) bool {
+	if id == nil {
+		return false
+	}
+	accepted, ok := scopeIncludes[scope]
+	if !ok {
+		accepted = []string{scope}
+	}
+	for i := range id.Scopes {
+		for j := range accepted {
+			if id.Scopes[i] == accepted[j] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx holding id
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// GetIdentity returns the authenticated caller of r, or nil when authentication is disabled
+func GetIdentity(r *http.Request) *Identity {
+	id, _ := r.Context().Value(identityKey{}).(*Identity)
+	return id
+}
+
+// RequireScope writes a 403 Forbidden error to w and returns false when the authenticated caller
+// wasn't granted scope. Every request is allowed when authentication is disabled.
+func RequireScope(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if id := GetIdentity(r); id == nil || id.HasScope(scope) {
+		return true
+	}
+	Problem(w, Forbidden(fmt.Errorf("missing %s scope", scope)))
+	return false
+}
+
+// GetActor returns the identity making a request. That's the authenticated subject when
+// authentication is enabled, otherwise it's provided by the upstream gateway in the X-User-ID header.
+func GetActor(r *http.Request) string {
+	if id := GetIdentity(r); id != nil && strings.TrimSpace(id.Subject) != "" {
+		return id.Subject
+	}
+	return moovhttp.GetUserID(r)
+}
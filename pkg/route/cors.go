@@ -0,0 +1,145 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CORSConfig controls which browser origins can call the API. With no AllowedOrigins no CORS
+// headers are written, so browsers block every cross-origin request.
+type CORSConfig struct {
+	// AllowedOrigins are compared exactly against the Origin header, e.g. https://admin.example.com
+	// A single "*" allows any origin but can't be combined with AllowCredentials.
+	AllowedOrigins []string
+
+	AllowedMethods []string
+	AllowedHeaders []string
+
+	// ExposedHeaders are response headers scripts are allowed to read
+	ExposedHeaders []string
+
+	// AllowCredentials lets browsers send cookies and Authorization headers
+	AllowCredentials bool
+
+	// MaxAge is how long browsers cache a preflight response
+	MaxAge time.Duration
+}
+
+var (
+	DefaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	DefaultCORSHeaders = []string{"Authorization", "Content-Type", "X-Organization", "X-Request-ID", "Idempotency-Key", "If-Match", "Upload-Offset"}
+	DefaultCORSExposed = []string{"X-Next-Cursor", "X-Request-ID", "ETag", "Upload-Offset"}
+
+	errCORSWildcardCredentials = errors.New("CORS: a wildcard origin can't be used when credentials are allowed")
+)
+
+// CORS answers preflight requests and adds Access-Control-* headers to the responses of
+// allowed origins
+type CORS struct {
+	cfg CORSConfig
+
+	origins map[string]bool
+	methods map[string]bool
+	headers map[string]bool
+}
+
+func NewCORS(cfg CORSConfig) (*CORS, error) {
+	c := &CORS{
+		cfg:     cfg,
+		origins: make(map[string]bool),
+		methods: make(map[string]bool),
+		headers: make(map[string]bool),
+	}
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" && cfg.AllowCredentials {
+			return nil, errCORSWildcardCredentials
+		}
+		c.origins[strings.TrimSuffix(o, "/")] = true
+	}
+	for _, m := range cfg.AllowedMethods {
+		c.methods[strings.ToUpper(m)] = true
+	}
+	for _, h := range cfg.AllowedHeaders {
+		c.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	return c, nil
+}
+
+func (c *CORS) allowedOrigin(origin string) bool {
+	return origin != "" && (c.origins[origin] || c.origins["*"])
+}
+
+// Middleware answers preflight (OPTIONS) requests from allowed origins without calling next,
+// so they don't need credentials, and adds CORS headers to every other response they're sent.
+// Requests from other origins are passed to next untouched.
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if !c.allowedOrigin(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		if c.origins["*"] {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if c.cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		requested := r.Header.Get("Access-Control-Request-Method")
+		if r.Method != http.MethodOptions || requested == "" {
+			if len(c.cfg.ExposedHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(c.cfg.ExposedHeaders, ", "))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Preflight, the browser only sends the request if the method and every header are allowed
+		if !c.methods[strings.ToUpper(requested)] || !c.allowedHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", strings.Join(c.cfg.AllowedMethods, ", "))
+		if len(c.cfg.AllowedHeaders) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(c.cfg.AllowedHeaders, ", "))
+		}
+		if c.cfg.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.cfg.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (c *CORS) allowedHeaders(requested string) bool {
+	for _, h := range strings.Split(requested, ",") {
+		if h = strings.TrimSpace(h); h != "" && !c.headers[http.CanonicalHeaderKey(h)] {
+			return false
+		}
+	}
+	return true
+}
+
+// AddPreflightRoute matches OPTIONS requests to every path so Middleware sees preflight requests.
+// Preflights it doesn't answer, such as from origins which aren't allowed, are refused.
+func (c *CORS) AddPreflightRoute(r *mux.Router) {
+	r.Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+}
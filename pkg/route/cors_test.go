@@ -0,0 +1,117 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORS(t *testing.T) {
+	cors, err := NewCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://admin.example.com"},
+		AllowedMethods:   DefaultCORSMethods,
+		AllowedHeaders:   DefaultCORSHeaders,
+		ExposedHeaders:   DefaultCORSExposed,
+		AllowCredentials: true,
+		MaxAge:           time.Minute,
+	})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Use(cors.Middleware)
+	cors.AddPreflightRoute(router)
+	router.Methods("GET").Path("/customers/{customerID}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	call := func(method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/customers/foo", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// allowed origins can read responses, including credentialed ones
+	w := call("GET", "https://admin.example.com", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "https://admin.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, "Origin", w.Header().Get("Vary"))
+	require.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "X-Next-Cursor")
+
+	// other origins and same-origin requests get no CORS headers
+	for _, origin := range []string{"https://evil.example.com", "http://localhost:3000", ""} {
+		w = call("GET", origin, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+	}
+
+	// preflight
+	w = call("OPTIONS", "https://admin.example.com", map[string]string{
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "authorization, x-organization, content-type",
+	})
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "https://admin.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "PUT")
+	require.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+	require.Equal(t, "60", w.Header().Get("Access-Control-Max-Age"))
+
+	// preflights for methods or headers which aren't allowed, or from other origins, are refused
+	w = call("OPTIONS", "https://admin.example.com", map[string]string{"Access-Control-Request-Method": "TRACE"})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = call("OPTIONS", "https://admin.example.com", map[string]string{
+		"Access-Control-Request-Method":  "GET",
+		"Access-Control-Request-Headers": "X-Custom",
+	})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = call("OPTIONS", "https://evil.example.com", map[string]string{"Access-Control-Request-Method": "GET"})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORS__disabled(t *testing.T) {
+	cors, err := NewCORS(CORSConfig{})
+	require.NoError(t, err)
+
+	handler := cors.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest("GET", "/customers/foo", nil)
+	req.Header.Set("Origin", "https://admin.example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORS__wildcard(t *testing.T) {
+	_, err := NewCORS(CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	require.Equal(t, errCORSWildcardCredentials, err)
+
+	cors, err := NewCORS(CORSConfig{AllowedOrigins: []string{"*"}})
+	require.NoError(t, err)
+
+	handler := cors.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest("GET", "/customers/foo", nil)
+	req.Header.Set("Origin", "https://anyone.example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+}
@@ -8,8 +8,6 @@ import (
 	"errors"
 	"net/http"
 
-	moovhttp "github.com/moov-io/base/http"
-
 	"github.com/gorilla/mux"
 )
 
@@ -20,7 +18,7 @@ var (
 func GetRepresentativeID(w http.ResponseWriter, r *http.Request) string {
 	v, ok := mux.Vars(r)["representativeID"]
 	if !ok || v == "" {
-		moovhttp.Problem(w, ErrNoRepresentativeID)
+		Problem(w, ErrNoRepresentativeID)
 		return ""
 	}
 	return v
@@ -6,11 +6,12 @@ package route
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
-	moovhttp "github.com/moov-io/base/http"
-
 	"github.com/gorilla/mux"
+
+	"github.com/moov-io/customers/internal/ids"
 )
 
 var (
@@ -20,7 +21,11 @@ var (
 func GetCustomerID(w http.ResponseWriter, r *http.Request) string {
 	v, ok := mux.Vars(r)["customerID"]
 	if !ok || v == "" {
-		moovhttp.Problem(w, ErrNoCustomerID)
+		Problem(w, ErrNoCustomerID)
+		return ""
+	}
+	if !ids.ValidCustomerID(v) {
+		Problem(w, Validation(fmt.Errorf("invalid Customer ID %q", v)))
 		return ""
 	}
 	return v
@@ -5,8 +5,13 @@
 package route
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/moov-io/customers/internal/ids"
 )
 
 func TestCustomers__GetCustomerID(t *testing.T) {
@@ -17,3 +22,29 @@ func TestCustomers__GetCustomerID(t *testing.T) {
 		t.Errorf("unexpected id: %v", id)
 	}
 }
+
+func TestCustomers__GetCustomerIDFormat(t *testing.T) {
+	defer ids.SetFormat(ids.Default)
+	ids.SetFormat(ids.ULID)
+
+	var customerID string
+	router := mux.NewRouter()
+	router.Path("/customers/{customerID}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		customerID = GetCustomerID(w, r)
+	})
+
+	get := func(id string) int {
+		customerID = ""
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/customers/"+id, nil))
+		return w.Code
+	}
+
+	id := ids.NewCustomerID()
+	if code := get(id); code != http.StatusOK || customerID != id {
+		t.Errorf("unexpected status=%d customerID=%q", code, customerID)
+	}
+	if code := get("foo"); code != http.StatusBadRequest || customerID != "" {
+		t.Errorf("unexpected status=%d customerID=%q", code, customerID)
+	}
+}
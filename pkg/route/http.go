@@ -16,6 +16,8 @@ import (
 	"github.com/go-kit/kit/metrics/prometheus"
 	"github.com/moov-io/base/log"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/moov-io/customers/internal/ids"
 )
 
 var (
@@ -38,7 +40,7 @@ var baseIdRegex = regexp.MustCompile(`([a-f0-9]{40})`)
 // cleanMetricsPath takes a URL path and formats it for Prometheus metrics
 //
 // This method replaces /'s with -'s and clean out ID's (which are numeric).
-// This method also strips out moov/base.ID() values from URL path slugs.
+// This method also strips out moov/base.ID(), UUID and ULID values from URL path slugs.
 func cleanMetricsPath(path string) string {
 	parts := strings.Split(path, "/")
 	var out []string
@@ -46,8 +48,8 @@ func cleanMetricsPath(path string) string {
 		if n, _ := strconv.Atoi(parts[i]); n > 0 || parts[i] == "" {
 			continue // numeric ID
 		}
-		if baseIdRegex.MatchString(parts[i]) {
-			continue // assume it's a moov/base.ID() value
+		if baseIdRegex.MatchString(parts[i]) || ids.IsID(parts[i]) {
+			continue // assume it's a moov/base.ID(), UUID or ULID value
 		}
 		out = append(out, parts[i])
 	}
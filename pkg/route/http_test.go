@@ -18,6 +18,9 @@ func TestHTTP__cleanMetricsPath(t *testing.T) {
 	if v := cleanMetricsPath("/v1/customers/customers/19636f90bc95779e2488b0f7a45c4b68958a2ddd"); v != "v1-customers-customers" {
 		t.Errorf("got %q", v)
 	}
+	if v := cleanMetricsPath("/customers/1b4e28ba-2fa1-4d2e-883f-0016d3cca427/documents/01ARZ3NDEKTSV4RRFFQ69G5FAV"); v != "customers-documents" {
+		t.Errorf("got %q", v)
+	}
 	// A value which looks like moov/base.ID, but is off by one character (last letter)
 	if v := cleanMetricsPath("/v1/customers/customers/19636f90bc95779e2488b0f7a45c4b68958a2ddz"); v != "v1-customers-customers-19636f90bc95779e2488b0f7a45c4b68958a2ddz" {
 		t.Errorf("got %q", v)
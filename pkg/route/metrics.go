@@ -0,0 +1,75 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/gorilla/mux"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Histogram of HTTP request durations by method and route template",
+		Buckets: stdprometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	requestsTotal = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Counter of HTTP requests by method, route template and status code",
+	}, []string{"method", "route", "code"})
+)
+
+// MetricsMiddleware records the duration and status code of every request. Requests are labeled
+// with their route's template (e.g. /customers/{customerID}) instead of the path so IDs don't
+// create a series each.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		name := "unmatched"
+		if route := mux.CurrentRoute(r); route != nil {
+			if tmpl, err := route.GetPathTemplate(); err == nil {
+				name = tmpl
+			}
+		}
+		requestDuration.With("method", r.Method, "route", name).Observe(time.Since(start).Seconds())
+		requestsTotal.With("method", r.Method, "route", name, "code", strconv.Itoa(rec.status())).Add(1)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *statusRecorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
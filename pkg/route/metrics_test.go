@@ -0,0 +1,67 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(MetricsMiddleware)
+	router.Methods("GET").Path("/metrics-test/{customerID}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["customerID"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	for _, id := range []string{"foo", "bar", "missing"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics-test/"+id, nil))
+	}
+
+	counts := make(map[string]float64)
+	var observed uint64
+	families, err := stdprometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			if label(m, "route") != "/metrics-test/{customerID}" || label(m, "method") != "GET" {
+				continue
+			}
+			switch family.GetName() {
+			case "http_requests_total":
+				counts[label(m, "code")] += m.GetCounter().GetValue()
+			case "http_request_duration_seconds":
+				observed += m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	if counts["200"] != 2 || counts["404"] != 1 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+	if observed != 3 {
+		t.Errorf("observed %d requests", observed)
+	}
+}
+
+func label(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
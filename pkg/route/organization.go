@@ -7,8 +7,6 @@ package route
 import (
 	"fmt"
 	"net/http"
-
-	moovhttp "github.com/moov-io/base/http"
 )
 
 const organizationHeaderKey = "X-Organization"
@@ -16,7 +14,7 @@ const organizationHeaderKey = "X-Organization"
 // GetOrganization returns the value from the X-Organization header and writes an error to w if it's missing
 func GetOrganization(w http.ResponseWriter, r *http.Request) string {
 	if ns := r.Header.Get(organizationHeaderKey); ns == "" {
-		moovhttp.Problem(w, fmt.Errorf("missing %s header", organizationHeaderKey))
+		Problem(w, fmt.Errorf("missing %s header", organizationHeaderKey))
 		return ""
 	} else {
 		return ns
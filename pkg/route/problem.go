@@ -0,0 +1,144 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/moov-io/base/database"
+)
+
+// Codes returned in the "code" field of error responses. Clients should branch on these
+// instead of the message, which can change.
+const (
+	CodeBadRequest           = "bad_request"
+	CodeValidation           = "validation_failed"
+	CodeNotFound             = "not_found"
+	CodeUnauthorized         = "unauthorized"
+	CodeConflict             = "conflict"
+	CodeForbidden            = "forbidden"
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	CodeTooManyRequests      = "too_many_requests"
+	CodeUnavailable          = "unavailable"
+	CodeTimeout              = "timeout"
+)
+
+// Error is an error returned to clients with its HTTP status and machine readable code
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+
+	// Details holds optional context like the field which failed validation
+	Details map[string]string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewError returns an Error with the given status and code
+func NewError(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Validation marks err as invalid input from the client
+func Validation(err error) error {
+	return wrap(http.StatusBadRequest, CodeValidation, err)
+}
+
+// NotFoundError marks err as a missing resource
+func NotFoundError(err error) error {
+	return wrap(http.StatusNotFound, CodeNotFound, err)
+}
+
+// Conflict marks err as conflicting with a resource which already exists
+func Conflict(err error) error {
+	return wrap(http.StatusConflict, CodeConflict, err)
+}
+
+// Unauthorized marks err as a request without valid credentials
+func Unauthorized(err error) error {
+	return wrap(http.StatusUnauthorized, CodeUnauthorized, err)
+}
+
+// Forbidden marks err as a request the caller isn't allowed to make
+func Forbidden(err error) error {
+	return wrap(http.StatusForbidden, CodeForbidden, err)
+}
+
+func wrap(status int, code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err // keep the original code
+	}
+	return &Error{Status: status, Code: code, Message: err.Error()}
+}
+
+// errorResponse is the JSON body of each error. "error" duplicates "message" for clients reading
+// the older {"error": "..."} responses.
+type errorResponse struct {
+	Error   string            `json:"error"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Problem writes err as a JSON error response. An *Error sets its own status and code, unique
+// constraint violations are returned as 409 Conflict, queries which timed out as 504 Gateway
+// Timeout, cancelled queries as 503 Service Unavailable and anything else is a 400 Bad Request.
+func Problem(w http.ResponseWriter, err error) {
+	if err == nil {
+		return
+	}
+	writeError(w, Classify(err))
+}
+
+// NotFound writes a 404 error response
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, &Error{
+		Status:  http.StatusNotFound,
+		Code:    CodeNotFound,
+		Message: fmt.Sprintf("%s not found", r.URL.Path),
+	})
+}
+
+// Classify returns err as an *Error with the status and code written by Problem
+func Classify(err error) *Error {
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+		return e
+	case database.UniqueViolation(err):
+		return &Error{Status: http.StatusConflict, Code: CodeConflict, Message: err.Error()}
+	case errors.Is(err, sql.ErrNoRows):
+		return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: err.Error()}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Status: http.StatusGatewayTimeout, Code: CodeTimeout, Message: err.Error()}
+	case errors.Is(err, context.Canceled):
+		return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Message: err.Error()}
+	}
+	return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: err.Error()}
+}
+
+func writeError(w http.ResponseWriter, e *Error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(errorResponse{
+		Error:   e.Message,
+		Code:    e.Code,
+		Message: e.Message,
+		Details: e.Details,
+	})
+}
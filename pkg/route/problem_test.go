@@ -0,0 +1,95 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readProblem(t *testing.T, w *httptest.ResponseRecorder) errorResponse {
+	t.Helper()
+
+	require.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	var resp errorResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Equal(t, resp.Message, resp.Error)
+	return resp
+}
+
+func TestProblem(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{errors.New("bad"), http.StatusBadRequest, CodeBadRequest},
+		{Validation(errors.New("missing name")), http.StatusBadRequest, CodeValidation},
+		{NotFoundError(errors.New("gone")), http.StatusNotFound, CodeNotFound},
+		{Conflict(errors.New("exists")), http.StatusConflict, CodeConflict},
+		{Forbidden(errors.New("nope")), http.StatusForbidden, CodeForbidden},
+		{fmt.Errorf("lookup: %w", sql.ErrNoRows), http.StatusNotFound, CodeNotFound},
+		{errors.New("UNIQUE constraint failed: customers.email"), http.StatusConflict, CodeConflict},
+		{fmt.Errorf("wrapped: %w", NewError(http.StatusPreconditionFailed, CodePreconditionFailed, "stale")), http.StatusPreconditionFailed, CodePreconditionFailed},
+		{fmt.Errorf("searching: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, CodeTimeout},
+		{fmt.Errorf("searching: %w", context.Canceled), http.StatusServiceUnavailable, CodeUnavailable},
+	}
+	for i := range cases {
+		w := httptest.NewRecorder()
+		Problem(w, cases[i].err)
+		require.Equal(t, cases[i].status, w.Code, cases[i].err.Error())
+
+		resp := readProblem(t, w)
+		require.Equal(t, cases[i].code, resp.Code)
+	}
+
+	// no error writes nothing
+	w := httptest.NewRecorder()
+	Problem(w, nil)
+	require.Equal(t, 0, w.Body.Len())
+}
+
+func TestProblem__details(t *testing.T) {
+	w := httptest.NewRecorder()
+	Problem(w, &Error{
+		Status:  http.StatusBadRequest,
+		Code:    CodeValidation,
+		Message: "invalid email",
+		Details: map[string]string{"field": "email"},
+	})
+
+	resp := readProblem(t, w)
+	require.Equal(t, "invalid email", resp.Message)
+	require.Equal(t, map[string]string{"field": "email"}, resp.Details)
+}
+
+func TestProblem__keepsCode(t *testing.T) {
+	err := Validation(Conflict(errors.New("exists")))
+
+	w := httptest.NewRecorder()
+	Problem(w, err)
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	require.Nil(t, Validation(nil))
+}
+
+func TestNotFound(t *testing.T) {
+	w := httptest.NewRecorder()
+	NotFound(w, httptest.NewRequest("GET", "/customers/foo", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	resp := readProblem(t, w)
+	require.Equal(t, CodeNotFound, resp.Code)
+	require.Equal(t, "/customers/foo not found", resp.Message)
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+)
+
+const requestIDHeaderKey = "X-Request-ID"
+
+// requestIDPattern limits the request IDs accepted from callers so they can be logged and forwarded
+// in headers safely. UUIDs, ULIDs and moov IDs all match.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx holding requestID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx by RequestIDMiddleware, if any
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDMiddleware gives every request an ID which ties together its log lines and downstream calls.
+// The caller's X-Request-ID is used when it's valid, otherwise one is generated. The ID is stored in the
+// request's context and header, so GetRequestID returns it, and echoed in the response.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeaderKey)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = base.ID()
+		}
+		r.Header.Set(requestIDHeaderKey, requestID)
+		w.Header().Set(requestIDHeaderKey, requestID)
+
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// RequestLogger returns logger with the ID of r set on each line, when r has one
+func RequestLogger(logger log.Logger, r *http.Request) log.Logger {
+	if requestID := GetRequestID(r); requestID != "" {
+		return logger.Set("requestID", log.String(requestID))
+	}
+	return logger
+}
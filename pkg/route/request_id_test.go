@@ -0,0 +1,70 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	buf, logger := log.NewBufferLogger()
+
+	router := mux.NewRouter()
+	router.Use(RequestIDMiddleware)
+	router.Methods("GET").Path("/request-id").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w = Responder(logger, w, r)
+		logger := RequestLogger(logger, r)
+
+		require.Equal(t, GetRequestID(r), RequestIDFromContext(r.Context()))
+		require.Equal(t, GetRequestID(r), moovhttp.GetRequestID(r))
+		logger.Log("handled")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	send := func(requestID string) string {
+		req := httptest.NewRequest("GET", "/request-id", nil)
+		if requestID != "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Header().Get("X-Request-ID")
+	}
+
+	// the caller's ID is kept and included in log lines
+	require.Equal(t, "rs4f9915", send("rs4f9915"))
+	require.Contains(t, buf.String(), "requestID=rs4f9915")
+
+	// missing and invalid IDs are replaced
+	generated := send("")
+	require.Len(t, generated, 40)
+	require.Contains(t, buf.String(), "requestID="+generated)
+
+	replaced := send("bad id\nmsg=forged")
+	require.Len(t, replaced, 40)
+	require.NotEqual(t, generated, replaced)
+	require.Len(t, send(strings.Repeat("a", 65)), 40)
+}
+
+func TestRequestLogger(t *testing.T) {
+	buf, logger := log.NewBufferLogger()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	RequestLogger(logger, req).Log("no request ID")
+	require.NotContains(t, buf.String(), "requestID")
+
+	req.Header.Set("X-Request-ID", "rs4f9915")
+	RequestLogger(logger, req).Log("from header")
+	require.Contains(t, buf.String(), "requestID=rs4f9915")
+}
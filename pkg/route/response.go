@@ -3,11 +3,12 @@ package route
 import (
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/go-kit/kit/metrics"
 	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/internal/logging"
 )
 
 // To avoid changes into base/http.Wrap and its dependencies (Fed, Watchman,
@@ -35,8 +36,6 @@ func (w *ResponseWriter) WriteHeader(code int) {
 	}
 	w.headersWritten = true
 
-	// Headers
-	SetAccessControlAllowHeaders(w, w.request.Header.Get("Origin"))
 	defer w.ResponseWriter.WriteHeader(code)
 
 	// Record route timing
@@ -52,13 +51,20 @@ func (w *ResponseWriter) WriteHeader(code int) {
 	}
 
 	if requestID := GetRequestID(w.request); requestID != "" && w.log != nil {
-		w.log.With(log.Fields{
+		w.log.With(logging.Debug, log.Fields{
 			"method":    log.String(w.request.Method),
 			"path":      log.String(w.request.URL.Path),
 			"status":    log.String(strconv.Itoa(code)),
 			"duration":  log.String(diff.String()),
 			"requestID": log.String(requestID),
-		})
+		}).Send()
+	}
+}
+
+// Flush sends any buffered data to the client, if the underlying ResponseWriter supports it
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
 	}
 }
 
@@ -74,22 +80,11 @@ func Wrap(logger log.Logger, m metrics.Histogram, w http.ResponseWriter, r *http
 	}
 }
 
-// SetAccessControlAllowHeaders writes Access-Control-Allow-* headers to a response to allow
-// for further CORS-allowed requests.
-func SetAccessControlAllowHeaders(w http.ResponseWriter, origin string) {
-	// Access-Control-Allow-Origin can't be '*' with requests that send credentials.
-	// Instead, we need to explicitly set the domain (from request's Origin header)
-	//
-	// Allow requests from anyone's localhost and only from secure pages.
-	if strings.HasPrefix(origin, "http://localhost:") || strings.HasPrefix(origin, "https://") {
-		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PATCH,DELETE,OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Cookie,X-User-Id,X-Request-Id,Content-Type")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-	}
-}
-
-// GetRequestID returns the Moov header value for request IDs
+// GetRequestID returns the ID RequestIDMiddleware gave r, or the Moov header value for request IDs
+// on routes without the middleware
 func GetRequestID(r *http.Request) string {
-	return r.Header.Get("X-Request-Id")
+	if requestID := RequestIDFromContext(r.Context()); requestID != "" {
+		return requestID
+	}
+	return r.Header.Get(requestIDHeaderKey)
 }
@@ -0,0 +1,218 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gocloud.dev/secrets/localsecrets"
+)
+
+const fieldPrefix = "enc:"
+
+// FieldKey is one AES-256 key used to encrypt database columns. The ID is stored with every value
+// it encrypts so the key can be found again after others are added.
+type FieldKey struct {
+	ID  string
+	Key []byte
+}
+
+// ParseFieldKeys reads keys written as a comma separated list of id:base64key pairs, such as the
+// FIELD_ENCRYPTION_KEYS environment variable. Each key must decode to 32 bytes and can keep the
+// base64key:// prefix printed by ./cmd/genkey.
+func ParseFieldKeys(value string) ([]FieldKey, error) {
+	var keys []FieldKey
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		idx := strings.Index(pair, ":")
+		if idx <= 0 {
+			return nil, fmt.Errorf("field key %q must be written as id:base64key", pair)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pair[idx+1:], localsecrets.Scheme+"://"))
+		if err != nil {
+			return nil, fmt.Errorf("field key %s: %v", pair[:idx], err)
+		}
+		keys = append(keys, FieldKey{ID: pair[:idx], Key: key})
+	}
+	return keys, nil
+}
+
+type fieldKey struct {
+	id    string
+	aead  cipher.AEAD
+	nonce []byte // HMAC key deterministic nonces are derived with
+}
+
+// FieldEncryptor encrypts individual database columns with AES-256-GCM.
+//
+// Encrypt is randomized so encrypting a value twice gives different ciphertexts and nothing can be
+// learned by comparing rows. EncryptDeterministic derives the nonce from an HMAC of the value, so
+// equal values encrypted by the same key have equal ciphertexts. That allows lookups by equality and
+// unique constraints on the column, but reveals which rows share a value. Neither supports prefix,
+// suffix or range searches.
+//
+// Values are stored as enc:<keyID>:<base64>. The first key encrypts and every key decrypts, so keys
+// are rotated by adding a new key first and re-encrypting existing values while the older keys keep
+// them readable. Values without the prefix are plaintext written before encryption was enabled and
+// are returned as they are. A nil FieldEncryptor leaves every value in plaintext.
+type FieldEncryptor struct {
+	keys []fieldKey
+}
+
+func NewFieldEncryptor(keys ...FieldKey) (*FieldEncryptor, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no field encryption keys")
+	}
+	enc := &FieldEncryptor{}
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if k.ID == "" || strings.Contains(k.ID, ":") {
+			return nil, fmt.Errorf("invalid field key ID %q", k.ID)
+		}
+		if seen[k.ID] {
+			return nil, fmt.Errorf("duplicate field key ID %s", k.ID)
+		}
+		seen[k.ID] = true
+
+		if len(k.Key) != 32 {
+			return nil, fmt.Errorf("field key %s must be 32 bytes, got %d", k.ID, len(k.Key))
+		}
+		block, err := aes.NewCipher(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("field key %s: %v", k.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("field key %s: %v", k.ID, err)
+		}
+		mac := hmac.New(sha256.New, k.Key)
+		mac.Write([]byte("deterministic nonce"))
+		enc.keys = append(enc.keys, fieldKey{id: k.ID, aead: aead, nonce: mac.Sum(nil)})
+	}
+	return enc, nil
+}
+
+// Enabled returns true when values are encrypted
+func (e *FieldEncryptor) Enabled() bool {
+	return e != nil && len(e.keys) > 0
+}
+
+// Encrypt returns a randomized ciphertext of plaintext under the current key.
+// Empty values are left empty.
+func (e *FieldEncryptor) Encrypt(plaintext string) (string, error) {
+	if !e.Enabled() || plaintext == "" {
+		return plaintext, nil
+	}
+	key := e.keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("field encryption nonce: %v", err)
+	}
+	return key.seal(nonce, plaintext), nil
+}
+
+// EncryptDeterministic returns the same ciphertext each time plaintext is encrypted under the
+// current key. Empty values are left empty.
+func (e *FieldEncryptor) EncryptDeterministic(plaintext string) (string, error) {
+	if !e.Enabled() || plaintext == "" {
+		return plaintext, nil
+	}
+	return e.keys[0].sealDeterministic(plaintext), nil
+}
+
+// SearchValues returns the stored forms plaintext could have: its deterministic ciphertext under
+// every key and the plaintext itself. Looking up a column by all of them finds rows which haven't
+// been re-encrypted since a key rotation or were written before encryption was enabled.
+func (e *FieldEncryptor) SearchValues(plaintext string) []string {
+	out := []string{plaintext}
+	if !e.Enabled() || plaintext == "" {
+		return out
+	}
+	for _, key := range e.keys {
+		out = append(out, key.sealDeterministic(plaintext))
+	}
+	return out
+}
+
+// Decrypt returns the plaintext of a value from Encrypt or EncryptDeterministic. Values which
+// aren't encrypted are returned unchanged.
+func (e *FieldEncryptor) Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, fieldPrefix) {
+		return value, nil
+	}
+	id, data, err := splitField(value)
+	if err != nil {
+		return "", err
+	}
+	if e != nil {
+		for _, key := range e.keys {
+			if key.id != id {
+				continue
+			}
+			size := key.aead.NonceSize()
+			if len(data) < size {
+				return "", errors.New("field ciphertext is too short")
+			}
+			out, err := key.aead.Open(nil, data[:size], data[size:], nil)
+			if err != nil {
+				return "", fmt.Errorf("decrypting field with key %s: %v", id, err)
+			}
+			return string(out), nil
+		}
+	}
+	return "", fmt.Errorf("unknown field key %s", id)
+}
+
+// Current returns false when value should be re-encrypted: it was encrypted by an older key or is
+// plaintext while encryption is enabled.
+func (e *FieldEncryptor) Current(value string) bool {
+	if value == "" {
+		return true
+	}
+	encrypted := strings.HasPrefix(value, fieldPrefix)
+	if !e.Enabled() {
+		return !encrypted
+	}
+	if !encrypted {
+		return false
+	}
+	id, _, err := splitField(value)
+	return err == nil && id == e.keys[0].id
+}
+
+func splitField(value string) (string, []byte, error) {
+	parts := strings.SplitN(strings.TrimPrefix(value, fieldPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", nil, errors.New("malformed encrypted field")
+	}
+	data, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, fmt.Errorf("malformed encrypted field: %v", err)
+	}
+	return parts[0], data, nil
+}
+
+func (k fieldKey) seal(nonce []byte, plaintext string) string {
+	data := k.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return fieldPrefix + k.id + ":" + base64.StdEncoding.EncodeToString(data)
+}
+
+func (k fieldKey) sealDeterministic(plaintext string) string {
+	mac := hmac.New(sha256.New, k.nonce)
+	mac.Write([]byte(plaintext))
+	return k.seal(mac.Sum(nil)[:k.aead.NonceSize()], plaintext)
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFieldKeys(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("a"), 32))
+	keys, err := ParseFieldKeys("2020-10:" + key + ", 2020-01:" + key)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, "2020-10", keys[0].ID)
+	require.Equal(t, bytes.Repeat([]byte("a"), 32), keys[1].Key)
+
+	keys, err = ParseFieldKeys("v1:base64key://" + key)
+	require.NoError(t, err)
+	require.Equal(t, bytes.Repeat([]byte("a"), 32), keys[0].Key)
+
+	keys, err = ParseFieldKeys("")
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	_, err = ParseFieldKeys(key)
+	require.Error(t, err)
+	_, err = ParseFieldKeys("v1:not-base64")
+	require.Error(t, err)
+
+	_, err = NewFieldEncryptor(FieldKey{ID: "v1", Key: []byte("short")})
+	require.Error(t, err)
+	_, err = NewFieldEncryptor(FieldKey{ID: "v1", Key: bytes.Repeat([]byte("a"), 32)}, FieldKey{ID: "v1", Key: bytes.Repeat([]byte("b"), 32)})
+	require.Error(t, err)
+	_, err = NewFieldEncryptor()
+	require.Error(t, err)
+}
+
+func TestFieldEncryptor__encrypt(t *testing.T) {
+	enc := TestFieldEncryptor(t)
+
+	// randomized values differ each time
+	first, err := enc.Encrypt("+15555551234")
+	require.NoError(t, err)
+	second, err := enc.Encrypt("+15555551234")
+	require.NoError(t, err)
+	require.NotEqual(t, first, second)
+	require.True(t, strings.HasPrefix(first, "enc:test:"))
+	require.NotContains(t, first, "5555551234")
+
+	for _, v := range []string{first, second} {
+		out, err := enc.Decrypt(v)
+		require.NoError(t, err)
+		require.Equal(t, "+15555551234", out)
+	}
+
+	// deterministic values are equal for equal plaintexts
+	first, err = enc.EncryptDeterministic("jane@example.com")
+	require.NoError(t, err)
+	second, err = enc.EncryptDeterministic("jane@example.com")
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+	other, err := enc.EncryptDeterministic("john@example.com")
+	require.NoError(t, err)
+	require.NotEqual(t, first, other)
+
+	out, err := enc.Decrypt(first)
+	require.NoError(t, err)
+	require.Equal(t, "jane@example.com", out)
+
+	// plaintext and empty values pass through
+	out, err = enc.Decrypt("jane@example.com")
+	require.NoError(t, err)
+	require.Equal(t, "jane@example.com", out)
+	empty, err := enc.Encrypt("")
+	require.NoError(t, err)
+	require.Empty(t, empty)
+
+	// tampered values fail
+	_, err = enc.Decrypt(first[:len(first)-4] + "AAA=")
+	require.Error(t, err)
+	_, err = enc.Decrypt("enc:other:" + strings.TrimPrefix(first, "enc:test:"))
+	require.Error(t, err)
+}
+
+func TestFieldEncryptor__rotation(t *testing.T) {
+	old := TestFieldEncryptor(t, "old")
+	email, err := old.EncryptDeterministic("jane@example.com")
+	require.NoError(t, err)
+
+	// a new key encrypts while the old one still decrypts
+	enc := TestFieldEncryptor(t, "new", "old")
+	require.False(t, enc.Current(email))
+	require.False(t, enc.Current("jane@example.com"))
+	out, err := enc.Decrypt(email)
+	require.NoError(t, err)
+	require.Equal(t, "jane@example.com", out)
+
+	rotated, err := enc.EncryptDeterministic(out)
+	require.NoError(t, err)
+	require.True(t, enc.Current(rotated))
+	require.NotEqual(t, email, rotated)
+
+	// lookups during the rotation find values under either key and plaintext
+	values := enc.SearchValues("jane@example.com")
+	require.Equal(t, []string{"jane@example.com", rotated, email}, values)
+
+	// without the old key its values can't be read
+	_, err = TestFieldEncryptor(t, "new").Decrypt(email)
+	require.Error(t, err)
+}
+
+func TestFieldEncryptor__nil(t *testing.T) {
+	var enc *FieldEncryptor
+	require.False(t, enc.Enabled())
+
+	out, err := enc.Encrypt("+15555551234")
+	require.NoError(t, err)
+	require.Equal(t, "+15555551234", out)
+	out, err = enc.EncryptDeterministic("jane@example.com")
+	require.NoError(t, err)
+	require.Equal(t, "jane@example.com", out)
+	require.Equal(t, []string{"jane@example.com"}, enc.SearchValues("jane@example.com"))
+	require.True(t, enc.Current("jane@example.com"))
+
+	// encrypted values are flagged for decryption once encryption is turned off
+	encrypted, err := TestFieldEncryptor(t).Encrypt("+15555551234")
+	require.NoError(t, err)
+	require.False(t, enc.Current(encrypted))
+	_, err = enc.Decrypt(encrypted)
+	require.Error(t, err)
+}
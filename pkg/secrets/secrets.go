@@ -19,6 +19,8 @@ import (
 	"gocloud.dev/secrets/gcpkms"
 	"gocloud.dev/secrets/hashivault"
 	"gocloud.dev/secrets/localsecrets"
+
+	"github.com/moov-io/customers/pkg/config"
 )
 
 // StringKeeper wraps a secrets.Keeper but accepts and returns strings, which are easier
@@ -156,8 +158,12 @@ func openVault(path string) (*secrets.Keeper, error) {
 		serverURL = v
 	}
 
+	token, err := config.Secret("VAULT_SERVER_TOKEN")
+	if err != nil {
+		return nil, err
+	}
 	client, err := hashivault.Dial(context.Background(), &hashivault.Config{
-		Token: os.Getenv("VAULT_SERVER_TOKEN"),
+		Token: token,
 		APIConfig: api.Config{
 			Address: serverURL,
 		},
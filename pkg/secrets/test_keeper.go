@@ -43,3 +43,20 @@ func TestKeeper(t *testing.T) *secrets.Keeper {
 	t.Cleanup(func() { keeper.Close() })
 	return keeper
 }
+
+// TestFieldEncryptor returns a FieldEncryptor with one key of each given ID, the first being current
+func TestFieldEncryptor(t *testing.T, ids ...string) *FieldEncryptor {
+	t.Helper()
+	if len(ids) == 0 {
+		ids = []string{"test"}
+	}
+	var keys []FieldKey
+	for _, id := range ids {
+		keys = append(keys, FieldKey{ID: id, Key: bytes.Repeat([]byte(id[:1]), 32)})
+	}
+	enc, err := NewFieldEncryptor(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package sms
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/base/log"
+
+	customersdb "github.com/moov-io/customers/internal/database"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+type Repository interface {
+	saveVerification(ver *verification) error
+	getPendingVerification(customerID, organization, number string, now time.Time) (*verification, error)
+	recentActivity(customerID, number string, since time.Time) (issued int, failed int, err error)
+	recordAttempt(verificationID string) error
+	markVerified(ver *verification, verifiedAt time.Time) error
+}
+
+// NewRepository returns a Repository for phone verifications. fields decrypts the Customer's phone
+// numbers when they're encrypted and can be nil otherwise.
+func NewRepository(logger log.Logger, db *sql.DB, fields *secrets.FieldEncryptor) Repository {
+	return &sqlRepository{db: db, logger: logger, fields: fields}
+}
+
+type sqlRepository struct {
+	db     *sql.DB
+	logger log.Logger
+	fields *secrets.FieldEncryptor
+}
+
+func (r *sqlRepository) saveVerification(ver *verification) error {
+	query := `insert into phone_verifications (verification_id, customer_id, organization, phone_number, code_hash, expires_at, created_at) values (?, ?, ?, ?, ?, ?, ?);`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("saveVerification: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(ver.VerificationID, ver.CustomerID, ver.Organization, ver.PhoneNumber, ver.CodeHash, ver.ExpiresAt, ver.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("saveVerification: exec: %v", err)
+	}
+	return nil
+}
+
+// getPendingVerification returns the latest unused and unexpired code sent to a phone number
+func (r *sqlRepository) getPendingVerification(customerID, organization, number string, now time.Time) (*verification, error) {
+	query := `select verification_id, customer_id, organization, phone_number, code_hash, attempts, expires_at, verified_at, created_at from phone_verifications
+where customer_id = ? and organization = ? and phone_number = ? and verified_at is null and expires_at > ? order by created_at desc limit 1;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("getPendingVerification: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	var ver verification
+	err = stmt.QueryRow(customerID, organization, number, now).Scan(&ver.VerificationID, &ver.CustomerID, &ver.Organization, &ver.PhoneNumber, &ver.CodeHash, &ver.Attempts, &ver.ExpiresAt, &ver.VerifiedAt, &ver.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getPendingVerification: scan: %v", err)
+	}
+	return &ver, nil
+}
+
+// recentActivity returns how many codes were sent to a phone number since the given time and
+// how many incorrect codes were submitted for them
+func (r *sqlRepository) recentActivity(customerID, number string, since time.Time) (int, int, error) {
+	query := `select count(*), coalesce(sum(attempts), 0) from phone_verifications where customer_id = ? and phone_number = ? and created_at > ?;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return 0, 0, fmt.Errorf("recentActivity: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	var issued, failed int
+	if err := stmt.QueryRow(customerID, number, since).Scan(&issued, &failed); err != nil {
+		return 0, 0, fmt.Errorf("recentActivity: scan: %v", err)
+	}
+	return issued, failed, nil
+}
+
+func (r *sqlRepository) recordAttempt(verificationID string) error {
+	query := `update phone_verifications set attempts = attempts + 1 where verification_id = ?;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("recordAttempt: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(verificationID); err != nil {
+		return fmt.Errorf("recordAttempt: exec: %v", err)
+	}
+	return nil
+}
+
+// markVerified records when the code was used and marks the Customer's phone number as valid
+func (r *sqlRepository) markVerified(ver *verification, verifiedAt time.Time) error {
+	return customersdb.RetryOnLock(r.db, func(tx *sql.Tx) error {
+		query := `update phone_verifications set verified_at = ? where verification_id = ? and verified_at is null;`
+		if _, err := tx.Exec(query, verifiedAt, ver.VerificationID); err != nil {
+			return fmt.Errorf("markVerified: verification: %v", err)
+		}
+		where, arg, err := r.findPhone(tx, ver.CustomerID, ver.PhoneNumber)
+		if err != nil {
+			return fmt.Errorf("markVerified: %v", err)
+		}
+		if where == "" {
+			return nil // the phone was removed after the code was sent
+		}
+		query = `update phones set valid = ?, last_modified = ? where owner_id = ? and owner_type = 'customer' and ` + where + ` and deleted_at is null;`
+		if _, err := tx.Exec(query, true, verifiedAt, ver.CustomerID, arg); err != nil {
+			return fmt.Errorf("markVerified: phone: %v", err)
+		}
+		return nil
+	})
+}
+
+// findPhone returns the filter matching the Customer's phones row for number. Encrypted numbers
+// are randomized, so each of the Customer's phones is decrypted and compared.
+func (r *sqlRepository) findPhone(tx *sql.Tx, customerID, number string) (string, interface{}, error) {
+	query := `select number, encrypted_number from phones where owner_id = ? and owner_type = 'customer' and deleted_at is null;`
+	rows, err := tx.Query(query, customerID)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading phones: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var plain, encrypted sql.NullString
+		if err := rows.Scan(&plain, &encrypted); err != nil {
+			return "", nil, fmt.Errorf("reading phones: %v", err)
+		}
+		if encrypted.String == "" {
+			if plain.String == number {
+				return "number = ?", plain.String, nil
+			}
+			continue
+		}
+		decrypted, err := r.fields.Decrypt(encrypted.String)
+		if err != nil {
+			return "", nil, fmt.Errorf("decrypting phone: %v", err)
+		}
+		if decrypted == number {
+			return "encrypted_number = ?", encrypted.String, nil
+		}
+	}
+	return "", nil, rows.Err()
+}
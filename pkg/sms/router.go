@@ -0,0 +1,122 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package sms
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// AddRoutes registers the endpoints which text a verification code to a Customer's phone and accept it back
+func AddRoutes(logger log.Logger, r *mux.Router, verifier *Verifier) {
+	logger = logger.Set("package", log.String("sms"))
+
+	r.Methods("POST").Path("/customers/{customerID}/phones/verification").HandlerFunc(sendVerification(logger, verifier))
+	r.Methods("POST").Path("/customers/{customerID}/phones/verify").HandlerFunc(verifyPhone(logger, verifier))
+}
+
+type sendVerificationRequest struct {
+	Number string `json:"number"`
+}
+
+type sendVerificationResponse struct {
+	CustomerID string    `json:"customerID"`
+	Number     string    `json:"number"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+func sendVerification(logger log.Logger, verifier *Verifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		var req sendVerificationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if req.Number == "" {
+			route.Problem(w, route.Validation(errors.New("missing phone number")))
+			return
+		}
+
+		ver, err := verifier.IssueCode(r.Context(), customerID, organization, req.Number)
+		if err != nil {
+			logger.Set("customerID", log.String(customerID)).LogErrorf("problem sending phone verification: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(sendVerificationResponse{
+			CustomerID: ver.CustomerID,
+			Number:     ver.PhoneNumber,
+			ExpiresAt:  ver.ExpiresAt,
+		})
+	}
+}
+
+type verifyRequest struct {
+	Number string `json:"number"`
+	Code   string `json:"code"`
+}
+
+type verifyResponse struct {
+	CustomerID string    `json:"customerID"`
+	Number     string    `json:"number"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
+
+func verifyPhone(logger log.Logger, verifier *Verifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		var req verifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			route.Problem(w, err)
+			return
+		}
+		if req.Number == "" || req.Code == "" {
+			route.Problem(w, errInvalidCode)
+			return
+		}
+
+		ver, err := verifier.Verify(r.Context(), customerID, organization, req.Number, req.Code)
+		if err != nil {
+			if err != errInvalidCode && err != errTooManyAttempts {
+				logger.Set("customerID", log.String(customerID)).LogErrorf("problem verifying phone: %v", err)
+			}
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(verifyResponse{
+			CustomerID: ver.CustomerID,
+			Number:     ver.PhoneNumber,
+			VerifiedAt: *ver.VerifiedAt,
+		})
+	}
+}
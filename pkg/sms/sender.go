@@ -0,0 +1,104 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package sms sends text messages through Twilio and verifies customers own their phone
+// numbers with one-time codes.
+package sms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SMSSender delivers a single text message
+type SMSSender interface {
+	Send(to, body string) error
+}
+
+// SenderConfig holds the settings for the configured SMSSender
+type SenderConfig struct {
+	// Provider is "twilio", an empty value disables sending text messages.
+	Provider string
+	From     string
+
+	Twilio TwilioConfig
+}
+
+type TwilioConfig struct {
+	AccountSID string
+	AuthToken  string
+}
+
+// NewSender returns the SMSSender for cfg or nil if no provider is configured.
+func NewSender(cfg SenderConfig) (SMSSender, error) {
+	provider := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	if provider == "" {
+		return nil, nil
+	}
+	if cfg.From == "" {
+		return nil, errors.New("sms: missing from number")
+	}
+	switch provider {
+	case "twilio":
+		if cfg.Twilio.AccountSID == "" || cfg.Twilio.AuthToken == "" {
+			return nil, errors.New("sms: missing Twilio account SID or auth token")
+		}
+		return &twilioSender{
+			from:    cfg.From,
+			cfg:     cfg.Twilio,
+			baseURL: "https://api.twilio.com",
+			client:  &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	}
+	return nil, fmt.Errorf("sms: unknown provider %q", cfg.Provider)
+}
+
+type twilioSender struct {
+	from    string
+	cfg     TwilioConfig
+	baseURL string
+	client  *http.Client
+}
+
+// twilioError is the body Twilio returns for failed requests
+type twilioError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s *twilioSender) Send(to, body string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", s.from)
+	form.Set("Body", body)
+
+	address := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", s.baseURL, url.PathEscape(s.cfg.AccountSID))
+	req, err := http.NewRequest("POST", address, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("twilio: %v", err)
+	}
+	req.SetBasicAuth(s.cfg.AccountSID, s.cfg.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("twilio: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e twilioError
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&e)
+		return fmt.Errorf("twilio: %s: %d %s", resp.Status, e.Code, e.Message)
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/secrets"
+)
+
+type mockSender struct {
+	err  error
+	sent []string
+}
+
+func (s *mockSender) Send(to, body string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, to+": "+body)
+	return nil
+}
+
+func TestSMS__NewSender(t *testing.T) {
+	sender, err := NewSender(SenderConfig{})
+	require.NoError(t, err)
+	require.Nil(t, sender)
+
+	_, err = NewSender(SenderConfig{Provider: "twilio"})
+	require.Error(t, err)
+
+	_, err = NewSender(SenderConfig{Provider: "twilio", From: "+15555550000"})
+	require.Error(t, err)
+
+	_, err = NewSender(SenderConfig{Provider: "carrier-pigeon", From: "+15555550000"})
+	require.Error(t, err)
+
+	sender, err = NewSender(SenderConfig{Provider: "Twilio", From: "+15555550000", Twilio: TwilioConfig{AccountSID: "AC123", AuthToken: "secret"}})
+	require.NoError(t, err)
+	require.NotNil(t, sender)
+}
+
+func TestSMS__Twilio(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if r.URL.Path != "/2010-04-01/Accounts/AC123/Messages.json" || user != "AC123" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code": 20003, "message": "Authenticate"}`))
+			return
+		}
+		r.ParseForm()
+		body = r.Form.Get("To") + " " + r.Form.Get("From") + " " + r.Form.Get("Body")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	sender := &twilioSender{
+		from:    "+15555550000",
+		cfg:     TwilioConfig{AccountSID: "AC123", AuthToken: "secret"},
+		baseURL: server.URL,
+		client:  server.Client(),
+	}
+	require.NoError(t, sender.Send("+15555551234", "hello"))
+	require.Equal(t, "+15555551234 +15555550000 hello", body)
+
+	sender.cfg.AuthToken = "wrong"
+	err := sender.Send("+15555551234", "hello")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "20003 Authenticate")
+}
+
+func setupVerifier(t *testing.T, sender SMSSender, cfg VerifierConfig) (*Verifier, customers.CustomerRepository, *mux.Router) {
+	t.Helper()
+	return setupEncryptedVerifier(t, sender, cfg, nil)
+}
+
+func setupEncryptedVerifier(t *testing.T, sender SMSSender, cfg VerifierConfig, fields *secrets.FieldEncryptor) (*Verifier, customers.CustomerRepository, *mux.Router) {
+	t.Helper()
+
+	logger := log.NewNopLogger()
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	customerRepo := customers.NewCustomerRepoWithEncryption(logger, db.DB, fields)
+	cust := &client.Customer{
+		CustomerID: "foo",
+		FirstName:  "Jane",
+		LastName:   "Doe",
+		Type:       client.CUSTOMERTYPE_INDIVIDUAL,
+		Phones: []client.Phone{
+			{Number: "+15555551234", OwnerType: client.OWNERTYPE_CUSTOMER, Type: client.PHONETYPE_MOBILE},
+		},
+	}
+	require.NoError(t, customerRepo.CreateCustomer(context.Background(), cust, "test"))
+
+	verifier := NewVerifier(logger, NewRepository(logger, db.DB, fields), customerRepo, sender, cfg)
+	router := mux.NewRouter()
+	AddRoutes(logger, router, verifier)
+	return verifier, customerRepo, router
+}
+
+func post(router *mux.Router, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("x-organization", "test")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSMS__Verification(t *testing.T) {
+	sender := &mockSender{}
+	_, customerRepo, router := setupVerifier(t, sender, VerifierConfig{Secret: "salt"})
+
+	// phones not on file are rejected
+	w := post(router, "/customers/foo/phones/verification", `{"number": "+1 555-555-9999"}`)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Empty(t, sender.sent)
+
+	w = post(router, "/customers/foo/phones/verification", `{"number": "(555) 555-1234"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), `"number":"+15555551234"`)
+	require.Len(t, sender.sent, 1)
+	require.True(t, strings.HasPrefix(sender.sent[0], "+15555551234: "))
+
+	code := regexp.MustCompile(`code is (\d{6})`).FindStringSubmatch(sender.sent[0])[1]
+	require.NotEmpty(t, code)
+
+	verify := func(code string) *httptest.ResponseRecorder {
+		return post(router, "/customers/foo/phones/verify", fmt.Sprintf(`{"number": "+15555551234", "code": %q}`, code))
+	}
+
+	w = verify("not-it")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = verify(code)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), `"verifiedAt"`)
+
+	cust, err := customerRepo.GetCustomer(context.Background(), "foo", "test")
+	require.NoError(t, err)
+	require.True(t, cust.Phones[0].Valid)
+
+	// codes can only be used once
+	w = verify(code)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSMS__VerificationEncryptedPhone(t *testing.T) {
+	sender := &mockSender{}
+	verifier, customerRepo, _ := setupEncryptedVerifier(t, sender, VerifierConfig{Secret: "salt"}, secrets.TestFieldEncryptor(t))
+
+	_, err := verifier.IssueCode(context.Background(), "foo", "test", "+15555551234")
+	require.NoError(t, err)
+	code := regexp.MustCompile(`code is (\d{6})`).FindStringSubmatch(sender.sent[0])[1]
+	_, err = verifier.Verify(context.Background(), "foo", "test", "+15555551234", code)
+	require.NoError(t, err)
+
+	cust, err := customerRepo.GetCustomer(context.Background(), "foo", "test")
+	require.NoError(t, err)
+	require.True(t, cust.Phones[0].Valid)
+}
+
+func TestSMS__VerificationAttempts(t *testing.T) {
+	sender := &mockSender{}
+	verifier, _, router := setupVerifier(t, sender, VerifierConfig{TTL: time.Hour, MaxAttempts: 2, Secret: "salt"})
+
+	_, err := verifier.IssueCode(context.Background(), "foo", "test", "+15555551234")
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err := verifier.Verify(context.Background(), "foo", "test", "+15555551234", "bad")
+		require.Equal(t, errInvalidCode, err)
+	}
+
+	// the correct code is refused once the phone is out of attempts
+	code := regexp.MustCompile(`code is (\d{6})`).FindStringSubmatch(sender.sent[0])[1]
+	_, err = verifier.Verify(context.Background(), "foo", "test", "+15555551234", code)
+	require.Equal(t, errTooManyAttempts, err)
+
+	w := post(router, "/customers/foo/phones/verification", `{"number": "+15555551234"}`)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Contains(t, w.Body.String(), `"code":"too_many_requests"`)
+}
+
+func TestSMS__VerificationSendError(t *testing.T) {
+	verifier, _, _ := setupVerifier(t, &mockSender{err: errors.New("bad thing")}, VerifierConfig{})
+
+	_, err := verifier.IssueCode(context.Background(), "foo", "test", "+15555551234")
+	require.Error(t, err)
+}
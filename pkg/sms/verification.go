@@ -0,0 +1,200 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package sms
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/internal/phonenumbers"
+	"github.com/moov-io/customers/pkg/customers"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+var (
+	errInvalidCode     = route.Validation(errors.New("invalid or expired verification code"))
+	errPhoneNotFound   = route.NotFoundError(errors.New("phone number not found"))
+	errTooManyAttempts = route.NewError(http.StatusTooManyRequests, route.CodeTooManyRequests, "too many verification attempts, try again later")
+)
+
+// verification is a one-time code texted to a Customer to confirm they own the phone number.
+// Only an HMAC of the code is stored.
+type verification struct {
+	VerificationID string
+	CustomerID     string
+	Organization   string
+	PhoneNumber    string
+	CodeHash       string
+	Attempts       int
+	ExpiresAt      time.Time
+	VerifiedAt     *time.Time
+	CreatedAt      time.Time
+}
+
+// VerifierConfig limits how long codes are valid and how often a phone can be tried
+type VerifierConfig struct {
+	// TTL is how long a code can be submitted, it defaults to 10 minutes.
+	TTL time.Duration
+
+	// MaxAttempts is how many codes can be issued or incorrectly submitted for a phone
+	// within TTL before requests are refused. It defaults to 5.
+	MaxAttempts int
+
+	// Secret keys the HMAC of each stored code
+	Secret string
+}
+
+// Verifier texts verification codes to Customer phones and marks the phone valid once the
+// code is submitted.
+type Verifier struct {
+	logger       log.Logger
+	repo         Repository
+	customerRepo customers.CustomerRepository
+	sender       SMSSender
+	cfg          VerifierConfig
+}
+
+func NewVerifier(logger log.Logger, repo Repository, customerRepo customers.CustomerRepository, sender SMSSender, cfg VerifierConfig) *Verifier {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 10 * time.Minute
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	return &Verifier{
+		logger:       logger.Set("package", log.String("sms")),
+		repo:         repo,
+		customerRepo: customerRepo,
+		sender:       sender,
+		cfg:          cfg,
+	}
+}
+
+// IssueCode texts a new verification code to one of the Customer's phone numbers
+func (v *Verifier) IssueCode(ctx context.Context, customerID, organization, number string) (*verification, error) {
+	number, err := v.customerPhone(ctx, customerID, organization, number)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.checkAttempts(customerID, number, true); err != nil {
+		return nil, err
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ver := &verification{
+		VerificationID: base.ID(),
+		CustomerID:     customerID,
+		Organization:   organization,
+		PhoneNumber:    number,
+		CodeHash:       v.hashCode(customerID, number, code),
+		ExpiresAt:      now.Add(v.cfg.TTL),
+		CreatedAt:      now,
+	}
+	if err := v.repo.saveVerification(ver); err != nil {
+		return nil, err
+	}
+
+	body := fmt.Sprintf("Your verification code is %s. It expires in %v.", code, v.cfg.TTL)
+	if err := v.sender.Send(number, body); err != nil {
+		return nil, fmt.Errorf("sending verification code: %v", err)
+	}
+	return ver, nil
+}
+
+// Verify marks the phone number valid if code matches the latest unexpired code sent to it.
+// Incorrect codes count towards the phone's attempt limit.
+func (v *Verifier) Verify(ctx context.Context, customerID, organization, number, code string) (*verification, error) {
+	number, err := v.customerPhone(ctx, customerID, organization, number)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.checkAttempts(customerID, number, false); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ver, err := v.repo.getPendingVerification(customerID, organization, number, now)
+	if err != nil {
+		return nil, err
+	}
+	if ver == nil || !hmac.Equal([]byte(ver.CodeHash), []byte(v.hashCode(customerID, number, code))) {
+		if ver != nil {
+			if err := v.repo.recordAttempt(ver.VerificationID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, errInvalidCode
+	}
+
+	if err := v.repo.markVerified(ver, now); err != nil {
+		return nil, err
+	}
+	ver.VerifiedAt = &now
+	return ver, nil
+}
+
+// customerPhone returns number in E.164 format if the Customer has it on file
+func (v *Verifier) customerPhone(ctx context.Context, customerID, organization, number string) (string, error) {
+	parsed, err := phonenumbers.Parse(number)
+	if err != nil {
+		return "", route.Validation(err)
+	}
+	cust, err := v.customerRepo.GetCustomer(ctx, customerID, organization)
+	if err != nil {
+		return "", err
+	}
+	if cust == nil {
+		return "", route.NotFoundError(errors.New("customer not found"))
+	}
+	for i := range cust.Phones {
+		if cust.Phones[i].Number == parsed.E164 {
+			return parsed.E164, nil
+		}
+	}
+	return "", errPhoneNotFound
+}
+
+// checkAttempts refuses requests once a phone has been sent, or incorrectly submitted, MaxAttempts
+// codes within the TTL window
+func (v *Verifier) checkAttempts(customerID, number string, issuing bool) error {
+	issued, failed, err := v.repo.recentActivity(customerID, number, time.Now().Add(-v.cfg.TTL))
+	if err != nil {
+		return err
+	}
+	if (issuing && issued >= v.cfg.MaxAttempts) || failed >= v.cfg.MaxAttempts {
+		return errTooManyAttempts
+	}
+	return nil
+}
+
+func (v *Verifier) hashCode(customerID, number, code string) string {
+	mac := hmac.New(sha256.New, []byte(v.cfg.Secret))
+	mac.Write([]byte(customerID + ":" + number + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateCode returns a random six digit code
+func generateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("generating verification code: %v", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
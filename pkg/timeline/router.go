@@ -0,0 +1,120 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package timeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/model"
+	"github.com/moov-io/customers/pkg/route"
+)
+
+const totalCountHeaderKey = "X-Total-Count"
+
+// AddRoutes registers the timeline endpoint
+func AddRoutes(logger log.Logger, r *mux.Router, repo Repository) {
+	logger = logger.Set("package", log.String("timeline"))
+
+	r.Methods("GET").Path("/customers/{customerID}/timeline").HandlerFunc(getTimeline(logger, repo))
+}
+
+func getTimeline(logger log.Logger, repo Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		customerID, organization := route.GetCustomerID(w, r), route.GetOrganization(w, r)
+		if customerID == "" || organization == "" {
+			return
+		}
+
+		params, err := readParams(r)
+		if err != nil {
+			route.Problem(w, route.Validation(err))
+			return
+		}
+
+		logger = logger.Set("customerID", log.String(customerID))
+		exists, err := repo.customerExists(customerID, organization)
+		if err != nil {
+			logger.LogErrorf("problem reading customer: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		if !exists {
+			route.NotFound(w, r)
+			return
+		}
+
+		events, err := repo.list(customerID, params)
+		if err != nil {
+			logger.LogErrorf("problem reading timeline: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		total, err := repo.count(customerID, params)
+		if err != nil {
+			logger.LogErrorf("problem counting timeline: %v", err)
+			route.Problem(w, err)
+			return
+		}
+		if events == nil {
+			events = []*Event{}
+		}
+
+		w.Header().Set(totalCountHeaderKey, fmt.Sprintf("%d", total))
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(events)
+	}
+}
+
+// readParams reads the optional from and to query parameters, which are either RFC 3339 timestamps
+// or YYYY-MM-DD dates, along with skip and count. A date for to includes the entire day.
+func readParams(r *http.Request) (Params, error) {
+	var params Params
+	var err error
+
+	if params.From, err = readTime(r.URL.Query().Get("from"), false); err != nil {
+		return params, fmt.Errorf("invalid from: %v", err)
+	}
+	if params.To, err = readTime(r.URL.Query().Get("to"), true); err != nil {
+		return params, fmt.Errorf("invalid to: %v", err)
+	}
+	if !params.From.IsZero() && !params.To.IsZero() && params.To.Before(params.From) {
+		return params, fmt.Errorf("to (%v) is before from (%v)", params.To, params.From)
+	}
+
+	skip, count, exists, err := moovhttp.GetSkipAndCount(r)
+	if exists && err != nil {
+		return params, err
+	}
+	params.Skip, params.Count = skip, count
+	return params, nil
+}
+
+func readTime(v string, endOfDay bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(model.YYYYMMDD_Format, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp or YYYY-MM-DD date", v)
+	}
+	if endOfDay {
+		t = t.Add(24 * time.Hour)
+	}
+	return t, nil
+}
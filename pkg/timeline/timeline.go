@@ -0,0 +1,225 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package timeline merges the records kept about a Customer across tables into one chronological
+// list of events for support agents.
+package timeline
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moov-io/customers/pkg/client"
+)
+
+// Types of Events on a timeline
+const (
+	CustomerCreated       = "customer.created"
+	CustomerStatusUpdated = "customer.status_updated"
+	OFACSearched          = "ofac.searched"
+	DocumentUploaded      = "document.uploaded"
+	DocumentDeleted       = "document.deleted"
+	DisclaimerAccepted    = "disclaimer.accepted"
+	EmailSent             = "email.sent"
+)
+
+// Event is one entry on a Customer's timeline. Only the field matching Type is set.
+type Event struct {
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Actor      string    `json:"actor,omitempty"`
+
+	Customer   *CustomerEvent   `json:"customer,omitempty"`
+	Status     *StatusEvent     `json:"status,omitempty"`
+	OFACSearch *OFACSearchEvent `json:"ofacSearch,omitempty"`
+	Document   *DocumentEvent   `json:"document,omitempty"`
+	Disclaimer *DisclaimerEvent `json:"disclaimer,omitempty"`
+	Email      *EmailEvent      `json:"email,omitempty"`
+}
+
+type CustomerEvent struct {
+	Type client.CustomerType `json:"type"`
+}
+
+type StatusEvent struct {
+	Status  client.CustomerStatus `json:"status"`
+	Comment string                `json:"comment,omitempty"`
+}
+
+type OFACSearchEvent struct {
+	EntityID       string  `json:"entityID"`
+	SdnName        string  `json:"sdnName"`
+	Match          float64 `json:"match"`
+	Blocked        bool    `json:"blocked"`
+	ReviewRequired bool    `json:"reviewRequired"`
+}
+
+type DocumentEvent struct {
+	DocumentID  string `json:"documentID"`
+	Type        string `json:"type"`
+	ContentType string `json:"contentType"`
+}
+
+type DisclaimerEvent struct {
+	DisclaimerID string `json:"disclaimerID"`
+}
+
+type EmailEvent struct {
+	EmailID string `json:"emailID"`
+	Type    string `json:"type"`
+	Subject string `json:"subject"`
+}
+
+// Params filter and page through a timeline
+type Params struct {
+	// From and To limit events to those at or after From and before To when set
+	From time.Time
+	To   time.Time
+
+	Skip  int
+	Count int
+}
+
+type Repository interface {
+	customerExists(customerID, organization string) (bool, error)
+	list(customerID string, params Params) ([]*Event, error)
+	count(customerID string, params Params) (int, error)
+}
+
+type sqlRepository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &sqlRepository{db: db}
+}
+
+// sources select every event as the same columns so they can be combined with union all:
+// occurred_at, type, actor, ref_id, label, detail, score, flag and flag2. Each select takes the
+// customerID as its only argument.
+var sources = []string{
+	fmt.Sprintf(`select created_at as occurred_at, '%s' as type, null as actor, null as ref_id, type as label, null as detail, null as score, null as flag, null as flag2
+from customers where customer_id = ?`, CustomerCreated),
+
+	fmt.Sprintf(`select changed_at, '%s', actor, null, future_status, comment, null, null, null
+from customer_status_updates where customer_id = ?`, CustomerStatusUpdated),
+
+	fmt.Sprintf(`select created_at, '%s', null, entity_id, sdn_name, null, percentage_match, blocked, review_required
+from customer_ofac_searches where customer_id = ?`, OFACSearched),
+
+	fmt.Sprintf(`select uploaded_at, '%s', null, document_id, type, content_type, null, null, null
+from documents where customer_id = ?`, DocumentUploaded),
+
+	fmt.Sprintf(`select deleted_at, '%s', null, document_id, type, content_type, null, null, null
+from documents where customer_id = ? and deleted_at is not null`, DocumentDeleted),
+
+	fmt.Sprintf(`select accepted_at, '%s', null, disclaimer_id, null, null, null, null, null
+from disclaimer_acceptances where customer_id = ? and accepted_at is not null`, DisclaimerAccepted),
+
+	fmt.Sprintf(`select sent_at, '%s', null, email_id, email_type, subject, null, null, null
+from outbound_emails where customer_id = ? and sent_at is not null`, EmailSent),
+}
+
+// events returns the union of every source filtered by params and its arguments
+func events(customerID string, params Params) (string, []interface{}) {
+	var args []interface{}
+	for range sources {
+		args = append(args, customerID)
+	}
+	query := "select * from (" + strings.Join(sources, "\nunion all\n") + ") as events where occurred_at is not null"
+	if !params.From.IsZero() {
+		query += " and occurred_at >= ?"
+		args = append(args, params.From)
+	}
+	if !params.To.IsZero() {
+		query += " and occurred_at < ?"
+		args = append(args, params.To)
+	}
+	return query, args
+}
+
+func (r *sqlRepository) customerExists(customerID, organization string) (bool, error) {
+	query := `select customer_id from customers where customer_id = ? and organization = ? and deleted_at is null limit 1;`
+	var id string
+	if err := r.db.QueryRow(query, customerID, organization).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("timeline: customer: %v", err)
+	}
+	return true, nil
+}
+
+// list returns a page of the Customer's events, oldest first. Events at the same time are ordered
+// by their type and ID so pages don't overlap.
+func (r *sqlRepository) list(customerID string, params Params) ([]*Event, error) {
+	query, args := events(customerID, params)
+	query += " order by occurred_at asc, type asc, ref_id asc limit ? offset ?;"
+	args = append(args, params.Count, params.Skip)
+
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("timeline: list: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, fmt.Errorf("timeline: list: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []*Event
+	for rows.Next() {
+		var e Event
+		var actor, refID, label, detail *string
+		var score *float64
+		var flag, flag2 *bool
+		if err := rows.Scan(&e.OccurredAt, &e.Type, &actor, &refID, &label, &detail, &score, &flag, &flag2); err != nil {
+			return nil, fmt.Errorf("timeline: list: scan: %v", err)
+		}
+		e.Actor = str(actor)
+
+		switch e.Type {
+		case CustomerCreated:
+			e.Customer = &CustomerEvent{Type: client.CustomerType(str(label))}
+		case CustomerStatusUpdated:
+			e.Status = &StatusEvent{Status: client.CustomerStatus(str(label)), Comment: str(detail)}
+		case OFACSearched:
+			e.OFACSearch = &OFACSearchEvent{EntityID: str(refID), SdnName: str(label)}
+			if score != nil {
+				e.OFACSearch.Match = *score
+			}
+			e.OFACSearch.Blocked = flag != nil && *flag
+			e.OFACSearch.ReviewRequired = flag2 != nil && *flag2
+		case DocumentUploaded, DocumentDeleted:
+			e.Document = &DocumentEvent{DocumentID: str(refID), Type: str(label), ContentType: str(detail)}
+		case DisclaimerAccepted:
+			e.Disclaimer = &DisclaimerEvent{DisclaimerID: str(refID)}
+		case EmailSent:
+			e.Email = &EmailEvent{EmailID: str(refID), Type: str(label), Subject: str(detail)}
+		}
+		out = append(out, &e)
+	}
+	return out, rows.Err()
+}
+
+func (r *sqlRepository) count(customerID string, params Params) (int, error) {
+	query, args := events(customerID, params)
+
+	var n int
+	if err := r.db.QueryRow("select count(*) from ("+query+") as page;", args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("timeline: count: %v", err)
+	}
+	return n, nil
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package timeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/pkg/client"
+	"github.com/moov-io/customers/pkg/customers"
+)
+
+func TestTimeline__readParams(t *testing.T) {
+	params, err := readParams(httptest.NewRequest("GET", "/customers/foo/timeline?from=2020-01-01&to=2020-01-31T12:00:00Z&skip=5&count=10", nil))
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), params.From)
+	require.Equal(t, time.Date(2020, time.January, 31, 12, 0, 0, 0, time.UTC), params.To)
+	require.Equal(t, 5, params.Skip)
+	require.Equal(t, 10, params.Count)
+
+	for _, q := range []string{"from=yesterday", "from=2020-02-01&to=2020-01-01"} {
+		_, err := readParams(httptest.NewRequest("GET", "/customers/foo/timeline?"+q, nil))
+		require.Error(t, err, q)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	cust := &client.Customer{CustomerID: "foo", FirstName: "Jane", LastName: "Doe", Type: client.CUSTOMERTYPE_INDIVIDUAL}
+	require.NoError(t, customers.NewCustomerRepo(log.NewNopLogger(), db.DB).CreateCustomer(context.Background(), cust, "test"))
+
+	start := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+	exec := func(query string, args ...interface{}) {
+		_, err := db.DB.Exec(query, args...)
+		require.NoError(t, err)
+	}
+	exec(`update customers set created_at = ? where customer_id = 'foo';`, at(0))
+	exec(`insert into customer_ofac_searches (customer_id, entity_id, sdn_name, percentage_match, blocked, review_required, created_at) values ('foo', '123', 'Jane Smith', 0.91, false, true, ?);`, at(1))
+	exec(`insert into documents (document_id, customer_id, type, content_type, uploaded_at, deleted_at) values ('doc1', 'foo', 'passport', 'image/png', ?, ?);`, at(2), at(6))
+	exec(`insert into disclaimer_acceptances (disclaimer_id, customer_id, accepted_at) values ('disc1', 'foo', ?);`, at(3))
+	exec(`insert into disclaimer_acceptances (disclaimer_id, customer_id) values ('disc2', 'foo');`)
+	exec(`insert into customer_status_updates (customer_id, future_status, comment, actor, changed_at) values ('foo', 'Verified', 'looks good', 'operator', ?);`, at(4))
+	exec(`insert into outbound_emails (email_id, customer_id, email_type, recipient, subject, body, next_attempt_at, sent_at, created_at) values ('email1', 'foo', 'status_updated', 'jane@example.com', 'Welcome', 'hi', ?, ?, ?);`, at(4), at(5), at(4))
+	exec(`insert into outbound_emails (email_id, customer_id, email_type, recipient, subject, body, next_attempt_at, created_at) values ('email2', 'foo', 'status_updated', 'jane@example.com', 'Pending', 'hi', ?, ?);`, at(4), at(4))
+	exec(`insert into documents (document_id, customer_id, type, content_type, uploaded_at) values ('other', 'bar', 'passport', 'image/png', ?);`, at(2))
+
+	router := mux.NewRouter()
+	AddRoutes(log.NewNopLogger(), router, NewRepository(db.DB))
+
+	read := func(customerID, organization, query string) (*httptest.ResponseRecorder, []*Event) {
+		req := httptest.NewRequest("GET", "/customers/"+customerID+"/timeline?"+query, nil)
+		req.Header.Set("X-Organization", organization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var events []*Event
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&events))
+		}
+		return w, events
+	}
+	types := func(events []*Event) []string {
+		var out []string
+		for i := range events {
+			out = append(out, events[i].Type)
+		}
+		return out
+	}
+
+	w, events := read("foo", "test", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "7", w.Header().Get("X-Total-Count"))
+	require.Equal(t, []string{
+		CustomerCreated, OFACSearched, DocumentUploaded, DisclaimerAccepted, CustomerStatusUpdated, EmailSent, DocumentDeleted,
+	}, types(events))
+
+	require.Equal(t, client.CUSTOMERTYPE_INDIVIDUAL, events[0].Customer.Type)
+	require.True(t, events[0].OccurredAt.Equal(at(0)))
+	require.Equal(t, &OFACSearchEvent{EntityID: "123", SdnName: "Jane Smith", Match: 0.91, ReviewRequired: true}, events[1].OFACSearch)
+	require.Equal(t, &DocumentEvent{DocumentID: "doc1", Type: "passport", ContentType: "image/png"}, events[2].Document)
+	require.Equal(t, "disc1", events[3].Disclaimer.DisclaimerID)
+	require.Equal(t, &StatusEvent{Status: client.CUSTOMERSTATUS_VERIFIED, Comment: "looks good"}, events[4].Status)
+	require.Equal(t, "operator", events[4].Actor)
+	require.Equal(t, &EmailEvent{EmailID: "email1", Type: "status_updated", Subject: "Welcome"}, events[5].Email)
+	require.True(t, events[6].OccurredAt.Equal(at(6)))
+
+	// date range and pagination
+	w, events = read("foo", "test", "from="+at(2).Format(time.RFC3339)+"&to="+at(5).Format(time.RFC3339))
+	require.Equal(t, "3", w.Header().Get("X-Total-Count"))
+	require.Equal(t, []string{DocumentUploaded, DisclaimerAccepted, CustomerStatusUpdated}, types(events))
+
+	w, events = read("foo", "test", "skip=5&count=5")
+	require.Equal(t, "7", w.Header().Get("X-Total-Count"))
+	require.Equal(t, []string{EmailSent, DocumentDeleted}, types(events))
+
+	w, events = read("foo", "test", "from=2021-01-01")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, events)
+
+	w, _ = read("foo", "other", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w, _ = read("foo", "test", "from=tomorrow")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
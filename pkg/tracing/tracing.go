@@ -0,0 +1,132 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package tracing wraps OpenTelemetry so spans can be created around database and
+// downstream calls. Spans are exported over OTLP when an endpoint is configured and
+// are discarded otherwise.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/moov-io/customers"
+
+var (
+	// CustomerIDKey is the span attribute holding the Customer a span operates on
+	CustomerIDKey = label.Key("customer.id")
+)
+
+type Config struct {
+	// Endpoint is the host:port of an OTLP collector. Tracing is disabled when empty.
+	Endpoint string
+
+	// Insecure disables TLS to the collector
+	Insecure bool
+
+	ServiceName string
+}
+
+// Setup installs a global tracer provider exporting spans to cfg.Endpoint and propagation of the
+// W3C trace context headers. The returned func flushes any remaining spans and should be called
+// on shutdown.
+//
+// When cfg.Endpoint is empty the global no-op provider is left in place.
+func Setup(logger log.Logger, cfg Config) (func(), error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.Endpoint == "" {
+		logger.Log("tracing is disabled")
+		return func() {}, nil
+	}
+	if cfg.ServiceName == "" {
+		return nil, errors.New("tracing: missing service name")
+	}
+
+	opts := []otlpgrpc.Option{otlpgrpc.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlpgrpc.WithInsecure())
+	}
+	exporter := otlp.NewUnstartedExporter(otlpgrpc.NewDriver(opts...))
+	if err := exporter.Start(context.Background()); err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.ServiceNameKey.String(cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	logger.Logf("exporting traces to %s", cfg.Endpoint)
+
+	return func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			logger.LogErrorf("problem shutting down tracer provider: %v", err)
+		}
+	}, nil
+}
+
+// Middleware starts a server span for each HTTP request. Trace context from the request headers
+// is used as the parent so spans link with the calling service.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), r.Header)
+
+		name := r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if tmpl, err := route.GetPathTemplate(); err == nil {
+				name = tmpl
+			}
+		}
+		ctx, span := tracer().Start(ctx, r.Method+" "+name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPMethodKey.String(r.Method), semconv.HTTPRouteKey.String(name)),
+		)
+		defer span.End()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// StartSpan begins a span for operation (e.g. "CreateCustomer") on the given Customer.
+// Callers must finish the span with EndSpan.
+func StartSpan(ctx context.Context, operation, customerID string) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return tracer().Start(ctx, operation, trace.WithAttributes(
+		semconv.DBOperationKey.String(operation),
+		CustomerIDKey.String(customerID),
+	))
+}
+
+// EndSpan records err (if non-nil) on span and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/export/trace/tracetest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTracing__Setup(t *testing.T) {
+	shutdown, err := Setup(log.NewNopLogger(), Config{})
+	require.NoError(t, err)
+	require.NotNil(t, shutdown)
+	shutdown()
+
+	_, err = Setup(log.NewNopLogger(), Config{Endpoint: "localhost:4317"})
+	require.Error(t, err)
+}
+
+func TestTracing__spans(t *testing.T) {
+	_, err := Setup(log.NewNopLogger(), Config{})
+	require.NoError(t, err)
+
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	router.Methods("GET").Path("/customers/{customerID}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := StartSpan(r.Context(), "GetCustomer", mux.Vars(r)["customerID"])
+		EndSpan(span, errors.New("bad error"))
+	})
+
+	req := httptest.NewRequest("GET", "/customers/foo", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	db, server := spans[0], spans[1]
+	require.Equal(t, "GetCustomer", db.Name)
+	require.Equal(t, codes.Error, db.StatusCode)
+	require.Equal(t, server.SpanContext.SpanID, db.ParentSpanID)
+
+	var customerID string
+	for _, attr := range db.Attributes {
+		if attr.Key == CustomerIDKey {
+			customerID = attr.Value.AsString()
+		}
+	}
+	require.Equal(t, "foo", customerID)
+
+	// the request span continues the upstream trace
+	require.Equal(t, "GET /customers/{customerID}", server.Name)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID.String())
+	require.True(t, server.HasRemoteParent)
+}
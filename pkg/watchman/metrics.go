@@ -0,0 +1,102 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package watchman
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	watchman "github.com/moov-io/watchman/client"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Name:    "watchman_request_duration_seconds",
+		Help:    "Histogram of Watchman call durations by method and outcome",
+		Buckets: stdprometheus.DefBuckets,
+	}, []string{"method", "outcome"})
+
+	requestErrors = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "watchman_request_errors_total",
+		Help: "Counter of failed Watchman calls by method and outcome",
+	}, []string{"method", "outcome"})
+)
+
+// Outcomes of each Watchman call
+const (
+	outcomeSuccess   = "success"
+	outcomeError     = "error"
+	outcomeTimeout   = "timeout"
+	outcomeCancelled = "cancelled"
+)
+
+// instrumentedClient records the duration and outcome of every call to the underlying Client. A
+// call covers each request Watchman needs to answer it, e.g. Search looks up individuals and
+// entities and then the SDN of a matched alternate name.
+type instrumentedClient struct {
+	Client
+}
+
+func instrument(c Client) Client {
+	return &instrumentedClient{Client: c}
+}
+
+// callOutcome classifies err, the outcome of a call made with ctx
+func callOutcome(ctx context.Context, err error) string {
+	if err == nil {
+		return outcomeSuccess
+	}
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return outcomeTimeout
+	case context.Canceled:
+		return outcomeCancelled
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return outcomeTimeout
+	}
+	return outcomeError
+}
+
+func observe(ctx context.Context, method string, start time.Time, err error) {
+	outcome := callOutcome(ctx, err)
+	requestDuration.With("method", method, "outcome", outcome).Observe(time.Since(start).Seconds())
+	if outcome != outcomeSuccess {
+		requestErrors.With("method", method, "outcome", outcome).Add(1)
+	}
+}
+
+func (c *instrumentedClient) Ping() error {
+	start := time.Now()
+	err := c.Client.Ping()
+	observe(context.Background(), "ping", start, err)
+	return err
+}
+
+func (c *instrumentedClient) Search(ctx context.Context, name string, requestID string) (*watchman.OfacSdn, error) {
+	start := time.Now()
+	sdn, err := c.Client.Search(ctx, name, requestID)
+	observe(ctx, "search", start, err)
+	return sdn, err
+}
+
+func (c *instrumentedClient) Candidates(ctx context.Context, name string, limit int, requestID string) ([]watchman.OfacSdn, error) {
+	start := time.Now()
+	sdns, err := c.Client.Candidates(ctx, name, limit, requestID)
+	observe(ctx, "candidates", start, err)
+	return sdns, err
+}
+
+func (c *instrumentedClient) AddressMatches(ctx context.Context, addr Address, requestID string) (map[string]float32, error) {
+	start := time.Now()
+	matches, err := c.Client.AddressMatches(ctx, addr, requestID)
+	observe(ctx, "address_matches", start, err)
+	return matches, err
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package watchman
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	watchman "github.com/moov-io/watchman/client"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchman__callOutcome(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, outcomeSuccess, callOutcome(ctx, nil))
+	require.Equal(t, outcomeError, callOutcome(ctx, errors.New("bad")))
+	require.Equal(t, outcomeTimeout, callOutcome(ctx, context.DeadlineExceeded))
+
+	expired, cancelFn := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancelFn()
+	require.Equal(t, outcomeTimeout, callOutcome(expired, errors.New("bad")))
+
+	cancelled, cancelFn := context.WithCancel(ctx)
+	cancelFn()
+	require.Equal(t, outcomeCancelled, callOutcome(cancelled, errors.New("bad")))
+}
+
+func TestWatchman__instrumentedClient(t *testing.T) {
+	ctx := context.Background()
+	sdn := &watchman.OfacSdn{EntityID: "1241421", SdnName: "Jane Doe", Match: 0.5}
+
+	client := instrument(NewTestWatchmanClient(sdn, nil))
+	found, err := client.Search(ctx, "jane doe", "")
+	require.NoError(t, err)
+	require.Equal(t, sdn, found)
+	_, err = client.Candidates(ctx, "jane doe", 5, "")
+	require.NoError(t, err)
+
+	failing := instrument(NewTestWatchmanClient(nil, errors.New("bad")))
+	_, err = failing.Candidates(ctx, "jane doe", 5, "")
+	require.Error(t, err)
+	_, err = failing.AddressMatches(ctx, Address{City: "Caracas"}, "")
+	require.Error(t, err)
+
+	expired, cancelFn := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancelFn()
+	_, err = failing.Search(expired, "jane doe", "")
+	require.Error(t, err)
+
+	observed := make(map[string]uint64)
+	errs := make(map[string]float64)
+	families, err := stdprometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			key := label(m, "method") + "/" + label(m, "outcome")
+			switch family.GetName() {
+			case "watchman_request_duration_seconds":
+				observed[key] += m.GetHistogram().GetSampleCount()
+			case "watchman_request_errors_total":
+				errs[key] += m.GetCounter().GetValue()
+			}
+		}
+	}
+	require.Equal(t, uint64(1), observed["search/success"])
+	require.Equal(t, uint64(1), observed["candidates/success"])
+	require.Equal(t, uint64(1), observed["search/timeout"])
+	require.Equal(t, float64(1), errs["search/timeout"])
+	require.Equal(t, float64(1), errs["candidates/error"])
+	require.Equal(t, float64(1), errs["address_matches/error"])
+	require.Zero(t, errs["search/success"])
+}
+
+func label(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
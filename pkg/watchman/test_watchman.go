@@ -31,3 +31,23 @@ func (c *TestWatchmanClient) Search(_ context.Context, name string, _ string) (*
 
 	return c.sdn, nil
 }
+
+func (c *TestWatchmanClient) Candidates(_ context.Context, name string, limit int, _ string) ([]watchman.OfacSdn, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.sdn == nil || limit == 0 {
+		return nil, nil
+	}
+	return []watchman.OfacSdn{*c.sdn}, nil
+}
+
+func (c *TestWatchmanClient) AddressMatches(_ context.Context, _ Address, _ string) (map[string]float32, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.sdn == nil {
+		return nil, nil
+	}
+	return map[string]float32{c.sdn.EntityID: c.sdn.Match}, nil
+}
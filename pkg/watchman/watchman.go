@@ -6,7 +6,11 @@ package watchman
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"sort"
 	"time"
 
 	"github.com/antihax/optional"
@@ -21,29 +25,78 @@ type Client interface {
 	Ping() error
 
 	Search(ctx context.Context, name string, requestID string) (*watchman.OfacSdn, error)
+
+	// Candidates returns up to limit SDNs whose name, or one of their alternate names, is similar
+	// to name. The highest match is first.
+	Candidates(ctx context.Context, name string, limit int, requestID string) ([]watchman.OfacSdn, error)
+
+	// AddressMatches returns how closely each SDN with an address similar to addr matched, keyed
+	// by their EntityID
+	AddressMatches(ctx context.Context, addr Address, requestID string) (map[string]float32, error)
+}
+
+// Address is searched against the addresses on OFAC's SDN list
+type Address struct {
+	Address    string
+	City       string
+	State      string
+	PostalCode string
+	Country    string
+}
+
+// Config describes the Watchman instance searched for OFAC matches
+type Config struct {
+	// Endpoint is the base address of Watchman, like http://watchman.apps.svc.cluster.local:8080
+	Endpoint string
+
+	// Timeout limits each request made to Watchman. Requests only end with their context when it's zero.
+	Timeout time.Duration
+
+	// AuthToken is sent as a bearer token in the Authorization header of each request when set
+	AuthToken string
+
+	// Debug prints each request and response
+	Debug bool
 }
 
 type moovWatchmanClient struct {
 	underlying *watchman.APIClient
+	timeout    time.Duration
 	logger     log.Logger
 }
 
+// checkResponse closes the response and returns an error for calls which didn't get an answer from
+// Watchman, so timeouts and error statuses are never read as a search without matches
+func (c *moovWatchmanClient) checkResponse(method string, resp *http.Response, err error) error {
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		if c.timeout > 0 {
+			return fmt.Errorf("watchman.%s: timed out after %v: %w", method, c.timeout, err)
+		}
+		return fmt.Errorf("watchman.%s: timed out: %w", method, err)
+	}
+	if resp != nil && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return fmt.Errorf("watchman.%s: unexpected status %s", method, resp.Status)
+	}
+	if err != nil {
+		return fmt.Errorf("watchman.%s: %w", method, err)
+	}
+	if resp == nil {
+		return fmt.Errorf("watchman.%s: no response", method)
+	}
+	return nil
+}
+
 func (c *moovWatchmanClient) Ping() error {
 	// create a context just for this so ping requests don't require the setup of one
 	ctx, cancelFn := context.WithTimeout(context.TODO(), 10*time.Second)
 	defer cancelFn()
 
 	resp, err := c.underlying.WatchmanApi.Ping(ctx)
-	if resp != nil && resp.Body != nil {
-		resp.Body.Close()
-	}
-	if resp == nil {
-		return fmt.Errorf("watchman.Ping: failed: %v", err)
-	}
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("watchman.Ping: got status: %s", resp.Status)
-	}
-	return err
+	return c.checkResponse("Ping", resp, err)
 }
 
 func (c *moovWatchmanClient) ofacSearch(ctx context.Context, name string, sdnType string, requestID string) (*watchman.Search, error) {
@@ -53,14 +106,10 @@ func (c *moovWatchmanClient) ofacSearch(ctx context.Context, name string, sdnTyp
 		Limit:      optional.NewInt32(10), // Ask for multiple results as Watchman takes N and then filters, probably a bug in that code.
 		XRequestID: optional.NewString(requestID),
 	})
-	if err != nil {
-		return nil, fmt.Errorf("watchman.Search: sdnType=%s: %v", sdnType, err)
-	}
-	resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return nil, fmt.Errorf("watchman.Search: customer=%q sdnType=%s (status code: %d): %v", name, sdnType, resp.StatusCode, err)
+	if err := c.checkResponse("Search", resp, err); err != nil {
+		return nil, fmt.Errorf("sdnType=%s: %w", sdnType, err)
 	}
-	return &search, err
+	return &search, nil
 }
 
 func highestOfacSearchMatch(results ...*watchman.Search) *watchman.Search {
@@ -108,9 +157,8 @@ func (c *moovWatchmanClient) altToSDN(ctx context.Context, alt watchman.OfacAlt,
 	sdn, resp, err := c.underlying.WatchmanApi.GetSDN(ctx, alt.EntityID, &watchman.GetSDNOpts{
 		XRequestID: optional.NewString(requestID),
 	})
-	resp.Body.Close()
-	if err != nil {
-		return nil, fmt.Errorf("watchman.Search: found alt name: %v", err)
+	if err := c.checkResponse("GetSDN", resp, err); err != nil {
+		return nil, fmt.Errorf("found alt name: %w", err)
 	}
 	sdn.Match = alt.Match // copy match from original search (GetSDN doesn't do string matching)
 	return &sdn, nil
@@ -157,28 +205,105 @@ func (c *moovWatchmanClient) Search(ctx context.Context, name string, requestID
 	return nil, nil // Nothing found
 }
 
+func (c *moovWatchmanClient) Candidates(ctx context.Context, name string, limit int, requestID string) ([]watchman.OfacSdn, error) {
+	byEntity := make(map[string]watchman.OfacSdn)
+	keep := func(sdn watchman.OfacSdn) {
+		if prev, ok := byEntity[sdn.EntityID]; !ok || sdn.Match > prev.Match {
+			byEntity[sdn.EntityID] = sdn
+		}
+	}
+	for _, sdnType := range []string{"individual", "entity"} {
+		search, err := c.ofacSearch(ctx, name, sdnType, requestID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range search.SDNs {
+			keep(search.SDNs[i])
+		}
+		for i := range search.AltNames {
+			alt := search.AltNames[i]
+			if prev, ok := byEntity[alt.EntityID]; ok && prev.Match >= alt.Match {
+				continue
+			}
+			sdn, err := c.altToSDN(ctx, alt, requestID)
+			if err != nil {
+				return nil, err
+			}
+			keep(*sdn)
+		}
+	}
+
+	out := make([]watchman.OfacSdn, 0, len(byEntity))
+	for _, sdn := range byEntity {
+		out = append(out, sdn)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Match == out[j].Match {
+			return out[i].EntityID < out[j].EntityID
+		}
+		return out[i].Match > out[j].Match
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (c *moovWatchmanClient) AddressMatches(ctx context.Context, addr Address, requestID string) (map[string]float32, error) {
+	opts := &watchman.SearchOpts{
+		Limit:      optional.NewInt32(10),
+		XRequestID: optional.NewString(requestID),
+	}
+	for v, field := range map[string]*optional.String{
+		addr.Address:    &opts.Address,
+		addr.City:       &opts.City,
+		addr.State:      &opts.State,
+		addr.PostalCode: &opts.Zip,
+		addr.Country:    &opts.Country,
+	} {
+		if v != "" {
+			*field = optional.NewString(v)
+		}
+	}
+	search, resp, err := c.underlying.WatchmanApi.Search(ctx, opts)
+	if err := c.checkResponse("AddressMatches", resp, err); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]float32)
+	for _, a := range search.Addresses {
+		if a.Match > out[a.EntityID] {
+			out[a.EntityID] = a.Match
+		}
+	}
+	return out, nil
+}
+
 // NewClient returns an WatchmanClient instance and will default to using the Watchman address in
-// moov's standard Kubernetes setup.
-//
-// endpoint is a DNS record responsible for routing us to an Watchman instance.
-// Example: http://watchman.apps.svc.cluster.local:8080
-func NewClient(logger log.Logger, endpoint string, debug bool) Client {
+// moov's standard Kubernetes setup when cfg.Endpoint is empty. The duration and outcome of each call
+// are recorded in the watchman_request_duration_seconds and watchman_request_errors_total metrics.
+func NewClient(logger log.Logger, cfg Config) Client {
 	conf := watchman.NewConfiguration()
 	conf.BasePath = "http://localhost" + bind.HTTP("watchman")
-	conf.Debug = debug
+	conf.Debug = cfg.Debug
+	conf.HTTPClient = &http.Client{Timeout: cfg.Timeout}
 
 	if k8s.Inside() {
 		conf.BasePath = "http://watchman.apps.svc.cluster.local:8080"
 	}
-	if endpoint != "" {
-		conf.BasePath = endpoint // override from provided Watchman_ENDPOINT env variable
+	if cfg.Endpoint != "" {
+		conf.BasePath = cfg.Endpoint // override from provided WATCHMAN_ENDPOINT env variable
+	}
+	if cfg.AuthToken != "" {
+		conf.AddDefaultHeader("Authorization", "Bearer "+cfg.AuthToken)
 	}
 
 	logger = logger.Set("package", log.String("watchman"))
 	logger.Logf("using %s for Watchman address", conf.BasePath)
 
-	return &moovWatchmanClient{
+	return instrument(&moovWatchmanClient{
 		underlying: watchman.NewAPIClient(conf),
+		timeout:    cfg.Timeout,
 		logger:     logger,
-	}
+	})
 }
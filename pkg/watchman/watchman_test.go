@@ -9,8 +9,11 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/moov-io/base"
 	"github.com/moov-io/base/docker"
@@ -18,6 +21,7 @@ import (
 
 	"github.com/moov-io/base/log"
 	"github.com/ory/dockertest/v3"
+	"github.com/stretchr/testify/require"
 )
 
 type watchmanDeployment struct {
@@ -55,7 +59,7 @@ func spawnWatchman(t *testing.T) *watchmanDeployment {
 		t.Fatal(err)
 	}
 
-	client := NewClient(log.NewNopLogger(), fmt.Sprintf("http://localhost:%s", resource.GetPort("8080/tcp")), false)
+	client := NewClient(log.NewNopLogger(), Config{Endpoint: fmt.Sprintf("http://localhost:%s", resource.GetPort("8080/tcp"))})
 	err = pool.Retry(func() error {
 		return client.Ping()
 	})
@@ -66,8 +70,7 @@ func spawnWatchman(t *testing.T) *watchmanDeployment {
 }
 
 func TestWatchman__client(t *testing.T) {
-	endpoint := ""
-	if client := NewClient(log.NewNopLogger(), endpoint, false); client == nil {
+	if client := NewClient(log.NewNopLogger(), Config{}); client == nil {
 		t.Fatal("expected non-nil client")
 	}
 
@@ -103,7 +106,7 @@ func TestWatchman__search(t *testing.T) {
 	}
 
 	// Lookup an SDN from an alt
-	if c, ok := deployment.client.(*moovWatchmanClient); ok {
+	if c, ok := deployment.client.(*instrumentedClient).Client.(*moovWatchmanClient); ok {
 		search, err := c.ofacSearch(ctx, "Osama Bin Ladin", "individual", base.ID())
 		if err != nil {
 			t.Fatal(err)
@@ -119,6 +122,78 @@ func TestWatchman__search(t *testing.T) {
 	deployment.close(t) // close only if successful
 }
 
+func TestWatchman__candidates(t *testing.T) {
+	ctx := context.TODO()
+
+	deployment := spawnWatchman(t)
+
+	sdns, err := deployment.client.Candidates(ctx, "Nicolas Maduro", 5, base.ID())
+	if err != nil || len(sdns) == 0 {
+		t.Fatalf("sdns=%v err=%v", sdns, err)
+	}
+	if len(sdns) > 5 {
+		t.Errorf("got %d candidates", len(sdns))
+	}
+	if sdns[0].EntityID != "22790" {
+		t.Errorf("SDN=%s %#v", sdns[0].EntityID, sdns[0])
+	}
+	seen := make(map[string]bool)
+	for i := range sdns {
+		if seen[sdns[i].EntityID] {
+			t.Errorf("duplicate SDN=%s", sdns[i].EntityID)
+		}
+		seen[sdns[i].EntityID] = true
+		if i > 0 && sdns[i].Match > sdns[i-1].Match {
+			t.Errorf("candidates out of order: %#v", sdns)
+		}
+	}
+
+	matches, err := deployment.client.AddressMatches(ctx, Address{City: "Caracas", Country: "Venezuela"}, base.ID())
+	if err != nil || len(matches) == 0 {
+		t.Fatalf("matches=%v err=%v", matches, err)
+	}
+
+	deployment.close(t) // close only if successful
+}
+
+func TestWatchman__config(t *testing.T) {
+	var authorization string
+	status := http.StatusOK
+	svc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if r.URL.Query().Get("q") == "slow" {
+			time.Sleep(250 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{}`))
+	}))
+	defer svc.Close()
+
+	client := NewClient(log.NewNopLogger(), Config{Endpoint: svc.URL, Timeout: 50 * time.Millisecond, AuthToken: "secret"})
+	ctx := context.Background()
+
+	sdn, err := client.Search(ctx, "Jane Doe", base.ID())
+	require.NoError(t, err)
+	require.Nil(t, sdn)
+	require.Equal(t, "Bearer secret", authorization)
+
+	// errors are never read as a search without matches
+	status = http.StatusServiceUnavailable
+	_, err = client.Search(ctx, "Jane Doe", base.ID())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unexpected status 503 Service Unavailable")
+	_, err = client.AddressMatches(ctx, Address{City: "Caracas"}, base.ID())
+	require.Error(t, err)
+	require.Error(t, client.Ping())
+
+	status = http.StatusOK
+	_, err = client.Search(ctx, "slow", base.ID())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "timed out after 50ms")
+	require.Equal(t, outcomeTimeout, callOutcome(ctx, err))
+}
+
 func TestWatchman_ping(t *testing.T) {
 	client := &TestWatchmanClient{}
 
@@ -0,0 +1,85 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package webhooks
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/base/log"
+)
+
+// Attempt is one delivery of an Event to the webhook endpoint
+type Attempt struct {
+	EventID     string    `json:"eventID"`
+	EventType   EventType `json:"eventType"`
+	CustomerID  string    `json:"customerID"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"statusCode"`
+	Error       string    `json:"error,omitempty"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+type Repository interface {
+	recordAttempt(event Event, attempt int, statusCode int, err error) error
+	getAttempts(customerID string, limit int) ([]*Attempt, error)
+}
+
+func NewRepository(logger log.Logger, db *sql.DB) Repository {
+	return &sqlRepository{db: db, logger: logger}
+}
+
+type sqlRepository struct {
+	db     *sql.DB
+	logger log.Logger
+}
+
+func (r *sqlRepository) recordAttempt(event Event, attempt int, statusCode int, err error) error {
+	query := `insert into webhook_attempts (event_id, event_type, customer_id, attempt, status_code, error, attempted_at) values (?, ?, ?, ?, ?, ?, ?);`
+	stmt, prepErr := r.db.Prepare(query)
+	if prepErr != nil {
+		return fmt.Errorf("recordAttempt: prepare: %v", prepErr)
+	}
+	defer stmt.Close()
+
+	var errMsg string
+	if err != nil {
+		errMsg = err.Error()
+		if len(errMsg) > 512 {
+			errMsg = errMsg[:512]
+		}
+	}
+	if _, err := stmt.Exec(event.EventID, event.EventType, event.CustomerID, attempt, statusCode, errMsg, time.Now()); err != nil {
+		return fmt.Errorf("recordAttempt: exec: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) getAttempts(customerID string, limit int) ([]*Attempt, error) {
+	query := `select event_id, event_type, customer_id, attempt, status_code, error, attempted_at from webhook_attempts
+where customer_id = ? order by attempted_at desc limit ?;`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("getAttempts: prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(customerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("getAttempts: query: %v", err)
+	}
+	defer rows.Close()
+
+	var out []*Attempt
+	for rows.Next() {
+		var a Attempt
+		if err := rows.Scan(&a.EventID, &a.EventType, &a.CustomerID, &a.Attempt, &a.StatusCode, &a.Error, &a.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("getAttempts: scan: %v", err)
+		}
+		out = append(out, &a)
+	}
+	return out, rows.Err()
+}
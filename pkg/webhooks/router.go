@@ -0,0 +1,58 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/moov-io/base/admin"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/pkg/route"
+)
+
+// AddAdminRoutes registers an endpoint to debug webhook deliveries
+func AddAdminRoutes(logger log.Logger, svc *admin.Server, repo Repository) {
+	logger = logger.Set("package", log.String("webhooks"))
+
+	svc.AddHandler("/customers/{customerID}/webhooks", getAttempts(logger, repo))
+}
+
+func getAttempts(logger log.Logger, repo Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w = route.Responder(logger, w, r)
+		logger := route.RequestLogger(logger, r)
+
+		if r.Method != "GET" {
+			route.Problem(w, fmt.Errorf("unsupported HTTP verb %s", r.Method))
+			return
+		}
+
+		customerID := route.GetCustomerID(w, r)
+		if customerID == "" {
+			return
+		}
+
+		_, count, _, err := moovhttp.GetSkipAndCount(r)
+		if err != nil {
+			route.Problem(w, err)
+			return
+		}
+
+		attempts, err := repo.getAttempts(customerID, count)
+		if err != nil {
+			logger.Set("customerID", log.String(customerID)).LogErrorf("problem reading webhook attempts: %v", err)
+			route.Problem(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(attempts)
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/customers/internal/background"
+)
+
+// EventType identifies which customer lifecycle event occurred
+type EventType string
+
+const (
+	CustomerCreated       EventType = "customer.created"
+	CustomerStatusUpdated EventType = "customer.status_updated"
+	CustomerDeleted       EventType = "customer.deleted"
+
+	// DocumentExpiring is sent once for each Document which is about to expire
+	DocumentExpiring EventType = "document.expiring"
+
+	// DocumentUploaded is sent once a Document is uploaded and processing it has finished
+	DocumentUploaded EventType = "document.uploaded"
+)
+
+// SignatureHeader holds the hex encoded HMAC-SHA256 of the request body, keyed with the webhook secret.
+const SignatureHeader = "X-Webhook-Signature"
+
+// Event is the JSON payload delivered to webhook receivers
+type Event struct {
+	EventID      string    `json:"eventID"`
+	EventType    EventType `json:"eventType"`
+	CustomerID   string    `json:"customerID"`
+	Organization string    `json:"organization,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	DocumentID   string    `json:"documentID,omitempty"`
+	DocumentType string    `json:"documentType,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Notifier delivers customer lifecycle events to downstream systems.
+type Notifier interface {
+	// Notify sends the event in the background. Delivery failures are recorded
+	// and never returned to the caller.
+	Notify(eventType EventType, customerID, organization, status string)
+}
+
+// DocumentNotifier is implemented by Notifiers which can include a Document in their events.
+type DocumentNotifier interface {
+	NotifyDocument(eventType EventType, customerID, organization, documentID, documentType string)
+}
+
+// NotifyDocument sends an event about one of the Customer's Documents. Notifiers which don't
+// implement DocumentNotifier are sent the event without the Document.
+func NotifyDocument(n Notifier, eventType EventType, customerID, organization, documentID, documentType string) {
+	switch dn := n.(type) {
+	case nil:
+	case DocumentNotifier:
+		dn.NotifyDocument(eventType, customerID, organization, documentID, documentType)
+	default:
+		n.Notify(eventType, customerID, organization, "")
+	}
+}
+
+// MultiNotifier returns a Notifier which sends each event to every non-nil notifier.
+func MultiNotifier(notifiers ...Notifier) Notifier {
+	var out multiNotifier
+	for i := range notifiers {
+		if notifiers[i] != nil {
+			out = append(out, notifiers[i])
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+type multiNotifier []Notifier
+
+func (ns multiNotifier) Notify(eventType EventType, customerID, organization, status string) {
+	for i := range ns {
+		ns[i].Notify(eventType, customerID, organization, status)
+	}
+}
+
+func (ns multiNotifier) NotifyDocument(eventType EventType, customerID, organization, documentID, documentType string) {
+	for i := range ns {
+		NotifyDocument(ns[i], eventType, customerID, organization, documentID, documentType)
+	}
+}
+
+// Config holds the settings for delivering webhooks
+type Config struct {
+	Endpoint string
+	Secret   string
+
+	// Events is the set of subscribed event types, an empty set subscribes to every event.
+	Events []EventType
+
+	MaxAttempts int
+	Backoff     time.Duration
+
+	// Workers runs each delivery so shutdown waits for those in progress, retries stop once its
+	// context is cancelled. Deliveries run in their own Group when it's nil.
+	Workers *background.Group
+}
+
+// ReadEventTypes parses a comma separated list of event types.
+func ReadEventTypes(v string) ([]EventType, error) {
+	var out []EventType
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		switch et := EventType(strings.ToLower(s)); et {
+		case CustomerCreated, CustomerStatusUpdated, CustomerDeleted, DocumentExpiring, DocumentUploaded:
+			out = append(out, et)
+		default:
+			return nil, fmt.Errorf("unknown webhook event type: %s", s)
+		}
+	}
+	return out, nil
+}
+
+// WebhookNotifier POSTs signed events to an HTTP endpoint, retrying failures with exponential backoff.
+type WebhookNotifier struct {
+	logger     log.Logger
+	repo       Repository
+	httpClient *http.Client
+
+	endpoint    string
+	secret      []byte
+	events      map[EventType]bool
+	maxAttempts int
+	backoff     time.Duration
+	workers     *background.Group
+}
+
+// NewNotifier returns a Notifier for cfg or nil if no endpoint is configured.
+func NewNotifier(logger log.Logger, repo Repository, cfg Config) (Notifier, error) {
+	if cfg.Endpoint == "" {
+		return nil, nil
+	}
+	if cfg.Secret == "" {
+		return nil, errors.New("webhooks: missing secret")
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.Workers == nil {
+		cfg.Workers = background.NewGroup(context.Background())
+	}
+	events := make(map[EventType]bool)
+	for i := range cfg.Events {
+		events[cfg.Events[i]] = true
+	}
+	return &WebhookNotifier{
+		logger: logger.Set("package", log.String("webhooks")),
+		repo:   repo,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		endpoint:    cfg.Endpoint,
+		secret:      []byte(cfg.Secret),
+		events:      events,
+		maxAttempts: cfg.MaxAttempts,
+		backoff:     cfg.Backoff,
+		workers:     cfg.Workers,
+	}, nil
+}
+
+func (n *WebhookNotifier) subscribed(eventType EventType) bool {
+	return len(n.events) == 0 || n.events[eventType]
+}
+
+func (n *WebhookNotifier) Notify(eventType EventType, customerID, organization, status string) {
+	n.notify(Event{
+		EventType:    eventType,
+		CustomerID:   customerID,
+		Organization: organization,
+		Status:       status,
+	})
+}
+
+func (n *WebhookNotifier) NotifyDocument(eventType EventType, customerID, organization, documentID, documentType string) {
+	n.notify(Event{
+		EventType:    eventType,
+		CustomerID:   customerID,
+		Organization: organization,
+		DocumentID:   documentID,
+		DocumentType: documentType,
+	})
+}
+
+func (n *WebhookNotifier) notify(event Event) {
+	if !n.subscribed(event.EventType) {
+		return
+	}
+	event.EventID = base.ID()
+	event.CreatedAt = time.Now()
+	n.workers.Go(func(ctx context.Context) {
+		n.deliver(ctx, event)
+	})
+}
+
+// deliver sends event until the receiver accepts it or maxAttempts is reached. An attempt in progress
+// finishes when ctx is cancelled but no more are made.
+func (n *WebhookNotifier) deliver(ctx context.Context, event Event) {
+	logger := n.logger.Set("eventID", log.String(event.EventID)).Set("customerID", log.String(event.CustomerID))
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.LogErrorf("problem encoding webhook event: %v", err)
+		return
+	}
+
+	backoff := n.backoff
+	for attempt := 1; attempt <= n.maxAttempts; attempt++ {
+		statusCode, err := n.send(body)
+		if err := n.repo.recordAttempt(event, attempt, statusCode, err); err != nil {
+			logger.LogErrorf("problem recording webhook attempt: %v", err)
+		}
+		if err == nil {
+			return
+		}
+		logger.LogErrorf("webhook attempt %d of %d failed: %v", attempt, n.maxAttempts, err)
+
+		if attempt < n.maxAttempts {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ctx.Done():
+				logger.LogErrorf("stopped webhook retries after attempt %d of %d: %v", attempt, n.maxAttempts, ctx.Err())
+				return
+			}
+		}
+	}
+}
+
+func (n *WebhookNotifier) send(body []byte) (int, error) {
+	req, err := http.NewRequest("POST", n.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(n.secret, body))
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign computes the signature sent in SignatureHeader for body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
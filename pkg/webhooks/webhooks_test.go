@@ -0,0 +1,192 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moov-io/customers/internal/background"
+)
+
+func TestWebhooks__ReadEventTypes(t *testing.T) {
+	events, err := ReadEventTypes("")
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	events, err = ReadEventTypes("customer.created, Customer.Deleted")
+	require.NoError(t, err)
+	require.Equal(t, []EventType{CustomerCreated, CustomerDeleted}, events)
+
+	_, err = ReadEventTypes("customer.created,other")
+	require.Error(t, err)
+}
+
+func TestWebhooks__NewNotifier(t *testing.T) {
+	notifier, err := NewNotifier(log.NewNopLogger(), nil, Config{})
+	require.NoError(t, err)
+	require.Nil(t, notifier)
+
+	_, err = NewNotifier(log.NewNopLogger(), nil, Config{Endpoint: "http://localhost"})
+	require.Error(t, err)
+}
+
+func TestWebhooks__deliver(t *testing.T) {
+	var mu sync.Mutex
+	var received []Event
+	calls := 0
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign([]byte("secret"), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var event Event
+		json.Unmarshal(body, &event)
+		received = append(received, event)
+	}))
+	defer svr.Close()
+
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+	repo := NewRepository(log.NewNopLogger(), db.DB)
+
+	notifier, err := NewNotifier(log.NewNopLogger(), repo, Config{
+		Endpoint:    svr.URL,
+		Secret:      "secret",
+		Events:      []EventType{CustomerCreated},
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	customerID := base.ID()
+	notifier.Notify(CustomerDeleted, customerID, "organization", "") // not subscribed
+	notifier.Notify(CustomerCreated, customerID, "organization", "Unknown")
+
+	require.Eventually(t, func() bool {
+		attempts, err := repo.getAttempts(customerID, 10)
+		return err == nil && len(attempts) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	require.Len(t, received, 1)
+	require.Equal(t, CustomerCreated, received[0].EventType)
+	require.Equal(t, customerID, received[0].CustomerID)
+	require.Equal(t, "Unknown", received[0].Status)
+	mu.Unlock()
+
+	attempts, err := repo.getAttempts(customerID, 10)
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts[0].Attempt)
+	require.Equal(t, http.StatusOK, attempts[0].StatusCode)
+	require.Equal(t, 1, attempts[1].Attempt)
+	require.Equal(t, http.StatusInternalServerError, attempts[1].StatusCode)
+	require.Contains(t, attempts[1].Error, "unexpected status")
+}
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []EventType
+}
+
+func (n *recordingNotifier) Notify(eventType EventType, customerID, organization, status string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, eventType)
+}
+
+func TestWebhooks__NotifyDocument(t *testing.T) {
+	received := make(chan Event, 1)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		json.NewDecoder(r.Body).Decode(&event)
+		received <- event
+	}))
+	defer svr.Close()
+
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+
+	webhook, err := NewNotifier(log.NewNopLogger(), NewRepository(log.NewNopLogger(), db.DB), Config{
+		Endpoint: svr.URL,
+		Secret:   "secret",
+	})
+	require.NoError(t, err)
+
+	// notifiers without Documents still receive the event
+	other := &recordingNotifier{}
+	notifier := MultiNotifier(webhook, other)
+
+	customerID, documentID := base.ID(), base.ID()
+	NotifyDocument(notifier, DocumentUploaded, customerID, "organization", documentID, "passport")
+	NotifyDocument(nil, DocumentUploaded, customerID, "organization", documentID, "passport")
+
+	select {
+	case event := <-received:
+		require.Equal(t, DocumentUploaded, event.EventType)
+		require.Equal(t, customerID, event.CustomerID)
+		require.Equal(t, documentID, event.DocumentID)
+		require.Equal(t, "passport", event.DocumentType)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for webhook")
+	}
+	require.Equal(t, []EventType{DocumentUploaded}, other.events)
+}
+
+func TestWebhooks__shutdown(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer svr.Close()
+
+	db := database.CreateTestSQLiteDB(t)
+	defer db.Close()
+	repo := NewRepository(log.NewNopLogger(), db.DB)
+
+	workers := background.NewGroup(context.Background())
+	notifier, err := NewNotifier(log.NewNopLogger(), repo, Config{
+		Endpoint:    svr.URL,
+		Secret:      "secret",
+		MaxAttempts: 3,
+		Backoff:     time.Hour,
+		Workers:     workers,
+	})
+	require.NoError(t, err)
+
+	customerID := base.ID()
+	notifier.Notify(CustomerCreated, customerID, "organization", "Unknown")
+	require.Eventually(t, func() bool {
+		attempts, err := repo.getAttempts(customerID, 10)
+		return err == nil && len(attempts) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// stopping doesn't wait out the backoff and no more attempts are made
+	require.True(t, workers.Stop(5*time.Second))
+	attempts, err := repo.getAttempts(customerID, 10)
+	require.NoError(t, err)
+	require.Len(t, attempts, 1)
+}